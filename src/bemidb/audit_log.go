@@ -0,0 +1,72 @@
+package bemidb
+
+import (
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BEMIDB_SCHEMA      = "bemidb"
+	BEMIDB_TABLE_AUDIT = "audit"
+)
+
+// Record of a committed sync, written once and never updated
+type AuditRecord struct {
+	Schema      string    `json:"schema"`
+	Table       string    `json:"table"`
+	Branch      string    `json:"branch"`
+	SnapshotId  int64     `json:"snapshot_id"`
+	CommittedAt time.Time `json:"committed_at"`
+	RecordCount int64     `json:"record_count"`
+	FileSize    int64     `json:"file_size"`
+	InitiatedBy string    `json:"initiated_by"`
+}
+
+// Column names and DuckDB types of the bemidb.audit table, in order
+var AUDIT_COLUMN_TYPES = NewOrderedMap([][]string{
+	{"schema", "varchar"},
+	{"table", "varchar"},
+	{"branch", "varchar"},
+	{"snapshot_id", "bigint"},
+	{"committed_at", "timestamptz"},
+	{"record_count", "bigint"},
+	{"file_size", "bigint"},
+	{"initiated_by", "varchar"},
+})
+
+// Sums up the data files of the snapshot, e.g. a file per partition
+func NewAuditRecord(icebergSchemaTable IcebergSchemaTable, branch string, snapshotId int64, parquetFiles []ParquetFile) AuditRecord {
+	var recordCount, fileSize int64
+	for _, parquetFile := range parquetFiles {
+		recordCount += parquetFile.RecordCount
+		fileSize += parquetFile.Size
+	}
+
+	return AuditRecord{
+		Schema:      icebergSchemaTable.Schema,
+		Table:       icebergSchemaTable.Table,
+		Branch:      branch,
+		SnapshotId:  snapshotId,
+		CommittedAt: time.Now().UTC(),
+		RecordCount: recordCount,
+		FileSize:    fileSize,
+		InitiatedBy: auditInitiatedBy(),
+	}
+}
+
+// Sorts by commit time, the UUID keeps records committed at the same time apart
+func (auditRecord AuditRecord) FileName() string {
+	return auditRecord.CommittedAt.Format("20060102T150405.000000000Z") + "-" + uuid.New().String() + ".json"
+}
+
+// OS user running the sync
+func auditInitiatedBy() string {
+	currentUser, err := user.Current()
+	if err == nil {
+		return currentUser.Username
+	}
+	return os.Getenv("USER")
+}
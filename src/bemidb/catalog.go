@@ -0,0 +1,40 @@
+package bemidb
+
+import (
+	"strings"
+)
+
+// Hadoop S3 filesystem schemes recorded by Spark, DuckDB only reads s3:// paths
+var CATALOG_S3_SCHEMES = []string{"s3a://", "s3n://"}
+
+// Resolves Iceberg tables and their current metadata files from an external catalog instead of listing the storage
+type Catalog interface {
+	Schemas() (icebergSchemas []string, err error)
+	SchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error)
+	MetadataFilePath(icebergSchemaTable IcebergSchemaTable) (path string, err error)
+}
+
+// Returns nil for the filesystem catalog, whose tables are listed from the storage
+func NewCatalog(config *Config) Catalog {
+	switch config.Catalog.Type {
+	case CATALOG_TYPE_GLUE:
+		return NewGlueCatalog(config)
+	case CATALOG_TYPE_REST:
+		return NewRestCatalog(config)
+	}
+
+	return nil
+}
+
+// s3a://bucket/path -> s3://bucket/path, file:///path -> /path
+func catalogMetadataLocation(metadataLocation string) string {
+	for _, scheme := range CATALOG_S3_SCHEMES {
+		if strings.HasPrefix(metadataLocation, scheme) {
+			return "s3://" + strings.TrimPrefix(metadataLocation, scheme)
+		}
+	}
+	if strings.HasPrefix(metadataLocation, "file:/") {
+		return "/" + strings.TrimLeft(strings.TrimPrefix(metadataLocation, "file:"), "/")
+	}
+	return metadataLocation
+}
@@ -0,0 +1,233 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	CATALOG_DIFF_ADDED   = "added"
+	CATALOG_DIFF_REMOVED = "removed"
+	CATALOG_DIFF_CHANGED = "changed"
+)
+
+// Difference of a table between a source and a target catalog, e.g. before and after a migration
+type CatalogTableDiff struct {
+	IcebergSchemaTable IcebergSchemaTable
+	Status             string // added to or removed from the target, or changed
+	ColumnDiffs        []CatalogColumnDiff
+	RecordCount        int64 // in the latest snapshot of the source
+	TargetRecordCount  int64 // in the latest snapshot of the target
+}
+
+type CatalogColumnDiff struct {
+	Name           string
+	Status         string
+	Type           string // in the source, empty if added
+	TargetType     string // in the target, empty if removed
+	Required       bool
+	TargetRequired bool
+}
+
+// Local path, s3://bucket/path, gs://bucket/path or az://container/path of the catalog to compare with, other storage options are shared
+func ParseCatalogDiffTargetConfig(config *Config, targetStoragePath string) *Config {
+	targetConfig := *config
+	targetConfig.Aws.S3TableBuckets = nil
+
+	if bucketPath, found := strings.CutPrefix(targetStoragePath, "s3://"); found {
+		bucket, path, _ := strings.Cut(bucketPath, "/")
+		if bucket == "" || path == "" {
+			panic("Invalid target storage path " + targetStoragePath + ". Must be a local path, s3://bucket/path, gs://bucket/path or az://container/path")
+		}
+		targetConfig.StorageType = STORAGE_TYPE_S3
+		targetConfig.Aws.S3Bucket = bucket
+		targetConfig.StoragePath = strings.TrimSuffix(path, "/")
+	} else if bucketPath, found := strings.CutPrefix(targetStoragePath, "gs://"); found {
+		bucket, path, _ := strings.Cut(bucketPath, "/")
+		if bucket == "" || path == "" {
+			panic("Invalid target storage path " + targetStoragePath + ". Must be a local path, s3://bucket/path, gs://bucket/path or az://container/path")
+		}
+		targetConfig.StorageType = STORAGE_TYPE_GCS
+		targetConfig.Gcs.Bucket = bucket
+		targetConfig.StoragePath = strings.TrimSuffix(path, "/")
+	} else if containerPath, found := strings.CutPrefix(targetStoragePath, "az://"); found {
+		container, path, _ := strings.Cut(containerPath, "/")
+		if container == "" || path == "" {
+			panic("Invalid target storage path " + targetStoragePath + ". Must be a local path, s3://bucket/path, gs://bucket/path or az://container/path")
+		}
+		targetConfig.StorageType = STORAGE_TYPE_AZURE
+		targetConfig.Azure.ContainerName = container
+		targetConfig.StoragePath = strings.TrimSuffix(path, "/")
+	} else {
+		targetConfig.StorageType = STORAGE_TYPE_LOCAL
+		targetConfig.StoragePath = targetStoragePath
+	}
+
+	return &targetConfig
+}
+
+// Compares the tables, columns of their current schemas, and record counts of their latest snapshots
+func DiffCatalogs(config *Config, targetConfig *Config) (catalogTableDiffs []CatalogTableDiff, err error) {
+	sourceReader := NewIcebergReader(config)
+	targetReader := NewIcebergReader(targetConfig)
+
+	sourceSchemaTables, err := sourceReader.SchemaTables()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read source tables: %v", err)
+	}
+	targetSchemaTables, err := targetReader.SchemaTables()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read target tables: %v", err)
+	}
+
+	for _, icebergSchemaTable := range catalogSchemaTables(sourceSchemaTables, targetSchemaTables) {
+		inSource := slices.Contains(sourceSchemaTables, icebergSchemaTable)
+		inTarget := slices.Contains(targetSchemaTables, icebergSchemaTable)
+		catalogTableDiff := CatalogTableDiff{IcebergSchemaTable: icebergSchemaTable}
+
+		var sourceFields, targetFields []IcebergSchemaField
+		if inSource {
+			sourceFields, catalogTableDiff.RecordCount, err = catalogTable(sourceReader, icebergSchemaTable)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if inTarget {
+			targetFields, catalogTableDiff.TargetRecordCount, err = catalogTable(targetReader, icebergSchemaTable)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case !inTarget:
+			catalogTableDiff.Status = CATALOG_DIFF_REMOVED
+		case !inSource:
+			catalogTableDiff.Status = CATALOG_DIFF_ADDED
+		default:
+			catalogTableDiff.ColumnDiffs = diffCatalogColumns(sourceFields, targetFields)
+			if len(catalogTableDiff.ColumnDiffs) == 0 && catalogTableDiff.RecordCount == catalogTableDiff.TargetRecordCount {
+				continue
+			}
+			catalogTableDiff.Status = CATALOG_DIFF_CHANGED
+		}
+
+		catalogTableDiffs = append(catalogTableDiffs, catalogTableDiff)
+	}
+
+	return catalogTableDiffs, nil
+}
+
+// One line per table prefixed with +, - or ~ like a diff, e.g. ~ "public"."orders" (3 -> 4 records),
+// followed by an indented line per column, e.g. ~ column "total" int -> long
+func (catalogTableDiff CatalogTableDiff) String() string {
+	var lines []string
+
+	switch catalogTableDiff.Status {
+	case CATALOG_DIFF_ADDED:
+		lines = append(lines, fmt.Sprintf("+ %s (%d records)", catalogTableDiff.IcebergSchemaTable.String(), catalogTableDiff.TargetRecordCount))
+	case CATALOG_DIFF_REMOVED:
+		lines = append(lines, fmt.Sprintf("- %s (%d records)", catalogTableDiff.IcebergSchemaTable.String(), catalogTableDiff.RecordCount))
+	case CATALOG_DIFF_CHANGED:
+		lines = append(lines, fmt.Sprintf("~ %s (%d -> %d records)", catalogTableDiff.IcebergSchemaTable.String(), catalogTableDiff.RecordCount, catalogTableDiff.TargetRecordCount))
+	}
+
+	for _, columnDiff := range catalogTableDiff.ColumnDiffs {
+		switch columnDiff.Status {
+		case CATALOG_DIFF_ADDED:
+			lines = append(lines, fmt.Sprintf("  + column \"%s\" %s", columnDiff.Name, columnDiff.TargetType))
+		case CATALOG_DIFF_REMOVED:
+			lines = append(lines, fmt.Sprintf("  - column \"%s\" %s", columnDiff.Name, columnDiff.Type))
+		case CATALOG_DIFF_CHANGED:
+			lines = append(lines, fmt.Sprintf("  ~ column \"%s\" %s -> %s", columnDiff.Name, columnDiff.describe(columnDiff.Type, columnDiff.Required), columnDiff.describe(columnDiff.TargetType, columnDiff.TargetRequired)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (columnDiff CatalogColumnDiff) describe(columnType string, required bool) string {
+	if required {
+		return columnType + " not null"
+	}
+	return columnType
+}
+
+func catalogTable(reader *IcebergReader, icebergSchemaTable IcebergSchemaTable) (icebergSchemaFields []IcebergSchemaField, recordCount int64, err error) {
+	icebergSchemaFields, err = reader.SchemaFields(icebergSchemaTable, 0)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to read the schema of %s: %v", icebergSchemaTable.String(), err)
+	}
+
+	icebergTableStats, err := reader.TableStats(icebergSchemaTable)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to read the stats of %s: %v", icebergSchemaTable.String(), err)
+	}
+
+	return icebergSchemaFields, icebergTableStats.RecordCount, nil
+}
+
+// Tables of both catalogs in schema and table name order
+func catalogSchemaTables(sourceSchemaTables []IcebergSchemaTable, targetSchemaTables []IcebergSchemaTable) []IcebergSchemaTable {
+	icebergSchemaTables := slices.Clone(sourceSchemaTables)
+	for _, icebergSchemaTable := range targetSchemaTables {
+		if !slices.Contains(icebergSchemaTables, icebergSchemaTable) {
+			icebergSchemaTables = append(icebergSchemaTables, icebergSchemaTable)
+		}
+	}
+
+	slices.SortFunc(icebergSchemaTables, func(a, b IcebergSchemaTable) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return icebergSchemaTables
+}
+
+// Columns are matched by name, since field ids of separately synced catalogs don't have to match
+func diffCatalogColumns(sourceFields []IcebergSchemaField, targetFields []IcebergSchemaField) (columnDiffs []CatalogColumnDiff) {
+	targetFieldsByName := map[string]IcebergSchemaField{}
+	for _, targetField := range targetFields {
+		targetFieldsByName[targetField.Name] = targetField
+	}
+
+	for _, sourceField := range sourceFields {
+		targetField, ok := targetFieldsByName[sourceField.Name]
+		if !ok {
+			columnDiffs = append(columnDiffs, CatalogColumnDiff{Name: sourceField.Name, Status: CATALOG_DIFF_REMOVED, Type: icebergFieldType(sourceField), Required: sourceField.Required})
+			continue
+		}
+		delete(targetFieldsByName, sourceField.Name)
+
+		sourceType, targetType := icebergFieldType(sourceField), icebergFieldType(targetField)
+		if sourceType != targetType || sourceField.Required != targetField.Required {
+			columnDiffs = append(columnDiffs, CatalogColumnDiff{
+				Name:           sourceField.Name,
+				Status:         CATALOG_DIFF_CHANGED,
+				Type:           sourceType,
+				TargetType:     targetType,
+				Required:       sourceField.Required,
+				TargetRequired: targetField.Required,
+			})
+		}
+	}
+
+	for _, targetField := range targetFields {
+		if _, ok := targetFieldsByName[targetField.Name]; ok {
+			columnDiffs = append(columnDiffs, CatalogColumnDiff{Name: targetField.Name, Status: CATALOG_DIFF_ADDED, TargetType: icebergFieldType(targetField), TargetRequired: targetField.Required})
+		}
+	}
+
+	return columnDiffs
+}
+
+// Primitive types are strings, nested types such as lists are compared by their JSON
+func icebergFieldType(icebergSchemaField IcebergSchemaField) string {
+	if fieldType, ok := icebergSchemaField.Type.(string); ok {
+		return fieldType
+	}
+
+	fieldTypeJson, err := json.Marshal(icebergSchemaField.Type)
+	PanicIfError(err)
+	return string(fieldTypeJson)
+}
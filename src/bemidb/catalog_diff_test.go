@@ -0,0 +1,141 @@
+package bemidb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffCatalogs(t *testing.T) {
+	idColumn := PgSchemaColumn{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"}
+	nameColumn := PgSchemaColumn{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"}
+	totalColumn := PgSchemaColumn{ColumnName: "total", DataType: "integer", UdtName: "int4", IsNullable: "YES", OrdinalPosition: "2", NumericPrecision: "32", Namespace: "pg_catalog"}
+	bigTotalColumn := PgSchemaColumn{ColumnName: "total", DataType: "bigint", UdtName: "int8", IsNullable: "YES", OrdinalPosition: "2", NumericPrecision: "64", Namespace: "pg_catalog"}
+	usersTable := IcebergSchemaTable{Schema: "public", Table: "users"}
+	ordersTable := IcebergSchemaTable{Schema: "public", Table: "orders"}
+	eventsTable := IcebergSchemaTable{Schema: "public", Table: "events"}
+
+	t.Run("Reports removed and changed tables with column drift", func(t *testing.T) {
+		sourceConfig := testCatalogConfig(t)
+		testWriteCatalogTable(sourceConfig, usersTable, []PgSchemaColumn{idColumn, nameColumn}, [][]string{{"1", "Alice"}})
+		testWriteCatalogTable(sourceConfig, ordersTable, []PgSchemaColumn{idColumn, totalColumn}, [][]string{{"1", "10"}})
+		testWriteCatalogTable(sourceConfig, eventsTable, []PgSchemaColumn{idColumn}, [][]string{{"1"}, {"2"}})
+		targetConfig := testCatalogConfig(t)
+		testWriteCatalogTable(targetConfig, usersTable, []PgSchemaColumn{idColumn, nameColumn}, [][]string{{"1", "Alice"}})
+		testWriteCatalogTable(targetConfig, ordersTable, []PgSchemaColumn{idColumn, bigTotalColumn}, [][]string{{"1", "10"}})
+
+		catalogTableDiffs, err := DiffCatalogs(sourceConfig, targetConfig)
+
+		testNoError(t, err)
+		if len(catalogTableDiffs) != 2 {
+			t.Fatalf("Expected 2 table diffs, got %v", catalogTableDiffs)
+		}
+		if catalogTableDiffs[0].String() != "- \"public\".\"events\" (2 records)" {
+			t.Errorf("Expected the events table to be removed, got %v", catalogTableDiffs[0].String())
+		}
+		if catalogTableDiffs[1].String() != "~ \"public\".\"orders\" (1 -> 1 records)\n  ~ column \"total\" int -> long" {
+			t.Errorf("Expected the total column of the orders table to change, got %v", catalogTableDiffs[1].String())
+		}
+	})
+
+	t.Run("Reports added tables and columns in the other direction", func(t *testing.T) {
+		sourceConfig := testCatalogConfig(t)
+		testWriteCatalogTable(sourceConfig, usersTable, []PgSchemaColumn{idColumn}, [][]string{{"1"}})
+		targetConfig := testCatalogConfig(t)
+		testWriteCatalogTable(targetConfig, usersTable, []PgSchemaColumn{idColumn, nameColumn}, [][]string{{"1", "Alice"}, {"2", "Bob"}})
+		testWriteCatalogTable(targetConfig, eventsTable, []PgSchemaColumn{idColumn}, [][]string{{"1"}})
+
+		catalogTableDiffs, err := DiffCatalogs(sourceConfig, targetConfig)
+
+		testNoError(t, err)
+		if len(catalogTableDiffs) != 2 {
+			t.Fatalf("Expected 2 table diffs, got %v", catalogTableDiffs)
+		}
+		if catalogTableDiffs[0].String() != "+ \"public\".\"events\" (1 records)" {
+			t.Errorf("Expected the events table to be added, got %v", catalogTableDiffs[0].String())
+		}
+		if catalogTableDiffs[1].String() != "~ \"public\".\"users\" (1 -> 2 records)\n  + column \"name\" string" {
+			t.Errorf("Expected the name column of the users table to be added, got %v", catalogTableDiffs[1].String())
+		}
+	})
+
+	t.Run("Returns no diffs for identical catalogs", func(t *testing.T) {
+		sourceConfig := testCatalogConfig(t)
+		testWriteCatalogTable(sourceConfig, usersTable, []PgSchemaColumn{idColumn, nameColumn}, [][]string{{"1", "Alice"}})
+		targetConfig := testCatalogConfig(t)
+		testWriteCatalogTable(targetConfig, usersTable, []PgSchemaColumn{idColumn, nameColumn}, [][]string{{"1", "Alice"}})
+
+		catalogTableDiffs, err := DiffCatalogs(sourceConfig, targetConfig)
+
+		testNoError(t, err)
+		if len(catalogTableDiffs) != 0 {
+			t.Errorf("Expected no table diffs, got %v", catalogTableDiffs)
+		}
+	})
+}
+
+func TestParseCatalogDiffTargetConfig(t *testing.T) {
+	t.Run("Parses an S3 storage path", func(t *testing.T) {
+		config := loadTestConfig()
+
+		targetConfig := ParseCatalogDiffTargetConfig(config, "s3://dr-bucket/iceberg/")
+
+		if targetConfig.StorageType != STORAGE_TYPE_S3 || targetConfig.Aws.S3Bucket != "dr-bucket" || targetConfig.StoragePath != "iceberg" {
+			t.Errorf("Expected the dr-bucket S3 storage, got %v %v %v", targetConfig.StorageType, targetConfig.Aws.S3Bucket, targetConfig.StoragePath)
+		}
+		if config.StoragePath == targetConfig.StoragePath {
+			t.Errorf("Expected the source config to be left unchanged")
+		}
+	})
+
+	t.Run("Parses a GCS storage path", func(t *testing.T) {
+		config := loadTestConfig()
+
+		targetConfig := ParseCatalogDiffTargetConfig(config, "gs://dr-bucket/iceberg")
+
+		if targetConfig.StorageType != STORAGE_TYPE_GCS || targetConfig.Gcs.Bucket != "dr-bucket" || targetConfig.StoragePath != "iceberg" {
+			t.Errorf("Expected the dr-bucket GCS storage, got %v %v %v", targetConfig.StorageType, targetConfig.Gcs.Bucket, targetConfig.StoragePath)
+		}
+	})
+
+	t.Run("Parses an Azure storage path", func(t *testing.T) {
+		config := loadTestConfig()
+
+		targetConfig := ParseCatalogDiffTargetConfig(config, "az://dr-container/iceberg/")
+
+		if targetConfig.StorageType != STORAGE_TYPE_AZURE || targetConfig.Azure.ContainerName != "dr-container" || targetConfig.StoragePath != "iceberg" {
+			t.Errorf("Expected the dr-container Azure storage, got %v %v %v", targetConfig.StorageType, targetConfig.Azure.ContainerName, targetConfig.StoragePath)
+		}
+	})
+
+	t.Run("Parses a local storage path", func(t *testing.T) {
+		config := loadTestConfig()
+
+		targetConfig := ParseCatalogDiffTargetConfig(config, "../iceberg-dr")
+
+		if targetConfig.StorageType != STORAGE_TYPE_LOCAL || targetConfig.StoragePath != "../iceberg-dr" {
+			t.Errorf("Expected the local storage, got %v %v", targetConfig.StorageType, targetConfig.StoragePath)
+		}
+	})
+}
+
+// Local storage paths are relative to the working directory
+func testCatalogConfig(t *testing.T) *Config {
+	config := *loadTestConfig()
+	workingDir, err := os.Getwd()
+	testNoError(t, err)
+	config.StoragePath, err = filepath.Rel(workingDir, t.TempDir())
+	testNoError(t, err)
+	return &config
+}
+
+func testWriteCatalogTable(config *Config, schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, rows [][]string) {
+	loaded := false
+	NewIcebergWriter(config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+		if loaded {
+			return [][]string{}
+		}
+		loaded = true
+		return rows
+	})
+}
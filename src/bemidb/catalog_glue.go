@@ -0,0 +1,216 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	// Glue marks Iceberg tables with this table_type parameter, other tables in a Glue database are ignored
+	GLUE_ICEBERG_TABLE_TYPE = "ICEBERG"
+
+	GLUE_REQUEST_TIMEOUT = 30 * time.Second
+)
+
+// Resolves Iceberg tables and their current metadata files from the AWS Glue Data Catalog instead of listing the storage.
+// Glue databases are Iceberg schemas, e.g. of tables written by Spark or Athena
+type GlueCatalog struct {
+	config         *Config
+	awsCredentials aws.CredentialsProvider
+	httpClient     *http.Client
+	endpoint       string
+}
+
+type GlueTable struct {
+	Name         string            `json:"Name"`
+	DatabaseName string            `json:"DatabaseName"`
+	Parameters   map[string]string `json:"Parameters"`
+}
+
+type glueError struct {
+	Type    string `json:"__type"`
+	Message string `json:"Message"`
+}
+
+func NewGlueCatalog(config *Config) *GlueCatalog {
+	endpoint := config.Aws.GlueEndpoint
+	if endpoint == "" {
+		endpoint = "https://glue." + config.Aws.Region + ".amazonaws.com"
+	}
+
+	return &GlueCatalog{
+		config:         config,
+		awsCredentials: newAwsCredentialsProvider(config),
+		httpClient:     &http.Client{Timeout: GLUE_REQUEST_TIMEOUT},
+		endpoint:       strings.TrimSuffix(endpoint, "/"),
+	}
+}
+
+func (catalog *GlueCatalog) Schemas() (icebergSchemas []string, err error) {
+	var nextToken string
+	for {
+		var response struct {
+			DatabaseList []struct {
+				Name string `json:"Name"`
+			} `json:"DatabaseList"`
+			NextToken string `json:"NextToken"`
+		}
+		err = catalog.request("GetDatabases", catalog.requestParams(map[string]interface{}{"NextToken": nextToken}), &response)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, database := range response.DatabaseList {
+			icebergSchemas = append(icebergSchemas, database.Name)
+		}
+		if response.NextToken == "" {
+			return icebergSchemas, nil
+		}
+		nextToken = response.NextToken
+	}
+}
+
+func (catalog *GlueCatalog) SchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	icebergSchemas, err := catalog.Schemas()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, icebergSchema := range icebergSchemas {
+		glueTables, err := catalog.tables(icebergSchema)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, glueTable := range glueTables {
+			if glueTable.isIceberg() {
+				icebergSchemaTables = append(icebergSchemaTables, IcebergSchemaTable{Schema: icebergSchema, Table: glueTable.Name})
+			}
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+// Returns the metadata file that Glue records as the current one of the table
+func (catalog *GlueCatalog) MetadataFilePath(icebergSchemaTable IcebergSchemaTable) (path string, err error) {
+	var response struct {
+		Table GlueTable `json:"Table"`
+	}
+	err = catalog.request("GetTable", catalog.requestParams(map[string]interface{}{
+		"DatabaseName": icebergSchemaTable.Schema,
+		"Name":         icebergSchemaTable.Table,
+	}), &response)
+	if err != nil {
+		return "", err
+	}
+
+	if !response.Table.isIceberg() {
+		return "", fmt.Errorf("Glue table %s is not an Iceberg table", icebergSchemaTable.String())
+	}
+	metadataLocation := response.Table.Parameters["metadata_location"]
+	if metadataLocation == "" {
+		return "", fmt.Errorf("Glue table %s has no metadata location", icebergSchemaTable.String())
+	}
+
+	return catalogMetadataLocation(metadataLocation), nil
+}
+
+func (catalog *GlueCatalog) tables(databaseName string) (glueTables []GlueTable, err error) {
+	var nextToken string
+	for {
+		var response struct {
+			TableList []GlueTable `json:"TableList"`
+			NextToken string      `json:"NextToken"`
+		}
+		err = catalog.request("GetTables", catalog.requestParams(map[string]interface{}{
+			"DatabaseName": databaseName,
+			"NextToken":    nextToken,
+		}), &response)
+		if err != nil {
+			return nil, err
+		}
+
+		glueTables = append(glueTables, response.TableList...)
+		if response.NextToken == "" {
+			return glueTables, nil
+		}
+		nextToken = response.NextToken
+	}
+}
+
+// Leaves out empty params, and adds the catalog ID of another AWS account if configured
+func (catalog *GlueCatalog) requestParams(params map[string]interface{}) map[string]interface{} {
+	if catalog.config.Aws.GlueCatalogId != "" {
+		params["CatalogId"] = catalog.config.Aws.GlueCatalogId
+	}
+	for key, value := range params {
+		if value == "" {
+			delete(params, key)
+		}
+	}
+	return params
+}
+
+// Calls a Glue API action with the AWS JSON 1.1 protocol, signed with the AWS credentials
+func (catalog *GlueCatalog) request(action string, params map[string]interface{}, response interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), GLUE_REQUEST_TIMEOUT)
+	defer cancel()
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, catalog.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", "AWSGlue."+action)
+
+	awsCredentials, err := catalog.awsCredentials.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256.Sum256(body)
+	err = v4.NewSigner().SignHTTP(ctx, awsCredentials, request, hex.EncodeToString(payloadHash[:]), "glue", catalog.config.Aws.Region, time.Now())
+	if err != nil {
+		return err
+	}
+
+	LogDebug(catalog.config, "Glue: Calling", action, string(body))
+	httpResponse, err := catalog.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("Glue %s request failed: %v", action, err)
+	}
+	defer httpResponse.Body.Close()
+
+	responseBody, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		var errorResponse glueError
+		json.Unmarshal(responseBody, &errorResponse)
+		// E.g. "com.amazonaws.glue#EntityNotFoundException"
+		errorType := errorResponse.Type[strings.LastIndex(errorResponse.Type, "#")+1:]
+		return fmt.Errorf("Glue %s request failed with status %d: %s %s", action, httpResponse.StatusCode, errorType, errorResponse.Message)
+	}
+
+	return json.Unmarshal(responseBody, response)
+}
+
+func (glueTable GlueTable) isIceberg() bool {
+	return strings.EqualFold(glueTable.Parameters["table_type"], GLUE_ICEBERG_TABLE_TYPE)
+}
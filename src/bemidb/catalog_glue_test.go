@@ -0,0 +1,127 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGlueCatalog(t *testing.T) {
+	glueTables := map[string][]GlueTable{
+		"analytics": {
+			{Name: "events", DatabaseName: "analytics", Parameters: map[string]string{"table_type": "ICEBERG", "metadata_location": "s3a://warehouse/analytics/events/metadata/00002-abc.metadata.json"}},
+			{Name: "raw_logs", DatabaseName: "analytics", Parameters: map[string]string{"classification": "csv"}},
+		},
+		"sales": {
+			{Name: "orders", DatabaseName: "sales", Parameters: map[string]string{"table_type": "iceberg", "metadata_location": "s3://warehouse/sales/orders/metadata/00001-def.metadata.json"}},
+		},
+	}
+
+	// Returns a page per database and per table, like Glue does with a small MaxResults
+	startGlueServer := func(t *testing.T) (config *Config, requestedTargets *[]string) {
+		requestedTargets = &[]string{}
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			target := request.Header.Get("X-Amz-Target")
+			*requestedTargets = append(*requestedTargets, target)
+			if !strings.HasPrefix(request.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+				t.Errorf("Expected a signed request, got %v", request.Header)
+			}
+
+			body, err := io.ReadAll(request.Body)
+			testNoError(t, err)
+			var params map[string]string
+			testNoError(t, json.Unmarshal(body, &params))
+
+			var response interface{}
+			switch target {
+			case "AWSGlue.GetDatabases":
+				if params["NextToken"] == "" {
+					response = map[string]interface{}{"DatabaseList": []map[string]string{{"Name": "analytics"}}, "NextToken": "sales"}
+				} else {
+					response = map[string]interface{}{"DatabaseList": []map[string]string{{"Name": params["NextToken"]}}}
+				}
+			case "AWSGlue.GetTables":
+				tables := glueTables[params["DatabaseName"]]
+				if params["NextToken"] == "" && len(tables) > 1 {
+					response = map[string]interface{}{"TableList": tables[:1], "NextToken": "1"}
+				} else if params["NextToken"] == "1" {
+					response = map[string]interface{}{"TableList": tables[1:]}
+				} else {
+					response = map[string]interface{}{"TableList": tables}
+				}
+			case "AWSGlue.GetTable":
+				for _, glueTable := range glueTables[params["DatabaseName"]] {
+					if glueTable.Name == params["Name"] {
+						response = map[string]interface{}{"Table": glueTable}
+					}
+				}
+				if response == nil {
+					writer.WriteHeader(http.StatusBadRequest)
+					response = map[string]string{"__type": "com.amazonaws.glue#EntityNotFoundException", "Message": "Table " + params["Name"] + " not found."}
+				}
+			}
+			json.NewEncoder(writer).Encode(response)
+		}))
+		t.Cleanup(server.Close)
+
+		config = loadTestConfig()
+		config.Catalog.Type = CATALOG_TYPE_GLUE
+		config.Aws.Region = "us-west-2"
+		config.Aws.AccessKeyId = "access-key-id"
+		config.Aws.SecretAccessKey = "secret-access-key"
+		config.Aws.GlueEndpoint = server.URL
+		return config, requestedTargets
+	}
+
+	t.Run("Lists the Iceberg tables of all Glue databases", func(t *testing.T) {
+		config, requestedTargets := startGlueServer(t)
+
+		icebergSchemaTables, err := NewIcebergReader(config).SchemaTables()
+
+		testNoError(t, err)
+		if len(icebergSchemaTables) != 2 || icebergSchemaTables[0].String() != `"analytics"."events"` || icebergSchemaTables[1].String() != `"sales"."orders"` {
+			t.Errorf("Expected the Iceberg tables of both databases, got %v", icebergSchemaTables)
+		}
+		if strings.Join(*requestedTargets, ",") != "AWSGlue.GetDatabases,AWSGlue.GetDatabases,AWSGlue.GetTables,AWSGlue.GetTables,AWSGlue.GetTables" {
+			t.Errorf("Expected the databases and tables to be paginated, got %v", *requestedTargets)
+		}
+	})
+
+	t.Run("Reads the metadata location recorded in Glue", func(t *testing.T) {
+		config, _ := startGlueServer(t)
+		icebergReader := NewIcebergReader(config)
+
+		eventsMetadataFilePath := icebergReader.MetadataFilePath(IcebergSchemaTable{Schema: "analytics", Table: "events"})
+		ordersMetadataFilePath := icebergReader.MetadataFilePath(IcebergSchemaTable{Schema: "sales", Table: "orders"})
+
+		if eventsMetadataFilePath != "s3://warehouse/analytics/events/metadata/00002-abc.metadata.json" {
+			t.Errorf("Expected the s3a:// location to be read as s3://, got %v", eventsMetadataFilePath)
+		}
+		if ordersMetadataFilePath != "s3://warehouse/sales/orders/metadata/00001-def.metadata.json" {
+			t.Errorf("Expected the metadata location of the table, got %v", ordersMetadataFilePath)
+		}
+	})
+
+	t.Run("Returns an error for a table that isn't in Glue", func(t *testing.T) {
+		config, _ := startGlueServer(t)
+
+		_, err := NewGlueCatalog(config).MetadataFilePath(IcebergSchemaTable{Schema: "sales", Table: "refunds"})
+
+		if err == nil || err.Error() != "Glue GetTable request failed with status 400: EntityNotFoundException Table refunds not found." {
+			t.Errorf("Expected an error for the missing table, got %v", err)
+		}
+	})
+
+	t.Run("Returns an error for a table that isn't an Iceberg table", func(t *testing.T) {
+		config, _ := startGlueServer(t)
+
+		_, err := NewGlueCatalog(config).MetadataFilePath(IcebergSchemaTable{Schema: "analytics", Table: "raw_logs"})
+
+		if err == nil || !strings.Contains(err.Error(), "is not an Iceberg table") {
+			t.Errorf("Expected an error for the CSV table, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package bemidb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	REST_CATALOG_REQUEST_TIMEOUT = 30 * time.Second
+
+	// Separates the parts of a nested namespace in request paths, e.g. "analytics\x1Fevents"
+	REST_CATALOG_NAMESPACE_SEPARATOR = "\x1F"
+)
+
+// Resolves Iceberg tables and their current metadata files from an Iceberg REST catalog, e.g. Polaris or Tabular.
+// Top-level namespaces are Iceberg schemas
+//
+// https://github.com/apache/iceberg/blob/main/open-api/rest-catalog-open-api.yaml
+type RestCatalog struct {
+	config     *Config
+	httpClient *http.Client
+
+	// Path prefix returned by the catalog for the warehouse, e.g. "my-catalog" in /v1/my-catalog/namespaces
+	prefix       string
+	prefixLoaded bool
+	prefixMutex  sync.Mutex
+}
+
+type restCatalogError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func NewRestCatalog(config *Config) *RestCatalog {
+	return &RestCatalog{
+		config:     config,
+		httpClient: &http.Client{Timeout: REST_CATALOG_REQUEST_TIMEOUT},
+	}
+}
+
+func (catalog *RestCatalog) Schemas() (icebergSchemas []string, err error) {
+	// An empty page token asks the catalog to paginate if it supports it
+	var pageToken string
+	for {
+		var response struct {
+			Namespaces    [][]string `json:"namespaces"`
+			NextPageToken string     `json:"next-page-token"`
+		}
+		err = catalog.request("/namespaces", url.Values{"pageToken": {pageToken}}, &response)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, namespace := range response.Namespaces {
+			icebergSchemas = append(icebergSchemas, strings.Join(namespace, "."))
+		}
+		if response.NextPageToken == "" {
+			return icebergSchemas, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+func (catalog *RestCatalog) SchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	icebergSchemas, err := catalog.Schemas()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, icebergSchema := range icebergSchemas {
+		// An empty page token asks the catalog to paginate if it supports it
+		var pageToken string
+		for {
+			var response struct {
+				Identifiers []struct {
+					Name string `json:"name"`
+				} `json:"identifiers"`
+				NextPageToken string `json:"next-page-token"`
+			}
+			err = catalog.request(catalog.namespacePath(icebergSchema)+"/tables", url.Values{"pageToken": {pageToken}}, &response)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, identifier := range response.Identifiers {
+				icebergSchemaTables = append(icebergSchemaTables, IcebergSchemaTable{Schema: icebergSchema, Table: identifier.Name})
+			}
+			if response.NextPageToken == "" {
+				break
+			}
+			pageToken = response.NextPageToken
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+// Returns the metadata file that the catalog records as the current one of the table
+func (catalog *RestCatalog) MetadataFilePath(icebergSchemaTable IcebergSchemaTable) (path string, err error) {
+	var response struct {
+		MetadataLocation string `json:"metadata-location"`
+	}
+	err = catalog.request(catalog.namespacePath(icebergSchemaTable.Schema)+"/tables/"+url.PathEscape(icebergSchemaTable.Table), nil, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if response.MetadataLocation == "" {
+		return "", fmt.Errorf("REST catalog table %s has no metadata location", icebergSchemaTable.String())
+	}
+	return catalogMetadataLocation(response.MetadataLocation), nil
+}
+
+// "analytics.events" -> /namespaces/analytics%1Fevents
+func (catalog *RestCatalog) namespacePath(icebergSchema string) string {
+	namespace := strings.ReplaceAll(icebergSchema, ".", REST_CATALOG_NAMESPACE_SEPARATOR)
+	return "/namespaces/" + url.PathEscape(namespace)
+}
+
+// Reads the path prefix of the warehouse from the catalog config before the first request
+func (catalog *RestCatalog) loadPrefix() error {
+	catalog.prefixMutex.Lock()
+	defer catalog.prefixMutex.Unlock()
+	if catalog.prefixLoaded {
+		return nil
+	}
+
+	configUrl := catalog.config.Catalog.Uri + "/v1/config"
+	if catalog.config.Catalog.Warehouse != "" {
+		configUrl += "?warehouse=" + url.QueryEscape(catalog.config.Catalog.Warehouse)
+	}
+
+	var response struct {
+		Defaults  map[string]string `json:"defaults"`
+		Overrides map[string]string `json:"overrides"`
+	}
+	err := catalog.get(configUrl, &response)
+	if err != nil {
+		return err
+	}
+
+	catalog.prefix = response.Defaults["prefix"]
+	if response.Overrides["prefix"] != "" {
+		catalog.prefix = response.Overrides["prefix"]
+	}
+	catalog.prefixLoaded = true
+	return nil
+}
+
+// Calls a catalog endpoint under /v1/{prefix}
+func (catalog *RestCatalog) request(path string, params url.Values, response interface{}) error {
+	err := catalog.loadPrefix()
+	if err != nil {
+		return err
+	}
+
+	requestUrl := catalog.config.Catalog.Uri + "/v1"
+	if catalog.prefix != "" {
+		requestUrl += "/" + strings.Trim(catalog.prefix, "/")
+	}
+	requestUrl += path
+	if params != nil {
+		requestUrl += "?" + params.Encode()
+	}
+
+	return catalog.get(requestUrl, response)
+}
+
+func (catalog *RestCatalog) get(requestUrl string, response interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), REST_CATALOG_REQUEST_TIMEOUT)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if catalog.config.Catalog.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+catalog.config.Catalog.Token)
+	}
+
+	LogDebug(catalog.config, "REST catalog: Requesting", requestUrl)
+	httpResponse, err := catalog.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("REST catalog request failed: %v", err)
+	}
+	defer httpResponse.Body.Close()
+
+	responseBody, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		var errorResponse restCatalogError
+		json.Unmarshal(responseBody, &errorResponse)
+		return fmt.Errorf("REST catalog request %s failed with status %d: %s %s", request.URL.Path, httpResponse.StatusCode, errorResponse.Error.Type, errorResponse.Error.Message)
+	}
+
+	return json.Unmarshal(responseBody, response)
+}
@@ -0,0 +1,126 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestCatalog(t *testing.T) {
+	testTableMetadataFilePath, _ := filepath.Abs("../../iceberg-test/public/test_table/metadata/v1.metadata.json")
+
+	// Returns a page per namespace and per table, like a catalog with a small page size does
+	startRestCatalogServer := func(t *testing.T) (config *Config, requestedPaths *[]string) {
+		requestedPaths = &[]string{}
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			*requestedPaths = append(*requestedPaths, request.URL.RequestURI())
+			if request.Header.Get("Authorization") != "Bearer catalog-token" {
+				t.Errorf("Expected the bearer token, got %v", request.Header.Get("Authorization"))
+			}
+
+			pageToken := request.URL.Query().Get("pageToken")
+			var response interface{}
+			switch request.URL.Path {
+			case "/v1/config":
+				response = map[string]interface{}{"defaults": map[string]string{}, "overrides": map[string]string{"prefix": "warehouse-" + request.URL.Query().Get("warehouse")}}
+			case "/v1/warehouse-analytics/namespaces":
+				if pageToken == "" {
+					response = map[string]interface{}{"namespaces": [][]string{{"public"}}, "next-page-token": "2"}
+				} else {
+					response = map[string]interface{}{"namespaces": [][]string{{"sales", "eu"}}, "next-page-token": nil}
+				}
+			case "/v1/warehouse-analytics/namespaces/public/tables":
+				if pageToken == "" {
+					response = map[string]interface{}{"identifiers": []map[string]interface{}{{"namespace": []string{"public"}, "name": "test_table"}}, "next-page-token": "2"}
+				} else {
+					response = map[string]interface{}{"identifiers": []map[string]interface{}{{"namespace": []string{"public"}, "name": "partitioned_table"}}}
+				}
+			case "/v1/warehouse-analytics/namespaces/sales\x1Feu/tables":
+				response = map[string]interface{}{"identifiers": []map[string]interface{}{{"namespace": []string{"sales", "eu"}, "name": "orders"}}}
+			case "/v1/warehouse-analytics/namespaces/public/tables/test_table":
+				response = map[string]interface{}{"metadata-location": "file://" + testTableMetadataFilePath, "metadata": map[string]interface{}{}}
+			case "/v1/warehouse-analytics/namespaces/sales\x1Feu/tables/orders":
+				response = map[string]interface{}{"metadata-location": "s3a://warehouse/sales/eu/orders/metadata/00001-abc.metadata.json", "metadata": map[string]interface{}{}}
+			default:
+				writer.WriteHeader(http.StatusNotFound)
+				response = map[string]interface{}{"error": map[string]interface{}{"message": "Table does not exist", "type": "NoSuchTableException", "code": 404}}
+			}
+			json.NewEncoder(writer).Encode(response)
+		}))
+		t.Cleanup(server.Close)
+
+		config = loadTestConfig()
+		config.Catalog.Type = CATALOG_TYPE_REST
+		config.Catalog.Uri = server.URL
+		config.Catalog.Token = "catalog-token"
+		config.Catalog.Warehouse = "analytics"
+		return config, requestedPaths
+	}
+
+	t.Run("Lists the tables of all namespaces page by page", func(t *testing.T) {
+		config, requestedPaths := startRestCatalogServer(t)
+
+		icebergSchemaTables, err := NewIcebergReader(config).SchemaTables()
+
+		testNoError(t, err)
+		if len(icebergSchemaTables) != 3 ||
+			icebergSchemaTables[0].String() != `"public"."test_table"` ||
+			icebergSchemaTables[1].String() != `"public"."partitioned_table"` ||
+			icebergSchemaTables[2].String() != `"sales.eu"."orders"` {
+			t.Errorf("Expected the tables of all namespaces, got %v", icebergSchemaTables)
+		}
+		expectedPaths := []string{
+			"/v1/config?warehouse=analytics",
+			"/v1/warehouse-analytics/namespaces?pageToken=",
+			"/v1/warehouse-analytics/namespaces?pageToken=2",
+			"/v1/warehouse-analytics/namespaces/public/tables?pageToken=",
+			"/v1/warehouse-analytics/namespaces/public/tables?pageToken=2",
+			"/v1/warehouse-analytics/namespaces/sales%1Feu/tables?pageToken=",
+		}
+		if strings.Join(*requestedPaths, ",") != strings.Join(expectedPaths, ",") {
+			t.Errorf("Expected the namespaces and tables to be paginated, got %v", *requestedPaths)
+		}
+	})
+
+	t.Run("Reads the metadata location recorded in the catalog", func(t *testing.T) {
+		config, _ := startRestCatalogServer(t)
+
+		metadataFilePath, err := NewRestCatalog(config).MetadataFilePath(IcebergSchemaTable{Schema: "sales.eu", Table: "orders"})
+
+		testNoError(t, err)
+		if metadataFilePath != "s3://warehouse/sales/eu/orders/metadata/00001-abc.metadata.json" {
+			t.Errorf("Expected the s3a:// location to be read as s3://, got %v", metadataFilePath)
+		}
+	})
+
+	t.Run("Returns an error for a table that isn't in the catalog", func(t *testing.T) {
+		config, _ := startRestCatalogServer(t)
+
+		_, err := NewRestCatalog(config).MetadataFilePath(IcebergSchemaTable{Schema: "public", Table: "refunds"})
+
+		expectedErrorMessage := "REST catalog request /v1/warehouse-analytics/namespaces/public/tables/refunds failed with status 404: NoSuchTableException Table does not exist"
+		if err == nil || err.Error() != expectedErrorMessage {
+			t.Errorf("Expected the error to be '%s', got %v", expectedErrorMessage, err)
+		}
+	})
+
+	t.Run("Queries a table from the metadata location recorded in the catalog", func(t *testing.T) {
+		config, _ := startRestCatalogServer(t)
+		queryHandler := NewQueryHandler(config, NewDuckdb(config), NewIcebergReader(config))
+
+		remappedQuery, err := queryHandler.remapQuery("SELECT COUNT(*) AS count FROM public.test_table")
+
+		testNoError(t, err)
+		if !strings.Contains(remappedQuery, "iceberg_scan('"+testTableMetadataFilePath+"'") {
+			t.Errorf("Expected the table to be read from the catalog's metadata location, got %v", remappedQuery)
+		}
+
+		messages, err := queryHandler.HandleQuery("SELECT COUNT(*) AS count FROM public.test_table")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2"})
+	})
+}
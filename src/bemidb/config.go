@@ -0,0 +1,1050 @@
+package bemidb
+
+import (
+	"flag"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const VERSION = "0.28.3"
+
+const (
+	ENV_PORT                = "BEMIDB_PORT"
+	ENV_DATABASE            = "BEMIDB_DATABASE"
+	ENV_USER                = "BEMIDB_USER"
+	ENV_PASSWORD            = "BEMIDB_PASSWORD"
+	ENV_HOST                = "BEMIDB_HOST"
+	ENV_INIT_SQL_FILEPATH   = "BEMIDB_INIT_SQL"
+	ENV_STORAGE_PATH        = "BEMIDB_STORAGE_PATH"
+	ENV_LOG_LEVEL           = "BEMIDB_LOG_LEVEL"
+	ENV_STORAGE_TYPE        = "BEMIDB_STORAGE_TYPE"
+	ENV_ICEBERG_BRANCH      = "BEMIDB_ICEBERG_BRANCH"
+	ENV_UNSUPPORTED_QUERIES = "BEMIDB_UNSUPPORTED_QUERIES"
+
+	ENV_NUMERIC_AGGREGATE_OVERFLOW = "BEMIDB_NUMERIC_AGGREGATE_OVERFLOW"
+
+	ENV_STORAGE_READ_FALLBACK   = "BEMIDB_STORAGE_READ_FALLBACK"
+	ENV_CASE_INSENSITIVE_TABLES = "BEMIDB_CASE_INSENSITIVE_TABLES"
+	ENV_SCHEMA_ALIASES          = "BEMIDB_SCHEMA_ALIASES"
+	ENV_ICEBERG_STATISTICS      = "BEMIDB_ICEBERG_STATISTICS"
+	ENV_MAX_CONCURRENCY         = "BEMIDB_MAX_CONCURRENCY"
+	ENV_ICEBERG_PARTITIONS      = "BEMIDB_ICEBERG_PARTITIONS"
+	ENV_AUDIT_LOG_PATH          = "BEMIDB_AUDIT_LOG_PATH"
+	ENV_CATALOG_TYPE            = "BEMIDB_CATALOG_TYPE"
+	ENV_CATALOG_URI             = "BEMIDB_CATALOG_URI"
+	ENV_CATALOG_TOKEN           = "BEMIDB_CATALOG_TOKEN"
+	ENV_CATALOG_WAREHOUSE       = "BEMIDB_CATALOG_WAREHOUSE"
+
+	ENV_SERVER_MAX_CONNECTIONS = "BEMIDB_SERVER_MAX_CONNECTIONS"
+	ENV_SERVER_MAX_ACCEPT_RATE = "BEMIDB_SERVER_MAX_ACCEPT_RATE"
+	ENV_SERVER_TCP_KEEPALIVE   = "BEMIDB_SERVER_TCP_KEEPALIVE"
+	ENV_SERVER_QUERY_KEEPALIVE = "BEMIDB_SERVER_QUERY_KEEPALIVE"
+	ENV_SERVER_VERSION         = "BEMIDB_SERVER_VERSION"
+
+	ENV_DUCKDB_DATABASE_PATH  = "BEMIDB_DUCKDB_DATABASE_PATH"
+	ENV_DUCKDB_TEMP_DIRECTORY = "BEMIDB_DUCKDB_TEMP_DIRECTORY"
+	ENV_DUCKDB_MEMORY_LIMIT   = "BEMIDB_DUCKDB_MEMORY_LIMIT"
+
+	ENV_MAINTENANCE_INTERVAL          = "BEMIDB_MAINTENANCE_INTERVAL"
+	ENV_MAINTENANCE_MAX_DATA_FILES    = "BEMIDB_MAINTENANCE_MAX_DATA_FILES"
+	ENV_MAINTENANCE_MIN_AVG_FILE_SIZE = "BEMIDB_MAINTENANCE_MIN_AVG_FILE_SIZE"
+	ENV_MAINTENANCE_TARGET_FILE_SIZE  = "BEMIDB_MAINTENANCE_TARGET_FILE_SIZE"
+	ENV_MAINTENANCE_VACUUM_MIN_AGE    = "BEMIDB_MAINTENANCE_VACUUM_MIN_AGE"
+	ENV_MAINTENANCE_SNAPSHOT_MAX_AGE  = "BEMIDB_MAINTENANCE_SNAPSHOT_MAX_AGE"
+	ENV_MAINTENANCE_KEEP_SNAPSHOTS    = "BEMIDB_MAINTENANCE_KEEP_SNAPSHOTS"
+	ENV_MAINTENANCE_EXPIRE_DRY_RUN    = "BEMIDB_MAINTENANCE_EXPIRE_DRY_RUN"
+
+	ENV_PARQUET_STATS_COLUMNS     = "BEMIDB_PARQUET_STATS_COLUMNS"
+	ENV_PARQUET_MAX_STATS_COLUMNS = "BEMIDB_PARQUET_MAX_STATS_COLUMNS"
+	ENV_PARQUET_COMPRESSION       = "BEMIDB_PARQUET_COMPRESSION"
+	ENV_PARQUET_ROW_GROUP_SIZE    = "BEMIDB_PARQUET_ROW_GROUP_SIZE"
+
+	ENV_PARQUET_TABLE_COMPRESSIONS    = "BEMIDB_PARQUET_TABLE_COMPRESSIONS"
+	ENV_PARQUET_TABLE_ROW_GROUP_SIZES = "BEMIDB_PARQUET_TABLE_ROW_GROUP_SIZES"
+
+	ENV_AWS_REGION              = "AWS_REGION"
+	ENV_AWS_S3_ENDPOINT         = "AWS_S3_ENDPOINT"
+	ENV_AWS_S3_FORCE_PATH_STYLE = "AWS_S3_FORCE_PATH_STYLE"
+	ENV_AWS_S3_BUCKET           = "AWS_S3_BUCKET"
+	ENV_AWS_ACCESS_KEY_ID       = "AWS_ACCESS_KEY_ID"
+	ENV_AWS_SECRET_ACCESS_KEY   = "AWS_SECRET_ACCESS_KEY"
+	ENV_AWS_S3_ACL              = "AWS_S3_ACL"
+	ENV_AWS_ASSUME_ROLE_ARN     = "AWS_ASSUME_ROLE_ARN"
+	ENV_AWS_EXTERNAL_ID         = "AWS_EXTERNAL_ID"
+
+	ENV_AWS_S3_SSE_ALGORITHM = "AWS_S3_SSE_ALGORITHM"
+	ENV_AWS_S3_KMS_KEY_ID    = "AWS_S3_KMS_KEY_ID"
+
+	ENV_AWS_S3_STORAGE_CLASS            = "AWS_S3_STORAGE_CLASS"
+	ENV_AWS_S3_SUPERSEDED_STORAGE_CLASS = "AWS_S3_SUPERSEDED_STORAGE_CLASS"
+	ENV_AWS_S3_TABLE_BUCKETS            = "AWS_S3_TABLE_BUCKETS"
+	ENV_AWS_S3_UPLOAD_PART_SIZE         = "AWS_S3_UPLOAD_PART_SIZE"
+	ENV_AWS_S3_UPLOAD_CONCURRENCY       = "AWS_S3_UPLOAD_CONCURRENCY"
+	ENV_AWS_S3_MAX_RETRIES              = "AWS_S3_MAX_RETRIES"
+	ENV_AWS_GLUE_CATALOG_ID             = "AWS_GLUE_CATALOG_ID"
+	ENV_AWS_GLUE_ENDPOINT               = "AWS_GLUE_ENDPOINT"
+
+	ENV_GCS_BUCKET      = "GCS_BUCKET"
+	ENV_GCS_HMAC_KEY_ID = "GCS_HMAC_KEY_ID"
+	ENV_GCS_HMAC_SECRET = "GCS_HMAC_SECRET"
+
+	ENV_AZURE_STORAGE_ACCOUNT     = "AZURE_STORAGE_ACCOUNT"
+	ENV_AZURE_STORAGE_ACCOUNT_KEY = "AZURE_STORAGE_ACCOUNT_KEY"
+	ENV_AZURE_STORAGE_CONTAINER   = "AZURE_STORAGE_CONTAINER"
+	ENV_AZURE_STORAGE_ENDPOINT    = "AZURE_STORAGE_ENDPOINT"
+
+	ENV_PG_DATABASE_URL    = "PG_DATABASE_URL"
+	ENV_PG_SYNC_INTERVAL   = "PG_SYNC_INTERVAL"
+	ENV_PG_SCHEMA_PREFIX   = "PG_SCHEMA_PREFIX"
+	ENV_PG_INCLUDE_SCHEMAS = "PG_INCLUDE_SCHEMAS"
+	ENV_PG_EXCLUDE_SCHEMAS = "PG_EXCLUDE_SCHEMAS"
+	ENV_PG_INCLUDE_TABLES  = "PG_INCLUDE_TABLES"
+	ENV_PG_EXCLUDE_TABLES  = "PG_EXCLUDE_TABLES"
+	ENV_PG_INCLUDE_VIEWS   = "PG_INCLUDE_VIEWS"
+
+	ENV_PG_PASSTHROUGH_TABLES   = "PG_PASSTHROUGH_TABLES"
+	ENV_PG_PASSTHROUGH_UNSYNCED = "PG_PASSTHROUGH_UNSYNCED"
+
+	ENV_PG_SYNC_BATCH_RETRIES       = "PG_SYNC_BATCH_RETRIES"
+	ENV_PG_SYNC_SKIP_FAILED_BATCHES = "PG_SYNC_SKIP_FAILED_BATCHES"
+	ENV_PG_SYNC_INFINITE_TIMESTAMPS = "PG_SYNC_INFINITE_TIMESTAMPS"
+	ENV_PG_SYNC_SOURCE_POOL_SIZE    = "PG_SYNC_SOURCE_POOL_SIZE"
+
+	DEFAULT_PORT                = "54321"
+	DEFAULT_DATABASE            = "bemidb"
+	DEFAULT_USER                = ""
+	DEFAULT_PASSWORD            = ""
+	DEFAULT_HOST                = "127.0.0.1"
+	DEFAULT_INIT_SQL_FILEPATH   = "./init.sql"
+	DEFAULT_STORAGE_PATH        = "iceberg"
+	DEFAULT_LOG_LEVEL           = "INFO"
+	DEFAULT_DB_STORAGE_TYPE     = "LOCAL"
+	DEFAULT_ICEBERG_BRANCH      = ICEBERG_MAIN_BRANCH
+	DEFAULT_UNSUPPORTED_QUERIES = UNSUPPORTED_QUERIES_ERROR
+
+	DEFAULT_NUMERIC_AGGREGATE_OVERFLOW = NUMERIC_AGGREGATE_OVERFLOW_ERROR
+
+	DEFAULT_STORAGE_READ_FALLBACK   = STORAGE_READ_FALLBACK_AUTO
+	DEFAULT_CASE_INSENSITIVE_TABLES = "false"
+	DEFAULT_ICEBERG_STATISTICS      = "false"
+	DEFAULT_MAX_CONCURRENCY         = "4"
+	DEFAULT_CATALOG_TYPE            = CATALOG_TYPE_FILESYSTEM
+
+	DEFAULT_SERVER_MAX_CONNECTIONS = "100"
+	DEFAULT_SERVER_MAX_ACCEPT_RATE = "0" // unlimited
+	DEFAULT_SERVER_TCP_KEEPALIVE   = "15s"
+	DEFAULT_SERVER_QUERY_KEEPALIVE = "0" // disabled
+	DEFAULT_SERVER_VERSION         = PG_VERSION
+
+	DEFAULT_MAINTENANCE_MAX_DATA_FILES    = "10"
+	DEFAULT_MAINTENANCE_MIN_AVG_FILE_SIZE = "8388608" // 8 MB
+	DEFAULT_MAINTENANCE_VACUUM_MIN_AGE    = "24h"
+	DEFAULT_MAINTENANCE_KEEP_SNAPSHOTS    = "5"
+	DEFAULT_MAINTENANCE_EXPIRE_DRY_RUN    = "false"
+	DEFAULT_MAINTENANCE_TARGET_FILE_SIZE  = "134217728" // 128 MB
+
+	DEFAULT_PARQUET_MAX_STATS_COLUMNS = "0"
+	DEFAULT_PARQUET_COMPRESSION       = "zstd"
+	DEFAULT_PARQUET_ROW_GROUP_SIZE    = "67108864" // 64 MB
+
+	DEFAULT_AWS_S3_ENDPOINT         = "s3.amazonaws.com"
+	DEFAULT_AWS_S3_FORCE_PATH_STYLE = "false"
+
+	DEFAULT_AWS_S3_UPLOAD_PART_SIZE   = "16" // MiB
+	DEFAULT_AWS_S3_UPLOAD_CONCURRENCY = "5"
+	DEFAULT_AWS_S3_MAX_RETRIES        = "10"
+
+	DEFAULT_PG_SYNC_BATCH_RETRIES       = "2"
+	DEFAULT_PG_SYNC_SKIP_FAILED_BATCHES = "false"
+	DEFAULT_PG_SYNC_INFINITE_TIMESTAMPS = INFINITE_TIMESTAMPS_INFINITY
+	DEFAULT_PG_SYNC_SOURCE_POOL_SIZE    = "1"
+
+	DEFAULT_PG_PASSTHROUGH_UNSYNCED = "false"
+
+	STORAGE_TYPE_LOCAL = "LOCAL"
+	STORAGE_TYPE_S3    = "S3"
+	STORAGE_TYPE_GCS   = "GCS"
+	STORAGE_TYPE_AZURE = "AZURE"
+
+	// Tables are discovered by listing the storage path, or resolved from the AWS Glue Data Catalog or an Iceberg REST catalog
+	CATALOG_TYPE_FILESYSTEM = "FILESYSTEM"
+	CATALOG_TYPE_GLUE       = "GLUE"
+	CATALOG_TYPE_REST       = "REST"
+
+	// Queries download the Iceberg files of the tables they read to a local temporary directory only if DuckDB can't read the storage
+	STORAGE_READ_FALLBACK_AUTO = "AUTO"
+	// E.g. for custom endpoints that DuckDB can't reach
+	STORAGE_READ_FALLBACK_ALWAYS = "ALWAYS"
+	STORAGE_READ_FALLBACK_NEVER  = "NEVER"
+
+	INFINITE_TIMESTAMPS_INFINITY = "INFINITY"
+	INFINITE_TIMESTAMPS_NULL     = "NULL"
+)
+
+var STORAGE_READ_FALLBACK_MODES = []string{STORAGE_READ_FALLBACK_AUTO, STORAGE_READ_FALLBACK_ALWAYS, STORAGE_READ_FALLBACK_NEVER}
+
+// E.g. "512MB", "4GB" or "1.5GiB"
+var DUCKDB_MEMORY_LIMIT_REGEX = regexp.MustCompile(`(?i)^\d+(\.\d+)?\s*(B|KB|MB|GB|TB|KIB|MIB|GIB|TIB)$`)
+
+// E.g. "17.0" or "14.5"
+var SERVER_VERSION_REGEX = regexp.MustCompile(`^\d+\.\d+$`)
+
+var CATALOG_TYPES = []string{CATALOG_TYPE_FILESYSTEM, CATALOG_TYPE_GLUE, CATALOG_TYPE_REST}
+
+var INFINITE_TIMESTAMPS_MODES = []string{INFINITE_TIMESTAMPS_INFINITY, INFINITE_TIMESTAMPS_NULL}
+
+type AwsConfig struct {
+	Region     string
+	S3Endpoint string // optional, e.g. "http://localhost:9000" for MinIO, https:// unless a scheme is specified
+	// Addresses buckets as endpoint/bucket instead of bucket.endpoint, which MinIO and Ceph require
+	S3ForcePathStyle bool // optional
+	S3Bucket         string
+	AccessKeyId      string
+	SecretAccessKey  string
+	S3ACL            string // optional
+	// Role assumed with the access keys, e.g. to access a bucket in another AWS account
+	AssumeRoleArn string // optional
+	ExternalId    string // optional
+	// Server-side encryption of uploaded objects, "AES256" (SSE-S3) or "aws:kms" (SSE-KMS) with the bucket's default key unless KmsKeyId is set
+	SseAlgorithm string // optional
+	KmsKeyId     string // optional
+	// Storage class of uploaded objects and of data files only referenced by non-current snapshots
+	S3StorageClass           string // optional
+	S3SupersededStorageClass string // optional
+	// Buckets of tables stored outside S3Bucket, by Iceberg "schema.table"
+	S3TableBuckets map[string]AwsS3Bucket // optional
+	// Size in bytes and number of parts uploaded in parallel per file, which bounds the memory buffered by each upload.
+	// Files smaller than one part are uploaded with a single request
+	UploadPartSize    int64
+	UploadConcurrency int
+	// Retries of throttled, 5xx and network errors of each AWS S3 request, with exponential backoff
+	MaxRetries int
+	// AWS account ID of the Glue Data Catalog, the account of the credentials by default
+	GlueCatalogId string // optional
+	GlueEndpoint  string // optional, e.g. a VPC endpoint, https://glue.<region>.amazonaws.com by default
+}
+
+type CatalogConfig struct {
+	Type string
+	// Base URL of the Iceberg REST catalog, e.g. "https://catalog.example.com/api/catalog"
+	Uri       string
+	Token     string // optional, sent as a bearer token
+	Warehouse string // optional, e.g. the catalog name in Polaris
+}
+
+type IcebergPartitionColumn struct {
+	ColumnName string
+	Transform  string
+}
+
+type AwsS3Bucket struct {
+	Name   string
+	Region string
+}
+
+// Files are written with application-default credentials. DuckDB reads them with an HMAC key, since it doesn't support them
+type GcsConfig struct {
+	Bucket     string
+	HmacKeyId  string
+	HmacSecret string
+}
+
+// Files are written with the account key if it's set, or with the default Azure credential chain otherwise
+type AzureConfig struct {
+	Account       string
+	AccountKey    string // optional
+	ContainerName string
+	// Blob service endpoint, e.g. of Azurite, "https://<account>.blob.core.windows.net" if empty
+	Endpoint string // optional
+}
+
+type PgConfig struct {
+	DatabaseUrl    string
+	SyncInterval   string // optional
+	SchemaPrefix   string // optional
+	IncludeSchemas *Set   // optional
+	ExcludeSchemas *Set   // optional
+	IncludeTables  *Set   // optional
+	ExcludeTables  *Set   // optional
+	// Views and materialized views synced like tables, they aren't synced by default
+	IncludeViews *Set // optional
+	// Retries of a batch of rows that failed to be written to Parquet, and whether to leave it out of the table afterwards
+	SyncBatchRetries      int
+	SyncSkipFailedBatches bool
+	// Handling of Postgres 'infinity' and '-infinity' timestamps and dates
+	SyncInfiniteTimestamps string
+	// Source connections shared by sync workers, which is also the number of tables synced in parallel
+	SyncSourcePoolSize int
+	// Tables read from the live source database instead of Iceberg, the whole query reading them runs on the source
+	PassthroughTables         *Set // optional
+	PassthroughUnsyncedTables bool
+}
+
+type ServerConfig struct {
+	MaxConnections int
+	MaxAcceptRate  int // connections per second, 0 for unlimited
+	// Interval of TCP keepalive probes on idle client connections, 0 to disable
+	TCPKeepAlive time.Duration
+	// Interval of protocol-level keepalive messages sent while a query is running, 0 to disable
+	QueryKeepAlive time.Duration
+	// Postgres version reported to clients, e.g. by version() and SHOW server_version
+	Version string
+}
+
+// DuckDB runs in memory unless DatabasePath is set. Queries that exceed the memory limit spill to the temp directory
+type DuckdbConfig struct {
+	// Database file kept across restarts, e.g. for caching. DuckDB locks it to a single BemiDB process
+	DatabasePath string // optional
+	// Defaults to DatabasePath with a ".tmp" suffix, or ".tmp" in the working directory for an in-memory database
+	TempDirectory string // optional
+	MemoryLimit   string // optional, e.g. "4GB", 80% of the RAM by default
+}
+
+type MaintenanceConfig struct {
+	Interval       string // optional
+	MaxDataFiles   int
+	MinAvgFileSize int64
+	// Data files smaller than this are merged by compaction into files of up to this size
+	TargetFileSize int64
+	// Orphaned files modified more recently are kept by vacuum, since they may belong to an in-flight commit
+	VacuumMinAge time.Duration
+	// Snapshots older than this are expired unless they're among the last KeepSnapshots or referenced by a branch or tag, 0 to keep all
+	SnapshotMaxAge time.Duration
+	KeepSnapshots  int
+	// Log the files that expiring snapshots would delete instead of deleting them
+	ExpireDryRun bool
+}
+
+// Min/max statistics of wide tables can be limited to some columns to keep Parquet footers and manifests small.
+// Other columns are still counted in Iceberg value counts and column sizes, but can't be used to skip files
+type ParquetConfig struct {
+	StatsColumns *Set // optional, column names
+	// Columns after this position only have statistics if they are in StatsColumns, 0 for no limit
+	MaxStatsColumns int
+	Compression     string
+	// Estimated uncompressed bytes per row group, 0 or less for the default. Smaller row groups hold
+	// fewer records each, so a data file has more row groups for ReadParquetStats to aggregate
+	RowGroupSize int64
+	// Compression and RowGroupSize of tables that override them, by Iceberg "schema.table"
+	TableCompressions  map[string]string // optional
+	TableRowGroupSizes map[string]int64  // optional
+}
+
+type Config struct {
+	Host               string
+	Port               string
+	Database           string
+	User               string
+	EncryptedPassword  string
+	InitSqlFilepath    string
+	LogLevel           string
+	StorageType        string
+	StoragePath        string
+	IcebergBranch      string
+	UnsupportedQueries string
+	// Handling of SUM and AVG over DECIMAL columns that exceed 38 digits, which Postgres returns as an arbitrary-precision numeric
+	NumericAggregateOverflow string
+	// Reads tables through a local copy of their files when DuckDB can't read them from the storage directly
+	StorageReadFallback string
+	// Fall back to a table that matches ignoring case when there is no exact match, e.g. "Users" for users
+	CaseInsensitiveTables bool
+	// Iceberg schemas that clients query under another name, by the exposed schema name
+	SchemaAliases map[string]string // optional
+	// Write Puffin files with per-column NDV sketches for other query engines when syncing
+	IcebergStatistics bool
+	// Tables synced at the same time, capped at the source pool size
+	MaxConcurrency int
+	// Column and transform that tables are partitioned by when syncing, by Iceberg "schema.table"
+	IcebergPartitions map[string]IcebergPartitionColumn // optional
+	// Folder or S3 prefix outside the storage path with a record of each sync commit, queryable as bemidb.audit
+	AuditLogPath string // optional
+	Catalog      CatalogConfig
+	Aws          AwsConfig
+	Gcs          GcsConfig
+	Azure        AzureConfig
+	Pg           PgConfig
+	Server       ServerConfig
+	Duckdb       DuckdbConfig
+	Maintenance  MaintenanceConfig
+	Parquet      ParquetConfig
+}
+
+type configParseValues struct {
+	password                  string
+	caseInsensitiveTables     string
+	schemaAliases             string
+	icebergStatistics         string
+	maxConcurrency            string
+	icebergPartitions         string
+	pgIncludeSchemas          string
+	pgExcludeSchemas          string
+	pgIncludeTables           string
+	pgExcludeTables           string
+	pgIncludeViews            string
+	pgPassthroughTables       string
+	pgPassthroughUnsynced     string
+	pgSyncBatchRetries        string
+	pgSyncSkipFailedBatches   string
+	pgSyncSourcePoolSize      string
+	serverMaxConnections      string
+	serverMaxAcceptRate       string
+	serverTcpKeepAlive        string
+	serverQueryKeepAlive      string
+	serverVersion             string
+	maintenanceMaxDataFiles   string
+	maintenanceMinAvgFileSize string
+	maintenanceTargetFileSize string
+	maintenanceVacuumMinAge   string
+	maintenanceSnapshotMaxAge string
+	maintenanceKeepSnapshots  string
+	maintenanceExpireDryRun   string
+	parquetStatsColumns       string
+	parquetMaxStatsColumns    string
+	parquetRowGroupSize       string
+	parquetTableCompressions  string
+	parquetTableRowGroupSizes string
+	awsS3ForcePathStyle       string
+	awsS3TableBuckets         string
+	awsS3UploadPartSize       string
+	awsS3UploadConcurrency    string
+	awsS3MaxRetries           string
+}
+
+var _config Config
+var _configParseValues configParseValues
+
+func init() {
+	registerFlags()
+}
+
+func registerFlags() {
+	flag.StringVar(&_config.Host, "host", os.Getenv(ENV_HOST), "Database host. Default: \""+DEFAULT_HOST+"\"")
+	flag.StringVar(&_config.Port, "port", os.Getenv(ENV_PORT), "Port for BemiDB to listen on. Default: \""+DEFAULT_PORT+"\"")
+	flag.StringVar(&_config.Database, "database", os.Getenv(ENV_DATABASE), "Database name. Default: \""+DEFAULT_DATABASE+"\"")
+	flag.StringVar(&_config.User, "user", os.Getenv(ENV_USER), "Database user. Default: \""+DEFAULT_USER+"\"")
+	flag.StringVar(&_configParseValues.password, "password", os.Getenv(ENV_PASSWORD), "Database password. Default: \""+DEFAULT_PASSWORD+"\"")
+	flag.StringVar(&_config.StoragePath, "storage-path", os.Getenv(ENV_STORAGE_PATH), "Path to the storage folder. Default: \""+DEFAULT_STORAGE_PATH+"\"")
+	flag.StringVar(&_config.InitSqlFilepath, "init-sql", os.Getenv(ENV_INIT_SQL_FILEPATH), "Path to the initialization SQL file. Default: \""+DEFAULT_INIT_SQL_FILEPATH+"\"")
+	flag.StringVar(&_config.LogLevel, "log-level", os.Getenv(ENV_LOG_LEVEL), "Log level: \"ERROR\", \"WARN\", \"INFO\", \"DEBUG\", \"TRACE\". Default: \""+DEFAULT_LOG_LEVEL+"\"")
+	flag.StringVar(&_config.StorageType, "storage-type", os.Getenv(ENV_STORAGE_TYPE), "Storage type: \"LOCAL\", \"S3\", \"GCS\", \"AZURE\". Default: \""+DEFAULT_DB_STORAGE_TYPE+"\"")
+	flag.StringVar(&_config.StorageReadFallback, "storage-read-fallback", os.Getenv(ENV_STORAGE_READ_FALLBACK), "Reading tables through a local copy of their files: \"AUTO\" if DuckDB can't read the storage directly, e.g. GCS without HMAC keys, \"ALWAYS\", \"NEVER\". Default: \""+DEFAULT_STORAGE_READ_FALLBACK+"\"")
+	flag.StringVar(&_config.UnsupportedQueries, "unsupported-queries", os.Getenv(ENV_UNSUPPORTED_QUERIES), "Handling of unsupported Postgres features: \"ERROR\" with the feature name, \"PASSTHROUGH\" to DuckDB. Default: \""+DEFAULT_UNSUPPORTED_QUERIES+"\"")
+	flag.StringVar(&_config.NumericAggregateOverflow, "numeric-aggregate-overflow", os.Getenv(ENV_NUMERIC_AGGREGATE_OVERFLOW), "Handling of SUM and AVG over numeric columns that exceed 38 digits: \"ERROR\" with a numeric field overflow, \"DOUBLE\" to return an approximate double precision result. Default: \""+DEFAULT_NUMERIC_AGGREGATE_OVERFLOW+"\"")
+	flag.StringVar(&_configParseValues.caseInsensitiveTables, "case-insensitive-tables", os.Getenv(ENV_CASE_INSENSITIVE_TABLES), "Fall back to a case-insensitive schema and table name match if there is no exact match: \"true\", \"false\". Default: \""+DEFAULT_CASE_INSENSITIVE_TABLES+"\"")
+	flag.StringVar(&_configParseValues.schemaAliases, "schema-aliases", os.Getenv(ENV_SCHEMA_ALIASES), "(Optional) Comma-separated list of Iceberg schemas that clients query under another name (format: exposed_schema=iceberg_schema)")
+	flag.StringVar(&_config.IcebergBranch, "iceberg-branch", os.Getenv(ENV_ICEBERG_BRANCH), "Iceberg branch to sync data into, e.g. a staging branch to promote later. Default: \""+DEFAULT_ICEBERG_BRANCH+"\"")
+	flag.StringVar(&_configParseValues.icebergStatistics, "iceberg-statistics", os.Getenv(ENV_ICEBERG_STATISTICS), "Write Iceberg table statistics with per-column NDV sketches as Puffin files when syncing: \"true\", \"false\". Default: \""+DEFAULT_ICEBERG_STATISTICS+"\"")
+	flag.StringVar(&_configParseValues.maxConcurrency, "max-concurrency", os.Getenv(ENV_MAX_CONCURRENCY), "Maximum number of tables to sync in parallel, capped at the source pool size. Default: \""+DEFAULT_MAX_CONCURRENCY+"\"")
+	flag.StringVar(&_configParseValues.icebergPartitions, "iceberg-partitions", os.Getenv(ENV_ICEBERG_PARTITIONS), "(Optional) Comma-separated list of tables to partition by a column when syncing (format: schema.table=column or schema.table=day(column))")
+	flag.StringVar(&_config.AuditLogPath, "audit-log-path", os.Getenv(ENV_AUDIT_LOG_PATH), "(Optional) Path to the folder or S3 prefix to write an append-only audit record of each sync commit to, queryable as bemidb.audit")
+	flag.StringVar(&_config.Catalog.Type, "catalog-type", os.Getenv(ENV_CATALOG_TYPE), "Catalog to discover tables and their current metadata files from: \"FILESYSTEM\" by listing the storage path, \"GLUE\" for the AWS Glue Data Catalog, \"REST\" for an Iceberg REST catalog. Default: \""+DEFAULT_CATALOG_TYPE+"\"")
+	flag.StringVar(&_config.Catalog.Uri, "catalog-uri", os.Getenv(ENV_CATALOG_URI), "(Optional) Base URL of the Iceberg REST catalog, required with the \"REST\" catalog type")
+	flag.StringVar(&_config.Catalog.Token, "catalog-token", os.Getenv(ENV_CATALOG_TOKEN), "(Optional) Bearer token for the Iceberg REST catalog")
+	flag.StringVar(&_config.Catalog.Warehouse, "catalog-warehouse", os.Getenv(ENV_CATALOG_WAREHOUSE), "(Optional) Warehouse of the Iceberg REST catalog, e.g. the catalog name in Polaris")
+	flag.StringVar(&_config.Pg.SchemaPrefix, "pg-schema-prefix", os.Getenv(ENV_PG_SCHEMA_PREFIX), "(Optional) Prefix for PostgreSQL schema names")
+	flag.StringVar(&_config.Pg.SyncInterval, "pg-sync-interval", os.Getenv(ENV_PG_SYNC_INTERVAL), "(Optional) Interval between syncs. Valid units: \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
+	flag.StringVar(&_configParseValues.pgIncludeSchemas, "pg-include-schemas", os.Getenv(ENV_PG_INCLUDE_SCHEMAS), "(Optional) Comma-separated list of schemas to include in sync")
+	flag.StringVar(&_configParseValues.pgExcludeSchemas, "pg-exclude-schemas", os.Getenv(ENV_PG_EXCLUDE_SCHEMAS), "(Optional) Comma-separated list of schemas to exclude from sync")
+	flag.StringVar(&_configParseValues.pgIncludeTables, "pg-include-tables", os.Getenv(ENV_PG_INCLUDE_TABLES), "(Optional) Comma-separated list of tables to include in sync (format: schema.table)")
+	flag.StringVar(&_configParseValues.pgExcludeTables, "pg-exclude-tables", os.Getenv(ENV_PG_EXCLUDE_TABLES), "(Optional) Comma-separated list of tables to exclude from sync (format: schema.table)")
+	flag.StringVar(&_configParseValues.pgIncludeViews, "pg-include-views", os.Getenv(ENV_PG_INCLUDE_VIEWS), "(Optional) Comma-separated list of views and materialized views to sync like tables (format: schema.view)")
+	flag.StringVar(&_configParseValues.pgPassthroughTables, "pg-passthrough-tables", os.Getenv(ENV_PG_PASSTHROUGH_TABLES), "(Optional) Comma-separated list of tables to query from the live PostgreSQL database instead of Iceberg (format: schema.table)")
+	flag.StringVar(&_configParseValues.pgPassthroughUnsynced, "pg-passthrough-unsynced", os.Getenv(ENV_PG_PASSTHROUGH_UNSYNCED), "Query tables that aren't synced to Iceberg from the live PostgreSQL database: \"true\", \"false\". Default: \""+DEFAULT_PG_PASSTHROUGH_UNSYNCED+"\"")
+	flag.StringVar(&_configParseValues.pgSyncBatchRetries, "pg-sync-batch-retries", os.Getenv(ENV_PG_SYNC_BATCH_RETRIES), "Number of retries with backoff of a batch of rows that failed to be written to Parquet. Default: \""+DEFAULT_PG_SYNC_BATCH_RETRIES+"\"")
+	flag.StringVar(&_configParseValues.pgSyncSkipFailedBatches, "pg-sync-skip-failed-batches", os.Getenv(ENV_PG_SYNC_SKIP_FAILED_BATCHES), "Skip a batch of rows that still fails to be written to Parquet after retries instead of failing the sync: \"true\", \"false\". Default: \""+DEFAULT_PG_SYNC_SKIP_FAILED_BATCHES+"\"")
+	flag.StringVar(&_config.Pg.SyncInfiniteTimestamps, "pg-sync-infinite-timestamps", os.Getenv(ENV_PG_SYNC_INFINITE_TIMESTAMPS), "Handling of 'infinity' and '-infinity' timestamps and dates: \"INFINITY\" to keep them queryable as infinity, \"NULL\" to write them as NULL. Default: \""+DEFAULT_PG_SYNC_INFINITE_TIMESTAMPS+"\"")
+	flag.StringVar(&_configParseValues.pgSyncSourcePoolSize, "pg-sync-source-pool-size", os.Getenv(ENV_PG_SYNC_SOURCE_POOL_SIZE), "Number of source database connections shared by sync workers to sync tables in parallel, capped at the available max_connections. Default: \""+DEFAULT_PG_SYNC_SOURCE_POOL_SIZE+"\"")
+	flag.StringVar(&_config.Pg.DatabaseUrl, "pg-database-url", os.Getenv(ENV_PG_DATABASE_URL), "PostgreSQL database URL to sync")
+	flag.StringVar(&_configParseValues.serverMaxConnections, "server-max-connections", os.Getenv(ENV_SERVER_MAX_CONNECTIONS), "Maximum number of concurrent client connections. Default: \""+DEFAULT_SERVER_MAX_CONNECTIONS+"\"")
+	flag.StringVar(&_configParseValues.serverMaxAcceptRate, "server-max-accept-rate", os.Getenv(ENV_SERVER_MAX_ACCEPT_RATE), "Maximum number of client connections accepted per second, 0 for unlimited. Default: \""+DEFAULT_SERVER_MAX_ACCEPT_RATE+"\"")
+	flag.StringVar(&_configParseValues.serverTcpKeepAlive, "server-tcp-keepalive", os.Getenv(ENV_SERVER_TCP_KEEPALIVE), "Interval of TCP keepalive probes on idle client connections, 0 to disable. Valid units: \"ms\", \"s\", \"m\", \"h\". Default: \""+DEFAULT_SERVER_TCP_KEEPALIVE+"\"")
+	flag.StringVar(&_configParseValues.serverQueryKeepAlive, "server-query-keepalive", os.Getenv(ENV_SERVER_QUERY_KEEPALIVE), "Interval of keepalive messages sent to clients while a query is running, 0 to disable. Valid units: \"ms\", \"s\", \"m\", \"h\". Default: \""+DEFAULT_SERVER_QUERY_KEEPALIVE+"\"")
+	flag.StringVar(&_configParseValues.serverVersion, "server-version", os.Getenv(ENV_SERVER_VERSION), "Postgres version reported to clients, e.g. by version() and SHOW server_version. Default: \""+DEFAULT_SERVER_VERSION+"\"")
+	flag.StringVar(&_config.Duckdb.DatabasePath, "duckdb-database-path", os.Getenv(ENV_DUCKDB_DATABASE_PATH), "(Optional) Path to an on-disk DuckDB database file to use instead of an in-memory database")
+	flag.StringVar(&_config.Duckdb.TempDirectory, "duckdb-temp-directory", os.Getenv(ENV_DUCKDB_TEMP_DIRECTORY), "(Optional) Directory that DuckDB spills to when queries exceed the memory limit. Default: the database path with a \".tmp\" suffix")
+	flag.StringVar(&_config.Duckdb.MemoryLimit, "duckdb-memory-limit", os.Getenv(ENV_DUCKDB_MEMORY_LIMIT), "(Optional) Maximum memory used by DuckDB before spilling to the temp directory, e.g. \"4GB\". Default: 80% of the RAM")
+	flag.StringVar(&_config.Maintenance.Interval, "maintenance-interval", os.Getenv(ENV_MAINTENANCE_INTERVAL), "(Optional) Interval between idle-time table maintenance runs (compaction and snapshot expiration). Valid units: \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"")
+	flag.StringVar(&_configParseValues.maintenanceMaxDataFiles, "maintenance-max-data-files", os.Getenv(ENV_MAINTENANCE_MAX_DATA_FILES), "Number of data files above which a table is maintained. Default: \""+DEFAULT_MAINTENANCE_MAX_DATA_FILES+"\"")
+	flag.StringVar(&_configParseValues.maintenanceMinAvgFileSize, "maintenance-min-avg-file-size", os.Getenv(ENV_MAINTENANCE_MIN_AVG_FILE_SIZE), "Average data file size in bytes below which a table with multiple data files is maintained. Default: \""+DEFAULT_MAINTENANCE_MIN_AVG_FILE_SIZE+"\"")
+	flag.StringVar(&_configParseValues.maintenanceTargetFileSize, "maintenance-target-file-size", os.Getenv(ENV_MAINTENANCE_TARGET_FILE_SIZE), "Size in bytes up to which compaction merges smaller data files of a table. Default: \""+DEFAULT_MAINTENANCE_TARGET_FILE_SIZE+"\"")
+	flag.StringVar(&_configParseValues.parquetStatsColumns, "parquet-stats-columns", os.Getenv(ENV_PARQUET_STATS_COLUMNS), "(Optional) Comma-separated list of columns with min/max statistics in Parquet files and Iceberg manifests, other columns have none")
+	flag.StringVar(&_config.Parquet.Compression, "parquet-compression", os.Getenv(ENV_PARQUET_COMPRESSION), "Compression codec of written Parquet files: \""+strings.Join(PARQUET_COMPRESSIONS, "\", \"")+"\". Default: \""+DEFAULT_PARQUET_COMPRESSION+"\"")
+	flag.StringVar(&_configParseValues.parquetRowGroupSize, "parquet-row-group-size", os.Getenv(ENV_PARQUET_ROW_GROUP_SIZE), "Estimated uncompressed size of Parquet row groups in bytes, 0 or less for the default. Default: \""+DEFAULT_PARQUET_ROW_GROUP_SIZE+"\"")
+	flag.StringVar(&_configParseValues.parquetTableCompressions, "parquet-table-compressions", os.Getenv(ENV_PARQUET_TABLE_COMPRESSIONS), "(Optional) Comma-separated list of tables with another compression codec than --parquet-compression (format: schema.table=codec)")
+	flag.StringVar(&_configParseValues.parquetTableRowGroupSizes, "parquet-table-row-group-sizes", os.Getenv(ENV_PARQUET_TABLE_ROW_GROUP_SIZES), "(Optional) Comma-separated list of tables with another row group size than --parquet-row-group-size (format: schema.table=bytes)")
+	flag.StringVar(&_configParseValues.parquetMaxStatsColumns, "parquet-max-stats-columns", os.Getenv(ENV_PARQUET_MAX_STATS_COLUMNS), "Number of leading columns of a table with min/max statistics, columns in --parquet-stats-columns always have them, 0 for all columns. Default: \""+DEFAULT_PARQUET_MAX_STATS_COLUMNS+"\"")
+	flag.StringVar(&_configParseValues.maintenanceVacuumMinAge, "maintenance-vacuum-min-age", os.Getenv(ENV_MAINTENANCE_VACUUM_MIN_AGE), "Age below which orphaned files are kept by vacuum. Default: \""+DEFAULT_MAINTENANCE_VACUUM_MIN_AGE+"\"")
+	flag.StringVar(&_configParseValues.maintenanceSnapshotMaxAge, "maintenance-snapshot-max-age", os.Getenv(ENV_MAINTENANCE_SNAPSHOT_MAX_AGE), "(Optional) Age above which snapshots are expired and their files deleted during maintenance, snapshots are kept forever if not set. Valid units: \"m\", \"h\"")
+	flag.StringVar(&_configParseValues.maintenanceKeepSnapshots, "maintenance-keep-snapshots", os.Getenv(ENV_MAINTENANCE_KEEP_SNAPSHOTS), "Number of most recent snapshots that are never expired. Default: \""+DEFAULT_MAINTENANCE_KEEP_SNAPSHOTS+"\"")
+	flag.StringVar(&_configParseValues.maintenanceExpireDryRun, "maintenance-expire-dry-run", os.Getenv(ENV_MAINTENANCE_EXPIRE_DRY_RUN), "Only log the files that expiring snapshots would delete: \"true\", \"false\". Default: \""+DEFAULT_MAINTENANCE_EXPIRE_DRY_RUN+"\"")
+	flag.StringVar(&_config.Aws.Region, "aws-region", os.Getenv(ENV_AWS_REGION), "AWS region")
+	flag.StringVar(&_config.Aws.S3Endpoint, "aws-s3-endpoint", os.Getenv(ENV_AWS_S3_ENDPOINT), "AWS S3 endpoint, or the endpoint of S3-compatible storage like MinIO, Cloudflare R2, or Ceph. Default: \""+DEFAULT_AWS_S3_ENDPOINT+"\"")
+	flag.StringVar(&_configParseValues.awsS3ForcePathStyle, "aws-s3-force-path-style", os.Getenv(ENV_AWS_S3_FORCE_PATH_STYLE), "Use path-style S3 addressing required by MinIO and Ceph: \"true\", \"false\". Default: \""+DEFAULT_AWS_S3_FORCE_PATH_STYLE+"\"")
+	flag.StringVar(&_config.Aws.S3Bucket, "aws-s3-bucket", os.Getenv(ENV_AWS_S3_BUCKET), "AWS S3 bucket name")
+	flag.StringVar(&_config.Aws.AccessKeyId, "aws-access-key-id", os.Getenv(ENV_AWS_ACCESS_KEY_ID), "AWS access key ID")
+	flag.StringVar(&_config.Aws.SecretAccessKey, "aws-secret-access-key", os.Getenv(ENV_AWS_SECRET_ACCESS_KEY), "AWS secret access key")
+	flag.StringVar(&_config.Aws.AssumeRoleArn, "aws-assume-role-arn", os.Getenv(ENV_AWS_ASSUME_ROLE_ARN), "(Optional) ARN of the AWS IAM role to assume with the access keys, e.g. for a bucket in another AWS account")
+	flag.StringVar(&_config.Aws.ExternalId, "aws-external-id", os.Getenv(ENV_AWS_EXTERNAL_ID), "(Optional) External ID required by the trust policy of the assumed AWS IAM role")
+	flag.StringVar(&_config.Aws.S3ACL, "aws-s3-acl", os.Getenv(ENV_AWS_S3_ACL), "(Optional) AWS S3 canned ACL for uploaded objects, e.g. \"bucket-owner-full-control\"")
+	flag.StringVar(&_config.Aws.SseAlgorithm, "aws-s3-sse-algorithm", os.Getenv(ENV_AWS_S3_SSE_ALGORITHM), "(Optional) AWS S3 server-side encryption of uploaded objects: \"AES256\" or \"aws:kms\"")
+	flag.StringVar(&_config.Aws.KmsKeyId, "aws-s3-kms-key-id", os.Getenv(ENV_AWS_S3_KMS_KEY_ID), "(Optional) AWS KMS key ID or ARN for \"aws:kms\" server-side encryption of uploaded objects")
+	flag.StringVar(&_config.Aws.S3StorageClass, "aws-s3-storage-class", os.Getenv(ENV_AWS_S3_STORAGE_CLASS), "(Optional) AWS S3 storage class for uploaded objects, e.g. \"INTELLIGENT_TIERING\"")
+	flag.StringVar(&_config.Aws.S3SupersededStorageClass, "aws-s3-superseded-storage-class", os.Getenv(ENV_AWS_S3_SUPERSEDED_STORAGE_CLASS), "(Optional) AWS S3 storage class to transition data files only referenced by non-current snapshots to during maintenance, e.g. \"STANDARD_IA\"")
+	flag.StringVar(&_config.Gcs.Bucket, "gcs-bucket", os.Getenv(ENV_GCS_BUCKET), "Google Cloud Storage bucket name")
+	flag.StringVar(&_config.Gcs.HmacKeyId, "gcs-hmac-key-id", os.Getenv(ENV_GCS_HMAC_KEY_ID), "Google Cloud Storage HMAC key ID for reading with DuckDB")
+	flag.StringVar(&_config.Gcs.HmacSecret, "gcs-hmac-secret", os.Getenv(ENV_GCS_HMAC_SECRET), "Google Cloud Storage HMAC secret for reading with DuckDB")
+	flag.StringVar(&_config.Azure.Account, "azure-storage-account", os.Getenv(ENV_AZURE_STORAGE_ACCOUNT), "Azure Storage account name")
+	flag.StringVar(&_config.Azure.AccountKey, "azure-storage-account-key", os.Getenv(ENV_AZURE_STORAGE_ACCOUNT_KEY), "(Optional) Azure Storage account key, the default Azure credential chain is used if not set")
+	flag.StringVar(&_config.Azure.ContainerName, "azure-storage-container", os.Getenv(ENV_AZURE_STORAGE_CONTAINER), "Azure Blob Storage container name")
+	flag.StringVar(&_config.Azure.Endpoint, "azure-storage-endpoint", os.Getenv(ENV_AZURE_STORAGE_ENDPOINT), "(Optional) Azure Blob Storage endpoint, e.g. of Azurite. Default: \"https://<account>.blob.core.windows.net\"")
+	flag.StringVar(&_configParseValues.awsS3UploadPartSize, "aws-s3-upload-part-size", os.Getenv(ENV_AWS_S3_UPLOAD_PART_SIZE), "Size of the parts of multipart uploads to AWS S3 in MiB, at least 5. Default: \""+DEFAULT_AWS_S3_UPLOAD_PART_SIZE+"\"")
+	flag.StringVar(&_configParseValues.awsS3UploadConcurrency, "aws-s3-upload-concurrency", os.Getenv(ENV_AWS_S3_UPLOAD_CONCURRENCY), "Number of parts uploaded to AWS S3 in parallel per file. Default: \""+DEFAULT_AWS_S3_UPLOAD_CONCURRENCY+"\"")
+	flag.StringVar(&_configParseValues.awsS3MaxRetries, "aws-s3-max-retries", os.Getenv(ENV_AWS_S3_MAX_RETRIES), "Maximum number of retries of throttled or failed AWS S3 requests. Default: \""+DEFAULT_AWS_S3_MAX_RETRIES+"\"")
+	flag.StringVar(&_config.Aws.GlueCatalogId, "aws-glue-catalog-id", os.Getenv(ENV_AWS_GLUE_CATALOG_ID), "(Optional) AWS account ID of the Glue Data Catalog, if it's in another AWS account")
+	flag.StringVar(&_config.Aws.GlueEndpoint, "aws-glue-endpoint", os.Getenv(ENV_AWS_GLUE_ENDPOINT), "(Optional) AWS Glue endpoint URL, e.g. a VPC endpoint. Default: https://glue.<region>.amazonaws.com")
+	flag.StringVar(&_configParseValues.awsS3TableBuckets, "aws-s3-table-buckets", os.Getenv(ENV_AWS_S3_TABLE_BUCKETS), "(Optional) Comma-separated list of tables stored in other AWS S3 buckets (format: schema.table=bucket or schema.table=bucket:region)")
+}
+
+func parseFlags() {
+	flag.Parse()
+
+	if _config.Host == "" {
+		_config.Host = DEFAULT_HOST
+	}
+	if _config.Port == "" {
+		_config.Port = DEFAULT_PORT
+	}
+	if _config.Database == "" {
+		_config.Database = DEFAULT_DATABASE
+	}
+	if _config.User == "" {
+		_config.User = DEFAULT_USER
+	}
+	if _configParseValues.password == "" {
+		_configParseValues.password = DEFAULT_PASSWORD
+	}
+	if _configParseValues.password != "" {
+		if _config.User == "" {
+			panic("Password is set without a user")
+		}
+		_config.EncryptedPassword = StringToScramSha256(_configParseValues.password)
+	}
+	if _config.StoragePath == "" {
+		_config.StoragePath = DEFAULT_STORAGE_PATH
+	}
+	if _config.InitSqlFilepath == "" {
+		_config.InitSqlFilepath = DEFAULT_INIT_SQL_FILEPATH
+	}
+	if _config.LogLevel == "" {
+		_config.LogLevel = DEFAULT_LOG_LEVEL
+	} else if !slices.Contains(LOG_LEVELS, _config.LogLevel) {
+		panic("Invalid log level " + _config.LogLevel + ". Must be one of " + strings.Join(LOG_LEVELS, ", "))
+	}
+	if _config.StorageType == "" {
+		_config.StorageType = DEFAULT_DB_STORAGE_TYPE
+	} else if !slices.Contains(STORAGE_TYPES, _config.StorageType) {
+		panic("Invalid storage type " + _config.StorageType + ". Must be one of " + strings.Join(STORAGE_TYPES, ", "))
+	}
+	if _config.StorageReadFallback == "" {
+		_config.StorageReadFallback = DEFAULT_STORAGE_READ_FALLBACK
+	} else if !slices.Contains(STORAGE_READ_FALLBACK_MODES, _config.StorageReadFallback) {
+		panic("Invalid storage read fallback mode " + _config.StorageReadFallback + ". Must be one of " + strings.Join(STORAGE_READ_FALLBACK_MODES, ", "))
+	}
+	if _config.UnsupportedQueries == "" {
+		_config.UnsupportedQueries = DEFAULT_UNSUPPORTED_QUERIES
+	} else if !slices.Contains(UNSUPPORTED_QUERIES_MODES, _config.UnsupportedQueries) {
+		panic("Invalid unsupported queries mode " + _config.UnsupportedQueries + ". Must be one of " + strings.Join(UNSUPPORTED_QUERIES_MODES, ", "))
+	}
+	if _config.NumericAggregateOverflow == "" {
+		_config.NumericAggregateOverflow = DEFAULT_NUMERIC_AGGREGATE_OVERFLOW
+	} else if !slices.Contains(NUMERIC_AGGREGATE_OVERFLOW_MODES, _config.NumericAggregateOverflow) {
+		panic("Invalid numeric aggregate overflow mode " + _config.NumericAggregateOverflow + ". Must be one of " + strings.Join(NUMERIC_AGGREGATE_OVERFLOW_MODES, ", "))
+	}
+	if _configParseValues.caseInsensitiveTables == "" {
+		_configParseValues.caseInsensitiveTables = DEFAULT_CASE_INSENSITIVE_TABLES
+	}
+	caseInsensitiveTables, err := strconv.ParseBool(_configParseValues.caseInsensitiveTables)
+	if err != nil {
+		panic("Invalid case-insensitive tables value " + _configParseValues.caseInsensitiveTables + ". Must be one of true, false")
+	}
+	_config.CaseInsensitiveTables = caseInsensitiveTables
+	if _configParseValues.schemaAliases != "" {
+		_config.SchemaAliases = parseSchemaAliases(_configParseValues.schemaAliases)
+	}
+	if _config.IcebergBranch == "" {
+		_config.IcebergBranch = DEFAULT_ICEBERG_BRANCH
+	} else if strings.Contains(_config.IcebergBranch, ICEBERG_REF_SEPARATOR) {
+		panic("Invalid Iceberg branch " + _config.IcebergBranch + ". Must not contain \"" + ICEBERG_REF_SEPARATOR + "\"")
+	}
+	if _configParseValues.icebergStatistics == "" {
+		_configParseValues.icebergStatistics = DEFAULT_ICEBERG_STATISTICS
+	}
+	icebergStatistics, err := strconv.ParseBool(_configParseValues.icebergStatistics)
+	if err != nil {
+		panic("Invalid Iceberg statistics value " + _configParseValues.icebergStatistics + ". Must be one of true, false")
+	}
+	_config.IcebergStatistics = icebergStatistics
+	if _configParseValues.maxConcurrency == "" {
+		_configParseValues.maxConcurrency = DEFAULT_MAX_CONCURRENCY
+	}
+	maxConcurrency, err := StringToInt(_configParseValues.maxConcurrency)
+	if err != nil || maxConcurrency < 1 {
+		panic("Invalid max concurrency: " + _configParseValues.maxConcurrency)
+	}
+	_config.MaxConcurrency = maxConcurrency
+	if _configParseValues.icebergPartitions != "" {
+		_config.IcebergPartitions = parseIcebergPartitions(_configParseValues.icebergPartitions)
+	}
+	if _config.AuditLogPath != "" {
+		_config.AuditLogPath = strings.TrimSuffix(_config.AuditLogPath, "/")
+		if _config.AuditLogPath == _config.StoragePath || strings.HasPrefix(_config.AuditLogPath, _config.StoragePath+"/") {
+			panic("Invalid audit log path " + _config.AuditLogPath + ". Must be outside the storage path " + _config.StoragePath)
+		}
+	}
+	if _config.Catalog.Type == "" {
+		_config.Catalog.Type = DEFAULT_CATALOG_TYPE
+	} else if !slices.Contains(CATALOG_TYPES, _config.Catalog.Type) {
+		panic("Invalid catalog type " + _config.Catalog.Type + ". Must be one of " + strings.Join(CATALOG_TYPES, ", "))
+	}
+	if _config.Catalog.Type == CATALOG_TYPE_GLUE && _config.StorageType != STORAGE_TYPE_S3 {
+		panic("Glue catalog requires the S3 storage type")
+	}
+	if _config.Catalog.Type == CATALOG_TYPE_REST {
+		if _config.Catalog.Uri == "" {
+			panic("Catalog URI is required for the REST catalog type")
+		}
+		_config.Catalog.Uri = strings.TrimSuffix(_config.Catalog.Uri, "/")
+	}
+	if _config.StorageType == STORAGE_TYPE_S3 {
+		if _config.Aws.Region == "" {
+			panic("AWS region is required")
+		}
+		if _config.Aws.S3Endpoint == "" {
+			_config.Aws.S3Endpoint = DEFAULT_AWS_S3_ENDPOINT
+		}
+		parseAwsS3Endpoint(_config.Aws.S3Endpoint)
+		if _configParseValues.awsS3ForcePathStyle == "" {
+			_configParseValues.awsS3ForcePathStyle = DEFAULT_AWS_S3_FORCE_PATH_STYLE
+		}
+		awsS3ForcePathStyle, err := strconv.ParseBool(_configParseValues.awsS3ForcePathStyle)
+		if err != nil {
+			panic("Invalid AWS S3 force path style value " + _configParseValues.awsS3ForcePathStyle + ". Must be one of true, false")
+		}
+		_config.Aws.S3ForcePathStyle = awsS3ForcePathStyle
+		if _config.Aws.S3Bucket == "" {
+			panic("AWS S3 bucket name is required")
+		}
+		if _config.Aws.AccessKeyId == "" {
+			panic("AWS access key ID is required")
+		}
+		if _config.Aws.SecretAccessKey == "" {
+			panic("AWS secret access key is required")
+		}
+		if _config.Aws.ExternalId != "" && _config.Aws.AssumeRoleArn == "" {
+			panic("AWS external ID requires an AWS role ARN to assume")
+		}
+		if _config.Aws.S3ACL != "" && !slices.Contains(AWS_S3_ACLS, _config.Aws.S3ACL) {
+			panic("Invalid AWS S3 ACL " + _config.Aws.S3ACL + ". Must be one of " + strings.Join(AWS_S3_ACLS, ", "))
+		}
+		if _config.Aws.SseAlgorithm != "" && !slices.Contains(AWS_S3_SSE_ALGORITHMS, _config.Aws.SseAlgorithm) {
+			panic("Invalid AWS S3 server-side encryption algorithm " + _config.Aws.SseAlgorithm + ". Must be one of " + strings.Join(AWS_S3_SSE_ALGORITHMS, ", "))
+		}
+		if _config.Aws.KmsKeyId != "" && _config.Aws.SseAlgorithm != "aws:kms" {
+			panic("AWS KMS key ID requires the aws:kms server-side encryption algorithm")
+		}
+		if _config.Aws.S3StorageClass != "" && !slices.Contains(AWS_S3_STORAGE_CLASSES, _config.Aws.S3StorageClass) {
+			panic("Invalid AWS S3 storage class " + _config.Aws.S3StorageClass + ". Must be one of " + strings.Join(AWS_S3_STORAGE_CLASSES, ", "))
+		}
+		if _config.Aws.S3SupersededStorageClass != "" && !slices.Contains(AWS_S3_STORAGE_CLASSES, _config.Aws.S3SupersededStorageClass) {
+			panic("Invalid AWS S3 superseded storage class " + _config.Aws.S3SupersededStorageClass + ". Must be one of " + strings.Join(AWS_S3_STORAGE_CLASSES, ", "))
+		}
+		if _configParseValues.awsS3TableBuckets != "" {
+			_config.Aws.S3TableBuckets = parseAwsS3TableBuckets(_configParseValues.awsS3TableBuckets, _config.Aws.Region)
+		}
+		if _configParseValues.awsS3UploadPartSize == "" {
+			_configParseValues.awsS3UploadPartSize = DEFAULT_AWS_S3_UPLOAD_PART_SIZE
+		}
+		awsS3UploadPartSize, err := StringToInt(_configParseValues.awsS3UploadPartSize)
+		if err != nil || awsS3UploadPartSize < 5 {
+			panic("Invalid AWS S3 upload part size " + _configParseValues.awsS3UploadPartSize + ". Must be at least 5 (MiB)")
+		}
+		_config.Aws.UploadPartSize = int64(awsS3UploadPartSize) * 1024 * 1024
+		if _configParseValues.awsS3UploadConcurrency == "" {
+			_configParseValues.awsS3UploadConcurrency = DEFAULT_AWS_S3_UPLOAD_CONCURRENCY
+		}
+		awsS3UploadConcurrency, err := StringToInt(_configParseValues.awsS3UploadConcurrency)
+		if err != nil || awsS3UploadConcurrency < 1 {
+			panic("Invalid AWS S3 upload concurrency: " + _configParseValues.awsS3UploadConcurrency)
+		}
+		_config.Aws.UploadConcurrency = awsS3UploadConcurrency
+		if _configParseValues.awsS3MaxRetries == "" {
+			_configParseValues.awsS3MaxRetries = DEFAULT_AWS_S3_MAX_RETRIES
+		}
+		awsS3MaxRetries, err := StringToInt(_configParseValues.awsS3MaxRetries)
+		if err != nil || awsS3MaxRetries < 0 {
+			panic("Invalid AWS S3 max retries: " + _configParseValues.awsS3MaxRetries)
+		}
+		_config.Aws.MaxRetries = awsS3MaxRetries
+	}
+	if _config.StorageType == STORAGE_TYPE_GCS {
+		if _config.Gcs.Bucket == "" {
+			panic("GCS bucket name is required")
+		}
+		// Without HMAC keys, DuckDB can't read from GCS and queries fall back to local copies
+		if _config.StorageReadFallback == STORAGE_READ_FALLBACK_NEVER {
+			if _config.Gcs.HmacKeyId == "" {
+				panic("GCS HMAC key ID is required")
+			}
+			if _config.Gcs.HmacSecret == "" {
+				panic("GCS HMAC secret is required")
+			}
+		} else if (_config.Gcs.HmacKeyId == "") != (_config.Gcs.HmacSecret == "") {
+			panic("GCS HMAC key ID and secret must be specified together")
+		}
+	}
+	if _config.StorageType == STORAGE_TYPE_AZURE {
+		if _config.Azure.Account == "" {
+			panic("Azure Storage account name is required")
+		}
+		if _config.Azure.ContainerName == "" {
+			panic("Azure Blob Storage container name is required")
+		}
+		_config.Azure.Endpoint = strings.TrimSuffix(_config.Azure.Endpoint, "/")
+	}
+	if _configParseValues.pgIncludeSchemas != "" && _configParseValues.pgExcludeSchemas != "" {
+		panic("Cannot specify both --pg-include-schemas and --pg-exclude-schemas")
+	}
+	if _configParseValues.pgIncludeSchemas != "" {
+		_config.Pg.IncludeSchemas = NewSet(strings.Split(_configParseValues.pgIncludeSchemas, ","))
+	}
+	if _configParseValues.pgExcludeSchemas != "" {
+		_config.Pg.ExcludeSchemas = NewSet(strings.Split(_configParseValues.pgExcludeSchemas, ","))
+	}
+	if _configParseValues.pgIncludeTables != "" && _configParseValues.pgExcludeTables != "" {
+		panic("Cannot specify both --pg-include-tables and --pg-exclude-tables")
+	}
+	if _configParseValues.pgIncludeTables != "" {
+		_config.Pg.IncludeTables = NewSet(strings.Split(_configParseValues.pgIncludeTables, ","))
+	}
+	if _configParseValues.pgExcludeTables != "" {
+		_config.Pg.ExcludeTables = NewSet(strings.Split(_configParseValues.pgExcludeTables, ","))
+	}
+	if _configParseValues.pgIncludeViews != "" {
+		_config.Pg.IncludeViews = NewSet(strings.Split(_configParseValues.pgIncludeViews, ","))
+	}
+	if _configParseValues.pgPassthroughTables != "" {
+		_config.Pg.PassthroughTables = NewSet(strings.Split(_configParseValues.pgPassthroughTables, ","))
+	}
+	if _configParseValues.pgPassthroughUnsynced == "" {
+		_configParseValues.pgPassthroughUnsynced = DEFAULT_PG_PASSTHROUGH_UNSYNCED
+	}
+	pgPassthroughUnsynced, err := strconv.ParseBool(_configParseValues.pgPassthroughUnsynced)
+	if err != nil {
+		panic("Invalid passthrough unsynced value " + _configParseValues.pgPassthroughUnsynced + ". Must be one of true, false")
+	}
+	_config.Pg.PassthroughUnsyncedTables = pgPassthroughUnsynced
+	if (_configParseValues.pgPassthroughTables != "" || _config.Pg.PassthroughUnsyncedTables) && _config.Pg.DatabaseUrl == "" {
+		panic("--pg-passthrough-tables and --pg-passthrough-unsynced require --pg-database-url")
+	}
+	if _configParseValues.pgSyncBatchRetries == "" {
+		_configParseValues.pgSyncBatchRetries = DEFAULT_PG_SYNC_BATCH_RETRIES
+	}
+	pgSyncBatchRetries, err := StringToInt(_configParseValues.pgSyncBatchRetries)
+	if err != nil || pgSyncBatchRetries < 0 {
+		panic("Invalid sync batch retries: " + _configParseValues.pgSyncBatchRetries)
+	}
+	_config.Pg.SyncBatchRetries = pgSyncBatchRetries
+	if _configParseValues.pgSyncSkipFailedBatches == "" {
+		_configParseValues.pgSyncSkipFailedBatches = DEFAULT_PG_SYNC_SKIP_FAILED_BATCHES
+	}
+	pgSyncSkipFailedBatches, err := strconv.ParseBool(_configParseValues.pgSyncSkipFailedBatches)
+	if err != nil {
+		panic("Invalid sync skip failed batches value " + _configParseValues.pgSyncSkipFailedBatches + ". Must be one of true, false")
+	}
+	_config.Pg.SyncSkipFailedBatches = pgSyncSkipFailedBatches
+	if _config.Pg.SyncInfiniteTimestamps == "" {
+		_config.Pg.SyncInfiniteTimestamps = DEFAULT_PG_SYNC_INFINITE_TIMESTAMPS
+	} else if !slices.Contains(INFINITE_TIMESTAMPS_MODES, _config.Pg.SyncInfiniteTimestamps) {
+		panic("Invalid sync infinite timestamps mode " + _config.Pg.SyncInfiniteTimestamps + ". Must be one of " + strings.Join(INFINITE_TIMESTAMPS_MODES, ", "))
+	}
+	if _configParseValues.pgSyncSourcePoolSize == "" {
+		_configParseValues.pgSyncSourcePoolSize = DEFAULT_PG_SYNC_SOURCE_POOL_SIZE
+	}
+	pgSyncSourcePoolSize, err := StringToInt(_configParseValues.pgSyncSourcePoolSize)
+	if err != nil || pgSyncSourcePoolSize < 1 {
+		panic("Invalid sync source pool size: " + _configParseValues.pgSyncSourcePoolSize)
+	}
+	_config.Pg.SyncSourcePoolSize = pgSyncSourcePoolSize
+
+	if _configParseValues.serverMaxConnections == "" {
+		_configParseValues.serverMaxConnections = DEFAULT_SERVER_MAX_CONNECTIONS
+	}
+	serverMaxConnections, err := StringToInt(_configParseValues.serverMaxConnections)
+	if err != nil || serverMaxConnections < 1 {
+		panic("Invalid server max connections: " + _configParseValues.serverMaxConnections)
+	}
+	_config.Server.MaxConnections = serverMaxConnections
+	if _configParseValues.serverMaxAcceptRate == "" {
+		_configParseValues.serverMaxAcceptRate = DEFAULT_SERVER_MAX_ACCEPT_RATE
+	}
+	serverMaxAcceptRate, err := StringToInt(_configParseValues.serverMaxAcceptRate)
+	if err != nil || serverMaxAcceptRate < 0 {
+		panic("Invalid server max accept rate: " + _configParseValues.serverMaxAcceptRate)
+	}
+	_config.Server.MaxAcceptRate = serverMaxAcceptRate
+	if _configParseValues.serverTcpKeepAlive == "" {
+		_configParseValues.serverTcpKeepAlive = DEFAULT_SERVER_TCP_KEEPALIVE
+	}
+	_config.Server.TCPKeepAlive = parseKeepAlive(_configParseValues.serverTcpKeepAlive, "Invalid server TCP keepalive: ")
+	if _configParseValues.serverQueryKeepAlive == "" {
+		_configParseValues.serverQueryKeepAlive = DEFAULT_SERVER_QUERY_KEEPALIVE
+	}
+	_config.Server.QueryKeepAlive = parseKeepAlive(_configParseValues.serverQueryKeepAlive, "Invalid server query keepalive: ")
+	if _configParseValues.serverVersion == "" {
+		_configParseValues.serverVersion = DEFAULT_SERVER_VERSION
+	}
+	if !SERVER_VERSION_REGEX.MatchString(_configParseValues.serverVersion) {
+		panic("Invalid server version: " + _configParseValues.serverVersion + ". Must be a Postgres version like " + PG_VERSION)
+	}
+	_config.Server.Version = _configParseValues.serverVersion
+	if _config.Duckdb.MemoryLimit != "" && !DUCKDB_MEMORY_LIMIT_REGEX.MatchString(_config.Duckdb.MemoryLimit) {
+		panic("Invalid DuckDB memory limit: " + _config.Duckdb.MemoryLimit)
+	}
+	if _config.Maintenance.Interval != "" {
+		if _, err := time.ParseDuration(_config.Maintenance.Interval); err != nil {
+			panic("Invalid maintenance interval format: " + _config.Maintenance.Interval)
+		}
+	}
+	if _configParseValues.maintenanceMaxDataFiles == "" {
+		_configParseValues.maintenanceMaxDataFiles = DEFAULT_MAINTENANCE_MAX_DATA_FILES
+	}
+	maintenanceMaxDataFiles, err := StringToInt(_configParseValues.maintenanceMaxDataFiles)
+	if err != nil || maintenanceMaxDataFiles < 1 {
+		panic("Invalid maintenance max data files: " + _configParseValues.maintenanceMaxDataFiles)
+	}
+	_config.Maintenance.MaxDataFiles = maintenanceMaxDataFiles
+	if _configParseValues.maintenanceMinAvgFileSize == "" {
+		_configParseValues.maintenanceMinAvgFileSize = DEFAULT_MAINTENANCE_MIN_AVG_FILE_SIZE
+	}
+	maintenanceMinAvgFileSize, err := strconv.ParseInt(_configParseValues.maintenanceMinAvgFileSize, 10, 64)
+	if err != nil || maintenanceMinAvgFileSize < 0 {
+		panic("Invalid maintenance min average file size: " + _configParseValues.maintenanceMinAvgFileSize)
+	}
+	_config.Maintenance.MinAvgFileSize = maintenanceMinAvgFileSize
+	if _configParseValues.maintenanceTargetFileSize == "" {
+		_configParseValues.maintenanceTargetFileSize = DEFAULT_MAINTENANCE_TARGET_FILE_SIZE
+	}
+	maintenanceTargetFileSize, err := strconv.ParseInt(_configParseValues.maintenanceTargetFileSize, 10, 64)
+	if err != nil || maintenanceTargetFileSize <= 0 {
+		panic("Invalid maintenance target file size: " + _configParseValues.maintenanceTargetFileSize)
+	}
+	_config.Maintenance.TargetFileSize = maintenanceTargetFileSize
+	if _configParseValues.maintenanceVacuumMinAge == "" {
+		_configParseValues.maintenanceVacuumMinAge = DEFAULT_MAINTENANCE_VACUUM_MIN_AGE
+	}
+	maintenanceVacuumMinAge, err := time.ParseDuration(_configParseValues.maintenanceVacuumMinAge)
+	if err != nil || maintenanceVacuumMinAge < 0 {
+		panic("Invalid maintenance vacuum min age: " + _configParseValues.maintenanceVacuumMinAge)
+	}
+	_config.Maintenance.VacuumMinAge = maintenanceVacuumMinAge
+	if _configParseValues.maintenanceSnapshotMaxAge != "" {
+		maintenanceSnapshotMaxAge, err := time.ParseDuration(_configParseValues.maintenanceSnapshotMaxAge)
+		if err != nil || maintenanceSnapshotMaxAge <= 0 {
+			panic("Invalid maintenance snapshot max age: " + _configParseValues.maintenanceSnapshotMaxAge)
+		}
+		_config.Maintenance.SnapshotMaxAge = maintenanceSnapshotMaxAge
+	}
+	if _configParseValues.maintenanceKeepSnapshots == "" {
+		_configParseValues.maintenanceKeepSnapshots = DEFAULT_MAINTENANCE_KEEP_SNAPSHOTS
+	}
+	maintenanceKeepSnapshots, err := StringToInt(_configParseValues.maintenanceKeepSnapshots)
+	if err != nil || maintenanceKeepSnapshots < 1 {
+		panic("Invalid maintenance keep snapshots: " + _configParseValues.maintenanceKeepSnapshots)
+	}
+	_config.Maintenance.KeepSnapshots = maintenanceKeepSnapshots
+	if _configParseValues.maintenanceExpireDryRun == "" {
+		_configParseValues.maintenanceExpireDryRun = DEFAULT_MAINTENANCE_EXPIRE_DRY_RUN
+	}
+	maintenanceExpireDryRun, err := strconv.ParseBool(_configParseValues.maintenanceExpireDryRun)
+	if err != nil {
+		panic("Invalid maintenance expire dry run: " + _configParseValues.maintenanceExpireDryRun)
+	}
+	_config.Maintenance.ExpireDryRun = maintenanceExpireDryRun
+	if _configParseValues.parquetStatsColumns != "" {
+		_config.Parquet.StatsColumns = NewSet(strings.Split(_configParseValues.parquetStatsColumns, ","))
+	}
+	if _configParseValues.parquetMaxStatsColumns == "" {
+		_configParseValues.parquetMaxStatsColumns = DEFAULT_PARQUET_MAX_STATS_COLUMNS
+	}
+	parquetMaxStatsColumns, err := StringToInt(_configParseValues.parquetMaxStatsColumns)
+	if err != nil || parquetMaxStatsColumns < 0 {
+		panic("Invalid Parquet max stats columns: " + _configParseValues.parquetMaxStatsColumns)
+	}
+	_config.Parquet.MaxStatsColumns = parquetMaxStatsColumns
+	if _configParseValues.parquetRowGroupSize == "" {
+		_configParseValues.parquetRowGroupSize = DEFAULT_PARQUET_ROW_GROUP_SIZE
+	}
+	parquetRowGroupSize, err := StringToInt(_configParseValues.parquetRowGroupSize)
+	if err != nil {
+		panic("Invalid Parquet row group size: " + _configParseValues.parquetRowGroupSize)
+	}
+	_config.Parquet.RowGroupSize = int64(parquetRowGroupSize)
+	if _config.Parquet.Compression == "" {
+		_config.Parquet.Compression = DEFAULT_PARQUET_COMPRESSION
+	} else if !slices.Contains(PARQUET_COMPRESSIONS, _config.Parquet.Compression) {
+		panic("Invalid Parquet compression " + _config.Parquet.Compression + ". Must be one of " + strings.Join(PARQUET_COMPRESSIONS, ", "))
+	}
+	if _configParseValues.parquetTableCompressions != "" {
+		_config.Parquet.TableCompressions = parseParquetTableCompressions(_configParseValues.parquetTableCompressions)
+	}
+	if _configParseValues.parquetTableRowGroupSizes != "" {
+		_config.Parquet.TableRowGroupSizes = parseParquetTableRowGroupSizes(_configParseValues.parquetTableRowGroupSizes)
+	}
+
+	_configParseValues = configParseValues{}
+}
+
+func LoadConfig(reRegisterFlags ...bool) *Config {
+	if reRegisterFlags != nil && reRegisterFlags[0] {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		registerFlags()
+	}
+	parseFlags()
+	return &_config
+}
+
+// Parses "host[:port]" or "scheme://host[:port]" where the scheme defaults to https
+func parseAwsS3Endpoint(endpoint string) *url.URL {
+	if endpoint == "" {
+		endpoint = DEFAULT_AWS_S3_ENDPOINT
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil || endpointUrl.Host == "" || (endpointUrl.Scheme != "http" && endpointUrl.Scheme != "https") || strings.Trim(endpointUrl.Path, "/") != "" {
+		panic("Invalid AWS S3 endpoint " + endpoint + ". Must be in the format host, host:port, or http(s)://host:port")
+	}
+	endpointUrl.Path = ""
+
+	return endpointUrl
+}
+
+// Parses "schema.table=bucket:region,..." where the region defaults to the AWS region
+func parseAwsS3TableBuckets(value string, defaultRegion string) map[string]AwsS3Bucket {
+	awsS3TableBuckets := map[string]AwsS3Bucket{}
+
+	for _, tableBucket := range strings.Split(value, ",") {
+		schemaTable, bucket, found := strings.Cut(strings.TrimSpace(tableBucket), "=")
+		if !found || !strings.Contains(schemaTable, ".") || bucket == "" {
+			panic("Invalid AWS S3 table bucket " + tableBucket + ". Must be in the format schema.table=bucket or schema.table=bucket:region")
+		}
+
+		awsS3Bucket := AwsS3Bucket{Name: bucket, Region: defaultRegion}
+		if name, region, found := strings.Cut(bucket, ":"); found {
+			awsS3Bucket = AwsS3Bucket{Name: name, Region: region}
+		}
+		awsS3TableBuckets[schemaTable] = awsS3Bucket
+	}
+
+	return awsS3TableBuckets
+}
+
+// Parses "schema.table=column,schema.table=transform(column),..." where the transform defaults to identity
+func parseIcebergPartitions(value string) map[string]IcebergPartitionColumn {
+	icebergPartitions := map[string]IcebergPartitionColumn{}
+
+	for _, tablePartition := range strings.Split(value, ",") {
+		schemaTable, partition, found := strings.Cut(strings.TrimSpace(tablePartition), "=")
+		if !found || !strings.Contains(schemaTable, ".") || partition == "" {
+			panic("Invalid Iceberg partition " + tablePartition + ". Must be in the format schema.table=column or schema.table=transform(column)")
+		}
+
+		partitionColumn := IcebergPartitionColumn{ColumnName: partition, Transform: ICEBERG_TRANSFORM_IDENTITY}
+		if transform, column, found := strings.Cut(partition, "("); found {
+			column, closed := strings.CutSuffix(column, ")")
+			if !closed || column == "" {
+				panic("Invalid Iceberg partition " + tablePartition + ". Must be in the format schema.table=column or schema.table=transform(column)")
+			}
+			if !slices.Contains(ICEBERG_PARTITION_TRANSFORMS, transform) {
+				panic("Invalid Iceberg partition transform " + transform + ". Must be one of " + strings.Join(ICEBERG_PARTITION_TRANSFORMS, ", "))
+			}
+			partitionColumn = IcebergPartitionColumn{ColumnName: column, Transform: transform}
+		}
+		icebergPartitions[schemaTable] = partitionColumn
+	}
+
+	return icebergPartitions
+}
+
+// Parses "exposed_schema=iceberg_schema,..." where each Iceberg schema has a single exposed name
+func parseSchemaAliases(value string) map[string]string {
+	schemaAliases := map[string]string{}
+	icebergSchemas := NewSet([]string{})
+
+	for _, schemaAlias := range strings.Split(value, ",") {
+		exposedSchema, icebergSchema, found := strings.Cut(strings.TrimSpace(schemaAlias), "=")
+		if !found || exposedSchema == "" || icebergSchema == "" || strings.Contains(exposedSchema, ".") || strings.Contains(icebergSchema, ".") {
+			panic("Invalid schema alias " + schemaAlias + ". Must be in the format exposed_schema=iceberg_schema")
+		}
+		if _, ok := schemaAliases[exposedSchema]; ok || icebergSchemas.Contains(icebergSchema) {
+			panic("Invalid schema alias " + schemaAlias + ". Each schema can only be aliased once")
+		}
+		schemaAliases[exposedSchema] = icebergSchema
+		icebergSchemas.Add(icebergSchema)
+	}
+
+	return schemaAliases
+}
+
+// Parses "schema.table=codec,..."
+func parseParquetTableCompressions(value string) map[string]string {
+	parquetTableCompressions := map[string]string{}
+
+	for _, tableCompression := range strings.Split(value, ",") {
+		schemaTable, compression, found := strings.Cut(strings.TrimSpace(tableCompression), "=")
+		if !found || !strings.Contains(schemaTable, ".") {
+			panic("Invalid Parquet table compression " + tableCompression + ". Must be in the format schema.table=codec")
+		}
+		if !slices.Contains(PARQUET_COMPRESSIONS, compression) {
+			panic("Invalid Parquet compression " + compression + ". Must be one of " + strings.Join(PARQUET_COMPRESSIONS, ", "))
+		}
+		parquetTableCompressions[schemaTable] = compression
+	}
+
+	return parquetTableCompressions
+}
+
+// Parses "schema.table=bytes,..." where 0 or less is the default row group size like in --parquet-row-group-size
+func parseParquetTableRowGroupSizes(value string) map[string]int64 {
+	parquetTableRowGroupSizes := map[string]int64{}
+
+	for _, tableRowGroupSize := range strings.Split(value, ",") {
+		schemaTable, rowGroupSize, found := strings.Cut(strings.TrimSpace(tableRowGroupSize), "=")
+		if !found || !strings.Contains(schemaTable, ".") {
+			panic("Invalid Parquet table row group size " + tableRowGroupSize + ". Must be in the format schema.table=bytes")
+		}
+		parsedRowGroupSize, err := strconv.ParseInt(rowGroupSize, 10, 64)
+		if err != nil {
+			panic("Invalid Parquet row group size: " + rowGroupSize)
+		}
+		parquetTableRowGroupSizes[schemaTable] = parsedRowGroupSize
+	}
+
+	return parquetTableRowGroupSizes
+}
+
+// Parses a non-negative duration where "0" disables the keepalive
+func parseKeepAlive(value string, invalidMessage string) time.Duration {
+	keepAlive, err := time.ParseDuration(value)
+	if err != nil || keepAlive < 0 {
+		panic(invalidMessage + value)
+	}
+	return keepAlive
+}
@@ -1,7 +1,9 @@
-package main
+package bemidb
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 )
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -76,11 +78,25 @@ func (set *Set) Add(item string) {
 	set.valueByItem[item] = true
 }
 
+func (set *Set) Remove(item string) {
+	delete(set.valueByItem, item)
+}
+
 func (set *Set) Contains(item string) bool {
 	_, ok := set.valueByItem[item]
 	return ok
 }
 
+// Returns the items in sorted order
+func (set *Set) Values() []string {
+	items := make([]string, 0, len(set.valueByItem))
+	for item := range set.valueByItem {
+		items = append(items, item)
+	}
+	slices.Sort(items)
+	return items
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 type IcebergSchemaTable struct {
@@ -92,6 +108,15 @@ func (schemaTable IcebergSchemaTable) String() string {
 	return fmt.Sprintf(`"%s"."%s"`, schemaTable.Schema, schemaTable.Table)
 }
 
+// schema.table -> IcebergSchemaTable, e.g. from a command argument
+func ParseIcebergSchemaTable(schemaTable string) IcebergSchemaTable {
+	schema, table, found := strings.Cut(schemaTable, ".")
+	if !found {
+		panic("Invalid table " + schemaTable + ". Must be in the format schema.table")
+	}
+	return IcebergSchemaTable{Schema: schema, Table: table}
+}
+
 type QuerySchemaTable struct {
 	Schema string
 	Table  string
@@ -106,9 +131,11 @@ func (qSchemaTable QuerySchemaTable) ToIcebergSchemaTable() IcebergSchemaTable {
 }
 
 type PgSchemaTable struct {
-	Schema                 string
-	Table                  string
-	ParentPartitionedTable string
+	Schema                    string
+	Table                     string
+	PartitionedTableAncestors []PgSchemaTable // Partitioned tables of a partition, its parent and the parent's ancestors
+	Partitioned               bool            // Declaratively partitioned table, its rows are stored in the partitions
+	View                      bool            // View or materialized view, synced from its result set
 }
 
 func (pgSchemaTable PgSchemaTable) String() string {
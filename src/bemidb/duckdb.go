@@ -0,0 +1,558 @@
+package bemidb
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	duckDb "github.com/marcboeker/go-duckdb"
+)
+
+var DEFAULT_BOOT_QUERIES = []string{
+	"INSTALL iceberg",
+	"LOAD iceberg",
+	"CREATE SCHEMA IF NOT EXISTS public",
+	"USE public",
+}
+
+// Range values are stored in the Postgres text format, e.g. ["2024-01-01 00:00:00+00","2024-01-02 00:00:00+00")
+var DUCKDB_RANGE_MACROS = []string{
+	`CREATE OR REPLACE MACRO bemidb_range_bound_text(v) AS CASE WHEN v IS NULL THEN '' WHEN regexp_matches(v, '[\s,()\[\]"]') THEN '"' || v || '"' ELSE v END`,
+	`CREATE OR REPLACE MACRO bemidb_range(lower_value, upper_value, bounds) AS left(bounds, 1) || bemidb_range_bound_text(CAST(lower_value AS VARCHAR)) || ',' || bemidb_range_bound_text(CAST(upper_value AS VARCHAR)) || right(bounds, 1)`,
+	// A single element is treated as the range [x,x]
+	`CREATE OR REPLACE MACRO bemidb_range_value(v) AS CASE WHEN v IS NULL THEN NULL WHEN CAST(v AS VARCHAR) = 'empty' OR regexp_matches(CAST(v AS VARCHAR), '^[\[(]') THEN CAST(v AS VARCHAR) ELSE '[' || bemidb_range_bound_text(CAST(v AS VARCHAR)) || ',' || bemidb_range_bound_text(CAST(v AS VARCHAR)) || ']' END`,
+	`CREATE OR REPLACE MACRO bemidb_range_bound(r, i) AS nullif(trim(split_part(substr(r, 2, length(r) - 2), ',', i), ' "'), '')`,
+	`CREATE OR REPLACE MACRO bemidb_range_key(v) AS coalesce(try_cast(v AS DOUBLE), epoch_us(try_cast(v AS TIMESTAMPTZ))::DOUBLE)`,
+	`CREATE OR REPLACE MACRO bemidb_range_starts_before_end(a, b) AS bemidb_range_bound(a, 1) IS NULL OR bemidb_range_bound(b, 2) IS NULL OR bemidb_range_key(bemidb_range_bound(a, 1)) < bemidb_range_key(bemidb_range_bound(b, 2)) OR (bemidb_range_key(bemidb_range_bound(a, 1)) = bemidb_range_key(bemidb_range_bound(b, 2)) AND left(a, 1) = '[' AND right(b, 1) = ']')`,
+	`CREATE OR REPLACE MACRO bemidb_range_lower_within(a, b) AS bemidb_range_bound(a, 1) IS NULL OR (bemidb_range_bound(b, 1) IS NOT NULL AND (bemidb_range_key(bemidb_range_bound(a, 1)) < bemidb_range_key(bemidb_range_bound(b, 1)) OR (bemidb_range_key(bemidb_range_bound(a, 1)) = bemidb_range_key(bemidb_range_bound(b, 1)) AND (left(a, 1) = '[' OR left(b, 1) = '('))))`,
+	`CREATE OR REPLACE MACRO bemidb_range_upper_within(a, b) AS bemidb_range_bound(a, 2) IS NULL OR (bemidb_range_bound(b, 2) IS NOT NULL AND (bemidb_range_key(bemidb_range_bound(a, 2)) > bemidb_range_key(bemidb_range_bound(b, 2)) OR (bemidb_range_key(bemidb_range_bound(a, 2)) = bemidb_range_key(bemidb_range_bound(b, 2)) AND (right(a, 1) = ']' OR right(b, 1) = ')'))))`,
+	// a && b
+	`CREATE OR REPLACE MACRO bemidb_range_overlaps(a, b) AS CASE WHEN a IS NULL OR b IS NULL THEN NULL ELSE bemidb_range_value(a) != 'empty' AND bemidb_range_value(b) != 'empty' AND bemidb_range_starts_before_end(bemidb_range_value(a), bemidb_range_value(b)) AND bemidb_range_starts_before_end(bemidb_range_value(b), bemidb_range_value(a)) END`,
+	// a @> b
+	`CREATE OR REPLACE MACRO bemidb_range_contains(a, b) AS CASE WHEN a IS NULL OR b IS NULL THEN NULL ELSE bemidb_range_value(b) = 'empty' OR (bemidb_range_value(a) != 'empty' AND bemidb_range_lower_within(bemidb_range_value(a), bemidb_range_value(b)) AND bemidb_range_upper_within(bemidb_range_value(a), bemidb_range_value(b))) END`,
+}
+
+// Synced arrays have a single dimension, so array_length() is NULL for other dimensions like for one-dimensional arrays in Postgres
+var DUCKDB_ARRAY_MACROS = []string{
+	`CREATE OR REPLACE MACRO bemidb_array_length(arr, dim) AS CASE WHEN dim = 1 THEN nullif(len(arr), 0)::INTEGER END`,
+	`CREATE OR REPLACE MACRO bemidb_cardinality(arr) AS len(arr)::INTEGER`,
+	// array_position(arr, elem, start) searches from the start position but still returns the position in the whole array
+	`CREATE OR REPLACE MACRO bemidb_array_position(arr, elem, start) AS (list_position(list_slice(arr, greatest(start, 1), len(arr)), elem) + greatest(start, 1) - 1)::INTEGER`,
+	// @> and && ignore the order and duplicates of elements, and NULL elements never match like NULL = NULL isn't true
+	`CREATE OR REPLACE MACRO bemidb_array_contains(arr, sub) AS CASE WHEN arr IS NOT NULL AND sub IS NOT NULL THEN len(list_filter(sub, elem -> elem IS NULL OR NOT list_contains(arr, elem))) = 0 END`,
+	`CREATE OR REPLACE MACRO bemidb_array_overlaps(arr1, arr2) AS CASE WHEN arr1 IS NOT NULL AND arr2 IS NOT NULL THEN len(list_filter(arr2, elem -> elem IS NOT NULL AND list_contains(arr1, elem))) > 0 END`,
+}
+
+// jsonb values are stored as JSON text. Paths of #> and #>> are text[] that are looked up as JSON pointers, e.g. {a,0} -> /a/0
+var DUCKDB_JSONB_MACROS = []string{
+	// j ? key is true for a top-level object key, a top-level string array element, or a string scalar
+	`CREATE OR REPLACE MACRO bemidb_jsonb_exists(j, key) AS CASE json_type(j) WHEN 'OBJECT' THEN list_contains(json_keys(j), key) WHEN 'ARRAY' THEN list_contains(CAST(json_extract(j, '$[*]') AS JSON[]), to_json(key)) WHEN 'VARCHAR' THEN json_extract_string(j, '$') = key ELSE false END`,
+	`CREATE OR REPLACE MACRO bemidb_jsonb_exists_any(j, keys) AS len(list_filter(keys, k -> bemidb_jsonb_exists(j, k))) > 0`,
+	`CREATE OR REPLACE MACRO bemidb_jsonb_exists_all(j, keys) AS len(list_filter(keys, k -> bemidb_jsonb_exists(j, k))) = len(keys)`,
+	`CREATE OR REPLACE MACRO bemidb_jsonb_pointer(path) AS '/' || array_to_string(list_transform(path, p -> replace(replace(p, '~', '~0'), '/', '~1')), '/')`,
+	// An empty path returns the whole value
+	`CREATE OR REPLACE MACRO bemidb_jsonb_extract_path(j, path) AS CASE WHEN len(path) = 0 THEN CAST(j AS JSON) ELSE json_extract(j, bemidb_jsonb_pointer(path)) END`,
+	`CREATE OR REPLACE MACRO bemidb_jsonb_extract_path_text(j, path) AS CASE WHEN len(path) = 0 THEN CAST(j AS VARCHAR) ELSE json_extract_string(j, bemidb_jsonb_pointer(path)) END`,
+	// jsonb_path_query() returns no rows if the path doesn't match, and a row per match if it has a wildcard
+	`CREATE OR REPLACE MACRO bemidb_jsonb_path_query(j, path) AS list_filter([json_extract(j, path)], v -> v IS NOT NULL)`,
+	`CREATE OR REPLACE MACRO bemidb_jsonb_path_query_wildcard(j, path) AS json_extract(j, path)`,
+}
+
+// bytea values are stored in the Postgres hex format, e.g. \x68656c6c6f, and bytea literals are DuckDB BLOBs.
+// decode() returns the hex format like Postgres outputs bytea, and base64 lines are wrapped at 76 characters like in Postgres
+var DUCKDB_ENCODING_MACROS = []string{
+	`CREATE OR REPLACE MACRO bemidb_bytea_hex(data) AS lower(CASE WHEN typeof(data) = 'BLOB' THEN hex(data) ELSE substr(CAST(data AS VARCHAR), 3) END)`,
+	`CREATE OR REPLACE MACRO bemidb_encode(data, format) AS CASE lower(format) WHEN 'hex' THEN bemidb_bytea_hex(data) WHEN 'base64' THEN rtrim(regexp_replace(to_base64(from_hex(bemidb_bytea_hex(data))), '(.{76})', '\1' || chr(10), 'g'), chr(10)) ELSE error('unrecognized encoding: "' || format || '"') END`,
+	`CREATE OR REPLACE MACRO bemidb_decode(str, format) AS '\x' || lower(hex(CASE lower(format) WHEN 'hex' THEN from_hex(regexp_replace(str, '\s', '', 'g')) WHEN 'base64' THEN from_base64(regexp_replace(str, '\s', '', 'g')) ELSE error('unrecognized encoding: "' || format || '"') END))`,
+	`CREATE OR REPLACE MACRO bemidb_to_hex(num) AS lower(to_hex(num))`,
+}
+
+// pg_typeof() returns DuckDB type names, e.g. varchar or decimal(2,1), which are mapped to the Postgres ones, e.g. text or numeric.
+// Nested arrays have the same type as their elements in Postgres, e.g. text[]
+var DUCKDB_TYPE_MACROS = []string{
+	`CREATE OR REPLACE MACRO bemidb_pg_type_name(t) AS CASE WHEN t = 'varchar' THEN 'text' WHEN t = 'double' THEN 'double precision' WHEN t = 'float' THEN 'real' WHEN t = 'tinyint' THEN 'smallint' WHEN t = 'hugeint' OR t LIKE 'decimal(%' THEN 'numeric' WHEN t = 'blob' THEN 'bytea' WHEN t = 'timestamp' THEN 'timestamp without time zone' WHEN t = 'time' THEN 'time without time zone' WHEN t LIKE 'struct(%' THEN 'record' WHEN t = '"null"' THEN 'unknown' ELSE t END`,
+	`CREATE OR REPLACE MACRO bemidb_pg_typeof(v) AS CASE WHEN pg_typeof(v) LIKE '%[]' THEN bemidb_pg_type_name(regexp_replace(pg_typeof(v), '(\[\])+$', '')) || '[]' ELSE bemidb_pg_type_name(pg_typeof(v)) END`,
+}
+
+// now(), current_timestamp and transaction_timestamp() are built in and return the start time of the transaction.
+// Each query runs in its own transaction, so statement_timestamp() returns the same value
+var DUCKDB_TIMESTAMP_MACROS = []string{
+	"CREATE OR REPLACE MACRO statement_timestamp() AS now()",
+}
+
+type Duckdb struct {
+	db     *sql.DB
+	config *Config
+	// Set for the S3 storage type, the S3 secrets are recreated when the credentials of an assumed role are refreshed
+	awsCredentials      aws.CredentialsProvider
+	awsAccessKeyId      string
+	awsCredentialsMutex sync.Mutex
+	// The TimeZone setting requires the ICU extension, which may not be installable, e.g. without network access
+	supportsTimeZones     bool
+	supportsTimeZonesOnce sync.Once
+	// Held by a query with changed global settings until their previous values are restored
+	globalSettingsMutex sync.Mutex
+}
+
+func NewDuckdb(config *Config) *Duckdb {
+	ctx := context.Background()
+	// An empty path opens an in-memory database. Connections of the pool share the database, since DuckDB locks its file to a single instance
+	db, err := sql.Open("duckdb", config.Duckdb.DatabasePath)
+	PanicIfError(err)
+
+	duckdb := &Duckdb{
+		db:     db,
+		config: config,
+	}
+
+	bootQueries := readDuckdbInitFile(config)
+	if bootQueries == nil {
+		bootQueries = DEFAULT_BOOT_QUERIES
+	}
+	for _, query := range bootQueries {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	// Macros are stored in the database, so they're replaced when an on-disk database is reopened
+	for _, query := range DUCKDB_RANGE_MACROS {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	for _, query := range DUCKDB_ARRAY_MACROS {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	for _, query := range DUCKDB_JSONB_MACROS {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	for _, query := range DUCKDB_ENCODING_MACROS {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	for _, query := range DUCKDB_TYPE_MACROS {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	for _, query := range DUCKDB_TIMESTAMP_MACROS {
+		_, err := duckdb.ExecContext(ctx, query, nil)
+		PanicIfError(err)
+	}
+	if config.Duckdb.TempDirectory != "" {
+		_, err = duckdb.ExecContext(ctx, "SET temp_directory = '$tempDirectory'", map[string]string{"tempDirectory": config.Duckdb.TempDirectory})
+		PanicIfError(err)
+	}
+	if config.Duckdb.MemoryLimit != "" {
+		_, err = duckdb.ExecContext(ctx, "SET memory_limit = '$memoryLimit'", map[string]string{"memoryLimit": config.Duckdb.MemoryLimit})
+		PanicIfError(err)
+	}
+	duckdb.registerClockTimestampFunction(ctx)
+	duckdb.registerXPathFunctions(ctx)
+	duckdb.registerSimilarityFunction(ctx)
+	duckdb.registerJsonbContainsFunction(ctx)
+
+	switch config.StorageType {
+	case STORAGE_TYPE_S3:
+		duckdb.awsCredentials = newAwsCredentialsProvider(config)
+		err = duckdb.refreshS3Secrets(ctx)
+		PanicIfError(err)
+
+		if config.LogLevel == LOG_LEVEL_TRACE {
+			_, err = duckdb.ExecContext(ctx, "SET enable_http_logging=true", nil)
+			PanicIfError(err)
+		}
+	case STORAGE_TYPE_GCS:
+		if config.Gcs.HmacKeyId == "" {
+			break // Tables are read through local copies
+		}
+		query := "CREATE SECRET gcs_secret (TYPE GCS, KEY_ID '$hmacKeyId', SECRET '$hmacSecret', SCOPE '$gcsBucket')"
+		_, err = duckdb.ExecContext(ctx, query, map[string]string{
+			"hmacKeyId":  config.Gcs.HmacKeyId,
+			"hmacSecret": config.Gcs.HmacSecret,
+			"gcsBucket":  "gs://" + config.Gcs.Bucket,
+		})
+		PanicIfError(err)
+	case STORAGE_TYPE_AZURE:
+		for _, query := range []string{"INSTALL azure", "LOAD azure"} {
+			_, err = duckdb.ExecContext(ctx, query, nil)
+			PanicIfError(err)
+		}
+		if config.Azure.AccountKey == "" {
+			// The default chain of the azure extension, like the one the storage writes with
+			query := "CREATE SECRET azure_secret (TYPE AZURE, PROVIDER CREDENTIAL_CHAIN, ACCOUNT_NAME '$account')"
+			_, err = duckdb.ExecContext(ctx, query, map[string]string{"account": config.Azure.Account})
+			PanicIfError(err)
+			break
+		}
+		connectionString := "AccountName=" + config.Azure.Account + ";AccountKey=" + config.Azure.AccountKey + ";"
+		if config.Azure.Endpoint != "" {
+			connectionString += "BlobEndpoint=" + config.Azure.Endpoint + ";"
+		} else {
+			connectionString += "DefaultEndpointsProtocol=https;EndpointSuffix=core.windows.net;"
+		}
+		_, err = duckdb.ExecContext(ctx, "CREATE SECRET azure_secret (TYPE AZURE, CONNECTION_STRING '$connectionString')", map[string]string{"connectionString": connectionString})
+		PanicIfError(err)
+	}
+
+	return duckdb
+}
+
+// Recreates the S3 secrets if the credentials changed, e.g. when the assumed role credentials were about to expire
+func (duckdb *Duckdb) refreshS3Secrets(ctx context.Context) error {
+	if duckdb.awsCredentials == nil {
+		return nil
+	}
+
+	awsCredentials, err := duckdb.awsCredentials.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+
+	duckdb.awsCredentialsMutex.Lock()
+	defer duckdb.awsCredentialsMutex.Unlock()
+	if awsCredentials.AccessKeyID == duckdb.awsAccessKeyId {
+		return nil
+	}
+
+	query := "CREATE OR REPLACE SECRET $name (TYPE S3, KEY_ID '$accessKeyId', SECRET '$secretAccessKey', SESSION_TOKEN '$sessionToken', REGION '$region', ENDPOINT '$endpoint', USE_SSL $useSsl, URL_STYLE '$urlStyle', SCOPE '$s3Bucket')"
+	for _, secretArgs := range duckdb.s3SecretArgs(awsCredentials) {
+		_, err = duckdb.ExecContext(ctx, query, secretArgs)
+		if err != nil {
+			return err
+		}
+	}
+	duckdb.awsAccessKeyId = awsCredentials.AccessKeyID
+
+	return nil
+}
+
+// One secret per bucket scoped to it, so DuckDB reads the tables of each bucket in its region, also when they're joined
+func (duckdb *Duckdb) s3SecretArgs(awsCredentials aws.Credentials) []map[string]string {
+	awsS3Buckets := []AwsS3Bucket{{Name: duckdb.config.Aws.S3Bucket, Region: duckdb.config.Aws.Region}}
+	for _, awsS3Bucket := range duckdb.config.Aws.S3TableBuckets {
+		if !slices.Contains(awsS3Buckets, awsS3Bucket) {
+			awsS3Buckets = append(awsS3Buckets, awsS3Bucket)
+		}
+	}
+	slices.SortFunc(awsS3Buckets[1:], func(a, b AwsS3Bucket) int {
+		return strings.Compare(a.Name+":"+a.Region, b.Name+":"+b.Region)
+	})
+
+	// DuckDB takes the endpoint without a scheme, e.g. localhost:9000 with USE_SSL false for http://localhost:9000
+	endpointUrl := parseAwsS3Endpoint(duckdb.config.Aws.S3Endpoint)
+	urlStyle := "vhost"
+	if duckdb.config.Aws.S3ForcePathStyle {
+		urlStyle = "path"
+	}
+
+	var secretArgs []map[string]string
+	for i, awsS3Bucket := range awsS3Buckets {
+		name := "aws_s3_secret"
+		if i > 0 {
+			name += "_" + IntToString(i)
+		}
+		secretArgs = append(secretArgs, map[string]string{
+			"name":            name,
+			"accessKeyId":     awsCredentials.AccessKeyID,
+			"secretAccessKey": awsCredentials.SecretAccessKey,
+			"sessionToken":    awsCredentials.SessionToken,
+			"region":          awsS3Bucket.Region,
+			"endpoint":        endpointUrl.Host,
+			"useSsl":          strconv.FormatBool(endpointUrl.Scheme == "https"),
+			"urlStyle":        urlStyle,
+			"s3Bucket":        "s3://" + awsS3Bucket.Name,
+		})
+	}
+
+	return secretArgs
+}
+
+func (duckdb *Duckdb) ExecContext(ctx context.Context, query string, args map[string]string) (sql.Result, error) {
+	LogDebug(duckdb.config, "Querying DuckDB:", query, args)
+	return duckdb.db.ExecContext(ctx, replaceNamedStringArgs(query, args))
+}
+
+func (duckdb *Duckdb) QueryContext(ctx context.Context, query string) (*sql.Rows, error) {
+	if err := duckdb.refreshS3Secrets(ctx); err != nil {
+		return nil, err
+	}
+	LogDebug(duckdb.config, "Querying DuckDB:", query)
+	return duckdb.db.QueryContext(ctx, query)
+}
+
+// Runs the query on a dedicated connection with the settings applied. Call release after closing the rows to restore the previous values
+func (duckdb *Duckdb) QueryContextWithSettings(ctx context.Context, query string, settings []DuckdbSetting) (rows *sql.Rows, release func(), err error) {
+	if len(settings) == 0 {
+		rows, err = duckdb.QueryContext(ctx, query)
+		return rows, func() {}, err
+	}
+
+	err = duckdb.refreshS3Secrets(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Global settings like threads apply to all connections, so queries changing them run one at a time
+	// and the previous values are restored instead of resetting them to the defaults
+	globalSettings := slices.ContainsFunc(settings, func(setting DuckdbSetting) bool {
+		return DUCKDB_GLOBAL_SETTINGS.Contains(setting.Name)
+	})
+	if globalSettings {
+		duckdb.globalSettingsMutex.Lock()
+	}
+	conn, err := duckdb.db.Conn(ctx)
+	if err != nil {
+		if globalSettings {
+			duckdb.globalSettingsMutex.Unlock()
+		}
+		return nil, nil, err
+	}
+
+	var restoreQueries []string
+	release = func() {
+		for i := len(restoreQueries) - 1; i >= 0; i-- {
+			LogDebug(duckdb.config, "Querying DuckDB:", restoreQueries[i])
+			_, err := conn.ExecContext(context.Background(), restoreQueries[i])
+			if err != nil {
+				LogError(duckdb.config, "Couldn't restore DuckDB setting:", err)
+			}
+		}
+		conn.Close()
+		if globalSettings {
+			duckdb.globalSettingsMutex.Unlock()
+		}
+	}
+
+	for _, setting := range settings {
+		var previousValue sql.NullString
+		err = conn.QueryRowContext(ctx, "SELECT current_setting('"+setting.Name+"')::VARCHAR").Scan(&previousValue)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+
+		setQuery := "SET " + setting.Name + " = " + setting.Value
+		LogDebug(duckdb.config, "Querying DuckDB:", setQuery)
+		_, err = conn.ExecContext(ctx, setQuery)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+
+		if previousValue.Valid {
+			restoreQueries = append(restoreQueries, "SET "+setting.Name+" = '"+strings.ReplaceAll(previousValue.String, "'", "''")+"'")
+		} else {
+			restoreQueries = append(restoreQueries, "RESET "+setting.Name)
+		}
+	}
+
+	LogDebug(duckdb.config, "Querying DuckDB:", query)
+	rows, err = conn.QueryContext(ctx, query)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return rows, release, nil
+}
+
+func (duckdb *Duckdb) SupportsTimeZones() bool {
+	duckdb.supportsTimeZonesOnce.Do(func() {
+		_, err := duckdb.db.ExecContext(context.Background(), "SELECT current_setting('TimeZone')")
+		if err != nil {
+			LogWarn(duckdb.config, "Couldn't load DuckDB time zones, date functions on timestamptz values use UTC:", err)
+		}
+		duckdb.supportsTimeZones = err == nil
+	})
+	return duckdb.supportsTimeZones
+}
+
+func (duckdb *Duckdb) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if err := duckdb.refreshS3Secrets(ctx); err != nil {
+		return nil, err
+	}
+	LogDebug(duckdb.config, "Preparing DuckDB statement:", query)
+	return duckdb.db.PrepareContext(ctx, query)
+}
+
+func (duckdb *Duckdb) Close() {
+	duckdb.db.Close()
+}
+
+// clock_timestamp() advances within a query, so it is a volatile function evaluated for each row
+func (duckdb *Duckdb) registerClockTimestampFunction(ctx context.Context) {
+	conn, err := duckdb.db.Conn(ctx)
+	PanicIfError(err)
+	defer conn.Close()
+
+	err = duckDb.RegisterScalarUDF(conn, "clock_timestamp", &DuckdbClockTimestampFunction{})
+	PanicIfError(err)
+}
+
+type DuckdbClockTimestampFunction struct{}
+
+func (function *DuckdbClockTimestampFunction) Config() duckDb.ScalarFuncConfig {
+	resultTypeInfo, err := duckDb.NewTypeInfo(duckDb.TYPE_TIMESTAMP_TZ)
+	PanicIfError(err)
+
+	return duckDb.ScalarFuncConfig{ResultTypeInfo: resultTypeInfo, Volatile: true}
+}
+
+func (function *DuckdbClockTimestampFunction) Executor() duckDb.ScalarFuncExecutor {
+	return duckDb.ScalarFuncExecutor{
+		RowExecutor: func(values []driver.Value) (any, error) {
+			return time.Now(), nil
+		},
+	}
+}
+
+// xml columns are stored as text, so xpath() and xpath_exists() evaluate a subset of XPath on it, see EvaluateXPath
+func (duckdb *Duckdb) registerXPathFunctions(ctx context.Context) {
+	conn, err := duckdb.db.Conn(ctx)
+	PanicIfError(err)
+	defer conn.Close()
+
+	err = duckDb.RegisterScalarUDF(conn, "xpath", &DuckdbXPathFunction{})
+	PanicIfError(err)
+	err = duckDb.RegisterScalarUDF(conn, "xpath_exists", &DuckdbXPathFunction{exists: true})
+	PanicIfError(err)
+}
+
+// xpath(path, xml) -> text[], xpath_exists(path, xml) -> bool
+type DuckdbXPathFunction struct {
+	exists bool
+}
+
+func (function *DuckdbXPathFunction) Config() duckDb.ScalarFuncConfig {
+	varcharTypeInfo, err := duckDb.NewTypeInfo(duckDb.TYPE_VARCHAR)
+	PanicIfError(err)
+
+	resultTypeInfo, err := duckDb.NewListInfo(varcharTypeInfo)
+	PanicIfError(err)
+	if function.exists {
+		resultTypeInfo, err = duckDb.NewTypeInfo(duckDb.TYPE_BOOLEAN)
+		PanicIfError(err)
+	}
+
+	return duckDb.ScalarFuncConfig{InputTypeInfos: []duckDb.TypeInfo{varcharTypeInfo, varcharTypeInfo}, ResultTypeInfo: resultTypeInfo}
+}
+
+func (function *DuckdbXPathFunction) Executor() duckDb.ScalarFuncExecutor {
+	return duckDb.ScalarFuncExecutor{
+		RowExecutor: func(values []driver.Value) (any, error) {
+			matches, err := EvaluateXPath(values[0].(string), values[1].(string))
+			if err != nil {
+				return nil, err
+			}
+			if function.exists {
+				return len(matches) > 0, nil
+			}
+			if matches == nil {
+				return []string{}, nil
+			}
+			return matches, nil
+		},
+	}
+}
+
+// pg_trgm's similarity(), also used by the remapped % and <-> operators
+func (duckdb *Duckdb) registerSimilarityFunction(ctx context.Context) {
+	conn, err := duckdb.db.Conn(ctx)
+	PanicIfError(err)
+	defer conn.Close()
+
+	err = duckDb.RegisterScalarUDF(conn, PG_FUNCTION_SIMILARITY, &DuckdbSimilarityFunction{})
+	PanicIfError(err)
+}
+
+// similarity(a, b) -> real, see TrigramSimilarity
+type DuckdbSimilarityFunction struct{}
+
+func (function *DuckdbSimilarityFunction) Config() duckDb.ScalarFuncConfig {
+	varcharTypeInfo, err := duckDb.NewTypeInfo(duckDb.TYPE_VARCHAR)
+	PanicIfError(err)
+	resultTypeInfo, err := duckDb.NewTypeInfo(duckDb.TYPE_FLOAT)
+	PanicIfError(err)
+
+	return duckDb.ScalarFuncConfig{InputTypeInfos: []duckDb.TypeInfo{varcharTypeInfo, varcharTypeInfo}, ResultTypeInfo: resultTypeInfo}
+}
+
+func (function *DuckdbSimilarityFunction) Executor() duckDb.ScalarFuncExecutor {
+	return duckDb.ScalarFuncExecutor{
+		RowExecutor: func(values []driver.Value) (any, error) {
+			return TrigramSimilarity(values[0].(string), values[1].(string)), nil
+		},
+	}
+}
+
+func replaceNamedStringArgs(query string, args map[string]string) string {
+	re := regexp.MustCompile(`['";]`) // Escape single quotes, double quotes, and semicolons from args
+
+	for key, value := range args {
+		query = strings.ReplaceAll(query, "$"+key, re.ReplaceAllString(value, ""))
+	}
+	return query
+}
+
+func readDuckdbInitFile(config *Config) []string {
+	_, err := os.Stat(config.InitSqlFilepath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			LogDebug(config, "DuckDB: No init file found at", config.InitSqlFilepath)
+			return nil
+		}
+		PanicIfError(err)
+	}
+
+	LogInfo(config, "DuckDB: Reading init file", config.InitSqlFilepath)
+	file, err := os.Open(config.InitSqlFilepath)
+	PanicIfError(err)
+	defer file.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	PanicIfError(scanner.Err())
+	return lines
+}
+
+// DuckDB's json_contains() also matches values nested at any depth, so the jsonb @> and <@ operators use JsonbContains instead
+func (duckdb *Duckdb) registerJsonbContainsFunction(ctx context.Context) {
+	conn, err := duckdb.db.Conn(ctx)
+	PanicIfError(err)
+	defer conn.Close()
+
+	err = duckDb.RegisterScalarUDF(conn, BEMIDB_FUNCTION_JSONB_CONTAINS, &DuckdbJsonbContainsFunction{})
+	PanicIfError(err)
+}
+
+// bemidb_jsonb_contains(value, template) -> bool
+type DuckdbJsonbContainsFunction struct{}
+
+func (function *DuckdbJsonbContainsFunction) Config() duckDb.ScalarFuncConfig {
+	varcharTypeInfo, err := duckDb.NewTypeInfo(duckDb.TYPE_VARCHAR)
+	PanicIfError(err)
+	resultTypeInfo, err := duckDb.NewTypeInfo(duckDb.TYPE_BOOLEAN)
+	PanicIfError(err)
+
+	return duckDb.ScalarFuncConfig{InputTypeInfos: []duckDb.TypeInfo{varcharTypeInfo, varcharTypeInfo}, ResultTypeInfo: resultTypeInfo}
+}
+
+func (function *DuckdbJsonbContainsFunction) Executor() duckDb.ScalarFuncExecutor {
+	return duckDb.ScalarFuncExecutor{
+		RowExecutor: func(values []driver.Value) (any, error) {
+			return JsonbContains(values[0].(string), values[1].(string))
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package bemidb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestNewDuckdb(t *testing.T) {
+	t.Run("Creates a new DuckDB instance", func(t *testing.T) {
+		config := loadTestConfig()
+
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+
+		rows, err := duckdb.QueryContext(context.Background(), "SELECT 1")
+		if err != nil {
+			t.Errorf("Expected query to succeed")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var result int
+			err = rows.Scan(&result)
+			if err != nil {
+				t.Errorf("Expected query to return a result")
+			}
+			if result != 1 {
+				t.Errorf("Expected query result to be 1, got %d", result)
+			}
+		}
+	})
+	t.Run("Spills a memory-limited query to the temp directory of an on-disk database", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Duckdb.DatabasePath = filepath.Join(t.TempDir(), "bemidb.duckdb")
+		config.Duckdb.TempDirectory = filepath.Join(t.TempDir(), "spill")
+		config.Duckdb.MemoryLimit = "32MB"
+
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+
+		rows, err := duckdb.QueryContext(context.Background(), "SELECT COUNT(*) FROM (SELECT i, md5(i::VARCHAR) AS hash FROM range(2000000) t(i) ORDER BY hash)")
+		testNoError(t, err)
+		defer rows.Close()
+		var count int
+		rows.Next()
+		testNoError(t, rows.Scan(&count))
+		if count != 2000000 {
+			t.Errorf("Expected the query to return 2000000, got %d", count)
+		}
+		if _, err := os.Stat(config.Duckdb.TempDirectory); err != nil {
+			t.Errorf("Expected the query to spill to the temp directory, got %v", err)
+		}
+		if _, err := os.Stat(config.Duckdb.DatabasePath); err != nil {
+			t.Errorf("Expected the database file to be created, got %v", err)
+		}
+	})
+	t.Run("Reopens an on-disk database", func(t *testing.T) {
+		config := loadTestConfig()
+		config.InitSqlFilepath = filepath.Join(t.TempDir(), "init.sql")
+		err := os.WriteFile(config.InitSqlFilepath, []byte("CREATE SCHEMA IF NOT EXISTS public\nUSE public\n"), 0644)
+		testNoError(t, err)
+		config.Duckdb.DatabasePath = filepath.Join(t.TempDir(), "bemidb.duckdb")
+		NewDuckdb(config).Close()
+
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+
+		rows, err := duckdb.QueryContext(context.Background(), "SELECT bemidb_to_hex(255)")
+		testNoError(t, err)
+		defer rows.Close()
+		var result string
+		rows.Next()
+		testNoError(t, rows.Scan(&result))
+		if result != "ff" {
+			t.Errorf("Expected the macros to be recreated, got %s", result)
+		}
+	})
+	t.Run("Runs queries of concurrent sessions on an on-disk database", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Duckdb.DatabasePath = filepath.Join(t.TempDir(), "bemidb.duckdb")
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+
+		var waitGroup sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				rows, err := duckdb.QueryContext(context.Background(), "SELECT SUM(i) FROM range(100000) t(i)")
+				if err == nil {
+					err = rows.Close()
+				}
+				errs[i] = err
+			}()
+		}
+		waitGroup.Wait()
+
+		for _, err := range errs {
+			testNoError(t, err)
+		}
+	})
+	t.Run("Creates an S3 secret scoped to each bucket", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{
+			Region:     "us-west-1",
+			S3Endpoint: "s3.amazonaws.com",
+			S3Bucket:   "hot-bucket",
+			S3TableBuckets: map[string]AwsS3Bucket{
+				"public.events": {Name: "cold-bucket", Region: "eu-west-1"},
+				"public.logs":   {Name: "cold-bucket", Region: "eu-west-1"},
+			},
+		}}
+		duckdb := &Duckdb{config: config}
+
+		secretArgs := duckdb.s3SecretArgs(aws.Credentials{AccessKeyID: "access-key-id", SecretAccessKey: "secret-access-key"})
+
+		if len(secretArgs) != 2 {
+			t.Fatalf("Expected 2 secrets, got %v", len(secretArgs))
+		}
+		if secretArgs[0]["name"] != "aws_s3_secret" || secretArgs[0]["s3Bucket"] != "s3://hot-bucket" || secretArgs[0]["region"] != "us-west-1" {
+			t.Errorf("Unexpected default bucket secret: %v", secretArgs[0])
+		}
+		if secretArgs[1]["name"] != "aws_s3_secret_1" || secretArgs[1]["s3Bucket"] != "s3://cold-bucket" || secretArgs[1]["region"] != "eu-west-1" {
+			t.Errorf("Unexpected table bucket secret: %v", secretArgs[1])
+		}
+		if secretArgs[0]["endpoint"] != "s3.amazonaws.com" || secretArgs[0]["useSsl"] != "true" || secretArgs[0]["urlStyle"] != "vhost" {
+			t.Errorf("Unexpected endpoint of the default bucket secret: %v", secretArgs[0])
+		}
+	})
+	t.Run("Creates an S3 secret with the session token of an assumed role", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{Region: "us-west-1", S3Endpoint: "s3.amazonaws.com", S3Bucket: "data-lake"}}
+		duckdb := &Duckdb{config: config}
+
+		secretArgs := duckdb.s3SecretArgs(aws.Credentials{AccessKeyID: "ASIA123", SecretAccessKey: "secret", SessionToken: "session-token"})
+
+		if secretArgs[0]["accessKeyId"] != "ASIA123" || secretArgs[0]["secretAccessKey"] != "secret" || secretArgs[0]["sessionToken"] != "session-token" {
+			t.Errorf("Unexpected assumed role secret: %v", secretArgs[0])
+		}
+	})
+	t.Run("Creates an S3 secret for a custom endpoint", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{
+			Region:           "us-east-1",
+			S3Endpoint:       "http://localhost:9000",
+			S3ForcePathStyle: true,
+			S3Bucket:         "bemidb",
+		}}
+		duckdb := &Duckdb{config: config}
+
+		secretArgs := duckdb.s3SecretArgs(aws.Credentials{AccessKeyID: "access-key-id", SecretAccessKey: "secret-access-key"})
+
+		if len(secretArgs) != 1 {
+			t.Fatalf("Expected 1 secret, got %v", len(secretArgs))
+		}
+		if secretArgs[0]["endpoint"] != "localhost:9000" || secretArgs[0]["useSsl"] != "false" || secretArgs[0]["urlStyle"] != "path" {
+			t.Errorf("Unexpected custom endpoint secret: %v", secretArgs[0])
+		}
+	})
+}
+
+func TestDuckdbQueryContextWithSettings(t *testing.T) {
+	t.Run("Restores global settings changed by concurrent queries", func(t *testing.T) {
+		config := loadTestConfig()
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+		ctx := context.Background()
+		var threads string
+		err := duckdb.db.QueryRowContext(ctx, "SELECT current_setting('threads')::VARCHAR").Scan(&threads)
+		testNoError(t, err)
+
+		var waitGroup sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				rows, release, err := duckdb.QueryContextWithSettings(ctx, "SELECT SUM(i) FROM range(100000) t(i)", []DuckdbSetting{{Name: "threads", Value: strconv.Itoa(i + 1)}})
+				if err == nil {
+					err = rows.Close()
+					release()
+				}
+				errs[i] = err
+			}()
+		}
+		waitGroup.Wait()
+
+		for _, err := range errs {
+			testNoError(t, err)
+		}
+		var restoredThreads string
+		err = duckdb.db.QueryRowContext(ctx, "SELECT current_setting('threads')::VARCHAR").Scan(&restoredThreads)
+		testNoError(t, err)
+		if restoredThreads != threads {
+			t.Errorf("Expected threads to be restored to %s, got %s", threads, restoredThreads)
+		}
+	})
+}
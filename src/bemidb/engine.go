@@ -0,0 +1,113 @@
+package bemidb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// Rows of a query with the names of their columns in order, which may repeat, e.g. SELECT 1 AS a, 2 AS a
+type Result struct {
+	Columns []string
+	Rows    []Row
+}
+
+// Values in the order of the columns, encoded as text like in the Postgres wire protocol, or nil for NULL
+type Row []interface{}
+
+// Engine runs queries through the same remapping and DuckDB pipeline as the Postgres server without listening on a port
+type Engine struct {
+	config       *Config
+	duckdb       *Duckdb
+	queryHandler *QueryHandler
+}
+
+func NewEngine(config *Config) *Engine {
+	duckdb := NewDuckdb(config)
+	icebergReader := NewIcebergReader(config)
+
+	return &Engine{
+		config:       config,
+		duckdb:       duckdb,
+		queryHandler: NewQueryHandler(config, duckdb, icebergReader),
+	}
+}
+
+// Returns the result of the last statement that returns rows
+func (engine *Engine) Query(ctx context.Context, sql string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	messages, err := engine.queryHandler.HandleQueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	messages, err = ReadMessageStreams(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Columns: []string{}, Rows: []Row{}}
+	for _, message := range messages {
+		switch message := message.(type) {
+		case *pgproto3.RowDescription:
+			result = &Result{Columns: []string{}, Rows: []Row{}}
+			for _, field := range message.Fields {
+				result.Columns = append(result.Columns, string(field.Name))
+			}
+		case *pgproto3.DataRow:
+			row := make(Row, len(message.Values))
+			for i, value := range message.Values {
+				if value != nil {
+					row[i] = string(value)
+				}
+			}
+			result.Rows = append(result.Rows, row)
+		}
+	}
+
+	return result, nil
+}
+
+// Returns a syncer that writes to the same storage the engine reads from
+func (engine *Engine) Syncer() *Syncer {
+	return NewSyncer(engine.config)
+}
+
+func (engine *Engine) Close() {
+	engine.duckdb.Close()
+}
+
+// Syncs from Postgres like SyncFromPostgres but returns an error instead of panicking
+func (syncer *Syncer) Sync(ctx context.Context) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Failed to sync from Postgres: %v", r)
+		}
+	}()
+
+	syncer.SyncFromPostgres()
+	return nil
+}
+
+// Syncs from a plain-format pg_dump file like SyncFromPgDump but returns an error instead of panicking
+func (syncer *Syncer) SyncPgDump(ctx context.Context, dumpPath string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Failed to sync from the pg_dump file: %v", r)
+		}
+	}()
+
+	syncer.SyncFromPgDump(dumpPath)
+	return nil
+}
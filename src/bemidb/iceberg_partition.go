@@ -0,0 +1,191 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Path value of the partition of NULL values
+	ICEBERG_PARTITION_NULL_VALUE = "null"
+	// Spec id of a new partitioned table, later syncs reuse a spec with the same fields or add one
+	ICEBERG_PARTITION_SPEC_ID = 0
+)
+
+// Routes the rows of a table to its partitions, e.g. by the day of a timestamp column
+type IcebergPartitioner struct {
+	config               *Config
+	icebergPartitionSpec IcebergPartitionSpec
+	// Columns of the partition fields, in field order
+	pgSchemaColumns []PgSchemaColumn
+	columnIndexes   []int
+}
+
+// Returns nil if the table isn't configured to be partitioned
+func NewIcebergPartitioner(config *Config, icebergSchemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn) (*IcebergPartitioner, error) {
+	partitionColumn, ok := config.IcebergPartitions[icebergSchemaTable.Schema+"."+icebergSchemaTable.Table]
+	if !ok {
+		return nil, nil
+	}
+
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		if pgSchemaColumn.ColumnName != partitionColumn.ColumnName {
+			continue
+		}
+
+		icebergPartitionField, err := icebergPartitionField(pgSchemaColumn, partitionColumn.Transform)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to partition %s: %v", icebergSchemaTable.String(), err)
+		}
+
+		return &IcebergPartitioner{
+			config:               config,
+			icebergPartitionSpec: IcebergPartitionSpec{SpecId: ICEBERG_PARTITION_SPEC_ID, Fields: []IcebergPartitionField{icebergPartitionField}},
+			pgSchemaColumns:      []PgSchemaColumn{pgSchemaColumn},
+			columnIndexes:        []int{i},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Failed to partition %s: column %s doesn't exist", icebergSchemaTable.String(), partitionColumn.ColumnName)
+}
+
+func (partitioner *IcebergPartitioner) Spec() IcebergPartitionSpec {
+	return partitioner.icebergPartitionSpec
+}
+
+// Returns the data subdirectory of the row's partition, e.g. "created_at_day=2025-01-01", and its Avro values for manifests
+func (partitioner *IcebergPartitioner) Partition(row []string) (path string, partition map[string]interface{}) {
+	var pathParts []string
+	partition = map[string]interface{}{}
+	for i, icebergPartitionField := range partitioner.icebergPartitionSpec.Fields {
+		avroValue, pathValue := partitioner.partitionValue(icebergPartitionField, &partitioner.pgSchemaColumns[i], row[partitioner.columnIndexes[i]])
+		pathParts = append(pathParts, icebergPartitionField.Name+"="+pathValue)
+		partition[icebergPartitionField.Name] = avroValue
+	}
+
+	return strings.Join(pathParts, "/"), partition
+}
+
+// Partition of a table without rows, so that its single empty data file still has partition values
+func (partitioner *IcebergPartitioner) NullPartition() (path string, partition map[string]interface{}) {
+	var pathParts []string
+	partition = map[string]interface{}{}
+	for _, icebergPartitionField := range partitioner.icebergPartitionSpec.Fields {
+		pathParts = append(pathParts, icebergPartitionField.Name+"="+ICEBERG_PARTITION_NULL_VALUE)
+		partition[icebergPartitionField.Name] = nil
+	}
+
+	return strings.Join(pathParts, "/"), partition
+}
+
+func (partitioner *IcebergPartitioner) partitionValue(icebergPartitionField IcebergPartitionField, pgSchemaColumn *PgSchemaColumn, value string) (avroValue interface{}, pathValue string) {
+	if value == PG_NULL_STRING || (partitioner.config.Pg.SyncInfiniteTimestamps == INFINITE_TIMESTAMPS_NULL && pgSchemaColumn.IsNullable == PG_TRUE && pgSchemaColumn.IsInfiniteValue(value)) {
+		return nil, ICEBERG_PARTITION_NULL_VALUE
+	}
+
+	parquetValue := pgSchemaColumn.FormatParquetValue(value)
+	if icebergPartitionField.Transform == ICEBERG_TRANSFORM_DAY || pgSchemaColumn.UdtName == "date" {
+		days := icebergPartitionDays(pgSchemaColumn, parquetValue.(int64))
+		return map[string]interface{}{"int": int32(days)}, time.Unix(days*86400, 0).UTC().Format("2006-01-02")
+	}
+
+	switch icebergPartitionField.ResultType {
+	case "int":
+		return map[string]interface{}{"int": parquetValue.(int32)}, fmt.Sprint(parquetValue)
+	case "long":
+		return map[string]interface{}{"long": parquetValue.(int64)}, fmt.Sprint(parquetValue)
+	case "boolean":
+		return map[string]interface{}{"boolean": parquetValue.(bool)}, fmt.Sprint(parquetValue)
+	default:
+		return map[string]interface{}{"string": parquetValue.(string)}, url.PathEscape(parquetValue.(string))
+	}
+}
+
+// Days since the epoch of a date or timestamp Parquet value, rounded down for times before the epoch
+func icebergPartitionDays(pgSchemaColumn *PgSchemaColumn, parquetValue int64) int64 {
+	if pgSchemaColumn.UdtName == "date" {
+		return parquetValue
+	}
+
+	unitsPerDay := int64(86400 * 1000)
+	if pgSchemaColumn.DatetimePrecision == "6" {
+		unitsPerDay *= 1000
+	}
+	days := parquetValue / unitsPerDay
+	if parquetValue%unitsPerDay < 0 {
+		days--
+	}
+	return days
+}
+
+func icebergPartitionField(pgSchemaColumn PgSchemaColumn, transform string) (IcebergPartitionField, error) {
+	sourceId, err := StringToInt(pgSchemaColumn.OrdinalPosition)
+	if err != nil {
+		return IcebergPartitionField{}, err
+	}
+	icebergPartitionField := IcebergPartitionField{
+		SourceId:  sourceId,
+		FieldId:   ICEBERG_FIRST_PARTITION_FIELD_ID,
+		Name:      pgSchemaColumn.ColumnName,
+		Transform: transform,
+	}
+
+	if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY {
+		return IcebergPartitionField{}, fmt.Errorf("array column %s can't be partitioned by", pgSchemaColumn.ColumnName)
+	}
+
+	switch transform {
+	case ICEBERG_TRANSFORM_DAY:
+		switch pgSchemaColumn.UdtName {
+		case "date", "timestamp", "timestamptz":
+			icebergPartitionField.Name += "_" + ICEBERG_TRANSFORM_DAY
+			icebergPartitionField.ResultType = "int"
+			return icebergPartitionField, nil
+		}
+	case ICEBERG_TRANSFORM_IDENTITY:
+		switch pgSchemaColumn.UdtName {
+		case "int2", "int4", "date":
+			icebergPartitionField.ResultType = "int"
+			return icebergPartitionField, nil
+		case "int8":
+			icebergPartitionField.ResultType = "long"
+			return icebergPartitionField, nil
+		case "bool":
+			icebergPartitionField.ResultType = "boolean"
+			return icebergPartitionField, nil
+		case "varchar", "char", "bpchar", "text":
+			icebergPartitionField.ResultType = "string"
+			return icebergPartitionField, nil
+		}
+	}
+
+	return IcebergPartitionField{}, fmt.Errorf("column %s of type %s can't be partitioned by %s", pgSchemaColumn.ColumnName, pgSchemaColumn.UdtName, transform)
+}
+
+// Manifest schema with the partition values of data files as a record with a field per partition field
+func icebergManifestSchema(icebergPartitionSpec IcebergPartitionSpec) string {
+	if len(icebergPartitionSpec.Fields) == 0 {
+		return MANIFEST_SCHEMA
+	}
+
+	var partitionFields []map[string]interface{}
+	for _, icebergPartitionField := range icebergPartitionSpec.Fields {
+		partitionFields = append(partitionFields, map[string]interface{}{
+			"name":     icebergPartitionField.Name,
+			"type":     []string{"null", icebergPartitionField.ResultType},
+			"default":  nil,
+			"field-id": icebergPartitionField.FieldId,
+		})
+	}
+	partitionRecordJson, err := json.Marshal(map[string]interface{}{"type": "record", "name": "r102", "fields": partitionFields})
+	PanicIfError(err)
+
+	return strings.Replace(MANIFEST_SCHEMA, `"name" : "record_count",`, `"name" : "partition",
+				"type" : `+string(partitionRecordJson)+`,
+				"field-id" : 102
+			}, {
+				"name" : "record_count",`, 1)
+}
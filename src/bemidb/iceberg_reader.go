@@ -0,0 +1,161 @@
+package bemidb
+
+import (
+	"fmt"
+	"time"
+)
+
+type IcebergReader struct {
+	config  *Config
+	storage Storage
+	// Set for the Glue and REST catalog types, which resolve the tables instead of the storage
+	catalog Catalog
+}
+
+func NewIcebergReader(config *Config) *IcebergReader {
+	storage := NewStorage(config)
+	return &IcebergReader{config: config, storage: storage, catalog: NewCatalog(config)}
+}
+
+func (reader *IcebergReader) Schemas() (icebergSchemas []string, err error) {
+	LogDebug(reader.config, "Reading Iceberg schemas...")
+	if reader.catalog != nil {
+		return reader.catalog.Schemas()
+	}
+	return reader.storage.IcebergSchemas()
+}
+
+func (reader *IcebergReader) SchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	LogDebug(reader.config, "Reading Iceberg tables...")
+	if reader.catalog != nil {
+		return reader.catalog.SchemaTables()
+	}
+	return reader.storage.IcebergSchemaTables()
+}
+
+func (reader *IcebergReader) MetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+	if reader.catalog != nil {
+		metadataFilePath, err := reader.catalog.MetadataFilePath(icebergSchemaTable)
+		if err == nil {
+			return metadataFilePath
+		}
+		LogWarn(reader.config, "Couldn't read the metadata location from the catalog:", err)
+	}
+	return reader.storage.IcebergMetadataFilePath(icebergSchemaTable)
+}
+
+// Whether DuckDB reads tables through local copies of their files instead of directly from the storage
+func (reader *IcebergReader) ReadsLocalCopies() bool {
+	if reader.catalog != nil {
+		// Tables in a catalog can be stored outside the storage path, so they're always read directly
+		return false
+	}
+	switch reader.config.StorageReadFallback {
+	case STORAGE_READ_FALLBACK_ALWAYS:
+		return true
+	case STORAGE_READ_FALLBACK_AUTO:
+		return !reader.storage.DirectReadsSupported()
+	}
+	return false
+}
+
+func (reader *IcebergReader) DownloadSnapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, localDirPath string) (localMetadataFilePath string, err error) {
+	LogDebug(reader.config, "Downloading Iceberg table", icebergSchemaTable.String(), "to", localDirPath+"...")
+	return reader.storage.DownloadIcebergSnapshot(icebergSchemaTable, snapshotId, localDirPath)
+}
+
+func (reader *IcebergReader) TableStats(icebergSchemaTable IcebergSchemaTable) (icebergTableStats IcebergTableStats, err error) {
+	return reader.storage.IcebergTableStats(icebergSchemaTable)
+}
+
+func (reader *IcebergReader) Refs(icebergSchemaTable IcebergSchemaTable) (icebergRefs map[string]IcebergRef, err error) {
+	return reader.storage.IcebergRefs(icebergSchemaTable)
+}
+
+func (reader *IcebergReader) RefSnapshotId(icebergSchemaTable IcebergSchemaTable, ref string) (snapshotId int64, err error) {
+	icebergRefs, err := reader.storage.IcebergRefs(icebergSchemaTable)
+	if err != nil {
+		return 0, err
+	}
+
+	icebergRef, ok := icebergRefs[ref]
+	if !ok {
+		return 0, fmt.Errorf("Failed to find ref %s in %s", ref, icebergSchemaTable.String())
+	}
+
+	return icebergRef.SnapshotId, nil
+}
+
+func (reader *IcebergReader) SnapshotLog(icebergSchemaTable IcebergSchemaTable) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error) {
+	return reader.storage.IcebergSnapshotLog(icebergSchemaTable)
+}
+
+// Returns whether the table has a snapshot with the id, including the ones only retained by tags and branches
+func (reader *IcebergReader) SnapshotIdExists(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (bool, error) {
+	icebergSnapshots, err := reader.storage.IcebergSnapshots(icebergSchemaTable)
+	if err != nil {
+		return false, err
+	}
+
+	for _, icebergSnapshot := range icebergSnapshots {
+		if icebergSnapshot.SnapshotId == snapshotId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Returns all snapshots of the table, including the ones only retained by tags and branches
+func (reader *IcebergReader) Snapshots(icebergSchemaTable IcebergSchemaTable) (icebergSnapshots []IcebergSnapshot, err error) {
+	return reader.storage.IcebergSnapshots(icebergSchemaTable)
+}
+
+// Returns the data files of the current snapshot
+func (reader *IcebergReader) DataFiles(icebergSchemaTable IcebergSchemaTable) (icebergDataFiles []IcebergDataFile, err error) {
+	return reader.storage.IcebergDataFiles(icebergSchemaTable)
+}
+
+// Returns the names of the primary key columns captured during sync, in key order
+func (reader *IcebergReader) PrimaryKey(icebergSchemaTable IcebergSchemaTable) (columnNames []string, err error) {
+	return reader.storage.IcebergPrimaryKey(icebergSchemaTable)
+}
+
+// Returns the columns of the snapshot, or of the current schema if the snapshot id is 0
+func (reader *IcebergReader) SchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error) {
+	return reader.storage.IcebergSchemaFields(icebergSchemaTable, snapshotId)
+}
+
+// Returns the partition specs of the files in the table, which differ if the table was partitioned differently over time
+func (reader *IcebergReader) PartitionSpecs(icebergSchemaTable IcebergSchemaTable) (icebergPartitionSpecs []IcebergPartitionSpec, err error) {
+	return reader.storage.IcebergPartitionSpecs(icebergSchemaTable)
+}
+
+// Returns the snapshot that was current on the main branch at the given time
+func (reader *IcebergReader) SnapshotIdAt(icebergSchemaTable IcebergSchemaTable, snapshotTime time.Time) (snapshotId int64, err error) {
+	icebergSnapshotLog, err := reader.storage.IcebergSnapshotLog(icebergSchemaTable)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, icebergSnapshotLogEntry := range icebergSnapshotLog {
+		if icebergSnapshotLogEntry.TimestampMs > snapshotTime.UnixMilli() {
+			break
+		}
+		snapshotId = icebergSnapshotLogEntry.SnapshotId
+	}
+
+	if snapshotId == 0 && len(icebergSnapshotLog) > 0 {
+		oldestSnapshotTime := time.UnixMilli(icebergSnapshotLog[0].TimestampMs).UTC()
+		return 0, fmt.Errorf("Failed to find a snapshot of %s at or before %s, the oldest one is from %s", icebergSchemaTable.String(), snapshotTime.Format(time.RFC3339Nano), oldestSnapshotTime.Format(time.RFC3339Nano))
+	}
+	if snapshotId == 0 {
+		return 0, fmt.Errorf("Failed to find a snapshot of %s at or before %s", icebergSchemaTable.String(), snapshotTime.Format(time.RFC3339Nano))
+	}
+
+	return snapshotId, nil
+}
+
+// Returns an empty path if there are no audit records yet
+func (reader *IcebergReader) AuditRecordsPath() (path string, err error) {
+	return reader.storage.AuditRecordsPath()
+}
@@ -0,0 +1,315 @@
+package bemidb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/xitongsys/parquet-go/parquet"
+)
+
+const (
+	PUFFIN_MAGIC                    = "PFA1"
+	PUFFIN_FOOTER_STRUCT_SIZE       = 12 // payload size, flags and magic after the payload
+	PUFFIN_BLOB_TYPE_THETA_SKETCH   = "apache-datasketches-theta-v1"
+	PUFFIN_BLOB_PROPERTY_NDV        = "ndv"
+	PUFFIN_FILE_PROPERTY_CREATED_BY = "created-by"
+
+	// Characters kept in string bounds of manifest entries, like Iceberg's default truncate(16) metrics mode
+	ICEBERG_STRING_BOUND_LENGTH = 16
+)
+
+type PuffinBlobMetadata struct {
+	Type           string            `json:"type"`
+	Fields         []int             `json:"fields"`
+	SnapshotId     int64             `json:"snapshot-id"`
+	SequenceNumber int64             `json:"sequence-number"`
+	Offset         int64             `json:"offset"`
+	Length         int64             `json:"length"`
+	Properties     map[string]string `json:"properties,omitempty"`
+}
+
+type PuffinFileMetadata struct {
+	Blobs      []PuffinBlobMetadata `json:"blobs"`
+	Properties map[string]string    `json:"properties,omitempty"`
+}
+
+// Theta sketches of the distinct values of each column, fed with the rows while they are written to Parquet
+type IcebergColumnSketches struct {
+	pgSchemaColumns []PgSchemaColumn
+	sketches        []*ThetaSketch // nil for columns without a single-value serialization, e.g. arrays
+}
+
+func NewIcebergColumnSketches(pgSchemaColumns []PgSchemaColumn) *IcebergColumnSketches {
+	sketches := make([]*ThetaSketch, len(pgSchemaColumns))
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		if pgSchemaColumn.DataType != PG_DATA_TYPE_ARRAY {
+			sketches[i] = NewThetaSketch()
+		}
+	}
+
+	return &IcebergColumnSketches{pgSchemaColumns: pgSchemaColumns, sketches: sketches}
+}
+
+// Wraps loadRows to update the sketches with every loaded row
+func (columnSketches *IcebergColumnSketches) ObserveRows(loadRows func() [][]string) func() [][]string {
+	return func() [][]string {
+		rows := loadRows()
+		for _, row := range rows {
+			for i, value := range row {
+				if columnSketches.sketches[i] == nil || value == PG_NULL_STRING {
+					continue
+				}
+				columnSketches.sketches[i].Update(icebergSingleValueBytes(&columnSketches.pgSchemaColumns[i], value))
+			}
+		}
+		return rows
+	}
+}
+
+// Writes a Puffin file with a theta sketch blob per column and returns the metadata of the blobs
+func (columnSketches *IcebergColumnSketches) WritePuffin(buffer *bytes.Buffer, snapshotId int64, sequenceNumber int64) (puffinFileMetadata PuffinFileMetadata, footerSize int64, err error) {
+	buffer.WriteString(PUFFIN_MAGIC)
+
+	puffinFileMetadata = PuffinFileMetadata{
+		Blobs:      []PuffinBlobMetadata{},
+		Properties: map[string]string{PUFFIN_FILE_PROPERTY_CREATED_BY: "BemiDB " + VERSION},
+	}
+	for i, sketch := range columnSketches.sketches {
+		if sketch == nil {
+			continue
+		}
+
+		fieldId, err := StringToInt(columnSketches.pgSchemaColumns[i].OrdinalPosition)
+		if err != nil {
+			return PuffinFileMetadata{}, 0, fmt.Errorf("Failed to parse field id: %v", err)
+		}
+		blob := sketch.CompactBytes()
+		puffinFileMetadata.Blobs = append(puffinFileMetadata.Blobs, PuffinBlobMetadata{
+			Type:           PUFFIN_BLOB_TYPE_THETA_SKETCH,
+			Fields:         []int{fieldId},
+			SnapshotId:     snapshotId,
+			SequenceNumber: sequenceNumber,
+			Offset:         int64(buffer.Len()),
+			Length:         int64(len(blob)),
+			Properties:     map[string]string{PUFFIN_BLOB_PROPERTY_NDV: strconv.FormatInt(int64(math.Round(sketch.Estimate())), 10)},
+		})
+		buffer.Write(blob)
+	}
+
+	footerPayload, err := json.Marshal(puffinFileMetadata)
+	if err != nil {
+		return PuffinFileMetadata{}, 0, fmt.Errorf("Failed to encode Puffin footer: %v", err)
+	}
+
+	footerStart := buffer.Len()
+	buffer.WriteString(PUFFIN_MAGIC)
+	buffer.Write(footerPayload)
+	buffer.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footerPayload))))
+	buffer.Write([]byte{0, 0, 0, 0}) // flags, the payload isn't compressed
+	buffer.WriteString(PUFFIN_MAGIC)
+
+	return puffinFileMetadata, int64(buffer.Len() - footerStart), nil
+}
+
+// Iceberg single-value serialization, which other engines use to build comparable sketches
+func icebergSingleValueBytes(pgSchemaColumn *PgSchemaColumn, value string) []byte {
+	icebergType := pgSchemaColumn.icebergPrimitiveType()
+
+	switch {
+	case icebergType == "uuid":
+		parsedUuid, err := uuid.Parse(value)
+		if err != nil {
+			return []byte(value)
+		}
+		return parsedUuid[:]
+	case icebergType == "float":
+		floatValue, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return []byte(value)
+		}
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(floatValue)))
+	case strings.HasPrefix(icebergType, "decimal"):
+		return icebergDecimalBytes(value, pgSchemaColumn.NumericScale)
+	}
+
+	switch parquetValue := pgSchemaColumn.FormatParquetValue(value).(type) {
+	case int32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(parquetValue))
+	case uint64: // xid and xid8
+		if icebergType == "int" {
+			return binary.LittleEndian.AppendUint32(nil, uint32(parquetValue))
+		}
+		return binary.LittleEndian.AppendUint64(nil, parquetValue)
+	case int64:
+		switch icebergType {
+		case "date":
+			return binary.LittleEndian.AppendUint32(nil, uint32(parquetValue))
+		case "timestamp", "time":
+			if pgSchemaColumn.DatetimePrecision != "6" {
+				parquetValue *= 1000 // milliseconds to microseconds
+			}
+		}
+		return binary.LittleEndian.AppendUint64(nil, uint64(parquetValue))
+	case bool:
+		if parquetValue {
+			return []byte{1}
+		}
+		return []byte{0}
+	case string:
+		return []byte(parquetValue)
+	}
+
+	return []byte(value)
+}
+
+// Unscaled value as a big-endian two's complement with the minimum number of bytes
+func icebergDecimalBytes(value string, numericScale string) []byte {
+	scale, err := StringToInt(numericScale)
+	if err != nil {
+		return []byte(value)
+	}
+
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return []byte(value) // NaN and infinity
+	}
+	unscaled := new(big.Rat).Mul(rat, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	unscaledInt := new(big.Int).Quo(unscaled.Num(), unscaled.Denom())
+
+	if unscaledInt.Sign() >= 0 {
+		unscaledBytes := unscaledInt.Bytes()
+		if len(unscaledBytes) == 0 || unscaledBytes[0]&0x80 != 0 {
+			unscaledBytes = append([]byte{0}, unscaledBytes...)
+		}
+		return unscaledBytes
+	}
+
+	// Two's complement of a negative value: 2^(8*n) + value for the smallest n that keeps the sign bit
+	byteCount := (new(big.Int).Not(unscaledInt).BitLen())/8 + 1
+	twosComplement := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(8*byteCount)), unscaledInt)
+	return twosComplement.FillBytes(make([]byte, byteCount))
+}
+
+// Value of a Parquet column chunk min or max statistic to aggregate into Iceberg bounds,
+// nil for types without bounds, e.g. floats with NaN, decimals, uuids stored as text, and unsigned integers
+func parquetStatsValue(schemaElement *parquet.SchemaElement, statsValue []byte) interface{} {
+	if schemaElement == nil || schemaElement.Type == nil || statsValue == nil {
+		return nil
+	}
+
+	switch *schemaElement.Type {
+	case parquet.Type_BOOLEAN:
+		if schemaElement.ConvertedType != nil || len(statsValue) != 1 {
+			return nil
+		}
+		return statsValue[0]&1 == 1
+	case parquet.Type_INT32:
+		if len(statsValue) != 4 {
+			return nil
+		}
+		value := int32(binary.LittleEndian.Uint32(statsValue))
+		switch {
+		case schemaElement.ConvertedType == nil || *schemaElement.ConvertedType == parquet.ConvertedType_DATE:
+			return value
+		case *schemaElement.ConvertedType == parquet.ConvertedType_TIME_MILLIS:
+			return int64(value) * 1000 // Iceberg times are in microseconds
+		}
+	case parquet.Type_INT64:
+		if len(statsValue) != 8 {
+			return nil
+		}
+		value := int64(binary.LittleEndian.Uint64(statsValue))
+		switch {
+		case schemaElement.ConvertedType == nil:
+			return value
+		case *schemaElement.ConvertedType == parquet.ConvertedType_TIMESTAMP_MICROS || *schemaElement.ConvertedType == parquet.ConvertedType_TIME_MICROS:
+			return value
+		case *schemaElement.ConvertedType == parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return value * 1000 // Iceberg timestamps are in microseconds
+		}
+	case parquet.Type_BYTE_ARRAY:
+		if schemaElement.ConvertedType != nil && *schemaElement.ConvertedType == parquet.ConvertedType_UTF8 && utf8.Valid(statsValue) {
+			return string(statsValue)
+		}
+	}
+
+	return nil
+}
+
+// Compares values returned by parquetStatsValue for the same column
+func parquetStatsValueLess(value interface{}, otherValue interface{}) bool {
+	switch value := value.(type) {
+	case bool:
+		return !value && otherValue.(bool)
+	case int32:
+		return value < otherValue.(int32)
+	case int64:
+		return value < otherValue.(int64)
+	case string:
+		return value < otherValue.(string)
+	}
+	return false
+}
+
+// Iceberg single-value serialization of a lower bound, strings are truncated
+func icebergLowerBoundBytes(value interface{}) []byte {
+	if stringValue, ok := value.(string); ok {
+		runes := []rune(stringValue)
+		if len(runes) > ICEBERG_STRING_BOUND_LENGTH {
+			return []byte(string(runes[:ICEBERG_STRING_BOUND_LENGTH]))
+		}
+	}
+	return icebergBoundBytes(value)
+}
+
+// Iceberg single-value serialization of an upper bound, nil if a truncated string can't be rounded up
+func icebergUpperBoundBytes(value interface{}) []byte {
+	stringValue, ok := value.(string)
+	if !ok {
+		return icebergBoundBytes(value)
+	}
+
+	runes := []rune(stringValue)
+	if len(runes) <= ICEBERG_STRING_BOUND_LENGTH {
+		return []byte(stringValue)
+	}
+
+	// "abc...xyz" truncated to "abc...xy" isn't an upper bound, "abc...xz" is
+	runes = runes[:ICEBERG_STRING_BOUND_LENGTH]
+	for i := len(runes) - 1; i >= 0; i-- {
+		nextRune := runes[i] + 1
+		if nextRune == 0xD800 {
+			nextRune = 0xE000 // skip UTF-16 surrogates that aren't valid in UTF-8
+		}
+		if nextRune <= utf8.MaxRune {
+			runes[i] = nextRune
+			return []byte(string(runes[:i+1]))
+		}
+	}
+	return nil
+}
+
+func icebergBoundBytes(value interface{}) []byte {
+	switch value := value.(type) {
+	case bool:
+		if value {
+			return []byte{1}
+		}
+		return []byte{0}
+	case int32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(value))
+	case int64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(value))
+	case string:
+		return []byte(value)
+	}
+	return nil
+}
@@ -0,0 +1,683 @@
+package bemidb
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Serializes commits to the same Iceberg table, e.g. a sync and a maintenance run
+var _icebergSchemaTableLocks sync.Map
+
+func LockIcebergSchemaTable(icebergSchemaTable IcebergSchemaTable) (unlock func()) {
+	lock, _ := _icebergSchemaTableLocks.LoadOrStore(icebergSchemaTable.String(), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+type IcebergWriter struct {
+	config  *Config
+	storage Storage
+	// Batches left out of the written tables, reported at the end of a sync
+	skippedParquetBatches      []SkippedParquetBatch
+	skippedParquetBatchesMutex sync.Mutex // tables are written by parallel sync workers
+}
+
+func NewIcebergWriter(config *Config) *IcebergWriter {
+	storage := NewStorage(config)
+	return &IcebergWriter{config: config, storage: storage}
+}
+
+const (
+	MANIFEST_SCHEMA = `{
+		"type" : "record",
+		"name" : "manifest_entry",
+		"fields" : [ {
+			"name" : "status",
+			"type" : "int",
+			"field-id" : 0
+		}, {
+			"name" : "snapshot_id",
+			"type" : [ "null", "long" ],
+			"default" : null,
+			"field-id" : 1
+		}, {
+			"name" : "sequence_number",
+			"type" : [ "null", "long" ],
+			"default" : null,
+			"field-id" : 3
+		}, {
+			"name" : "file_sequence_number",
+			"type" : [ "null", "long" ],
+			"default" : null,
+			"field-id" : 4
+		}, {
+			"name" : "data_file",
+			"type" : {
+			"type" : "record",
+			"name" : "r2",
+			"fields" : [ {
+				"name" : "content",
+				"type" : "int",
+				"doc" : "File format name: avro, orc, or parquet",
+				"field-id" : 134
+			}, {
+				"name" : "file_path",
+				"type" : "string",
+				"doc" : "Location URI with FS scheme",
+				"field-id" : 100
+			}, {
+				"name" : "file_format",
+				"type" : "string",
+				"doc" : "File format name: avro, orc, or parquet",
+				"field-id" : 101
+			}, {
+				"name" : "record_count",
+				"type" : "long",
+				"doc" : "Number of records in the file",
+				"field-id" : 103
+			}, {
+				"name" : "file_size_in_bytes",
+				"type" : "long",
+				"doc" : "Total file size in bytes",
+				"field-id" : 104
+			}, {
+				"name" : "column_sizes",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : {
+					"type" : "record",
+					"name" : "k117_v118",
+					"fields" : [ {
+					"name" : "key",
+					"type" : "int",
+					"field-id" : 117
+					}, {
+					"name" : "value",
+					"type" : "long",
+					"field-id" : 118
+					} ]
+				},
+				"logicalType" : "map"
+				} ],
+				"doc" : "Map of column id to total size on disk",
+				"default" : null,
+				"field-id" : 108
+			}, {
+				"name" : "value_counts",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : {
+					"type" : "record",
+					"name" : "k119_v120",
+					"fields" : [ {
+					"name" : "key",
+					"type" : "int",
+					"field-id" : 119
+					}, {
+					"name" : "value",
+					"type" : "long",
+					"field-id" : 120
+					} ]
+				},
+				"logicalType" : "map"
+				} ],
+				"doc" : "Map of column id to total count, including null and NaN",
+				"default" : null,
+				"field-id" : 109
+			}, {
+				"name" : "null_value_counts",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : {
+					"type" : "record",
+					"name" : "k121_v122",
+					"fields" : [ {
+					"name" : "key",
+					"type" : "int",
+					"field-id" : 121
+					}, {
+					"name" : "value",
+					"type" : "long",
+					"field-id" : 122
+					} ]
+				},
+				"logicalType" : "map"
+				} ],
+				"doc" : "Map of column id to null value count",
+				"default" : null,
+				"field-id" : 110
+			}, {
+				"name" : "nan_value_counts",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : {
+					"type" : "record",
+					"name" : "k138_v139",
+					"fields" : [ {
+					"name" : "key",
+					"type" : "int",
+					"field-id" : 138
+					}, {
+					"name" : "value",
+					"type" : "long",
+					"field-id" : 139
+					} ]
+				},
+				"logicalType" : "map"
+				} ],
+				"doc" : "Map of column id to number of NaN values in the column",
+				"default" : null,
+				"field-id" : 137
+			}, {
+				"name" : "lower_bounds",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : {
+					"type" : "record",
+					"name" : "k126_v127",
+					"fields" : [ {
+					"name" : "key",
+					"type" : "int",
+					"field-id" : 126
+					}, {
+					"name" : "value",
+					"type" : "bytes",
+					"field-id" : 127
+					} ]
+				},
+				"logicalType" : "map"
+				} ],
+				"doc" : "Map of column id to lower bound",
+				"default" : null,
+				"field-id" : 125
+			}, {
+				"name" : "upper_bounds",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : {
+					"type" : "record",
+					"name" : "k129_v130",
+					"fields" : [ {
+					"name" : "key",
+					"type" : "int",
+					"field-id" : 129
+					}, {
+					"name" : "value",
+					"type" : "bytes",
+					"field-id" : 130
+					} ]
+				},
+				"logicalType" : "map"
+				} ],
+				"doc" : "Map of column id to upper bound",
+				"default" : null,
+				"field-id" : 128
+			}, {
+				"name" : "key_metadata",
+				"type" : [ "null", "bytes" ],
+				"doc" : "Encryption key metadata blob",
+				"default" : null,
+				"field-id" : 131
+			}, {
+				"name" : "split_offsets",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : "long",
+				"element-id" : 133
+				} ],
+				"doc" : "Splittable offsets",
+				"default" : null,
+				"field-id" : 132
+			}, {
+				"name" : "equality_ids",
+				"type" : [ "null", {
+				"type" : "array",
+				"items" : "long",
+				"element-id" : 136
+				} ],
+				"doc" : "Field ids used to determine row equality in equality delete files.",
+				"default" : null,
+				"field-id" : 135
+			}, {
+				"name" : "sort_order_id",
+				"type" : [ "null", "int" ],
+				"doc" : "ID representing sort order for this file",
+				"default" : null,
+				"field-id" : 140
+			} ]
+			},
+			"field-id" : 2
+		} ]
+	}`
+	MANIFEST_LIST_SCHEMA = `{
+		"type" : "record",
+		"name" : "manifest_file",
+		"fields" : [ {
+			"name" : "manifest_path",
+			"type" : "string",
+			"doc" : "Location URI with FS scheme",
+			"field-id" : 500
+		}, {
+			"name" : "manifest_length",
+			"type" : "long",
+			"field-id" : 501
+		}, {
+			"name" : "partition_spec_id",
+			"type" : "int",
+			"field-id" : 502
+		}, {
+			"name" : "content",
+			"type" : "int",
+			"field-id" : 517
+		}, {
+			"name" : "sequence_number",
+			"type" : "long",
+			"field-id" : 515
+		}, {
+			"name" : "min_sequence_number",
+			"type" : "long",
+			"field-id" : 516
+		}, {
+			"name" : "added_snapshot_id",
+			"type" : "long",
+			"field-id" : 503
+		}, {
+			"name" : "added_files_count",
+			"type" : "int",
+			"field-id" : 504
+		}, {
+			"name" : "existing_files_count",
+			"type" : "int",
+			"field-id" : 505
+		}, {
+			"name" : "deleted_files_count",
+			"type" : "int",
+			"field-id" : 506
+		}, {
+			"name" : "added_rows_count",
+			"type" : "long",
+			"field-id" : 512
+		}, {
+			"name" : "existing_rows_count",
+			"type" : "long",
+			"field-id" : 513
+		}, {
+			"name" : "deleted_rows_count",
+			"type" : "long",
+			"field-id" : 514
+		}, {
+			"name" : "partitions",
+			"type" : [ "null", {
+			"type" : "array",
+			"items" : {
+				"type" : "record",
+				"name" : "r508",
+				"fields" : [ {
+				"name" : "contains_null",
+				"type" : "boolean",
+				"field-id" : 509
+				}, {
+				"name" : "contains_nan",
+				"type" : [ "null", "boolean" ],
+				"default" : null,
+				"field-id" : 518
+				}, {
+				"name" : "lower_bound",
+				"type" : [ "null", "bytes" ],
+				"default" : null,
+				"field-id" : 510
+				}, {
+				"name" : "upper_bound",
+				"type" : [ "null", "bytes" ],
+				"default" : null,
+				"field-id" : 511
+				} ]
+			},
+			"element-id" : 508
+			} ],
+			"default" : null,
+			"field-id" : 507
+		}, {
+			"name" : "key_metadata",
+			"type" : [ "null", "bytes" ],
+			"default" : null,
+			"field-id" : 519
+		} ]
+	}`
+)
+
+func (icebergWriter *IcebergWriter) Write(schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) {
+	icebergSchemaTable := IcebergSchemaTable{Schema: icebergWriter.config.Pg.SchemaPrefix + schemaTable.Schema, Table: schemaTable.Table}
+	unlock := LockIcebergSchemaTable(icebergSchemaTable)
+	defer unlock()
+
+	branch := icebergWriter.config.IcebergBranch
+	if branch != ICEBERG_MAIN_BRANCH {
+		_, err := icebergWriter.storage.IcebergRefs(icebergSchemaTable)
+		if err == nil {
+			icebergWriter.writeBranch(schemaTable, branch, pgSchemaColumns, loadRows)
+			return
+		}
+		// Other errors, e.g. a failed read, mustn't publish the rows on the main branch
+		if !errors.Is(err, ERROR_ICEBERG_TABLE_NOT_FOUND) {
+			PanicIfError(err)
+		}
+		LogWarn(icebergWriter.config, "Creating", icebergSchemaTable.String(), "on the", ICEBERG_MAIN_BRANCH, "branch since it doesn't exist yet")
+	}
+
+	partitioner, err := NewIcebergPartitioner(icebergWriter.config, icebergSchemaTable, pgSchemaColumns)
+	PanicIfError(err)
+
+	// Each sync adds a snapshot with all rows to the table's history, so the specs of previous snapshots are kept
+	icebergPartitionSpecs, err := icebergWriter.storage.IcebergPartitionSpecs(icebergSchemaTable)
+	if err != nil {
+		icebergPartitionSpecs = nil // The table doesn't exist yet
+	}
+
+	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
+
+	columnSketches := icebergWriter.columnSketches(pgSchemaColumns)
+	if columnSketches != nil {
+		loadRows = columnSketches.ObserveRows(loadRows)
+	}
+
+	icebergPartitionSpec := IcebergPartitionSpec{}
+	var parquetFiles []ParquetFile
+	if partitioner != nil {
+		icebergPartitionSpec = partitioner.Spec()
+		parquetFiles, err = icebergWriter.createPartitionedParquets(icebergSchemaTable, dataDirPath, pgSchemaColumns, partitioner, loadRows)
+	} else {
+		var parquetFile ParquetFile
+		parquetFile, err = icebergWriter.storage.CreateParquet(icebergSchemaTable, dataDirPath, pgSchemaColumns, loadRows)
+		parquetFiles = []ParquetFile{parquetFile}
+	}
+	PanicIfError(err)
+	icebergPartitionSpec.SpecId = icebergPartitionSpecId(icebergPartitionSpecs, icebergPartitionSpec.Fields)
+	icebergWriter.recordSkippedParquetBatches(schemaTable, parquetFiles)
+
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+
+	manifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, icebergPartitionSpec, parquetFiles)
+	PanicIfError(err)
+
+	manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, parquetFiles, manifestFile)
+	PanicIfError(err)
+
+	metadataFile, err := icebergWriter.storage.CreateMetadata(metadataDirPath, pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+	PanicIfError(err)
+
+	err = icebergWriter.storage.CreateVersionHint(metadataDirPath, metadataFile)
+	PanicIfError(err)
+
+	// Statistics are committed as the next metadata version
+	if columnSketches != nil {
+		_, err = icebergWriter.storage.CreateStatistics(metadataDirPath, manifestFile, columnSketches)
+		PanicIfError(err)
+	}
+
+	if branch != ICEBERG_MAIN_BRANCH {
+		err = icebergWriter.storage.UpdateIcebergRef(icebergSchemaTable, branch, IcebergRef{SnapshotId: manifestFile.SnapshotId, Type: ICEBERG_REF_TYPE_BRANCH})
+		PanicIfError(err)
+	}
+
+	icebergWriter.createAuditRecord(icebergSchemaTable, branch, manifestFile, parquetFiles)
+}
+
+// Writes a Parquet file per partition into its subdirectory of the data directory. Each loaded batch is split
+// by partition and passed on to the writer of each partition while it's writing, so that rows aren't held in memory
+func (icebergWriter *IcebergWriter) createPartitionedParquets(icebergSchemaTable IcebergSchemaTable, dataDirPath string, pgSchemaColumns []PgSchemaColumn, partitioner *IcebergPartitioner, loadRows func() [][]string) (parquetFiles []ParquetFile, err error) {
+	rowsChannels := map[string]chan [][]string{}
+	partitions := map[string]map[string]interface{}{}
+	parquetFilesByPath := map[string]ParquetFile{}
+	var errs []error
+	var waitGroup sync.WaitGroup
+	var mutex sync.Mutex
+
+	startPartitionWriter := func(path string) chan [][]string {
+		rowsChannel := make(chan [][]string, 1)
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			parquetFile, err := icebergWriter.storage.CreateParquet(icebergSchemaTable, dataDirPath+"/"+path, pgSchemaColumns, func() [][]string {
+				rows, ok := <-rowsChannel
+				if !ok {
+					return [][]string{}
+				}
+				return rows
+			})
+			// Drains the rows of a failed writer, so that loading the other partitions isn't blocked
+			for range rowsChannel {
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			parquetFilesByPath[path] = parquetFile
+		}()
+		return rowsChannel
+	}
+
+	for rows := loadRows(); len(rows) > 0; rows = loadRows() {
+		rowsByPath := map[string][][]string{}
+		for _, row := range rows {
+			path, partition := partitioner.Partition(row)
+			rowsByPath[path] = append(rowsByPath[path], row)
+			partitions[path] = partition
+		}
+
+		for path, partitionRows := range rowsByPath {
+			if _, ok := rowsChannels[path]; !ok {
+				rowsChannels[path] = startPartitionWriter(path)
+			}
+			rowsChannels[path] <- partitionRows
+		}
+	}
+
+	// An empty table is written as a single empty file, so that its snapshot still has a data file with its columns
+	if len(rowsChannels) == 0 {
+		path, partition := partitioner.NullPartition()
+		rowsChannels[path] = startPartitionWriter(path)
+		partitions[path] = partition
+	}
+
+	for _, rowsChannel := range rowsChannels {
+		close(rowsChannel)
+	}
+	waitGroup.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	paths := make([]string, 0, len(parquetFilesByPath))
+	for path := range parquetFilesByPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		parquetFile := parquetFilesByPath[path]
+		parquetFile.Partition = partitions[path]
+		parquetFiles = append(parquetFiles, parquetFile)
+	}
+
+	return parquetFiles, nil
+}
+
+// Adds a new snapshot on the branch without changing the data read from other refs
+func (icebergWriter *IcebergWriter) writeBranch(schemaTable IcebergSchemaTable, branch string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) {
+	icebergSchemaTable := IcebergSchemaTable{Schema: icebergWriter.config.Pg.SchemaPrefix + schemaTable.Schema, Table: schemaTable.Table}
+	dataDirPath := icebergWriter.storage.CreateDataDir(schemaTable)
+
+	columnSketches := icebergWriter.columnSketches(pgSchemaColumns)
+	if columnSketches != nil {
+		loadRows = columnSketches.ObserveRows(loadRows)
+	}
+
+	parquetFile, err := icebergWriter.storage.CreateParquet(icebergSchemaTable, dataDirPath, pgSchemaColumns, loadRows)
+	PanicIfError(err)
+	parquetFiles := []ParquetFile{parquetFile}
+	icebergWriter.recordSkippedParquetBatches(schemaTable, parquetFiles)
+
+	metadataDirPath := icebergWriter.storage.CreateMetadataDir(schemaTable)
+
+	// The table may be partitioned, by the main branch sync or by another writer, so the unpartitioned file is listed under a spec without partition fields
+	icebergPartitionSpecs, err := icebergWriter.storage.IcebergPartitionSpecs(icebergSchemaTable)
+	PanicIfError(err)
+	icebergPartitionSpec := IcebergPartitionSpec{SpecId: unpartitionedSpecId(icebergPartitionSpecs)}
+
+	manifestFile, err := icebergWriter.storage.CreateManifest(metadataDirPath, icebergPartitionSpec, parquetFiles)
+	PanicIfError(err)
+
+	manifestListFile, err := icebergWriter.storage.CreateManifestList(metadataDirPath, parquetFiles, manifestFile)
+	PanicIfError(err)
+
+	metadataFile, err := icebergWriter.storage.CreateBranchMetadata(metadataDirPath, branch, pgSchemaColumns, parquetFiles, manifestFile, manifestListFile)
+	PanicIfError(err)
+
+	err = icebergWriter.storage.CreateVersionHint(metadataDirPath, metadataFile)
+	PanicIfError(err)
+
+	if columnSketches != nil {
+		_, err = icebergWriter.storage.CreateStatistics(metadataDirPath, manifestFile, columnSketches)
+		PanicIfError(err)
+	}
+
+	icebergWriter.createAuditRecord(icebergSchemaTable, branch, manifestFile, parquetFiles)
+}
+
+// Fails the sync if the record can't be written, so that no commit is missing from the audit log
+func (icebergWriter *IcebergWriter) createAuditRecord(icebergSchemaTable IcebergSchemaTable, branch string, manifestFile ManifestFile, parquetFiles []ParquetFile) {
+	if icebergWriter.config.AuditLogPath == "" {
+		return
+	}
+
+	err := icebergWriter.storage.CreateAuditRecord(NewAuditRecord(icebergSchemaTable, branch, manifestFile.SnapshotId, parquetFiles))
+	PanicIfError(err)
+}
+
+// Returns the batches skipped since the last call
+func (icebergWriter *IcebergWriter) TakeSkippedParquetBatches() []SkippedParquetBatch {
+	icebergWriter.skippedParquetBatchesMutex.Lock()
+	defer icebergWriter.skippedParquetBatchesMutex.Unlock()
+
+	skippedParquetBatches := icebergWriter.skippedParquetBatches
+	icebergWriter.skippedParquetBatches = nil
+	return skippedParquetBatches
+}
+
+func (icebergWriter *IcebergWriter) recordSkippedParquetBatches(schemaTable IcebergSchemaTable, parquetFiles []ParquetFile) {
+	icebergWriter.skippedParquetBatchesMutex.Lock()
+	defer icebergWriter.skippedParquetBatchesMutex.Unlock()
+
+	for _, parquetFile := range parquetFiles {
+		for _, skippedBatch := range parquetFile.SkippedBatches {
+			skippedBatch.SchemaTable = schemaTable
+			icebergWriter.skippedParquetBatches = append(icebergWriter.skippedParquetBatches, skippedBatch)
+		}
+	}
+}
+
+// Sketches are only built when statistics are enabled, since hashing every value adds compute to each sync
+func (icebergWriter *IcebergWriter) columnSketches(pgSchemaColumns []PgSchemaColumn) *IcebergColumnSketches {
+	if !icebergWriter.config.IcebergStatistics {
+		return nil
+	}
+	return NewIcebergColumnSketches(pgSchemaColumns)
+}
+
+// Points the tag at the snapshot of an existing ref, e.g. to mark an audited state of a branch
+func (icebergWriter *IcebergWriter) CreateTag(icebergSchemaTable IcebergSchemaTable, tag string, fromRef string) error {
+	unlock := LockIcebergSchemaTable(icebergSchemaTable)
+	defer unlock()
+
+	snapshotId, err := icebergWriter.refSnapshotId(icebergSchemaTable, fromRef)
+	if err != nil {
+		return err
+	}
+
+	return icebergWriter.storage.UpdateIcebergRef(icebergSchemaTable, tag, IcebergRef{SnapshotId: snapshotId, Type: ICEBERG_REF_TYPE_TAG})
+}
+
+// Points the main branch at the snapshot of the ref, making its data visible to regular queries
+func (icebergWriter *IcebergWriter) PromoteRef(icebergSchemaTable IcebergSchemaTable, ref string) error {
+	unlock := LockIcebergSchemaTable(icebergSchemaTable)
+	defer unlock()
+
+	snapshotId, err := icebergWriter.refSnapshotId(icebergSchemaTable, ref)
+	if err != nil {
+		return err
+	}
+
+	return icebergWriter.storage.UpdateIcebergRef(icebergSchemaTable, ICEBERG_MAIN_BRANCH, IcebergRef{SnapshotId: snapshotId, Type: ICEBERG_REF_TYPE_BRANCH})
+}
+
+func (icebergWriter *IcebergWriter) refSnapshotId(icebergSchemaTable IcebergSchemaTable, ref string) (snapshotId int64, err error) {
+	icebergReader := &IcebergReader{config: icebergWriter.config, storage: icebergWriter.storage}
+	return icebergReader.RefSnapshotId(icebergSchemaTable, ref)
+}
+
+func (icebergWriter *IcebergWriter) DeleteSchemaTable(schemaTable IcebergSchemaTable) {
+	err := icebergWriter.storage.DeleteSchemaTable(schemaTable)
+	PanicIfError(err)
+}
+
+func (icebergWriter *IcebergWriter) DeleteSchema(schema string) {
+	err := icebergWriter.storage.DeleteSchema(schema)
+	PanicIfError(err)
+}
+
+// Deletes files that aren't referenced by any snapshot of the table and are older than the vacuum min age,
+// e.g. data files of a sync that failed before its metadata was written
+func (icebergWriter *IcebergWriter) Vacuum(icebergSchemaTable IcebergSchemaTable) (deletedFilePaths []string, err error) {
+	unlock := LockIcebergSchemaTable(icebergSchemaTable)
+	defer unlock()
+
+	modifiedBefore := time.Now().Add(-icebergWriter.config.Maintenance.VacuumMinAge)
+	return icebergWriter.storage.DeleteOrphanedFiles(icebergSchemaTable, modifiedBefore)
+}
+
+// Merges data files smaller than the target file size into a new snapshot, tables without such files are left as they are.
+// Must be called while holding the table lock
+func (icebergWriter *IcebergWriter) CompactTable(icebergSchemaTable IcebergSchemaTable) error {
+	compactedFilePaths, err := icebergWriter.storage.CompactDataFiles(icebergSchemaTable, icebergWriter.config.Maintenance.TargetFileSize)
+	if err != nil {
+		return err
+	}
+	if len(compactedFilePaths) > 0 {
+		LogInfo(icebergWriter.config, "Compacted", len(compactedFilePaths), "data file(s) of", icebergSchemaTable.String())
+	}
+
+	return nil
+}
+
+// Deletes snapshots older than the snapshot max age and the files only they reference, keeping the last snapshots and the ones of branches and tags.
+// Must be called while holding the table lock
+func (icebergWriter *IcebergWriter) ExpireSnapshots(icebergSchemaTable IcebergSchemaTable) error {
+	maintenanceConfig := icebergWriter.config.Maintenance
+	if maintenanceConfig.SnapshotMaxAge == 0 {
+		// Snapshots of previous syncs are kept as the table's history
+		LogDebug(icebergWriter.config, "No snapshot max age, keeping all snapshots of", icebergSchemaTable.String())
+	} else {
+		olderThan := time.Now().Add(-maintenanceConfig.SnapshotMaxAge)
+		expiredFilePaths, err := icebergWriter.storage.ExpireSnapshots(icebergSchemaTable, maintenanceConfig.KeepSnapshots, olderThan, maintenanceConfig.ExpireDryRun)
+		if err != nil {
+			return err
+		}
+		if maintenanceConfig.ExpireDryRun {
+			LogInfo(icebergWriter.config, "Dry run, expiring snapshots would delete", len(expiredFilePaths), "file(s) from", icebergSchemaTable.String())
+		} else {
+			LogInfo(icebergWriter.config, "Deleted", len(expiredFilePaths), "file(s) of expired snapshots from", icebergSchemaTable.String())
+		}
+	}
+
+	// Snapshots retained by other refs are kept, but their data files can be moved to a cheaper storage class
+	if icebergWriter.config.Aws.S3SupersededStorageClass != "" {
+		return icebergWriter.storage.TransitionSupersededDataFiles(icebergSchemaTable, icebergWriter.config.Aws.S3SupersededStorageClass)
+	}
+
+	return nil
+}
@@ -0,0 +1,1386 @@
+package bemidb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/linkedin/goavro"
+)
+
+func TestIcebergWriterBranch(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_branch_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+	mainRows := [][]string{{"1"}}
+	stagingRows := [][]string{{"1"}, {"2"}}
+
+	writeTestBranchTable := func(config Config, branch string, rows [][]string) {
+		config.IcebergBranch = branch
+		loaded := false
+		NewIcebergWriter(&config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+	}
+
+	t.Run("Writes to a branch without changing the main branch", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestBranchTable(config, ICEBERG_MAIN_BRANCH, mainRows)
+		writeTestBranchTable(config, "staging", stagingRows)
+
+		icebergReader := NewIcebergReader(&config)
+		icebergRefs, err := icebergReader.Refs(schemaTable)
+		testNoError(t, err)
+		if icebergRefs[ICEBERG_MAIN_BRANCH].SnapshotId == icebergRefs["staging"].SnapshotId {
+			t.Errorf("Expected the staging branch to point to a different snapshot than main, got %v", icebergRefs)
+		}
+		if icebergRefs["staging"].Type != ICEBERG_REF_TYPE_BRANCH {
+			t.Errorf("Expected staging to be a branch, got %v", icebergRefs["staging"].Type)
+		}
+
+		icebergTableStats, err := icebergReader.TableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != int64(len(mainRows)) {
+			t.Errorf("Expected main to have %v record(s), got %v", len(mainRows), icebergTableStats.RecordCount)
+		}
+		testSnapshotRecordCount(t, icebergReader.MetadataFilePath(schemaTable), icebergRefs["staging"].SnapshotId, len(stagingRows))
+	})
+
+	t.Run("Reads a branch by its ref", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestBranchTable(config, ICEBERG_MAIN_BRANCH, mainRows)
+		writeTestBranchTable(config, "staging", stagingRows)
+		stagingSnapshotId, err := NewIcebergReader(&config).RefSnapshotId(schemaTable, "staging")
+		testNoError(t, err)
+
+		queryHandler := initQueryHandler()
+		remappedQuery, err := queryHandler.remapQuery(`SELECT id FROM "test_branch_table@staging"`)
+		testNoError(t, err)
+		if !strings.Contains(remappedQuery, "'"+strconv.FormatInt(stagingSnapshotId, 10)+"'::ubigint") {
+			t.Errorf("Expected the query to read the staging snapshot %v, got %v", stagingSnapshotId, remappedQuery)
+		}
+		if !strings.HasSuffix(remappedQuery, `) "test_branch_table@staging"`) {
+			t.Errorf("Expected the table to keep its name with the ref, got %v", remappedQuery)
+		}
+
+		remappedQuery, err = queryHandler.remapQuery("SELECT id FROM test_branch_table")
+		testNoError(t, err)
+		if strings.Contains(remappedQuery, "ubigint") {
+			t.Errorf("Expected the query to read the current snapshot, got %v", remappedQuery)
+		}
+	})
+
+	t.Run("Creates a tag and promotes it to the main branch", func(t *testing.T) {
+		config := *loadTestConfig()
+		icebergWriter := NewIcebergWriter(&config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestBranchTable(config, ICEBERG_MAIN_BRANCH, mainRows)
+		writeTestBranchTable(config, "staging", stagingRows)
+
+		err := icebergWriter.CreateTag(schemaTable, "audited", "staging")
+		testNoError(t, err)
+		err = icebergWriter.PromoteRef(schemaTable, "audited")
+		testNoError(t, err)
+
+		icebergReader := NewIcebergReader(&config)
+		icebergRefs, err := icebergReader.Refs(schemaTable)
+		testNoError(t, err)
+		if icebergRefs["audited"].Type != ICEBERG_REF_TYPE_TAG || icebergRefs["audited"].SnapshotId != icebergRefs["staging"].SnapshotId {
+			t.Errorf("Expected audited to tag the staging snapshot, got %v", icebergRefs)
+		}
+		if icebergRefs[ICEBERG_MAIN_BRANCH].SnapshotId != icebergRefs["staging"].SnapshotId {
+			t.Errorf("Expected main to point to the staging snapshot, got %v", icebergRefs)
+		}
+		icebergTableStats, err := icebergReader.TableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != int64(len(stagingRows)) {
+			t.Errorf("Expected main to have %v record(s), got %v", len(stagingRows), icebergTableStats.RecordCount)
+		}
+	})
+
+	t.Run("Returns an error for an unknown ref", func(t *testing.T) {
+		config := *loadTestConfig()
+		icebergWriter := NewIcebergWriter(&config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestBranchTable(config, ICEBERG_MAIN_BRANCH, mainRows)
+
+		err := icebergWriter.PromoteRef(schemaTable, "unknown")
+
+		if err == nil || !strings.Contains(err.Error(), "Failed to find ref unknown") {
+			t.Errorf("Expected an unknown ref error, got %v", err)
+		}
+	})
+
+	t.Run("Creates a new table on both main and the branch", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestBranchTable(config, "staging", stagingRows)
+
+		icebergRefs, err := NewIcebergReader(&config).Refs(schemaTable)
+		testNoError(t, err)
+		if icebergRefs[ICEBERG_MAIN_BRANCH].SnapshotId != icebergRefs["staging"].SnapshotId {
+			t.Errorf("Expected main and staging to point to the same snapshot, got %v", icebergRefs)
+		}
+	})
+
+	t.Run("Doesn't write to the main branch if the refs can't be read", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestBranchTable(config, ICEBERG_MAIN_BRANCH, mainRows)
+		icebergReader := NewIcebergReader(&config)
+		mainSnapshotId, err := icebergReader.RefSnapshotId(schemaTable, ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+
+		config.IcebergBranch = "staging"
+		icebergWriter := &IcebergWriter{config: &config, storage: &testFailingRefsStorage{Storage: NewStorage(&config)}}
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Expected panic for refs that can't be read")
+				}
+			}()
+			icebergWriter.Write(schemaTable, pgSchemaColumns, func() [][]string { return [][]string{} })
+		}()
+
+		icebergRefs, err := icebergReader.Refs(schemaTable)
+		testNoError(t, err)
+		if icebergRefs[ICEBERG_MAIN_BRANCH].SnapshotId != mainSnapshotId {
+			t.Errorf("Expected main to still point to snapshot %v, got %v", mainSnapshotId, icebergRefs)
+		}
+		if _, ok := icebergRefs["staging"]; ok {
+			t.Errorf("Expected no staging branch, got %v", icebergRefs)
+		}
+	})
+}
+
+// Fails to read the refs like a storage that can't be reached
+type testFailingRefsStorage struct {
+	Storage
+}
+
+func (storage *testFailingRefsStorage) IcebergRefs(icebergSchemaTable IcebergSchemaTable) (map[string]IcebergRef, error) {
+	return nil, errors.New("Failed to get version hint file: connection reset by peer")
+}
+
+func TestIcebergWriterStatistics(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_statistics_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		{ColumnName: "status", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+		{ColumnName: "tags", DataType: "ARRAY", UdtName: "_text", IsNullable: "YES", OrdinalPosition: "3", Namespace: "pg_catalog"},
+	}
+	rows := [][]string{}
+	for i := 0; i < 10000; i++ {
+		status := []string{"active", "inactive", PG_NULL_STRING}[i%3]
+		rows = append(rows, []string{strconv.Itoa(i), status, "{a,b}"})
+	}
+
+	writeTestStatisticsTable := func(config Config, branch string) {
+		config.IcebergBranch = branch
+		loaded := false
+		NewIcebergWriter(&config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+	}
+
+	t.Run("Writes a Puffin file with NDV sketches referenced from the metadata", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.IcebergStatistics = true
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestStatisticsTable(config, ICEBERG_MAIN_BRANCH)
+
+		icebergReader := NewIcebergReader(&config)
+		snapshotId, err := icebergReader.RefSnapshotId(schemaTable, ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+		statisticsFiles := testMetadataStatistics(t, icebergReader.MetadataFilePath(schemaTable))
+		if len(statisticsFiles) != 1 {
+			t.Fatalf("Expected 1 statistics file, got %v", statisticsFiles)
+		}
+		statisticsFile := statisticsFiles[0]
+		if statisticsFile.SnapshotId != snapshotId {
+			t.Errorf("Expected statistics of snapshot %v, got %v", snapshotId, statisticsFile.SnapshotId)
+		}
+
+		puffinContent, err := os.ReadFile(statisticsFile.Path)
+		testNoError(t, err)
+		if int64(len(puffinContent)) != statisticsFile.Size {
+			t.Errorf("Expected file size %v, got %v", len(puffinContent), statisticsFile.Size)
+		}
+		puffinFileMetadata := testPuffinFooter(t, puffinContent, statisticsFile.FooterSize)
+
+		expectedNdvByField := map[int]float64{1: 10000, 2: 2}
+		if len(puffinFileMetadata.Blobs) != len(expectedNdvByField) || len(statisticsFile.Blobs) != len(expectedNdvByField) {
+			t.Fatalf("Expected a blob for each non-array column, got %v and %v", puffinFileMetadata.Blobs, statisticsFile.Blobs)
+		}
+		for i, blob := range puffinFileMetadata.Blobs {
+			if blob.Type != PUFFIN_BLOB_TYPE_THETA_SKETCH || blob.SnapshotId != snapshotId || blob.SequenceNumber != 1 {
+				t.Errorf("Unexpected blob metadata %v", blob)
+			}
+			if blob.Properties[PUFFIN_BLOB_PROPERTY_NDV] != statisticsFile.Blobs[i].Properties[PUFFIN_BLOB_PROPERTY_NDV] || blob.Fields[0] != statisticsFile.Blobs[i].Fields[0] {
+				t.Errorf("Expected the metadata to match the Puffin footer, got %v and %v", statisticsFile.Blobs[i], blob)
+			}
+
+			ndv, err := strconv.ParseFloat(blob.Properties[PUFFIN_BLOB_PROPERTY_NDV], 64)
+			testNoError(t, err)
+			expectedNdv := expectedNdvByField[blob.Fields[0]]
+			if math.Abs(ndv-expectedNdv) > expectedNdv*0.05 {
+				t.Errorf("Expected field %v to have about %v distinct values, got %v", blob.Fields[0], expectedNdv, ndv)
+			}
+
+			sketch := puffinContent[blob.Offset : blob.Offset+blob.Length]
+			if sketch[1] != THETA_SKETCH_SERIAL_VERSION || sketch[2] != THETA_SKETCH_FAMILY_COMPACT || binary.LittleEndian.Uint16(sketch[6:8]) != 0x93cc {
+				t.Errorf("Expected a compact theta sketch with the default seed, got %x", sketch[:8])
+			}
+		}
+	})
+
+	t.Run("Adds statistics for a branch snapshot", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.IcebergStatistics = true
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestStatisticsTable(config, ICEBERG_MAIN_BRANCH)
+		writeTestStatisticsTable(config, "staging")
+
+		icebergReader := NewIcebergReader(&config)
+		stagingSnapshotId, err := icebergReader.RefSnapshotId(schemaTable, "staging")
+		testNoError(t, err)
+		statisticsFiles := testMetadataStatistics(t, icebergReader.MetadataFilePath(schemaTable))
+		if len(statisticsFiles) != 2 || statisticsFiles[1].SnapshotId != stagingSnapshotId || statisticsFiles[1].Blobs[0].SequenceNumber != 2 {
+			t.Errorf("Expected statistics for the main and staging snapshots, got %v", statisticsFiles)
+		}
+	})
+
+	t.Run("Doesn't write statistics by default", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestStatisticsTable(config, ICEBERG_MAIN_BRANCH)
+
+		statisticsFiles := testMetadataStatistics(t, NewIcebergReader(&config).MetadataFilePath(schemaTable))
+		if len(statisticsFiles) != 0 {
+			t.Errorf("Expected no statistics files, got %v", statisticsFiles)
+		}
+	})
+}
+
+func TestIcebergWriterAuditLog(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_audit_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	writeTestAuditTable := func(config Config, branch string, rows [][]string) {
+		config.IcebergBranch = branch
+		loaded := false
+		NewIcebergWriter(&config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+	}
+
+	t.Run("Writes an audit record per commit that can be queried", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.AuditLogPath = t.TempDir()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+
+		writeTestAuditTable(config, ICEBERG_MAIN_BRANCH, [][]string{{"1"}})
+		writeTestAuditTable(config, ICEBERG_MAIN_BRANCH, [][]string{{"1"}, {"2"}})
+		writeTestAuditTable(config, "staging", [][]string{{"1"}, {"2"}, {"3"}})
+
+		auditRecords := testAuditRecords(t, config.AuditLogPath)
+		if len(auditRecords) != 3 {
+			t.Fatalf("Expected 3 audit records, got %v", len(auditRecords))
+		}
+		icebergRefs, err := NewIcebergReader(&config).Refs(schemaTable)
+		testNoError(t, err)
+		for i, expected := range []struct {
+			branch      string
+			recordCount int64
+			snapshotId  int64
+		}{
+			{ICEBERG_MAIN_BRANCH, 1, 0},
+			{ICEBERG_MAIN_BRANCH, 2, icebergRefs[ICEBERG_MAIN_BRANCH].SnapshotId},
+			{"staging", 3, icebergRefs["staging"].SnapshotId},
+		} {
+			auditRecord := auditRecords[i]
+			if auditRecord.Schema != "public" || auditRecord.Table != "test_audit_table" || auditRecord.Branch != expected.branch || auditRecord.RecordCount != expected.recordCount {
+				t.Errorf("Expected audit record %v to be for %v rows on %v, got %+v", i, expected.recordCount, expected.branch, auditRecord)
+			}
+			if expected.snapshotId != 0 && auditRecord.SnapshotId != expected.snapshotId {
+				t.Errorf("Expected audit record %v to have snapshot id %v, got %v", i, expected.snapshotId, auditRecord.SnapshotId)
+			}
+			if auditRecord.FileSize == 0 || auditRecord.CommittedAt.IsZero() || auditRecord.InitiatedBy == "" {
+				t.Errorf("Expected audit record %v to have a file size, commit time and user, got %+v", i, auditRecord)
+			}
+		}
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		messages, err := queryHandler.HandleQuery("SELECT branch, SUM(record_count) AS record_count FROM bemidb.audit WHERE \"table\" = 'test_audit_table' GROUP BY branch ORDER BY branch")
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"main", "3"})
+		testDataRowValues(t, messages[2], []string{"staging", "3"})
+	})
+
+	t.Run("Returns no rows before the first commit", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.AuditLogPath = t.TempDir()
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		messages, err := queryHandler.HandleQuery("SELECT * FROM bemidb.audit")
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], AUDIT_COLUMN_TYPES.Keys())
+		testMessageTypes(t, messages, []pgproto3.Message{&pgproto3.RowDescription{}, &pgproto3.CommandComplete{}})
+	})
+
+	t.Run("Fails the sync when the audit record can't be written", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.AuditLogPath = filepath.Join(t.TempDir(), "file")
+		err := os.WriteFile(config.AuditLogPath, []byte{}, 0644)
+		testNoError(t, err)
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Expected the write to panic")
+			}
+		}()
+		writeTestAuditTable(config, ICEBERG_MAIN_BRANCH, [][]string{{"1"}})
+	})
+}
+
+func testAuditRecords(t *testing.T, auditLogPath string) (auditRecords []AuditRecord) {
+	filePaths, err := filepath.Glob(filepath.Join(auditLogPath, "*.json"))
+	testNoError(t, err)
+
+	for _, filePath := range filePaths {
+		content, err := os.ReadFile(filePath)
+		testNoError(t, err)
+		var auditRecord AuditRecord
+		err = json.Unmarshal(content, &auditRecord)
+		testNoError(t, err)
+		auditRecords = append(auditRecords, auditRecord)
+	}
+	return auditRecords
+}
+
+func testMetadataStatistics(t *testing.T, metadataFilePath string) []StatisticsFile {
+	metadataContent, err := os.ReadFile(metadataFilePath)
+	testNoError(t, err)
+	var metadata struct {
+		Statistics []struct {
+			SnapshotId   int64                `json:"snapshot-id"`
+			Path         string               `json:"statistics-path"`
+			Size         int64                `json:"file-size-in-bytes"`
+			FooterSize   int64                `json:"file-footer-size-in-bytes"`
+			BlobMetadata []PuffinBlobMetadata `json:"blob-metadata"`
+		} `json:"statistics"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	testNoError(t, err)
+
+	statisticsFiles := []StatisticsFile{}
+	for _, statistics := range metadata.Statistics {
+		statisticsFiles = append(statisticsFiles, StatisticsFile{
+			SnapshotId: statistics.SnapshotId,
+			Path:       statistics.Path,
+			Size:       statistics.Size,
+			FooterSize: statistics.FooterSize,
+			Blobs:      statistics.BlobMetadata,
+		})
+	}
+	return statisticsFiles
+}
+
+// Magic, blobs, and a footer of magic, JSON payload, payload size, flags and magic
+func testPuffinFooter(t *testing.T, puffinContent []byte, footerSize int64) PuffinFileMetadata {
+	fileSize := int64(len(puffinContent))
+	if string(puffinContent[:4]) != PUFFIN_MAGIC || string(puffinContent[fileSize-4:]) != PUFFIN_MAGIC || string(puffinContent[fileSize-footerSize:fileSize-footerSize+4]) != PUFFIN_MAGIC {
+		t.Fatalf("Expected Puffin magic at the start, the footer start and the end")
+	}
+	if flags := binary.LittleEndian.Uint32(puffinContent[fileSize-8 : fileSize-4]); flags != 0 {
+		t.Errorf("Expected an uncompressed footer, got flags %v", flags)
+	}
+
+	payloadSize := int64(binary.LittleEndian.Uint32(puffinContent[fileSize-PUFFIN_FOOTER_STRUCT_SIZE : fileSize-8]))
+	if payloadSize != footerSize-4-PUFFIN_FOOTER_STRUCT_SIZE {
+		t.Errorf("Expected a payload size of %v, got %v", footerSize-4-PUFFIN_FOOTER_STRUCT_SIZE, payloadSize)
+	}
+	payloadStart := fileSize - PUFFIN_FOOTER_STRUCT_SIZE - payloadSize
+	var puffinFileMetadata PuffinFileMetadata
+	err := json.Unmarshal(puffinContent[payloadStart:fileSize-PUFFIN_FOOTER_STRUCT_SIZE], &puffinFileMetadata)
+	testNoError(t, err)
+
+	return puffinFileMetadata
+}
+
+func testSnapshotRecordCount(t *testing.T, metadataFilePath string, snapshotId int64, expectedRecordCount int) {
+	metadataContent, err := os.ReadFile(metadataFilePath)
+	testNoError(t, err)
+	var metadata struct {
+		Snapshots []struct {
+			SnapshotId int64             `json:"snapshot-id"`
+			Summary    map[string]string `json:"summary"`
+		} `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	testNoError(t, err)
+
+	for _, snapshot := range metadata.Snapshots {
+		if snapshot.SnapshotId == snapshotId {
+			if snapshot.Summary["total-records"] != strconv.Itoa(expectedRecordCount) {
+				t.Errorf("Expected snapshot %v to have %v record(s), got %v", snapshotId, expectedRecordCount, snapshot.Summary["total-records"])
+			}
+			return
+		}
+	}
+	t.Errorf("Expected snapshot %v to exist", snapshotId)
+}
+
+func TestIcebergWriterSpecialValues(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_special_values_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		{ColumnName: "float4_column", DataType: "real", UdtName: "float4", IsNullable: "YES", OrdinalPosition: "2", NumericPrecision: "24", Namespace: "pg_catalog"},
+		{ColumnName: "float8_column", DataType: "double precision", UdtName: "float8", IsNullable: "YES", OrdinalPosition: "3", NumericPrecision: "53", Namespace: "pg_catalog"},
+		{ColumnName: "timestamp_column", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "YES", OrdinalPosition: "4", DatetimePrecision: "6", Namespace: "pg_catalog"},
+		{ColumnName: "timestamp_ms_column", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "YES", OrdinalPosition: "5", DatetimePrecision: "3", Namespace: "pg_catalog"},
+		{ColumnName: "timestamptz_column", DataType: "timestamp with time zone", UdtName: "timestamptz", IsNullable: "YES", OrdinalPosition: "6", DatetimePrecision: "6", Namespace: "pg_catalog"},
+		{ColumnName: "date_column", DataType: "date", UdtName: "date", IsNullable: "YES", OrdinalPosition: "7", Namespace: "pg_catalog"},
+	}
+	rows := [][]string{
+		{"1", "NaN", "NaN", "2024-01-01 00:00:00", "2024-01-01 00:00:00", "2024-01-01 00:00:00+00", "2024-01-01"},
+		{"2", "Infinity", "Infinity", "infinity", "infinity", "infinity", "infinity"},
+		{"3", "-Infinity", "-Infinity", "-infinity", "-infinity", "-infinity", "-infinity"},
+		{"4", "1.5", "1.5", PG_NULL_STRING, PG_NULL_STRING, PG_NULL_STRING, PG_NULL_STRING},
+	}
+
+	writeTestSpecialValuesTable := func(config Config) {
+		loaded := false
+		NewIcebergWriter(&config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+	}
+
+	t.Run("Round-trips NaN and infinity floats", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestSpecialValuesTable(config)
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		messages, err := queryHandler.HandleQuery("SELECT float4_column, float8_column FROM test_special_values_table ORDER BY id")
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"NaN", "NaN"})
+		testDataRowValues(t, messages[2], []string{"Infinity", "Infinity"})
+		testDataRowValues(t, messages[3], []string{"-Infinity", "-Infinity"})
+		testDataRowValues(t, messages[4], []string{"1.5", "1.5"})
+	})
+
+	t.Run("Round-trips infinite timestamps and dates", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestSpecialValuesTable(config)
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		messages, err := queryHandler.HandleQuery("SELECT timestamp_column, timestamp_ms_column, timestamptz_column, date_column FROM test_special_values_table ORDER BY id")
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2024-01-01 00:00:00", "2024-01-01 00:00:00", "2024-01-01 00:00:00", "2024-01-01"})
+		testDataRowValues(t, messages[2], []string{"infinity", "9999-12-31 23:59:59.999", "infinity", "infinity"})
+		testDataRowValues(t, messages[3], []string{"-infinity", "0001-01-01 00:00:00", "-infinity", "-infinity"})
+	})
+
+	t.Run("Filters on NaN and infinity", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestSpecialValuesTable(config)
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		for query, expectedIds := range map[string][]string{
+			"SELECT id FROM test_special_values_table WHERE float4_column = 'NaN' ORDER BY id":                          {"1"},
+			"SELECT id FROM test_special_values_table WHERE float8_column = 'Infinity' ORDER BY id":                     {"2"},
+			"SELECT id FROM test_special_values_table WHERE float8_column < 0 ORDER BY id":                              {"3"},
+			"SELECT id FROM test_special_values_table WHERE float8_column > 1 ORDER BY id":                              {"1", "2", "4"},
+			"SELECT id FROM test_special_values_table WHERE timestamp_column = 'infinity' ORDER BY id":                  {"2"},
+			"SELECT id FROM test_special_values_table WHERE timestamptz_column < '2000-01-01' ORDER BY id":              {"3"},
+			"SELECT id FROM test_special_values_table WHERE date_column = '-infinity' ORDER BY id":                      {"3"},
+			"SELECT id FROM test_special_values_table WHERE date_column > '2000-01-01' ORDER BY id":                     {"1", "2"},
+			"SELECT id FROM test_special_values_table WHERE isinf(timestamp_column) AND isinf(date_column) ORDER BY id": {"2", "3"},
+		} {
+			messages, err := queryHandler.HandleQuery(query)
+			testNoError(t, err)
+			if len(messages) != len(expectedIds)+2 {
+				t.Fatalf("Expected %v rows for %v, got %v messages", len(expectedIds), query, len(messages))
+			}
+			for i, expectedId := range expectedIds {
+				testDataRowValues(t, messages[i+1], []string{expectedId})
+			}
+		}
+	})
+
+	t.Run("Counts NaN values in the manifest", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestSpecialValuesTable(config)
+
+		manifestPaths, err := filepath.Glob(filepath.Join(config.StoragePath, schemaTable.Schema, schemaTable.Table, "metadata", "*-m0.avro"))
+		testNoError(t, err)
+		manifestRecords, err := (&StorageBase{}).readAvroRecords(manifestPaths[0], func(path string) (io.ReadCloser, error) { return os.Open(path) })
+		testNoError(t, err)
+
+		nanValueCounts := map[int32]int64{}
+		dataFile := manifestRecords[0]["data_file"].(map[string]interface{})
+		for _, nanValueCount := range dataFile["nan_value_counts"].(map[string]interface{})["array"].([]interface{}) {
+			keyValue := nanValueCount.(map[string]interface{})
+			nanValueCounts[keyValue["key"].(int32)] = keyValue["value"].(int64)
+		}
+		if len(nanValueCounts) != 2 || nanValueCounts[2] != 1 || nanValueCounts[3] != 1 {
+			t.Errorf("Expected a NaN value for each float column, got %v", nanValueCounts)
+		}
+	})
+
+	t.Run("Writes infinite timestamps and dates as NULL if configured", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.Pg.SyncInfiniteTimestamps = INFINITE_TIMESTAMPS_NULL
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestSpecialValuesTable(config)
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		messages, err := queryHandler.HandleQuery("SELECT COUNT(*) FROM test_special_values_table WHERE timestamp_column IS NULL AND timestamp_ms_column IS NULL AND timestamptz_column IS NULL AND date_column IS NULL")
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"3"})
+	})
+}
+
+func TestIcebergWriterPartitionEvolution(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_partition_evolution_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	writeTestPartitionEvolutionTable := func(config Config, branch string, rows [][]string) {
+		config.IcebergBranch = branch
+		loaded := false
+		NewIcebergWriter(&config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+	}
+
+	t.Run("Reads files written under different partition specs", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestPartitionEvolutionTable(config, ICEBERG_MAIN_BRANCH, [][]string{{"1"}, {"2"}})
+		testEvolvePartitionSpec(t, config, schemaTable, pgSchemaColumns, 3)
+
+		icebergPartitionSpecs, err := NewIcebergReader(&config).PartitionSpecs(schemaTable)
+		testNoError(t, err)
+		if len(icebergPartitionSpecs) != 2 || len(icebergPartitionSpecs[0].Fields) != 0 || icebergPartitionSpecs[1].Fields[0].Transform != "identity" {
+			t.Errorf("Expected an unpartitioned and an identity partition spec, got %+v", icebergPartitionSpecs)
+		}
+
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+		messages, err := queryHandler.HandleQuery("SELECT id FROM test_partition_evolution_table ORDER BY id")
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"1"})
+		testDataRowValues(t, messages[2], []string{"2"})
+		testDataRowValues(t, messages[3], []string{"3"})
+	})
+
+	t.Run("Writes a branch of a table with an evolved partition spec under the unpartitioned spec", func(t *testing.T) {
+		config := *loadTestConfig()
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestPartitionEvolutionTable(config, ICEBERG_MAIN_BRANCH, [][]string{{"1"}, {"2"}})
+		testEvolvePartitionSpec(t, config, schemaTable, pgSchemaColumns, 3)
+		writeTestPartitionEvolutionTable(config, "staging", [][]string{{"4"}})
+
+		metadataContent, err := os.ReadFile(NewIcebergReader(&config).MetadataFilePath(schemaTable))
+		testNoError(t, err)
+		var metadata struct {
+			DefaultSpecId int `json:"default-spec-id"`
+		}
+		err = json.Unmarshal(metadataContent, &metadata)
+		testNoError(t, err)
+		if metadata.DefaultSpecId != 1 {
+			t.Errorf("Expected the default spec to stay 1, got %v", metadata.DefaultSpecId)
+		}
+		stagingManifestListRecords := testManifestListRecords(t, config, schemaTable, "staging")
+		if len(stagingManifestListRecords) != 1 || stagingManifestListRecords[0]["partition_spec_id"] != int32(ICEBERG_UNPARTITIONED_SPEC_ID) {
+			t.Errorf("Expected the staging manifest to use the unpartitioned spec, got %v", stagingManifestListRecords)
+		}
+
+		stagingSnapshotId, err := NewIcebergReader(&config).RefSnapshotId(schemaTable, "staging")
+		testNoError(t, err)
+		testSnapshotRecordCount(t, NewIcebergReader(&config).MetadataFilePath(schemaTable), stagingSnapshotId, 1)
+		mainManifestListRecords := testManifestListRecords(t, config, schemaTable, ICEBERG_MAIN_BRANCH)
+		if len(mainManifestListRecords) != 2 || mainManifestListRecords[0]["partition_spec_id"] != int32(0) || mainManifestListRecords[1]["partition_spec_id"] != int32(1) {
+			t.Errorf("Expected main to keep its manifests under both partition specs, got %v", mainManifestListRecords)
+		}
+	})
+}
+
+// Partitions the table by its id column like another Iceberg writer would, and appends a data file with the id value under the new spec
+func testEvolvePartitionSpec(t *testing.T, config Config, schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, id int) {
+	storageBase := &StorageBase{config: &config}
+	storage := NewStorage(&config)
+	metadataFilePath := NewIcebergReader(&config).MetadataFilePath(schemaTable)
+	metadataContent, err := os.ReadFile(metadataFilePath)
+	testNoError(t, err)
+	metadata, err := storageBase.decodeMetadata(metadataContent)
+	testNoError(t, err)
+	metadataDirPath := filepath.Dir(metadataFilePath)
+
+	loaded := false
+	parquetFile, err := storage.CreateParquet(schemaTable, storage.CreateDataDir(schemaTable), pgSchemaColumns, func() [][]string {
+		if loaded {
+			return [][]string{}
+		}
+		loaded = true
+		return [][]string{{strconv.Itoa(id)}}
+	})
+	testNoError(t, err)
+	unpartitionedManifestFile, err := storage.CreateManifest(t.TempDir(), IcebergPartitionSpec{}, []ParquetFile{parquetFile})
+	testNoError(t, err)
+	manifestRecords, err := storageBase.readAvroRecords(unpartitionedManifestFile.Path, func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+	testNoError(t, err)
+
+	manifestRecords[0]["data_file"].(map[string]interface{})["partition"] = map[string]interface{}{"id": map[string]interface{}{"int": int32(id)}}
+	partitionedManifestSchema := strings.Replace(MANIFEST_SCHEMA, `"name" : "record_count",`, `"name" : "partition",
+				"type" : { "type" : "record", "name" : "r102", "fields" : [ { "name" : "id", "type" : [ "null", "int" ], "default" : null, "field-id" : 1000 } ] },
+				"field-id" : 102
+			}, {
+				"name" : "record_count",`, 1)
+	manifestFilePath := filepath.Join(metadataDirPath, parquetFile.Uuid+"-m1.avro")
+	testWriteAvroRecords(t, manifestFilePath, partitionedManifestSchema, manifestRecords)
+	manifestFileInfo, err := os.Stat(manifestFilePath)
+	testNoError(t, err)
+
+	manifestListRecords := testManifestListRecords(t, config, schemaTable, ICEBERG_MAIN_BRANCH)
+	manifestListRecords = append(manifestListRecords, map[string]interface{}{
+		"added_files_count":    int32(1),
+		"added_rows_count":     int64(1),
+		"added_snapshot_id":    unpartitionedManifestFile.SnapshotId,
+		"content":              int32(0),
+		"deleted_files_count":  int32(0),
+		"deleted_rows_count":   int64(0),
+		"existing_files_count": int32(0),
+		"existing_rows_count":  int64(0),
+		"key_metadata":         nil,
+		"manifest_length":      manifestFileInfo.Size(),
+		"manifest_path":        manifestFilePath,
+		"min_sequence_number":  int64(2),
+		"partition_spec_id":    int32(1),
+		"partitions":           map[string]interface{}{"array": []interface{}{}},
+		"sequence_number":      int64(2),
+	})
+	manifestListFilePath := filepath.Join(metadataDirPath, "snap-"+strconv.FormatInt(unpartitionedManifestFile.SnapshotId, 10)+"-1-"+parquetFile.Uuid+".avro")
+	testWriteAvroRecords(t, manifestListFilePath, MANIFEST_LIST_SCHEMA, manifestListRecords)
+
+	metadata["partition-specs"] = append(metadata["partition-specs"].([]interface{}), map[string]interface{}{
+		"spec-id": 1,
+		"fields":  []interface{}{map[string]interface{}{"source-id": 1, "field-id": 1000, "name": "id", "transform": "identity"}},
+	})
+	metadata["default-spec-id"] = 1
+	metadata["last-partition-id"] = 1000
+	metadata["last-sequence-number"] = 2
+	metadata["snapshots"] = append(metadata["snapshots"].([]interface{}), map[string]interface{}{
+		"schema-id":          0,
+		"snapshot-id":        unpartitionedManifestFile.SnapshotId,
+		"parent-snapshot-id": metadata["current-snapshot-id"],
+		"sequence-number":    2,
+		"timestamp-ms":       metadata["last-updated-ms"],
+		"manifest-list":      manifestListFilePath,
+		"summary":            map[string]interface{}{"operation": "append", "total-records": "3"},
+	})
+	metadata["current-snapshot-id"] = unpartitionedManifestFile.SnapshotId
+	metadata["refs"].(map[string]interface{})[ICEBERG_MAIN_BRANCH] = IcebergRef{SnapshotId: unpartitionedManifestFile.SnapshotId, Type: ICEBERG_REF_TYPE_BRANCH}
+	metadataContent, err = storageBase.encodeMetadata(metadata)
+	testNoError(t, err)
+	err = os.WriteFile(metadataFilePath, metadataContent, 0644)
+	testNoError(t, err)
+}
+
+func testManifestListRecords(t *testing.T, config Config, schemaTable IcebergSchemaTable, ref string) []map[string]interface{} {
+	metadataContent, err := os.ReadFile(NewIcebergReader(&config).MetadataFilePath(schemaTable))
+	testNoError(t, err)
+	storageBase := &StorageBase{config: &config}
+	metadata, err := storageBase.decodeMetadata(metadataContent)
+	testNoError(t, err)
+	snapshotId, err := NewIcebergReader(&config).RefSnapshotId(schemaTable, ref)
+	testNoError(t, err)
+
+	snapshot := storageBase.findSnapshot(metadata, snapshotId)
+	manifestListRecords, err := storageBase.readAvroRecords(snapshot["manifest-list"].(string), func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+	testNoError(t, err)
+	return manifestListRecords
+}
+
+func testWriteAvroRecords(t *testing.T, filePath string, schema string, records []map[string]interface{}) {
+	avroFile, err := os.Create(filePath)
+	testNoError(t, err)
+	defer avroFile.Close()
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{W: avroFile, Schema: schema})
+	testNoError(t, err)
+
+	values := make([]interface{}, len(records))
+	for i, record := range records {
+		values[i] = record
+	}
+	err = ocfWriter.Append(values)
+	testNoError(t, err)
+}
+
+func TestIcebergWriterPartitioned(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_partitioned_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		{ColumnName: "created_at", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "YES", OrdinalPosition: "2", DatetimePrecision: "6", Namespace: "pg_catalog"},
+	}
+
+	writeTestPartitionedTable := func(config Config, rows [][]string) {
+		loaded := false
+		NewIcebergWriter(&config).Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+	}
+
+	t.Run("Writes a data file per day of a timestamp column", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.IcebergPartitions = map[string]IcebergPartitionColumn{"public.test_partitioned_table": {ColumnName: "created_at", Transform: ICEBERG_TRANSFORM_DAY}}
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestPartitionedTable(config, [][]string{
+			{"1", "2025-01-01 10:00:00"},
+			{"2", "2025-01-02 11:00:00"},
+			{"3", "2025-01-01 23:59:59.999999"},
+			{"4", "1969-12-31 12:00:00"},
+			{"5", PG_NULL_STRING},
+		})
+
+		icebergReader := NewIcebergReader(&config)
+		tablePath := filepath.Dir(filepath.Dir(icebergReader.MetadataFilePath(schemaTable)))
+		dataDirEntries, err := os.ReadDir(filepath.Join(tablePath, "data"))
+		testNoError(t, err)
+		var partitionDirs []string
+		for _, dataDirEntry := range dataDirEntries {
+			partitionDirs = append(partitionDirs, dataDirEntry.Name())
+		}
+		expectedPartitionDirs := []string{"created_at_day=1969-12-31", "created_at_day=2025-01-01", "created_at_day=2025-01-02", "created_at_day=null"}
+		if !slices.Equal(partitionDirs, expectedPartitionDirs) {
+			t.Errorf("Expected partition directories %v, got %v", expectedPartitionDirs, partitionDirs)
+		}
+
+		icebergPartitionSpecs, err := icebergReader.PartitionSpecs(schemaTable)
+		testNoError(t, err)
+		expectedPartitionField := IcebergPartitionField{SourceId: 2, FieldId: 1000, Name: "created_at_day", Transform: "day"}
+		if len(icebergPartitionSpecs) != 1 || len(icebergPartitionSpecs[0].Fields) != 1 || icebergPartitionSpecs[0].Fields[0] != expectedPartitionField {
+			t.Errorf("Expected a day partition spec, got %+v", icebergPartitionSpecs)
+		}
+
+		icebergDataFiles, err := icebergReader.DataFiles(schemaTable)
+		testNoError(t, err)
+		var partitions []string
+		var recordCount int64
+		for _, icebergDataFile := range icebergDataFiles {
+			partitions = append(partitions, icebergDataFile.Partition)
+			recordCount += icebergDataFile.RecordCount
+		}
+		slices.Sort(partitions)
+		expectedPartitions := []string{`{"created_at_day":-1}`, `{"created_at_day":20089}`, `{"created_at_day":20090}`, `{"created_at_day":null}`}
+		if !slices.Equal(partitions, expectedPartitions) || recordCount != 5 {
+			t.Errorf("Expected partitions %v with 5 records, got %v with %v records", expectedPartitions, partitions, recordCount)
+		}
+
+		for _, icebergDataFile := range icebergDataFiles {
+			partitionDir := filepath.Base(filepath.Dir(icebergDataFile.Path))
+			if !slices.Contains(expectedPartitionDirs, partitionDir) {
+				t.Errorf("Expected data file %v to be in a partition directory", icebergDataFile.Path)
+			}
+		}
+	})
+
+	t.Run("Writes a single file with a null partition for an empty table", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.IcebergPartitions = map[string]IcebergPartitionColumn{"public.test_partitioned_table": {ColumnName: "id", Transform: ICEBERG_TRANSFORM_IDENTITY}}
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		writeTestPartitionedTable(config, [][]string{})
+
+		icebergDataFiles, err := NewIcebergReader(&config).DataFiles(schemaTable)
+		testNoError(t, err)
+		if len(icebergDataFiles) != 1 || icebergDataFiles[0].Partition != `{"id":null}` || icebergDataFiles[0].RecordCount != 0 {
+			t.Errorf("Expected a single empty data file with a null partition, got %+v", icebergDataFiles)
+		}
+	})
+
+	t.Run("Panics for a column that can't be partitioned by", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.IcebergPartitions = map[string]IcebergPartitionColumn{"public.test_partitioned_table": {ColumnName: "id", Transform: ICEBERG_TRANSFORM_DAY}}
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for the day transform of an integer column")
+			}
+		}()
+
+		writeTestPartitionedTable(config, [][]string{{"1", PG_NULL_STRING}})
+	})
+}
+
+func TestIcebergWriterVacuum(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_vacuum_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	writeTestVacuumTable := func(config *Config) (tablePath string) {
+		config.IcebergStatistics = true
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}, {"2"}})
+		stagingConfig := *config
+		stagingConfig.IcebergBranch = "staging"
+		testWriteCatalogTable(&stagingConfig, schemaTable, pgSchemaColumns, [][]string{{"3"}})
+		return filepath.Dir(filepath.Dir(NewIcebergReader(config).MetadataFilePath(schemaTable)))
+	}
+
+	writeOrphanedFile := func(t *testing.T, filePath string, age time.Duration) {
+		err := os.WriteFile(filePath, []byte("orphaned"), 0644)
+		testNoError(t, err)
+		modifiedAt := time.Now().Add(-age)
+		err = os.Chtimes(filePath, modifiedAt, modifiedAt)
+		testNoError(t, err)
+	}
+
+	t.Run("Deletes old orphaned files and keeps the files referenced by any snapshot", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		tablePath := writeTestVacuumTable(config)
+		referencedFilePaths := testTableFilePaths(t, tablePath)
+		for _, referencedFilePath := range referencedFilePaths {
+			modifiedAt := time.Now().Add(-48 * time.Hour)
+			err := os.Chtimes(referencedFilePath, modifiedAt, modifiedAt)
+			testNoError(t, err)
+		}
+		oldDataFilePath := filepath.Join(tablePath, "data", "00000-0-orphaned.parquet")
+		oldManifestPath := filepath.Join(tablePath, "metadata", "orphaned-m0.avro")
+		recentDataFilePath := filepath.Join(tablePath, "data", "00000-0-in-flight.parquet")
+		writeOrphanedFile(t, oldDataFilePath, 48*time.Hour)
+		writeOrphanedFile(t, oldManifestPath, 48*time.Hour)
+		writeOrphanedFile(t, recentDataFilePath, time.Hour)
+
+		currentMetadataFilePath := NewIcebergReader(config).MetadataFilePath(schemaTable)
+		supersededMetadataFilePaths, err := filepath.Glob(filepath.Join(tablePath, "metadata", "v*.metadata.json"))
+		testNoError(t, err)
+		supersededMetadataFilePaths = slices.DeleteFunc(supersededMetadataFilePaths, func(filePath string) bool { return filePath == currentMetadataFilePath })
+
+		deletedFilePaths, err := NewIcebergWriter(config).Vacuum(schemaTable)
+
+		testNoError(t, err)
+		expectedDeletedFilePaths := append([]string{oldDataFilePath, oldManifestPath}, supersededMetadataFilePaths...)
+		if len(supersededMetadataFilePaths) == 0 || strings.Join(deletedFilePaths, ",") != strings.Join(expectedDeletedFilePaths, ",") {
+			t.Errorf("Expected the old orphaned files and superseded metadata versions to be deleted, got %v", deletedFilePaths)
+		}
+		expectedFilePaths := slices.DeleteFunc(referencedFilePaths, func(filePath string) bool { return slices.Contains(supersededMetadataFilePaths, filePath) })
+		expectedFilePaths = append(expectedFilePaths, recentDataFilePath)
+		slices.Sort(expectedFilePaths)
+		remainingFilePaths := testTableFilePaths(t, tablePath)
+		if strings.Join(remainingFilePaths, ",") != strings.Join(expectedFilePaths, ",") {
+			t.Errorf("Expected the referenced and recent files to remain, got %v", remainingFilePaths)
+		}
+		icebergTableStats, err := NewIcebergReader(config).TableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != 2 {
+			t.Errorf("Expected the table to still have 2 records, got %v", icebergTableStats.RecordCount)
+		}
+	})
+
+	t.Run("Deletes nothing when a snapshot can't be read", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		tablePath := writeTestVacuumTable(config)
+		manifestListPaths, err := filepath.Glob(filepath.Join(tablePath, "metadata", "snap-*.avro"))
+		testNoError(t, err)
+		err = os.Remove(manifestListPaths[0])
+		testNoError(t, err)
+		oldDataFilePath := filepath.Join(tablePath, "data", "00000-0-orphaned.parquet")
+		writeOrphanedFile(t, oldDataFilePath, 48*time.Hour)
+
+		deletedFilePaths, err := NewIcebergWriter(config).Vacuum(schemaTable)
+
+		if err == nil {
+			t.Errorf("Expected an error for the missing manifest list, deleted %v", deletedFilePaths)
+		}
+		if _, err := os.Stat(oldDataFilePath); err != nil {
+			t.Errorf("Expected the orphaned file to be kept, got %v", err)
+		}
+	})
+}
+
+func TestIcebergWriterExpireSnapshots(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_expire_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	// Syncs three snapshots, the first one is tagged
+	writeTestExpireTable := func(t *testing.T, config *Config) (tablePath string) {
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}})
+		err := NewIcebergWriter(config).CreateTag(schemaTable, "first", ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"2"}})
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"3"}})
+		config.Maintenance.SnapshotMaxAge = time.Millisecond
+		config.Maintenance.KeepSnapshots = 1
+		time.Sleep(5 * time.Millisecond)
+		return filepath.Dir(filepath.Dir(NewIcebergReader(config).MetadataFilePath(schemaTable)))
+	}
+
+	t.Run("Deletes old snapshots and the files only they reference", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		tablePath := writeTestExpireTable(t, config)
+		icebergSnapshots, err := NewIcebergReader(config).Snapshots(schemaTable)
+		testNoError(t, err)
+		dataFilePaths, err := filepath.Glob(filepath.Join(tablePath, "data", "*.parquet"))
+		testNoError(t, err)
+
+		err = NewIcebergWriter(config).ExpireSnapshots(schemaTable)
+
+		testNoError(t, err)
+		retainedSnapshots, err := NewIcebergReader(config).Snapshots(schemaTable)
+		testNoError(t, err)
+		if len(retainedSnapshots) != 2 || retainedSnapshots[0].SnapshotId != icebergSnapshots[0].SnapshotId || retainedSnapshots[1].SnapshotId != icebergSnapshots[2].SnapshotId {
+			t.Errorf("Expected the tagged and the current snapshots to be retained, got %+v", retainedSnapshots)
+		}
+		icebergSnapshotLog, err := NewIcebergReader(config).SnapshotLog(schemaTable)
+		testNoError(t, err)
+		for _, icebergSnapshotLogEntry := range icebergSnapshotLog {
+			if icebergSnapshotLogEntry.SnapshotId == icebergSnapshots[1].SnapshotId {
+				t.Errorf("Expected the expired snapshot to be removed from the snapshot log, got %+v", icebergSnapshotLog)
+			}
+		}
+		if _, err := os.Stat(icebergSnapshots[1].ManifestList); !os.IsNotExist(err) {
+			t.Errorf("Expected the manifest list of the expired snapshot to be deleted, got %v", err)
+		}
+		remainingDataFilePaths, err := filepath.Glob(filepath.Join(tablePath, "data", "*.parquet"))
+		testNoError(t, err)
+		if len(dataFilePaths) != 3 || len(remainingDataFilePaths) != 2 {
+			t.Errorf("Expected the data file of the expired snapshot to be deleted, got %v", remainingDataFilePaths)
+		}
+		icebergTableStats, err := NewIcebergReader(config).TableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != 1 {
+			t.Errorf("Expected the table to still have 1 record, got %v", icebergTableStats.RecordCount)
+		}
+	})
+
+	t.Run("Deletes nothing in a dry run", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		tablePath := writeTestExpireTable(t, config)
+		config.Maintenance.ExpireDryRun = true
+		filePaths := testTableFilePaths(t, tablePath)
+
+		err := NewIcebergWriter(config).ExpireSnapshots(schemaTable)
+
+		testNoError(t, err)
+		remainingFilePaths := testTableFilePaths(t, tablePath)
+		if strings.Join(remainingFilePaths, ",") != strings.Join(filePaths, ",") {
+			t.Errorf("Expected all files to remain, got %v", remainingFilePaths)
+		}
+		icebergSnapshots, err := NewIcebergReader(config).Snapshots(schemaTable)
+		testNoError(t, err)
+		if len(icebergSnapshots) != 3 {
+			t.Errorf("Expected all snapshots to remain, got %+v", icebergSnapshots)
+		}
+	})
+
+	t.Run("Keeps all snapshots without a snapshot max age", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		tablePath := writeTestExpireTable(t, config)
+		config.Maintenance.SnapshotMaxAge = 0
+		filePaths := testTableFilePaths(t, tablePath)
+
+		err := NewIcebergWriter(config).ExpireSnapshots(schemaTable)
+
+		testNoError(t, err)
+		remainingFilePaths := testTableFilePaths(t, tablePath)
+		if strings.Join(remainingFilePaths, ",") != strings.Join(filePaths, ",") {
+			t.Errorf("Expected all files to remain, got %v", remainingFilePaths)
+		}
+	})
+}
+
+func TestIcebergWriterCompactTable(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_compact_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+	}
+
+	// Commits a snapshot with a small data file per batch of rows, like another Iceberg writer appending to the table would
+	writeTestCompactTable := func(t *testing.T, config *Config, batches [][][]string) {
+		storage := NewStorage(config)
+		dataDirPath := storage.CreateDataDir(schemaTable)
+		parquetFiles := []ParquetFile{}
+		for _, rows := range batches {
+			loaded := false
+			parquetFile, err := storage.CreateParquet(schemaTable, dataDirPath, pgSchemaColumns, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return rows
+			})
+			testNoError(t, err)
+			parquetFiles = append(parquetFiles, parquetFile)
+		}
+		metadataDirPath := storage.CreateMetadataDir(schemaTable)
+		manifestFile, err := storage.CreateManifest(metadataDirPath, IcebergPartitionSpec{}, parquetFiles)
+		testNoError(t, err)
+		manifestListFile, err := storage.CreateManifestList(metadataDirPath, parquetFiles, manifestFile)
+		testNoError(t, err)
+		metadataFile, err := storage.CreateMetadata(metadataDirPath, pgSchemaColumns, IcebergPartitionSpec{}, parquetFiles, manifestFile, manifestListFile)
+		testNoError(t, err)
+		err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+		testNoError(t, err)
+	}
+
+	t.Run("Merges small data files into a single data file of a new snapshot", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		writeTestCompactTable(t, config, [][][]string{{{"1", "Alice"}, {"2", "Bob"}}, {{"3", PG_NULL_STRING}}})
+
+		err := NewIcebergWriter(config).CompactTable(schemaTable)
+
+		testNoError(t, err)
+		icebergDataFiles, err := NewIcebergReader(config).DataFiles(schemaTable)
+		testNoError(t, err)
+		if len(icebergDataFiles) != 1 || icebergDataFiles[0].RecordCount != 3 {
+			t.Errorf("Expected a single data file with 3 records, got %+v", icebergDataFiles)
+		}
+		icebergSnapshots, err := NewIcebergReader(config).Snapshots(schemaTable)
+		testNoError(t, err)
+		if len(icebergSnapshots) != 2 || icebergSnapshots[1].Summary["operation"] != "replace" || icebergSnapshots[1].ParentSnapshotId != icebergSnapshots[0].SnapshotId {
+			t.Errorf("Expected a replace snapshot on top of the previous one, got %+v", icebergSnapshots)
+		}
+		if icebergSnapshots[1].Summary["deleted-data-files"] != "2" || icebergSnapshots[1].Summary["total-data-files"] != "1" || icebergSnapshots[1].Summary["total-records"] != "3" {
+			t.Errorf("Expected the summary to count the merged files, got %+v", icebergSnapshots[1].Summary)
+		}
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+		rows, err := duckdb.QueryContext(context.Background(), "SELECT string_agg(id || ':' || coalesce(name, 'NULL'), ',' ORDER BY id) FROM read_parquet('"+icebergDataFiles[0].Path+"')")
+		testNoError(t, err)
+		defer rows.Close()
+		var values string
+		rows.Next()
+		testNoError(t, rows.Scan(&values))
+		if values != "1:Alice,2:Bob,3:NULL" {
+			t.Errorf("Expected the compacted data file to have the rows of both files, got %v", values)
+		}
+	})
+
+	t.Run("Keeps data files larger than the target file size", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		config.Maintenance.TargetFileSize = 1
+		writeTestCompactTable(t, config, [][][]string{{{"1", "Alice"}}, {{"2", "Bob"}}})
+
+		err := NewIcebergWriter(config).CompactTable(schemaTable)
+
+		testNoError(t, err)
+		icebergSnapshots, err := NewIcebergReader(config).Snapshots(schemaTable)
+		testNoError(t, err)
+		if len(icebergSnapshots) != 1 {
+			t.Errorf("Expected no new snapshot, got %+v", icebergSnapshots)
+		}
+	})
+}
+
+func TestIcebergWriterConcurrentCommits(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_concurrent_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	t.Run("Keeps the changes of writers committing at the same time", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}})
+		icebergRefs, err := NewIcebergReader(config).Refs(schemaTable)
+		testNoError(t, err)
+		snapshotId := icebergRefs[ICEBERG_MAIN_BRANCH].SnapshotId
+		tags := []string{"audit-1", "audit-2", "audit-3", "audit-4"}
+
+		// Each storage commits without the in-process table lock, like separate BemiDB processes
+		var waitGroup sync.WaitGroup
+		errs := make([]error, len(tags))
+		for i, tag := range tags {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				errs[i] = NewStorage(config).UpdateIcebergRef(schemaTable, tag, IcebergRef{SnapshotId: snapshotId, Type: ICEBERG_REF_TYPE_TAG})
+			}()
+		}
+		waitGroup.Wait()
+
+		for _, err := range errs {
+			testNoError(t, err)
+		}
+		icebergRefs, err = NewIcebergReader(config).Refs(schemaTable)
+		testNoError(t, err)
+		for _, tag := range tags {
+			if icebergRefs[tag].SnapshotId != snapshotId {
+				t.Errorf("Expected the %s tag to be kept, got %v", tag, icebergRefs)
+			}
+		}
+		metadataFilePath := NewIcebergReader(config).MetadataFilePath(schemaTable)
+		if filepath.Base(metadataFilePath) != IcebergMetadataFileName(int64(1+len(tags))) {
+			t.Errorf("Expected each commit to create a metadata version, got %s", metadataFilePath)
+		}
+	})
+
+	t.Run("Reads and commits versions created after the version hint", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}})
+		icebergWriter := NewIcebergWriter(config)
+		err := icebergWriter.CreateTag(schemaTable, "audit-1", ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+		metadataDirPath := filepath.Dir(NewIcebergReader(config).MetadataFilePath(schemaTable))
+		// A slower writer of an older version may overwrite the version hint last
+		err = os.WriteFile(filepath.Join(metadataDirPath, VERSION_HINT_FILE_NAME), []byte("1"), 0644)
+		testNoError(t, err)
+
+		err = icebergWriter.CreateTag(schemaTable, "audit-2", ICEBERG_MAIN_BRANCH)
+
+		testNoError(t, err)
+		metadataFilePath := NewIcebergReader(config).MetadataFilePath(schemaTable)
+		if metadataFilePath != filepath.Join(metadataDirPath, IcebergMetadataFileName(3)) {
+			t.Errorf("Expected the metadata file of version 3, got %s", metadataFilePath)
+		}
+		icebergRefs, err := NewIcebergReader(config).Refs(schemaTable)
+		testNoError(t, err)
+		if _, ok := icebergRefs["audit-1"]; !ok {
+			t.Errorf("Expected the tag committed after the version hint to be kept, got %v", icebergRefs)
+		}
+	})
+
+	t.Run("Commits after the latest metadata file if the version hint is empty", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}})
+		icebergWriter := NewIcebergWriter(config)
+		err := icebergWriter.CreateTag(schemaTable, "audit-1", ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+		metadataDirPath := filepath.Dir(NewIcebergReader(config).MetadataFilePath(schemaTable))
+		err = os.WriteFile(filepath.Join(metadataDirPath, VERSION_HINT_FILE_NAME), []byte(""), 0644)
+		testNoError(t, err)
+
+		err = icebergWriter.CreateTag(schemaTable, "audit-2", ICEBERG_MAIN_BRANCH)
+
+		testNoError(t, err)
+		metadataFilePath := NewIcebergReader(config).MetadataFilePath(schemaTable)
+		if metadataFilePath != filepath.Join(metadataDirPath, IcebergMetadataFileName(3)) {
+			t.Errorf("Expected the metadata file of version 3, got %s", metadataFilePath)
+		}
+		entries, err := os.ReadDir(metadataDirPath)
+		testNoError(t, err)
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".tmp") {
+				t.Errorf("Expected no temporary files to be left, got %s", entry.Name())
+			}
+		}
+	})
+}
+
+func TestIcebergWriterParquetTableSettings(t *testing.T) {
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	t.Run("Writes tables with their own compression codecs and row group sizes", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		config.Parquet.TableCompressions = map[string]string{"public.test_snappy_table": "snappy"}
+		config.Parquet.TableRowGroupSizes = map[string]int64{"public.test_snappy_table": 1024}
+		rows := [][]string{}
+		for i := range 10000 {
+			rows = append(rows, []string{strconv.Itoa(i)})
+		}
+
+		testWriteCatalogTable(config, IcebergSchemaTable{Schema: "public", Table: "test_snappy_table"}, pgSchemaColumns, rows)
+		testWriteCatalogTable(config, IcebergSchemaTable{Schema: "public", Table: "test_zstd_table"}, pgSchemaColumns, rows)
+
+		duckdb := NewDuckdb(config)
+		defer duckdb.Close()
+		for table, expected := range map[string]struct {
+			codec     string
+			rowGroups bool
+		}{
+			"test_snappy_table": {codec: "SNAPPY", rowGroups: true},
+			"test_zstd_table":   {codec: "ZSTD", rowGroups: false},
+		} {
+			dataFilePaths, err := filepath.Glob(filepath.Join(config.StoragePath, "public", table, "data", "*.parquet"))
+			testNoError(t, err)
+			if len(dataFilePaths) != 1 {
+				t.Fatalf("Expected a data file of %s, got %v", table, dataFilePaths)
+			}
+
+			rows, err := duckdb.QueryContext(context.Background(), "SELECT MIN(compression), COUNT(DISTINCT row_group_id) FROM parquet_metadata('"+dataFilePaths[0]+"')")
+			testNoError(t, err)
+			var codec string
+			var rowGroupCount int
+			rows.Next()
+			testNoError(t, rows.Scan(&codec, &rowGroupCount))
+			rows.Close()
+			if codec != expected.codec {
+				t.Errorf("Expected %s codec for %s, got %s", expected.codec, table, codec)
+			}
+			if (rowGroupCount > 1) != expected.rowGroups {
+				t.Errorf("Expected multiple row groups for %s to be %v, got %d row group(s)", table, expected.rowGroups, rowGroupCount)
+			}
+		}
+	})
+}
+
+func TestIcebergWriterSnapshotLog(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_snapshot_log_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	t.Run("Adds a snapshot per sync and keeps the previous ones", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}, {"2"}})
+		firstSnapshotId, err := NewIcebergReader(config).RefSnapshotId(schemaTable, ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"3"}})
+
+		metadataFilePath := NewIcebergReader(config).MetadataFilePath(schemaTable)
+		if filepath.Base(metadataFilePath) != IcebergMetadataFileName(2) {
+			t.Errorf("Expected the second sync to create metadata version 2, got %s", metadataFilePath)
+		}
+		versionHintContent, err := os.ReadFile(filepath.Join(filepath.Dir(metadataFilePath), VERSION_HINT_FILE_NAME))
+		testNoError(t, err)
+		if string(versionHintContent) != "2" {
+			t.Errorf("Expected the version hint to point at version 2, got %s", versionHintContent)
+		}
+		metadataContent, err := os.ReadFile(metadataFilePath)
+		testNoError(t, err)
+		var metadata struct {
+			CurrentSnapshotId int64 `json:"current-snapshot-id"`
+			Snapshots         []struct {
+				SnapshotId       int64             `json:"snapshot-id"`
+				ParentSnapshotId int64             `json:"parent-snapshot-id"`
+				Summary          map[string]string `json:"summary"`
+			} `json:"snapshots"`
+			SnapshotLog []struct {
+				SnapshotId int64 `json:"snapshot-id"`
+			} `json:"snapshot-log"`
+		}
+		err = json.Unmarshal(metadataContent, &metadata)
+		testNoError(t, err)
+		secondSnapshotId, err := NewIcebergReader(config).RefSnapshotId(schemaTable, ICEBERG_MAIN_BRANCH)
+		testNoError(t, err)
+		if metadata.CurrentSnapshotId != secondSnapshotId || secondSnapshotId == firstSnapshotId {
+			t.Errorf("Expected the main branch and the current snapshot to be the new snapshot, got %v and %v", secondSnapshotId, metadata.CurrentSnapshotId)
+		}
+		if len(metadata.Snapshots) != 2 || metadata.Snapshots[1].ParentSnapshotId != firstSnapshotId {
+			t.Fatalf("Expected the new snapshot to follow the first one, got %+v", metadata.Snapshots)
+		}
+		if metadata.Snapshots[0].Summary["operation"] != "append" {
+			t.Errorf("Expected the first snapshot to be an append, got %v", metadata.Snapshots[0].Summary)
+		}
+		secondSummary := metadata.Snapshots[1].Summary
+		if secondSummary["operation"] != "overwrite" || secondSummary["deleted-data-files"] != "1" || secondSummary["deleted-records"] != "2" || secondSummary["total-records"] != "1" {
+			t.Errorf("Expected the second snapshot to overwrite the rows of the first one, got %v", secondSummary)
+		}
+		if len(metadata.SnapshotLog) != 2 || metadata.SnapshotLog[0].SnapshotId != firstSnapshotId || metadata.SnapshotLog[1].SnapshotId != secondSnapshotId {
+			t.Errorf("Expected both snapshots in the snapshot log, got %+v", metadata.SnapshotLog)
+		}
+		testSnapshotRecordCount(t, metadataFilePath, firstSnapshotId, 2)
+		testSnapshotRecordCount(t, metadataFilePath, secondSnapshotId, 1)
+		manifestListRecords := testManifestListRecords(t, *config, schemaTable, ICEBERG_MAIN_BRANCH)
+		if len(manifestListRecords) != 1 {
+			t.Errorf("Expected the new snapshot to only have the manifest of the synced rows, got %v", manifestListRecords)
+		}
+	})
+
+	t.Run("Adds the schema and partition spec of a sync if they've changed", func(t *testing.T) {
+		config := testCatalogConfig(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1"}})
+		config.IcebergPartitions = map[string]IcebergPartitionColumn{"public.test_snapshot_log_table": {ColumnName: "id", Transform: ICEBERG_TRANSFORM_IDENTITY}}
+		changedPgSchemaColumns := append(slices.Clone(pgSchemaColumns), PgSchemaColumn{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"})
+
+		testWriteCatalogTable(config, schemaTable, changedPgSchemaColumns, [][]string{{"1", "one"}})
+
+		icebergPartitionSpecs, err := NewIcebergReader(config).PartitionSpecs(schemaTable)
+		testNoError(t, err)
+		if len(icebergPartitionSpecs) != 2 || len(icebergPartitionSpecs[0].Fields) != 0 || icebergPartitionSpecs[1].SpecId != 1 || icebergPartitionSpecs[1].Fields[0].Transform != ICEBERG_TRANSFORM_IDENTITY {
+			t.Errorf("Expected an unpartitioned and an identity partition spec, got %+v", icebergPartitionSpecs)
+		}
+		metadataContent, err := os.ReadFile(NewIcebergReader(config).MetadataFilePath(schemaTable))
+		testNoError(t, err)
+		var metadata struct {
+			CurrentSchemaId int `json:"current-schema-id"`
+			DefaultSpecId   int `json:"default-spec-id"`
+		}
+		err = json.Unmarshal(metadataContent, &metadata)
+		testNoError(t, err)
+		if metadata.CurrentSchemaId != 1 || metadata.DefaultSpecId != 1 {
+			t.Errorf("Expected the new schema and partition spec to be current, got %+v", metadata)
+		}
+		manifestListRecords := testManifestListRecords(t, *config, schemaTable, ICEBERG_MAIN_BRANCH)
+		if len(manifestListRecords) != 1 || manifestListRecords[0]["partition_spec_id"] != int32(1) {
+			t.Errorf("Expected the new snapshot to use the new partition spec, got %v", manifestListRecords)
+		}
+	})
+}
+
+func testTableFilePaths(t *testing.T, tablePath string) (filePaths []string) {
+	err := filepath.WalkDir(tablePath, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			filePaths = append(filePaths, path)
+		}
+		return err
+	})
+	testNoError(t, err)
+	slices.Sort(filePaths)
+	return filePaths
+}
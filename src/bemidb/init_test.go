@@ -1,4 +1,4 @@
-package main
+package bemidb
 
 import (
 	"flag"
@@ -343,6 +343,9 @@ func init() {
 		TEST_PG_SCHEMA_COLUMNS[i].IsNullable = "YES"
 	}
 
+	// Syncs add snapshots to existing tables, so the table is recreated to start each test run with a single snapshot
+	icebergWriter.DeleteSchemaTable(IcebergSchemaTable{Schema: "public", Table: "test_table"})
+
 	i := 0
 	icebergWriter.Write(
 		IcebergSchemaTable{Schema: "public", Table: "test_table"},
@@ -365,7 +368,7 @@ func loadTestConfig() *Config {
 	config.User = "bemidb"
 	config.EncryptedPassword = "bemidb-encrypted"
 	config.StorageType = STORAGE_TYPE_LOCAL
-	config.StoragePath = "../iceberg-test"
+	config.StoragePath = "../../iceberg-test"
 	config.LogLevel = "ERROR"
 
 	return config
@@ -0,0 +1,111 @@
+package bemidb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Whether the JSON value contains the template like jsonb's @> operator: objects contain a subset of the template's keys with
+// contained values, arrays contain each template element in any order. Only a top-level array can contain a scalar template,
+// e.g. '[1, 2]' @> '1' is true but '{"a": [1, 2]}' @> '{"a": 1}' is false
+func JsonbContains(value string, template string) (bool, error) {
+	decodedValue, err := decodeJsonb(value)
+	if err != nil {
+		return false, err
+	}
+	decodedTemplate, err := decodeJsonb(template)
+	if err != nil {
+		return false, err
+	}
+
+	if valueArray, ok := decodedValue.([]interface{}); ok && isJsonbScalar(decodedTemplate) {
+		return jsonbArrayContainsScalar(valueArray, decodedTemplate), nil
+	}
+	return jsonbContains(decodedValue, decodedTemplate), nil
+}
+
+func jsonbContains(value interface{}, template interface{}) bool {
+	switch template := template.(type) {
+	case map[string]interface{}:
+		valueObject, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, templateValue := range template {
+			objectValue, ok := valueObject[key]
+			if !ok || !jsonbContains(objectValue, templateValue) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		valueArray, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, templateElement := range template {
+			if isJsonbScalar(templateElement) {
+				if !jsonbArrayContainsScalar(valueArray, templateElement) {
+					return false
+				}
+				continue
+			}
+
+			contained := false
+			for _, element := range valueArray {
+				if !isJsonbScalar(element) && jsonbContains(element, templateElement) {
+					contained = true
+					break
+				}
+			}
+			if !contained {
+				return false
+			}
+		}
+		return true
+	default:
+		return isJsonbScalar(value) && jsonbScalarsEqual(value, template)
+	}
+}
+
+func jsonbArrayContainsScalar(valueArray []interface{}, scalar interface{}) bool {
+	for _, element := range valueArray {
+		if isJsonbScalar(element) && jsonbScalarsEqual(element, scalar) {
+			return true
+		}
+	}
+	return false
+}
+
+// Numbers are compared by value like jsonb's numeric, e.g. 1 = 1.0
+func jsonbScalarsEqual(a interface{}, b interface{}) bool {
+	numberA, okA := a.(json.Number)
+	numberB, okB := b.(json.Number)
+	if okA && okB {
+		ratA, okA := new(big.Rat).SetString(numberA.String())
+		ratB, okB := new(big.Rat).SetString(numberB.String())
+		return okA && okB && ratA.Cmp(ratB) == 0
+	}
+
+	return a == b
+}
+
+func isJsonbScalar(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+func decodeJsonb(text string) (value interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input syntax for type json: %v", err)
+	}
+	return value, nil
+}
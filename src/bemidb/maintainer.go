@@ -0,0 +1,103 @@
+package bemidb
+
+import (
+	"time"
+)
+
+// Maintainer periodically compacts fragmented Iceberg tables and expires their old snapshots while no sync is running
+type Maintainer struct {
+	config          *Config
+	icebergReader   *IcebergReader
+	compactTable    func(icebergSchemaTable IcebergSchemaTable) error
+	expireSnapshots func(icebergSchemaTable IcebergSchemaTable) error
+}
+
+func NewMaintainer(config *Config) *Maintainer {
+	icebergWriter := NewIcebergWriter(config)
+
+	return &Maintainer{
+		config:          config,
+		icebergReader:   NewIcebergReader(config),
+		compactTable:    icebergWriter.CompactTable,
+		expireSnapshots: icebergWriter.ExpireSnapshots,
+	}
+}
+
+func (maintainer *Maintainer) Run() {
+	interval, err := time.ParseDuration(maintainer.config.Maintenance.Interval)
+	PanicIfError(err)
+
+	LogInfo(maintainer.config, "Starting maintenance loop with interval:", maintainer.config.Maintenance.Interval)
+	for {
+		time.Sleep(interval)
+
+		if IsSyncInProgress() {
+			LogDebug(maintainer.config, "Skipping maintenance while syncing...")
+			continue
+		}
+
+		maintainer.MaintainTables()
+	}
+}
+
+func (maintainer *Maintainer) MaintainTables() {
+	icebergSchemaTables, err := maintainer.icebergReader.SchemaTables()
+	if err != nil {
+		LogError(maintainer.config, "Couldn't list Iceberg tables for maintenance:", err)
+		return
+	}
+
+	for _, icebergSchemaTable := range icebergSchemaTables {
+		if IsSyncInProgress() {
+			LogDebug(maintainer.config, "Stopping maintenance while syncing...")
+			return
+		}
+
+		_, err := maintainer.maintainTable(icebergSchemaTable)
+		if err != nil {
+			LogError(maintainer.config, "Couldn't maintain", icebergSchemaTable.String()+":", err)
+		}
+	}
+}
+
+// Returns true if the table was fragmented and has been maintained
+func (maintainer *Maintainer) maintainTable(icebergSchemaTable IcebergSchemaTable) (maintained bool, err error) {
+	unlock := LockIcebergSchemaTable(icebergSchemaTable)
+	defer unlock()
+
+	icebergTableStats, err := maintainer.icebergReader.TableStats(icebergSchemaTable)
+	if err != nil {
+		return false, err
+	}
+
+	if !maintainer.isFragmented(icebergTableStats) {
+		LogDebug(maintainer.config, "Skipping maintenance of", icebergSchemaTable.String())
+		return false, nil
+	}
+
+	LogInfo(maintainer.config, "Compacting", icebergSchemaTable.String(), "with", icebergTableStats.DataFileCount, "data file(s)...")
+	err = maintainer.compactTable(icebergSchemaTable)
+	if err != nil {
+		return false, err
+	}
+
+	LogInfo(maintainer.config, "Expiring snapshots of", icebergSchemaTable.String(), "...")
+	err = maintainer.expireSnapshots(icebergSchemaTable)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (maintainer *Maintainer) isFragmented(icebergTableStats IcebergTableStats) bool {
+	if icebergTableStats.DataFileCount <= 1 {
+		return false
+	}
+
+	if icebergTableStats.DataFileCount > int64(maintainer.config.Maintenance.MaxDataFiles) {
+		return true
+	}
+
+	return icebergTableStats.FilesSize/icebergTableStats.DataFileCount < maintainer.config.Maintenance.MinAvgFileSize
+}
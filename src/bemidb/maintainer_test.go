@@ -0,0 +1,112 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMaintainTable(t *testing.T) {
+	t.Run("Compacts and expires snapshots of a fragmented table", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_fragmented_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		overrideTestTableSummary(t, config, schemaTable, "20", "20480")
+		maintainer, maintainedTables := initTestMaintainer(config)
+
+		maintained, err := maintainer.maintainTable(schemaTable)
+
+		testNoError(t, err)
+		if !maintained {
+			t.Error("Expected the fragmented table to be maintained")
+		}
+		if len(*maintainedTables) != 2 || (*maintainedTables)[0] != "compact "+schemaTable.String() || (*maintainedTables)[1] != "expire "+schemaTable.String() {
+			t.Errorf("Expected compaction and snapshot expiration, got %v", *maintainedTables)
+		}
+	})
+
+	t.Run("Skips a healthy table", func(t *testing.T) {
+		config := loadTestConfig()
+		maintainer, maintainedTables := initTestMaintainer(config)
+
+		maintained, err := maintainer.maintainTable(IcebergSchemaTable{Schema: "public", Table: "test_table"})
+
+		testNoError(t, err)
+		if maintained {
+			t.Error("Expected the healthy table to be skipped")
+		}
+		if len(*maintainedTables) != 0 {
+			t.Errorf("Expected no maintenance, got %v", *maintainedTables)
+		}
+	})
+
+	t.Run("Waits for a commit to the same table", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_table"}
+		maintainer, _ := initTestMaintainer(config)
+
+		unlock := LockIcebergSchemaTable(schemaTable)
+		done := make(chan bool)
+		go func() {
+			maintainer.maintainTable(schemaTable)
+			done <- true
+		}()
+
+		select {
+		case <-done:
+			t.Error("Expected maintenance to wait for the table lock")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		unlock()
+		<-done
+	})
+}
+
+func initTestMaintainer(config *Config) (*Maintainer, *[]string) {
+	maintainedTables := &[]string{}
+	maintainer := NewMaintainer(config)
+	maintainer.compactTable = func(icebergSchemaTable IcebergSchemaTable) error {
+		*maintainedTables = append(*maintainedTables, "compact "+icebergSchemaTable.String())
+		return nil
+	}
+	maintainer.expireSnapshots = func(icebergSchemaTable IcebergSchemaTable) error {
+		*maintainedTables = append(*maintainedTables, "expire "+icebergSchemaTable.String())
+		return nil
+	}
+	return maintainer, maintainedTables
+}
+
+func writeTestTable(icebergWriter *IcebergWriter, schemaTable IcebergSchemaTable) {
+	loaded := false
+	icebergWriter.Write(schemaTable, TEST_PG_SCHEMA_COLUMNS, func() [][]string {
+		if loaded {
+			return [][]string{}
+		}
+		loaded = true
+		return TEST_LOADED_ROWS
+	})
+}
+
+// Simulates a table written as many small data files
+func overrideTestTableSummary(t *testing.T, config *Config, schemaTable IcebergSchemaTable, totalDataFiles string, totalFilesSize string) {
+	metadataFilePath := NewIcebergReader(config).MetadataFilePath(schemaTable)
+	metadataContent, err := os.ReadFile(metadataFilePath)
+	testNoError(t, err)
+
+	var metadata map[string]interface{}
+	err = json.Unmarshal(metadataContent, &metadata)
+	testNoError(t, err)
+
+	summary := metadata["snapshots"].([]interface{})[0].(map[string]interface{})["summary"].(map[string]interface{})
+	summary["total-data-files"] = totalDataFiles
+	summary["total-files-size"] = totalFilesSize
+
+	metadataContent, err = json.Marshal(metadata)
+	testNoError(t, err)
+	err = os.WriteFile(metadataFilePath, metadataContent, 0644)
+	testNoError(t, err)
+}
@@ -0,0 +1,69 @@
+package bemidb
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	PARQUET_BATCH_RETRY_BACKOFF = 1 * time.Second // doubled after each retry
+)
+
+// Batch of synced rows left out of a table since it couldn't be written to Parquet
+type SkippedParquetBatch struct {
+	SchemaTable IcebergSchemaTable
+	BatchNumber int // 1-based, in the order returned by loadRows
+	RowCount    int
+	Error       string
+}
+
+func (skippedBatch SkippedParquetBatch) String() string {
+	return fmt.Sprintf("%s batch %d (%d row(s)): %s", skippedBatch.SchemaTable.String(), skippedBatch.BatchNumber, skippedBatch.RowCount, skippedBatch.Error)
+}
+
+type ParquetBatchRetrier struct {
+	config  *Config
+	backoff time.Duration
+	sleep   func(time.Duration)
+}
+
+func NewParquetBatchRetrier(config *Config) *ParquetBatchRetrier {
+	return &ParquetBatchRetrier{config: config, backoff: PARQUET_BATCH_RETRY_BACKOFF, sleep: time.Sleep}
+}
+
+// Calls writeBatch until it succeeds or runs out of retries, waiting twice as long before each retry.
+// A batch that still fails is returned as skipped if failed batches are skipped, so one bad batch doesn't fail the whole table
+func (retrier *ParquetBatchRetrier) Write(batchNumber int, rowCount int, writeBatch func() error) (skippedBatch *SkippedParquetBatch, err error) {
+	backoff := retrier.backoff
+	for retry := 0; ; retry++ {
+		err = retrier.recoverWrite(writeBatch)
+		if err == nil {
+			return nil, nil
+		}
+		if retry == retrier.config.Pg.SyncBatchRetries {
+			break
+		}
+
+		LogWarn(retrier.config, "Failed to write batch", batchNumber, "to Parquet, retrying in", backoff.String()+":", err)
+		retrier.sleep(backoff)
+		backoff *= 2
+	}
+
+	if !retrier.config.Pg.SyncSkipFailedBatches {
+		return nil, fmt.Errorf("Failed to write batch %d to Parquet: %v", batchNumber, err)
+	}
+
+	LogWarn(retrier.config, "Skipping batch", batchNumber, "with", rowCount, "row(s) that failed to be written to Parquet:", err)
+	return &SkippedParquetBatch{BatchNumber: batchNumber, RowCount: rowCount, Error: err.Error()}, nil
+}
+
+// Values that can't be converted to Parquet panic, e.g. a non-numeric integer
+func (retrier *ParquetBatchRetrier) recoverWrite(writeBatch func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return writeBatch()
+}
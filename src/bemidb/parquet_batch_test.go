@@ -0,0 +1,115 @@
+package bemidb
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParquetBatchRetrier(t *testing.T) {
+	t.Run("Retries a batch with backoff until it succeeds", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.Pg.SyncBatchRetries = 2
+		retrier, sleeps := testParquetBatchRetrier(&config)
+		attempts := 0
+
+		skippedBatch, err := retrier.Write(1, 10, func() error {
+			attempts++
+			if attempts <= 2 {
+				return errors.New("connection reset")
+			}
+			return nil
+		})
+
+		testNoError(t, err)
+		if skippedBatch != nil {
+			t.Errorf("Expected the batch not to be skipped, got %v", skippedBatch)
+		}
+		if attempts != 3 {
+			t.Errorf("Expected 3 attempts, got %d", attempts)
+		}
+		if !slices.Equal(*sleeps, []time.Duration{time.Second, 2 * time.Second}) {
+			t.Errorf("Expected backoffs of [1s 2s], got %v", *sleeps)
+		}
+	})
+
+	t.Run("Skips a batch that persistently fails when failed batches are skipped", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.Pg.SyncBatchRetries = 2
+		config.Pg.SyncSkipFailedBatches = true
+		retrier, _ := testParquetBatchRetrier(&config)
+		attempts := 0
+
+		skippedBatch, err := retrier.Write(3, 10, func() error {
+			attempts++
+			panic("strconv.ParseInt: parsing \"abc\": invalid syntax")
+		})
+
+		testNoError(t, err)
+		if attempts != 3 {
+			t.Errorf("Expected 3 attempts, got %d", attempts)
+		}
+		if skippedBatch == nil || skippedBatch.BatchNumber != 3 || skippedBatch.RowCount != 10 || skippedBatch.Error != "strconv.ParseInt: parsing \"abc\": invalid syntax" {
+			t.Errorf("Expected batch 3 with 10 rows to be skipped, got %v", skippedBatch)
+		}
+	})
+
+	t.Run("Returns an error for a batch that persistently fails by default", func(t *testing.T) {
+		config := *loadTestConfig()
+		retrier, _ := testParquetBatchRetrier(&config)
+
+		_, err := retrier.Write(1, 10, func() error {
+			return errors.New("connection reset")
+		})
+
+		if err == nil || err.Error() != "Failed to write batch 1 to Parquet: connection reset" {
+			t.Errorf("Expected an error for batch 1, got %v", err)
+		}
+	})
+}
+
+func TestIcebergWriterSkippedBatches(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_skipped_batches_table"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+
+	t.Run("Writes the table without a batch that persistently fails", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.Pg.SyncBatchRetries = 0
+		config.Pg.SyncSkipFailedBatches = true
+		icebergWriter := NewIcebergWriter(&config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		batches := [][][]string{{{"1"}, {"2"}}, {{"3"}, {"abc"}}, {{"5"}}}
+
+		icebergWriter.Write(schemaTable, pgSchemaColumns, func() [][]string {
+			if len(batches) == 0 {
+				return [][]string{}
+			}
+			rows := batches[0]
+			batches = batches[1:]
+			return rows
+		})
+
+		icebergTableStats, err := NewIcebergReader(&config).TableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != 3 {
+			t.Errorf("Expected 3 records, got %v", icebergTableStats.RecordCount)
+		}
+		skippedBatches := icebergWriter.TakeSkippedParquetBatches()
+		if len(skippedBatches) != 1 || skippedBatches[0].SchemaTable != schemaTable || skippedBatches[0].BatchNumber != 2 || skippedBatches[0].RowCount != 2 {
+			t.Errorf("Expected batch 2 of %v to be skipped, got %v", schemaTable, skippedBatches)
+		}
+		if len(icebergWriter.TakeSkippedParquetBatches()) != 0 {
+			t.Errorf("Expected skipped batches to be reported once")
+		}
+	})
+}
+
+func testParquetBatchRetrier(config *Config) (*ParquetBatchRetrier, *[]time.Duration) {
+	sleeps := []time.Duration{}
+	retrier := NewParquetBatchRetrier(config)
+	retrier.sleep = func(duration time.Duration) { sleeps = append(sleeps, duration) }
+	return retrier, &sleeps
+}
@@ -0,0 +1,106 @@
+package bemidb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Subset of *pgx.Conn kept in a PgConnPool
+type PgPoolConn interface {
+	PgSnapshotConn
+	Ping(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// Bounded pool of source database connections shared by sync workers.
+// Released connections are reused after a health check instead of paying the connection setup for each table
+type PgConnPool struct {
+	config    *Config
+	connect   func(ctx context.Context) (PgPoolConn, error)
+	slots     chan struct{} // one per connection that is acquired or being opened
+	idleConns chan PgPoolConn
+	mutex     sync.Mutex
+	openConns []PgPoolConn
+}
+
+func NewPgConnPool(config *Config, size int, connect func(ctx context.Context) (PgPoolConn, error)) *PgConnPool {
+	return &PgConnPool{
+		config:    config,
+		connect:   connect,
+		slots:     make(chan struct{}, size),
+		idleConns: make(chan PgPoolConn, size),
+	}
+}
+
+func (pool *PgConnPool) Size() int {
+	return cap(pool.slots)
+}
+
+// Waits until fewer than Size() connections are acquired, then returns an idle connection or opens a new one
+func (pool *PgConnPool) Acquire(ctx context.Context) (conn PgPoolConn, err error) {
+	select {
+	case pool.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case conn = <-pool.idleConns:
+		err = conn.Ping(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		LogWarn(pool.config, "Replacing a source connection that failed the health check:", err)
+		pool.closeConn(ctx, conn)
+	default:
+	}
+
+	conn, err = pool.connect(ctx)
+	if err != nil {
+		<-pool.slots
+		return nil, fmt.Errorf("Failed to connect to the source database: %v", err)
+	}
+
+	pool.mutex.Lock()
+	pool.openConns = append(pool.openConns, conn)
+	pool.mutex.Unlock()
+
+	return conn, nil
+}
+
+func (pool *PgConnPool) Release(conn PgPoolConn) {
+	pool.idleConns <- conn
+	<-pool.slots
+}
+
+// Closes a connection that can't be reused, e.g. after its transaction was aborted by a failed query
+func (pool *PgConnPool) Discard(conn PgPoolConn) {
+	pool.closeConn(context.Background(), conn)
+	<-pool.slots
+}
+
+// Closes all connections, which must be released first
+func (pool *PgConnPool) Close(ctx context.Context) {
+	pool.mutex.Lock()
+	openConns := pool.openConns
+	pool.openConns = nil
+	pool.mutex.Unlock()
+
+	for _, conn := range openConns {
+		conn.Close(ctx)
+	}
+}
+
+func (pool *PgConnPool) closeConn(ctx context.Context, conn PgPoolConn) {
+	conn.Close(ctx)
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for i, openConn := range pool.openConns {
+		if openConn == conn {
+			pool.openConns = append(pool.openConns[:i], pool.openConns[i+1:]...)
+			break
+		}
+	}
+}
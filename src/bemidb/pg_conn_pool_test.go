@@ -0,0 +1,167 @@
+package bemidb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPgConnPool(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reuses released connections", func(t *testing.T) {
+		pool, openedConns := testPgConnPool(2)
+
+		conn1, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		pool.Release(conn1)
+		conn2, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		pool.Release(conn2)
+
+		if conn1 != conn2 {
+			t.Errorf("Expected the released connection to be reused")
+		}
+		if len(*openedConns) != 1 || conn1.(*testPgPoolConn).pings != 1 {
+			t.Errorf("Expected 1 opened connection with 1 health check, got %v", *openedConns)
+		}
+	})
+
+	t.Run("Caps concurrent connections at the pool size", func(t *testing.T) {
+		pool, openedConns := testPgConnPool(3)
+		var acquiredCount, maxAcquiredCount atomic.Int32
+
+		var waitGroup sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				conn, err := pool.Acquire(ctx)
+				testNoError(t, err)
+
+				acquired := acquiredCount.Add(1)
+				for {
+					maxAcquired := maxAcquiredCount.Load()
+					if acquired <= maxAcquired || maxAcquiredCount.CompareAndSwap(maxAcquired, acquired) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				acquiredCount.Add(-1)
+
+				pool.Release(conn)
+			}()
+		}
+		waitGroup.Wait()
+
+		if maxAcquiredCount.Load() != 3 {
+			t.Errorf("Expected at most 3 connections to be acquired at a time, got %v", maxAcquiredCount.Load())
+		}
+		if len(*openedConns) > 3 {
+			t.Errorf("Expected at most 3 opened connections, got %v", len(*openedConns))
+		}
+	})
+
+	t.Run("Waits for a released connection until the context is done", func(t *testing.T) {
+		pool, _ := testPgConnPool(1)
+		conn, err := pool.Acquire(ctx)
+		testNoError(t, err)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err = pool.Acquire(timeoutCtx)
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected a deadline exceeded error, got %v", err)
+		}
+		pool.Release(conn)
+	})
+
+	t.Run("Replaces a connection that fails the health check", func(t *testing.T) {
+		pool, openedConns := testPgConnPool(1)
+		conn1, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		conn1.(*testPgPoolConn).pingErr = errors.New("connection reset by peer")
+		pool.Release(conn1)
+
+		conn2, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		pool.Release(conn2)
+
+		if conn1 == conn2 || !conn1.(*testPgPoolConn).closed {
+			t.Errorf("Expected the unhealthy connection to be closed and replaced")
+		}
+		if len(*openedConns) != 2 {
+			t.Errorf("Expected 2 opened connections, got %v", len(*openedConns))
+		}
+	})
+
+	t.Run("Replaces a discarded connection", func(t *testing.T) {
+		pool, openedConns := testPgConnPool(1)
+		conn1, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		pool.Discard(conn1)
+
+		conn2, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		pool.Release(conn2)
+
+		if conn1 == conn2 || !conn1.(*testPgPoolConn).closed {
+			t.Errorf("Expected the discarded connection to be closed and replaced")
+		}
+		if len(*openedConns) != 2 {
+			t.Errorf("Expected 2 opened connections, got %v", len(*openedConns))
+		}
+	})
+
+	t.Run("Closes all connections", func(t *testing.T) {
+		pool, openedConns := testPgConnPool(2)
+		conn1, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		conn2, err := pool.Acquire(ctx)
+		testNoError(t, err)
+		pool.Release(conn1)
+		pool.Release(conn2)
+
+		pool.Close(ctx)
+
+		for i, conn := range *openedConns {
+			if !conn.closed {
+				t.Errorf("Expected connection %v to be closed", i)
+			}
+		}
+	})
+}
+
+func testPgConnPool(size int) (pool *PgConnPool, openedConns *[]*testPgPoolConn) {
+	openedConns = &[]*testPgPoolConn{}
+	var mutex sync.Mutex
+	pool = NewPgConnPool(&Config{LogLevel: LOG_LEVEL_ERROR}, size, func(ctx context.Context) (PgPoolConn, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		conn := &testPgPoolConn{}
+		*openedConns = append(*openedConns, conn)
+		return conn, nil
+	})
+	return pool, openedConns
+}
+
+type testPgPoolConn struct {
+	testPgSnapshotConn
+	pingErr error
+	pings   int
+	closed  bool
+}
+
+func (conn *testPgPoolConn) Ping(ctx context.Context) error {
+	conn.pings++
+	return conn.pingErr
+}
+
+func (conn *testPgPoolConn) Close(ctx context.Context) error {
+	conn.closed = true
+	return nil
+}
@@ -0,0 +1,476 @@
+package bemidb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+const (
+	PG_DUMP_COPY_DATA_END  = "\\."
+	PG_DUMP_COPY_NULL      = "\\N"
+	PG_DUMP_DEFAULT_SCHEMA = "public"
+)
+
+// Statements that define tables, their enum types or start COPY data sections, other statements in a dump are skipped
+var PG_DUMP_STATEMENT_PREFIXES = []string{"CREATE TYPE ", "CREATE TABLE ", "CREATE UNLOGGED TABLE ", "ALTER TABLE ", "COPY "}
+
+var PG_DUMP_DOLLAR_QUOTE_REGEX = regexp.MustCompile(`\$([A-Za-z_][A-Za-z_0-9]*)?\$`)
+
+type PgDumpTable struct {
+	PgSchemaTable   PgSchemaTable
+	PgSchemaColumns []PgSchemaColumn
+}
+
+// Reads table definitions and COPY data sections from a plain-format pg_dump file (pg_dump --format=plain)
+type PgDumpReader struct {
+	reader     *bufio.Reader
+	lineNumber int
+
+	// Tables by PgSchemaTable.String(), read from CREATE TABLE and ALTER TABLE statements
+	pgDumpTables map[string]*PgDumpTable
+	// Enum labels by schema.type, pg_dump creates types before the tables that use them
+	enumLabels map[string][]string
+	// Definitions are read up front when primary keys are added after the data, as pg_dump does
+	definitionsRead bool
+
+	inCopyData     bool
+	dollarQuoteTag string // e.g. $$ while reading a function body
+}
+
+func NewPgDumpReader(reader io.Reader) *PgDumpReader {
+	return &PgDumpReader{
+		reader:       bufio.NewReader(reader),
+		pgDumpTables: map[string]*PgDumpTable{},
+		enumLabels:   map[string][]string{},
+	}
+}
+
+// Reads the dump with table definitions from a previous pass over it
+func NewPgDumpReaderWithTables(reader io.Reader, pgDumpTables map[string]*PgDumpTable) *PgDumpReader {
+	return &PgDumpReader{
+		reader:          bufio.NewReader(reader),
+		pgDumpTables:    pgDumpTables,
+		definitionsRead: true,
+	}
+}
+
+// Reads the whole dump, skipping the data, and returns its table definitions
+func (dumpReader *PgDumpReader) ReadTables() (map[string]*PgDumpTable, error) {
+	for {
+		_, _, err := dumpReader.NextCopy()
+		if err == io.EOF {
+			return dumpReader.pgDumpTables, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Advances to the next COPY data section, skipping the rest of the current one.
+// Returns the copied columns in the order of the data, or io.EOF at the end of the dump
+func (dumpReader *PgDumpReader) NextCopy() (pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn, err error) {
+	for dumpReader.inCopyData {
+		_, err := dumpReader.ReadRows(BATCH_SIZE)
+		if err != nil {
+			return PgSchemaTable{}, nil, err
+		}
+	}
+
+	for {
+		statement, err := dumpReader.nextStatement()
+		if err != nil {
+			return PgSchemaTable{}, nil, err
+		}
+
+		parseResult, err := pgQuery.Parse(statement)
+		if err != nil || len(parseResult.Stmts) != 1 {
+			continue
+		}
+
+		node := parseResult.Stmts[0].Stmt
+		switch {
+		case node.GetCopyStmt() != nil:
+			pgSchemaTable, pgSchemaColumns, err = dumpReader.copyPgSchemaColumns(node.GetCopyStmt())
+			if err != nil {
+				return PgSchemaTable{}, nil, err
+			}
+			dumpReader.inCopyData = true
+			return pgSchemaTable, pgSchemaColumns, nil
+		case dumpReader.definitionsRead:
+			continue
+		case node.GetCreateEnumStmt() != nil:
+			dumpReader.createEnum(node.GetCreateEnumStmt())
+		case node.GetCreateStmt() != nil:
+			dumpReader.createTable(node.GetCreateStmt(), statement)
+		case node.GetAlterTableStmt() != nil:
+			dumpReader.alterTable(node.GetAlterTableStmt(), statement)
+		}
+	}
+}
+
+// Reads up to limit rows from the current COPY data section, returns no rows at its end.
+// Values are decoded from the COPY text format, NULLs are returned as PG_NULL_STRING
+func (dumpReader *PgDumpReader) ReadRows(limit int) ([][]string, error) {
+	var rows [][]string
+
+	for dumpReader.inCopyData && len(rows) < limit {
+		line, err := dumpReader.readLine()
+		if err == io.EOF {
+			return nil, errors.New("Unexpected end of the dump in COPY data")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == PG_DUMP_COPY_DATA_END {
+			dumpReader.inCopyData = false
+			break
+		}
+
+		row := strings.Split(line, "\t")
+		for i, value := range row {
+			row[i] = dumpReader.decodeCopyValue(value)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (dumpReader *PgDumpReader) nextStatement() (string, error) {
+	var statement strings.Builder
+
+	for {
+		line, err := dumpReader.readLine()
+		if err == io.EOF && statement.Len() > 0 {
+			return "", fmt.Errorf("Unexpected end of the dump in statement: %s", statement.String())
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if statement.Len() == 0 && (dumpReader.isInDollarQuote(line) || !dumpReader.isStatementStart(line)) {
+			continue
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		if strings.HasSuffix(strings.TrimRight(line, " "), ";") {
+			return statement.String(), nil
+		}
+	}
+}
+
+func (dumpReader *PgDumpReader) isStatementStart(line string) bool {
+	for _, prefix := range PG_DUMP_STATEMENT_PREFIXES {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines in dollar-quoted function bodies may look like statements, returns whether the line starts in one
+func (dumpReader *PgDumpReader) isInDollarQuote(line string) bool {
+	inDollarQuote := dumpReader.dollarQuoteTag != ""
+
+	for _, tag := range PG_DUMP_DOLLAR_QUOTE_REGEX.FindAllString(line, -1) {
+		if dumpReader.dollarQuoteTag == "" {
+			dumpReader.dollarQuoteTag = tag
+		} else if tag == dumpReader.dollarQuoteTag {
+			dumpReader.dollarQuoteTag = ""
+		}
+	}
+
+	return inDollarQuote
+}
+
+func (dumpReader *PgDumpReader) readLine() (string, error) {
+	line, err := dumpReader.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dumpReader.lineNumber++
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// COPY schema.table (column, ...) FROM stdin;
+func (dumpReader *PgDumpReader) copyPgSchemaColumns(copyStmt *pgQuery.CopyStmt) (PgSchemaTable, []PgSchemaColumn, error) {
+	if !copyStmt.IsFrom || copyStmt.Filename != "" || copyStmt.Relation == nil {
+		return PgSchemaTable{}, nil, fmt.Errorf("Unsupported COPY statement on line %d, expected COPY ... FROM stdin", dumpReader.lineNumber)
+	}
+	if len(copyStmt.Options) > 0 {
+		return PgSchemaTable{}, nil, fmt.Errorf("Unsupported COPY options on line %d, only the text format is supported", dumpReader.lineNumber)
+	}
+
+	pgSchemaTable := dumpReader.pgSchemaTable(copyStmt.Relation)
+	pgDumpTable := dumpReader.pgDumpTables[pgSchemaTable.String()]
+	if pgDumpTable == nil {
+		return PgSchemaTable{}, nil, fmt.Errorf("Missing table definition for %s on line %d", pgSchemaTable.String(), dumpReader.lineNumber)
+	}
+
+	if len(copyStmt.Attlist) == 0 {
+		return pgSchemaTable, pgDumpTable.PgSchemaColumns, nil
+	}
+
+	var pgSchemaColumns []PgSchemaColumn
+	for _, attribute := range copyStmt.Attlist {
+		columnName := attribute.GetString_().Sval
+		pgSchemaColumn, found := dumpReader.pgSchemaColumn(pgDumpTable, columnName)
+		if !found {
+			return PgSchemaTable{}, nil, fmt.Errorf("Missing column definition for %s in %s", columnName, pgSchemaTable.String())
+		}
+		pgSchemaColumns = append(pgSchemaColumns, *pgSchemaColumn)
+	}
+
+	return pgSchemaTable, pgSchemaColumns, nil
+}
+
+// CREATE TYPE schema.type AS ENUM ('label', ...);
+func (dumpReader *PgDumpReader) createEnum(createEnumStmt *pgQuery.CreateEnumStmt) {
+	var labels []string
+	for _, valNode := range createEnumStmt.Vals {
+		labels = append(labels, valNode.GetString_().Sval)
+	}
+	dumpReader.enumLabels[dumpReader.typeName(createEnumStmt.TypeName)] = labels
+}
+
+// CREATE TABLE schema.table (column type [DEFAULT expression] [NOT NULL] [PRIMARY KEY], ..., [PRIMARY KEY (column, ...)]);
+func (dumpReader *PgDumpReader) createTable(createStmt *pgQuery.CreateStmt, statement string) {
+	pgSchemaTable := dumpReader.pgSchemaTable(createStmt.Relation)
+	pgDumpTable := &PgDumpTable{PgSchemaTable: pgSchemaTable}
+	var primaryKeyColumnNames []string
+
+	for tableElementIndex, tableElement := range createStmt.TableElts {
+		if constraint := tableElement.GetConstraint(); constraint != nil {
+			if constraint.Contype == pgQuery.ConstrType_CONSTR_PRIMARY {
+				primaryKeyColumnNames = dumpReader.constraintColumnNames(constraint)
+			}
+			continue
+		}
+
+		columnDef := tableElement.GetColumnDef()
+		if columnDef == nil || columnDef.TypeName == nil {
+			continue
+		}
+
+		pgSchemaColumn := dumpReader.typePgSchemaColumn(columnDef.TypeName)
+		if pgSchemaColumn.DataType != PG_DATA_TYPE_ARRAY {
+			pgSchemaColumn.EnumLabels = dumpReader.enumLabels[dumpReader.typeName(columnDef.TypeName.Names)]
+		}
+		pgSchemaColumn.ColumnName = columnDef.Colname
+		pgSchemaColumn.OrdinalPosition = IntToString(len(pgDumpTable.PgSchemaColumns) + 1)
+		pgSchemaColumn.IsNullable = PG_TRUE
+		pgSchemaColumn.PrimaryKeyPosition = "0"
+		for _, constraintNode := range columnDef.Constraints {
+			switch constraintNode.GetConstraint().Contype {
+			case pgQuery.ConstrType_CONSTR_NOTNULL:
+				pgSchemaColumn.IsNullable = "NO"
+			case pgQuery.ConstrType_CONSTR_PRIMARY:
+				primaryKeyColumnNames = []string{columnDef.Colname}
+			case pgQuery.ConstrType_CONSTR_DEFAULT:
+				pgSchemaColumn.ColumnDefault = dumpReader.columnDefault(statement, createStmt.TableElts[tableElementIndex+1:], columnDef.Constraints, constraintNode.GetConstraint())
+			}
+		}
+		pgDumpTable.PgSchemaColumns = append(pgDumpTable.PgSchemaColumns, pgSchemaColumn)
+	}
+
+	dumpReader.pgDumpTables[pgSchemaTable.String()] = pgDumpTable
+	dumpReader.setPrimaryKey(pgDumpTable, primaryKeyColumnNames)
+}
+
+// ALTER TABLE ONLY schema.table ADD CONSTRAINT name PRIMARY KEY (column, ...);
+// ALTER TABLE ONLY schema.table ALTER COLUMN column SET NOT NULL;
+// ALTER TABLE ONLY schema.table ALTER COLUMN column SET DEFAULT nextval('schema.sequence'::regclass);
+func (dumpReader *PgDumpReader) alterTable(alterTableStmt *pgQuery.AlterTableStmt, statement string) {
+	pgDumpTable := dumpReader.pgDumpTables[dumpReader.pgSchemaTable(alterTableStmt.Relation).String()]
+	if pgDumpTable == nil {
+		return
+	}
+
+	for _, cmdNode := range alterTableStmt.Cmds {
+		cmd := cmdNode.GetAlterTableCmd()
+		switch cmd.Subtype {
+		case pgQuery.AlterTableType_AT_AddConstraint:
+			constraint := cmd.Def.GetConstraint()
+			if constraint != nil && constraint.Contype == pgQuery.ConstrType_CONSTR_PRIMARY {
+				dumpReader.setPrimaryKey(pgDumpTable, dumpReader.constraintColumnNames(constraint))
+			}
+		case pgQuery.AlterTableType_AT_SetNotNull:
+			if pgSchemaColumn, found := dumpReader.pgSchemaColumn(pgDumpTable, cmd.Name); found {
+				pgSchemaColumn.IsNullable = "NO"
+			}
+		case pgQuery.AlterTableType_AT_ColumnDefault:
+			if pgSchemaColumn, found := dumpReader.pgSchemaColumn(pgDumpTable, cmd.Name); found {
+				pgSchemaColumn.ColumnDefault = ""
+				if _, expression, found := strings.Cut(statement, " SET DEFAULT "); found && cmd.Def != nil {
+					pgSchemaColumn.ColumnDefault = strings.TrimSuffix(strings.TrimSpace(expression), ";")
+				}
+			}
+		}
+	}
+}
+
+// Primary key columns are NOT NULL like in information_schema.columns
+func (dumpReader *PgDumpReader) setPrimaryKey(pgDumpTable *PgDumpTable, primaryKeyColumnNames []string) {
+	for i, columnName := range primaryKeyColumnNames {
+		if pgSchemaColumn, found := dumpReader.pgSchemaColumn(pgDumpTable, columnName); found {
+			pgSchemaColumn.PrimaryKeyPosition = IntToString(i + 1)
+			pgSchemaColumn.IsNullable = "NO"
+		}
+	}
+}
+
+// Column attributes like in information_schema.columns, which the live sync reads
+func (dumpReader *PgDumpReader) typePgSchemaColumn(typeName *pgQuery.TypeName) PgSchemaColumn {
+	var names []string
+	for _, nameNode := range typeName.Names {
+		names = append(names, nameNode.GetString_().Sval)
+	}
+
+	namespace := PG_SCHEMA_PG_CATALOG
+	// pg_dump qualifies user-defined types with their schema
+	if len(names) > 1 && names[0] != PG_SCHEMA_PG_CATALOG {
+		namespace = names[0]
+	}
+
+	var typmods []int
+	for _, typmodNode := range typeName.Typmods {
+		if aConst := typmodNode.GetAConst(); aConst != nil && aConst.GetIval() != nil {
+			typmods = append(typmods, int(aConst.GetIval().Ival))
+		}
+	}
+
+	return pgTypeSchemaColumn(names[len(names)-1], namespace, len(typeName.ArrayBounds) > 0, typmods)
+}
+
+// Qualified type name, e.g. public.mood
+func (dumpReader *PgDumpReader) typeName(nameNodes []*pgQuery.Node) string {
+	var names []string
+	for _, nameNode := range nameNodes {
+		names = append(names, nameNode.GetString_().Sval)
+	}
+	if len(names) == 1 {
+		names = append([]string{PG_DUMP_DEFAULT_SCHEMA}, names...)
+	}
+	return strings.Join(names, ".")
+}
+
+func (dumpReader *PgDumpReader) pgSchemaTable(rangeVar *pgQuery.RangeVar) PgSchemaTable {
+	schema := rangeVar.Schemaname
+	if schema == "" {
+		schema = PG_DUMP_DEFAULT_SCHEMA
+	}
+	return PgSchemaTable{Schema: schema, Table: rangeVar.Relname}
+}
+
+func (dumpReader *PgDumpReader) pgSchemaColumn(pgDumpTable *PgDumpTable, columnName string) (*PgSchemaColumn, bool) {
+	for i := range pgDumpTable.PgSchemaColumns {
+		if pgDumpTable.PgSchemaColumns[i].ColumnName == columnName {
+			return &pgDumpTable.PgSchemaColumns[i], true
+		}
+	}
+	return nil, false
+}
+
+// Text of the DEFAULT expression as pg_dump writes it, which is how Postgres formats it in information_schema.columns.
+// It ends where the next constraint of the column or the next column starts
+func (dumpReader *PgDumpReader) columnDefault(statement string, nextTableElements []*pgQuery.Node, columnConstraints []*pgQuery.Node, defaultConstraint *pgQuery.Constraint) string {
+	start := int(defaultConstraint.Location) + len("DEFAULT")
+	end := strings.LastIndex(statement, ")")
+	for _, constraintNode := range columnConstraints {
+		location := int(constraintNode.GetConstraint().Location)
+		if location > start && location < end {
+			end = location
+		}
+	}
+	if len(nextTableElements) > 0 {
+		var location int
+		if columnDef := nextTableElements[0].GetColumnDef(); columnDef != nil {
+			location = int(columnDef.Location)
+		} else {
+			location = int(nextTableElements[0].GetConstraint().Location)
+		}
+		if location > start && location < end {
+			end = location
+		}
+	}
+
+	return strings.TrimSuffix(strings.TrimSpace(statement[start:end]), ",")
+}
+
+func (dumpReader *PgDumpReader) constraintColumnNames(constraint *pgQuery.Constraint) []string {
+	var columnNames []string
+	for _, key := range constraint.Keys {
+		columnNames = append(columnNames, key.GetString_().Sval)
+	}
+	return columnNames
+}
+
+// Decodes backslash escapes of the COPY text format: \b \f \n \r \t \v \\, octal \123 and hex \x7F
+func (dumpReader *PgDumpReader) decodeCopyValue(value string) string {
+	if value == PG_DUMP_COPY_NULL {
+		return PG_NULL_STRING
+	}
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var decoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			decoded.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch char := value[i]; {
+		case char == 'b':
+			decoded.WriteByte('\b')
+		case char == 'f':
+			decoded.WriteByte('\f')
+		case char == 'n':
+			decoded.WriteByte('\n')
+		case char == 'r':
+			decoded.WriteByte('\r')
+		case char == 't':
+			decoded.WriteByte('\t')
+		case char == 'v':
+			decoded.WriteByte('\v')
+		case char >= '0' && char <= '7':
+			digitCount := dumpReader.countDigits(value[i:], 3, "01234567")
+			octal, _ := strconv.ParseUint(value[i:i+digitCount], 8, 8)
+			decoded.WriteByte(byte(octal))
+			i += digitCount - 1
+		case char == 'x' && dumpReader.countDigits(value[i+1:], 2, "0123456789abcdefABCDEF") > 0:
+			digitCount := dumpReader.countDigits(value[i+1:], 2, "0123456789abcdefABCDEF")
+			hex, _ := strconv.ParseUint(value[i+1:i+1+digitCount], 16, 8)
+			decoded.WriteByte(byte(hex))
+			i += digitCount
+		default:
+			decoded.WriteByte(char)
+		}
+	}
+
+	return decoded.String()
+}
+
+func (dumpReader *PgDumpReader) countDigits(value string, maxCount int, digits string) int {
+	count := 0
+	for count < maxCount && count < len(value) && strings.IndexByte(digits, value[count]) >= 0 {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,308 @@
+package bemidb
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+const TEST_PG_DUMP = `--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+SET standard_conforming_strings = on;
+
+CREATE TYPE public.mood AS ENUM (
+    'sad',
+    'happy'
+);
+
+CREATE FUNCTION public.create_audit_table() RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+CREATE TABLE audit (id integer);
+END;
+$$;
+
+--
+-- Name: test_dump_table; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.test_dump_table (
+    id integer NOT NULL,
+    name character varying(255) DEFAULT 'unnamed, ok'::character varying,
+    price numeric(10,2),
+    tags text[],
+    current_mood public.mood,
+    created_at timestamp(3) with time zone DEFAULT now(),
+    "Notes" text DEFAULT ''::text
+);
+
+CREATE TABLE "Other Schema".test_dump_table_skipped (
+    id bigint PRIMARY KEY
+);
+
+--
+-- Data for Name: test_dump_table; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+COPY public.test_dump_table (id, name, price, tags, current_mood, created_at, "Notes") FROM stdin;
+1	Alice	10.50	{a,b}	happy	2024-01-01 12:00:00.123+00	line 1\nline 2\ttabbed
+2	\N	\N	\N	\N	\N	back\\slash \101\x42
+\.
+
+
+COPY "Other Schema".test_dump_table_skipped (id) FROM stdin;
+1
+\.
+
+
+--
+-- Name: test_dump_table test_dump_table_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.test_dump_table ALTER COLUMN id SET DEFAULT nextval('public.test_dump_table_id_seq'::regclass);
+
+ALTER TABLE ONLY public.test_dump_table
+    ADD CONSTRAINT test_dump_table_pkey PRIMARY KEY (id);
+
+--
+-- PostgreSQL database dump complete
+--
+`
+
+func TestPgDumpReader(t *testing.T) {
+	t.Run("Reads table definitions with primary keys added after the data", func(t *testing.T) {
+		pgDumpTables, err := NewPgDumpReader(strings.NewReader(TEST_PG_DUMP)).ReadTables()
+
+		testNoError(t, err)
+		if len(pgDumpTables) != 2 {
+			t.Fatalf("Expected 2 tables, got %v", len(pgDumpTables))
+		}
+
+		pgDumpTable := pgDumpTables[`"public"."test_dump_table"`]
+		expectedPgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", CharacterMaximumLength: "0", NumericPrecision: "32", NumericScale: "0", DatetimePrecision: "0", Namespace: "pg_catalog", PrimaryKeyPosition: "1", ColumnDefault: "nextval('public.test_dump_table_id_seq'::regclass)"},
+			{ColumnName: "name", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", OrdinalPosition: "2", CharacterMaximumLength: "255", NumericPrecision: "0", NumericScale: "0", DatetimePrecision: "0", Namespace: "pg_catalog", PrimaryKeyPosition: "0", ColumnDefault: "'unnamed, ok'::character varying"},
+			{ColumnName: "price", DataType: "numeric", UdtName: "numeric", IsNullable: "YES", OrdinalPosition: "3", CharacterMaximumLength: "0", NumericPrecision: "10", NumericScale: "2", DatetimePrecision: "0", Namespace: "pg_catalog", PrimaryKeyPosition: "0"},
+			{ColumnName: "tags", DataType: "ARRAY", UdtName: "_text", IsNullable: "YES", OrdinalPosition: "4", CharacterMaximumLength: "0", NumericPrecision: "0", NumericScale: "0", DatetimePrecision: "0", Namespace: "pg_catalog", PrimaryKeyPosition: "0"},
+			{ColumnName: "current_mood", DataType: "USER-DEFINED", UdtName: "mood", IsNullable: "YES", OrdinalPosition: "5", CharacterMaximumLength: "0", NumericPrecision: "0", NumericScale: "0", DatetimePrecision: "0", Namespace: "public", PrimaryKeyPosition: "0", EnumLabels: []string{"sad", "happy"}},
+			{ColumnName: "created_at", DataType: "timestamp with time zone", UdtName: "timestamptz", IsNullable: "YES", OrdinalPosition: "6", CharacterMaximumLength: "0", NumericPrecision: "0", NumericScale: "0", DatetimePrecision: "3", Namespace: "pg_catalog", PrimaryKeyPosition: "0", ColumnDefault: "now()"},
+			{ColumnName: "Notes", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "7", CharacterMaximumLength: "0", NumericPrecision: "0", NumericScale: "0", DatetimePrecision: "0", Namespace: "pg_catalog", PrimaryKeyPosition: "0", ColumnDefault: "''::text"},
+		}
+		if len(pgDumpTable.PgSchemaColumns) != len(expectedPgSchemaColumns) {
+			t.Fatalf("Expected %v columns, got %v", len(expectedPgSchemaColumns), len(pgDumpTable.PgSchemaColumns))
+		}
+		for i, expectedPgSchemaColumn := range expectedPgSchemaColumns {
+			if !reflect.DeepEqual(pgDumpTable.PgSchemaColumns[i], expectedPgSchemaColumn) {
+				t.Errorf("Expected column %v, got %v", expectedPgSchemaColumn, pgDumpTable.PgSchemaColumns[i])
+			}
+		}
+
+		skippedPgDumpTable := pgDumpTables[`"Other Schema"."test_dump_table_skipped"`]
+		if skippedPgDumpTable.PgSchemaColumns[0].UdtName != "int8" || skippedPgDumpTable.PgSchemaColumns[0].PrimaryKeyPosition != "1" {
+			t.Errorf("Unexpected inline primary key column: %v", skippedPgDumpTable.PgSchemaColumns[0])
+		}
+	})
+
+	t.Run("Reads COPY data sections decoding the text format", func(t *testing.T) {
+		pgDumpTables, err := NewPgDumpReader(strings.NewReader(TEST_PG_DUMP)).ReadTables()
+		testNoError(t, err)
+		dumpReader := NewPgDumpReaderWithTables(strings.NewReader(TEST_PG_DUMP), pgDumpTables)
+
+		pgSchemaTable, pgSchemaColumns, err := dumpReader.NextCopy()
+		testNoError(t, err)
+		if pgSchemaTable.String() != `"public"."test_dump_table"` || len(pgSchemaColumns) != 7 {
+			t.Errorf("Unexpected COPY section: %v with %v columns", pgSchemaTable.String(), len(pgSchemaColumns))
+		}
+
+		rows, err := dumpReader.ReadRows(1)
+		testNoError(t, err)
+		expectedRow := []string{"1", "Alice", "10.50", "{a,b}", "happy", "2024-01-01 12:00:00.123+00", "line 1\nline 2\ttabbed"}
+		if len(rows) != 1 || strings.Join(rows[0], "|") != strings.Join(expectedRow, "|") {
+			t.Errorf("Expected rows %v, got %v", [][]string{expectedRow}, rows)
+		}
+
+		rows, err = dumpReader.ReadRows(BATCH_SIZE)
+		testNoError(t, err)
+		expectedRow = []string{"2", PG_NULL_STRING, PG_NULL_STRING, PG_NULL_STRING, PG_NULL_STRING, PG_NULL_STRING, "back\\slash AB"}
+		if len(rows) != 1 || strings.Join(rows[0], "|") != strings.Join(expectedRow, "|") {
+			t.Errorf("Expected rows %v, got %v", [][]string{expectedRow}, rows)
+		}
+
+		pgSchemaTable, _, err = dumpReader.NextCopy()
+		testNoError(t, err)
+		if pgSchemaTable.String() != `"Other Schema"."test_dump_table_skipped"` {
+			t.Errorf("Unexpected COPY section: %v", pgSchemaTable.String())
+		}
+
+		_, _, err = dumpReader.NextCopy()
+		if err != io.EOF {
+			t.Errorf("Expected the end of the dump, got %v", err)
+		}
+	})
+
+	t.Run("Returns an error for COPY data without a table definition", func(t *testing.T) {
+		dump := "COPY public.missing_table (id) FROM stdin;\n1\n\\.\n"
+
+		_, err := NewPgDumpReader(strings.NewReader(dump)).ReadTables()
+
+		if err == nil || err.Error() != `Missing table definition for "public"."missing_table" on line 1` {
+			t.Errorf("Expected a missing table definition error, got %v", err)
+		}
+	})
+}
+
+func TestSyncFromPgDump(t *testing.T) {
+	t.Run("Syncs tables from a dump file into queryable Iceberg tables", func(t *testing.T) {
+		dumpPath := filepath.Join(t.TempDir(), "dump.sql")
+		err := os.WriteFile(dumpPath, []byte(TEST_PG_DUMP), 0644)
+		testNoError(t, err)
+
+		config := loadTestConfig()
+		config.Pg.IncludeSchemas = NewSet([]string{"public"})
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_dump_table"}
+		defer NewIcebergWriter(config).DeleteSchemaTable(schemaTable)
+
+		err = NewSyncer(config).SyncPgDump(context.Background(), dumpPath)
+		testNoError(t, err)
+
+		queryHandler := initQueryHandler()
+		messages, err := queryHandler.HandleQuery("SELECT id, name, price, tags, current_mood, \"Notes\" FROM test_dump_table ORDER BY id")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"id", "name", "price", "tags", "current_mood", "Notes"})
+		testDataRowValues(t, messages[1], []string{"1", "Alice", "10.5", "{a,b}", "happy", "line 1\nline 2\ttabbed"})
+		testDataRowNullValues(t, messages[2], 1, 4)
+		if string(messages[2].(*pgproto3.DataRow).Values[5]) != "back\\slash AB" {
+			t.Errorf("Expected decoded escapes, got %v", string(messages[2].(*pgproto3.DataRow).Values[5]))
+		}
+
+		icebergSchemaTables, err := NewIcebergReader(config).SchemaTables()
+		testNoError(t, err)
+		for _, icebergSchemaTable := range icebergSchemaTables {
+			if icebergSchemaTable.Table == "test_dump_table_skipped" {
+				t.Errorf("Expected the excluded schema not to be synced")
+			}
+		}
+	})
+
+	t.Run("Preserves the content of xml columns", func(t *testing.T) {
+		xmlValue := "<?xml version=\"1.0\"?>\n<note lang=\"en\"><to>Tove &amp; Jani</to><body><![CDATA[<b>bold</b>]]></body><!-- draft --></note>"
+		dump := "CREATE TABLE public.test_dump_xml_table (\n    id integer NOT NULL,\n    document xml\n);\n\n" +
+			"COPY public.test_dump_xml_table (id, document) FROM stdin;\n1\t" + strings.ReplaceAll(xmlValue, "\n", "\\n") + "\n\\.\n"
+		dumpPath := filepath.Join(t.TempDir(), "dump.sql")
+		err := os.WriteFile(dumpPath, []byte(dump), 0644)
+		testNoError(t, err)
+
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_dump_xml_table"}
+		defer NewIcebergWriter(config).DeleteSchemaTable(schemaTable)
+
+		err = NewSyncer(config).SyncPgDump(context.Background(), dumpPath)
+		testNoError(t, err)
+
+		queryHandler := initQueryHandler()
+		messages, err := queryHandler.HandleQuery("SELECT document, xpath('/note/to/text()', document) AS recipients, xpath_exists('/note/@lang', document) AS has_lang FROM test_dump_xml_table")
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], []string{"document", "recipients", "has_lang"})
+		testDataRowValues(t, messages[1], []string{xmlValue, "{Tove &amp; Jani}", "true"})
+	})
+
+	t.Run("Preserves mixed-case quoted column names", func(t *testing.T) {
+		dump := "CREATE TABLE public.test_dump_mixed_case_table (\n    \"userId\" integer NOT NULL,\n    \"CreatedAt\" timestamp without time zone,\n    \"userName\" text,\n    \"Tags\" text[]\n);\n\n" +
+			"COPY public.test_dump_mixed_case_table (\"userId\", \"CreatedAt\", \"userName\", \"Tags\") FROM stdin;\n1\t2024-01-01 10:00:00\tAlice\t{a,b}\n2\t2024-01-02 10:00:00\tBob\t\\N\n\\.\n\n" +
+			"ALTER TABLE ONLY public.test_dump_mixed_case_table\n    ADD CONSTRAINT test_dump_mixed_case_table_pkey PRIMARY KEY (\"userId\");\n"
+		dumpPath := filepath.Join(t.TempDir(), "dump.sql")
+		err := os.WriteFile(dumpPath, []byte(dump), 0644)
+		testNoError(t, err)
+
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_dump_mixed_case_table"}
+		defer NewIcebergWriter(config).DeleteSchemaTable(schemaTable)
+
+		err = NewSyncer(config).SyncPgDump(context.Background(), dumpPath)
+		testNoError(t, err)
+
+		icebergSchemaFields, err := NewIcebergReader(config).SchemaFields(schemaTable, 0)
+		testNoError(t, err)
+		var fieldNames []string
+		for _, icebergSchemaField := range icebergSchemaFields {
+			fieldNames = append(fieldNames, icebergSchemaField.Name)
+		}
+		if strings.Join(fieldNames, ",") != "userId,CreatedAt,userName,Tags" {
+			t.Errorf("Expected the Iceberg schema to keep the column names, got %v", fieldNames)
+		}
+
+		queryHandler := initQueryHandler()
+		messages, err := queryHandler.HandleQuery("SELECT t.\"userId\", \"CreatedAt\", \"userName\", \"Tags\"[1] AS \"FirstTag\" FROM test_dump_mixed_case_table t WHERE \"userName\" = 'Alice' AND t.\"CreatedAt\" < '2024-01-02' ORDER BY \"CreatedAt\"")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"userId", "CreatedAt", "userName", "FirstTag"})
+		testDataRowValues(t, messages[1], []string{"1", "2024-01-01 10:00:00", "Alice", "a"})
+
+		messages, err = queryHandler.HandleQuery("SELECT column_name FROM information_schema.key_column_usage WHERE table_name = 'test_dump_mixed_case_table'")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"userId"})
+	})
+
+	t.Run("Orders enum columns by their declared order", func(t *testing.T) {
+		dump := "CREATE TYPE public.mood AS ENUM (\n    'sad',\n    'ok',\n    'happy'\n);\n\n" +
+			"CREATE TABLE public.test_dump_enum_table (\n    id integer NOT NULL,\n    current_mood public.mood\n);\n\n" +
+			"COPY public.test_dump_enum_table (id, current_mood) FROM stdin;\n1\thappy\n2\tsad\n3\tok\n4\t\\N\n\\.\n"
+		dumpPath := filepath.Join(t.TempDir(), "dump.sql")
+		err := os.WriteFile(dumpPath, []byte(dump), 0644)
+		testNoError(t, err)
+
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_dump_enum_table"}
+		defer NewIcebergWriter(config).DeleteSchemaTable(schemaTable)
+
+		err = NewSyncer(config).SyncPgDump(context.Background(), dumpPath)
+		testNoError(t, err)
+
+		queryHandler := initQueryHandler()
+		queries := map[string][]string{
+			"SELECT current_mood FROM test_dump_enum_table WHERE current_mood IS NOT NULL ORDER BY current_mood":      {"sad", "ok", "happy"},
+			"SELECT current_mood FROM test_dump_enum_table WHERE current_mood IS NOT NULL ORDER BY current_mood DESC": {"happy", "ok", "sad"},
+			"SELECT current_mood FROM test_dump_enum_table WHERE current_mood < 'happy' ORDER BY id":                  {"sad", "ok"},
+			"SELECT current_mood FROM test_dump_enum_table WHERE 'ok' <= current_mood ORDER BY id":                    {"happy", "ok"},
+			"SELECT current_mood FROM test_dump_enum_table t WHERE t.current_mood BETWEEN 'sad' AND 'ok' ORDER BY id": {"sad", "ok"},
+			"SELECT max(current_mood) FROM test_dump_enum_table":                                                      {"happy"},
+		}
+		for query, expectedValues := range queries {
+			messages, err := queryHandler.HandleQuery(query)
+			testNoError(t, err)
+
+			var values []string
+			for _, message := range messages[1 : len(messages)-1] {
+				values = append(values, string(message.(*pgproto3.DataRow).Values[0]))
+			}
+			if strings.Join(values, ",") != strings.Join(expectedValues, ",") {
+				t.Errorf("Expected %v for %s, got %v", expectedValues, query, values)
+			}
+		}
+	})
+}
@@ -1,7 +1,7 @@
-package main
+package bemidb
 
 import (
-	"encoding/csv"
+	"fmt"
 	"math"
 	"strconv"
 	"strings"
@@ -20,8 +20,20 @@ const (
 	PARQUET_SCHEMA_REPETITION_TYPE_REQUIRED = "REQUIRED"
 	PARQUET_SCHEMA_REPETITION_TYPE_OPTIONAL = "OPTIONAL"
 
-	PARQUET_NAN           = "NaN"
-	PARQUET_MAX_PRECISION = 38
+	PG_INFINITY          = "infinity"
+	PG_NEGATIVE_INFINITY = "-infinity"
+
+	PARQUET_NAN               = "NaN"
+	PARQUET_INFINITY          = "+Inf"
+	PARQUET_NEGATIVE_INFINITY = "-Inf"
+	PARQUET_MAX_PRECISION     = 38
+
+	// Read by DuckDB as infinity and -infinity
+	PARQUET_TIMESTAMP_INFINITY_US = math.MaxInt64
+	PARQUET_DATE_INFINITY         = math.MaxInt32
+	// Millisecond timestamps can't hold DuckDB's infinity, so they are clamped to 9999-12-31 23:59:59.999 and 0001-01-01 00:00:00
+	PARQUET_TIMESTAMP_MAX_MS = 253402300799999
+	PARQUET_TIMESTAMP_MIN_MS = -62135596800000
 
 	// 0000-01-01 00:00:00 +0000 UTC
 	EPOCH_TIME_MS = -62167219200000
@@ -38,6 +50,9 @@ type PgSchemaColumn struct {
 	NumericScale           string
 	DatetimePrecision      string
 	Namespace              string
+	PrimaryKeyPosition     string   // 1-based position in the primary key, "0" if the column isn't part of it
+	EnumLabels             []string // Labels in their declared order if the column is a Postgres enum, stored as text
+	ColumnDefault          string   // Default expression as Postgres formats it, e.g. nextval('users_id_seq'::regclass), empty if there is none
 }
 
 type ParquetSchemaField struct {
@@ -58,9 +73,15 @@ type IcebergSchemaField struct {
 	Name     string      `json:"name"`
 	Type     interface{} `json:"type"`
 	Required bool        `json:"required"`
+
+	// Not part of the Iceberg spec, other readers ignore it
+	EnumLabels    []string `json:"bemidb-enum-labels,omitempty"`
+	RangeType     string   `json:"bemidb-range-type,omitempty"` // Postgres range type of a column stored as text, e.g. tstzrange
+	ColumnDefault string   `json:"bemidb-column-default,omitempty"`
 }
 
-func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap() map[string]interface{} {
+// Without statistics the column chunks have no min/max values and null counts
+func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap(omitStats bool) map[string]interface{} {
 	field := pgSchemaColumn.toParquetSchemaField()
 
 	tagKeyVals := []string{
@@ -82,6 +103,9 @@ func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap() map[string]interf
 	if field.Precision != "" {
 		tagKeyVals = append(tagKeyVals, "precision="+field.Precision)
 	}
+	if omitStats && field.NestedType == "" {
+		tagKeyVals = append(tagKeyVals, "omitstats=true")
+	}
 
 	result := map[string]interface{}{
 		"Tag": strings.Join(tagKeyVals, ", "),
@@ -96,6 +120,9 @@ func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap() map[string]interf
 		if field.NestedConvertedType != "" {
 			nestedTagKeyVals = append(nestedTagKeyVals, "convertedtype="+field.NestedConvertedType)
 		}
+		if omitStats {
+			nestedTagKeyVals = append(nestedTagKeyVals, "omitstats=true")
+		}
 
 		result["Fields"] = []map[string]interface{}{
 			{"Tag": strings.Join(nestedTagKeyVals, ", ")},
@@ -105,6 +132,93 @@ func (pgSchemaColumn PgSchemaColumn) ToParquetSchemaFieldMap() map[string]interf
 	return result
 }
 
+// Column type attributes like in information_schema.columns, from the type's name, schema and declared modifiers, e.g. [10, 2] for numeric(10, 2)
+func pgTypeSchemaColumn(udtName string, namespace string, isArray bool, typmods []int) PgSchemaColumn {
+	pgSchemaColumn := PgSchemaColumn{
+		UdtName:                udtName,
+		Namespace:              namespace,
+		CharacterMaximumLength: "0",
+		NumericPrecision:       "0",
+		NumericScale:           "0",
+		DatetimePrecision:      "0",
+	}
+
+	if isArray {
+		pgSchemaColumn.DataType = PG_DATA_TYPE_ARRAY
+		pgSchemaColumn.UdtName = "_" + pgSchemaColumn.UdtName
+		return pgSchemaColumn
+	}
+
+	pgSchemaColumn.DataType = pgSchemaColumn.UdtName
+	if pgSchemaColumn.Namespace != PG_SCHEMA_PG_CATALOG {
+		pgSchemaColumn.DataType = "USER-DEFINED"
+		return pgSchemaColumn
+	}
+
+	switch pgSchemaColumn.UdtName {
+	case "int2":
+		pgSchemaColumn.DataType = "smallint"
+		pgSchemaColumn.NumericPrecision = "16"
+	case "int4":
+		pgSchemaColumn.DataType = "integer"
+		pgSchemaColumn.NumericPrecision = "32"
+	case "int8":
+		pgSchemaColumn.DataType = "bigint"
+		pgSchemaColumn.NumericPrecision = "64"
+	case "float4":
+		pgSchemaColumn.DataType = "real"
+		pgSchemaColumn.NumericPrecision = "24"
+	case "float8":
+		pgSchemaColumn.DataType = "double precision"
+		pgSchemaColumn.NumericPrecision = "53"
+	case "numeric":
+		if len(typmods) > 0 {
+			pgSchemaColumn.NumericPrecision = IntToString(typmods[0])
+		}
+		if len(typmods) > 1 {
+			pgSchemaColumn.NumericScale = IntToString(typmods[1])
+		}
+	case "bool":
+		pgSchemaColumn.DataType = "boolean"
+	case "varchar", "bpchar":
+		pgSchemaColumn.DataType = map[string]string{"varchar": "character varying", "bpchar": "character"}[pgSchemaColumn.UdtName]
+		if len(typmods) > 0 {
+			pgSchemaColumn.CharacterMaximumLength = IntToString(typmods[0])
+		}
+	case "timestamp", "timestamptz", "time", "timetz":
+		pgSchemaColumn.DataType = map[string]string{
+			"timestamp":   "timestamp without time zone",
+			"timestamptz": "timestamp with time zone",
+			"time":        "time without time zone",
+			"timetz":      "time with time zone",
+		}[pgSchemaColumn.UdtName]
+		pgSchemaColumn.DatetimePrecision = "6"
+		if len(typmods) > 0 {
+			pgSchemaColumn.DatetimePrecision = IntToString(typmods[0])
+		}
+	}
+
+	return pgSchemaColumn
+}
+
+// Declared modifiers of a type from the atttypmod Postgres reports for it, e.g. in a query result description, -1 if there are none
+func pgTypeModifiers(udtName string, typeModifier int32) []int {
+	if typeModifier < 0 {
+		return nil
+	}
+
+	switch udtName {
+	case "numeric":
+		typeModifier -= 4 // VARHDRSZ
+		return []int{int(typeModifier>>16) & 0xffff, int(typeModifier) & 0xffff}
+	case "varchar", "bpchar":
+		return []int{int(typeModifier) - 4}
+	case "timestamp", "timestamptz", "time", "timetz":
+		return []int{int(typeModifier)}
+	}
+	return nil
+}
+
 func (pgSchemaColumn PgSchemaColumn) ToIcebergSchemaFieldMap() IcebergSchemaField {
 	icebergSchemaField := IcebergSchemaField{}
 
@@ -132,7 +246,12 @@ func (pgSchemaColumn PgSchemaColumn) ToIcebergSchemaFieldMap() IcebergSchemaFiel
 		}
 	} else {
 		icebergSchemaField.Type = primitiveType
+		icebergSchemaField.EnumLabels = pgSchemaColumn.EnumLabels
+		if PG_RANGE_TYPES.Contains(pgSchemaColumn.UdtName) {
+			icebergSchemaField.RangeType = pgSchemaColumn.UdtName
+		}
 	}
+	icebergSchemaField.ColumnDefault = pgSchemaColumn.ColumnDefault
 
 	return icebergSchemaField
 }
@@ -145,16 +264,18 @@ func (pgSchemaColumn *PgSchemaColumn) FormatParquetValue(value string) interface
 	if pgSchemaColumn.DataType == PG_DATA_TYPE_ARRAY {
 		var values []interface{}
 
-		csvString := strings.Trim(value, "{}")
-		if csvString == "" {
-			return values
-		}
-
-		csvReader := csv.NewReader(strings.NewReader(csvString))
-		stringValues, err := csvReader.Read()
+		stringValues, err := parsePgArray(value, pgSchemaColumn.pgArrayDelimiter())
 		PanicIfError(err)
 
+		primitiveType, _ := pgSchemaColumn.parquetPrimitiveTypes()
 		for _, stringValue := range stringValues {
+			// The Parquet JSON writer can't write NULL list elements, so they're kept as "NULL" strings in string arrays and skipped otherwise
+			if stringValue == PG_NULL_STRING {
+				if primitiveType != "BYTE_ARRAY" {
+					continue
+				}
+				stringValue = "NULL"
+			}
 			values = append(values, pgSchemaColumn.parquetPrimitiveValue(stringValue))
 		}
 
@@ -214,7 +335,8 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveValue(value string) interf
 	case "varchar", "char", "text", "bit", "bytea", "jsonb", "json", "numeric", "uuid", "interval",
 		"point", "line", "lseg", "box", "path", "polygon", "circle",
 		"cidr", "inet", "macaddr", "macaddr8",
-		"tsvector", "xml", "pg_snapshot":
+		"tsvector", "xml", "pg_snapshot",
+		"int4range", "int8range", "numrange", "tsrange", "tstzrange", "daterange":
 		return value
 	case "bpchar":
 		trimmedValue := strings.TrimRight(value, " ")
@@ -238,22 +360,25 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveValue(value string) interf
 	case "float4":
 		floatValue, err := strconv.ParseFloat(value, 32)
 		PanicIfError(err)
-		if math.IsNaN(floatValue) {
-			return PARQUET_NAN
+		if math.IsNaN(floatValue) || math.IsInf(floatValue, 0) {
+			return parquetSpecialFloat(floatValue)
 		}
 		return float32(floatValue)
 	case "float8":
 		floatValue, err := strconv.ParseFloat(value, 64)
 		PanicIfError(err)
-		if math.IsNaN(floatValue) {
-			return PARQUET_NAN
+		if math.IsNaN(floatValue) || math.IsInf(floatValue, 0) {
+			return parquetSpecialFloat(floatValue)
 		}
 		return floatValue
 	case "bool":
-		boolValue, err := strconv.ParseBool(value)
+		boolValue, err := parsePgBool(value)
 		PanicIfError(err)
 		return boolValue
 	case "timestamp":
+		if pgSchemaColumn.IsInfiniteValue(value) {
+			return pgSchemaColumn.parquetInfiniteTimestamp(value)
+		}
 		if pgSchemaColumn.DatetimePrecision == "6" {
 			parsedTime, err := time.Parse("2006-01-02 15:04:05.999999", value)
 			PanicIfError(err)
@@ -264,6 +389,9 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveValue(value string) interf
 			return parsedTime.UnixMilli()
 		}
 	case "timestamptz":
+		if pgSchemaColumn.IsInfiniteValue(value) {
+			return pgSchemaColumn.parquetInfiniteTimestamp(value)
+		}
 		if pgSchemaColumn.DatetimePrecision == "6" {
 			parsedTime, err := time.Parse("2006-01-02 15:04:05.999999-07:00", value)
 			if err != nil {
@@ -300,6 +428,12 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveValue(value string) interf
 			return -EPOCH_TIME_MS + parsedTime.UnixMilli()
 		}
 	case "date":
+		if pgSchemaColumn.IsInfiniteValue(value) {
+			if value == PG_NEGATIVE_INFINITY {
+				return int64(-PARQUET_DATE_INFINITY)
+			}
+			return int64(PARQUET_DATE_INFINITY)
+		}
 		parsedTime, err := time.Parse("2006-01-02", value)
 		PanicIfError(err)
 		return parsedTime.Unix() / 86400
@@ -313,12 +447,130 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveValue(value string) interf
 	panic("Unsupported PostgreSQL value: " + value)
 }
 
+// Elements of a Postgres array in row-major order, e.g. {{1,2},{3,NULL}} -> 1, 2, 3, BEMIDB_NULL, like unnest() returns them.
+// Quoted elements are unescaped, e.g. elements of composite types: {"(1,\"a b\")"} -> (1,"a b")
+func parsePgArray(value string, delimiter byte) (elements []string, err error) {
+	// Arrays with non-default bounds are prefixed with their dimensions, e.g. [0:1]={a,b}
+	if strings.HasPrefix(value, "[") {
+		_, value, _ = strings.Cut(value, "=")
+	}
+	if !strings.HasPrefix(value, "{") || !strings.HasSuffix(value, "}") {
+		return nil, fmt.Errorf("invalid array value: %s", value)
+	}
+
+	var element strings.Builder
+	isQuoted := false
+	isElement := false
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case char == '"':
+			isQuoted = true
+			isElement = true
+			for i++; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' {
+					i++
+				}
+				if i < len(value) {
+					element.WriteByte(value[i])
+				}
+			}
+		case char == '{' || char == ' ' && !isElement:
+			continue
+		case char == delimiter || char == '}':
+			if !isElement {
+				continue
+			}
+			if !isQuoted && strings.EqualFold(strings.TrimSpace(element.String()), "NULL") {
+				elements = append(elements, PG_NULL_STRING)
+			} else if isQuoted {
+				elements = append(elements, element.String())
+			} else {
+				elements = append(elements, strings.TrimSpace(element.String()))
+			}
+			element.Reset()
+			isQuoted = false
+			isElement = false
+		case char == '\\':
+			i++
+			if i < len(value) {
+				element.WriteByte(value[i])
+			}
+			isElement = true
+		default:
+			element.WriteByte(char)
+			isElement = true
+		}
+	}
+
+	return elements, nil
+}
+
+// Postgres separates the elements of box arrays with semicolons, since boxes contain commas
+func (pgSchemaColumn *PgSchemaColumn) pgArrayDelimiter() byte {
+	if strings.TrimLeft(pgSchemaColumn.UdtName, "_") == "box" {
+		return ';'
+	}
+	return ','
+}
+
+// Postgres boolean input: 't'/'f' from COPY text output, 'true'/'false' and the other spellings Postgres accepts
+func parsePgBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, nil
+	case "f", "false", "n", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value: %s", value)
+}
+
+// Postgres 'infinity' and '-infinity' of a timestamp or date column
+func (pgSchemaColumn *PgSchemaColumn) IsInfiniteValue(value string) bool {
+	switch strings.TrimLeft(pgSchemaColumn.UdtName, "_") {
+	case "timestamp", "timestamptz", "date":
+		return value == PG_INFINITY || value == PG_NEGATIVE_INFINITY
+	}
+	return false
+}
+
+// Whether the column is a non-array float whose NaN values are counted in Iceberg nan_value_counts
+func (pgSchemaColumn *PgSchemaColumn) IsNanCountable() bool {
+	return pgSchemaColumn.DataType != PG_DATA_TYPE_ARRAY && (pgSchemaColumn.UdtName == "float4" || pgSchemaColumn.UdtName == "float8")
+}
+
+func (pgSchemaColumn *PgSchemaColumn) parquetInfiniteTimestamp(value string) int64 {
+	if pgSchemaColumn.DatetimePrecision == "6" {
+		if value == PG_NEGATIVE_INFINITY {
+			return -PARQUET_TIMESTAMP_INFINITY_US
+		}
+		return PARQUET_TIMESTAMP_INFINITY_US
+	}
+
+	if value == PG_NEGATIVE_INFINITY {
+		return PARQUET_TIMESTAMP_MIN_MS
+	}
+	return PARQUET_TIMESTAMP_MAX_MS
+}
+
+// NaN and infinity can't be encoded as JSON numbers, the Parquet JSON writer parses them from strings instead
+func parquetSpecialFloat(floatValue float64) string {
+	switch {
+	case math.IsInf(floatValue, 1):
+		return PARQUET_INFINITY
+	case math.IsInf(floatValue, -1):
+		return PARQUET_NEGATIVE_INFINITY
+	}
+	return PARQUET_NAN
+}
+
 func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveTypes() (primitiveType string, primitiveConvertedType string) {
 	switch strings.TrimLeft(pgSchemaColumn.UdtName, "_") {
 	case "varchar", "char", "text", "bpchar", "bit", "bytea", "interval", "jsonb", "json",
 		"point", "line", "lseg", "box", "path", "polygon", "circle",
 		"cidr", "inet", "macaddr", "macaddr8",
-		"tsvector", "xml", "pg_snapshot":
+		"tsvector", "xml", "pg_snapshot",
+		"int4range", "int8range", "numrange", "tsrange", "tstzrange", "daterange":
 		return "BYTE_ARRAY", "UTF8"
 	case "date":
 		return "INT32", "DATE"
@@ -347,6 +599,7 @@ func (pgSchemaColumn *PgSchemaColumn) parquetPrimitiveTypes() (primitiveType str
 			return "INT32", "TIME_MILLIS"
 		}
 	case "timestamp", "timestamptz":
+		// Never the legacy INT96 that some Iceberg readers don't support. DuckDB still reads INT96 from externally produced files
 		if pgSchemaColumn.DatetimePrecision == "6" {
 			return "INT64", "TIMESTAMP_MICROS"
 		} else {
@@ -367,7 +620,8 @@ func (pgSchemaColumn *PgSchemaColumn) icebergPrimitiveType() string {
 	case "varchar", "char", "text", "interval", "jsonb", "json", "bpchar", "bit",
 		"point", "line", "lseg", "box", "path", "polygon", "circle",
 		"cidr", "inet", "macaddr", "macaddr8",
-		"tsvector", "xml", "pg_snapshot":
+		"tsvector", "xml", "pg_snapshot",
+		"int4range", "int8range", "numrange", "tsrange", "tstzrange", "daterange":
 		return "string"
 	case "uuid":
 		return "uuid"
@@ -0,0 +1,437 @@
+package bemidb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+const (
+	PG_VERSION        = "17.0"
+	PG_ENCODING       = "UTF8"
+	PG_TX_STATUS_IDLE = 'I'
+
+	// DuckDB reads backslashes in '...' literally, clients escape them only in E'...' strings
+	PG_STANDARD_CONFORMING_STRINGS = "on"
+
+	SYSTEM_AUTH_USER = "bemidb"
+
+	PG_ERROR_CODE_TOO_MANY_CONNECTIONS = "53300"
+
+	MESSAGE_STREAM_CHUNK_SIZE = 64 * 1024 // bytes written to the client at once
+
+	REJECTED_CONNECTION_TIMEOUT = 5 * time.Second
+	MAX_ACCEPT_RETRY_DELAY      = 1 * time.Second
+)
+
+type Postgres struct {
+	backend *pgproto3.Backend
+	conn    *net.Conn
+	config  *Config
+}
+
+func NewPostgres(config *Config, conn *net.Conn) *Postgres {
+	return &Postgres{
+		conn:    conn,
+		backend: pgproto3.NewBackend(*conn, *conn),
+		config:  config,
+	}
+}
+
+func NewTcpListener(config *Config) net.Listener {
+	parsedIp := net.ParseIP(config.Host)
+	if parsedIp == nil {
+		panic("Invalid host: " + config.Host)
+	}
+
+	var network, host string
+	if parsedIp.To4() == nil {
+		network = "tcp6"
+		host = "[" + config.Host + "]"
+	} else {
+		network = "tcp4"
+		host = config.Host
+	}
+
+	// Probes idle client connections so that dead peers and stale NAT/load balancer mappings are detected
+	listenConfig := net.ListenConfig{KeepAlive: config.Server.TCPKeepAlive}
+	if config.Server.TCPKeepAlive == 0 {
+		listenConfig.KeepAlive = -1 // disabled
+	}
+
+	tcpListener, err := listenConfig.Listen(context.Background(), network, host+":"+config.Port)
+	PanicIfError(err)
+	return tcpListener
+}
+
+func AcceptConnection(listener net.Listener) (net.Conn, error) {
+	return listener.Accept()
+}
+
+// Caps the number of open client connections and spaces out accepting new ones
+type ConnectionLimiter struct {
+	activeConnections atomic.Int64
+	acceptMutex       sync.Mutex
+	lastAcceptedAt    time.Time
+	config            *Config
+}
+
+func NewConnectionLimiter(config *Config) *ConnectionLimiter {
+	return &ConnectionLimiter{config: config}
+}
+
+// Blocks until accepting another connection stays within the max accept rate
+func (limiter *ConnectionLimiter) WaitToAccept() {
+	if limiter.config.Server.MaxAcceptRate == 0 {
+		return
+	}
+
+	limiter.acceptMutex.Lock()
+	defer limiter.acceptMutex.Unlock()
+
+	interval := time.Second / time.Duration(limiter.config.Server.MaxAcceptRate)
+	time.Sleep(time.Until(limiter.lastAcceptedAt.Add(interval)))
+	limiter.lastAcceptedAt = time.Now()
+}
+
+// Returns false if the connection exceeds the max connections and must be rejected
+func (limiter *ConnectionLimiter) Acquire() bool {
+	if limiter.activeConnections.Add(1) > int64(limiter.config.Server.MaxConnections) {
+		limiter.activeConnections.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (limiter *ConnectionLimiter) Release() {
+	limiter.activeConnections.Add(-1)
+}
+
+func (limiter *ConnectionLimiter) ActiveConnections() int64 {
+	return limiter.activeConnections.Load()
+}
+
+// Accepts client connections until the listener is closed. Other accept errors, e.g. running out of file descriptors,
+// are retried with an increasing delay like in net/http
+func Serve(config *Config, tcpListener net.Listener, engine *Engine, connectionLimiter *ConnectionLimiter) {
+	var retryDelay time.Duration
+	for {
+		connectionLimiter.WaitToAccept()
+		conn, err := AcceptConnection(tcpListener)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			if retryDelay == 0 {
+				retryDelay = 5 * time.Millisecond
+			} else {
+				retryDelay = min(retryDelay*2, MAX_ACCEPT_RETRY_DELAY)
+			}
+			LogError(config, "BemiDB: Couldn't accept connection, retrying in", retryDelay, "-", err)
+			time.Sleep(retryDelay)
+			continue
+		}
+		retryDelay = 0
+		postgres := NewPostgres(config, &conn)
+
+		if !connectionLimiter.Acquire() {
+			LogWarn(config, "BemiDB: Rejected connection from", conn.RemoteAddr(), "- too many connections")
+			go func() {
+				defer postgres.Close()
+				postgres.RejectTooManyConnections()
+			}()
+			continue
+		}
+
+		LogInfo(config, "BemiDB: Accepted connection from", conn.RemoteAddr())
+		queryHandler := engine.queryHandler.NewSession()
+
+		go func() {
+			defer connectionLimiter.Release()
+			postgres.Run(queryHandler)
+			defer postgres.Close()
+			LogInfo(config, "BemiDB: Closed connection from", conn.RemoteAddr())
+		}()
+	}
+}
+
+func (postgres *Postgres) Run(queryHandler *QueryHandler) {
+	err := postgres.handleStartup()
+	if err != nil {
+		LogError(postgres.config, "Error handling startup:", err)
+		return // Terminate connection
+	}
+
+	for {
+		message, err := postgres.backend.Receive()
+		if err != nil {
+			return // Terminate connection
+		}
+
+		switch message.(type) {
+		case *pgproto3.Query:
+			postgres.handleSimpleQuery(queryHandler, message.(*pgproto3.Query))
+		case *pgproto3.Parse:
+			err = postgres.handleExtendedQuery(queryHandler, message.(*pgproto3.Parse))
+			if err != nil {
+				return // Terminate connection
+			}
+		case *pgproto3.Terminate:
+			LogDebug(postgres.config, "Client terminated connection")
+			return
+		default:
+			LogError(postgres.config, "Received message other than Query from client:", message)
+			return // Terminate connection
+		}
+	}
+}
+
+// Replies to the startup message with a fatal error like Postgres does when max_connections is reached
+// Clients that don't send a startup message or read the response in time are disconnected, so that they can't hold
+// rejected connections open
+func (postgres *Postgres) RejectTooManyConnections() {
+	(*postgres.conn).SetDeadline(time.Now().Add(REJECTED_CONNECTION_TIMEOUT))
+
+	startupMessage, err := postgres.backend.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+
+	if _, ok := startupMessage.(*pgproto3.SSLRequest); ok {
+		_, err = (*postgres.conn).Write([]byte("N"))
+		if err != nil {
+			return
+		}
+		_, err = postgres.backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+	}
+
+	// Ignore write errors since misbehaving clients often disconnect without waiting for the response
+	buf, err := (&pgproto3.ErrorResponse{
+		Severity: "FATAL",
+		Code:     PG_ERROR_CODE_TOO_MANY_CONNECTIONS,
+		Message:  "sorry, too many clients already",
+	}).Encode(nil)
+	PanicIfError(err, "Error encoding messages")
+	(*postgres.conn).Write(buf)
+}
+
+func (postgres *Postgres) Close() error {
+	return (*postgres.conn).Close()
+}
+
+func (postgres *Postgres) handleSimpleQuery(queryHandler *QueryHandler, queryMessage *pgproto3.Query) {
+	LogDebug(postgres.config, "Received query:", queryMessage.String)
+	var messages []pgproto3.Message
+	var err error
+	postgres.withQueryKeepAlive(func() {
+		messages, err = queryHandler.HandleQueryContext(context.Background(), queryMessage.String)
+	})
+	if err != nil {
+		postgres.writeError(err.Error())
+		return
+	}
+	messages = append(messages, &pgproto3.ReadyForQuery{TxStatus: PG_TX_STATUS_IDLE})
+	postgres.writeMessages(messages...)
+}
+
+func (postgres *Postgres) handleExtendedQuery(queryHandler *QueryHandler, parseMessage *pgproto3.Parse) error {
+	LogDebug(postgres.config, "Parsing query", parseMessage.Query)
+	messages, preparedStatement, err := queryHandler.HandleParseQuery(parseMessage)
+	if err != nil {
+		var unsupportedFeatureError *UnsupportedFeatureError
+		if errors.As(err, &unsupportedFeatureError) {
+			postgres.writeError(err.Error())
+		} else {
+			postgres.writeError("Failed to parse query")
+		}
+		return nil
+	}
+	postgres.writeMessages(messages...)
+	defer preparedStatement.Close()
+
+	for {
+		message, err := postgres.backend.Receive()
+		if err != nil {
+			return err
+		}
+
+		switch message.(type) {
+		case *pgproto3.Bind:
+			message := message.(*pgproto3.Bind)
+			LogDebug(postgres.config, "Binding query", message.PreparedStatement)
+			messages, preparedStatement, err = queryHandler.HandleBindQuery(message, preparedStatement)
+			if err != nil {
+				postgres.writeError("Failed to bind query")
+				continue
+			}
+			postgres.writeMessages(messages...)
+		case *pgproto3.Describe:
+			message := message.(*pgproto3.Describe)
+			LogDebug(postgres.config, "Describing query", message.Name, "("+string(message.ObjectType)+")")
+			var messages []pgproto3.Message
+			messages, preparedStatement, err = queryHandler.HandleDescribeQuery(message, preparedStatement)
+			if err != nil {
+				postgres.writeError("Failed to describe query")
+				continue
+			}
+			postgres.writeMessages(messages...)
+		case *pgproto3.Execute:
+			message := message.(*pgproto3.Execute)
+			LogDebug(postgres.config, "Executing query", message.Portal)
+			var messages []pgproto3.Message
+			postgres.withQueryKeepAlive(func() {
+				messages, err = queryHandler.HandleExecuteQuery(message, preparedStatement)
+			})
+			if err != nil {
+				postgres.writeError("Failed to execute query")
+				continue
+			}
+			postgres.writeMessages(messages...)
+		case *pgproto3.Sync:
+			LogDebug(postgres.config, "Syncing query")
+			postgres.writeMessages(
+				&pgproto3.ReadyForQuery{TxStatus: PG_TX_STATUS_IDLE},
+			)
+			return nil
+		}
+	}
+}
+
+// Periodically sends a ParameterStatus message while a long-running query is executed,
+// so that idle timeouts of proxies between the client and the server are not triggered
+func (postgres *Postgres) withQueryKeepAlive(handleQuery func()) {
+	if postgres.config.Server.QueryKeepAlive == 0 {
+		handleQuery()
+		return
+	}
+
+	done := make(chan struct{})
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		ticker := time.NewTicker(postgres.config.Server.QueryKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				buf, _ := (&pgproto3.ParameterStatus{Name: "server_version", Value: postgres.config.Server.Version}).Encode(nil)
+				if _, err := (*postgres.conn).Write(buf); err != nil {
+					LogDebug(postgres.config, "Failed to send a keepalive message:", err)
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	handleQuery()
+	close(done)
+	waitGroup.Wait() // don't interleave keepalive messages with query results
+}
+
+func (postgres *Postgres) writeMessages(messages ...pgproto3.Message) {
+	var buf []byte
+	var err error
+	for _, message := range messages {
+		if stream, ok := message.(*MessageStream); ok {
+			buf = postgres.writeMessageStream(buf, stream)
+			continue
+		}
+		buf, err = message.Encode(buf)
+		PanicIfError(err, "Error encoding messages")
+	}
+	_, err = (*postgres.conn).Write(buf)
+	PanicIfError(err, "Error writing messages")
+}
+
+// Writes the streamed messages in chunks as they're read, an error ends the stream with an error response
+func (postgres *Postgres) writeMessageStream(buf []byte, stream *MessageStream) []byte {
+	defer stream.Close()
+
+	for {
+		message, err := stream.Next()
+		if err != nil {
+			buf, err = (&pgproto3.ErrorResponse{Message: err.Error()}).Encode(buf)
+			PanicIfError(err, "Error encoding messages")
+			return buf
+		}
+		if message == nil {
+			return buf
+		}
+
+		buf, err = message.Encode(buf)
+		PanicIfError(err, "Error encoding messages")
+		if len(buf) >= MESSAGE_STREAM_CHUNK_SIZE {
+			_, err = (*postgres.conn).Write(buf)
+			PanicIfError(err, "Error writing messages")
+			buf = buf[:0]
+		}
+	}
+}
+
+func (postgres *Postgres) writeError(message string) {
+	postgres.writeMessages(
+		&pgproto3.ErrorResponse{Message: message},
+		&pgproto3.ReadyForQuery{TxStatus: PG_TX_STATUS_IDLE},
+	)
+}
+
+func (postgres *Postgres) handleStartup() error {
+	startupMessage, err := postgres.backend.ReceiveStartupMessage()
+	if err != nil {
+		return err
+	}
+
+	switch startupMessage.(type) {
+	case *pgproto3.StartupMessage:
+		params := startupMessage.(*pgproto3.StartupMessage).Parameters
+		LogDebug(postgres.config, "BemiDB: startup message", params)
+
+		if params["database"] != postgres.config.Database {
+			postgres.writeError("database " + params["database"] + " does not exist")
+			return errors.New("Database does not exist")
+		}
+
+		if postgres.config.User != "" && params["user"] != postgres.config.User && params["user"] != SYSTEM_AUTH_USER {
+			postgres.writeError("role \"" + params["user"] + "\" does not exist")
+			return errors.New("Role does not exist")
+		}
+
+		if clientEncoding, ok := params[PG_SETTING_CLIENT_ENCODING]; ok {
+			_, err = ParseClientEncoding(clientEncoding)
+			if err != nil {
+				postgres.writeError(err.Error())
+				return err
+			}
+		}
+
+		postgres.writeMessages(
+			&pgproto3.AuthenticationOk{},
+			&pgproto3.ParameterStatus{Name: "client_encoding", Value: PG_ENCODING},
+			&pgproto3.ParameterStatus{Name: "server_version", Value: postgres.config.Server.Version},
+			&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: PG_STANDARD_CONFORMING_STRINGS},
+			&pgproto3.ReadyForQuery{TxStatus: PG_TX_STATUS_IDLE},
+		)
+		return nil
+	case *pgproto3.SSLRequest:
+		_, err = (*postgres.conn).Write([]byte("N"))
+		if err != nil {
+			return err
+		}
+		postgres.handleStartup()
+		return nil
+	default:
+		return errors.New("Unknown startup message")
+	}
+}
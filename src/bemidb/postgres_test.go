@@ -0,0 +1,280 @@
+package bemidb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+func TestServe(t *testing.T) {
+	t.Run("Rejects connections above the max connections with a too many connections error", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Server.MaxConnections = 2
+		tcpListener, err := net.Listen("tcp4", "127.0.0.1:0")
+		testNoError(t, err)
+		defer tcpListener.Close()
+		engine := NewEngine(config)
+		defer engine.Close()
+		connectionLimiter := NewConnectionLimiter(config)
+		go Serve(config, tcpListener, engine, connectionLimiter)
+		databaseUrl := "postgres://" + config.User + "@" + tcpListener.Addr().String() + "/" + config.Database + "?sslmode=disable"
+		ctx := context.Background()
+
+		for i := 0; i < 2; i++ {
+			conn, err := pgx.Connect(ctx, databaseUrl)
+			testNoError(t, err)
+			defer conn.Close(ctx)
+		}
+
+		_, err = pgx.Connect(ctx, databaseUrl)
+
+		var pgError *pgconn.PgError
+		if !errors.As(err, &pgError) || pgError.Code != PG_ERROR_CODE_TOO_MANY_CONNECTIONS {
+			t.Errorf("Expected a too many connections error, got %v", err)
+		}
+		if connectionLimiter.ActiveConnections() != 2 {
+			t.Errorf("Expected 2 active connections, got %v", connectionLimiter.ActiveConnections())
+		}
+	})
+
+	t.Run("Closes rejected connections that don't send a startup message", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Server.MaxConnections = 0
+		tcpListener, err := net.Listen("tcp4", "127.0.0.1:0")
+		testNoError(t, err)
+		defer tcpListener.Close()
+		engine := NewEngine(config)
+		defer engine.Close()
+		go Serve(config, tcpListener, engine, NewConnectionLimiter(config))
+
+		conn, err := net.Dial("tcp4", tcpListener.Addr().String())
+		testNoError(t, err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(2 * REJECTED_CONNECTION_TIMEOUT))
+		_, err = conn.Read(make([]byte, 1))
+
+		if err != io.EOF {
+			t.Errorf("Expected the connection to be closed, got %v", err)
+		}
+	})
+
+	t.Run("Keeps accepting connections after an accept error", func(t *testing.T) {
+		config := loadTestConfig()
+		tcpListener, err := net.Listen("tcp4", "127.0.0.1:0")
+		testNoError(t, err)
+		defer tcpListener.Close()
+		engine := NewEngine(config)
+		defer engine.Close()
+		failingListener := &testFailingListener{Listener: tcpListener, errs: []error{syscall.EMFILE, syscall.EMFILE}}
+		go Serve(config, failingListener, engine, NewConnectionLimiter(config))
+		databaseUrl := "postgres://" + config.User + "@" + tcpListener.Addr().String() + "/" + config.Database + "?sslmode=disable"
+		ctx := context.Background()
+
+		conn, err := pgx.Connect(ctx, databaseUrl)
+
+		testNoError(t, err)
+		if err == nil {
+			conn.Close(ctx)
+		}
+	})
+}
+
+type testFailingListener struct {
+	net.Listener
+	errs []error
+}
+
+func (listener *testFailingListener) Accept() (net.Conn, error) {
+	if len(listener.errs) > 0 {
+		err := listener.errs[0]
+		listener.errs = listener.errs[1:]
+		return nil, err
+	}
+	return listener.Listener.Accept()
+}
+
+func TestHandleStartup(t *testing.T) {
+	startServer := func(t *testing.T) (databaseUrl string) {
+		config := loadTestConfig()
+		tcpListener, err := net.Listen("tcp4", "127.0.0.1:0")
+		testNoError(t, err)
+		t.Cleanup(func() { tcpListener.Close() })
+		engine := NewEngine(config)
+		t.Cleanup(engine.Close)
+		go Serve(config, tcpListener, engine, NewConnectionLimiter(config))
+		return "postgres://" + config.User + "@" + tcpListener.Addr().String() + "/" + config.Database + "?sslmode=disable"
+	}
+
+	t.Run("Accepts the UTF8 client encoding", func(t *testing.T) {
+		databaseUrl := startServer(t)
+		ctx := context.Background()
+
+		conn, err := pgx.Connect(ctx, databaseUrl+"&client_encoding=UTF8")
+
+		testNoError(t, err)
+		if err == nil {
+			defer conn.Close(ctx)
+			if conn.PgConn().ParameterStatus("client_encoding") != "UTF8" {
+				t.Errorf("Expected the UTF8 client encoding, got %v", conn.PgConn().ParameterStatus("client_encoding"))
+			}
+		}
+	})
+
+	t.Run("Rejects an unsupported client encoding", func(t *testing.T) {
+		databaseUrl := startServer(t)
+
+		_, err := pgx.Connect(context.Background(), databaseUrl+"&client_encoding=LATIN1")
+
+		if err == nil || !strings.Contains(err.Error(), `client_encoding "LATIN1" is not supported, BemiDB only supports UTF8`) {
+			t.Errorf("Expected an unsupported encoding error, got %v", err)
+		}
+	})
+}
+
+func TestNewTcpListener(t *testing.T) {
+	t.Run("Enables TCP keepalive on accepted connections", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Host = "127.0.0.1"
+		config.Port = "0"
+		config.Server.TCPKeepAlive = 30 * time.Second
+
+		if !testAcceptedConnKeepAlive(t, config) {
+			t.Errorf("Expected TCP keepalive to be enabled")
+		}
+	})
+
+	t.Run("Disables TCP keepalive on accepted connections", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Host = "127.0.0.1"
+		config.Port = "0"
+		config.Server.TCPKeepAlive = 0
+
+		if testAcceptedConnKeepAlive(t, config) {
+			t.Errorf("Expected TCP keepalive to be disabled")
+		}
+	})
+}
+
+func TestWithQueryKeepAlive(t *testing.T) {
+	t.Run("Sends keepalive messages while a query is running", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Server.QueryKeepAlive = 5 * time.Millisecond
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		postgres := NewPostgres(config, &serverConn)
+		received := make(chan []byte)
+		go func() {
+			bytes, _ := io.ReadAll(clientConn)
+			received <- bytes
+		}()
+
+		postgres.withQueryKeepAlive(func() { time.Sleep(50 * time.Millisecond) })
+		postgres.Close()
+
+		keepAliveMessage, _ := (&pgproto3.ParameterStatus{Name: "server_version", Value: PG_VERSION}).Encode(nil)
+		bytes := <-received
+		if len(bytes) == 0 || len(bytes)%len(keepAliveMessage) != 0 || string(bytes[:len(keepAliveMessage)]) != string(keepAliveMessage) {
+			t.Errorf("Expected keepalive messages, got %v", bytes)
+		}
+	})
+
+	t.Run("Doesn't send keepalive messages when disabled", func(t *testing.T) {
+		config := loadTestConfig()
+		config.Server.QueryKeepAlive = 0
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		postgres := NewPostgres(config, &serverConn)
+		received := make(chan []byte)
+		go func() {
+			bytes, _ := io.ReadAll(clientConn)
+			received <- bytes
+		}()
+
+		postgres.withQueryKeepAlive(func() { time.Sleep(20 * time.Millisecond) })
+		postgres.Close()
+
+		if bytes := <-received; len(bytes) != 0 {
+			t.Errorf("Expected no keepalive messages, got %v", bytes)
+		}
+	})
+}
+
+func TestWriteMessages(t *testing.T) {
+	t.Run("Writes streamed messages and ends the stream with an error response if reading it fails", func(t *testing.T) {
+		config := loadTestConfig()
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		postgres := NewPostgres(config, &serverConn)
+		received := make(chan []byte)
+		go func() {
+			bytes, _ := io.ReadAll(clientConn)
+			received <- bytes
+		}()
+		rowCount := 0
+		closed := false
+		stream := NewMessageStream(func() (pgproto3.Message, error) {
+			rowCount++
+			if rowCount > 3 {
+				return nil, errors.New("connection lost")
+			}
+			return &pgproto3.DataRow{Values: [][]byte{make([]byte, MESSAGE_STREAM_CHUNK_SIZE/2)}}, nil
+		}, func() { closed = true })
+
+		postgres.writeMessages(&pgproto3.RowDescription{}, stream, &pgproto3.ReadyForQuery{TxStatus: PG_TX_STATUS_IDLE})
+		postgres.Close()
+
+		frontend := pgproto3.NewFrontend(strings.NewReader(string(<-received)), nil)
+		var messageTypes []string
+		for {
+			message, err := frontend.Receive()
+			if err != nil {
+				break
+			}
+			switch message := message.(type) {
+			case *pgproto3.ErrorResponse:
+				messageTypes = append(messageTypes, "ErrorResponse:"+message.Message)
+			default:
+				messageTypes = append(messageTypes, reflect.TypeOf(message).Elem().Name())
+			}
+		}
+		expectedMessageTypes := []string{"RowDescription", "DataRow", "DataRow", "DataRow", "ErrorResponse:connection lost", "ReadyForQuery"}
+		if !reflect.DeepEqual(messageTypes, expectedMessageTypes) {
+			t.Errorf("Expected messages %v, got %v", expectedMessageTypes, messageTypes)
+		}
+		if !closed {
+			t.Errorf("Expected the stream to be closed")
+		}
+	})
+}
+
+func testAcceptedConnKeepAlive(t *testing.T, config *Config) bool {
+	tcpListener := NewTcpListener(config)
+	defer tcpListener.Close()
+
+	clientConn, err := net.Dial("tcp4", tcpListener.Addr().String())
+	testNoError(t, err)
+	defer clientConn.Close()
+	conn, err := AcceptConnection(tcpListener)
+	testNoError(t, err)
+	defer conn.Close()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	testNoError(t, err)
+	var keepAlive int
+	err = rawConn.Control(func(fd uintptr) {
+		keepAlive, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+	})
+	testNoError(t, err)
+
+	return keepAlive != 0
+}
@@ -0,0 +1,995 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	duckDb "github.com/marcboeker/go-duckdb"
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+const (
+	FALLBACK_SQL_QUERY = "SELECT 1"
+)
+
+// DuckDB's infinity and -infinity timestamps and dates as returned by the driver
+var (
+	DUCKDB_TIMESTAMP_INFINITY          = time.UnixMicro(math.MaxInt64)
+	DUCKDB_TIMESTAMP_NEGATIVE_INFINITY = time.UnixMicro(-math.MaxInt64)
+	DUCKDB_DATE_INFINITY               = time.Date(5881580, time.July, 11, 0, 0, 0, 0, time.UTC)
+	DUCKDB_DATE_NEGATIVE_INFINITY      = time.Date(-5877641, time.June, 24, 0, 0, 0, 0, time.UTC)
+)
+
+var ERROR_STATEMENT_TIMEOUT = errors.New("canceling statement due to statement timeout")
+
+type QueryHandler struct {
+	duckdb         *Duckdb
+	icebergReader  *IcebergReader
+	selectRemapper *SelectRemapper
+	session        *QuerySession
+	config         *Config
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type PreparedStatement struct {
+	Name      string
+	Query     string
+	Statement *sql.Stmt
+	Variables []interface{}
+	Portal    string
+	Rows      *sql.Rows
+	// Local copies of the tables read by the statement, removed once it has been executed
+	LocalCopyDirPaths []string
+	// Whether the statement reads passthrough tables and runs on the source database, and its data rows once described
+	Passthrough         bool
+	PassthroughDataRows *MessageStream
+}
+
+// Closes the source database query of a passthrough statement that was described but not executed
+func (preparedStatement *PreparedStatement) Close() {
+	if preparedStatement.PassthroughDataRows != nil {
+		preparedStatement.PassthroughDataRows.Close()
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Messages written to the client one at a time as they're read instead of being buffered in memory,
+// e.g. the rows of a passthrough query. Next returns nil after the last message
+type MessageStream struct {
+	next   func() (pgproto3.Message, error)
+	close  func()
+	closed bool
+}
+
+func NewMessageStream(next func() (pgproto3.Message, error), close func()) *MessageStream {
+	return &MessageStream{next: next, close: close}
+}
+
+func (stream *MessageStream) Next() (pgproto3.Message, error) {
+	if stream.closed {
+		return nil, nil
+	}
+	return stream.next()
+}
+
+func (stream *MessageStream) Close() {
+	if stream.closed {
+		return
+	}
+	stream.closed = true
+	stream.close()
+}
+
+// Reads the remaining messages into memory and closes the stream
+func (stream *MessageStream) ReadAll() ([]pgproto3.Message, error) {
+	defer stream.Close()
+
+	var messages []pgproto3.Message
+	for {
+		message, err := stream.Next()
+		if err != nil {
+			return nil, err
+		}
+		if message == nil {
+			return messages, nil
+		}
+		messages = append(messages, message)
+	}
+}
+
+func (stream *MessageStream) Decode(data []byte) error {
+	return errors.New("message streams can't be decoded")
+}
+
+func (stream *MessageStream) Encode(dst []byte) ([]byte, error) {
+	messages, err := stream.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		dst, err = message.Encode(dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// Replaces message streams with the messages they contain, for callers that need the whole result
+func ReadMessageStreams(messages []pgproto3.Message) ([]pgproto3.Message, error) {
+	var readMessages []pgproto3.Message
+	for i, message := range messages {
+		stream, ok := message.(*MessageStream)
+		if !ok {
+			readMessages = append(readMessages, message)
+			continue
+		}
+		streamMessages, err := stream.ReadAll()
+		if err != nil {
+			for _, message := range messages[i+1:] {
+				if stream, ok := message.(*MessageStream); ok {
+					stream.Close()
+				}
+			}
+			return nil, err
+		}
+		readMessages = append(readMessages, streamMessages...)
+	}
+	return readMessages, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type NullDecimal struct {
+	Present bool
+	Value   duckDb.Decimal
+}
+
+func (nullDecimal *NullDecimal) Scan(value interface{}) error {
+	if value == nil {
+		nullDecimal.Present = false
+		return nil
+	}
+
+	nullDecimal.Present = true
+	nullDecimal.Value = value.(duckDb.Decimal)
+	return nil
+}
+
+// Formats the exact digits without trailing fractional zeros, e.g. 12345.60 -> 12345.6, since sums can exceed float64 precision
+func (nullDecimal NullDecimal) String() string {
+	if !nullDecimal.Present {
+		return ""
+	}
+
+	digits := new(big.Int).Abs(nullDecimal.Value.Value).String()
+	scale := int(nullDecimal.Value.Scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	formatted := digits
+	if scale > 0 {
+		formatted = strings.TrimRight(strings.TrimRight(digits[:len(digits)-scale]+"."+digits[len(digits)-scale:], "0"), ".")
+	}
+	if nullDecimal.Value.Value.Sign() < 0 {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type NullUint32 struct {
+	Present bool
+	Value   uint32
+}
+
+func (nullUint32 *NullUint32) Scan(value interface{}) error {
+	if value == nil {
+		nullUint32.Present = false
+		return nil
+	}
+
+	nullUint32.Present = true
+	nullUint32.Value = value.(uint32)
+	return nil
+}
+
+func (nullUint32 NullUint32) String() string {
+	if nullUint32.Present {
+		return fmt.Sprintf("%v", nullUint32.Value)
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type NullUint64 struct {
+	Present bool
+	Value   uint64
+}
+
+func (nullUint64 *NullUint64) Scan(value interface{}) error {
+	if value == nil {
+		nullUint64.Present = false
+		return nil
+	}
+
+	nullUint64.Present = true
+	nullUint64.Value = value.(uint64)
+	return nil
+}
+
+func (nullUint64 NullUint64) String() string {
+	if nullUint64.Present {
+		return fmt.Sprintf("%v", nullUint64.Value)
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// DuckDB returns UUIDs as 16 bytes, e.g. from uuid() or gen_random_uuid()
+type NullUuid struct {
+	Present bool
+	Value   uuid.UUID
+}
+
+func (nullUuid *NullUuid) Scan(value interface{}) error {
+	if value == nil {
+		nullUuid.Present = false
+		return nil
+	}
+
+	parsedUuid, err := uuid.FromBytes(value.([]byte))
+	if err != nil {
+		return err
+	}
+	nullUuid.Present = true
+	nullUuid.Value = parsedUuid
+	return nil
+}
+
+func (nullUuid NullUuid) String() string {
+	if nullUuid.Present {
+		return nullUuid.Value.String()
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type NullArray struct {
+	Present bool
+	Value   []interface{}
+}
+
+func (nullArray *NullArray) Scan(value interface{}) error {
+	if value == nil {
+		nullArray.Present = false
+		return nil
+	}
+
+	nullArray.Present = true
+	nullArray.Value = value.([]interface{})
+	return nil
+}
+
+func (nullArray NullArray) String() string {
+	if nullArray.Present {
+		var stringVals []string
+		for _, v := range nullArray.Value {
+			switch v.(type) {
+			case nil:
+				stringVals = append(stringVals, "NULL")
+			case []uint8:
+				stringVals = append(stringVals, fmt.Sprintf("%s", v))
+			default:
+				stringVals = append(stringVals, fmt.Sprintf("%v", v))
+			}
+		}
+		buffer := &bytes.Buffer{}
+		csvWriter := csv.NewWriter(buffer)
+		err := csvWriter.Write(stringVals)
+		if err != nil {
+			return ""
+		}
+		csvWriter.Flush()
+		return "{" + strings.TrimRight(buffer.String(), "\n") + "}"
+	}
+	return ""
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func NewQueryHandler(config *Config, duckdb *Duckdb, icebergReader *IcebergReader) *QueryHandler {
+	session := &QuerySession{}
+	queryHandler := &QueryHandler{
+		duckdb:         duckdb,
+		icebergReader:  icebergReader,
+		selectRemapper: NewSelectRemapper(config, icebergReader, duckdb, session),
+		session:        session,
+		config:         config,
+	}
+
+	queryHandler.createSchemas()
+
+	return queryHandler
+}
+
+// Returns a query handler sharing the same DuckDB connection with its own session settings, e.g. for a client connection
+func (queryHandler *QueryHandler) NewSession() *QueryHandler {
+	session := &QuerySession{}
+	return &QueryHandler{
+		duckdb:         queryHandler.duckdb,
+		icebergReader:  queryHandler.icebergReader,
+		selectRemapper: NewSelectRemapper(queryHandler.config, queryHandler.icebergReader, queryHandler.duckdb, session),
+		session:        session,
+		config:         queryHandler.config,
+	}
+}
+
+// Returns the whole result, with streamed messages read into memory
+func (queryHandler *QueryHandler) HandleQuery(originalQuery string) ([]pgproto3.Message, error) {
+	messages, err := queryHandler.HandleQueryContext(context.Background(), originalQuery)
+	if err != nil {
+		return nil, err
+	}
+	return ReadMessageStreams(messages)
+}
+
+// Returns the result, which can contain message streams that must be read or closed
+
+func (queryHandler *QueryHandler) HandleQueryContext(ctx context.Context, originalQuery string) ([]pgproto3.Message, error) {
+	// Empty, whitespace-only or comment-only query, e.g. "-- ping" sent by clients to check the connection
+	if queryHandler.isEmptyQuery(originalQuery) {
+		return []pgproto3.Message{&pgproto3.EmptyQueryResponse{}}, nil
+	}
+	defer func() {
+		queryHandler.removeLocalCopies(queryHandler.selectRemapper.remapperTable.TakeLocalCopyDirPaths())
+		queryHandler.selectRemapper.remapperTable.TakePassthroughSchemaTables()
+	}()
+
+	if copyStmt := queryHandler.parseCopyStatement(originalQuery); copyStmt != nil {
+		return queryHandler.HandleCopyQuery(copyStmt)
+	}
+	if explainStmt := queryHandler.parseExplainStatement(originalQuery); explainStmt != nil {
+		return queryHandler.HandleExplainQuery(explainStmt)
+	}
+
+	// /*+ duckdb: SET threads=2 */ SELECT ... -> apply the settings only while running the query
+	hintSettings, err := ParseDuckdbHint(originalQuery)
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't parse query hint:", originalQuery+"\n"+err.Error())
+		return nil, err
+	}
+
+	query, err := queryHandler.remapQuery(originalQuery)
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't map query:", originalQuery+"\n"+err.Error())
+		return nil, err
+	}
+	if passthroughSchemaTables := queryHandler.selectRemapper.remapperTable.TakePassthroughSchemaTables(); len(passthroughSchemaTables) > 0 {
+		LogDebug(queryHandler.config, "Querying passthrough tables via the source database:", passthroughSchemaTables)
+		return queryHandler.HandlePassthroughQuery(ctx, originalQuery, nil)
+	}
+
+	ctx, cancel := queryHandler.statementContext(ctx)
+	defer cancel()
+
+	settings := append(queryHandler.sessionDuckdbSettings(), hintSettings...)
+	rows, release, err := queryHandler.duckdb.QueryContextWithSettings(ctx, query, settings)
+	if err != nil && queryHandler.isNumericAggregateOverflow(err) {
+		query, err = queryHandler.widenNumericAggregates(query)
+		if err == nil {
+			rows, release, err = queryHandler.duckdb.QueryContextWithSettings(ctx, query, settings)
+		}
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ERROR_STATEMENT_TIMEOUT
+		}
+		errorMessage := err.Error()
+
+		if errorMessage == "Binder Error: UNNEST requires a single list as input" {
+			// https://github.com/duckdb/duckdb/issues/11693
+			LogWarn(queryHandler.config, "Couldn't handle query via DuckDB:", query+"\n"+err.Error())
+			return queryHandler.HandleQueryContext(ctx, FALLBACK_SQL_QUERY)
+		} else {
+			LogError(queryHandler.config, "Couldn't handle query via DuckDB:", query+"\n"+err.Error())
+			return nil, err
+		}
+	}
+	defer release()
+	defer rows.Close()
+
+	var messages []pgproto3.Message
+	descriptionMessages, err := queryHandler.rowsToDescriptionMessages(rows, query)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, descriptionMessages...)
+	dataMessages, err := queryHandler.rowsToDataMessages(rows, query)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ERROR_STATEMENT_TIMEOUT
+		}
+		return nil, err
+	}
+	messages = append(messages, dataMessages...)
+	return messages, nil
+}
+
+func (queryHandler *QueryHandler) HandleParseQuery(message *pgproto3.Parse) ([]pgproto3.Message, *PreparedStatement, error) {
+	ctx := context.Background()
+	originalQuery := string(message.Query)
+	query, err := queryHandler.remapQuery(originalQuery)
+	localCopyDirPaths := queryHandler.selectRemapper.remapperTable.TakeLocalCopyDirPaths()
+	passthroughSchemaTables := queryHandler.selectRemapper.remapperTable.TakePassthroughSchemaTables()
+	if err != nil {
+		queryHandler.removeLocalCopies(localCopyDirPaths)
+		LogError(queryHandler.config, "Couldn't map query:", originalQuery+"\n"+err.Error())
+		return nil, nil, err
+	}
+	if len(passthroughSchemaTables) > 0 {
+		queryHandler.removeLocalCopies(localCopyDirPaths)
+		LogDebug(queryHandler.config, "Querying passthrough tables via the source database:", passthroughSchemaTables)
+		preparedStatement := &PreparedStatement{Name: message.Name, Query: originalQuery, Passthrough: true}
+		return []pgproto3.Message{&pgproto3.ParseComplete{}}, preparedStatement, nil
+	}
+
+	statement, err := queryHandler.duckdb.PrepareContext(ctx, query)
+	if err != nil {
+		queryHandler.removeLocalCopies(localCopyDirPaths)
+		LogError(queryHandler.config, "Couldn't prepare query via DuckDB:", query+"\n"+err.Error())
+		return nil, nil, err
+	}
+
+	preparedStatement := &PreparedStatement{
+		Name:              message.Name,
+		Query:             query,
+		Statement:         statement,
+		LocalCopyDirPaths: localCopyDirPaths,
+	}
+
+	messages := []pgproto3.Message{&pgproto3.ParseComplete{}}
+
+	return messages, preparedStatement, nil
+}
+
+func (queryHandler *QueryHandler) HandleBindQuery(message *pgproto3.Bind, preparedStatement *PreparedStatement) ([]pgproto3.Message, *PreparedStatement, error) {
+	if message.PreparedStatement != preparedStatement.Name {
+		LogError(queryHandler.config, "Prepared statement mismatch:", message.PreparedStatement, "instead of", preparedStatement.Name)
+		return nil, nil, errors.New("Prepared statement mismatch")
+	}
+
+	var variables []interface{}
+	paramFormatCodes := message.ParameterFormatCodes
+
+	for i, param := range message.Parameters {
+		if param == nil {
+			variables = append(variables, nil)
+			continue
+		}
+
+		textFormat := true
+		if len(paramFormatCodes) == 1 {
+			textFormat = paramFormatCodes[0] == 0
+		} else if len(paramFormatCodes) > 1 {
+			textFormat = paramFormatCodes[i] == 0
+		}
+
+		if textFormat {
+			variables = append(variables, string(param))
+		} else {
+			variables = append(variables, int64(binary.BigEndian.Uint64(param)))
+		}
+	}
+
+	LogDebug(queryHandler.config, "Bound variables:", variables)
+	preparedStatement.Variables = variables
+	preparedStatement.Portal = message.DestinationPortal
+
+	messages := []pgproto3.Message{&pgproto3.BindComplete{}}
+
+	return messages, preparedStatement, nil
+}
+
+func (queryHandler *QueryHandler) HandleDescribeQuery(message *pgproto3.Describe, preparedStatement *PreparedStatement) ([]pgproto3.Message, *PreparedStatement, error) {
+	switch message.ObjectType {
+	case 'S': // Statement
+		if message.Name != preparedStatement.Query {
+			LogError(queryHandler.config, "Statement mismatch:", message.Name, "instead of", preparedStatement.Query)
+			return nil, nil, errors.New("Statement mismatch")
+		}
+	case 'P': // Portal
+		if message.Name != preparedStatement.Portal {
+			LogError(queryHandler.config, "Portal mismatch:", message.Name, "instead of", preparedStatement.Portal)
+			return nil, nil, errors.New("Portal mismatch")
+		}
+	}
+
+	if preparedStatement.Passthrough {
+		preparedStatement.Close()
+		descriptionMessage, dataRows, err := queryHandler.queryPassthrough(context.Background(), preparedStatement.Query, preparedStatement.Variables)
+		if err != nil {
+			return nil, nil, err
+		}
+		preparedStatement.PassthroughDataRows = dataRows
+		return []pgproto3.Message{descriptionMessage}, preparedStatement, nil
+	}
+
+	rows, err := queryHandler.queryPreparedStatement(context.Background(), preparedStatement)
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't execute prepared statement via DuckDB:", preparedStatement.Query+"\n"+err.Error())
+		return nil, nil, err
+	}
+	preparedStatement.Rows = rows
+
+	messages, err := queryHandler.rowsToDescriptionMessages(preparedStatement.Rows, preparedStatement.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages, preparedStatement, nil
+}
+
+func (queryHandler *QueryHandler) HandleExecuteQuery(message *pgproto3.Execute, preparedStatement *PreparedStatement) ([]pgproto3.Message, error) {
+	if message.Portal != preparedStatement.Portal {
+		LogError(queryHandler.config, "Portal mismatch:", message.Portal, "instead of", preparedStatement.Portal)
+		return nil, errors.New("Portal mismatch")
+	}
+
+	if preparedStatement.Passthrough {
+		dataRows := preparedStatement.PassthroughDataRows
+		preparedStatement.PassthroughDataRows = nil
+		if dataRows == nil {
+			var err error
+			_, dataRows, err = queryHandler.queryPassthrough(context.Background(), preparedStatement.Query, preparedStatement.Variables)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return []pgproto3.Message{dataRows}, nil
+	}
+
+	defer queryHandler.removeLocalCopies(preparedStatement.LocalCopyDirPaths)
+
+	ctx, cancel := queryHandler.statementContext(context.Background())
+	defer cancel()
+
+	if preparedStatement.Rows == nil {
+		rows, err := queryHandler.queryPreparedStatement(ctx, preparedStatement)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, ERROR_STATEMENT_TIMEOUT
+			}
+			LogError(queryHandler.config, "Couldn't execute prepared statement via DuckDB:", preparedStatement.Query+"\n"+err.Error())
+			return nil, err
+		}
+		preparedStatement.Rows = rows
+	}
+
+	defer preparedStatement.Rows.Close()
+
+	return queryHandler.rowsToDataMessages(preparedStatement.Rows, preparedStatement.Query)
+}
+
+// Re-prepares the statement with widened aggregates if DuckDB's DECIMAL aggregate overflowed
+func (queryHandler *QueryHandler) queryPreparedStatement(ctx context.Context, preparedStatement *PreparedStatement) (*sql.Rows, error) {
+	rows, err := preparedStatement.Statement.QueryContext(ctx, preparedStatement.Variables...)
+	if err == nil || !queryHandler.isNumericAggregateOverflow(err) {
+		return rows, err
+	}
+
+	query, err := queryHandler.widenNumericAggregates(preparedStatement.Query)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := queryHandler.duckdb.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	preparedStatement.Statement.Close()
+	preparedStatement.Statement = statement
+
+	return preparedStatement.Statement.QueryContext(ctx, preparedStatement.Variables...)
+}
+
+// Removes the local copies of tables that DuckDB couldn't read from the storage directly
+func (queryHandler *QueryHandler) removeLocalCopies(localCopyDirPaths []string) {
+	for _, localCopyDirPath := range localCopyDirPaths {
+		err := os.RemoveAll(localCopyDirPath)
+		if err != nil {
+			LogWarn(queryHandler.config, "Couldn't remove local copy of Iceberg table:", err)
+		}
+	}
+}
+
+func (queryHandler *QueryHandler) createSchemas() {
+	ctx := context.Background()
+	schemas, err := queryHandler.icebergReader.Schemas()
+	PanicIfError(err)
+
+	for _, schema := range schemas {
+		_, err := queryHandler.duckdb.ExecContext(
+			ctx,
+			"CREATE SCHEMA IF NOT EXISTS \"$schema\"",
+			map[string]string{"schema": schema},
+		)
+		PanicIfError(err)
+	}
+}
+
+func (queryHandler *QueryHandler) rowsToDescriptionMessages(rows *sql.Rows, query string) ([]pgproto3.Message, error) {
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't get column types", query+"\n"+err.Error())
+		return nil, err
+	}
+
+	var messages []pgproto3.Message
+	messages = append(messages, queryHandler.generateRowDescription(cols))
+	return messages, nil
+}
+
+func (queryHandler *QueryHandler) rowsToDataMessages(rows *sql.Rows, query string) ([]pgproto3.Message, error) {
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't get column types", query+"\n"+err.Error())
+		return nil, err
+	}
+
+	var messages []pgproto3.Message
+	for rows.Next() {
+		dataRow, err := queryHandler.generateDataRow(rows, cols)
+		if err != nil {
+			LogError(queryHandler.config, "Couldn't get data row", query+"\n"+err.Error())
+			return nil, err
+		}
+		messages = append(messages, dataRow)
+	}
+	messages = append(messages, &pgproto3.CommandComplete{CommandTag: []byte(FALLBACK_SQL_QUERY)})
+	return messages, nil
+}
+
+// SET TIME ZONE of the session, for DuckDB functions on timestamptz values like date_trunc(). Hint settings can override it
+func (queryHandler *QueryHandler) sessionDuckdbSettings() []DuckdbSetting {
+	settings := queryHandler.session.DuckdbSettings()
+	if len(settings) == 0 || !queryHandler.duckdb.SupportsTimeZones() {
+		return nil
+	}
+	return settings
+}
+
+// Cancels the query after SET statement_timeout of the session
+func (queryHandler *QueryHandler) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if queryHandler.session.StatementTimeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, queryHandler.session.StatementTimeout)
+}
+
+func (queryHandler *QueryHandler) isEmptyQuery(query string) bool {
+	queryTree, err := pgQuery.Parse(query)
+	return err == nil && len(queryTree.Stmts) == 0
+}
+
+func (queryHandler *QueryHandler) remapQuery(query string) (string, error) {
+	queryTree, err := pgQuery.Parse(query)
+	if err != nil {
+		LogError(queryHandler.config, "Error parsing query:", query+"\n"+err.Error())
+		return "", err
+	}
+
+	if len(queryTree.Stmts) == 0 {
+		return FALLBACK_SQL_QUERY, nil
+	}
+
+	err = queryHandler.checkUnsupportedFeatures(queryTree)
+	if err != nil {
+		return "", err
+	}
+
+	for i, stmt := range queryTree.Stmts {
+		remappedStmt, err := queryHandler.remapStatement(stmt)
+		if err != nil {
+			return "", err
+		}
+		queryTree.Stmts[i] = remappedStmt
+	}
+
+	return NewQueryParserUtils(queryHandler.config).DeparseForDuckdb(queryTree)
+}
+
+func (queryHandler *QueryHandler) remapStatement(stmt *pgQuery.RawStmt) (*pgQuery.RawStmt, error) {
+	node := stmt.Stmt
+
+	switch {
+
+	case node != nil && node.GetSelectStmt() != nil:
+		err := queryHandler.selectRemapper.remapTableNameCase(node)
+		if err != nil {
+			return nil, err
+		}
+		err = queryHandler.selectRemapper.checkIcebergSnapshots(node)
+		if err != nil {
+			return nil, err
+		}
+		queryHandler.selectRemapper.remapRanges(node)
+		err = queryHandler.selectRemapper.remapSimilarTo(node)
+		if err != nil {
+			return nil, err
+		}
+		err = queryHandler.selectRemapper.remapLimits(node)
+		if err != nil {
+			return nil, err
+		}
+		queryHandler.selectRemapper.remapTrigramOperators(node)
+		queryHandler.selectRemapper.remapJsonb(node)
+		queryHandler.selectRemapper.remapArrayFunctions(node)
+		queryHandler.selectRemapper.remapEncodingFunctions(node)
+		queryHandler.selectRemapper.remapSchemaFunctions(node)
+		queryHandler.selectRemapper.remapSettingFunctions(node)
+		queryHandler.selectRemapper.remapEnumComparisons(node)
+		selectStmt := stmt.Stmt.GetSelectStmt()
+		remappedSelect := queryHandler.selectRemapper.remapSelectStatement(selectStmt, 0)
+		err = queryHandler.selectRemapper.remapperTable.TakeRemapTableError()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Stmt = &pgQuery.Node{
+			Node: &pgQuery.Node_SelectStmt{
+				SelectStmt: remappedSelect,
+			},
+		}
+		return stmt, nil
+
+	case node != nil && node.GetVariableSetStmt() != nil:
+		err := queryHandler.session.ApplySetStatement(node.GetVariableSetStmt())
+		if err != nil {
+			return nil, err
+		}
+		return queryHandler.selectRemapper.RemapSetStatement(stmt), nil
+
+	case node.GetDiscardStmt() != nil:
+		fallbackStmt, _ := pgQuery.Parse(FALLBACK_SQL_QUERY)
+		return fallbackStmt.Stmts[0], nil
+
+	case node != nil && node.GetVariableShowStmt() != nil:
+		variableShowStmt := node.GetVariableShowStmt()
+		if value, ok := queryHandler.selectRemapper.Setting(variableShowStmt.Name); ok {
+			parserTable := queryHandler.selectRemapper.parserTable
+			settingStmt, _ := pgQuery.Parse("SELECT " + parserTable.quoteString(value) + " AS " + parserTable.quoteIdentifier(variableShowStmt.Name))
+			return settingStmt.Stmts[0], nil
+		}
+		fallbackStmt, _ := pgQuery.Parse(FALLBACK_SQL_QUERY)
+		return fallbackStmt.Stmts[0], nil
+
+	default:
+		LogDebug(queryHandler.config, "Query tree:", stmt, node)
+		return nil, errors.New("unsupported query type")
+	}
+}
+
+func (queryHandler *QueryHandler) generateRowDescription(cols []*sql.ColumnType) *pgproto3.RowDescription {
+	description := pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{}}
+
+	for _, col := range cols {
+		description.Fields = append(description.Fields, pgproto3.FieldDescription{
+			Name:                 []byte(col.Name()),
+			TableOID:             0,
+			TableAttributeNumber: 0,
+			DataTypeOID:          pgtype.TextOID,
+			DataTypeSize:         -1,
+			TypeModifier:         -1,
+			Format:               0,
+		})
+	}
+	return &description
+}
+
+func (queryHandler *QueryHandler) generateDataRow(rows *sql.Rows, cols []*sql.ColumnType) (*pgproto3.DataRow, error) {
+	valuePtrs, err := queryHandler.scanRow(rows, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgproto3.DataRow{Values: queryHandler.formatRowValues(cols, valuePtrs)}, nil
+}
+
+// Scans the current row into pointers to nullable values of the column types
+func (queryHandler *QueryHandler) scanRow(rows *sql.Rows, cols []*sql.ColumnType) ([]interface{}, error) {
+	valuePtrs := make([]interface{}, len(cols))
+	for i, col := range cols {
+		switch col.ScanType().String() {
+		case "int16", "int32": // int16 is for smallint aggregates like bit_and
+			var value sql.NullInt32
+			valuePtrs[i] = &value
+		case "int64", "*big.Int":
+			var value sql.NullInt64
+			valuePtrs[i] = &value
+		case "uint32": // xid
+			var value NullUint32
+			valuePtrs[i] = &value
+		case "uint64": // xid8
+			var value NullUint64
+			valuePtrs[i] = &value
+		case "float64", "float32":
+			var value sql.NullFloat64
+			valuePtrs[i] = &value
+		case "string", "[]uint8":
+			if col.DatabaseTypeName() == "UUID" {
+				var value NullUuid
+				valuePtrs[i] = &value
+				continue
+			}
+			var value sql.NullString
+			valuePtrs[i] = &value
+		case "bool":
+			var value sql.NullBool
+			valuePtrs[i] = &value
+		case "time.Time":
+			var value sql.NullTime
+			valuePtrs[i] = &value
+		case "duckdb.Decimal":
+			var value NullDecimal
+			valuePtrs[i] = &value
+		case "[]interface {}":
+			var value NullArray
+			valuePtrs[i] = &value
+		default:
+			panic("Unsupported queried type: " + col.ScanType().String())
+		}
+	}
+
+	err := rows.Scan(valuePtrs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return valuePtrs, nil
+}
+
+// Formats scanned values in the text format, with nil for NULL
+func (queryHandler *QueryHandler) formatRowValues(cols []*sql.ColumnType, valuePtrs []interface{}) [][]byte {
+	var values [][]byte
+	for i, valuePtr := range valuePtrs {
+		switch value := valuePtr.(type) {
+		case *sql.NullInt32:
+			if value.Valid {
+				values = append(values, []byte(strconv.Itoa(int(value.Int32))))
+			} else {
+				values = append(values, nil)
+			}
+		case *sql.NullInt64:
+			if value.Valid {
+				values = append(values, []byte(strconv.Itoa(int(value.Int64))))
+			} else {
+				values = append(values, nil)
+			}
+		case *NullUint32:
+			if value.Present {
+				values = append(values, []byte(value.String()))
+			} else {
+				values = append(values, nil)
+			}
+		case *NullUint64:
+			if value.Present {
+				values = append(values, []byte(value.String()))
+			} else {
+				values = append(values, nil)
+			}
+		case *sql.NullFloat64:
+			if value.Valid {
+				values = append(values, []byte(queryHandler.formatFloat(value.Float64)))
+			} else {
+				values = append(values, nil)
+			}
+		case *sql.NullString:
+			if value.Valid {
+				values = append(values, []byte(value.String))
+			} else {
+				values = append(values, nil)
+			}
+		case *sql.NullBool:
+			if value.Valid {
+				values = append(values, []byte(fmt.Sprintf("%v", value.Bool)))
+			} else {
+				values = append(values, nil)
+			}
+		case *sql.NullTime:
+			if value.Valid {
+				if infinity, ok := queryHandler.formatInfiniteTime(value.Time); ok {
+					values = append(values, []byte(infinity))
+					continue
+				}
+				switch cols[i].DatabaseTypeName() {
+				case "DATE":
+					values = append(values, []byte(value.Time.Format("2006-01-02")))
+				case "TIME":
+					values = append(values, []byte(value.Time.Format("15:04:05.999999")))
+				case "TIMESTAMP":
+					values = append(values, []byte(value.Time.Format("2006-01-02 15:04:05.999999")))
+				case "TIMESTAMPTZ":
+					values = append(values, []byte(queryHandler.formatTimestamptz(value.Time)))
+				default:
+					panic("Unsupported type: " + cols[i].DatabaseTypeName())
+				}
+			} else {
+				values = append(values, nil)
+			}
+		case *NullUuid:
+			if value.Present {
+				values = append(values, []byte(value.String()))
+			} else {
+				values = append(values, nil)
+			}
+		case *NullDecimal:
+			if value.Present {
+				values = append(values, []byte(value.String()))
+			} else {
+				values = append(values, nil)
+			}
+		case *NullArray:
+			if value.Present {
+				values = append(values, []byte(value.String()))
+			} else {
+				values = append(values, nil)
+			}
+		case *string:
+			values = append(values, []byte(*value))
+		default:
+			panic("Unsupported type: " + cols[i].ScanType().Name())
+		}
+	}
+
+	return values
+}
+
+// Formats floats like Postgres, e.g. Infinity instead of +Inf
+func (queryHandler *QueryHandler) formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (queryHandler *QueryHandler) formatInfiniteTime(value time.Time) (infinity string, ok bool) {
+	switch {
+	case value.Equal(DUCKDB_TIMESTAMP_INFINITY), value.Equal(DUCKDB_DATE_INFINITY):
+		return PG_INFINITY, true
+	case value.Equal(DUCKDB_TIMESTAMP_NEGATIVE_INFINITY), value.Equal(DUCKDB_DATE_NEGATIVE_INFINITY):
+		return PG_NEGATIVE_INFINITY, true
+	}
+	return "", false
+}
+
+// Formats timestamptz values in the session time zone like Postgres, e.g. 2024-01-01 12:00:00.123456+05:30
+func (queryHandler *QueryHandler) formatTimestamptz(value time.Time) string {
+	value = value.In(queryHandler.session.Location())
+	_, offset := value.Zone()
+	if offset%3600 != 0 {
+		return value.Format("2006-01-02 15:04:05.999999-07:00")
+	}
+	return value.Format("2006-01-02 15:04:05.999999-07")
+}
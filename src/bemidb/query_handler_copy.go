@@ -0,0 +1,503 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+const (
+	COPY_FORMAT_TEXT   = "text"
+	COPY_FORMAT_CSV    = "csv"
+	COPY_FORMAT_BINARY = "binary"
+
+	COPY_TEXT_NULL      = "\\N"
+	COPY_TEXT_DELIMITER = "\t"
+	COPY_CSV_DELIMITER  = ","
+	COPY_CSV_QUOTE      = "\""
+)
+
+// https://www.postgresql.org/docs/current/sql-copy.html#id-1.9.3.55.9.4.5
+var COPY_BINARY_SIGNATURE = []byte("PGCOPY\n\377\r\n\000")
+
+var PG_OID_BY_DUCKDB_TYPE = map[string]uint32{
+	"BOOLEAN":   pgtype.BoolOID,
+	"TINYINT":   pgtype.Int2OID,
+	"SMALLINT":  pgtype.Int2OID,
+	"INTEGER":   pgtype.Int4OID,
+	"BIGINT":    pgtype.Int8OID,
+	"HUGEINT":   pgtype.NumericOID,
+	"UTINYINT":  pgtype.Int2OID,
+	"USMALLINT": pgtype.Int4OID,
+	"UINTEGER":  pgtype.Int8OID,
+	"UBIGINT":   pgtype.NumericOID,
+	"FLOAT":     pgtype.Float4OID,
+	"DOUBLE":    pgtype.Float8OID,
+	"DATE":      pgtype.DateOID,
+	"TIME":      pgtype.TimeOID,
+	"TIMESTAMP": pgtype.TimestampOID,
+	"UUID":      pgtype.UUIDOID,
+	"VARCHAR":   pgtype.TextOID,
+	"BLOB":      pgtype.ByteaOID,
+}
+
+type CopyOptions struct {
+	Format            string
+	Header            bool
+	Delimiter         string
+	Null              string
+	Quote             string   // CSV only
+	Escape            string   // CSV only
+	ForceQuoteAll     bool     // CSV only, FORCE_QUOTE *
+	ForceQuoteColumns []string // CSV only, FORCE_QUOTE (column, ...)
+}
+
+// Returns the COPY statement if the query is a single COPY statement
+func (queryHandler *QueryHandler) parseCopyStatement(query string) *pgQuery.CopyStmt {
+	queryTree, err := pgQuery.Parse(query)
+	if err != nil || len(queryTree.Stmts) != 1 || queryTree.Stmts[0].Stmt == nil {
+		return nil
+	}
+
+	return queryTree.Stmts[0].Stmt.GetCopyStmt()
+}
+
+// COPY { table [ ( column, ... ) ] | ( query ) } TO STDOUT [ [ WITH ] ( option, ... ) ]
+func (queryHandler *QueryHandler) HandleCopyQuery(copyStmt *pgQuery.CopyStmt) ([]pgproto3.Message, error) {
+	if copyStmt.IsFrom {
+		return nil, errors.New("COPY FROM is not supported")
+	}
+	if copyStmt.IsProgram || copyStmt.Filename != "" {
+		return nil, errors.New("COPY TO a file or a program is not supported, use COPY ... TO STDOUT")
+	}
+
+	copyOptions, err := queryHandler.parseCopyOptions(copyStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := queryHandler.remapCopySelect(copyStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Local copies of the tables are read while the rows are streamed
+	localCopyDirPaths := queryHandler.selectRemapper.remapperTable.TakeLocalCopyDirPaths()
+
+	rows, err := queryHandler.duckdb.QueryContext(context.Background(), query)
+	if err != nil {
+		queryHandler.removeLocalCopies(localCopyDirPaths)
+		LogError(queryHandler.config, "Couldn't handle query via DuckDB:", query+"\n"+err.Error())
+		return nil, err
+	}
+	closeRows := func() {
+		rows.Close()
+		queryHandler.removeLocalCopies(localCopyDirPaths)
+	}
+
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		closeRows()
+		LogError(queryHandler.config, "Couldn't get column types", query+"\n"+err.Error())
+		return nil, err
+	}
+
+	forceQuotes, err := queryHandler.copyForceQuotes(copyOptions, cols)
+	if err != nil {
+		closeRows()
+		return nil, err
+	}
+
+	formatCode := uint16(pgtype.TextFormatCode)
+	if copyOptions.Format == COPY_FORMAT_BINARY {
+		formatCode = uint16(pgtype.BinaryFormatCode)
+	}
+	columnFormatCodes := make([]uint16, len(cols))
+	for i := range cols {
+		columnFormatCodes[i] = formatCode
+	}
+
+	messages := []pgproto3.Message{
+		&pgproto3.CopyOutResponse{OverallFormat: byte(formatCode), ColumnFormatCodes: columnFormatCodes},
+	}
+
+	typeMap := pgtype.NewMap()
+	oids := make([]uint32, len(cols))
+	for i, col := range cols {
+		oids[i] = queryHandler.copyColumnOid(col.DatabaseTypeName())
+	}
+
+	switch copyOptions.Format {
+	case COPY_FORMAT_BINARY:
+		header := append([]byte{}, COPY_BINARY_SIGNATURE...)
+		header = binary.BigEndian.AppendUint32(header, 0) // Flags
+		header = binary.BigEndian.AppendUint32(header, 0) // Header extension length
+		messages = append(messages, &pgproto3.CopyData{Data: header})
+	case COPY_FORMAT_TEXT, COPY_FORMAT_CSV:
+		if copyOptions.Header {
+			var columnNames []string
+			for _, col := range cols {
+				columnNames = append(columnNames, col.Name())
+			}
+			messages = append(messages, &pgproto3.CopyData{Data: queryHandler.formatCopyTextRow(copyOptions, stringsToBytes(columnNames), nil)})
+		}
+	}
+
+	// Rows are sent one at a time as they're read, followed by the trailing messages
+	rowCount := 0
+	var trailingMessages []pgproto3.Message
+	completed := false
+	copyRows := NewMessageStream(func() (pgproto3.Message, error) {
+		if !completed && rows.Next() {
+			valuePtrs, err := queryHandler.scanRow(rows, cols)
+			if err != nil {
+				LogError(queryHandler.config, "Couldn't get data row", query+"\n"+err.Error())
+				return nil, err
+			}
+
+			var data []byte
+			if copyOptions.Format == COPY_FORMAT_BINARY {
+				data, err = queryHandler.formatCopyBinaryRow(typeMap, oids, cols, valuePtrs)
+				if err != nil {
+					LogError(queryHandler.config, "Couldn't encode binary COPY row", query+"\n"+err.Error())
+					return nil, err
+				}
+			} else {
+				data = queryHandler.formatCopyTextRow(copyOptions, queryHandler.formatRowValues(cols, valuePtrs), forceQuotes)
+			}
+
+			rowCount++
+			return &pgproto3.CopyData{Data: data}, nil
+		}
+
+		if !completed {
+			completed = true
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+			if copyOptions.Format == COPY_FORMAT_BINARY {
+				trailer := binary.BigEndian.AppendUint16(nil, 0xFFFF) // -1
+				trailingMessages = append(trailingMessages, &pgproto3.CopyData{Data: trailer})
+			}
+			trailingMessages = append(trailingMessages,
+				&pgproto3.CopyDone{},
+				&pgproto3.CommandComplete{CommandTag: []byte("COPY " + strconv.Itoa(rowCount))},
+			)
+		}
+
+		if len(trailingMessages) == 0 {
+			return nil, nil
+		}
+		message := trailingMessages[0]
+		trailingMessages = trailingMessages[1:]
+		return message, nil
+	}, closeRows)
+
+	return append(messages, copyRows), nil
+}
+
+func (queryHandler *QueryHandler) parseCopyOptions(copyStmt *pgQuery.CopyStmt) (CopyOptions, error) {
+	copyOptions := CopyOptions{Format: COPY_FORMAT_TEXT}
+	specifiedOptions := NewSet([]string{})
+
+	for _, optionNode := range copyStmt.Options {
+		option := optionNode.GetDefElem()
+		if specifiedOptions.Contains(option.Defname) {
+			return copyOptions, errors.New("conflicting or redundant options")
+		}
+		specifiedOptions.Add(option.Defname)
+
+		switch option.Defname {
+		case "format":
+			format := strings.ToLower(option.Arg.GetString_().GetSval())
+			if format != COPY_FORMAT_TEXT && format != COPY_FORMAT_CSV && format != COPY_FORMAT_BINARY {
+				return copyOptions, errors.New("COPY format \"" + format + "\" not recognized")
+			}
+			copyOptions.Format = format
+		case "header":
+			copyOptions.Header = option.Arg == nil || option.Arg.GetBoolean().GetBoolval() || strings.ToLower(option.Arg.GetString_().GetSval()) == "true" || strings.ToLower(option.Arg.GetString_().GetSval()) == "on"
+		case "delimiter":
+			copyOptions.Delimiter = option.Arg.GetString_().GetSval()
+		case "null":
+			copyOptions.Null = option.Arg.GetString_().GetSval()
+		case "quote":
+			copyOptions.Quote = option.Arg.GetString_().GetSval()
+		case "escape":
+			copyOptions.Escape = option.Arg.GetString_().GetSval()
+		case "force_quote":
+			if option.Arg.GetAStar() != nil {
+				copyOptions.ForceQuoteAll = true
+				continue
+			}
+			for _, columnNode := range option.Arg.GetList().GetItems() {
+				copyOptions.ForceQuoteColumns = append(copyOptions.ForceQuoteColumns, columnNode.GetString_().GetSval())
+			}
+		default:
+			return copyOptions, errors.New("COPY option \"" + option.Defname + "\" is not supported")
+		}
+	}
+
+	return copyOptions, queryHandler.validateCopyOptions(&copyOptions, specifiedOptions)
+}
+
+// Sets the defaults of the format and rejects the same combinations as Postgres
+func (queryHandler *QueryHandler) validateCopyOptions(copyOptions *CopyOptions, specifiedOptions *Set) error {
+	isCsv := copyOptions.Format == COPY_FORMAT_CSV
+
+	if copyOptions.Format == COPY_FORMAT_BINARY {
+		switch {
+		case specifiedOptions.Contains("delimiter"):
+			return errors.New("cannot specify DELIMITER in BINARY mode")
+		case specifiedOptions.Contains("null"):
+			return errors.New("cannot specify NULL in BINARY mode")
+		case copyOptions.Header:
+			return errors.New("cannot specify HEADER in BINARY mode")
+		}
+	}
+	if !isCsv {
+		switch {
+		case specifiedOptions.Contains("quote"):
+			return errors.New("COPY quote available only in CSV mode")
+		case specifiedOptions.Contains("escape"):
+			return errors.New("COPY escape available only in CSV mode")
+		case specifiedOptions.Contains("force_quote"):
+			return errors.New("COPY force quote available only in CSV mode")
+		}
+	}
+
+	if !specifiedOptions.Contains("delimiter") {
+		copyOptions.Delimiter = COPY_TEXT_DELIMITER
+		if isCsv {
+			copyOptions.Delimiter = COPY_CSV_DELIMITER
+		}
+	}
+	if !specifiedOptions.Contains("null") && !isCsv {
+		copyOptions.Null = COPY_TEXT_NULL
+	}
+	if !specifiedOptions.Contains("quote") && isCsv {
+		copyOptions.Quote = COPY_CSV_QUOTE
+	}
+	if !specifiedOptions.Contains("escape") && isCsv {
+		copyOptions.Escape = copyOptions.Quote
+	}
+
+	if copyOptions.Format == COPY_FORMAT_BINARY {
+		return nil
+	}
+
+	if len(copyOptions.Delimiter) != 1 {
+		return errors.New("COPY delimiter must be a single one-byte character")
+	}
+	if copyOptions.Delimiter == "\n" || copyOptions.Delimiter == "\r" {
+		return errors.New("COPY delimiter cannot be newline or carriage return")
+	}
+	if strings.ContainsAny(copyOptions.Null, "\r\n") {
+		return errors.New("COPY null representation cannot use newline or carriage return")
+	}
+	// Backslash sequences of the text format
+	if !isCsv && strings.Contains("\\.abcdefghijklmnopqrstuvwxyz0123456789", copyOptions.Delimiter) {
+		return errors.New("COPY delimiter cannot be \"" + copyOptions.Delimiter + "\"")
+	}
+	if strings.Contains(copyOptions.Null, copyOptions.Delimiter) {
+		return errors.New("COPY delimiter must not appear in the NULL specification")
+	}
+
+	if isCsv {
+		if len(copyOptions.Quote) != 1 {
+			return errors.New("COPY quote must be a single one-byte character")
+		}
+		if copyOptions.Quote == copyOptions.Delimiter {
+			return errors.New("COPY delimiter and quote must be different")
+		}
+		if len(copyOptions.Escape) != 1 {
+			return errors.New("COPY escape must be a single one-byte character")
+		}
+		if strings.Contains(copyOptions.Null, copyOptions.Quote) {
+			return errors.New("CSV quote character must not appear in the NULL specification")
+		}
+	}
+
+	return nil
+}
+
+// Returns whether the non-NULL values of each column are always quoted, or nil if no FORCE_QUOTE is specified
+func (queryHandler *QueryHandler) copyForceQuotes(copyOptions CopyOptions, cols []*sql.ColumnType) ([]bool, error) {
+	if !copyOptions.ForceQuoteAll && len(copyOptions.ForceQuoteColumns) == 0 {
+		return nil, nil
+	}
+
+	forceQuotes := make([]bool, len(cols))
+	for i := range cols {
+		forceQuotes[i] = copyOptions.ForceQuoteAll
+	}
+
+	for _, columnName := range copyOptions.ForceQuoteColumns {
+		found := false
+		for i, col := range cols {
+			if col.Name() == columnName {
+				forceQuotes[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.New("FORCE_QUOTE column \"" + columnName + "\" not referenced by COPY")
+		}
+	}
+
+	return forceQuotes, nil
+}
+
+// Converts the COPY source into a remapped SELECT query
+func (queryHandler *QueryHandler) remapCopySelect(copyStmt *pgQuery.CopyStmt) (string, error) {
+	selectNode := copyStmt.Query
+
+	if selectNode == nil {
+		var targetList []*pgQuery.Node
+		if len(copyStmt.Attlist) == 0 {
+			targetList = append(targetList, pgQuery.MakeResTargetNodeWithVal(pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeAStarNode()}, 0), 0))
+		} else {
+			for _, columnNode := range copyStmt.Attlist {
+				targetList = append(targetList, pgQuery.MakeResTargetNodeWithVal(pgQuery.MakeColumnRefNode([]*pgQuery.Node{columnNode}, 0), 0))
+			}
+		}
+
+		selectNode = &pgQuery.Node{
+			Node: &pgQuery.Node_SelectStmt{
+				SelectStmt: &pgQuery.SelectStmt{
+					TargetList: targetList,
+					FromClause: []*pgQuery.Node{{Node: &pgQuery.Node_RangeVar{RangeVar: copyStmt.Relation}}},
+				},
+			},
+		}
+	}
+
+	if selectNode.GetSelectStmt() == nil {
+		return "", errors.New("COPY is only supported for SELECT queries")
+	}
+
+	stmt, err := queryHandler.remapStatement(&pgQuery.RawStmt{Stmt: selectNode})
+	if err != nil {
+		return "", err
+	}
+
+	return NewQueryParserUtils(queryHandler.config).DeparseForDuckdb(&pgQuery.ParseResult{Stmts: []*pgQuery.RawStmt{stmt}})
+}
+
+func (queryHandler *QueryHandler) copyColumnOid(duckdbType string) uint32 {
+	if strings.HasPrefix(duckdbType, "DECIMAL") {
+		return pgtype.NumericOID
+	}
+
+	oid, ok := PG_OID_BY_DUCKDB_TYPE[duckdbType]
+	if !ok {
+		return pgtype.TextOID
+	}
+	return oid
+}
+
+func (queryHandler *QueryHandler) formatCopyTextRow(copyOptions CopyOptions, values [][]byte, forceQuotes []bool) []byte {
+	buffer := &bytes.Buffer{}
+
+	for i, value := range values {
+		if i > 0 {
+			buffer.WriteString(copyOptions.Delimiter)
+		}
+
+		switch {
+		case value == nil:
+			buffer.WriteString(copyOptions.Null)
+		case copyOptions.Format == COPY_FORMAT_CSV:
+			forceQuote := forceQuotes != nil && forceQuotes[i]
+			buffer.WriteString(queryHandler.escapeCopyCsvValue(copyOptions, string(value), forceQuote))
+		default:
+			buffer.WriteString(queryHandler.escapeCopyTextValue(copyOptions, string(value)))
+		}
+	}
+
+	buffer.WriteByte('\n')
+	return buffer.Bytes()
+}
+
+func (queryHandler *QueryHandler) escapeCopyTextValue(copyOptions CopyOptions, value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r", copyOptions.Delimiter, "\\"+copyOptions.Delimiter)
+	return replacer.Replace(value)
+}
+
+// Values equal to the NULL string (empty by default) are quoted to distinguish them from NULLs
+func (queryHandler *QueryHandler) escapeCopyCsvValue(copyOptions CopyOptions, value string, forceQuote bool) string {
+	if !forceQuote && value != copyOptions.Null && !strings.ContainsAny(value, copyOptions.Delimiter+copyOptions.Quote+"\r\n") {
+		return value
+	}
+
+	quote, escape := copyOptions.Quote[0], copyOptions.Escape[0]
+	buffer := &strings.Builder{}
+	buffer.WriteByte(quote)
+	for i := 0; i < len(value); i++ {
+		if value[i] == quote || value[i] == escape {
+			buffer.WriteByte(escape)
+		}
+		buffer.WriteByte(value[i])
+	}
+	buffer.WriteByte(quote)
+	return buffer.String()
+}
+
+func (queryHandler *QueryHandler) formatCopyBinaryRow(typeMap *pgtype.Map, oids []uint32, cols []*sql.ColumnType, valuePtrs []interface{}) ([]byte, error) {
+	values := queryHandler.formatRowValues(cols, valuePtrs)
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+
+	for i, value := range values {
+		if value == nil {
+			data = binary.BigEndian.AppendUint32(data, 0xFFFFFFFF) // -1
+			continue
+		}
+
+		var encodedValue []byte
+		if nullUuid, ok := valuePtrs[i].(*NullUuid); ok {
+			// DuckDB returns UUIDs as their 16 raw bytes, which is also their binary format
+			encodedValue = nullUuid.Value[:]
+		} else {
+			var err error
+			encodedValue, err = queryHandler.encodeCopyBinaryValue(typeMap, oids[i], value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		data = binary.BigEndian.AppendUint32(data, uint32(len(encodedValue)))
+		data = append(data, encodedValue...)
+	}
+
+	return data, nil
+}
+
+// Decodes a value from its text representation and encodes it in the binary format
+func (queryHandler *QueryHandler) encodeCopyBinaryValue(typeMap *pgtype.Map, oid uint32, value []byte) ([]byte, error) {
+	switch oid {
+	case pgtype.TextOID, pgtype.ByteaOID:
+		return value, nil
+	}
+
+	pgType, _ := typeMap.TypeForOID(oid)
+	decodedValue, err := pgType.Codec.DecodeValue(typeMap, oid, pgtype.TextFormatCode, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return typeMap.Encode(oid, pgtype.BinaryFormatCode, decodedValue, []byte{})
+}
+
+func stringsToBytes(values []string) [][]byte {
+	result := make([][]byte, len(values))
+	for i, value := range values {
+		result[i] = []byte(value)
+	}
+	return result
+}
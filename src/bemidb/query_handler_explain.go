@@ -0,0 +1,483 @@
+package bemidb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+const (
+	EXPLAIN_FORMAT_TEXT = "text"
+	EXPLAIN_FORMAT_JSON = "json"
+	EXPLAIN_FORMAT_YAML = "yaml"
+	EXPLAIN_FORMAT_XML  = "xml"
+
+	EXPLAIN_COLUMN_NAME   = "QUERY PLAN"
+	EXPLAIN_XML_NAMESPACE = "http://www.postgresql.org/2009/explain"
+)
+
+// Accepted for compatibility with Postgres clients, DuckDB doesn't report these details
+var EXPLAIN_IGNORED_OPTIONS = NewSet([]string{
+	"verbose",
+	"costs",
+	"settings",
+	"generic_plan",
+	"buffers",
+	"serialize",
+	"wal",
+	"timing",
+	"summary",
+	"memory",
+})
+
+// Plan properties rendered as numbers in the JSON and YAML formats
+var EXPLAIN_NUMERIC_PROPERTIES = NewSet([]string{
+	"Plan Rows",
+	"Actual Rows",
+	"Actual Total Time",
+	"Execution Time",
+})
+
+type ExplainOptions struct {
+	Format  string
+	Analyze bool
+	// Return the DuckDB query produced by remapping instead of its plan, without running it
+	Remap bool
+}
+
+// Postgres-shaped plan node, e.g. {"Node Type": "FILTER", "Plan Rows": 2, "Expression": "(i > 1)", "Plans": [...]}
+type ExplainPlanNode struct {
+	Properties *OrderedMap
+	Plans      []*ExplainPlanNode
+}
+
+// DuckDB node from EXPLAIN (FORMAT JSON) or EXPLAIN (ANALYZE, FORMAT JSON)
+type duckdbExplainNode struct {
+	Name                string              `json:"name"`
+	OperatorType        string              `json:"operator_type"`
+	OperatorTiming      float64             `json:"operator_timing"`
+	OperatorCardinality int64               `json:"operator_cardinality"`
+	ExtraInfo           json.RawMessage     `json:"extra_info"`
+	Children            []duckdbExplainNode `json:"children"`
+}
+
+// Returns the EXPLAIN statement if the query is a single EXPLAIN statement
+func (queryHandler *QueryHandler) parseExplainStatement(query string) *pgQuery.ExplainStmt {
+	queryTree, err := pgQuery.Parse(query)
+	if err != nil || len(queryTree.Stmts) != 1 || queryTree.Stmts[0].Stmt == nil {
+		return nil
+	}
+
+	return queryTree.Stmts[0].Stmt.GetExplainStmt()
+}
+
+// EXPLAIN [ ( option, ... ) ] query
+func (queryHandler *QueryHandler) HandleExplainQuery(explainStmt *pgQuery.ExplainStmt) ([]pgproto3.Message, error) {
+	explainOptions, err := queryHandler.parseExplainOptions(explainStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	if explainStmt.Query.GetSelectStmt() == nil {
+		return nil, errors.New("EXPLAIN is only supported for SELECT queries")
+	}
+
+	stmt, err := queryHandler.remapStatement(&pgQuery.RawStmt{Stmt: explainStmt.Query})
+	if err != nil {
+		return nil, err
+	}
+	query, err := NewQueryParserUtils(queryHandler.config).DeparseForDuckdb(&pgQuery.ParseResult{Stmts: []*pgQuery.RawStmt{stmt}})
+	if err != nil {
+		return nil, err
+	}
+
+	if explainOptions.Remap {
+		return queryHandler.explainMessages([]string{query}), nil
+	}
+
+	if explainOptions.Analyze {
+		query = "EXPLAIN (ANALYZE, FORMAT JSON) " + query
+	} else {
+		query = "EXPLAIN (FORMAT JSON) " + query
+	}
+
+	startedAt := time.Now()
+	rows, err := queryHandler.duckdb.QueryContext(context.Background(), query)
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't handle query via DuckDB:", query+"\n"+err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var explainKey, explainValue string
+	if rows.Next() {
+		err = rows.Scan(&explainKey, &explainValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Milliseconds, only reported with ANALYZE
+	executionTime := ""
+	if explainOptions.Analyze {
+		executionTime = strconv.FormatFloat(float64(time.Since(startedAt).Microseconds())/1000, 'f', 3, 64)
+	}
+
+	plan, err := queryHandler.parseDuckdbExplainPlan(explainValue, explainOptions.Analyze)
+	if err != nil {
+		LogError(queryHandler.config, "Couldn't parse DuckDB query plan:", query+"\n"+err.Error())
+		return nil, err
+	}
+
+	var lines []string
+	switch explainOptions.Format {
+	case EXPLAIN_FORMAT_JSON:
+		lines = []string{queryHandler.formatExplainJson(plan, executionTime)}
+	case EXPLAIN_FORMAT_YAML:
+		lines = []string{queryHandler.formatExplainYaml(plan, executionTime)}
+	case EXPLAIN_FORMAT_XML:
+		lines = []string{queryHandler.formatExplainXml(plan, executionTime)}
+	default:
+		lines = queryHandler.formatExplainText(plan, executionTime)
+	}
+
+	return queryHandler.explainMessages(lines), nil
+}
+
+func (queryHandler *QueryHandler) explainMessages(lines []string) []pgproto3.Message {
+	messages := []pgproto3.Message{
+		&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+			{
+				Name:         []byte(EXPLAIN_COLUMN_NAME),
+				DataTypeOID:  pgtype.TextOID,
+				DataTypeSize: -1,
+				TypeModifier: -1,
+			},
+		}},
+	}
+	for _, line := range lines {
+		messages = append(messages, &pgproto3.DataRow{Values: [][]byte{[]byte(line)}})
+	}
+	messages = append(messages, &pgproto3.CommandComplete{CommandTag: []byte("EXPLAIN")})
+
+	return messages
+}
+
+func (queryHandler *QueryHandler) parseExplainOptions(explainStmt *pgQuery.ExplainStmt) (ExplainOptions, error) {
+	explainOptions := ExplainOptions{Format: EXPLAIN_FORMAT_TEXT}
+
+	for _, optionNode := range explainStmt.Options {
+		option := optionNode.GetDefElem()
+
+		switch option.Defname {
+		case "format":
+			format := strings.ToLower(option.Arg.GetString_().GetSval())
+			if format != EXPLAIN_FORMAT_TEXT && format != EXPLAIN_FORMAT_JSON && format != EXPLAIN_FORMAT_YAML && format != EXPLAIN_FORMAT_XML {
+				return explainOptions, errors.New("unrecognized value for EXPLAIN option \"format\": \"" + format + "\"")
+			}
+			explainOptions.Format = format
+		case "analyze":
+			explainOptions.Analyze = queryHandler.isExplainOptionEnabled(option)
+		case "remap":
+			explainOptions.Remap = queryHandler.isExplainOptionEnabled(option)
+		default:
+			if !EXPLAIN_IGNORED_OPTIONS.Contains(option.Defname) {
+				return explainOptions, errors.New("unrecognized EXPLAIN option \"" + option.Defname + "\"")
+			}
+		}
+	}
+
+	if explainOptions.Remap && explainOptions.Analyze {
+		return explainOptions, errors.New("EXPLAIN options REMAP and ANALYZE cannot be used together")
+	}
+
+	return explainOptions, nil
+}
+
+// ANALYZE, ANALYZE true, ANALYZE on
+func (queryHandler *QueryHandler) isExplainOptionEnabled(option *pgQuery.DefElem) bool {
+	if option.Arg == nil || option.Arg.GetBoolean().GetBoolval() {
+		return true
+	}
+
+	value := strings.ToLower(option.Arg.GetString_().GetSval())
+	return value == "true" || value == "on"
+}
+
+func (queryHandler *QueryHandler) parseDuckdbExplainPlan(explainValue string, analyze bool) (*ExplainPlanNode, error) {
+	if analyze {
+		var root duckdbExplainNode
+		err := json.Unmarshal([]byte(explainValue), &root)
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip the wrappers around the profiled query
+		node := root
+		for node.Name == "" && (node.OperatorType == "" || node.OperatorType == "EXPLAIN_ANALYZE") && len(node.Children) == 1 {
+			node = node.Children[0]
+		}
+		return queryHandler.makeExplainPlanNode(node, analyze), nil
+	}
+
+	var roots []duckdbExplainNode
+	err := json.Unmarshal([]byte(explainValue), &roots)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("DuckDB returned an empty query plan")
+	}
+
+	return queryHandler.makeExplainPlanNode(roots[0], analyze), nil
+}
+
+func (queryHandler *QueryHandler) makeExplainPlanNode(duckdbNode duckdbExplainNode, analyze bool) *ExplainPlanNode {
+	nodeType := strings.TrimSpace(duckdbNode.Name)
+	if nodeType == "" {
+		nodeType = strings.TrimSpace(duckdbNode.OperatorType)
+	}
+
+	properties := NewOrderedMap([][]string{{"Node Type", nodeType}})
+	extraInfo := queryHandler.parseDuckdbExplainExtraInfo(duckdbNode.ExtraInfo)
+
+	if estimatedCardinality, err := strconv.ParseInt(extraInfo.Get("Estimated Cardinality"), 10, 64); err == nil {
+		properties.Set("Plan Rows", strconv.FormatInt(estimatedCardinality, 10))
+	}
+	if analyze {
+		properties.Set("Actual Rows", strconv.FormatInt(duckdbNode.OperatorCardinality, 10))
+		properties.Set("Actual Total Time", strconv.FormatFloat(duckdbNode.OperatorTiming*1000, 'f', 3, 64))
+	}
+	for _, key := range extraInfo.Keys() {
+		if key != "Estimated Cardinality" {
+			properties.Set(key, extraInfo.Get(key))
+		}
+	}
+
+	planNode := &ExplainPlanNode{Properties: properties}
+	for _, child := range duckdbNode.Children {
+		planNode.Plans = append(planNode.Plans, queryHandler.makeExplainPlanNode(child, analyze))
+	}
+
+	return planNode
+}
+
+// Keeps the order of the DuckDB plan details, lists are joined with commas
+func (queryHandler *QueryHandler) parseDuckdbExplainExtraInfo(extraInfo json.RawMessage) *OrderedMap {
+	orderedMap := NewOrderedMap([][]string{})
+	if len(extraInfo) == 0 {
+		return orderedMap
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(extraInfo)))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return orderedMap
+	}
+
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return orderedMap
+		}
+		key, _ := keyToken.(string)
+
+		var value interface{}
+		err = decoder.Decode(&value)
+		if err != nil {
+			return orderedMap
+		}
+
+		switch typedValue := value.(type) {
+		case string:
+			orderedMap.Set(key, typedValue)
+		case []interface{}:
+			var items []string
+			for _, item := range typedValue {
+				items = append(items, fmt.Sprint(item))
+			}
+			orderedMap.Set(key, strings.Join(items, ", "))
+		default:
+			orderedMap.Set(key, fmt.Sprint(typedValue))
+		}
+	}
+
+	return orderedMap
+}
+
+// One row per line: "HASH_GROUP_BY  (rows=1)", "  Groups: #0", "  ->  PROJECTION  (rows=2)"
+func (queryHandler *QueryHandler) formatExplainText(plan *ExplainPlanNode, executionTime string) []string {
+	var lines []string
+
+	var formatNode func(node *ExplainPlanNode, indent string, prefix string)
+	formatNode = func(node *ExplainPlanNode, indent string, prefix string) {
+		var stats []string
+		if node.Properties.HasKey("Actual Total Time") {
+			stats = append(stats, "actual time="+node.Properties.Get("Actual Total Time"))
+		}
+		if node.Properties.HasKey("Actual Rows") {
+			stats = append(stats, "rows="+node.Properties.Get("Actual Rows"))
+		} else if node.Properties.HasKey("Plan Rows") {
+			stats = append(stats, "rows="+node.Properties.Get("Plan Rows"))
+		}
+
+		line := indent + prefix + node.Properties.Get("Node Type")
+		if len(stats) > 0 {
+			line += "  (" + strings.Join(stats, " ") + ")"
+		}
+		lines = append(lines, line)
+
+		detailIndent := indent + strings.Repeat(" ", len(prefix)+2)
+		for _, key := range node.Properties.Keys() {
+			if key == "Node Type" || EXPLAIN_NUMERIC_PROPERTIES.Contains(key) {
+				continue
+			}
+			lines = append(lines, detailIndent+key+": "+strings.ReplaceAll(node.Properties.Get(key), "\n", " "))
+		}
+
+		for _, child := range node.Plans {
+			formatNode(child, detailIndent, "->  ")
+		}
+	}
+	formatNode(plan, "", "")
+
+	if executionTime != "" {
+		lines = append(lines, "Execution Time: "+executionTime+" ms")
+	}
+
+	return lines
+}
+
+// [{"Plan": {"Node Type": "PROJECTION", "Plans": [...]}}]
+func (queryHandler *QueryHandler) formatExplainJson(plan *ExplainPlanNode, executionTime string) string {
+	builder := &strings.Builder{}
+
+	var formatNode func(node *ExplainPlanNode, indent string)
+	formatNode = func(node *ExplainPlanNode, indent string) {
+		builder.WriteString("{")
+		for i, key := range node.Properties.Keys() {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString("\n" + indent + "  " + queryHandler.explainJsonString(key) + ": " + queryHandler.explainJsonValue(key, node.Properties.Get(key)))
+		}
+		if len(node.Plans) > 0 {
+			builder.WriteString(",\n" + indent + "  \"Plans\": [")
+			for i, child := range node.Plans {
+				if i > 0 {
+					builder.WriteString(",")
+				}
+				builder.WriteString("\n" + indent + "    ")
+				formatNode(child, indent+"    ")
+			}
+			builder.WriteString("\n" + indent + "  ]")
+		}
+		builder.WriteString("\n" + indent + "}")
+	}
+
+	builder.WriteString("[\n  {\n    \"Plan\": ")
+	formatNode(plan, "    ")
+	if executionTime != "" {
+		builder.WriteString(",\n    \"Execution Time\": " + executionTime)
+	}
+	builder.WriteString("\n  }\n]")
+
+	return builder.String()
+}
+
+// - Plan: { Node Type: "PROJECTION", Plans: [ - Node Type: "DUMMY_SCAN" ] } as an indented YAML document
+func (queryHandler *QueryHandler) formatExplainYaml(plan *ExplainPlanNode, executionTime string) string {
+	var lines []string
+
+	var formatNode func(node *ExplainPlanNode, indent string, firstPrefix string)
+	formatNode = func(node *ExplainPlanNode, indent string, firstPrefix string) {
+		for i, key := range node.Properties.Keys() {
+			prefix := indent
+			if i == 0 {
+				prefix = firstPrefix
+			}
+			lines = append(lines, prefix+key+": "+queryHandler.explainYamlValue(key, node.Properties.Get(key)))
+		}
+		if len(node.Plans) > 0 {
+			lines = append(lines, indent+"Plans: ")
+			for _, child := range node.Plans {
+				formatNode(child, indent+"    ", indent+"  - ")
+			}
+		}
+	}
+
+	lines = append(lines, "- Plan: ")
+	formatNode(plan, "    ", "    ")
+	if executionTime != "" {
+		lines = append(lines, "  Execution Time: "+executionTime)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// <explain xmlns="http://www.postgresql.org/2009/explain"><Query><Plan><Node-Type>PROJECTION</Node-Type>...
+func (queryHandler *QueryHandler) formatExplainXml(plan *ExplainPlanNode, executionTime string) string {
+	var lines []string
+
+	var formatNode func(node *ExplainPlanNode, indent string)
+	formatNode = func(node *ExplainPlanNode, indent string) {
+		lines = append(lines, indent+"<Plan>")
+		for _, key := range node.Properties.Keys() {
+			tag := strings.ReplaceAll(key, " ", "-")
+			lines = append(lines, indent+"  <"+tag+">"+queryHandler.explainXmlEscape(node.Properties.Get(key))+"</"+tag+">")
+		}
+		if len(node.Plans) > 0 {
+			lines = append(lines, indent+"  <Plans>")
+			for _, child := range node.Plans {
+				formatNode(child, indent+"    ")
+			}
+			lines = append(lines, indent+"  </Plans>")
+		}
+		lines = append(lines, indent+"</Plan>")
+	}
+
+	lines = append(lines, "<explain xmlns=\""+EXPLAIN_XML_NAMESPACE+"\">", "  <Query>")
+	formatNode(plan, "    ")
+	if executionTime != "" {
+		lines = append(lines, "    <Execution-Time>"+executionTime+"</Execution-Time>")
+	}
+	lines = append(lines, "  </Query>", "</explain>")
+
+	return strings.Join(lines, "\n")
+}
+
+func (queryHandler *QueryHandler) explainJsonValue(key string, value string) string {
+	if EXPLAIN_NUMERIC_PROPERTIES.Contains(key) {
+		return value
+	}
+	return queryHandler.explainJsonString(value)
+}
+
+func (queryHandler *QueryHandler) explainJsonString(value string) string {
+	buffer := &strings.Builder{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func (queryHandler *QueryHandler) explainYamlValue(key string, value string) string {
+	if EXPLAIN_NUMERIC_PROPERTIES.Contains(key) {
+		return value
+	}
+	return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value) + "\""
+}
+
+func (queryHandler *QueryHandler) explainXmlEscape(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(value)
+}
@@ -0,0 +1,63 @@
+package bemidb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DuckDB settings that can be changed for a single query, others are rejected to keep the server configuration intact
+var DUCKDB_HINT_SETTINGS = NewSet([]string{
+	"threads",
+	"enable_profiling",
+	"profiling_mode",
+	"disabled_optimizers",
+	"preserve_insertion_order",
+	"max_expression_depth",
+})
+
+// Hint settings that DuckDB applies to all connections instead of the query's own
+var DUCKDB_GLOBAL_SETTINGS = NewSet([]string{
+	"threads",
+	"disabled_optimizers",
+	"preserve_insertion_order",
+})
+
+// /*+ duckdb: SET threads=2; SET disabled_optimizers='filter_pushdown' */ SELECT ...
+var DUCKDB_HINT_REGEX = regexp.MustCompile(`(?is)^\s*/\*\+\s*duckdb:(.*?)\*/`)
+var DUCKDB_HINT_SET_REGEX = regexp.MustCompile(`(?i)^SET\s+([a-z_]+)\s*(?:=|\s+TO\s+)\s*('[^']*'|[\w.]+)$`)
+
+type DuckdbSetting struct {
+	Name  string
+	Value string // SQL literal, e.g. 2 or 'filter_pushdown'
+}
+
+// Returns the settings from a leading duckdb hint comment, or nil if the query has no hint
+func ParseDuckdbHint(query string) ([]DuckdbSetting, error) {
+	match := DUCKDB_HINT_REGEX.FindStringSubmatch(query)
+	if match == nil {
+		return nil, nil
+	}
+
+	var settings []DuckdbSetting
+	for _, statement := range strings.Split(match[1], ";") {
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+
+		setMatch := DUCKDB_HINT_SET_REGEX.FindStringSubmatch(statement)
+		if setMatch == nil {
+			return nil, fmt.Errorf("invalid duckdb hint: \"%s\"", statement)
+		}
+
+		name := strings.ToLower(setMatch[1])
+		if !DUCKDB_HINT_SETTINGS.Contains(name) {
+			return nil, fmt.Errorf("DuckDB setting \"%s\" is not allowed in a duckdb hint", name)
+		}
+
+		settings = append(settings, DuckdbSetting{Name: name, Value: setMatch[2]})
+	}
+
+	return settings, nil
+}
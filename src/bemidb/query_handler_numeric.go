@@ -0,0 +1,72 @@
+package bemidb
+
+import (
+	"errors"
+	"strings"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	NUMERIC_AGGREGATE_OVERFLOW_ERROR  = "ERROR"
+	NUMERIC_AGGREGATE_OVERFLOW_DOUBLE = "DOUBLE"
+)
+
+var NUMERIC_AGGREGATE_OVERFLOW_MODES = []string{NUMERIC_AGGREGATE_OVERFLOW_ERROR, NUMERIC_AGGREGATE_OVERFLOW_DOUBLE}
+
+// Aggregates that DuckDB accumulates in a 128-bit integer for DECIMAL arguments, while Postgres widens to an arbitrary-precision numeric
+var NUMERIC_OVERFLOW_AGGREGATES = []string{"sum", "avg"}
+
+var ERROR_NUMERIC_AGGREGATE_OVERFLOW = errors.New("numeric field overflow: the aggregate exceeds the 38 digits of a DECIMAL, cast its argument to double precision or set --numeric-aggregate-overflow to DOUBLE")
+
+func (queryHandler *QueryHandler) isNumericAggregateOverflow(err error) bool {
+	return strings.Contains(err.Error(), "Overflow in HUGEINT addition") || strings.Contains(err.Error(), "Overflow in SUM")
+}
+
+// SUM(decimal_column) -> SUM(decimal_column::double) to return an approximate result instead of an overflow error.
+// Returns a numeric field overflow error like Postgres if DOUBLE isn't allowed or the query has no aggregates to widen
+func (queryHandler *QueryHandler) widenNumericAggregates(query string) (string, error) {
+	if queryHandler.config.NumericAggregateOverflow != NUMERIC_AGGREGATE_OVERFLOW_DOUBLE {
+		return "", ERROR_NUMERIC_AGGREGATE_OVERFLOW
+	}
+
+	queryTree, err := pgQuery.Parse(query)
+	if err != nil {
+		return "", ERROR_NUMERIC_AGGREGATE_OVERFLOW
+	}
+
+	parserType := NewQueryParserType(queryHandler.config)
+	widened := false
+	for _, stmt := range queryTree.Stmts {
+		parserType.utils.WalkMessages(stmt.Stmt, func(message protoreflect.Message) {
+			funcCall, ok := message.Interface().(*pgQuery.FuncCall)
+			if !ok || len(funcCall.Args) != 1 || funcCall.AggStar {
+				return
+			}
+
+			functionName := funcCall.Funcname[len(funcCall.Funcname)-1].GetString_().Sval
+			if !queryHandler.isNumericOverflowAggregate(functionName) || parserType.inferNodeType(funcCall.Args[0]) == "double" {
+				return
+			}
+
+			funcCall.Args[0] = parserType.MakeTypeCastNode(funcCall.Args[0], "double")
+			widened = true
+		})
+	}
+	if !widened {
+		return "", ERROR_NUMERIC_AGGREGATE_OVERFLOW
+	}
+
+	LogWarn(queryHandler.config, "Aggregate overflowed DECIMAL, rerunning it over DOUBLE:", query)
+	return parserType.utils.DeparseForDuckdb(queryTree)
+}
+
+func (queryHandler *QueryHandler) isNumericOverflowAggregate(functionName string) bool {
+	for _, aggregate := range NUMERIC_OVERFLOW_AGGREGATES {
+		if strings.EqualFold(functionName, aggregate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package bemidb
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// Runs the original query on the live source database when it reads passthrough tables, e.g. small reference tables
+// that need to be fresh. Each query opens its own connection with a read-only transaction, and its rows are streamed
+// to the client as they're read. The connection is closed once all rows have been read
+func (queryHandler *QueryHandler) HandlePassthroughQuery(ctx context.Context, query string, variables []interface{}) ([]pgproto3.Message, error) {
+	descriptionMessage, dataRows, err := queryHandler.queryPassthrough(ctx, query, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return []pgproto3.Message{descriptionMessage, dataRows}, nil
+}
+
+func (queryHandler *QueryHandler) queryPassthrough(ctx context.Context, query string, variables []interface{}) (*pgproto3.RowDescription, *MessageStream, error) {
+	ctx, cancel := queryHandler.statementContext(ctx)
+	conn, err := pgconn.Connect(ctx, UrlEncodePassword(queryHandler.config.Pg.DatabaseUrl))
+	if err != nil {
+		cancel()
+		LogError(queryHandler.config, "Couldn't connect to the source database:", err)
+		return nil, nil, err
+	}
+	// Closing the connection rolls back the transaction
+	closeConn := func() {
+		conn.Close(context.Background())
+		cancel()
+	}
+
+	// Side effects of functions called by the query, e.g. nextval() or set_config(), fail in a read-only transaction
+	_, err = conn.Exec(ctx, "BEGIN READ ONLY").ReadAll()
+	if err != nil {
+		closeConn()
+		LogError(queryHandler.config, "Couldn't start a read-only transaction on the source database:", err)
+		return nil, nil, err
+	}
+
+	resultReader := conn.ExecParams(ctx, query, queryHandler.passthroughParams(variables), nil, nil, nil)
+	fieldDescriptions := resultReader.FieldDescriptions()
+	if fieldDescriptions == nil {
+		// The query failed before returning any rows
+		_, err := resultReader.Close()
+		if err != nil {
+			closeConn()
+			return nil, nil, queryHandler.passthroughError(ctx, query, err)
+		}
+	}
+
+	descriptionMessage := &pgproto3.RowDescription{}
+	for _, field := range fieldDescriptions {
+		descriptionMessage.Fields = append(descriptionMessage.Fields, pgproto3.FieldDescription{
+			Name:                 []byte(field.Name),
+			TableOID:             field.TableOID,
+			TableAttributeNumber: field.TableAttributeNumber,
+			DataTypeOID:          field.DataTypeOID,
+			DataTypeSize:         field.DataTypeSize,
+			TypeModifier:         field.TypeModifier,
+			Format:               field.Format,
+		})
+	}
+
+	completed := false
+	dataRows := NewMessageStream(func() (pgproto3.Message, error) {
+		if completed {
+			return nil, nil
+		}
+		if resultReader.NextRow() {
+			// The values are only valid until the next row is read
+			values := make([][]byte, len(resultReader.Values()))
+			for i, value := range resultReader.Values() {
+				if value != nil {
+					values[i] = append([]byte{}, value...)
+				}
+			}
+			return &pgproto3.DataRow{Values: values}, nil
+		}
+
+		completed = true
+		commandTag, err := resultReader.Close()
+		if err != nil {
+			return nil, queryHandler.passthroughError(ctx, query, err)
+		}
+		return &pgproto3.CommandComplete{CommandTag: []byte(commandTag.String())}, nil
+	}, closeConn)
+
+	return descriptionMessage, dataRows, nil
+}
+
+func (queryHandler *QueryHandler) passthroughError(ctx context.Context, query string, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ERROR_STATEMENT_TIMEOUT
+	}
+	LogError(queryHandler.config, "Couldn't handle query via the source database:", query+"\n"+err.Error())
+	return err
+}
+
+// Bound variables are sent in the text format
+func (queryHandler *QueryHandler) passthroughParams(variables []interface{}) [][]byte {
+	params := make([][]byte, len(variables))
+	for i, variable := range variables {
+		switch value := variable.(type) {
+		case string:
+			params[i] = []byte(value)
+		case int64:
+			params[i] = []byte(strconv.FormatInt(value, 10))
+		}
+	}
+	return params
+}
@@ -0,0 +1,2892 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestHandleQuery(t *testing.T) {
+	var responsesByQuery = map[string]map[string][]string{
+		// PG functions
+		"SELECT VERSION()": {
+			"description": {"version"},
+			"values":      {"PostgreSQL 17.0 (BemiDB " + VERSION + "), compiled by Bemi"},
+		},
+		"SELECT pg_catalog.pg_get_userbyid(p.proowner) AS owner, 'Foo' AS foo FROM pg_catalog.pg_proc p LEFT JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace LIMIT 1": {
+			"description": {"owner", "foo"},
+			"values":      {"bemidb", "Foo"},
+		},
+		"SELECT QUOTE_IDENT('fooBar')": {
+			"description": {"quote_ident"},
+			"values":      {"\"fooBar\""},
+		},
+		"SELECT setting from pg_show_all_settings() WHERE name = 'default_null_order'": {
+			"description": {"setting"},
+			"values":      {"nulls_last"},
+		},
+		"SELECT pg_catalog.pg_get_partkeydef(c.oid) FROM pg_catalog.pg_class c LIMIT 1": {
+			"description": {"pg_get_partkeydef"},
+			"values":      {""},
+		},
+		"SELECT pg_tablespace_location(t.oid) loc FROM pg_catalog.pg_tablespace": {
+			"description": {"loc"},
+			"values":      {""},
+		},
+		"SELECT pg_catalog.pg_get_expr(adbin, drelid, TRUE) AS def_value FROM pg_catalog.pg_attrdef": {
+			"description": {"def_value"},
+		},
+		"SELECT pg_catalog.pg_get_expr('now()', 0, true) AS def_value": {
+			"description": {"def_value"},
+			"values":      {"now()"},
+		},
+		"SELECT pg_catalog.pg_get_constraintdef(c.oid, true) AS condef FROM pg_catalog.pg_class c LIMIT 1": {
+			"description": {"condef"},
+			"values":      {""},
+		},
+		"SELECT pg_typeof(1), pg_typeof(1.5) AS numeric, pg_typeof(1.5::float8) AS float8, pg_typeof(true) AS bool, pg_typeof(NULL) AS null, pg_typeof(now()) AS now": {
+			"description": {"pg_typeof", "numeric", "float8", "bool", "null", "now"},
+			"values":      {"integer", "numeric", "double precision", "boolean", "unknown", "timestamp with time zone"},
+		},
+		"SELECT pg_typeof(text_column) AS text, pg_typeof(date_column) AS date, pg_typeof(timestamp_column) AS timestamp, pg_typeof(array_text_column) AS array FROM public.test_table LIMIT 1": {
+			"description": {"text", "date", "timestamp", "array"},
+			"values":      {"text", "date", "timestamp without time zone", "text[]"},
+		},
+		"SELECT upper(pg_typeof(text_column)) AS type FROM public.test_table LIMIT 1": {
+			"description": {"type"},
+			"values":      {"TEXT"},
+		},
+		"SELECT set_config('bytea_output', 'hex', false)": {
+			"description": {"set_config"},
+			"values":      {"hex"},
+		},
+		"SELECT pg_catalog.pg_encoding_to_char(6)": {
+			"description": {"pg_encoding_to_char"},
+			"values":      {"UTF8"},
+		},
+		"SELECT pg_backend_pid()": {
+			"description": {"pg_backend_pid"},
+			"values":      {"0"},
+		},
+		"SELECT * from pg_is_in_recovery()": {
+			"description": {"pg_is_in_recovery"},
+			"values":      {"f"},
+		},
+		"SELECT row_to_json(t) FROM (SELECT usename, passwd FROM pg_shadow WHERE usename='bemidb') t": {
+			"description": {"row_to_json"},
+			"values":      {`{"usename":"bemidb","passwd":"bemidb-encrypted"}`},
+		},
+		"SELECT lpad('hello', 8, '*')": {
+			"description": {"lpad"},
+			"values":      {"***hello"},
+		},
+		"SELECT lpad('hello', 3, '*')": {
+			"description": {"lpad"},
+			"values":      {"hel"},
+		},
+		"SELECT lpad('hi', 5)": {
+			"description": {"lpad"},
+			"values":      {"   hi"},
+		},
+		"SELECT lpad('hello', 3, '')": {
+			"description": {"lpad"},
+			"values":      {"hel"},
+		},
+		"SELECT lpad('hi', 5, '')": {
+			"description": {"lpad"},
+			"values":      {"hi"},
+		},
+		"SELECT lpad('hi', -1, '*')": {
+			"description": {"lpad"},
+			"values":      {""},
+		},
+		"SELECT lpad('héllo', 7, 'ñ')": {
+			"description": {"lpad"},
+			"values":      {"ññhéllo"},
+		},
+		"SELECT rpad('hello', 8, 'ab')": {
+			"description": {"rpad"},
+			"values":      {"helloaba"},
+		},
+		"SELECT rpad('hi', 5)": {
+			"description": {"rpad"},
+			"values":      {"hi   "},
+		},
+		"SELECT rpad('héllo', 2, 'ñ')": {
+			"description": {"rpad"},
+			"values":      {"hé"},
+		},
+		"SELECT upper(rpad('hi', 4)) AS upper": {
+			"description": {"upper"},
+			"values":      {"HI  "},
+		},
+		"SELECT translate('12345', '143', 'ax') AS translate": {
+			"description": {"translate"},
+			"values":      {"a2x5"},
+		},
+		"SELECT translate('héllo', 'é', 'e') AS translate": {
+			"description": {"translate"},
+			"values":      {"hello"},
+		},
+		"SELECT ascii('x') AS ascii": {
+			"description": {"ascii"},
+			"values":      {"120"},
+		},
+		"SELECT ascii('é') AS ascii": {
+			"description": {"ascii"},
+			"values":      {"233"},
+		},
+		"SELECT chr(65) AS chr": {
+			"description": {"chr"},
+			"values":      {"A"},
+		},
+		"SELECT chr(233) AS chr": {
+			"description": {"chr"},
+			"values":      {"é"},
+		},
+		"SELECT repeat('ab', 3) AS repeat": {
+			"description": {"repeat"},
+			"values":      {"ababab"},
+		},
+		"SELECT repeat('ab', 0) AS repeat": {
+			"description": {"repeat"},
+			"values":      {""},
+		},
+		"SELECT repeat('ab', -2) AS repeat": {
+			"description": {"repeat"},
+			"values":      {""},
+		},
+		"SELECT repeat('ñé', 2) AS repeat": {
+			"description": {"repeat"},
+			"values":      {"ñéñé"},
+		},
+		"SELECT encode('hello'::bytea, 'hex')": {
+			"description": {"encode"},
+			"values":      {"68656c6c6f"},
+		},
+		"SELECT encode('hello'::bytea, 'base64')": {
+			"description": {"encode"},
+			"values":      {"aGVsbG8="},
+		},
+		"SELECT encode(repeat('a', 60)::bytea, 'base64')": {
+			"description": {"encode"},
+			"values":      {"YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFh\nYWFh"},
+		},
+		"SELECT encode(bytea_column, 'hex') FROM public.test_table WHERE bytea_column IS NOT NULL": {
+			"description": {"encode"},
+			"values":      {"1234"},
+		},
+		"SELECT encode(bytea_column, 'base64') FROM public.test_table WHERE bytea_column IS NOT NULL": {
+			"description": {"encode"},
+			"values":      {"EjQ="},
+		},
+		"SELECT decode('68656C6c6f', 'hex')": {
+			"description": {"decode"},
+			"values":      {"\\x68656c6c6f"},
+		},
+		"SELECT decode('aGVsbG8=', 'base64')": {
+			"description": {"decode"},
+			"values":      {"\\x68656c6c6f"},
+		},
+		"SELECT encode(decode('aGVsbG8=', 'base64'), 'hex')": {
+			"description": {"encode"},
+			"values":      {"68656c6c6f"},
+		},
+		"SELECT md5('hello')": {
+			"description": {"md5"},
+			"values":      {"5d41402abc4b2a76b9719d911017c592"},
+		},
+		"SELECT to_hex(255)": {
+			"description": {"to_hex"},
+			"values":      {"ff"},
+		},
+		"SELECT bool_and(b) AS bool_and, bool_or(b) AS bool_or FROM (VALUES (true), (false), (NULL)) t(b)": {
+			"description": {"bool_and", "bool_or"},
+			"values":      {"false", "true"},
+		},
+		"SELECT every(b) FROM (VALUES (true), (NULL)) t(b)": {
+			"description": {"every"},
+			"values":      {"true"},
+		},
+		"SELECT bit_and(i) AS bit_and, bit_or(i) AS bit_or FROM (VALUES (5), (3), (NULL)) t(i)": {
+			"description": {"bit_and", "bit_or"},
+			"values":      {"1", "7"},
+		},
+		"SELECT bit_and(i::smallint) AS bit_and, bit_or(i::bigint) AS bit_or FROM (VALUES (6), (3)) t(i)": {
+			"description": {"bit_and", "bit_or"},
+			"values":      {"2", "7"},
+		},
+		"SELECT count(DISTINCT i) AS count, sum(DISTINCT i) AS sum, array_agg(DISTINCT i) AS array_agg FROM (VALUES (2), (NULL), (1), (2), (NULL)) t(i)": {
+			"description": {"count", "sum", "array_agg"},
+			"values":      {"2", "3", "{1,2,NULL}"},
+		},
+		"SELECT count(DISTINCT int4_column) AS count, sum(DISTINCT int4_column) AS sum, array_agg(DISTINCT int4_column) AS array_agg FROM test_table": {
+			"description": {"count", "sum", "array_agg"},
+			"values":      {"1", "2147483647", "{2147483647,NULL}"},
+		},
+		"SELECT string_agg(DISTINCT s, ',') AS string_agg FROM (VALUES ('b'), (NULL), ('a'), ('b')) t(s)": {
+			"description": {"string_agg"},
+			"values":      {"a,b"},
+		},
+		"SELECT mode() WITHIN GROUP (ORDER BY int4_column) AS mode FROM test_table": {
+			"description": {"mode"},
+			"values":      {"2147483647"},
+		},
+		"SELECT mode() WITHIN GROUP (ORDER BY x) AS mode FROM (VALUES (3), (1), (3), (2)) t(x)": {
+			"description": {"mode"},
+			"values":      {"3"},
+		},
+		"SELECT mode() WITHIN GROUP (ORDER BY x) AS mode FROM (VALUES (3), (1), (3), (1), (2)) t(x)": {
+			"description": {"mode"},
+			"values":      {"1"},
+		},
+		"SELECT mode() WITHIN GROUP (ORDER BY x DESC) AS mode FROM (VALUES (1), (3), (3), (1), (2)) t(x)": {
+			"description": {"mode"},
+			"values":      {"3"},
+		},
+		"SELECT mode() WITHIN GROUP (ORDER BY s) AS mode FROM (VALUES ('b'), (NULL), ('a'), ('b'), ('a'), (NULL), (NULL)) t(s)": {
+			"description": {"mode"},
+			"values":      {"a"},
+		},
+		// Ranges
+		"SELECT tstzrange('2024-01-01 00:00:00+00', '2024-01-03 00:00:00+00') && tstzrange('2024-01-02 00:00:00+00', '2024-01-04 00:00:00+00') AS overlaps": {
+			"description": {"overlaps"},
+			"values":      {"true"},
+		},
+		"SELECT tstzrange('2024-01-01 00:00:00+00', '2024-01-02 00:00:00+00') && tstzrange('2024-01-02 00:00:00+00', '2024-01-04 00:00:00+00') AS overlaps": {
+			"description": {"overlaps"},
+			"values":      {"false"},
+		},
+		"SELECT tstzrange('2024-01-01 00:00:00+00', '2024-01-02 00:00:00+00', '[]') && '[\"2024-01-02 00:00:00+00\",\"2024-01-04 00:00:00+00\")'::tstzrange AS overlaps": {
+			"description": {"overlaps"},
+			"values":      {"true"},
+		},
+		"SELECT tstzrange('2024-01-01 00:00:00+00', NULL) && tstzrange('2030-01-01 00:00:00+00', '2030-01-02 00:00:00+00') AS overlaps": {
+			"description": {"overlaps"},
+			"values":      {"true"},
+		},
+		"SELECT int4range(1, 10) @> 5 AS contains, 10 <@ int4range(1, 10) AS contained_by, '[1,10]'::int4range @> int4range(2, 10, '[]') AS contains_range": {
+			"description": {"contains", "contained_by", "contains_range"},
+			"values":      {"true", "false", "true"},
+		},
+		"SELECT tstzrange('2024-01-01 00:00:00+00', '2024-01-02 00:00:00+00') AS tstzrange": {
+			"description": {"tstzrange"},
+			"values":      {"[\"2024-01-01 00:00:00+00\",\"2024-01-02 00:00:00+00\")"},
+		},
+		"SELECT count(*) AS count FROM public.test_table WHERE tstzrange(timestamptz_column, timestamptz_column + INTERVAL '1 hour') && tstzrange('2024-01-01 16:00:00+00', '2024-01-01 18:00:00+00')": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		// SIMILAR TO
+		"SELECT 'abc' SIMILAR TO 'abc' AS exact, 'abc' SIMILAR TO 'a' AS partial, 'abc' SIMILAR TO '%(b|d)%' AS alternation, 'abc' SIMILAR TO '(b|c)%' AS anchored": {
+			"description": {"exact", "partial", "alternation", "anchored"},
+			"values":      {"true", "false", "true", "false"},
+		},
+		"SELECT 'a.c' SIMILAR TO 'a.c' AS literal_dot, 'abc' SIMILAR TO 'a.c' AS regex_dot, 'aab' SIMILAR TO 'a+b' AS plus, 'b' SIMILAR TO 'a*b' AS star, 'abd' NOT SIMILAR TO 'a_c' AS not_similar": {
+			"description": {"literal_dot", "regex_dot", "plus", "star", "not_similar"},
+			"values":      {"true", "false", "true", "true", "true"},
+		},
+		"SELECT 'ab%' SIMILAR TO 'ab#%' ESCAPE '#' AS escaped, 'abc' SIMILAR TO 'ab#%' ESCAPE '#' AS escaped_mismatch, 'a_c' SIMILAR TO 'a\\_c' AS default_escape, 'b' SIMILAR TO '[a-c]' AS char_class": {
+			"description": {"escaped", "escaped_mismatch", "default_escape", "char_class"},
+			"values":      {"true", "false", "true", "true"},
+		},
+		"SELECT count(*) AS count FROM test_table WHERE int4_column::text SIMILAR TO '2%7'": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		// pg_trgm
+		"SELECT similarity('word', 'two words') AS similarity, 'word' <-> 'two words' AS distance, similarity('Word!', 'word') AS normalized, similarity('', 'word') AS empty": {
+			"description": {"similarity", "distance", "normalized", "empty"},
+			"values":      {"0.36363637", "0.6363636", "1", "0"},
+		},
+		"SELECT 'word' % 'two words' AS similar, 'word' % 'letters' AS not_similar, 7 % 3 AS modulo": {
+			"description": {"similar", "not_similar", "modulo"},
+			"values":      {"true", "false", "1"},
+		},
+		"SELECT count(*) AS count FROM test_table WHERE text_column % 'texts'": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		// PG system tables
+		"SELECT oid, typname AS typename FROM pg_type WHERE typname='geometry' OR typname='geography'": {
+			"description": {"oid", "typename"},
+			"values":      {},
+		},
+		"SELECT relname FROM pg_catalog.pg_class WHERE relnamespace = (SELECT oid FROM pg_catalog.pg_namespace WHERE nspname = 'public' LIMIT 1) LIMIT 1": {
+			"description": {"relname"},
+			"values":      {"test_table"},
+		},
+		"SELECT oid FROM pg_catalog.pg_extension": {
+			"description": {"oid"},
+			"values":      {"13823"},
+		},
+		"SELECT slot_name FROM pg_replication_slots": {
+			"description": {"slot_name"},
+			"values":      {},
+		},
+		"SELECT oid, datname, datdba FROM pg_catalog.pg_database where oid = 1146": {
+			"description": {"oid", "datname", "datdba"},
+			"values":      {"1146", "bemidb", "10"},
+		},
+		"SELECT * FROM pg_catalog.pg_stat_gssapi": {
+			"description": {"pid", "gss_authenticated", "principal", "encrypted", "credentials_delegated"},
+			"values":      {},
+		},
+		"SELECT * FROM pg_catalog.pg_user": {
+			"description": {"usename", "usesysid", "usecreatedb", "usesuper", "userepl", "usebypassrls", "passwd", "valuntil", "useconfig"},
+			"values":      {"bemidb", "10", "t", "t", "t", "t", "", "NULL", "NULL"},
+		},
+		"SELECT datid FROM pg_catalog.pg_stat_activity": {
+			"description": {"datid"},
+			"values":      {},
+		},
+		// pg_namespace
+		"SELECT DISTINCT(nspname) FROM pg_catalog.pg_namespace WHERE nspname != 'information_schema' AND nspname != 'pg_catalog'": {
+			"description": {"nspname"},
+			"values":      {"public"},
+		},
+		"SELECT string_agg(nspname, ',' ORDER BY nspname) AS nspnames FROM pg_catalog.pg_namespace": {
+			"description": {"nspnames"},
+			"values":      {"information_schema,pg_catalog,public"},
+		},
+		"SELECT n.nspname, n.nspowner FROM pg_catalog.pg_namespace n WHERE n.nspname = 'public'": {
+			"description": {"nspname", "nspowner"},
+			"values":      {"public", "10"},
+		},
+		"SELECT COUNT(*) AS count FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = 'public' AND c.relname = 'test_table'": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		"SELECT c.relnatts, COUNT(*) AS count FROM pg_catalog.pg_class c JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid WHERE c.relname = 'test_table' GROUP BY c.relnatts": {
+			"description": {"relnatts", "count"},
+			"values":      {"37", "37"},
+		},
+		"SELECT a.attnum, a.attname, t.typname, a.atttypmod, a.attnotnull FROM pg_catalog.pg_attribute a JOIN pg_catalog.pg_class c ON a.attrelid = c.oid JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace JOIN pg_catalog.pg_type t ON t.oid = a.atttypid WHERE n.nspname = 'public' AND c.relname = 'test_table' AND a.attname = 'int4_column'": {
+			"description": {"attnum", "attname", "typname", "atttypmod", "attnotnull"},
+			"values":      {"7", "int4_column", "int4", "-1", "false"},
+		},
+		"SELECT a.atttypid, a.atttypmod FROM pg_catalog.pg_attribute a JOIN pg_catalog.pg_class c ON a.attrelid = c.oid WHERE c.relname = 'test_table' AND a.attname = 'numeric_column'": {
+			"description": {"atttypid", "atttypmod"},
+			"values":      {"1700", "2621446"},
+		},
+		"SELECT COUNT(*) AS count FROM pg_catalog.pg_attribute a JOIN pg_catalog.pg_class c ON a.attrelid = c.oid WHERE c.relname = 'test_table' AND a.attname = 'id'": {
+			"description": {"count"},
+			"values":      {"0"},
+		},
+		"SELECT nspname FROM pg_catalog.pg_namespace WHERE nspname == 'main'": {
+			"description": {"nspname"},
+			"values":      {},
+		},
+		"SELECT n.nspname FROM pg_catalog.pg_namespace n LEFT OUTER JOIN pg_catalog.pg_description d ON d.objoid = n.oid ORDER BY n.oid LIMIT 1": {
+			"description": {"nspname"},
+			"values":      {"public"},
+		},
+		"SELECT pg_total_relation_size(relid) AS total_size FROM pg_catalog.pg_statio_user_tables WHERE schemaname = 'public'": {
+			"description": {"total_size"},
+		},
+		"SELECT pg_total_relation_size(relid) AS total_size FROM pg_catalog.pg_statio_user_tables WHERE schemaname = 'public' UNION SELECT NULL AS total_size FROM pg_catalog.pg_proc p LEFT JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = 'public'": {
+			"description": {"total_size"},
+		},
+		"SELECT * FROM pg_catalog.pg_shdescription": {
+			"description": {"objoid", "classoid", "description"},
+		},
+		"SELECT * FROM pg_catalog.pg_roles": {
+			"description": {"oid", "rolname", "rolsuper", "rolinherit", "rolcreaterole", "rolcreatedb", "rolcanlogin", "rolreplication", "rolconnlimit", "rolpassword", "rolvaliduntil", "rolbypassrls", "rolconfig"},
+			"values":      {"10", "bemidb", "true", "true", "true", "true", "true", "false", "-1", "NULL", "NULL", "false", "NULL"},
+		},
+		"SELECT * FROM pg_catalog.pg_inherits": {
+			"description": {"inhrelid", "inhparent", "inhseqno", "inhdetachpending"},
+		},
+		"SELECT * FROM pg_auth_members": {
+			"description": {"oid", "roleid", "member", "grantor", "admin_option", "inherit_option", "set_option"},
+		},
+		// Information schema
+		"SELECT * FROM information_schema.tables": {
+			"description": {"table_catalog", "table_schema", "table_name", "table_type", "self_referencing_column_name", "reference_generation", "user_defined_type_catalog", "user_defined_type_schema", "user_defined_type_name", "is_insertable_into", "is_typed", "commit_action", "TABLE_COMMENT"},
+			"values":      {"memory", "public", "test_table", "BASE TABLE", "", "", "", "", "", "YES", "NO", "", ""},
+		},
+		"SELECT table_catalog, table_schema, table_name AS table FROM information_schema.tables": {
+			"description": {"table_catalog", "table_schema", "table"},
+			"values":      {"memory", "public", "test_table"},
+		},
+		// SET
+		"SET client_encoding TO 'UTF8'": {
+			"description": {"Success"},
+			"values":      {},
+		},
+		// DISCARD
+		"DISCARD ALL": {
+			"description": {"1"},
+			"values":      {"1"},
+		},
+		// Iceberg data
+		"SELECT COUNT(*) AS count FROM public.test_table": {
+			"description": {"count"},
+			"values":      {"2"},
+		},
+		"SELECT COUNT(*) AS count FROM test_table": {
+			"description": {"count"},
+			"values":      {"2"},
+		},
+		"SELECT array_text_column[1], array_text_column[3] AS last FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"array_text_column", "last"},
+			"values":      {"one", "three"},
+		},
+		"SELECT array_text_column[2:3] AS slice, array_text_column[:2] AS head, array_text_column[2:] AS tail, array_text_column[-1:2] AS clamped, array_text_column[2:-1] AS empty FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"slice", "head", "tail", "clamped", "empty"},
+			"values":      {"{two,three}", "{one,two}", "{two,three}", "{one,two}", "{}"},
+		},
+		"SELECT array_text_column[0] IS NULL AS zero, array_text_column[-1] IS NULL AS negative, array_text_column[4] IS NULL AS over, array_text_column[5:9] AS slice FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"zero", "negative", "over", "slice"},
+			"values":      {"true", "true", "true", "{}"},
+		},
+		"SELECT upper(array_text_column[2]) AS upper, array_text_column[array_length(array_text_column, 1) - 3] IS NULL AS computed FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"upper", "computed"},
+			"values":      {"TWO", "true"},
+		},
+		"SELECT array_position(array_text_column, 'two'), array_position(array_text_column, 'four') IS NULL AS missing, array_position(array_text_column, 'one', 2) IS NULL AS before_start FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"array_position", "missing", "before_start"},
+			"values":      {"2", "true", "true"},
+		},
+		"SELECT array_position(ARRAY['a', 'b', 'a'], 'a', 2) AS from_start, array_position(ARRAY['a', 'b'], 'a', 0) AS below_lower_bound": {
+			"description": {"from_start", "below_lower_bound"},
+			"values":      {"3", "1"},
+		},
+		"SELECT array_length(array_text_column, 1), array_length(array_text_column, 2) IS NULL AS second_dimension, cardinality(array_text_column) FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"array_length", "second_dimension", "cardinality"},
+			"values":      {"3", "true", "3"},
+		},
+		"SELECT array_length(ARRAY[]::text[], 1) IS NULL AS empty_length, cardinality(ARRAY[]::text[]) AS empty_cardinality": {
+			"description": {"empty_length", "empty_cardinality"},
+			"values":      {"true", "0"},
+		},
+		"SELECT COUNT(*) AS count FROM public.test_table WHERE cardinality(array_text_column) = 3 AND array_position(array_text_column, 'three') = array_length(array_text_column, 1)": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		"SELECT ARRAY[1, 2, 3] @> ARRAY[3, 1] AS contains, ARRAY[1, 2] @> ARRAY[1, 4] AS missing, ARRAY[1, 2] @> ARRAY[2, 2, 1] AS duplicates": {
+			"description": {"contains", "missing", "duplicates"},
+			"values":      {"true", "false", "true"},
+		},
+		"SELECT ARRAY[3, 1] <@ ARRAY[1, 2, 3] AS contained, ARRAY[1, 4] <@ ARRAY[1, 2] AS missing": {
+			"description": {"contained", "missing"},
+			"values":      {"true", "false"},
+		},
+		"SELECT ARRAY[1, 2] && ARRAY[2, 5] AS overlaps, ARRAY[1, 2] && ARRAY[3, 4] AS disjoint": {
+			"description": {"overlaps", "disjoint"},
+			"values":      {"true", "false"},
+		},
+		"SELECT ARRAY[1] @> ARRAY[]::int[] AS contains_empty, ARRAY[]::int[] @> ARRAY[]::int[] AS empty_contains_empty, ARRAY[]::int[] @> ARRAY[1] AS empty_contains, ARRAY[]::int[] <@ ARRAY[1] AS empty_contained, ARRAY[]::int[] && ARRAY[]::int[] AS empty_overlaps": {
+			"description": {"contains_empty", "empty_contains_empty", "empty_contains", "empty_contained", "empty_overlaps"},
+			"values":      {"true", "true", "false", "true", "false"},
+		},
+		"SELECT ARRAY[1, NULL] @> ARRAY[NULL]::int[] AS null_element, ARRAY[1, NULL] && ARRAY[NULL, 2] AS null_overlap, (NULL::int[] @> ARRAY[1]) IS NULL AS null_array": {
+			"description": {"null_element", "null_overlap", "null_array"},
+			"values":      {"false", "false", "true"},
+		},
+		"SELECT COUNT(*) AS count FROM public.test_table WHERE array_int_column @> '{3,1}' AND array_int_column <@ '{1,2,3,4}'::int[] AND array_int_column && ARRAY[3, 5]": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		"SELECT x[1][2] AS element, x[2][-1] IS NULL AS negative FROM (SELECT ARRAY[ARRAY[1, 2], ARRAY[3, 4]] AS x) t": {
+			"description": {"element", "negative"},
+			"values":      {"2", "true"},
+		},
+		"SELECT x.bit_column FROM public.test_table x WHERE x.bit_column IS NOT NULL": {
+			"description": {"bit_column"},
+			"values":      {"1"},
+		},
+		"SELECT bit_column FROM public.test_table WHERE bit_column IS NOT NULL": {
+			"description": {"bit_column"},
+			"values":      {"1"},
+		},
+		"SELECT test_table.bit_column FROM public.test_table WHERE bit_column IS NOT NULL": {
+			"description": {"bit_column"},
+			"values":      {"1"},
+		},
+		"SELECT bit_column FROM public.test_table WHERE bit_column IS NULL": {
+			"description": {"bit_column"},
+			"values":      {""},
+		},
+		"SELECT bool_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"bool_column"},
+			"values":      {"true"},
+		},
+		"SELECT bool_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"bool_column"},
+			"values":      {"false"},
+		},
+		"SELECT bpchar_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"bpchar_column"},
+			"values":      {"bpchar"},
+		},
+		"SELECT bpchar_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"bpchar_column"},
+			"values":      {""},
+		},
+		"SELECT varchar_column FROM public.test_table WHERE varchar_column IS NOT NULL": {
+			"description": {"varchar_column"},
+			"values":      {"varchar"},
+		},
+		"SELECT varchar_column FROM public.test_table WHERE varchar_column IS NULL": {
+			"description": {"varchar_column"},
+			"values":      {""},
+		},
+		"SELECT text_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"text_column"},
+			"values":      {"text"},
+		},
+		"SELECT text_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"text_column"},
+			"values":      {""},
+		},
+		"SELECT int2_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"int2_column"},
+			"values":      {"32767"},
+		},
+		"SELECT int2_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"int2_column"},
+			"values":      {"-32767"},
+		},
+		"SELECT int4_column FROM public.test_table WHERE int4_column IS NOT NULL": {
+			"description": {"int4_column"},
+			"values":      {"2147483647"},
+		},
+		"SELECT int4_column FROM public.test_table WHERE int4_column IS NULL": {
+			"description": {"int4_column"},
+			"values":      {""},
+		},
+		"SELECT int8_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"int8_column"},
+			"values":      {"9223372036854775807"},
+		},
+		"SELECT int8_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"int8_column"},
+			"values":      {"-9223372036854775807"},
+		},
+		"SELECT xid_column FROM public.test_table WHERE xid_column IS NOT NULL": {
+			"description": {"xid_column"},
+			"values":      {"4294967295"},
+		},
+		"SELECT xid_column FROM public.test_table WHERE xid_column IS NULL": {
+			"description": {"xid_column"},
+			"values":      {""},
+		},
+		"SELECT xid8_column FROM public.test_table WHERE xid8_column IS NOT NULL": {
+			"description": {"xid8_column"},
+			"values":      {"18446744073709551615"},
+		},
+		"SELECT xid8_column FROM public.test_table WHERE xid8_column IS NULL": {
+			"description": {"xid8_column"},
+			"values":      {""},
+		},
+		"SELECT float4_column FROM public.test_table WHERE float4_column = 3.14": {
+			"description": {"float4_column"},
+			"values":      {"3.14"},
+		},
+		"SELECT float4_column FROM public.test_table WHERE float4_column != 3.14": {
+			"description": {"float4_column"},
+			"values":      {"NaN"},
+		},
+		"SELECT float8_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"float8_column"},
+			"values":      {"3.141592653589793"},
+		},
+		"SELECT float8_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"float8_column"},
+			"values":      {"-3.141592653589793"},
+		},
+		"SELECT numeric_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"numeric_column"},
+			"values":      {"12345.67"},
+		},
+		"SELECT numeric_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"numeric_column"},
+			"values":      {"-12345"},
+		},
+		"SELECT date_column FROM public.test_table WHERE date_column IS NOT NULL": {
+			"description": {"date_column"},
+			"values":      {"2021-01-01"},
+		},
+		"SELECT date_column FROM public.test_table WHERE date_column IS NULL": {
+			"description": {"date_column"},
+			"values":      {""},
+		},
+		"SELECT time_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"time_column"},
+			"values":      {"12:00:00.123456"},
+		},
+		"SELECT time_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"time_column"},
+			"values":      {"12:00:00.123"},
+		},
+		"SELECT time_ms_column FROM public.test_table WHERE time_ms_column IS NOT NULL": {
+			"description": {"time_ms_column"},
+			"values":      {"12:00:00.123"},
+		},
+		"SELECT time_ms_column FROM public.test_table WHERE time_ms_column IS NULL": {
+			"description": {"time_ms_column"},
+			"values":      {""},
+		},
+		"SELECT timetz_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"timetz_column"},
+			"values":      {"17:00:00.123456"},
+		},
+		"SELECT timetz_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"timetz_column"},
+			"values":      {"07:00:00.123"},
+		},
+		"SELECT timestamp_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"timestamp_column"},
+			"values":      {"2024-01-01 12:00:00.123456"},
+		},
+		"SELECT timestamp_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"timestamp_column"},
+			"values":      {"2024-01-01 12:00:00"},
+		},
+		"SELECT timestamp_ms_column FROM public.test_table WHERE timestamp_ms_column IS NOT NULL": {
+			"description": {"timestamp_ms_column"},
+			"values":      {"2024-01-01 12:00:00.123"},
+		},
+		"SELECT timestamp_ms_column FROM public.test_table WHERE timestamp_ms_column IS NULL": {
+			"description": {"timestamp_ms_column"},
+			"values":      {""},
+		},
+		"SELECT timestamptz_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"timestamptz_column"},
+			"values":      {"2024-01-01 17:00:00.123456"},
+		},
+		"SELECT timestamptz_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"timestamptz_column"},
+			"values":      {"2024-01-01 07:00:00.000123"},
+		},
+		"SELECT timestamptz_ms_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"timestamptz_ms_column"},
+			"values":      {"2024-01-01 17:00:00.123"},
+		},
+		"SELECT timestamptz_ms_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"timestamptz_ms_column"},
+			"values":      {"2024-01-01 07:00:00.12"},
+		},
+		"SELECT uuid_column FROM public.test_table WHERE uuid_column IS NOT NULL": {
+			"description": {"uuid_column"},
+			"values":      {"58a7c845-af77-44b2-8664-7ca613d92f04"},
+		},
+		"SELECT uuid_column FROM public.test_table WHERE uuid_column IS NULL": {
+			"description": {"uuid_column"},
+			"values":      {""},
+		},
+		"SELECT bytea_column FROM public.test_table WHERE bytea_column IS NOT NULL": {
+			"description": {"bytea_column"},
+			"values":      {"\\x1234"},
+		},
+		"SELECT bytea_column FROM public.test_table WHERE bytea_column IS NULL": {
+			"description": {"bytea_column"},
+			"values":      {""},
+		},
+		"SELECT interval_column FROM public.test_table WHERE interval_column IS NOT NULL": {
+			"description": {"interval_column"},
+			"values":      {"1 mon 2 days 01:00:01.000001"},
+		},
+		"SELECT interval_column FROM public.test_table WHERE interval_column IS NULL": {
+			"description": {"interval_column"},
+			"values":      {""},
+		},
+		"SELECT json_column FROM public.test_table WHERE json_column IS NOT NULL": {
+			"description": {"json_column"},
+			"values":      {"{\"key\": \"value\"}"},
+		},
+		"SELECT json_column FROM public.test_table WHERE json_column IS NULL": {
+			"description": {"json_column"},
+			"values":      {""},
+		},
+		"SELECT jsonb_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"jsonb_column"},
+			"values":      {"{\"key\": \"value\"}"},
+		},
+		"SELECT jsonb_column->'key' AS key FROM public.test_table WHERE jsonb_column->>'key' = 'value'": {
+			"description": {"key"},
+			"values":      {"\"value\""},
+		},
+		"SELECT jsonb_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"jsonb_column"},
+			"values":      {"{}"},
+		},
+		"SELECT count(*) AS count FROM public.test_table WHERE jsonb_column @> '{\"key\": \"value\"}'": {
+			"description": {"count"},
+			"values":      {"1"},
+		},
+		"SELECT jsonb_column <@ '{\"key\": \"value\", \"other\": 1}' AS contained, jsonb_column ? 'key' AS exists, jsonb_column ?| '{other,key}' AS exists_any, jsonb_column ?& ARRAY['other', 'key'] AS exists_all FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"contained", "exists", "exists_any", "exists_all"},
+			"values":      {"true", "true", "true", "false"},
+		},
+		"SELECT '{\"a\": {\"b\": 1}}'::jsonb @> '{\"b\": 1}' AS nested, '[1, [2, 3]]'::jsonb @> '[[3], 1.0]' AS array, '[\"a\", \"b\"]'::jsonb @> '\"a\"' AS scalar, '{\"a\": [\"b\"]}'::jsonb @> '{\"a\": \"b\"}' AS nested_scalar": {
+			"description": {"nested", "array", "scalar", "nested_scalar"},
+			"values":      {"false", "true", "true", "false"},
+		},
+		"SELECT jsonb_column #> '{key}' AS value, jsonb_column #>> '{key}' AS text, jsonb_column #>> '{}' AS whole, jsonb_column #> '{missing,key}' IS NULL AS missing FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"value", "text", "whole", "missing"},
+			"values":      {"\"value\"", "value", "{\"key\": \"value\"}", "true"},
+		},
+		"SELECT '{\"a\": [{\"b/c\": \"x\"}]}'::jsonb #>> '{a,0,b/c}' AS path, '[\"a\", {\"b\": 1}]'::jsonb ? 'b' AS nested_key": {
+			"description": {"path", "nested_key"},
+			"values":      {"x", "false"},
+		},
+		"SELECT jsonb_path_query(jsonb_column, '$.key') FROM public.test_table": {
+			"description": {"jsonb_path_query"},
+			"values":      {"\"value\""},
+		},
+		"SELECT count(*) AS count FROM (SELECT jsonb_path_query('{\"a\": [1, 2]}'::jsonb, 'lax $.a[*]') AS item) items": {
+			"description": {"count"},
+			"values":      {"2"},
+		},
+		"SELECT count(*) AS count FROM (SELECT jsonb_path_query(jsonb_column, '$.missing') AS item FROM public.test_table) items": {
+			"description": {"count"},
+			"values":      {"0"},
+		},
+		"SELECT tsvector_column FROM public.test_table WHERE tsvector_column IS NOT NULL": {
+			"description": {"tsvector_column"},
+			"values":      {"'sampl':1 'text':2 'tsvector':4"},
+		},
+		"SELECT tsvector_column FROM public.test_table WHERE tsvector_column IS NULL": {
+			"description": {"tsvector_column"},
+			"values":      {""},
+		},
+		"SELECT xml_column FROM public.test_table WHERE xml_column IS NOT NULL": {
+			"description": {"xml_column"},
+			"values":      {"<root><child>text</child></root>"},
+		},
+		"SELECT xml_column FROM public.test_table WHERE xml_column IS NULL": {
+			"description": {"xml_column"},
+			"values":      {""},
+		},
+		"SELECT xpath('/root/child/text()', xml_column) AS texts, xpath('//child', xml_column) AS children, xpath_exists('/root/missing', xml_column) AS missing FROM public.test_table WHERE xml_column IS NOT NULL": {
+			"description": {"texts", "children", "missing"},
+			"values":      {"{text}", "{<child>text</child>}", "false"},
+		},
+		"SELECT xpath('/items/item[2]/@id', '<items><item id=\"1\"/><item id=\"2\">b &amp; c</item></items>') AS ids, xpath('//item/text()', '<items><item id=\"1\"/><item id=\"2\">b &amp; c</item></items>') AS texts": {
+			"description": {"ids", "texts"},
+			"values":      {"{2}", "{b &amp; c}"},
+		},
+		"SELECT pg_snapshot_column FROM public.test_table WHERE pg_snapshot_column IS NOT NULL": {
+			"description": {"pg_snapshot_column"},
+			"values":      {"2784:2784:"},
+		},
+		"SELECT pg_snapshot_column FROM public.test_table WHERE pg_snapshot_column IS NULL": {
+			"description": {"pg_snapshot_column"},
+			"values":      {""},
+		},
+		"SELECT point_column FROM public.test_table WHERE point_column IS NOT NULL": {
+			"description": {"point_column"},
+			"values":      {"(37.347301483154,45.002101898193)"},
+		},
+		"SELECT point_column FROM public.test_table WHERE point_column IS NULL": {
+			"description": {"point_column"},
+			"values":      {""},
+		},
+		"SELECT inet_column FROM public.test_table WHERE inet_column IS NOT NULL": {
+			"description": {"inet_column"},
+			"values":      {"192.168.0.1"},
+		},
+		"SELECT inet_column FROM public.test_table WHERE inet_column IS NULL": {
+			"description": {"inet_column"},
+			"values":      {""},
+		},
+		"SELECT array_text_column FROM public.test_table WHERE array_text_column IS NOT NULL": {
+			"description": {"array_text_column"},
+			"values":      {"{one,two,three}"},
+		},
+		"SELECT array_text_column FROM public.test_table WHERE array_text_column IS NULL": {
+			"description": {"array_text_column"},
+			"values":      {""},
+		},
+		"SELECT array_int_column FROM public.test_table WHERE bool_column = TRUE": {
+			"description": {"array_int_column"},
+			"values":      {"{1,2,3}"},
+		},
+		"SELECT array_int_column FROM public.test_table WHERE bool_column = FALSE": {
+			"description": {"array_int_column"},
+			"values":      {""},
+		},
+		"SELECT array_ltree_column FROM public.test_table WHERE array_ltree_column IS NOT NULL": {
+			"description": {"array_ltree_column"},
+			"values":      {"{a.b,c.d}"},
+		},
+		"SELECT array_ltree_column FROM public.test_table WHERE array_ltree_column IS NULL": {
+			"description": {"array_ltree_column"},
+			"values":      {""},
+		},
+		"SELECT user_defined_column FROM public.test_table WHERE user_defined_column IS NOT NULL": {
+			"description": {"user_defined_column"},
+			"values":      {"(Toronto)"},
+		},
+		"SELECT user_defined_column FROM public.test_table WHERE user_defined_column IS NULL": {
+			"description": {"user_defined_column"},
+			"values":      {""},
+		},
+		// Typecasts
+		"SELECT objoid, classoid, objsubid, description FROM pg_description WHERE classoid = 'pg_class'::regclass": {
+			"description": {"objoid", "classoid", "objsubid", "description"},
+			"values":      {},
+		},
+		"SELECT d.objoid, d.classoid, d.description, c.relname FROM pg_description d JOIN pg_class c ON d.classoid = 'pg_class'::regclass": {
+			"description": {"objoid", "classoid", "description", "relname"},
+			"values":      {},
+		},
+		"SELECT objoid, classoid, objsubid, description FROM (SELECT * FROM pg_description WHERE classoid = 'pg_class'::regclass) d": {
+			"description": {"objoid", "classoid", "objsubid", "description"},
+			"values":      {},
+		},
+		"SELECT objoid, classoid, objsubid, description FROM pg_description WHERE (classoid = 'pg_class'::regclass AND objsubid = 0) OR classoid = 'pg_type'::regclass": {
+			"description": {"objoid", "classoid", "objsubid", "description"},
+			"values":      {},
+		},
+		"SELECT objoid, classoid, objsubid, description FROM pg_description WHERE classoid IN ('pg_class'::regclass, 'pg_type'::regclass)": {
+			"description": {"objoid", "classoid", "objsubid", "description"},
+			"values":      {},
+		},
+		"SELECT objoid FROM pg_description WHERE classoid = CASE WHEN true THEN 'pg_class'::regclass ELSE 'pg_type'::regclass END": {
+			"description": {"objoid"},
+			"values":      {},
+		},
+		"SELECT word FROM (VALUES ('abort', 'U', 't', 'unreserved', 'can be bare label')) t(word, catcode, barelabel, catdesc, baredesc) WHERE word <> ALL('{a,abs,absolute,action}'::text[])": {
+			"description": {"word"},
+			"values":      {"abort"},
+		},
+		// String literals
+		"SELECT E'line1\\nline2\\ttab \\'quoted\\' back\\\\slash \\x41\\u00e9' AS escaped": {
+			"description": {"escaped"},
+			"values":      {"line1\nline2\ttab 'quoted' back\\slash Aé"},
+		},
+		"SELECT $$it's \"quoted\" \\n$$ AS dollar_quoted, $tag$a $$ b$tag$ AS tagged": {
+			"description": {"dollar_quoted", "tagged"},
+			"values":      {"it's \"quoted\" \\n", "a $$ b"},
+		},
+		"SELECT text_column FROM public.test_table WHERE text_column = E'\\x74ext' AND $$it's$$ = E'it\\'s'": {
+			"description": {"text_column"},
+			"values":      {"text"},
+		},
+		// SHOW
+		"SHOW search_path": {
+			"description": {"search_path"},
+			"values":      {`"$user", public`},
+		},
+		"SHOW max_prepared_transactions": {
+			"description": {"max_prepared_transactions"},
+			"values":      {"0"},
+		},
+		// SELECT * FROM function()
+		"SELECT * FROM pg_catalog.pg_get_keywords() LIMIT 1": {
+			"description": {"word", "catcode", "barelabel", "catdesc", "baredesc"},
+			"values":      {"abort", "U", "t", "unreserved", "can be bare label"},
+		},
+		"SELECT pg_get_keywords.word FROM pg_catalog.pg_get_keywords() LIMIT 1": {
+			"description": {"word"},
+			"values":      {"abort"},
+		},
+		"SELECT * FROM generate_series(1, 2) AS series(index) LIMIT 1": {
+			"description": {"index"},
+			"values":      {"1"},
+		},
+		"SELECT * FROM generate_series(1, array_upper(current_schemas(FALSE), 1)) AS series(index) LIMIT 1": {
+			"description": {"index"},
+			"values":      {"1"},
+		},
+		"SELECT * FROM pg_catalog.generate_series(1, 3) AS series(index) LIMIT 1": {
+			"description": {"index"},
+			"values":      {"1"},
+		},
+		"SELECT * FROM pg_catalog.unnest(ARRAY['a', 'b']) AS letters(letter) LIMIT 1": {
+			"description": {"letter"},
+			"values":      {"a"},
+		},
+		"SELECT series.index, numbers.number FROM pg_catalog.generate_series(1, 2) AS series(index) JOIN pg_catalog.unnest(ARRAY[2, 3]) AS numbers(number) ON series.index = numbers.number": {
+			"description": {"index", "number"},
+			"values":      {"2", "2"},
+		},
+		"SELECT * FROM pg_get_keywords() LIMIT 1": {
+			"description": {"word", "catcode", "barelabel", "catdesc", "baredesc"},
+			"values":      {"abort", "U", "t", "unreserved", "can be bare label"},
+		},
+		"SELECT * FROM pg_catalog.pg_is_in_recovery()": {
+			"description": {"pg_is_in_recovery"},
+			"values":      {"f"},
+		},
+		// Transformed JOIN's
+		"SELECT s.usename, r.rolconfig FROM pg_catalog.pg_shadow s LEFT JOIN pg_catalog.pg_roles r ON s.usename = r.rolname": {
+			"description": {"usename", "rolconfig"},
+			"values":      {"bemidb", "NULL"},
+		},
+		"SELECT a.oid, pd.description FROM pg_catalog.pg_roles a LEFT JOIN pg_catalog.pg_shdescription pd ON a.oid = pd.objoid": {
+			"description": {"oid", "description"},
+			"values":      {"10", ""},
+		},
+		// CASE
+		"SELECT CASE WHEN true THEN 'yes' ELSE 'no' END AS case": {
+			"description": {"case"},
+			"values":      {"yes"},
+		},
+		"SELECT CASE WHEN false THEN 'yes' ELSE 'no' END AS case": {
+			"description": {"case"},
+			"values":      {"no"},
+		},
+		"SELECT CASE WHEN true THEN 'one' WHEN false THEN 'two' ELSE 'three' END AS case": {
+			"description": {"case"},
+			"values":      {"one"},
+		},
+		"SELECT CASE WHEN (SELECT count(extname) FROM pg_catalog.pg_extension WHERE extname = 'bdr') > 0 THEN 'pgd' WHEN (SELECT count(*) FROM pg_replication_slots) > 0 THEN 'log' ELSE NULL END AS type": {
+			"description": {"type"},
+			"values":      {""},
+		},
+		"SELECT roles.oid AS id, roles.rolname AS name, roles.rolsuper AS is_superuser, CASE WHEN roles.rolsuper THEN true ELSE false END AS can_create_role FROM pg_catalog.pg_roles roles WHERE rolname = current_user": {
+			"description": {"id", "name", "is_superuser", "can_create_role"},
+			"values":      {},
+		},
+		"SELECT roles.oid AS id, roles.rolname AS name, roles.rolsuper AS is_superuser, CASE WHEN roles.rolsuper THEN true ELSE roles.rolcreaterole END AS can_create_role FROM pg_catalog.pg_roles roles WHERE rolname = current_user": {
+			"description": {"id", "name", "is_superuser", "can_create_role"},
+			"values":      {},
+		},
+		// WHERE pg functions
+		"SELECT gss_authenticated, encrypted FROM (SELECT false, false, false, false, false WHERE false) t(pid, gss_authenticated, principal, encrypted, credentials_delegated) WHERE pid = pg_backend_pid()": {
+			"description": {"gss_authenticated", "encrypted"},
+			"values":      {},
+		},
+		// WITH
+		"WITH RECURSIVE simple_cte AS (SELECT oid, rolname FROM pg_roles WHERE rolname = 'postgres' UNION ALL SELECT oid, rolname FROM pg_roles) SELECT * FROM simple_cte": {
+			"description": {"oid", "rolname"},
+			"values":      {"10", "bemidb"},
+		},
+		// Table alias
+		"SELECT pg_shadow.usename FROM pg_shadow": {
+			"description": {"usename"},
+			"values":      {"bemidb"},
+		},
+		"SELECT pg_roles.rolname FROM pg_roles": {
+			"description": {"rolname"},
+			"values":      {"bemidb"},
+		},
+		"SELECT pg_extension.extname FROM pg_extension": {
+			"description": {"extname"},
+			"values":      {"plpgsql"},
+		},
+		"SELECT pg_database.datname FROM pg_database": {
+			"description": {"datname"},
+			"values":      {"bemidb"},
+		},
+		"SELECT pg_inherits.inhrelid FROM pg_inherits": {
+			"description": {"inhrelid"},
+			"values":      {},
+		},
+		"SELECT pg_shdescription.objoid FROM pg_shdescription": {
+			"description": {"objoid"},
+			"values":      {},
+		},
+		"SELECT pg_statio_user_tables.relid FROM pg_statio_user_tables": {
+			"description": {"relid"},
+			"values":      {},
+		},
+		"SELECT pg_replication_slots.slot_name FROM pg_replication_slots": {
+			"description": {"slot_name"},
+			"values":      {},
+		},
+		"SELECT pg_stat_gssapi.pid FROM pg_stat_gssapi": {
+			"description": {"pid"},
+			"values":      {},
+		},
+		"SELECT pg_auth_members.oid FROM pg_auth_members": {
+			"description": {"oid"},
+			"values":      {},
+		},
+		"SELECT tables.table_name FROM information_schema.tables": {
+			"description": {"table_name"},
+			"values":      {"test_table"},
+		},
+		// Sublink's in target list
+		"SELECT x.usename, (SELECT passwd FROM pg_shadow WHERE usename = x.usename) as password FROM pg_shadow x WHERE x.usename = 'bemidb'": {
+			"description": {"usename", "password"},
+			"values":      {"bemidb", "bemidb-encrypted"},
+		},
+	}
+
+	for query, responses := range responsesByQuery {
+		t.Run(query, func(t *testing.T) {
+			queryHandler := initQueryHandler()
+
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			testRowDescription(t, messages[0], responses["description"])
+
+			if len(responses["values"]) > 0 {
+				testMessageTypes(t, messages, []pgproto3.Message{
+					&pgproto3.RowDescription{},
+					&pgproto3.DataRow{},
+					&pgproto3.CommandComplete{},
+				})
+				testDataRowValues(t, messages[1], responses["values"])
+			} else {
+				testMessageTypes(t, messages, []pgproto3.Message{
+					&pgproto3.RowDescription{},
+					&pgproto3.CommandComplete{},
+				})
+			}
+
+		})
+	}
+
+	t.Run("Returns NULL from boolean and bitwise aggregates over all-NULL and empty groups", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery(`SELECT g, bool_and(b) AS bool_and, bool_or(b) AS bool_or, bit_and(i) AS bit_and, bit_or(i) AS bit_or
+FROM (VALUES (1, true, 12), (1, NULL, NULL), (2, NULL::boolean, NULL::int)) t(g, b, i) GROUP BY g ORDER BY g`)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"1", "true", "true", "12", "12"})
+		testDataRowNullValues(t, messages[2], 1, 4)
+
+		messages, err = queryHandler.HandleQuery("SELECT bool_and(b) AS bool_and, bit_or(i) AS bit_or FROM (VALUES (true, 1)) t(b, i) WHERE false")
+
+		testNoError(t, err)
+		testDataRowNullValues(t, messages[1], 0, 1)
+	})
+
+	t.Run("Zips multiple arrays in unnest() padding shorter arrays with NULL", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT * FROM unnest(ARRAY[1, 2, 3], ARRAY['a', 'b']) AS t(number, letter)")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"number", "letter"})
+		testDataRowValues(t, messages[1], []string{"1", "a"})
+		testDataRowValues(t, messages[2], []string{"2", "b"})
+		testDataRowValues(t, messages[3], []string{"3", ""})
+		testDataRowNullValues(t, messages[3], 1, 1)
+
+		messages, err = queryHandler.HandleQuery("SELECT t.letter, t.number FROM pg_catalog.unnest(ARRAY['a'], ARRAY[1, 2]) AS t(letter, number) WHERE t.number = 2")
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], []string{"letter", "number"})
+		testDataRowNullValues(t, messages[1], 0, 0)
+		testDataRowValues(t, messages[1], []string{"", "2"})
+	})
+
+	t.Run("Orders by trigram similarity like pg_trgm", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT name, similarity(name, 'word') AS similarity FROM (VALUES ('letters'), ('two words'), ('word'), ('sword')) AS t(name) WHERE name % 'word' OR name = 'letters' ORDER BY name <-> 'word'")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"word", "1"})
+		testDataRowValues(t, messages[2], []string{"sword", "0.375"})
+		testDataRowValues(t, messages[3], []string{"two words", "0.36363637"})
+		testDataRowValues(t, messages[4], []string{"letters", "0"})
+	})
+
+	t.Run("Advances clock_timestamp() within a statement while now() stays fixed", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery(`SELECT count(DISTINCT clock_timestamp()) > 1 AS clock_advances,
+count(DISTINCT now()) AS now_count, count(DISTINCT current_timestamp) AS current_timestamp_count,
+count(DISTINCT transaction_timestamp()) AS transaction_count, count(DISTINCT statement_timestamp()) AS statement_count,
+bool_and(now() = statement_timestamp()) AS statement_is_now
+FROM generate_series(1, 100000)`)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"true", "1", "1", "1", "1", "true"})
+
+		messages, err = queryHandler.HandleQuery("SELECT min(clock_timestamp()) < max(clock_timestamp()) AS clock_advances, min(now()) = max(now()) AS now_fixed FROM generate_series(1, 100000)")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"true", "true"})
+	})
+
+	t.Run("Returns timestamptz values in the session time zone", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT '2024-01-01 12:00:00.123456+00'::timestamptz AS t")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2024-01-01 12:00:00.123456+00"})
+
+		_, err = queryHandler.HandleQuery("SET TIME ZONE 'Asia/Kolkata'")
+		testNoError(t, err)
+		messages, err = queryHandler.HandleQuery("SELECT '2024-01-01 12:00:00.123456+00'::timestamptz AS t")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2024-01-01 17:30:00.123456+05:30"})
+
+		_, err = queryHandler.HandleQuery("SET timezone TO 'America/New_York'")
+		testNoError(t, err)
+		messages, err = queryHandler.HandleQuery("SELECT '2024-01-01 12:00:00+00'::timestamptz AS t")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2024-01-01 07:00:00-05"})
+	})
+
+	t.Run("Returns primary keys from information_schema.table_constraints and key_column_usage", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_pk_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		loaded := false
+		icebergWriter.Write(schemaTable, []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog", PrimaryKeyPosition: "1"},
+			{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog", PrimaryKeyPosition: "0"},
+		}, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return [][]string{{"1", "Alice"}}
+		})
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT table_schema, table_name, constraint_name, constraint_type FROM information_schema.table_constraints WHERE table_name = 'test_pk_table'")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"public", "test_pk_table", "test_pk_table_pkey", "PRIMARY KEY"})
+
+		messages, err = queryHandler.HandleQuery(`SELECT kcu.column_name, kcu.ordinal_position
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_name = 'test_pk_table'`)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"id", "1"})
+
+		messages, err = queryHandler.HandleQuery("SELECT column_name FROM information_schema.key_column_usage WHERE table_name = 'test_table'")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.CommandComplete{},
+		})
+	})
+
+	t.Run("Returns column defaults from information_schema.columns", func(t *testing.T) {
+		config := loadTestConfig()
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchema("test_defaults")
+		for _, schema := range []string{"public", "test_defaults"} {
+			schemaTable := IcebergSchemaTable{Schema: schema, Table: "test_default_table"}
+			defer icebergWriter.DeleteSchemaTable(schemaTable)
+			loaded := false
+			icebergWriter.Write(schemaTable, []PgSchemaColumn{
+				{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog", ColumnDefault: "nextval('public.test_default_table_id_seq'::regclass)"},
+				{ColumnName: "status", DataType: "character varying", UdtName: "varchar", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog", ColumnDefault: "'active'::character varying"},
+				{ColumnName: "created_at", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "YES", OrdinalPosition: "3", DatetimePrecision: "6", Namespace: "pg_catalog", ColumnDefault: "now()"},
+				{ColumnName: "notes", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "4", Namespace: "pg_catalog"},
+			}, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return [][]string{{"1", "active", "2024-01-01 00:00:00", "note"}}
+			})
+		}
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT table_schema, column_name, ordinal_position, column_default, is_nullable, data_type, udt_name FROM information_schema.columns WHERE table_name = 'test_default_table' ORDER BY table_schema, ordinal_position")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"public", "id", "1", "nextval('test_default_table_id_seq'::regclass)", "NO", "integer", "int4"})
+		testDataRowValues(t, messages[2], []string{"public", "status", "2", "'active'::character varying", "YES", "text", "text"})
+		testDataRowValues(t, messages[3], []string{"public", "created_at", "3", "now()", "YES", "timestamp without time zone", "timestamp"})
+		testDataRowValues(t, messages[4], []string{"public", "notes", "4", "", "YES", "text", "text"})
+		testDataRowValues(t, messages[5], []string{"test_defaults", "id", "1", "nextval('test_defaults.test_default_table_id_seq'::regclass)", "NO", "integer", "int4"})
+
+		messages, err = queryHandler.HandleQuery("SELECT column_name FROM information_schema.columns WHERE table_name = 'test_default_table' AND table_schema = 'public' AND column_default IS NULL")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"notes"})
+	})
+
+	t.Run("Returns EmptyQueryResponse for an empty or comment-only query", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for _, query := range []string{"", "  \n", "-- comment", "/* comment */ ;"} {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			testMessageTypes(t, messages, []pgproto3.Message{
+				&pgproto3.EmptyQueryResponse{},
+			})
+		}
+	})
+
+	t.Run("Requires quoting mixed-case table names unless case-insensitive table names are enabled", func(t *testing.T) {
+		config := loadTestConfig()
+		icebergWriter := NewIcebergWriter(config)
+		for _, table := range []string{"TestCaseTable", "TestAmbiguousCase", "TESTAMBIGUOUSCASE"} {
+			schemaTable := IcebergSchemaTable{Schema: "public", Table: table}
+			defer icebergWriter.DeleteSchemaTable(schemaTable)
+			loaded := false
+			icebergWriter.Write(schemaTable, []PgSchemaColumn{
+				{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+			}, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return [][]string{{"1"}}
+			})
+		}
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SELECT id FROM TestCaseTable")
+
+		expectedErrorMessage := `relation "testcasetable" does not exist, quote the mixed-case name to query "public"."TestCaseTable"`
+		if err == nil || err.Error() != expectedErrorMessage {
+			t.Errorf("Expected the error to be '%s', got %v", expectedErrorMessage, err)
+		}
+
+		messages, err := queryHandler.HandleQuery(`SELECT id FROM "TestCaseTable"`)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"1"})
+
+		queryHandler.config.CaseInsensitiveTables = true
+		messages, err = queryHandler.HandleQuery("SELECT t.id FROM TestCaseTable t JOIN public.testcasetable USING (id) WHERE id IN (SELECT id FROM testcasetable)")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"1"})
+
+		_, err = queryHandler.HandleQuery("SELECT id FROM TestAmbiguousCase")
+
+		expectedErrorMessage = `table name "testambiguouscase" is ambiguous, it matches "public"."TESTAMBIGUOUSCASE", "public"."TestAmbiguousCase"`
+		if err == nil || err.Error() != expectedErrorMessage {
+			t.Errorf("Expected the error to be '%s', got %v", expectedErrorMessage, err)
+		}
+	})
+
+	t.Run("Queries and lists aliased schemas under their exposed name", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "test_lake_schema", Table: "test_schema_alias_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchema(schemaTable.Schema)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		config.SchemaAliases = map[string]string{"test_exposed_schema": "test_lake_schema"}
+		queryHandler := NewQueryHandler(config, NewDuckdb(config), NewIcebergReader(config))
+
+		expectedRowCount := IntToString(len(TEST_LOADED_ROWS))
+		testSessionSetting(t, queryHandler, "SELECT COUNT(*) FROM test_exposed_schema.test_schema_alias_table", expectedRowCount)
+		testSessionSetting(t, queryHandler, "SELECT table_schema FROM information_schema.tables WHERE table_name = 'test_schema_alias_table'", "test_exposed_schema")
+		testSessionSetting(t, queryHandler, "SELECT string_agg(nspname, ',') FROM pg_catalog.pg_namespace WHERE nspname LIKE 'test_%_schema'", "test_exposed_schema")
+		testSessionSetting(t, queryHandler, "SELECT DISTINCT table_schema FROM information_schema.columns WHERE table_name = 'test_schema_alias_table'", "test_exposed_schema")
+
+		_, err := queryHandler.HandleQuery("SET search_path TO test_exposed_schema")
+		testNoError(t, err)
+
+		testSessionSetting(t, queryHandler, "SELECT COUNT(*) FROM test_schema_alias_table", expectedRowCount)
+		testSessionSetting(t, queryHandler, "SELECT current_schema()", "test_exposed_schema")
+	})
+
+	t.Run("Returns the data files and snapshots of a table from its metadata tables", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_metadata_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		loaded := false
+		icebergWriter.Write(schemaTable, []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		}, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return [][]string{{"1"}, {"2"}, {"3"}}
+		})
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT file_path LIKE '%/test_metadata_table/data/%.parquet' AS parquet, file_format, partition, record_count, file_size_in_bytes > 0 AS sized FROM test_metadata_table.files")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"parquet", "file_format", "partition", "record_count", "sized"})
+		testDataRowValues(t, messages[1], []string{"true", "PARQUET", "{}", "3", "true"})
+
+		messages, err = queryHandler.HandleQuery("SELECT s.operation, s.parent_id IS NULL AS root, s.summary::json->>'added-records' AS added_records FROM public.test_metadata_table.snapshots s")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"append", "true", "3"})
+
+		_, err = queryHandler.HandleQuery("SELECT * FROM test_missing_table.files")
+
+		if err == nil {
+			t.Errorf("Expected an error for the metadata table of a missing table")
+		}
+	})
+
+	t.Run("Reads a table through a local copy of its files when DuckDB can't read the storage directly", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.StorageReadFallback = STORAGE_READ_FALLBACK_ALWAYS
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_local_copy_table"}
+		icebergWriter := NewIcebergWriter(&config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		loaded := false
+		icebergWriter.Write(schemaTable, []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		}, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return [][]string{{"1"}, {"2"}}
+		})
+		tempDirPath := t.TempDir()
+		t.Setenv("TMPDIR", tempDirPath)
+		queryHandler := NewQueryHandler(&config, NewDuckdb(&config), NewIcebergReader(&config))
+
+		messages, err := queryHandler.HandleQuery("SELECT COUNT(*) AS count FROM test_local_copy_table t1 JOIN test_local_copy_table t2 ON t1.id = t2.id")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"2"})
+		remapper := queryHandler.selectRemapper.remapperTable
+		remappedQuery, err := queryHandler.remapQuery("SELECT * FROM test_local_copy_table")
+		testNoError(t, err)
+		localCopyDirPaths := remapper.TakeLocalCopyDirPaths()
+		if len(localCopyDirPaths) != 1 || !strings.Contains(remappedQuery, "iceberg_scan('"+localCopyDirPaths[0]) {
+			t.Errorf("Expected the table to be read from a local copy, got %v", remappedQuery)
+		}
+		queryHandler.removeLocalCopies(localCopyDirPaths)
+		tempDirEntries, err := os.ReadDir(tempDirPath)
+		testNoError(t, err)
+		if len(tempDirEntries) != 0 {
+			t.Errorf("Expected the local copies to be removed, got %v", tempDirEntries)
+		}
+	})
+
+	t.Run("Reads tables directly from the storage if DuckDB can read it", func(t *testing.T) {
+		config := *loadTestConfig()
+
+		for _, storageReadFallback := range []string{STORAGE_READ_FALLBACK_AUTO, STORAGE_READ_FALLBACK_NEVER} {
+			config.StorageReadFallback = storageReadFallback
+			if NewIcebergReader(&config).ReadsLocalCopies() {
+				t.Errorf("Expected local storage to be read directly with the %v fallback", storageReadFallback)
+			}
+		}
+	})
+
+	t.Run("Round-trips strings with backslashes and quotes interpolated by pgx with the simple protocol", func(t *testing.T) {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, startTestServer(t))
+		testNoError(t, err)
+		defer conn.Close(ctx)
+
+		var value string
+		err = conn.QueryRow(ctx, "SELECT $1::text AS value", pgx.QueryExecModeSimpleProtocol, `C:\path\n 'quoted' $$`).Scan(&value)
+
+		testNoError(t, err)
+		if value != `C:\path\n 'quoted' $$` {
+			t.Errorf("Expected the string to be unchanged, got %v", value)
+		}
+		if conn.PgConn().ParameterStatus("standard_conforming_strings") != "on" {
+			t.Errorf("Expected standard_conforming_strings to be reported as on, got %v", conn.PgConn().ParameterStatus("standard_conforming_strings"))
+		}
+	})
+
+	t.Run("Returns all rows for LIMIT ALL and a NULL LIMIT or OFFSET", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for _, query := range []string{
+			"SELECT int4_column FROM public.test_table ORDER BY int4_column LIMIT ALL",
+			"SELECT int4_column FROM public.test_table ORDER BY int4_column LIMIT NULL OFFSET NULL",
+			"SELECT int4_column FROM (SELECT int4_column FROM public.test_table LIMIT NULL::bigint) t ORDER BY int4_column LIMIT ALL OFFSET 1",
+		} {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			remappedQuery, _ := queryHandler.remapQuery(query)
+			if strings.Contains(remappedQuery, "LIMIT") || strings.Contains(remappedQuery, "NULL") {
+				t.Errorf("Expected LIMIT ALL and NULL to be removed, got %v", remappedQuery)
+			}
+			if strings.Contains(query, "OFFSET 1") {
+				testMessageTypes(t, messages, []pgproto3.Message{&pgproto3.RowDescription{}, &pgproto3.DataRow{}, &pgproto3.CommandComplete{}})
+			} else {
+				testMessageTypes(t, messages, []pgproto3.Message{&pgproto3.RowDescription{}, &pgproto3.DataRow{}, &pgproto3.DataRow{}, &pgproto3.CommandComplete{}})
+			}
+		}
+	})
+
+	t.Run("Returns an error for a negative LIMIT or OFFSET", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for query, expectedErrorMessage := range map[string]string{
+			"SELECT int4_column FROM public.test_table LIMIT -1":          "LIMIT must not be negative",
+			"SELECT int4_column FROM public.test_table LIMIT 1 OFFSET -1": "OFFSET must not be negative",
+		} {
+			_, err := queryHandler.HandleQuery(query)
+
+			if err == nil || err.Error() != expectedErrorMessage {
+				t.Errorf("Expected the error to be '%s', got %v", expectedErrorMessage, err)
+			}
+		}
+	})
+
+	t.Run("Returns arrays of composite types and multidimensional arrays", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_nested_array_table"}
+		testWriteCatalogTable(queryHandler.config, schemaTable, []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", NumericScale: "0", Namespace: "pg_catalog"},
+			{ColumnName: "addresses", DataType: "ARRAY", UdtName: "_address", IsNullable: "YES", OrdinalPosition: "2", Namespace: "public"},
+			{ColumnName: "matrix", DataType: "ARRAY", UdtName: "_int4", IsNullable: "YES", OrdinalPosition: "3", Namespace: "pg_catalog"},
+		}, [][]string{
+			{"1", `{"(Toronto,\"M5V 2T6\")","(\"New York\",10001)",NULL}`, "{{1,2},{3,NULL}}"},
+			{"2", "{}", "[0:1]={5,6}"},
+		})
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		messages, err := queryHandler.HandleQuery("SELECT addresses[1] AS first, addresses[2] AS second, array_length(addresses, 1) AS length, matrix FROM public.test_nested_array_table ORDER BY id")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{`(Toronto,"M5V 2T6")`, `("New York",10001)`, "3", "{1,2,3}"})
+		testDataRowValues(t, messages[2], []string{"", "", "", "{5,6}"})
+	})
+
+	t.Run("Compares an element with the bounds of a range constructor honoring their inclusivity", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for bounds, expectedCounts := range map[string][]string{
+			"[]": {"1", "1"},
+			"[)": {"1", "0"},
+			"(]": {"0", "1"},
+			"()": {"0", "0"},
+		} {
+			for i, query := range []string{
+				"SELECT count(*) AS count FROM public.test_table WHERE timestamptz_column <@ tstzrange('2024-01-01 17:00:00.123456+00', '2024-01-01 18:00:00+00', '" + bounds + "')",
+				"SELECT count(*) AS count FROM public.test_table WHERE tstzrange('2024-01-01 16:00:00+00', '2024-01-01 17:00:00.123456+00', '" + bounds + "') @> timestamptz_column",
+			} {
+				messages, err := queryHandler.HandleQuery(query)
+
+				testNoError(t, err)
+				testDataRowValues(t, messages[1], []string{expectedCounts[i]})
+			}
+		}
+
+		for query, expectedCount := range map[string]string{
+			"SELECT count(*) AS count FROM public.test_table WHERE date_column <@ daterange('2021-01-01', '2021-01-02')":        "1",
+			"SELECT count(*) AS count FROM public.test_table WHERE date_column <@ daterange('2020-12-31', '2021-01-01')":        "0",
+			"SELECT count(*) AS count FROM public.test_table WHERE numrange(12345.67, NULL, '[]') @> numeric_column":            "1",
+			"SELECT count(*) AS count FROM public.test_table WHERE numeric_column <@ numrange(NULL, 12345.67)":                  "1",
+			"SELECT count(*) AS count FROM public.test_table WHERE date_column <@ daterange(date_column, NULL::date + 1, '[]')": "1",
+		} {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			testDataRowValues(t, messages[1], []string{expectedCount})
+		}
+
+		remappedQuery, err := queryHandler.remapQuery("SELECT id FROM public.test_table WHERE timestamptz_column <@ tstzrange('2024-01-01', '2024-02-01', '(]')")
+
+		testNoError(t, err)
+		expectedWhere := "WHERE timestamptz_column > '2024-01-01'::timestamptz AND timestamptz_column <= '2024-02-01'::timestamptz"
+		if !strings.Contains(remappedQuery, expectedWhere) {
+			t.Errorf("Expected the remapped query to contain %s, got %s", expectedWhere, remappedQuery)
+		}
+	})
+
+	t.Run("Compares two range columns with range operators", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_range_table"}
+		testWriteCatalogTable(queryHandler.config, schemaTable, []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", NumericScale: "0", Namespace: "pg_catalog"},
+			{ColumnName: "booked_period", DataType: "tstzrange", UdtName: "tstzrange", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+			{ColumnName: "blocked_period", DataType: "tstzrange", UdtName: "tstzrange", IsNullable: "YES", OrdinalPosition: "3", Namespace: "pg_catalog"},
+		}, [][]string{
+			{"1", `["2024-01-01 00:00:00+00","2024-01-03 00:00:00+00")`, `["2024-01-02 00:00:00+00","2024-01-04 00:00:00+00")`},
+			{"2", `["2024-01-01 00:00:00+00","2024-01-02 00:00:00+00")`, `["2024-01-02 00:00:00+00","2024-01-04 00:00:00+00")`},
+			{"3", `["2024-01-01 00:00:00+00","2024-01-05 00:00:00+00")`, `["2024-01-02 00:00:00+00","2024-01-04 00:00:00+00")`},
+		})
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		for query, expectedValues := range map[string][]string{
+			"SELECT id FROM public.test_range_table WHERE booked_period && blocked_period ORDER BY id":                                                {"1", "3"},
+			"SELECT id FROM public.test_range_table WHERE booked_period @> blocked_period ORDER BY id":                                                {"3"},
+			"SELECT id FROM public.test_range_table WHERE blocked_period <@ booked_period ORDER BY id":                                                {"3"},
+			"SELECT id FROM public.test_range_table WHERE booked_period @> tstzrange('2024-01-02 00:00:00+00', '2024-01-03 00:00:00+00') ORDER BY id": {"1", "3"},
+		} {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			if len(messages) != len(expectedValues)+2 {
+				t.Fatalf("Expected %v rows for %s, got %v messages", len(expectedValues), query, len(messages))
+			}
+			for i, expectedValue := range expectedValues {
+				testDataRowValues(t, messages[i+1], []string{expectedValue})
+			}
+		}
+	})
+
+	t.Run("Returns the top 3 rows per group of a window function in a subquery", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := testWriteTopNTable(queryHandler.config)
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		for query, expectedRows := range map[string][][]string{
+			"SELECT category, name, score FROM (SELECT category, name, score, row_number() OVER (PARTITION BY category ORDER BY score DESC, name) AS rn FROM public.test_top_n_table) ranked WHERE rn <= 3 ORDER BY category, rn": {
+				{"a", "a2", "50"}, {"a", "a3", "50"}, {"a", "a1", "40"},
+				{"b", "b1", "70"}, {"b", "b2", "10"},
+			},
+			"SELECT category, name, rnk FROM (SELECT category, name, rank() OVER (PARTITION BY category ORDER BY score DESC) AS rnk FROM public.test_top_n_table) ranked WHERE rnk = 1 ORDER BY category, name": {
+				{"a", "a2", "1"}, {"a", "a3", "1"},
+				{"b", "b1", "1"},
+			},
+			"SELECT name, dense_rank() OVER w AS rnk, sum(score) OVER (w ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS running FROM public.test_top_n_table WHERE category = 'a' WINDOW w AS (PARTITION BY category ORDER BY score, name) ORDER BY rnk": {
+				{"a4", "1", "20"}, {"a1", "2", "60"}, {"a2", "3", "90"}, {"a3", "4", "100"},
+			},
+			"SELECT DISTINCT ON (category) category, name, score FROM public.test_top_n_table ORDER BY category, score DESC, name": {
+				{"a", "a2", "50"},
+				{"b", "b1", "70"},
+			},
+		} {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			if len(messages) != len(expectedRows)+2 {
+				t.Fatalf("Expected %d rows for %s, got %v", len(expectedRows), query, messages)
+			}
+			for i, expectedRow := range expectedRows {
+				testDataRowValues(t, messages[i+1], expectedRow)
+			}
+		}
+	})
+
+	t.Run("Returns columns named after Postgres and DuckDB keywords", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := testWriteKeywordColumnsTable(queryHandler.config)
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		for query, expectedRow := range map[string][]string{
+			`SELECT "order", "group", "select" FROM public.test_keyword_columns_table WHERE "order" = 2`:                         {"2", "b", "y"},
+			`SELECT qualify, anti, show FROM public.test_keyword_columns_table ORDER BY "order" DESC LIMIT 1`:                    {"30", "z", "true"},
+			`SELECT t."group", count(*) FROM public.test_keyword_columns_table t GROUP BY t."group" ORDER BY 1 LIMIT 1`:          {"a", "2"},
+			`SELECT max(qualify) AS qualify FROM (SELECT qualify FROM public.test_keyword_columns_table WHERE anti = 'x') pivot`: {"10"},
+		} {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			testMessageTypes(t, messages, []pgproto3.Message{
+				&pgproto3.RowDescription{},
+				&pgproto3.DataRow{},
+				&pgproto3.CommandComplete{},
+			})
+			testDataRowValues(t, messages[1], expectedRow)
+		}
+	})
+
+	t.Run("Quotes DuckDB reserved words in the remapped query", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		remappedQuery, err := queryHandler.remapQuery(`SELECT qualify, "order", map(qualify) FROM (SELECT 1 AS qualify, 2 AS "order") pivot`)
+
+		testNoError(t, err)
+		expectedQuery := `SELECT "qualify", "order", map("qualify") FROM (SELECT 1 AS "qualify", 2 AS "order") "pivot"`
+		if remappedQuery != expectedQuery {
+			t.Errorf("Expected the remapped query to be %s, got %s", expectedQuery, remappedQuery)
+		}
+	})
+
+	t.Run("Keeps the partition and order keys of window functions", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		remappedQuery, err := queryHandler.remapQuery("SELECT * FROM (SELECT id, row_number() OVER (PARTITION BY varchar_column, date_column ORDER BY timestamp_column DESC NULLS LAST, id) AS rn FROM public.test_table) t WHERE rn = 1")
+
+		testNoError(t, err)
+		if !strings.Contains(remappedQuery, "row_number() OVER (PARTITION BY varchar_column, date_column ORDER BY timestamp_column DESC NULLS LAST, id)") {
+			t.Errorf("Expected the window definition to be kept, got %s", remappedQuery)
+		}
+	})
+
+	t.Run("Returns the exact sum of a numeric column beyond float precision", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := testWriteNumericOverflowTable(queryHandler.config, "20", "2", "12345678901234567.89")
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		messages, err := queryHandler.HandleQuery("SELECT SUM(amount) AS sum FROM public.test_numeric_overflow_table")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"24691357802469135.78"})
+	})
+
+	t.Run("Returns a numeric field overflow for a sum beyond 38 digits", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := testWriteNumericOverflowTable(queryHandler.config, "38", "0", "99999999999999999999999999999999999999")
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		for _, query := range []string{
+			"SELECT SUM(amount) FROM public.test_numeric_overflow_table",
+			"SELECT AVG(amount) FROM public.test_numeric_overflow_table",
+		} {
+			_, err := queryHandler.HandleQuery(query)
+
+			if err != ERROR_NUMERIC_AGGREGATE_OVERFLOW {
+				t.Errorf("Expected a numeric field overflow for %s, got %v", query, err)
+			}
+		}
+	})
+
+	t.Run("Returns an approximate sum beyond 38 digits with the DOUBLE numeric aggregate overflow", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		queryHandler.config.NumericAggregateOverflow = NUMERIC_AGGREGATE_OVERFLOW_DOUBLE
+		schemaTable := testWriteNumericOverflowTable(queryHandler.config, "38", "0", "99999999999999999999999999999999999999")
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+
+		messages, err := queryHandler.HandleQuery("SELECT id % 1 AS bucket, SUM(amount) AS sum, AVG(amount) AS avg, COUNT(*) AS count FROM public.test_numeric_overflow_table GROUP BY bucket")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"0", "2e+38", "1e+38", "2"})
+	})
+
+	t.Run("Returns a random UUID from gen_random_uuid()", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT gen_random_uuid(), gen_random_uuid() AS other_uuid")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"gen_random_uuid", "other_uuid"})
+		values := messages[1].(*pgproto3.DataRow).Values
+		uuidRegexp := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+		if !uuidRegexp.Match(values[0]) || !uuidRegexp.Match(values[1]) {
+			t.Errorf("Expected version 4 UUIDs, got %s and %s", values[0], values[1])
+		}
+		if bytes.Equal(values[0], values[1]) {
+			t.Errorf("Expected different UUIDs, got %s twice", values[0])
+		}
+	})
+
+	t.Run("Returns an error for an unrecognized encoding", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SELECT encode('hello'::bytea, 'base32')")
+
+		expectedErrorMessage := `Invalid Input Error: unrecognized encoding: "base32"`
+		if err == nil || err.Error() != expectedErrorMessage {
+			t.Errorf("Expected the error to be '%s', got %v", expectedErrorMessage, err)
+		}
+	})
+
+	t.Run("Returns max_connections matching the configured cap from SHOW and pg_settings", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		queryHandler.config.Server.MaxConnections = 42
+
+		messages, err := queryHandler.HandleQuery("SHOW max_connections")
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], []string{"max_connections"})
+		testDataRowValues(t, messages[1], []string{"42"})
+
+		messages, err = queryHandler.HandleQuery("SELECT setting FROM pg_catalog.pg_settings WHERE name = 'max_connections'")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"42"})
+	})
+
+	t.Run("Reads timestamps stored as INT96 in externally produced Parquet files", func(t *testing.T) {
+		config := loadTestConfig()
+		icebergWriter := NewIcebergWriter(config)
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_int96_table"}
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		loaded := false
+		icebergWriter.Write(schemaTable, []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+			{ColumnName: "created_at", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "YES", OrdinalPosition: "2", DatetimePrecision: "6", Namespace: "pg_catalog"},
+		}, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return [][]string{{"1", "2024-01-02 03:04:05.123456"}}
+		})
+		dataFilePaths, err := filepath.Glob(filepath.Join(config.StoragePath, "public", "test_int96_table", "data", "*.parquet"))
+		testNoError(t, err)
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("SELECT type, converted_type FROM parquet_schema('" + dataFilePaths[0] + "') WHERE name = 'created_at'")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"INT64", "TIMESTAMP_MICROS"})
+
+		// Replace the data file like a legacy writer (e.g., Spark or Impala) would produce it
+		testWriteInt96ParquetFile(t, dataFilePaths[0], []testInt96ParquetRow{
+			{Id: 1, CreatedAt: types.TimeToINT96(time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC))},
+			{Id: 2, CreatedAt: types.TimeToINT96(time.Date(1900, 1, 2, 3, 4, 5, 0, time.UTC))},
+		})
+		messages, err = queryHandler.HandleQuery("SELECT id, created_at FROM test_int96_table ORDER BY id")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"1", "2024-01-02 03:04:05.123456"})
+		testDataRowValues(t, messages[2], []string{"2", "1900-01-02 03:04:05"})
+	})
+
+	t.Run("Remaps Iceberg tables in every arm of set operations", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_set_operation_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		queryHandler := initQueryHandler()
+		valuesByQuery := map[string][]string{
+			"SELECT COUNT(*) AS count FROM (SELECT int4_column FROM test_table UNION ALL SELECT int4_column FROM test_set_operation_table) t":                                         {"4"},
+			"SELECT int4_column FROM test_table EXCEPT SELECT int4_column FROM test_set_operation_table WHERE int4_column IS NULL":                                                    {"2147483647"},
+			"SELECT COUNT(*) AS count FROM ((SELECT int4_column FROM test_table UNION SELECT int4_column FROM test_set_operation_table) EXCEPT SELECT int4_column FROM test_table) t": {"0"},
+			"SELECT COUNT(*) AS count FROM test_table WHERE int4_column IN (SELECT int4_column FROM test_set_operation_table INTERSECT SELECT int4_column FROM test_table)":           {"1"},
+		}
+
+		for query, values := range valuesByQuery {
+			t.Run(query, func(t *testing.T) {
+				messages, err := queryHandler.HandleQuery(query)
+
+				testNoError(t, err)
+				testMessageTypes(t, messages, []pgproto3.Message{
+					&pgproto3.RowDescription{},
+					&pgproto3.DataRow{},
+					&pgproto3.CommandComplete{},
+				})
+				testDataRowValues(t, messages[1], values)
+			})
+		}
+	})
+
+	t.Run("Applies DuckDB settings from a query hint and restores them afterwards", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		messages, err := queryHandler.HandleQuery("SELECT current_setting('threads') AS threads, current_setting('disabled_optimizers') AS disabled_optimizers")
+		testNoError(t, err)
+		defaultValues := []string{string(messages[1].(*pgproto3.DataRow).Values[0]), string(messages[1].(*pgproto3.DataRow).Values[1])}
+
+		messages, err = queryHandler.HandleQuery("/*+ duckdb: SET threads=2; SET disabled_optimizers TO 'filter_pushdown' */ SELECT current_setting('threads') AS threads, current_setting('disabled_optimizers') AS disabled_optimizers")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2", "filter_pushdown"})
+
+		messages, err = queryHandler.HandleQuery("SELECT current_setting('threads') AS threads, current_setting('disabled_optimizers') AS disabled_optimizers")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], defaultValues)
+	})
+
+	t.Run("Returns an error for a DuckDB setting that isn't allowed in a query hint", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("/*+ duckdb: SET memory_limit='1GB' */ SELECT 1")
+
+		if err == nil || err.Error() != `DuckDB setting "memory_limit" is not allowed in a duckdb hint` {
+			t.Errorf("Expected a disallowed setting error, got %v", err)
+		}
+	})
+
+	t.Run("Returns an error if a table does not exist", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SELECT * FROM non_existent_table")
+
+		if err == nil {
+			t.Errorf("Expected an error, got nil")
+		}
+
+		expectedErrorMessage := strings.Join([]string{
+			"Catalog Error: Table with name non_existent_table does not exist!",
+			"Did you mean \"test_table\"?",
+			"LINE 1: SELECT * FROM non_existent_table",
+			"                      ^",
+		}, "\n")
+		if err.Error() != expectedErrorMessage {
+			t.Errorf("Expected the error to be '"+expectedErrorMessage+"', got %v", err.Error())
+		}
+	})
+
+	t.Run("Returns an error with the name of an unsupported feature", func(t *testing.T) {
+		errorMessageByQuery := map[string]string{
+			"SELECT * FROM test_table FOR UPDATE":                 "unsupported feature: SELECT ... FOR UPDATE/SHARE",
+			"SELECT * INTO new_table FROM test_table":             "unsupported feature: SELECT INTO",
+			"SELECT xmlelement(name foo)":                         "unsupported feature: XML functions",
+			"SELECT id FROM test_table WHERE text_column IS JSON": "unsupported feature: IS JSON",
+			"INSERT INTO test_table (id) VALUES (1)":              "unsupported feature: INSERT",
+			"CREATE TABLE new_table AS SELECT * FROM test_table":  "unsupported feature: CREATE TABLE AS",
+		}
+
+		for query, expectedErrorMessage := range errorMessageByQuery {
+			t.Run(query, func(t *testing.T) {
+				queryHandler := initQueryHandler()
+
+				_, err := queryHandler.HandleQuery(query)
+
+				if err == nil || err.Error() != expectedErrorMessage {
+					t.Errorf("Expected the error to be '%v', got %v", expectedErrorMessage, err)
+				}
+			})
+		}
+	})
+
+	t.Run("Returns a DuckDB error for an unsupported feature in passthrough mode", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		queryHandler.config.UnsupportedQueries = UNSUPPORTED_QUERIES_PASSTHROUGH
+
+		_, err := queryHandler.HandleQuery("SELECT * FROM test_table FOR UPDATE")
+
+		if err == nil || err.Error() != "Parser Error: SELECT locking clause is not supported!" {
+			t.Errorf("Expected a DuckDB parser error, got %v", err)
+		}
+	})
+}
+
+func TestHandleParseQuery(t *testing.T) {
+	t.Run("Handles PARSE extended query", func(t *testing.T) {
+		query := "SELECT usename, passwd FROM pg_shadow WHERE usename=$1"
+		queryHandler := initQueryHandler()
+		message := &pgproto3.Parse{Query: query}
+
+		messages, preparedStatement, err := queryHandler.HandleParseQuery(message)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.ParseComplete{},
+		})
+
+		remappedQuery := "SELECT usename, passwd FROM (VALUES ('bemidb', '10', 'FALSE', 'FALSE', 'TRUE', 'FALSE', 'bemidb-encrypted', 'NULL', 'NULL')) pg_shadow(usename, usesysid, usecreatedb, usesuper, userepl, usebypassrls, passwd, valuntil, useconfig) WHERE usename = $1"
+		if preparedStatement.Query != remappedQuery {
+			t.Errorf("Expected the prepared statement query to be %v, got %v", remappedQuery, preparedStatement.Query)
+		}
+		if preparedStatement.Statement == nil {
+			t.Errorf("Expected the prepared statement to have a statement")
+		}
+	})
+}
+
+func TestHandleBindQuery(t *testing.T) {
+	t.Run("Handles BIND extended query with text format parameter", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		query := "SELECT usename, passwd FROM pg_shadow WHERE usename=$1"
+		parseMessage := &pgproto3.Parse{Query: query}
+		_, preparedStatement, err := queryHandler.HandleParseQuery(parseMessage)
+		testNoError(t, err)
+
+		bindMessage := &pgproto3.Bind{
+			Parameters:           [][]byte{[]byte("bemidb")},
+			ParameterFormatCodes: []int16{0}, // Text format
+		}
+		messages, preparedStatement, err := queryHandler.HandleBindQuery(bindMessage, preparedStatement)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.BindComplete{},
+		})
+		if len(preparedStatement.Variables) != 1 {
+			t.Errorf("Expected the prepared statement to have 1 variable, got %v", len(preparedStatement.Variables))
+		}
+		if preparedStatement.Variables[0] != "bemidb" {
+			t.Errorf("Expected the prepared statement variable to be 'bemidb', got %v", preparedStatement.Variables[0])
+		}
+	})
+
+	t.Run("Handles BIND extended query with binary format parameter", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		query := "SELECT c.oid FROM pg_catalog.pg_class c WHERE c.relnamespace = $1"
+		parseMessage := &pgproto3.Parse{Query: query}
+		_, preparedStatement, err := queryHandler.HandleParseQuery(parseMessage)
+		testNoError(t, err)
+
+		paramValue := int64(2200)
+		paramBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(paramBytes, uint64(paramValue))
+
+		bindMessage := &pgproto3.Bind{
+			Parameters:           [][]byte{paramBytes},
+			ParameterFormatCodes: []int16{1}, // Binary format
+		}
+		messages, preparedStatement, err := queryHandler.HandleBindQuery(bindMessage, preparedStatement)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.BindComplete{},
+		})
+		if len(preparedStatement.Variables) != 1 {
+			t.Errorf("Expected the prepared statement to have 1 variable, got %v", len(preparedStatement.Variables))
+		}
+		if preparedStatement.Variables[0] != paramValue {
+			t.Errorf("Expected the prepared statement variable to be %v, got %v", paramValue, preparedStatement.Variables[0])
+		}
+	})
+}
+
+func TestHandleDescribeQuery(t *testing.T) {
+	t.Run("Handles DESCRIBE extended query", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		query := "SELECT usename, passwd FROM pg_shadow WHERE usename=$1"
+		parseMessage := &pgproto3.Parse{Query: query}
+		messages, preparedStatement, err := queryHandler.HandleParseQuery(parseMessage)
+		bindMessage := &pgproto3.Bind{Parameters: [][]byte{[]byte("bemidb")}}
+		messages, preparedStatement, err = queryHandler.HandleBindQuery(bindMessage, preparedStatement)
+		message := &pgproto3.Describe{ObjectType: 'P'}
+
+		messages, preparedStatement, err = queryHandler.HandleDescribeQuery(message, preparedStatement)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+		})
+		testRowDescription(t, messages[0], []string{"usename", "passwd"})
+		if preparedStatement.Rows == nil {
+			t.Errorf("Expected the prepared statement to have rows")
+		}
+	})
+}
+
+func TestHandleExecuteQuery(t *testing.T) {
+	t.Run("Handles EXECUTE extended query", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		query := "SELECT usename, passwd FROM pg_shadow WHERE usename=$1"
+		parseMessage := &pgproto3.Parse{Query: query}
+		messages, preparedStatement, err := queryHandler.HandleParseQuery(parseMessage)
+		bindMessage := &pgproto3.Bind{Parameters: [][]byte{[]byte("bemidb")}}
+		messages, preparedStatement, err = queryHandler.HandleBindQuery(bindMessage, preparedStatement)
+		describeMessage := &pgproto3.Describe{ObjectType: 'P'}
+		messages, preparedStatement, err = queryHandler.HandleDescribeQuery(describeMessage, preparedStatement)
+		message := &pgproto3.Execute{}
+
+		messages, err = queryHandler.HandleExecuteQuery(message, preparedStatement)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[0], []string{"bemidb", "bemidb-encrypted"})
+	})
+
+	t.Run("Returns all rows for a NULL-bound LIMIT parameter", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		_, preparedStatement, err := queryHandler.HandleParseQuery(&pgproto3.Parse{Query: "SELECT int4_column FROM public.test_table ORDER BY int4_column LIMIT $1 OFFSET $2"})
+		testNoError(t, err)
+		_, preparedStatement, err = queryHandler.HandleBindQuery(&pgproto3.Bind{Parameters: [][]byte{nil, []byte("0")}}, preparedStatement)
+		testNoError(t, err)
+
+		messages, err := queryHandler.HandleExecuteQuery(&pgproto3.Execute{}, preparedStatement)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.DataRow{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+	})
+
+	t.Run("Returns a numeric field overflow for a sum beyond 38 digits", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		schemaTable := testWriteNumericOverflowTable(queryHandler.config, "38", "0", "99999999999999999999999999999999999999")
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+		_, preparedStatement, err := queryHandler.HandleParseQuery(&pgproto3.Parse{Query: "SELECT SUM(amount) FROM public.test_numeric_overflow_table"})
+		testNoError(t, err)
+		_, preparedStatement, err = queryHandler.HandleBindQuery(&pgproto3.Bind{}, preparedStatement)
+		testNoError(t, err)
+
+		_, err = queryHandler.HandleExecuteQuery(&pgproto3.Execute{}, preparedStatement)
+
+		if err != ERROR_NUMERIC_AGGREGATE_OVERFLOW {
+			t.Errorf("Expected a numeric field overflow, got %v", err)
+		}
+	})
+
+	t.Run("Returns an approximate sum beyond 38 digits with the DOUBLE numeric aggregate overflow", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		queryHandler.config.NumericAggregateOverflow = NUMERIC_AGGREGATE_OVERFLOW_DOUBLE
+		schemaTable := testWriteNumericOverflowTable(queryHandler.config, "38", "0", "99999999999999999999999999999999999999")
+		defer NewIcebergWriter(queryHandler.config).DeleteSchemaTable(schemaTable)
+		_, preparedStatement, err := queryHandler.HandleParseQuery(&pgproto3.Parse{Query: "SELECT SUM(amount) FROM public.test_numeric_overflow_table"})
+		testNoError(t, err)
+		_, preparedStatement, err = queryHandler.HandleBindQuery(&pgproto3.Bind{}, preparedStatement)
+		testNoError(t, err)
+
+		messages, err := queryHandler.HandleExecuteQuery(&pgproto3.Execute{}, preparedStatement)
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[0], []string{"2e+38"})
+	})
+}
+
+func TestHandleMultipleQueries(t *testing.T) {
+	t.Run("Handles multiple SET statements", func(t *testing.T) {
+		query := `SET client_encoding TO 'UTF8';
+SET client_min_messages TO 'warning';
+SET standard_conforming_strings = on;`
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery(query)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.CommandComplete{},
+		})
+	})
+
+	t.Run("Handles mixed SET and SELECT statements", func(t *testing.T) {
+		query := `SET client_encoding TO 'UTF8';
+SELECT passwd FROM pg_shadow WHERE usename='bemidb';`
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery(query)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"bemidb-encrypted"})
+	})
+
+	t.Run("Handles multiple SELECT statements", func(t *testing.T) {
+		query := `SELECT passwd FROM pg_shadow WHERE usename='bemidb';
+SELECT passwd FROM pg_shadow WHERE usename='bemidb';`
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery(query)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[1], []string{"bemidb-encrypted"})
+	})
+
+	t.Run("Handles error in any of multiple statements", func(t *testing.T) {
+		query := `SET client_encoding TO 'UTF8';
+SELECT * FROM non_existent_table;
+SET standard_conforming_strings = on;`
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery(query)
+
+		if err == nil {
+			t.Error("Expected an error for non-existent table, got nil")
+			return
+		}
+
+		if !strings.Contains(err.Error(), "non_existent_table") {
+			t.Errorf("Expected error message to contain 'non_existent_table', got: %s", err.Error())
+		}
+	})
+}
+
+func TestHandleCopyQuery(t *testing.T) {
+	t.Run("Handles COPY TO STDOUT in text format", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("COPY (SELECT 1 AS id, 'a\tb' AS name, NULL AS empty) TO STDOUT")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.CopyOutResponse{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyDone{},
+			&pgproto3.CommandComplete{},
+		})
+		testCopyData(t, messages[1], "1\ta\\tb\t\\N\n")
+		testCommandComplete(t, messages[3], "COPY 1")
+	})
+
+	t.Run("Handles COPY TO STDOUT in csv format with header", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("COPY (SELECT 1 AS id, 'a,b' AS name, '' AS empty, NULL AS null) TO STDOUT WITH (FORMAT csv, HEADER)")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.CopyOutResponse{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyDone{},
+			&pgproto3.CommandComplete{},
+		})
+		testCopyData(t, messages[1], "id,name,empty,null\n")
+		testCopyData(t, messages[2], "1,\"a,b\",\"\",\n")
+	})
+
+	t.Run("Handles COPY TO STDOUT in csv format with a custom NULL string and quoting", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery(`COPY (SELECT 1 AS id, 'a;''b' AS name, '' AS empty, NULL AS null, 'NULL' AS null_string) TO STDOUT WITH (FORMAT csv, HEADER, DELIMITER ';', NULL 'NULL', QUOTE '''', ESCAPE '\', FORCE_QUOTE (id))`)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.CopyOutResponse{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyDone{},
+			&pgproto3.CommandComplete{},
+		})
+		testCopyData(t, messages[1], "id;name;empty;null;null_string\n")
+		testCopyData(t, messages[2], "'1';'a;\\'b';;NULL;'NULL'\n")
+	})
+
+	t.Run("Handles COPY TO STDOUT in csv format with FORCE QUOTE *", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("COPY (SELECT 1 AS id, 'a\"b' AS name, NULL AS null) TO STDOUT WITH CSV FORCE QUOTE *")
+
+		testNoError(t, err)
+		testCopyData(t, messages[1], "\"1\",\"a\"\"b\",\n")
+	})
+
+	t.Run("Handles COPY TO STDOUT in text format with a custom delimiter and NULL string", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("COPY (SELECT 1 AS id, 'a|b' AS name, NULL AS null) TO STDOUT WITH (DELIMITER '|', NULL '')")
+
+		testNoError(t, err)
+		testCopyData(t, messages[1], "1|a\\|b|\n")
+	})
+
+	t.Run("Returns an error for invalid COPY option combinations", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for query, expectedError := range map[string]string{
+			"COPY (SELECT 1) TO STDOUT WITH (QUOTE '\"')":                             "COPY quote available only in CSV mode",
+			"COPY (SELECT 1) TO STDOUT WITH (FORMAT binary, NULL '')":                 "cannot specify NULL in BINARY mode",
+			"COPY (SELECT 1) TO STDOUT WITH (FORMAT csv, DELIMITER ';;')":             "COPY delimiter must be a single one-byte character",
+			"COPY (SELECT 1) TO STDOUT WITH (FORMAT csv, DELIMITER '''', QUOTE '''')": "COPY delimiter and quote must be different",
+			"COPY (SELECT 1) TO STDOUT WITH (FORMAT csv, NULL 'a,b')":                 "COPY delimiter must not appear in the NULL specification",
+			"COPY (SELECT 1) TO STDOUT WITH (FORMAT csv, NULL '\"')":                  "CSV quote character must not appear in the NULL specification",
+			"COPY (SELECT 1) TO STDOUT WITH (NULL 'a', NULL 'b')":                     "conflicting or redundant options",
+			"COPY (SELECT 1 AS id) TO STDOUT WITH (FORMAT csv, FORCE_QUOTE (name))":   "FORCE_QUOTE column \"name\" not referenced by COPY",
+		} {
+			_, err := queryHandler.HandleQuery(query)
+
+			if err == nil || err.Error() != expectedError {
+				t.Errorf("Expected the error %q for %s, got: %v", expectedError, query, err)
+			}
+		}
+	})
+
+	t.Run("Handles COPY of an Iceberg table", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("COPY public.test_table (int4_column, text_column) TO STDOUT WITH CSV")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.CopyOutResponse{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyData{},
+			&pgproto3.CopyDone{},
+			&pgproto3.CommandComplete{},
+		})
+		testCommandComplete(t, messages[4], "COPY 2")
+	})
+
+	t.Run("Returns an error for COPY FROM", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("COPY public.test_table FROM STDIN")
+
+		if err == nil || err.Error() != "COPY FROM is not supported" {
+			t.Errorf("Expected a COPY FROM error, got: %v", err)
+		}
+	})
+
+	t.Run("Round-trips COPY TO STDOUT in binary format via pgx", func(t *testing.T) {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, startTestServer(t))
+		testNoError(t, err)
+		defer conn.Close(ctx)
+
+		buffer := &bytes.Buffer{}
+		result, err := conn.PgConn().CopyTo(
+			ctx,
+			buffer,
+			"COPY (SELECT bool_column, int4_column, int8_column, float8_column, numeric_column, date_column, timestamp_column, text_column FROM public.test_table ORDER BY int4_column NULLS LAST) TO STDOUT WITH (FORMAT binary)",
+		)
+		testNoError(t, err)
+		if result.RowsAffected() != 2 {
+			t.Errorf("Expected 2 copied rows, got %v", result.RowsAffected())
+		}
+
+		rows := testDecodeBinaryCopy(t, buffer.Bytes())
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 rows, got %v", len(rows))
+		}
+
+		typeMap := pgtype.NewMap()
+		var boolValue bool
+		var int4Value int32
+		var int8Value int64
+		var float8Value float64
+		var numericValue float64
+		var dateValue time.Time
+		var timestampValue time.Time
+		var textValue string
+		for i, dst := range []interface{}{&boolValue, &int4Value, &int8Value, &float8Value, &numericValue, &dateValue, &timestampValue, &textValue} {
+			oid := []uint32{pgtype.BoolOID, pgtype.Int4OID, pgtype.Int8OID, pgtype.Float8OID, pgtype.NumericOID, pgtype.DateOID, pgtype.TimestampOID, pgtype.TextOID}[i]
+			err := typeMap.Scan(oid, pgtype.BinaryFormatCode, rows[0][i], dst)
+			testNoError(t, err)
+		}
+
+		if !boolValue || int4Value != 2147483647 || int8Value != 9223372036854775807 || float8Value != 3.141592653589793 || numericValue != 12345.67 {
+			t.Errorf("Unexpected numeric values: %v %v %v %v %v", boolValue, int4Value, int8Value, float8Value, numericValue)
+		}
+		if dateValue.Format("2006-01-02") != "2021-01-01" || timestampValue.Format("2006-01-02 15:04:05.999999") != "2024-01-01 12:00:00.123456" {
+			t.Errorf("Unexpected time values: %v %v", dateValue, timestampValue)
+		}
+		if textValue != "text" {
+			t.Errorf("Unexpected text value: %v", textValue)
+		}
+		if rows[1][1] != nil {
+			t.Errorf("Expected NULL int4_column in the second row, got %v", rows[1][1])
+		}
+	})
+
+	t.Run("Round-trips a uuid column in COPY TO STDOUT binary format via pgx", func(t *testing.T) {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, startTestServer(t))
+		testNoError(t, err)
+		defer conn.Close(ctx)
+
+		buffer := &bytes.Buffer{}
+		result, err := conn.PgConn().CopyTo(
+			ctx,
+			buffer,
+			"COPY (SELECT '58a7c845-af77-44b2-8664-7ca613d92f04'::uuid AS uuid_column UNION ALL SELECT NULL::uuid ORDER BY uuid_column NULLS LAST) TO STDOUT WITH (FORMAT binary)",
+		)
+		testNoError(t, err)
+		if result.RowsAffected() != 2 {
+			t.Errorf("Expected 2 copied rows, got %v", result.RowsAffected())
+		}
+
+		rows := testDecodeBinaryCopy(t, buffer.Bytes())
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 rows, got %v", len(rows))
+		}
+
+		uuidValue, err := uuid.FromBytes(rows[0][0])
+		testNoError(t, err)
+		if uuidValue.String() != "58a7c845-af77-44b2-8664-7ca613d92f04" {
+			t.Errorf("Expected the uuid to be 58a7c845-af77-44b2-8664-7ca613d92f04, got %v", uuidValue.String())
+		}
+		if rows[1][0] != nil {
+			t.Errorf("Expected NULL uuid_column in the second row, got %v", rows[1][0])
+		}
+	})
+}
+
+func TestHandleExplainQuery(t *testing.T) {
+	t.Run("Returns EXPLAIN (FORMAT JSON) as a single row of parseable JSON", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("EXPLAIN (FORMAT JSON) SELECT int4_column FROM test_table WHERE int4_column > 1 ORDER BY int4_column")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"QUERY PLAN"})
+
+		var plans []map[string]interface{}
+		err = json.Unmarshal(messages[1].(*pgproto3.DataRow).Values[0], &plans)
+		if err != nil {
+			t.Fatalf("Expected the plan to be valid JSON, got %v", err)
+		}
+		plan := plans[0]["Plan"].(map[string]interface{})
+		if plan["Node Type"] != "ORDER_BY" {
+			t.Errorf("Expected the root node to be ORDER_BY, got %v", plan["Node Type"])
+		}
+		if len(plan["Plans"].([]interface{})) == 0 {
+			t.Errorf("Expected the root node to have child plans, got %v", plan)
+		}
+	})
+
+	t.Run("Returns EXPLAIN ANALYZE as one text row per line", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("EXPLAIN ANALYZE SELECT int4_column FROM test_table")
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], []string{"QUERY PLAN"})
+		lastRow := string(messages[len(messages)-2].(*pgproto3.DataRow).Values[0])
+		if !strings.HasPrefix(lastRow, "Execution Time: ") {
+			t.Errorf("Expected the last row to be the execution time, got %v", lastRow)
+		}
+		testCommandComplete(t, messages[len(messages)-1], "EXPLAIN")
+	})
+
+	t.Run("Returns EXPLAIN (FORMAT XML) with the Postgres namespace", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("EXPLAIN (FORMAT XML) SELECT 1")
+
+		testNoError(t, err)
+		plan := string(messages[1].(*pgproto3.DataRow).Values[0])
+		if !strings.HasPrefix(plan, `<explain xmlns="http://www.postgresql.org/2009/explain">`) || !strings.Contains(plan, "<Node-Type>PROJECTION</Node-Type>") {
+			t.Errorf("Unexpected XML plan: %v", plan)
+		}
+	})
+
+	t.Run("Returns the remapped DuckDB query with EXPLAIN (REMAP) without running it", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		messages, err := queryHandler.HandleQuery("EXPLAIN (REMAP) SELECT int4_column FROM public.test_table WHERE int4_column > 1")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"QUERY PLAN"})
+		remappedQuery := string(messages[1].(*pgproto3.DataRow).Values[0])
+		if !strings.Contains(remappedQuery, "FROM iceberg_scan(") || !strings.HasSuffix(remappedQuery, ") test_table WHERE int4_column > 1") {
+			t.Errorf("Expected the remapped query to read the table with iceberg_scan, got %v", remappedQuery)
+		}
+		testCommandComplete(t, messages[2], "EXPLAIN")
+
+		_, err = queryHandler.HandleQuery("EXPLAIN (REMAP, ANALYZE) SELECT 1")
+
+		if err == nil || err.Error() != "EXPLAIN options REMAP and ANALYZE cannot be used together" {
+			t.Errorf("Expected an error for REMAP with ANALYZE, got %v", err)
+		}
+	})
+
+	t.Run("Returns an error for an unrecognized EXPLAIN option", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("EXPLAIN (FORMAT CSV) SELECT 1")
+
+		if err == nil || err.Error() != `unrecognized value for EXPLAIN option "format": "csv"` {
+			t.Errorf("Expected an unrecognized format error, got %v", err)
+		}
+	})
+}
+
+type testInt96ParquetRow struct {
+	Id        int32  `parquet:"name=id, type=INT32"`
+	CreatedAt string `parquet:"name=created_at, type=INT96"`
+}
+
+func TestHandlePassthroughQuery(t *testing.T) {
+	t.Run("Returns live data of a passthrough table from the source database", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+		queryHandler.config.Pg.PassthroughTables = NewSet([]string{"public.test_reference_table"})
+
+		messages, err := queryHandler.HandleQuery("SELECT name FROM test_reference_table WHERE id = 1")
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.RowDescription{},
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testRowDescription(t, messages[0], []string{"name"})
+		testDataRowValues(t, messages[1], []string{"live"})
+		testCommandComplete(t, messages[2], "SELECT 1")
+		sourceServer.testQueries(t, []string{"BEGIN READ ONLY", "SELECT name FROM test_reference_table WHERE id = 1"})
+	})
+
+	t.Run("Reads Iceberg tables via DuckDB", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+		queryHandler.config.Pg.PassthroughTables = NewSet([]string{"public.test_reference_table"})
+
+		messages, err := queryHandler.HandleQuery("SELECT COUNT(*) FROM public.test_table")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"2"})
+		sourceServer.testQueries(t, nil)
+	})
+
+	t.Run("Returns live data of an unsynced table with passthrough of unsynced tables", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+
+		_, err := queryHandler.HandleQuery("SELECT name FROM public.test_unsynced_table")
+
+		if err == nil || !strings.Contains(err.Error(), "test_unsynced_table does not exist") {
+			t.Errorf("Expected a table does not exist error, got: %v", err)
+		}
+
+		queryHandler.config.Pg.PassthroughUnsyncedTables = true
+
+		messages, err := queryHandler.HandleQuery("SELECT name FROM public.test_unsynced_table")
+
+		testNoError(t, err)
+		testDataRowValues(t, messages[1], []string{"live"})
+		sourceServer.testQueries(t, []string{"BEGIN READ ONLY", "SELECT name FROM public.test_unsynced_table"})
+	})
+
+	t.Run("Doesn't pass through unsynced tables excluded from the sync", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+		queryHandler.config.Pg.PassthroughUnsyncedTables = true
+		queryHandler.config.Pg.ExcludeTables = NewSet([]string{"public.test_unsynced_table"})
+		defer func() { queryHandler.config.Pg.ExcludeTables = nil }()
+
+		_, err := queryHandler.HandleQuery("SELECT name FROM public.test_unsynced_table")
+
+		if err == nil || !strings.Contains(err.Error(), "test_unsynced_table does not exist") {
+			t.Errorf("Expected a table does not exist error, got: %v", err)
+		}
+		sourceServer.testQueries(t, nil)
+	})
+
+	t.Run("Returns an error of the remapped query without running it on the source database", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+		queryHandler.config.Pg.PassthroughTables = NewSet([]string{"public.test_reference_table"})
+
+		_, err := queryHandler.HandleQuery("SELECT name FROM test_reference_table LIMIT -1")
+
+		if err == nil || err.Error() != "LIMIT must not be negative" {
+			t.Errorf("Expected a negative LIMIT error, got: %v", err)
+		}
+		sourceServer.testQueries(t, nil)
+	})
+
+	t.Run("Returns the error of the source database", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+		queryHandler.config.Pg.PassthroughUnsyncedTables = true
+
+		_, err := queryHandler.HandleQuery("SELECT name FROM public.test_missing_table")
+
+		if err == nil || !strings.Contains(err.Error(), `relation "public.test_missing_table" does not exist`) {
+			t.Errorf("Expected the source database error, got: %v", err)
+		}
+	})
+
+	t.Run("Runs an extended query with bound variables on the source database", func(t *testing.T) {
+		sourceServer := startTestPgSourceServer(t)
+		queryHandler := initQueryHandler()
+		queryHandler.config.Pg.DatabaseUrl = sourceServer.databaseUrl
+		queryHandler.config.Pg.PassthroughTables = NewSet([]string{"public.test_reference_table"})
+		_, preparedStatement, err := queryHandler.HandleParseQuery(&pgproto3.Parse{Query: "SELECT name FROM public.test_reference_table WHERE id = $1"})
+		testNoError(t, err)
+		_, preparedStatement, err = queryHandler.HandleBindQuery(&pgproto3.Bind{Parameters: [][]byte{[]byte("1")}}, preparedStatement)
+		testNoError(t, err)
+
+		messages, preparedStatement, err := queryHandler.HandleDescribeQuery(&pgproto3.Describe{ObjectType: 'P'}, preparedStatement)
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], []string{"name"})
+
+		messages, err = queryHandler.HandleExecuteQuery(&pgproto3.Execute{}, preparedStatement)
+
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{&MessageStream{}})
+		messages, err = ReadMessageStreams(messages)
+		testNoError(t, err)
+		testMessageTypes(t, messages, []pgproto3.Message{
+			&pgproto3.DataRow{},
+			&pgproto3.CommandComplete{},
+		})
+		testDataRowValues(t, messages[0], []string{"live"})
+		sourceServer.testQueries(t, []string{"BEGIN READ ONLY", "SELECT name FROM public.test_reference_table WHERE id = $1 [1]"})
+	})
+}
+
+func testWriteInt96ParquetFile(t *testing.T, filePath string, rows []testInt96ParquetRow) {
+	fileWriter, err := local.NewLocalFileWriter(filePath)
+	testNoError(t, err)
+	parquetWriter, err := writer.NewParquetWriter(fileWriter, new(testInt96ParquetRow), 1)
+	testNoError(t, err)
+	for _, row := range rows {
+		testNoError(t, parquetWriter.Write(row))
+	}
+	testNoError(t, parquetWriter.WriteStop())
+	testNoError(t, fileWriter.Close())
+}
+
+// Two rows with the same amount, so that their sum has one more digit than the amount
+func testWriteNumericOverflowTable(config *Config, precision string, scale string, amount string) IcebergSchemaTable {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_numeric_overflow_table"}
+	testWriteCatalogTable(config, schemaTable, []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", NumericScale: "0", Namespace: "pg_catalog"},
+		{ColumnName: "amount", DataType: "numeric", UdtName: "numeric", IsNullable: "YES", OrdinalPosition: "2", NumericPrecision: precision, NumericScale: scale, Namespace: "pg_catalog"},
+	}, [][]string{{"1", amount}, {"2", amount}})
+	return schemaTable
+}
+
+// Scores of two categories, with a tie for the top score of "a"
+func testWriteTopNTable(config *Config) IcebergSchemaTable {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_top_n_table"}
+	testWriteCatalogTable(config, schemaTable, []PgSchemaColumn{
+		{ColumnName: "category", DataType: "text", UdtName: "text", IsNullable: "NO", OrdinalPosition: "1", Namespace: "pg_catalog"},
+		{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "NO", OrdinalPosition: "2", Namespace: "pg_catalog"},
+		{ColumnName: "score", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "3", NumericPrecision: "32", NumericScale: "0", Namespace: "pg_catalog"},
+	}, [][]string{{"a", "a1", "40"}, {"a", "a2", "50"}, {"a", "a3", "50"}, {"a", "a4", "20"}, {"b", "b1", "70"}, {"b", "b2", "10"}})
+	return schemaTable
+}
+
+// Columns named after keywords reserved by Postgres or only by DuckDB
+func testWriteKeywordColumnsTable(config *Config) IcebergSchemaTable {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_keyword_columns_table"}
+	testWriteCatalogTable(config, schemaTable, []PgSchemaColumn{
+		{ColumnName: "order", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", NumericScale: "0", Namespace: "pg_catalog"},
+		{ColumnName: "group", DataType: "text", UdtName: "text", IsNullable: "NO", OrdinalPosition: "2", Namespace: "pg_catalog"},
+		{ColumnName: "select", DataType: "text", UdtName: "text", IsNullable: "NO", OrdinalPosition: "3", Namespace: "pg_catalog"},
+		{ColumnName: "qualify", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "4", NumericPrecision: "32", NumericScale: "0", Namespace: "pg_catalog"},
+		{ColumnName: "anti", DataType: "text", UdtName: "text", IsNullable: "NO", OrdinalPosition: "5", Namespace: "pg_catalog"},
+		{ColumnName: "show", DataType: "boolean", UdtName: "bool", IsNullable: "NO", OrdinalPosition: "6", Namespace: "pg_catalog"},
+	}, [][]string{{"1", "a", "x", "10", "x", "false"}, {"2", "b", "y", "20", "y", "false"}, {"3", "a", "z", "30", "z", "true"}})
+	return schemaTable
+}
+
+// Source database that returns a single "live" row for any query, or an error for missing tables
+type testPgSourceServer struct {
+	databaseUrl string
+	mutex       sync.Mutex
+	queries     []string // with their bound variables
+}
+
+func startTestPgSourceServer(t *testing.T) *testPgSourceServer {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	testNoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	sourceServer := &testPgSourceServer{databaseUrl: "postgres://user:pass@" + listener.Addr().String() + "/db?sslmode=disable"}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sourceServer.serve(conn)
+		}
+	}()
+
+	return sourceServer
+}
+
+func (sourceServer *testPgSourceServer) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if backend.Flush() != nil {
+		return
+	}
+
+	var query string
+	failed := false
+	for {
+		message, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.Query:
+			sourceServer.mutex.Lock()
+			sourceServer.queries = append(sourceServer.queries, message.String)
+			sourceServer.mutex.Unlock()
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("BEGIN")})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'T'})
+		case *pgproto3.Parse:
+			query = message.Query
+			failed = strings.Contains(query, "test_missing_table")
+			if failed {
+				backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: `relation "public.test_missing_table" does not exist`})
+			} else {
+				backend.Send(&pgproto3.ParseComplete{})
+			}
+		case *pgproto3.Bind:
+			if failed {
+				continue
+			}
+			sourceServer.mutex.Lock()
+			if len(message.Parameters) > 0 {
+				sourceServer.queries = append(sourceServer.queries, fmt.Sprintf("%s %s", query, message.Parameters))
+			} else {
+				sourceServer.queries = append(sourceServer.queries, query)
+			}
+			sourceServer.mutex.Unlock()
+			backend.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Describe:
+			if failed {
+				continue
+			}
+			backend.Send(&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("name"), DataTypeOID: pgtype.TextOID, DataTypeSize: -1, TypeModifier: -1}}})
+		case *pgproto3.Execute:
+			if failed {
+				continue
+			}
+			backend.Send(&pgproto3.DataRow{Values: [][]byte{[]byte("live")}})
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")})
+		case *pgproto3.Sync:
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+		case *pgproto3.Terminate:
+			return
+		}
+		if backend.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (sourceServer *testPgSourceServer) testQueries(t *testing.T, expectedQueries []string) {
+	sourceServer.mutex.Lock()
+	defer sourceServer.mutex.Unlock()
+
+	if !reflect.DeepEqual(sourceServer.queries, expectedQueries) {
+		t.Errorf("Expected the source database to receive %v, got %v", expectedQueries, sourceServer.queries)
+	}
+}
+
+func initQueryHandler() *QueryHandler {
+	config := loadTestConfig()
+	duckdb := NewDuckdb(config)
+	icebergReader := NewIcebergReader(config)
+	return NewQueryHandler(config, duckdb, icebergReader)
+}
+
+func testNoError(t *testing.T, err error) {
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func testMessageTypes(t *testing.T, messages []pgproto3.Message, expectedTypes []pgproto3.Message) {
+	if len(messages) != len(expectedTypes) {
+		t.Errorf("Expected %v messages, got %v", len(expectedTypes), len(messages))
+	}
+
+	for i, expectedType := range expectedTypes {
+		if reflect.TypeOf(messages[i]) != reflect.TypeOf(expectedType) {
+			t.Errorf("Expected the %v message to be a %v", i, expectedType)
+		}
+	}
+}
+
+func testRowDescription(t *testing.T, rowDescriptionMessage pgproto3.Message, expectedColumnNames []string) {
+	rowDescription := rowDescriptionMessage.(*pgproto3.RowDescription)
+
+	if len(rowDescription.Fields) != len(expectedColumnNames) {
+		t.Errorf("Expected %v row description fields, got %v", len(expectedColumnNames), len(rowDescription.Fields))
+	}
+
+	for i, expectedColumnName := range expectedColumnNames {
+		if string(rowDescription.Fields[i].Name) != expectedColumnName {
+			t.Errorf("Expected the %v row description field to be %v, got %v", i, expectedColumnName, string(rowDescription.Fields[i].Name))
+		}
+	}
+}
+
+func testDataRowValues(t *testing.T, dataRowMessage pgproto3.Message, expectedValues []string) {
+	dataRow := dataRowMessage.(*pgproto3.DataRow)
+
+	if len(dataRow.Values) != len(expectedValues) {
+		t.Errorf("Expected %v data row values, got %v", len(expectedValues), len(dataRow.Values))
+	}
+
+	for i, expectedValue := range expectedValues {
+		if string(dataRow.Values[i]) != expectedValue {
+			t.Errorf("Expected the %v data row value to be %v, got %v", i, expectedValue, string(dataRow.Values[i]))
+		}
+	}
+}
+
+func testDataRowNullValues(t *testing.T, dataRowMessage pgproto3.Message, fromIndex int, toIndex int) {
+	dataRow := dataRowMessage.(*pgproto3.DataRow)
+
+	for i := fromIndex; i <= toIndex; i++ {
+		if dataRow.Values[i] != nil {
+			t.Errorf("Expected the %v data row value to be NULL, got %v", i, string(dataRow.Values[i]))
+		}
+	}
+}
+
+func testCopyData(t *testing.T, copyDataMessage pgproto3.Message, expectedData string) {
+	copyData := copyDataMessage.(*pgproto3.CopyData)
+
+	if string(copyData.Data) != expectedData {
+		t.Errorf("Expected the copy data to be %q, got %q", expectedData, string(copyData.Data))
+	}
+}
+
+func testCommandComplete(t *testing.T, commandCompleteMessage pgproto3.Message, expectedCommandTag string) {
+	commandComplete := commandCompleteMessage.(*pgproto3.CommandComplete)
+
+	if string(commandComplete.CommandTag) != expectedCommandTag {
+		t.Errorf("Expected the command tag to be %v, got %v", expectedCommandTag, string(commandComplete.CommandTag))
+	}
+}
+
+// Parses a binary COPY stream into rows of raw field values
+func testDecodeBinaryCopy(t *testing.T, data []byte) [][][]byte {
+	if !bytes.HasPrefix(data, COPY_BINARY_SIGNATURE) {
+		t.Fatalf("Expected the binary COPY signature, got %q", data)
+	}
+	data = data[len(COPY_BINARY_SIGNATURE)+8:]
+
+	var rows [][][]byte
+	for {
+		fieldCount := int16(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if fieldCount == -1 {
+			break
+		}
+
+		var row [][]byte
+		for i := 0; i < int(fieldCount); i++ {
+			length := int32(binary.BigEndian.Uint32(data))
+			data = data[4:]
+			if length == -1 {
+				row = append(row, nil)
+				continue
+			}
+			row = append(row, data[:length])
+			data = data[length:]
+		}
+		rows = append(rows, row)
+	}
+
+	if len(data) != 0 {
+		t.Errorf("Expected no data after the binary COPY trailer, got %q", data)
+	}
+	return rows
+}
+
+func startTestServer(t *testing.T) string {
+	config := loadTestConfig()
+	queryHandler := initQueryHandler()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testNoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		postgres := NewPostgres(config, &conn)
+		defer postgres.Close()
+		postgres.Run(queryHandler)
+	}()
+
+	return "postgres://" + config.User + "@" + listener.Addr().String() + "/" + config.Database + "?sslmode=disable"
+}
@@ -0,0 +1,108 @@
+package bemidb
+
+import (
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	UNSUPPORTED_QUERIES_ERROR       = "ERROR"
+	UNSUPPORTED_QUERIES_PASSTHROUGH = "PASSTHROUGH"
+)
+
+var UNSUPPORTED_QUERIES_MODES = []string{UNSUPPORTED_QUERIES_ERROR, UNSUPPORTED_QUERIES_PASSTHROUGH}
+
+// Postgres features that parse fine but can't be translated for DuckDB, by parser node type
+var UNSUPPORTED_FEATURE_BY_PG_NODE = map[string]string{
+	"LockingClause":             "SELECT ... FOR UPDATE/SHARE",
+	"IntoClause":                "SELECT INTO",
+	"CurrentOfExpr":             "WHERE CURRENT OF",
+	"XmlExpr":                   "XML functions",
+	"XmlSerialize":              "XMLSERIALIZE",
+	"RangeTableFunc":            "XMLTABLE",
+	"JsonIsPredicate":           "IS JSON",
+	"JsonObjectConstructor":     "JSON_OBJECT",
+	"JsonArrayConstructor":      "JSON_ARRAY",
+	"JsonArrayQueryConstructor": "JSON_ARRAY",
+	"JsonObjectAgg":             "JSON_OBJECTAGG",
+	"JsonArrayAgg":              "JSON_ARRAYAGG",
+}
+
+// Statements that would modify data, which BemiDB only syncs from Postgres
+var UNSUPPORTED_FEATURE_BY_PG_STATEMENT = map[string]string{
+	"InsertStmt":        "INSERT",
+	"UpdateStmt":        "UPDATE",
+	"DeleteStmt":        "DELETE",
+	"MergeStmt":         "MERGE",
+	"TruncateStmt":      "TRUNCATE",
+	"CreateStmt":        "CREATE TABLE",
+	"CreateTableAsStmt": "CREATE TABLE AS",
+	"ViewStmt":          "CREATE VIEW",
+	"IndexStmt":         "CREATE INDEX",
+	"AlterTableStmt":    "ALTER TABLE",
+	"DropStmt":          "DROP",
+}
+
+type UnsupportedFeatureError struct {
+	Feature string
+}
+
+func (err *UnsupportedFeatureError) Error() string {
+	return "unsupported feature: " + err.Feature
+}
+
+// Returns an error naming the first unsupported feature instead of letting DuckDB fail with a parser error
+func (queryHandler *QueryHandler) checkUnsupportedFeatures(queryTree *pgQuery.ParseResult) error {
+	if queryHandler.config.UnsupportedQueries == UNSUPPORTED_QUERIES_PASSTHROUGH {
+		return nil
+	}
+
+	for _, stmt := range queryTree.Stmts {
+		feature := findUnsupportedStatement(stmt.Stmt)
+		if feature == "" {
+			feature = findUnsupportedFeature(stmt.ProtoReflect())
+		}
+
+		if feature != "" {
+			LogWarn(queryHandler.config, "Unsupported feature:", feature)
+			return &UnsupportedFeatureError{Feature: feature}
+		}
+	}
+
+	return nil
+}
+
+func findUnsupportedStatement(node *pgQuery.Node) (feature string) {
+	if node == nil {
+		return ""
+	}
+
+	// Node is a oneof wrapper around the statement
+	node.ProtoReflect().Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		feature = UNSUPPORTED_FEATURE_BY_PG_STATEMENT[string(field.Message().Name())]
+		return false
+	})
+
+	return feature
+}
+
+func findUnsupportedFeature(message protoreflect.Message) (feature string) {
+	if feature, ok := UNSUPPORTED_FEATURE_BY_PG_NODE[string(message.Descriptor().Name())]; ok {
+		return feature
+	}
+
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len() && feature == ""; i++ {
+				feature = findUnsupportedFeature(list.Get(i).Message())
+			}
+		case !field.IsMap() && field.Message() != nil:
+			feature = findUnsupportedFeature(value.Message())
+		}
+		return feature == ""
+	})
+
+	return feature
+}
@@ -0,0 +1,206 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_FUNCTION_ARRAY_POSITION = "array_position"
+	PG_FUNCTION_ARRAY_LENGTH   = "array_length"
+	PG_FUNCTION_CARDINALITY    = "cardinality"
+
+	PG_ARRAY_OPERATOR_CONTAINS     = "@>"
+	PG_ARRAY_OPERATOR_CONTAINED_BY = "<@"
+	PG_ARRAY_OPERATOR_OVERLAPS     = "&&"
+)
+
+// DuckDB functions with the Postgres semantics, keyed by the Postgres function name and the number of arguments.
+// array_position(arr, elem) is the same in DuckDB, it's only matched to keep its column name
+var BEMIDB_ARRAY_FUNCTION_BY_PG_FUNCTION = map[string]map[int]string{
+	PG_FUNCTION_ARRAY_POSITION: {2: "array_position", 3: "bemidb_array_position"},
+	PG_FUNCTION_ARRAY_LENGTH:   {2: "bemidb_array_length"},
+	PG_FUNCTION_CARDINALITY:    {1: "bemidb_cardinality"},
+}
+
+type QueryParserArray struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserArray(config *Config) *QueryParserArray {
+	return &QueryParserArray{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// Array functions anywhere in the statement, including subqueries
+func (parser *QueryParserArray) ArrayFunctionCalls(node *pgQuery.Node) (arrayFunctionCalls []*pgQuery.FuncCall) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if functionCall, ok := message.Interface().(*pgQuery.FuncCall); ok && parser.IsArrayFunction(functionCall) {
+			arrayFunctionCalls = append(arrayFunctionCalls, functionCall)
+		}
+	})
+
+	return arrayFunctionCalls
+}
+
+// SELECT array_length(arr, 1) -> SELECT array_length(arr, 1) AS array_length, so that the column keeps its name after remapping
+func (parser *QueryParserArray) SetDefaultTargetNames(node *pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		resTarget, ok := message.Interface().(*pgQuery.ResTarget)
+		if !ok || resTarget.Name != "" || resTarget.Val == nil {
+			return
+		}
+
+		if functionCall := resTarget.Val.GetFuncCall(); functionCall != nil && parser.IsArrayFunction(functionCall) {
+			resTarget.Name = parser.functionName(functionCall)
+		}
+	})
+}
+
+func (parser *QueryParserArray) IsArrayFunction(functionCall *pgQuery.FuncCall) bool {
+	_, ok := BEMIDB_ARRAY_FUNCTION_BY_PG_FUNCTION[parser.functionName(functionCall)][len(functionCall.Args)]
+	return ok
+}
+
+// array_position(arr, elem, start) -> bemidb_array_position(arr, elem, start)
+// array_length(arr, dim) -> bemidb_array_length(arr, dim)
+// cardinality(arr) -> bemidb_cardinality(arr)
+func (parser *QueryParserArray) RemapArrayFunction(functionCall *pgQuery.FuncCall) {
+	bemidbFunctionName := BEMIDB_ARRAY_FUNCTION_BY_PG_FUNCTION[parser.functionName(functionCall)][len(functionCall.Args)]
+	functionCall.Funcname = []*pgQuery.Node{pgQuery.MakeStrNode(bemidbFunctionName)}
+}
+
+// Array operators anywhere in the statement, including subqueries
+func (parser *QueryParserArray) ArrayOperatorNodes(node *pgQuery.Node) (arrayOperatorNodes []*pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if arrayOperatorNode, ok := message.Interface().(*pgQuery.Node); ok && parser.IsArrayOperator(arrayOperatorNode) {
+			arrayOperatorNodes = append(arrayOperatorNodes, arrayOperatorNode)
+		}
+	})
+
+	return arrayOperatorNodes
+}
+
+// a @> b, a <@ b, a && b where a or b is an ARRAY[...] or ARRAY(SELECT ...) expression, a cast to an array type,
+// or an array literal such as '{1,2}'. Range and jsonb operators are remapped before, so other operands are left untouched
+func (parser *QueryParserArray) IsArrayOperator(node *pgQuery.Node) bool {
+	aExpr := node.GetAExpr()
+	if aExpr == nil || aExpr.Kind != pgQuery.A_Expr_Kind_AEXPR_OP || len(aExpr.Name) != 1 || aExpr.Lexpr == nil {
+		return false
+	}
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_ARRAY_OPERATOR_CONTAINS, PG_ARRAY_OPERATOR_CONTAINED_BY, PG_ARRAY_OPERATOR_OVERLAPS:
+		return parser.isArrayOperand(aExpr.Lexpr) || parser.isArrayOperand(aExpr.Rexpr)
+	}
+
+	return false
+}
+
+// a @> b -> bemidb_array_contains(a, b)
+// a <@ b -> bemidb_array_contains(b, a)
+// a && b -> bemidb_array_overlaps(a, b)
+// Array literals become lists, e.g. a @> '{1,2}' -> bemidb_array_contains(a, list_value(1, 2))
+func (parser *QueryParserArray) RemapArrayOperator(node *pgQuery.Node) {
+	aExpr := node.GetAExpr()
+	leftNode := parser.makeListNode(aExpr.Lexpr)
+	rightNode := parser.makeListNode(aExpr.Rexpr)
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_ARRAY_OPERATOR_CONTAINS:
+		node.Node = parser.makeFunctionCallNode("bemidb_array_contains", leftNode, rightNode).Node
+	case PG_ARRAY_OPERATOR_CONTAINED_BY:
+		node.Node = parser.makeFunctionCallNode("bemidb_array_contains", rightNode, leftNode).Node
+	case PG_ARRAY_OPERATOR_OVERLAPS:
+		node.Node = parser.makeFunctionCallNode("bemidb_array_overlaps", leftNode, rightNode).Node
+	}
+}
+
+func (parser *QueryParserArray) isArrayOperand(node *pgQuery.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.GetAArrayExpr() != nil {
+		return true
+	}
+	if subLink := node.GetSubLink(); subLink != nil {
+		return subLink.SubLinkType == pgQuery.SubLinkType_ARRAY_SUBLINK
+	}
+	if typeCast := node.GetTypeCast(); typeCast != nil {
+		return typeCast.TypeName != nil && len(typeCast.TypeName.ArrayBounds) > 0
+	}
+
+	return parser.isArrayLiteral(node)
+}
+
+// '{1,2}', but not '{}' or other valid JSON, which is a jsonb operand
+func (parser *QueryParserArray) isArrayLiteral(node *pgQuery.Node) bool {
+	if node.GetAConst().GetSval() == nil {
+		return false
+	}
+
+	value := strings.TrimSpace(node.GetAConst().GetSval().Sval)
+	return strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") && !json.Valid([]byte(value))
+}
+
+// '{1,NULL}' -> list_value(1, NULL), '{a,"b c"}' -> list_value('a', 'b c'),
+// '{1,2}'::int[] -> list_value('1', '2')::int[], other expressions are lists in DuckDB
+func (parser *QueryParserArray) makeListNode(node *pgQuery.Node) *pgQuery.Node {
+	if typeCast := node.GetTypeCast(); typeCast != nil && typeCast.Arg.GetAConst().GetSval() != nil {
+		typeCast.Arg = parser.makeListValueNode(typeCast.Arg.GetAConst().GetSval().Sval, false)
+		return node
+	}
+	if !parser.isArrayLiteral(node) {
+		return node
+	}
+
+	return parser.makeListValueNode(node.GetAConst().GetSval().Sval, true)
+}
+
+// Unquoted elements are numbers if inferTypes is set, since an untyped literal isn't cast to the other operand's type
+func (parser *QueryParserArray) makeListValueNode(arrayValue string, inferTypes bool) *pgQuery.Node {
+	var elementNodes []*pgQuery.Node
+	arrayValue = strings.TrimSpace(arrayValue)
+	arrayValue = strings.TrimSuffix(strings.TrimPrefix(arrayValue, "{"), "}")
+	if strings.TrimSpace(arrayValue) != "" {
+		for _, element := range strings.Split(arrayValue, ",") {
+			element = strings.TrimSpace(element)
+			if len(element) >= 2 && strings.HasPrefix(element, "\"") && strings.HasSuffix(element, "\"") {
+				elementNodes = append(elementNodes, pgQuery.MakeAConstStrNode(element[1:len(element)-1], 0))
+				continue
+			}
+
+			if strings.EqualFold(element, "NULL") {
+				elementNodes = append(elementNodes, &pgQuery.Node{Node: &pgQuery.Node_AConst{AConst: &pgQuery.A_Const{Isnull: true}}})
+			} else if intValue, err := strconv.ParseInt(element, 10, 64); err == nil && inferTypes {
+				elementNodes = append(elementNodes, pgQuery.MakeAConstIntNode(intValue, 0))
+			} else if _, err := strconv.ParseFloat(element, 64); err == nil && inferTypes {
+				elementNodes = append(elementNodes, &pgQuery.Node{Node: &pgQuery.Node_AConst{AConst: &pgQuery.A_Const{Val: &pgQuery.A_Const_Fval{Fval: &pgQuery.Float{Fval: element}}}}})
+			} else {
+				elementNodes = append(elementNodes, pgQuery.MakeAConstStrNode(element, 0))
+			}
+		}
+	}
+
+	return parser.makeFunctionCallNode("list_value", elementNodes...)
+}
+
+func (parser *QueryParserArray) makeFunctionCallNode(functionName string, args ...*pgQuery.Node) *pgQuery.Node {
+	return pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode(functionName)}, args, 0)
+}
+
+// array_length or pg_catalog.array_length
+func (parser *QueryParserArray) functionName(functionCall *pgQuery.FuncCall) string {
+	if len(functionCall.Funcname) == 0 || len(functionCall.Funcname) > 2 {
+		return ""
+	}
+	if len(functionCall.Funcname) == 2 && functionCall.Funcname[0].GetString_().GetSval() != PG_SCHEMA_PG_CATALOG {
+		return ""
+	}
+
+	return functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().GetSval()
+}
@@ -0,0 +1,84 @@
+package bemidb
+
+import (
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_FUNCTION_ENCODE          = "encode"
+	PG_FUNCTION_DECODE          = "decode"
+	PG_FUNCTION_TO_HEX          = "to_hex"
+	PG_FUNCTION_MD5             = "md5"
+	PG_FUNCTION_GEN_RANDOM_UUID = "gen_random_uuid"
+)
+
+// DuckDB functions with the Postgres semantics, keyed by the Postgres function name and the number of arguments.
+// md5(str) is the same in DuckDB, it's only matched to keep its column name
+var BEMIDB_ENCODING_FUNCTION_BY_PG_FUNCTION = map[string]map[int]string{
+	PG_FUNCTION_ENCODE:          {2: "bemidb_encode"},
+	PG_FUNCTION_DECODE:          {2: "bemidb_decode"},
+	PG_FUNCTION_TO_HEX:          {1: "bemidb_to_hex"},
+	PG_FUNCTION_MD5:             {1: "md5"},
+	PG_FUNCTION_GEN_RANDOM_UUID: {0: "uuid"},
+}
+
+type QueryParserEncoding struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserEncoding(config *Config) *QueryParserEncoding {
+	return &QueryParserEncoding{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// Encoding functions anywhere in the statement, including subqueries
+func (parser *QueryParserEncoding) EncodingFunctionCalls(node *pgQuery.Node) (encodingFunctionCalls []*pgQuery.FuncCall) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if functionCall, ok := message.Interface().(*pgQuery.FuncCall); ok && parser.IsEncodingFunction(functionCall) {
+			encodingFunctionCalls = append(encodingFunctionCalls, functionCall)
+		}
+	})
+
+	return encodingFunctionCalls
+}
+
+// SELECT encode(data, 'hex') -> SELECT encode(data, 'hex') AS encode, so that the column keeps its name after remapping
+func (parser *QueryParserEncoding) SetDefaultTargetNames(node *pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		resTarget, ok := message.Interface().(*pgQuery.ResTarget)
+		if !ok || resTarget.Name != "" || resTarget.Val == nil {
+			return
+		}
+
+		if functionCall := resTarget.Val.GetFuncCall(); functionCall != nil && parser.IsEncodingFunction(functionCall) {
+			resTarget.Name = parser.functionName(functionCall)
+		}
+	})
+}
+
+func (parser *QueryParserEncoding) IsEncodingFunction(functionCall *pgQuery.FuncCall) bool {
+	_, ok := BEMIDB_ENCODING_FUNCTION_BY_PG_FUNCTION[parser.functionName(functionCall)][len(functionCall.Args)]
+	return ok
+}
+
+// encode(data, format) -> bemidb_encode(data, format)
+// decode(str, format) -> bemidb_decode(str, format)
+// to_hex(num) -> bemidb_to_hex(num)
+// gen_random_uuid() -> uuid()
+func (parser *QueryParserEncoding) RemapEncodingFunction(functionCall *pgQuery.FuncCall) {
+	bemidbFunctionName := BEMIDB_ENCODING_FUNCTION_BY_PG_FUNCTION[parser.functionName(functionCall)][len(functionCall.Args)]
+	functionCall.Funcname = []*pgQuery.Node{pgQuery.MakeStrNode(bemidbFunctionName)}
+}
+
+// encode or pg_catalog.encode
+func (parser *QueryParserEncoding) functionName(functionCall *pgQuery.FuncCall) string {
+	if len(functionCall.Funcname) == 0 || len(functionCall.Funcname) > 2 {
+		return ""
+	}
+	if len(functionCall.Funcname) == 2 && functionCall.Funcname[0].GetString_().GetSval() != PG_SCHEMA_PG_CATALOG {
+		return ""
+	}
+
+	return functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().GetSval()
+}
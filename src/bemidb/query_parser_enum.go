@@ -0,0 +1,101 @@
+package bemidb
+
+import (
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	DUCKDB_TYPE_ENUM = "enum"
+)
+
+var PG_ENUM_ORDERING_OPERATORS = NewSet([]string{"<", "<=", ">", ">="})
+
+type QueryParserEnum struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserEnum(config *Config) *QueryParserEnum {
+	return &QueryParserEnum{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// Ordering comparisons anywhere in the statement, including subqueries
+func (parser *QueryParserEnum) ComparisonNodes(node *pgQuery.Node) (comparisonNodes []*pgQuery.A_Expr) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if aExpr, ok := message.Interface().(*pgQuery.A_Expr); ok && parser.IsComparison(aExpr) {
+			comparisonNodes = append(comparisonNodes, aExpr)
+		}
+	})
+
+	return comparisonNodes
+}
+
+// a < b, a <= b, a > b, a >= b, a [NOT] BETWEEN [SYMMETRIC] b AND c
+func (parser *QueryParserEnum) IsComparison(aExpr *pgQuery.A_Expr) bool {
+	switch aExpr.Kind {
+	case pgQuery.A_Expr_Kind_AEXPR_OP:
+		return len(aExpr.Name) == 1 && PG_ENUM_ORDERING_OPERATORS.Contains(aExpr.Name[0].GetString_().Sval)
+	case pgQuery.A_Expr_Kind_AEXPR_BETWEEN, pgQuery.A_Expr_Kind_AEXPR_NOT_BETWEEN, pgQuery.A_Expr_Kind_AEXPR_BETWEEN_SYM, pgQuery.A_Expr_Kind_AEXPR_NOT_BETWEEN_SYM:
+		return aExpr.Rexpr.GetList() != nil
+	}
+	return false
+}
+
+// DuckDB compares an enum with a string as text, so the string is cast to the enum to compare by the declared order:
+// mood < 'happy' -> mood < 'happy'::enum('sad', 'happy')
+// mood BETWEEN 'sad' AND 'happy' -> mood BETWEEN 'sad'::enum('sad', 'happy') AND 'happy'::enum('sad', 'happy')
+func (parser *QueryParserEnum) RemapComparison(aExpr *pgQuery.A_Expr, enumLabelsByColumnName map[string][]string) {
+	if aExpr.Kind != pgQuery.A_Expr_Kind_AEXPR_OP {
+		if enumLabels := parser.columnEnumLabels(aExpr.Lexpr, enumLabelsByColumnName); enumLabels != nil {
+			for i, item := range aExpr.Rexpr.GetList().Items {
+				aExpr.Rexpr.GetList().Items[i] = parser.castStringToEnum(item, enumLabels)
+			}
+		}
+		return
+	}
+
+	if enumLabels := parser.columnEnumLabels(aExpr.Lexpr, enumLabelsByColumnName); enumLabels != nil {
+		aExpr.Rexpr = parser.castStringToEnum(aExpr.Rexpr, enumLabels)
+	} else if enumLabels := parser.columnEnumLabels(aExpr.Rexpr, enumLabelsByColumnName); enumLabels != nil {
+		aExpr.Lexpr = parser.castStringToEnum(aExpr.Lexpr, enumLabels)
+	}
+}
+
+// 'happy'::enum('sad', 'happy')
+func (parser *QueryParserEnum) MakeEnumTypeCastNode(node *pgQuery.Node, enumLabels []string) *pgQuery.Node {
+	var typmods []*pgQuery.Node
+	for _, enumLabel := range enumLabels {
+		typmods = append(typmods, pgQuery.MakeAConstStrNode(enumLabel, 0))
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_TypeCast{
+			TypeCast: &pgQuery.TypeCast{
+				Arg: node,
+				TypeName: &pgQuery.TypeName{
+					Names:   []*pgQuery.Node{pgQuery.MakeStrNode(DUCKDB_TYPE_ENUM)},
+					Typmods: typmods,
+					Typemod: -1,
+				},
+			},
+		},
+	}
+}
+
+func (parser *QueryParserEnum) columnEnumLabels(node *pgQuery.Node, enumLabelsByColumnName map[string][]string) []string {
+	columnRef := node.GetColumnRef()
+	if columnRef == nil || len(columnRef.Fields) == 0 {
+		return nil
+	}
+
+	columnName := columnRef.Fields[len(columnRef.Fields)-1].GetString_().GetSval()
+	return enumLabelsByColumnName[columnName]
+}
+
+func (parser *QueryParserEnum) castStringToEnum(node *pgQuery.Node, enumLabels []string) *pgQuery.Node {
+	if node.GetAConst() == nil || node.GetAConst().GetSval() == nil {
+		return node
+	}
+	return parser.MakeEnumTypeCastNode(node, enumLabels)
+}
@@ -0,0 +1,224 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"strings"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_JSONB_OPERATOR_CONTAINS          = "@>"
+	PG_JSONB_OPERATOR_CONTAINED_BY      = "<@"
+	PG_JSONB_OPERATOR_EXISTS            = "?"
+	PG_JSONB_OPERATOR_EXISTS_ANY        = "?|"
+	PG_JSONB_OPERATOR_EXISTS_ALL        = "?&"
+	PG_JSONB_OPERATOR_EXTRACT_PATH      = "#>"
+	PG_JSONB_OPERATOR_EXTRACT_PATH_TEXT = "#>>"
+	PG_JSONB_OPERATOR_EXTRACT           = "->"
+
+	PG_FUNCTION_JSONB_PATH_QUERY = "jsonb_path_query"
+
+	PG_TYPE_JSONB    = "jsonb"
+	DUCKDB_TYPE_JSON = "json"
+
+	BEMIDB_FUNCTION_JSONB_CONTAINS = "bemidb_jsonb_contains"
+)
+
+var PG_JSON_TYPES = NewSet([]string{"json", "jsonb"})
+
+// jsonpath modes, DuckDB paths are always lax
+var PG_JSONPATH_MODES = []string{"lax ", "strict "}
+
+type QueryParserJsonb struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserJsonb(config *Config) *QueryParserJsonb {
+	return &QueryParserJsonb{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// jsonb operators anywhere in the statement, including subqueries
+func (parser *QueryParserJsonb) JsonbOperatorNodes(node *pgQuery.Node) (jsonbOperatorNodes []*pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if jsonbOperatorNode, ok := message.Interface().(*pgQuery.Node); ok && parser.IsJsonbOperator(jsonbOperatorNode) {
+			jsonbOperatorNodes = append(jsonbOperatorNodes, jsonbOperatorNode)
+		}
+	})
+
+	return jsonbOperatorNodes
+}
+
+// a ? b, a ?| b, a ?& b, a #> b, a #>> b, and a @> b, a <@ b where a or b is a JSON string constant, a cast to json or jsonb,
+// or a JSON value extracted with -> or #>. @> and <@ are also array operators, so other operands are left untouched
+func (parser *QueryParserJsonb) IsJsonbOperator(node *pgQuery.Node) bool {
+	aExpr := node.GetAExpr()
+	if aExpr == nil || aExpr.Kind != pgQuery.A_Expr_Kind_AEXPR_OP || len(aExpr.Name) != 1 || aExpr.Lexpr == nil {
+		return false
+	}
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_JSONB_OPERATOR_EXISTS, PG_JSONB_OPERATOR_EXISTS_ANY, PG_JSONB_OPERATOR_EXISTS_ALL, PG_JSONB_OPERATOR_EXTRACT_PATH, PG_JSONB_OPERATOR_EXTRACT_PATH_TEXT:
+		return true
+	case PG_JSONB_OPERATOR_CONTAINS, PG_JSONB_OPERATOR_CONTAINED_BY:
+		return parser.isJsonbOperand(aExpr.Lexpr) || parser.isJsonbOperand(aExpr.Rexpr)
+	}
+
+	return false
+}
+
+// a @> b -> bemidb_jsonb_contains(a, b)
+// a <@ b -> bemidb_jsonb_contains(b, a)
+// a ? b -> bemidb_jsonb_exists(a, b)
+// a ?| '{b,c}' -> bemidb_jsonb_exists_any(a, list_value('b', 'c'))
+// a ?& '{b,c}' -> bemidb_jsonb_exists_all(a, list_value('b', 'c'))
+// a #> '{b,c}' -> bemidb_jsonb_extract_path(a, list_value('b', 'c'))
+// a #>> '{b,c}' -> bemidb_jsonb_extract_path_text(a, list_value('b', 'c'))
+func (parser *QueryParserJsonb) RemapJsonbOperator(node *pgQuery.Node) {
+	aExpr := node.GetAExpr()
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_JSONB_OPERATOR_CONTAINS:
+		node.Node = parser.makeFunctionCallNode(BEMIDB_FUNCTION_JSONB_CONTAINS, aExpr.Lexpr, aExpr.Rexpr).Node
+	case PG_JSONB_OPERATOR_CONTAINED_BY:
+		node.Node = parser.makeFunctionCallNode(BEMIDB_FUNCTION_JSONB_CONTAINS, aExpr.Rexpr, aExpr.Lexpr).Node
+	case PG_JSONB_OPERATOR_EXISTS:
+		node.Node = parser.makeFunctionCallNode("bemidb_jsonb_exists", aExpr.Lexpr, aExpr.Rexpr).Node
+	case PG_JSONB_OPERATOR_EXISTS_ANY:
+		node.Node = parser.makeFunctionCallNode("bemidb_jsonb_exists_any", aExpr.Lexpr, parser.makeTextListNode(aExpr.Rexpr)).Node
+	case PG_JSONB_OPERATOR_EXISTS_ALL:
+		node.Node = parser.makeFunctionCallNode("bemidb_jsonb_exists_all", aExpr.Lexpr, parser.makeTextListNode(aExpr.Rexpr)).Node
+	case PG_JSONB_OPERATOR_EXTRACT_PATH:
+		node.Node = parser.makeFunctionCallNode("bemidb_jsonb_extract_path", aExpr.Lexpr, parser.makeTextListNode(aExpr.Rexpr)).Node
+	case PG_JSONB_OPERATOR_EXTRACT_PATH_TEXT:
+		node.Node = parser.makeFunctionCallNode("bemidb_jsonb_extract_path_text", aExpr.Lexpr, parser.makeTextListNode(aExpr.Rexpr)).Node
+	}
+}
+
+// Casts to jsonb anywhere in the statement, including subqueries
+func (parser *QueryParserJsonb) JsonbTypeCasts(node *pgQuery.Node) (typeCasts []*pgQuery.TypeCast) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if typeCast, ok := message.Interface().(*pgQuery.TypeCast); ok && parser.IsJsonbTypeCast(typeCast) {
+			typeCasts = append(typeCasts, typeCast)
+		}
+	})
+
+	return typeCasts
+}
+
+// '{"a":1}'::jsonb or '{"a":1}'::pg_catalog.jsonb
+func (parser *QueryParserJsonb) IsJsonbTypeCast(typeCast *pgQuery.TypeCast) bool {
+	if typeCast.TypeName == nil || len(typeCast.TypeName.Names) == 0 || len(typeCast.TypeName.ArrayBounds) > 0 {
+		return false
+	}
+	return typeCast.TypeName.Names[len(typeCast.TypeName.Names)-1].GetString_().GetSval() == PG_TYPE_JSONB
+}
+
+// '{"a":1}'::jsonb -> '{"a":1}'::json, since DuckDB has a single JSON type
+func (parser *QueryParserJsonb) RemapJsonbTypeCast(typeCast *pgQuery.TypeCast) {
+	typeCast.TypeName.Names = []*pgQuery.Node{pgQuery.MakeStrNode(DUCKDB_TYPE_JSON)}
+}
+
+// jsonb_path_query() calls with a constant path anywhere in the statement, including subqueries
+func (parser *QueryParserJsonb) JsonbPathQueryCalls(node *pgQuery.Node) (functionCalls []*pgQuery.FuncCall) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if functionCall, ok := message.Interface().(*pgQuery.FuncCall); ok && parser.IsJsonbPathQuery(functionCall) {
+			functionCalls = append(functionCalls, functionCall)
+		}
+	})
+
+	return functionCalls
+}
+
+// SELECT jsonb_path_query(j, path) -> SELECT jsonb_path_query(j, path) AS jsonb_path_query, so that the column keeps its name after remapping
+func (parser *QueryParserJsonb) SetDefaultTargetNames(node *pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		resTarget, ok := message.Interface().(*pgQuery.ResTarget)
+		if !ok || resTarget.Name != "" || resTarget.Val == nil {
+			return
+		}
+
+		if functionCall := resTarget.Val.GetFuncCall(); functionCall != nil && parser.IsJsonbPathQuery(functionCall) {
+			resTarget.Name = PG_FUNCTION_JSONB_PATH_QUERY
+		}
+	})
+}
+
+// jsonb_path_query(j, '$.a.b')
+func (parser *QueryParserJsonb) IsJsonbPathQuery(functionCall *pgQuery.FuncCall) bool {
+	if len(functionCall.Args) != 2 || functionCall.Args[1].GetAConst().GetSval() == nil {
+		return false
+	}
+
+	functionName := functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().GetSval()
+	return functionName == PG_FUNCTION_JSONB_PATH_QUERY
+}
+
+// jsonb_path_query(j, 'lax $.a.b') -> unnest(bemidb_jsonb_path_query(j, '$.a.b'))
+// jsonb_path_query(j, '$.a[*]') -> unnest(bemidb_jsonb_path_query_wildcard(j, '$.a[*]'))
+func (parser *QueryParserJsonb) RemapJsonbPathQuery(functionCall *pgQuery.FuncCall) {
+	path := strings.TrimSpace(functionCall.Args[1].GetAConst().GetSval().Sval)
+	for _, mode := range PG_JSONPATH_MODES {
+		path = strings.TrimSpace(strings.TrimPrefix(path, mode))
+	}
+
+	bemidbFunctionName := "bemidb_jsonb_path_query"
+	if strings.Contains(path, "*") {
+		bemidbFunctionName = "bemidb_jsonb_path_query_wildcard"
+	}
+
+	functionCall.Funcname = []*pgQuery.Node{pgQuery.MakeStrNode("unnest")}
+	functionCall.Args = []*pgQuery.Node{parser.makeFunctionCallNode(bemidbFunctionName, functionCall.Args[0], pgQuery.MakeAConstStrNode(path, 0))}
+}
+
+func (parser *QueryParserJsonb) isJsonbOperand(node *pgQuery.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.GetAConst() != nil {
+		return node.GetAConst().GetSval() != nil && json.Valid([]byte(node.GetAConst().GetSval().Sval))
+	}
+	if aExpr := node.GetAExpr(); aExpr != nil && len(aExpr.Name) == 1 {
+		operator := aExpr.Name[0].GetString_().GetSval()
+		return operator == PG_JSONB_OPERATOR_EXTRACT || operator == PG_JSONB_OPERATOR_EXTRACT_PATH
+	}
+
+	typeCast := node.GetTypeCast()
+	if typeCast == nil || typeCast.TypeName == nil || len(typeCast.TypeName.Names) == 0 {
+		return false
+	}
+	typeName := typeCast.TypeName.Names[len(typeCast.TypeName.Names)-1].GetString_().GetSval()
+	return PG_JSON_TYPES.Contains(typeName)
+}
+
+// '{a,"b c"}' or '{a,"b c"}'::text[] -> list_value('a', 'b c'), other text[] expressions such as ARRAY['a', 'b c'] are lists in DuckDB
+func (parser *QueryParserJsonb) makeTextListNode(node *pgQuery.Node) *pgQuery.Node {
+	arrayNode := node
+	if typeCast := node.GetTypeCast(); typeCast != nil && typeCast.Arg.GetAConst() != nil {
+		arrayNode = typeCast.Arg
+	}
+	if arrayNode.GetAConst().GetSval() == nil {
+		return node
+	}
+
+	var elementNodes []*pgQuery.Node
+	arrayValue := strings.TrimSpace(arrayNode.GetAConst().GetSval().Sval)
+	arrayValue = strings.TrimSuffix(strings.TrimPrefix(arrayValue, "{"), "}")
+	if strings.TrimSpace(arrayValue) != "" {
+		for _, element := range strings.Split(arrayValue, ",") {
+			element = strings.TrimSpace(element)
+			if len(element) >= 2 && strings.HasPrefix(element, "\"") && strings.HasSuffix(element, "\"") {
+				element = element[1 : len(element)-1]
+			}
+			elementNodes = append(elementNodes, pgQuery.MakeAConstStrNode(element, 0))
+		}
+	}
+
+	return pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode("list_value")}, elementNodes, 0)
+}
+
+func (parser *QueryParserJsonb) makeFunctionCallNode(functionName string, args ...*pgQuery.Node) *pgQuery.Node {
+	return pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode(functionName)}, args, 0)
+}
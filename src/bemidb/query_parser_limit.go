@@ -0,0 +1,71 @@
+package bemidb
+
+import (
+	"errors"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type QueryParserLimit struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserLimit(config *Config) *QueryParserLimit {
+	return &QueryParserLimit{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// SELECT statements with LIMIT or OFFSET anywhere in the statement, including subqueries
+func (parser *QueryParserLimit) LimitedSelectStatements(node *pgQuery.Node) (selectStatements []*pgQuery.SelectStmt) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if selectStatement, ok := message.Interface().(*pgQuery.SelectStmt); ok && (selectStatement.LimitCount != nil || selectStatement.LimitOffset != nil) {
+			selectStatements = append(selectStatements, selectStatement)
+		}
+	})
+
+	return selectStatements
+}
+
+// LIMIT ALL, LIMIT NULL -> (no limit), OFFSET NULL -> (no offset)
+// LIMIT -1 -> error like Postgres, since DuckDB's error doesn't say which clause is negative
+func (parser *QueryParserLimit) RemapLimit(selectStatement *pgQuery.SelectStmt) error {
+	if parser.isNullConstant(selectStatement.LimitCount) {
+		selectStatement.LimitCount = nil
+	} else if parser.isNegativeConstant(selectStatement.LimitCount) {
+		return errors.New("LIMIT must not be negative")
+	}
+
+	if parser.isNullConstant(selectStatement.LimitOffset) {
+		selectStatement.LimitOffset = nil
+	} else if parser.isNegativeConstant(selectStatement.LimitOffset) {
+		return errors.New("OFFSET must not be negative")
+	}
+
+	return nil
+}
+
+// NULL, NULL::int, or ALL which is parsed as NULL
+func (parser *QueryParserLimit) isNullConstant(node *pgQuery.Node) bool {
+	if typeCast := node.GetTypeCast(); typeCast != nil {
+		node = typeCast.Arg
+	}
+	return node.GetAConst() != nil && node.GetAConst().Isnull
+}
+
+func (parser *QueryParserLimit) isNegativeConstant(node *pgQuery.Node) bool {
+	if typeCast := node.GetTypeCast(); typeCast != nil {
+		node = typeCast.Arg
+	}
+	aConst := node.GetAConst()
+	if aConst == nil {
+		return false
+	}
+	if aConst.GetIval() != nil {
+		return aConst.GetIval().Ival < 0
+	}
+	if aConst.GetFval() != nil {
+		return len(aConst.GetFval().Fval) > 0 && aConst.GetFval().Fval[0] == '-'
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package bemidb
+
+import (
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_RANGE_OPERATOR_OVERLAPS     = "&&"
+	PG_RANGE_OPERATOR_CONTAINS     = "@>"
+	PG_RANGE_OPERATOR_CONTAINED_BY = "<@"
+
+	PG_RANGE_DEFAULT_BOUNDS = "[)"
+)
+
+var PG_RANGE_TYPES = NewSet([]string{
+	"int4range",
+	"int8range",
+	"numrange",
+	"tsrange",
+	"tstzrange",
+	"daterange",
+})
+
+// Bounds of range constructors are cast to the element type when compared with an element, e.g. a string literal
+var PG_RANGE_ELEMENT_TYPES = map[string]string{
+	"tsrange":   "timestamp",
+	"tstzrange": "timestamptz",
+	"daterange": "date",
+}
+
+type QueryParserRange struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserRange(config *Config) *QueryParserRange {
+	return &QueryParserRange{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// Range constructors, range type casts and range operators anywhere in the statement, including subqueries
+func (parser *QueryParserRange) RangeNodes(node *pgQuery.Node, rangeColumnNames *Set) (rangeNodes []*pgQuery.Node) {
+	if node == nil {
+		return nil
+	}
+
+	var findRangeNodes func(message protoreflect.Message)
+	findRangeNodes = func(message protoreflect.Message) {
+		if rangeNode, ok := message.Interface().(*pgQuery.Node); ok {
+			if parser.IsRangeOperator(rangeNode, rangeColumnNames) || parser.IsRangeConstructor(rangeNode) || parser.IsRangeTypeCast(rangeNode) {
+				rangeNodes = append(rangeNodes, rangeNode)
+			}
+		}
+
+		message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+			switch {
+			case field.IsList() && field.Message() != nil:
+				list := value.List()
+				for i := 0; i < list.Len(); i++ {
+					findRangeNodes(list.Get(i).Message())
+				}
+			case !field.IsMap() && field.Message() != nil:
+				findRangeNodes(value.Message())
+			}
+			return true
+		})
+	}
+	findRangeNodes(node.ProtoReflect())
+
+	return rangeNodes
+}
+
+// a && b, a @> b, a <@ b where a or b is a range constructor, a range type cast, or a range column.
+// The same operators are used for arrays and JSONB, so other operands are left untouched
+func (parser *QueryParserRange) IsRangeOperator(node *pgQuery.Node, rangeColumnNames *Set) bool {
+	aExpr := node.GetAExpr()
+	if aExpr == nil || aExpr.Kind != pgQuery.A_Expr_Kind_AEXPR_OP || len(aExpr.Name) != 1 {
+		return false
+	}
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_RANGE_OPERATOR_OVERLAPS, PG_RANGE_OPERATOR_CONTAINS, PG_RANGE_OPERATOR_CONTAINED_BY:
+		return parser.isRangeOperand(aExpr.Lexpr, rangeColumnNames) || parser.isRangeOperand(aExpr.Rexpr, rangeColumnNames)
+	}
+
+	return false
+}
+
+// a && b -> bemidb_range_overlaps(a, b)
+// a @> b -> bemidb_range_contains(a, b)
+// a <@ b -> bemidb_range_contains(b, a)
+// ts <@ tstzrange(start, end, '[)') -> ts >= start::timestamptz AND ts < end::timestamptz, which DuckDB can push down to Parquet files
+func (parser *QueryParserRange) RemapRangeOperator(node *pgQuery.Node, rangeColumnNames *Set) {
+	aExpr := node.GetAExpr()
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_RANGE_OPERATOR_OVERLAPS:
+		node.Node = parser.makeFunctionCallNode("bemidb_range_overlaps", aExpr.Lexpr, aExpr.Rexpr).Node
+	case PG_RANGE_OPERATOR_CONTAINS:
+		if boundsNode := parser.makeElementWithinBoundsNode(aExpr.Rexpr, aExpr.Lexpr, rangeColumnNames); boundsNode != nil {
+			node.Node = boundsNode.Node
+			return
+		}
+		node.Node = parser.makeFunctionCallNode("bemidb_range_contains", aExpr.Lexpr, aExpr.Rexpr).Node
+	case PG_RANGE_OPERATOR_CONTAINED_BY:
+		if boundsNode := parser.makeElementWithinBoundsNode(aExpr.Lexpr, aExpr.Rexpr, rangeColumnNames); boundsNode != nil {
+			node.Node = boundsNode.Node
+			return
+		}
+		node.Node = parser.makeFunctionCallNode("bemidb_range_contains", aExpr.Rexpr, aExpr.Lexpr).Node
+	}
+}
+
+// tstzrange(lower, upper), tstzrange(lower, upper, bounds)
+func (parser *QueryParserRange) IsRangeConstructor(node *pgQuery.Node) bool {
+	functionCall := node.GetFuncCall()
+	if functionCall == nil || (len(functionCall.Args) != 2 && len(functionCall.Args) != 3) {
+		return false
+	}
+
+	functionName := functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().GetSval()
+	return PG_RANGE_TYPES.Contains(functionName)
+}
+
+// tstzrange(lower, upper) -> bemidb_range(lower, upper, '[)')
+func (parser *QueryParserRange) RemapRangeConstructor(node *pgQuery.Node) {
+	functionCall := node.GetFuncCall()
+
+	boundsNode := pgQuery.MakeAConstStrNode(PG_RANGE_DEFAULT_BOUNDS, 0)
+	if len(functionCall.Args) == 3 {
+		boundsNode = functionCall.Args[2]
+	}
+
+	node.Node = parser.makeFunctionCallNode("bemidb_range", functionCall.Args[0], functionCall.Args[1], boundsNode).Node
+}
+
+// '[1,5)'::int4range
+func (parser *QueryParserRange) IsRangeTypeCast(node *pgQuery.Node) bool {
+	typeCast := node.GetTypeCast()
+	if typeCast == nil || typeCast.TypeName == nil || len(typeCast.TypeName.Names) == 0 {
+		return false
+	}
+
+	typeName := typeCast.TypeName.Names[len(typeCast.TypeName.Names)-1].GetString_().GetSval()
+	return PG_RANGE_TYPES.Contains(typeName)
+}
+
+// '[1,5)'::int4range -> '[1,5)'::varchar
+func (parser *QueryParserRange) RemapRangeTypeCast(node *pgQuery.Node) {
+	node.GetTypeCast().TypeName.Names = []*pgQuery.Node{pgQuery.MakeStrNode("varchar")}
+}
+
+// element, tstzrange(lower, upper, '[)') -> element >= lower AND element < upper, with "(" and "]" as > and <= respectively.
+// A NULL constant bound is unbounded, other bounds may be NULL at runtime: element >= coalesce(lower, element).
+// Returns nil if the element is a range itself, the bounds flags aren't a constant, or the range is unbounded on both sides
+func (parser *QueryParserRange) makeElementWithinBoundsNode(elementNode *pgQuery.Node, rangeNode *pgQuery.Node, rangeColumnNames *Set) *pgQuery.Node {
+	if !parser.IsRangeConstructor(rangeNode) || parser.isRangeOperand(elementNode, rangeColumnNames) {
+		return nil
+	}
+
+	functionCall := rangeNode.GetFuncCall()
+	bounds := PG_RANGE_DEFAULT_BOUNDS
+	if len(functionCall.Args) == 3 {
+		boundsConst := functionCall.Args[2].GetAConst()
+		if boundsConst == nil || boundsConst.GetSval() == nil {
+			return nil
+		}
+		bounds = boundsConst.GetSval().GetSval()
+	}
+	if len(bounds) != 2 || (bounds[0] != '[' && bounds[0] != '(') || (bounds[1] != ']' && bounds[1] != ')') {
+		return nil
+	}
+
+	lowerOperator := ">"
+	if bounds[0] == '[' {
+		lowerOperator = ">="
+	}
+	upperOperator := "<"
+	if bounds[1] == ']' {
+		upperOperator = "<="
+	}
+
+	elementType := PG_RANGE_ELEMENT_TYPES[functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().GetSval()]
+	var comparisonNodes []*pgQuery.Node
+	for i, operator := range []string{lowerOperator, upperOperator} {
+		boundNode := functionCall.Args[i]
+		boundConst := boundNode.GetAConst()
+		if boundConst != nil && boundConst.Isnull {
+			continue
+		}
+		if elementType != "" {
+			boundNode = NewQueryParserType(parser.config).MakeTypeCastNode(boundNode, elementType)
+		}
+
+		if boundConst == nil {
+			boundNode = &pgQuery.Node{Node: &pgQuery.Node_CoalesceExpr{CoalesceExpr: &pgQuery.CoalesceExpr{Args: []*pgQuery.Node{boundNode, elementNode}}}}
+		}
+		comparisonNodes = append(comparisonNodes, pgQuery.MakeAExprNode(pgQuery.A_Expr_Kind_AEXPR_OP, []*pgQuery.Node{pgQuery.MakeStrNode(operator)}, elementNode, boundNode, 0))
+	}
+
+	switch len(comparisonNodes) {
+	case 0:
+		return nil
+	case 1:
+		return comparisonNodes[0]
+	}
+	return pgQuery.MakeBoolExprNode(pgQuery.BoolExprType_AND_EXPR, comparisonNodes, 0)
+}
+
+func (parser *QueryParserRange) isRangeOperand(node *pgQuery.Node, rangeColumnNames *Set) bool {
+	return node != nil && (parser.IsRangeConstructor(node) || parser.IsRangeTypeCast(node) || parser.isRangeColumn(node, rangeColumnNames))
+}
+
+func (parser *QueryParserRange) isRangeColumn(node *pgQuery.Node, rangeColumnNames *Set) bool {
+	columnRef := node.GetColumnRef()
+	if columnRef == nil || len(columnRef.Fields) == 0 {
+		return false
+	}
+
+	columnName := columnRef.Fields[len(columnRef.Fields)-1].GetString_().GetSval()
+	return rangeColumnNames.Contains(columnName)
+}
+
+func (parser *QueryParserRange) makeFunctionCallNode(functionName string, args ...*pgQuery.Node) *pgQuery.Node {
+	return pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode(functionName)}, args, 0)
+}
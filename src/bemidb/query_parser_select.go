@@ -0,0 +1,307 @@
+package bemidb
+
+import (
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_FUNCTION_QUOTE_INDENT = "quote_ident"
+	PG_FUNCTION_PG_GET_EXPR  = "pg_get_expr"
+	PG_FUNCTION_PG_TYPEOF    = "pg_typeof"
+	PG_FUNCTION_SET_CONFIG   = "set_config"
+	PG_FUNCTION_ROW_TO_JSON  = "row_to_json"
+	PG_FUNCTION_LPAD         = "lpad"
+	PG_FUNCTION_RPAD         = "rpad"
+	PG_FUNCTION_EVERY        = "every"
+	PG_FUNCTION_ARRAY_AGG    = "array_agg"
+	PG_FUNCTION_STRING_AGG   = "string_agg"
+)
+
+type QueryParserSelect struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserSelect(config *Config) *QueryParserSelect {
+	return &QueryParserSelect{config: config, utils: NewQueryParserUtils(config)}
+}
+
+func (parser *QueryParserSelect) FunctionCall(targetNode *pgQuery.Node) *pgQuery.FuncCall {
+	return targetNode.GetResTarget().Val.GetFuncCall()
+}
+
+func (parser *QueryParserSelect) NestedFunctionCalls(functionCall *pgQuery.FuncCall) []*pgQuery.FuncCall {
+	nestedFunctionCalls := []*pgQuery.FuncCall{}
+
+	for _, arg := range functionCall.Args {
+		nestedFunctionCalls = append(nestedFunctionCalls, arg.GetFuncCall())
+	}
+
+	return nestedFunctionCalls
+}
+
+func (parser *QueryParserSelect) FunctionName(functionCall *pgQuery.FuncCall) string {
+	return functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().Sval
+}
+
+// quote_ident()
+func (parser *QueryParserSelect) IsQuoteIdentFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_QUOTE_INDENT
+}
+
+// quote_ident(str) -> concat("\""+str+"\"")
+func (parser *QueryParserSelect) RemapQuoteIdentToConcat(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	functionCall.Funcname[0] = pgQuery.MakeStrNode("concat")
+	argConstant := functionCall.Args[0].GetAConst()
+	if argConstant != nil {
+		str := argConstant.GetSval().Sval
+		str = "\"" + str + "\""
+		functionCall.Args[0] = pgQuery.MakeAConstStrNode(str, 0)
+	}
+
+	return functionCall
+}
+
+// every()
+func (parser *QueryParserSelect) IsEveryFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_EVERY
+}
+
+// every(bool) -> bool_and(bool)
+func (parser *QueryParserSelect) RemapEveryToBoolAnd(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	functionCall.Funcname = []*pgQuery.Node{pgQuery.MakeStrNode("bool_and")}
+
+	return functionCall
+}
+
+// array_agg(DISTINCT x), string_agg(DISTINCT x, sep)
+func (parser *QueryParserSelect) IsUnorderedDistinctAggregate(functionName string, functionCall *pgQuery.FuncCall) bool {
+	return (functionName == PG_FUNCTION_ARRAY_AGG || functionName == PG_FUNCTION_STRING_AGG) &&
+		functionCall.AggDistinct && len(functionCall.AggOrder) == 0 && len(functionCall.Args) > 0
+}
+
+// Postgres sorts the values to remove duplicates, while DuckDB keeps them in an arbitrary order
+// array_agg(DISTINCT x) -> array_agg(DISTINCT x ORDER BY x)
+func (parser *QueryParserSelect) RemapUnorderedDistinctAggregate(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	functionCall.AggOrder = []*pgQuery.Node{
+		pgQuery.MakeSortByNode(functionCall.Args[0], pgQuery.SortByDir_SORTBY_DEFAULT, pgQuery.SortByNulls_SORTBY_NULLS_DEFAULT, 0),
+	}
+
+	return functionCall
+}
+
+// pg_typeof()
+func (parser *QueryParserSelect) IsPgTypeofFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_PG_TYPEOF
+}
+
+// pg_typeof(value) -> bemidb_pg_typeof(value) to return Postgres type names
+func (parser *QueryParserSelect) RemapPgTypeof(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	functionCall.Funcname = []*pgQuery.Node{pgQuery.MakeStrNode("bemidb_pg_typeof")}
+	return functionCall
+}
+
+// pg_get_expr()
+func (parser *QueryParserSelect) IsPgGetExprFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_PG_GET_EXPR
+}
+
+// pg_get_expr(pg_node_tree, relation_oid, pretty_bool) -> pg_get_expr(pg_node_tree, relation_oid)
+func (parser *QueryParserSelect) RemoveThirdArgumentFromPgGetExpr(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	if len(functionCall.Args) > 2 {
+		functionCall.Args = functionCall.Args[:2]
+	}
+
+	return functionCall
+}
+
+// set_config()
+func (parser *QueryParserSelect) IsSetConfigFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_SET_CONFIG
+}
+
+// set_config(setting_name, new_value, is_local) -> new_value
+func (parser *QueryParserSelect) RemapSetConfigFunction(targetNode *pgQuery.Node, functionCall *pgQuery.FuncCall) {
+	valueNode := functionCall.Args[1]
+	settingName := functionCall.Args[0].GetAConst().GetSval().Sval
+	LogWarn(parser.config, "Unsupported set_config", settingName, ":", functionCall)
+
+	parser.OverrideTargetValue(targetNode, valueNode)
+	parser.SetDefaultTargetName(targetNode, PG_FUNCTION_SET_CONFIG)
+}
+
+// row_to_json()
+func (parser *QueryParserSelect) IsRowToJsonFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_ROW_TO_JSON
+}
+
+// row_to_json() -> to_json()
+func (parser *QueryParserSelect) RemapRowToJson(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	functionCall.Funcname = []*pgQuery.Node{pgQuery.MakeStrNode("to_json")}
+	return functionCall
+}
+
+// lpad() / rpad()
+func (parser *QueryParserSelect) IsPadFunction(functionName string) bool {
+	return functionName == PG_FUNCTION_LPAD || functionName == PG_FUNCTION_RPAD
+}
+
+// lpad(str, len) -> lpad(str, len, ' ')
+// lpad(str, len, fill) -> lpad(str, if(fill is empty, least(len, length(str)), len), if(fill is empty, ' ', fill))
+func (parser *QueryParserSelect) RemapPadFunction(functionCall *pgQuery.FuncCall) *pgQuery.FuncCall {
+	if len(functionCall.Args) == 2 {
+		functionCall.Args = append(functionCall.Args, pgQuery.MakeAConstStrNode(" ", 0))
+		return functionCall
+	}
+
+	if len(functionCall.Args) != 3 {
+		return functionCall
+	}
+
+	fillConstant := functionCall.Args[2].GetAConst()
+	if fillConstant != nil && fillConstant.GetSval() != nil && fillConstant.GetSval().Sval != "" {
+		return functionCall
+	}
+
+	// DuckDB fails with "Insufficient padding" on an empty fill, while Postgres returns the string truncated to len
+	strNode := functionCall.Args[0]
+	lenNode := functionCall.Args[1]
+	fillNode := functionCall.Args[2]
+	isEmptyFillNode := pgQuery.MakeAExprNode(pgQuery.A_Expr_Kind_AEXPR_OP, []*pgQuery.Node{pgQuery.MakeStrNode("=")}, fillNode, pgQuery.MakeAConstStrNode("", 0), 0)
+
+	strLengthNode := NewQueryParserType(parser.config).MakeTypeCastNode(
+		pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode("length")}, []*pgQuery.Node{strNode}, 0),
+		"int4",
+	)
+	functionCall.Args[1] = parser.makeIfFunctionNode(
+		isEmptyFillNode,
+		pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode("least")}, []*pgQuery.Node{lenNode, strLengthNode}, 0),
+		lenNode,
+	)
+	functionCall.Args[2] = parser.makeIfFunctionNode(isEmptyFillNode, pgQuery.MakeAConstStrNode(" ", 0), fillNode)
+
+	return functionCall
+}
+
+// arr[1], arr[2:4], matrix[1][2] anywhere in an expression, e.g. arr[1] IS NULL, except in subqueries
+func (parser *QueryParserSelect) ArrayIndirections(node *pgQuery.Node) (indirections []*pgQuery.A_Indirection) {
+	if node == nil {
+		return nil
+	}
+
+	var findIndirections func(message protoreflect.Message)
+	findIndirections = func(message protoreflect.Message) {
+		switch indirection := message.Interface().(type) {
+		case *pgQuery.SubLink:
+			return
+		case *pgQuery.A_Indirection:
+			for _, indirectionNode := range indirection.Indirection {
+				if indirectionNode.GetAIndices() != nil {
+					indirections = append(indirections, indirection)
+					break
+				}
+			}
+		}
+
+		message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+			switch {
+			case field.IsList() && field.Message() != nil:
+				list := value.List()
+				for i := 0; i < list.Len(); i++ {
+					findIndirections(list.Get(i).Message())
+				}
+			case !field.IsMap() && field.Message() != nil:
+				findIndirections(value.Message())
+			}
+			return true
+		})
+	}
+	findIndirections(node.ProtoReflect())
+
+	return indirections
+}
+
+// Postgres returns NULL for indexes below 1 and clamps slice bounds, while DuckDB counts negative indexes from the end
+// arr[-1] -> arr[NULL], arr[i] -> arr[if(i > 0, i, NULL)]
+// arr[-1:-2] -> arr[1:0], arr[i:j] -> arr[greatest(i, 1):greatest(j, 0)]
+func (parser *QueryParserSelect) RemapArrayIndirection(indirection *pgQuery.A_Indirection) {
+	for _, indirectionNode := range indirection.Indirection {
+		indices := indirectionNode.GetAIndices()
+		if indices == nil {
+			continue
+		}
+
+		if indices.IsSlice {
+			indices.Lidx = parser.makeArraySliceBoundNode(indices.Lidx, 1)
+			indices.Uidx = parser.makeArraySliceBoundNode(indices.Uidx, 0)
+		} else {
+			indices.Uidx = parser.makeArrayIndexNode(indices.Uidx)
+		}
+	}
+}
+
+// Postgres names arr[1] after the column
+func (parser *QueryParserSelect) ArrayIndirectionColumnName(indirection *pgQuery.A_Indirection) string {
+	columnRef := indirection.Arg.GetColumnRef()
+	if columnRef == nil {
+		return ""
+	}
+
+	return columnRef.Fields[len(columnRef.Fields)-1].GetString_().GetSval()
+}
+
+func (parser *QueryParserSelect) OverrideFunctionCallArg(functionCall *pgQuery.FuncCall, index int, node *pgQuery.Node) {
+	functionCall.Args[index] = node
+}
+
+func (parser *QueryParserSelect) OverrideTargetValue(targetNode *pgQuery.Node, node *pgQuery.Node) {
+	targetNode.GetResTarget().Val = node
+}
+
+func (parser *QueryParserSelect) SetDefaultTargetName(targetNode *pgQuery.Node, name string) {
+	target := targetNode.GetResTarget()
+
+	if target.Name == "" {
+		target.Name = name
+	}
+}
+
+func (parser *QueryParserSelect) makeArrayIndexNode(indexNode *pgQuery.Node) *pgQuery.Node {
+	if constant := indexNode.GetAConst(); constant != nil {
+		if constant.GetIval() != nil && constant.GetIval().Ival < 1 {
+			return parser.makeNullNode()
+		}
+		return indexNode
+	}
+
+	isPositiveNode := pgQuery.MakeAExprNode(pgQuery.A_Expr_Kind_AEXPR_OP, []*pgQuery.Node{pgQuery.MakeStrNode(">")}, indexNode, pgQuery.MakeAConstIntNode(0, 0), 0)
+	return parser.makeIfFunctionNode(isPositiveNode, indexNode, parser.makeNullNode())
+}
+
+func (parser *QueryParserSelect) makeArraySliceBoundNode(boundNode *pgQuery.Node, minBound int64) *pgQuery.Node {
+	if boundNode == nil {
+		return nil
+	}
+
+	if constant := boundNode.GetAConst(); constant != nil {
+		if constant.GetIval() != nil && int64(constant.GetIval().Ival) < minBound {
+			return pgQuery.MakeAConstIntNode(minBound, 0)
+		}
+		return boundNode
+	}
+
+	return pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode("greatest")}, []*pgQuery.Node{boundNode, pgQuery.MakeAConstIntNode(minBound, 0)}, 0)
+}
+
+func (parser *QueryParserSelect) makeNullNode() *pgQuery.Node {
+	return &pgQuery.Node{Node: &pgQuery.Node_AConst{AConst: &pgQuery.A_Const{Isnull: true}}}
+}
+
+func (parser *QueryParserSelect) makeIfFunctionNode(conditionNode *pgQuery.Node, thenNode *pgQuery.Node, elseNode *pgQuery.Node) *pgQuery.Node {
+	return pgQuery.MakeFuncCallNode(
+		[]*pgQuery.Node{pgQuery.MakeStrNode("if")},
+		[]*pgQuery.Node{conditionNode, thenNode, elseNode},
+		0,
+	)
+}
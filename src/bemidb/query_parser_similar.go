@@ -0,0 +1,149 @@
+package bemidb
+
+import (
+	"errors"
+	"strings"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_SIMILAR_TO_OPERATOR     = "~"
+	PG_NOT_SIMILAR_TO_OPERATOR = "!~"
+	PG_SIMILAR_TO_ESCAPE       = "\\"
+)
+
+type QueryParserSimilar struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserSimilar(config *Config) *QueryParserSimilar {
+	return &QueryParserSimilar{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// SIMILAR TO expressions anywhere in the statement, including subqueries
+func (parser *QueryParserSimilar) SimilarToNodes(node *pgQuery.Node) (similarToNodes []*pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if similarToNode, ok := message.Interface().(*pgQuery.Node); ok && parser.IsSimilarTo(similarToNode) {
+			similarToNodes = append(similarToNodes, similarToNode)
+		}
+	})
+
+	return similarToNodes
+}
+
+// a SIMILAR TO 'pattern' [ESCAPE 'e'], a NOT SIMILAR TO 'pattern' [ESCAPE 'e'] with constant pattern and escape.
+// DuckDB treats SIMILAR TO patterns as regular expressions, so other patterns can't be translated
+func (parser *QueryParserSimilar) IsSimilarTo(node *pgQuery.Node) bool {
+	aExpr := node.GetAExpr()
+	if aExpr == nil || aExpr.Kind != pgQuery.A_Expr_Kind_AEXPR_SIMILAR || aExpr.Rexpr.GetFuncCall() == nil {
+		return false
+	}
+
+	for _, arg := range aExpr.Rexpr.GetFuncCall().Args {
+		if arg.GetAConst() == nil || arg.GetAConst().GetSval() == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// a SIMILAR TO 'pattern' -> regexp_full_match(a, 'regex')
+// a NOT SIMILAR TO 'pattern' -> NOT regexp_full_match(a, 'regex')
+func (parser *QueryParserSimilar) RemapSimilarTo(node *pgQuery.Node) error {
+	aExpr := node.GetAExpr()
+	escapeArgs := aExpr.Rexpr.GetFuncCall().Args
+
+	pattern := escapeArgs[0].GetAConst().GetSval().Sval
+	escape := PG_SIMILAR_TO_ESCAPE
+	if len(escapeArgs) > 1 {
+		escape = escapeArgs[1].GetAConst().GetSval().Sval
+	}
+
+	regex, err := parser.similarToRegex(pattern, escape)
+	if err != nil {
+		return err
+	}
+
+	functionCall := pgQuery.MakeFuncCallNode(
+		[]*pgQuery.Node{pgQuery.MakeStrNode("regexp_full_match")},
+		[]*pgQuery.Node{aExpr.Lexpr, pgQuery.MakeAConstStrNode(regex, 0)},
+		0,
+	)
+	if aExpr.Name[0].GetString_().GetSval() == PG_NOT_SIMILAR_TO_OPERATOR {
+		functionCall = pgQuery.MakeBoolExprNode(pgQuery.BoolExprType_NOT_EXPR, []*pgQuery.Node{functionCall}, 0)
+	}
+
+	node.Node = functionCall.Node
+	return nil
+}
+
+// Translates a SQL regular expression to a regular expression like Postgres' similar_to_escape():
+// % -> .*, _ -> ., ( -> (?:, escaped characters and regex-only metacharacters like . ^ $ are matched literally.
+// (?s) lets % and _ match newlines like in Postgres
+func (parser *QueryParserSimilar) similarToRegex(pattern string, escape string) (string, error) {
+	if len([]rune(escape)) > 1 {
+		return "", errors.New("invalid escape string")
+	}
+	escapeRune := rune(0)
+	if escape != "" {
+		escapeRune = []rune(escape)[0]
+	}
+
+	var regex strings.Builder
+	regex.WriteString("(?s)(?:")
+
+	afterEscape := false
+	inCharClass := false
+	quoteCount := 0
+	for _, char := range pattern {
+		switch {
+		case afterEscape:
+			afterEscape = false
+			if char == '"' && !inCharClass {
+				// Escape-double-quote separators for SUBSTRING, matched lazily before the first and greedily after the second
+				switch quoteCount {
+				case 0:
+					regex.WriteString("){1,1}?(")
+				case 1:
+					regex.WriteString("){1,1}(?:")
+				default:
+					return "", errors.New("SQL regular expression may not contain more than two escape-double-quote separators")
+				}
+				quoteCount++
+			} else {
+				regex.WriteRune('\\')
+				regex.WriteRune(char)
+			}
+		case escapeRune != 0 && char == escapeRune:
+			afterEscape = true
+		case inCharClass:
+			if char == '\\' {
+				regex.WriteRune('\\')
+			}
+			regex.WriteRune(char)
+			if char == ']' {
+				inCharClass = false
+			}
+		case char == '[':
+			regex.WriteRune(char)
+			inCharClass = true
+		case char == '%':
+			regex.WriteString(".*")
+		case char == '_':
+			regex.WriteRune('.')
+		case char == '(':
+			regex.WriteString("(?:")
+		case char == '\\' || char == '.' || char == '^' || char == '$':
+			regex.WriteRune('\\')
+			regex.WriteRune(char)
+		default:
+			regex.WriteRune(char)
+		}
+	}
+
+	regex.WriteString(")")
+	return regex.String(), nil
+}
@@ -0,0 +1,2075 @@
+package bemidb
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// PG_SCHEMA_PG_CATALOG = "pg_catalog" Already defined in pg_schema_column.go
+	PG_SCHEMA_INFORMATION_SCHEMA = "information_schema"
+
+	PG_FUNCTION_PG_GET_KEYWORDS      = "pg_get_keywords"
+	PG_FUNCTION_ARRAY_UPPER          = "array_upper"
+	PG_FUNCTION_PG_SHOW_ALL_SETTINGS = "pg_show_all_settings"
+	PG_FUNCTION_PG_IS_IN_RECOVERY    = "pg_is_in_recovery"
+	PG_FUNCTION_UNNEST               = "unnest"
+	PG_FUNCTION_CURRENT_SCHEMA       = "current_schema"
+	PG_FUNCTION_CURRENT_SCHEMAS      = "current_schemas"
+	PG_FUNCTION_VERSION              = "version"
+	PG_FUNCTION_CURRENT_SETTING      = "current_setting"
+)
+
+// Primary key of an Iceberg table captured during sync
+type PrimaryKey struct {
+	SchemaTable IcebergSchemaTable
+	ColumnNames []string
+}
+
+// Schema fields of an Iceberg table, including column defaults captured during sync
+type TableSchemaFields struct {
+	SchemaTable  IcebergSchemaTable
+	SchemaFields []IcebergSchemaField
+}
+
+type QueryParserTable struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserTable(config *Config) *QueryParserTable {
+	return &QueryParserTable{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// Names are as folded by the parser like in Postgres: unquoted to lowercase, quoted verbatim
+func (parser *QueryParserTable) NodeToQuerySchemaTable(node *pgQuery.Node) QuerySchemaTable {
+	rangeVar := node.GetRangeVar()
+	var alias string
+
+	if rangeVar.Alias != nil {
+		alias = rangeVar.Alias.Aliasname
+	}
+
+	return QuerySchemaTable{
+		Schema: rangeVar.Schemaname,
+		Table:  rangeVar.Relname,
+		Alias:  alias,
+	}
+}
+
+func (parser *QueryParserTable) MakeEmptyTableNode(tableName string, columns []string, alias string) *pgQuery.Node {
+	return parser.utils.MakeSubselectWithoutRowsNode(tableName, columns, alias)
+}
+
+// pg_catalog.pg_shadow -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakePgShadowNode(user string, encryptedPassword string, alias string) *pgQuery.Node {
+	columns := PG_SHADOW_VALUE_BY_COLUMN.Keys()
+	staticRowValues := PG_SHADOW_VALUE_BY_COLUMN.Values()
+
+	var rowsValues [][]string
+
+	rowValues := make([]string, len(staticRowValues))
+	copy(rowValues, staticRowValues)
+	for i, column := range columns {
+		switch column {
+		case "usename":
+			rowValues[i] = user
+		case "passwd":
+			rowValues[i] = encryptedPassword
+		}
+	}
+	rowsValues = append(rowsValues, rowValues)
+
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_PG_SHADOW, columns, rowsValues, alias)
+}
+
+// pg_catalog.pg_roles -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakePgRolesNode(user string, alias string) *pgQuery.Node {
+	columns := PG_ROLES_VALUE_BY_COLUMN.Keys()
+	staticRowValues := PG_ROLES_VALUE_BY_COLUMN.Values()
+
+	var rowsValues [][]string
+	rowValues := make([]string, len(staticRowValues))
+	copy(rowValues, staticRowValues)
+
+	for i, column := range columns {
+		if column == "rolname" {
+			rowValues[i] = user
+		}
+	}
+	rowsValues = append(rowsValues, rowValues)
+
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_PG_ROLES, columns, rowsValues, alias)
+}
+
+// pg_catalog.pg_extension -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakePgExtensionNode(alias string) *pgQuery.Node {
+	columns := PG_EXTENSION_VALUE_BY_COLUMN.Keys()
+	staticRowValues := PG_EXTENSION_VALUE_BY_COLUMN.Values()
+	rowsValues := [][]string{staticRowValues}
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_PG_EXTENSION, columns, rowsValues, alias)
+}
+
+// pg_catalog.pg_database -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakePgDatabaseNode(database string, alias string) *pgQuery.Node {
+	columns := PG_DATABASE_VALUE_BY_COLUMN.Keys()
+	staticRowValues := PG_DATABASE_VALUE_BY_COLUMN.Values()
+
+	var rowsValues [][]string
+	rowValues := make([]string, len(staticRowValues))
+	copy(rowValues, staticRowValues)
+	for i, column := range columns {
+		if column == "datname" {
+			rowValues[i] = database
+		}
+	}
+	rowsValues = append(rowsValues, rowValues)
+
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_PG_DATABASE, columns, rowsValues, alias)
+}
+
+// pg_catalog.pg_settings -> (VALUES(server settings...) UNION ALL SELECT ... FROM duckdb_settings()) t(columns...)
+func (parser *QueryParserTable) MakePgSettingsNode(serverSettings []PgServerSetting, alias string) *pgQuery.Node {
+	var rowsSql []string
+	var namesSql []string
+	for _, serverSetting := range serverSettings {
+		rowsSql = append(rowsSql, "("+strings.Join([]string{
+			parser.quoteString(serverSetting.Name),
+			parser.quoteString(serverSetting.Setting),
+			"NULL",
+			parser.quoteString(serverSetting.Category),
+			parser.quoteString(serverSetting.ShortDesc),
+			"'postmaster'",
+			"'integer'",
+			"'default'",
+			"'0'",
+			parser.quoteString(serverSetting.MaxVal),
+		}, ", ")+")")
+		namesSql = append(namesSql, parser.quoteString(serverSetting.Name))
+	}
+
+	// DuckDB's own settings are kept, e.g. for SELECT setting FROM pg_settings WHERE name = 'threads'
+	query := "SELECT * FROM (VALUES " + strings.Join(rowsSql, ", ") + ") " + PG_TABLE_PG_SETTINGS + "(" + strings.Join(PG_SETTINGS_COLUMNS, ", ") + ")" +
+		" UNION ALL SELECT name, value, NULL, NULL, description, 'user', input_type, 'default', NULL, NULL FROM duckdb_settings() WHERE name NOT IN (" + strings.Join(namesSql, ", ") + ")"
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err)
+
+	if alias == "" {
+		alias = PG_TABLE_PG_SETTINGS
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Subquery: queryTree.Stmts[0].Stmt,
+				Alias:    &pgQuery.Alias{Aliasname: alias},
+			},
+		},
+	}
+}
+
+// pg_catalog.pg_namespace -> (SELECT oid, schema_name AS nspname, ... FROM duckdb_schemas() WHERE ...) pg_namespace
+// DuckDB lists pg_catalog, information_schema and main once per attached database. The oids are kept to join with pg_class.relnamespace
+func (parser *QueryParserTable) MakePgNamespaceNode(schemas []string, alias string) *pgQuery.Node {
+	var schemasSql []string
+	for _, schema := range schemas {
+		schemasSql = append(schemasSql, parser.quoteString(schema))
+	}
+
+	query := "SELECT oid, schema_name AS nspname, " + PG_ROLES_VALUE_BY_COLUMN.Get("oid") + " AS nspowner, NULL AS nspacl FROM duckdb_schemas()" +
+		" WHERE database_name = current_database() AND schema_name IN (" + strings.Join(schemasSql, ", ") + ")"
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err)
+
+	if alias == "" {
+		alias = PG_TABLE_PG_NAMESPACE
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Subquery: queryTree.Stmts[0].Stmt,
+				Alias:    &pgQuery.Alias{Aliasname: alias},
+			},
+		},
+	}
+}
+
+// pg_catalog.pg_class -> (SELECT ... FROM pg_catalog.pg_class LEFT JOIN (Iceberg tables) ...) pg_class
+// Iceberg tables are created in DuckDB with a placeholder column, so their number of columns comes from the Iceberg schema
+func (parser *QueryParserTable) MakePgClassNode(tablesSchemaFields []TableSchemaFields, alias string) *pgQuery.Node {
+	var rowsSql []string
+	for _, tableSchemaFields := range tablesSchemaFields {
+		rowsSql = append(rowsSql, "("+strings.Join([]string{
+			parser.quoteString(tableSchemaFields.SchemaTable.Schema),
+			parser.quoteString(tableSchemaFields.SchemaTable.Table),
+			IntToString(len(tableSchemaFields.SchemaFields)),
+		}, ", ")+")")
+	}
+
+	var columnsSql []string
+	for _, column := range PG_CLASS_COLUMNS {
+		switch column {
+		case "relowner":
+			columnsSql = append(columnsSql, "CASE WHEN iceberg_tables.table_oid IS NULL THEN pg_class.relowner ELSE "+PG_ROLES_VALUE_BY_COLUMN.Get("oid")+" END AS relowner")
+		case "relnatts":
+			columnsSql = append(columnsSql, "COALESCE(iceberg_tables.relnatts, pg_class.relnatts) AS relnatts")
+		default:
+			columnsSql = append(columnsSql, "pg_class."+column)
+		}
+	}
+
+	query := "SELECT * FROM pg_catalog.pg_class"
+	if len(rowsSql) > 0 {
+		query = "SELECT " + strings.Join(columnsSql, ", ") + " FROM pg_catalog.pg_class LEFT JOIN (" +
+			"SELECT duckdb_tables.table_oid, iceberg_tables.relnatts FROM duckdb_tables() duckdb_tables" +
+			" JOIN (VALUES " + strings.Join(rowsSql, ", ") + ") iceberg_tables(schema_name, table_name, relnatts)" +
+			" ON duckdb_tables.schema_name = iceberg_tables.schema_name AND duckdb_tables.table_name = iceberg_tables.table_name" +
+			" WHERE duckdb_tables.database_name = current_database()" +
+			") iceberg_tables ON iceberg_tables.table_oid = pg_class.oid"
+	}
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err)
+
+	if alias == "" {
+		alias = PG_TABLE_PG_CLASS
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Subquery: queryTree.Stmts[0].Stmt,
+				Alias:    &pgQuery.Alias{Aliasname: alias},
+			},
+		},
+	}
+}
+
+// pg_catalog.pg_attribute -> (SELECT ... FROM pg_catalog.pg_attribute WHERE attrelid NOT IN (Iceberg tables) UNION ALL SELECT ... FROM (Iceberg columns)) pg_attribute
+// Iceberg tables are created in DuckDB with a placeholder column, so their columns come from the Iceberg schema.
+// attrelid is the oid of the DuckDB table to join with pg_class, and atttypid the Postgres type oid to join with pg_type
+func (parser *QueryParserTable) MakePgAttributeNode(tablesSchemaFields []TableSchemaFields, alias string) *pgQuery.Node {
+	var schemaTablesSql, rowsSql []string
+	for _, tableSchemaFields := range tablesSchemaFields {
+		schemaTable := tableSchemaFields.SchemaTable
+		schemaTablesSql = append(schemaTablesSql, "("+parser.quoteString(schemaTable.Schema)+", "+parser.quoteString(schemaTable.Table)+")")
+
+		for position, icebergSchemaField := range tableSchemaFields.SchemaFields {
+			dataType, udtName, numericPrecision, numericScale := parser.pgColumnType(icebergSchemaField.Type)
+
+			attndims := "0"
+			if dataType == "ARRAY" {
+				attndims = "1"
+			}
+			atttypmod := "-1"
+			if dataType == "numeric" && numericPrecision != "NULL::INTEGER" {
+				precision, _ := StringToInt(numericPrecision)
+				scale, _ := StringToInt(numericScale)
+				atttypmod = IntToString(precision<<16 | scale + 4) // VARHDRSZ
+			}
+
+			rowsSql = append(rowsSql, "("+strings.Join([]string{
+				parser.quoteString(schemaTable.Schema),
+				parser.quoteString(schemaTable.Table),
+				parser.quoteString(icebergSchemaField.Name),
+				IntToString(int(parser.pgTypeOid(udtName))),
+				IntToString(position + 1),
+				attndims,
+				atttypmod,
+				strconv.FormatBool(icebergSchemaField.Required),
+				strconv.FormatBool(icebergSchemaField.ColumnDefault != ""),
+			}, ", ")+")")
+		}
+	}
+
+	query := "SELECT " + strings.Join(PG_ATTRIBUTE_VALUE_BY_COLUMN.Keys(), ", ") + " FROM pg_catalog.pg_attribute"
+	if len(schemaTablesSql) > 0 {
+		query += " WHERE attrelid NOT IN (SELECT table_oid FROM duckdb_tables() WHERE database_name = current_database() AND (schema_name, table_name) IN (" + strings.Join(schemaTablesSql, ", ") + "))"
+	}
+	if len(rowsSql) > 0 {
+		var columnsSql []string
+		for _, column := range PG_ATTRIBUTE_VALUE_BY_COLUMN.Keys() {
+			columnsSql = append(columnsSql, PG_ATTRIBUTE_VALUE_BY_COLUMN.Get(column)+" AS "+column)
+		}
+		query += " UNION ALL SELECT " + strings.Join(columnsSql, ", ") + " FROM duckdb_tables() duckdb_tables" +
+			" JOIN (VALUES " + strings.Join(rowsSql, ", ") + ") iceberg_columns(schema_name, table_name, attname, atttypid, attnum, attndims, atttypmod, attnotnull, atthasdef)" +
+			" ON duckdb_tables.schema_name = iceberg_columns.schema_name AND duckdb_tables.table_name = iceberg_columns.table_name" +
+			" WHERE duckdb_tables.database_name = current_database()"
+	}
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err)
+
+	if alias == "" {
+		alias = PG_TABLE_PG_ATTRIBUTE
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Subquery: queryTree.Stmts[0].Stmt,
+				Alias:    &pgQuery.Alias{Aliasname: alias},
+			},
+		},
+	}
+}
+
+// Postgres type oid by type name, e.g. int4 -> 23 and _int4 -> 1007
+func (parser *QueryParserTable) pgTypeOid(udtName string) uint32 {
+	if pgType, ok := pgtype.NewMap().TypeForName(udtName); ok {
+		return pgType.OID
+	}
+	return pgtype.TextOID
+}
+
+func (parser *QueryParserTable) quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// pg_catalog.pg_user -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakePgUserNode(user string, alias string) *pgQuery.Node {
+	columns := PG_USER_VALUE_BY_COLUMN.Keys()
+	rowValues := PG_USER_VALUE_BY_COLUMN.Values()
+
+	rowValues[0] = user
+
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_PG_USER, columns, [][]string{rowValues}, alias)
+}
+
+// information_schema.table_constraints -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakeTableConstraintsNode(database string, primaryKeys []PrimaryKey, alias string) *pgQuery.Node {
+	columns := PG_TABLE_CONSTRAINTS_VALUE_BY_COLUMN.Keys()
+	if len(primaryKeys) == 0 {
+		return parser.MakeEmptyTableNode(PG_TABLE_TABLE_CONSTRAINTS, columns, alias)
+	}
+	staticRowValues := PG_TABLE_CONSTRAINTS_VALUE_BY_COLUMN.Values()
+
+	var rowsValues [][]string
+	for _, primaryKey := range primaryKeys {
+		rowValues := make([]string, len(staticRowValues))
+		copy(rowValues, staticRowValues)
+		for i, column := range columns {
+			switch column {
+			case "constraint_catalog", "table_catalog":
+				rowValues[i] = database
+			case "constraint_schema", "table_schema":
+				rowValues[i] = primaryKey.SchemaTable.Schema
+			case "constraint_name":
+				rowValues[i] = parser.primaryKeyConstraintName(primaryKey)
+			case "table_name":
+				rowValues[i] = primaryKey.SchemaTable.Table
+			}
+		}
+		rowsValues = append(rowsValues, rowValues)
+	}
+
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_TABLE_CONSTRAINTS, columns, rowsValues, alias)
+}
+
+// information_schema.key_column_usage -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakeKeyColumnUsageNode(database string, primaryKeys []PrimaryKey, alias string) *pgQuery.Node {
+	columns := PG_KEY_COLUMN_USAGE_VALUE_BY_COLUMN.Keys()
+	staticRowValues := PG_KEY_COLUMN_USAGE_VALUE_BY_COLUMN.Values()
+
+	var rowsValues [][]string
+	for _, primaryKey := range primaryKeys {
+		for position, columnName := range primaryKey.ColumnNames {
+			rowValues := make([]string, len(staticRowValues))
+			copy(rowValues, staticRowValues)
+			for i, column := range columns {
+				switch column {
+				case "constraint_catalog", "table_catalog":
+					rowValues[i] = database
+				case "constraint_schema", "table_schema":
+					rowValues[i] = primaryKey.SchemaTable.Schema
+				case "constraint_name":
+					rowValues[i] = parser.primaryKeyConstraintName(primaryKey)
+				case "table_name":
+					rowValues[i] = primaryKey.SchemaTable.Table
+				case "column_name":
+					rowValues[i] = columnName
+				case "ordinal_position":
+					rowValues[i] = IntToString(position + 1)
+				}
+			}
+			rowsValues = append(rowsValues, rowValues)
+		}
+	}
+
+	if len(rowsValues) == 0 {
+		return parser.MakeEmptyTableNode(PG_TABLE_KEY_COLUMN_USAGE, columns, alias)
+	}
+	return parser.utils.MakeSubselectWithRowsNode(PG_TABLE_KEY_COLUMN_USAGE, columns, rowsValues, alias)
+}
+
+// information_schema.columns -> (VALUES(values...)) t(columns...) with NULLs where Postgres returns them
+func (parser *QueryParserTable) MakeColumnsNode(database string, tablesSchemaFields []TableSchemaFields, alias string) *pgQuery.Node {
+	var rowsSql []string
+	for _, tableSchemaFields := range tablesSchemaFields {
+		schemaTable := tableSchemaFields.SchemaTable
+		for position, icebergSchemaField := range tableSchemaFields.SchemaFields {
+			dataType, udtName, numericPrecision, numericScale := parser.pgColumnType(icebergSchemaField.Type)
+
+			columnDefault := "NULL"
+			if icebergSchemaField.ColumnDefault != "" {
+				columnDefault = parser.quoteString(parser.columnDefault(icebergSchemaField.ColumnDefault, schemaTable.Schema))
+			}
+			isNullable := "'YES'"
+			if icebergSchemaField.Required {
+				isNullable = "'NO'"
+			}
+
+			rowsSql = append(rowsSql, "("+strings.Join([]string{
+				parser.quoteString(database),
+				parser.quoteString(schemaTable.Schema),
+				parser.quoteString(schemaTable.Table),
+				parser.quoteString(icebergSchemaField.Name),
+				IntToString(position + 1),
+				columnDefault,
+				isNullable,
+				parser.quoteString(dataType),
+				"NULL::INTEGER",
+				numericPrecision,
+				numericScale,
+				"NULL::INTEGER",
+				parser.quoteString(database),
+				"'pg_catalog'",
+				parser.quoteString(udtName),
+				"'NO'",
+				"'NEVER'",
+				"'YES'",
+			}, ", ")+")")
+		}
+	}
+
+	if len(rowsSql) == 0 {
+		return parser.MakeEmptyTableNode(PG_TABLE_COLUMNS, PG_COLUMNS_COLUMNS, alias)
+	}
+
+	query := "SELECT * FROM (VALUES " + strings.Join(rowsSql, ", ") + ") " + PG_TABLE_COLUMNS + "(" + strings.Join(PG_COLUMNS_COLUMNS, ", ") + ")"
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err)
+
+	if alias == "" {
+		alias = PG_TABLE_COLUMNS
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Subquery: queryTree.Stmts[0].Stmt,
+				Alias:    &pgQuery.Alias{Aliasname: alias},
+			},
+		},
+	}
+}
+
+// Sequences are synced into the table's Iceberg schema:
+// nextval('public.users_id_seq'::regclass) -> nextval('users_id_seq'::regclass) in public, like Postgres renders it on the search path
+// nextval('public.users_id_seq'::regclass) -> nextval('analytics.users_id_seq'::regclass) in other schemas
+func (parser *QueryParserTable) columnDefault(columnDefault string, schema string) string {
+	sequenceName, found := strings.CutPrefix(columnDefault, "nextval('")
+	if !found {
+		return columnDefault
+	}
+	sequenceName, found = strings.CutSuffix(sequenceName, "'::regclass)")
+	if !found {
+		return columnDefault
+	}
+
+	// Keep only the sequence name, which may be quoted: "Users_id_seq" or public."Users_id_seq"
+	if strings.HasSuffix(sequenceName, `"`) {
+		if index := strings.LastIndex(sequenceName[:len(sequenceName)-1], `"`); index > 0 && sequenceName[index-1] == '.' {
+			sequenceName = sequenceName[index:]
+		}
+	} else if index := strings.LastIndex(sequenceName, "."); index != -1 {
+		sequenceName = sequenceName[index+1:]
+	}
+
+	if schema != PG_SCHEMA_PUBLIC {
+		sequenceName = parser.quoteIdentifier(schema) + "." + sequenceName
+	}
+	return "nextval('" + strings.ReplaceAll(sequenceName, "'", "''") + "'::regclass)"
+}
+
+// Quotes identifiers like Postgres does when it renders them, e.g. "My Schema"
+func (parser *QueryParserTable) quoteIdentifier(identifier string) string {
+	for _, char := range identifier {
+		if !(char >= 'a' && char <= 'z' || char >= '0' && char <= '9' || char == '_') {
+			return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+		}
+	}
+	return identifier
+}
+
+// Iceberg type -> data_type, udt_name, numeric_precision, numeric_scale in information_schema.columns
+func (parser *QueryParserTable) pgColumnType(icebergType interface{}) (dataType string, udtName string, numericPrecision string, numericScale string) {
+	numericPrecision = "NULL::INTEGER"
+	numericScale = "NULL::INTEGER"
+
+	icebergPrimitiveType, ok := icebergType.(string)
+	if !ok {
+		// list
+		elementType := ""
+		if listType, ok := icebergType.(map[string]interface{}); ok {
+			elementType, _ = listType["element"].(string)
+		}
+		_, elementUdtName, _, _ := parser.pgColumnType(elementType)
+		return "ARRAY", "_" + elementUdtName, numericPrecision, numericScale
+	}
+
+	switch {
+	case icebergPrimitiveType == "boolean":
+		return "boolean", "bool", numericPrecision, numericScale
+	case icebergPrimitiveType == "int":
+		return "integer", "int4", "32", "0"
+	case icebergPrimitiveType == "long":
+		return "bigint", "int8", "64", "0"
+	case icebergPrimitiveType == "float":
+		return "real", "float4", "24", numericScale
+	case icebergPrimitiveType == "double":
+		return "double precision", "float8", "53", numericScale
+	case strings.HasPrefix(icebergPrimitiveType, "decimal("):
+		precisionScale := strings.Split(strings.TrimSuffix(strings.TrimPrefix(icebergPrimitiveType, "decimal("), ")"), ",")
+		if len(precisionScale) == 2 {
+			numericPrecision = strings.TrimSpace(precisionScale[0])
+			numericScale = strings.TrimSpace(precisionScale[1])
+		}
+		return "numeric", "numeric", numericPrecision, numericScale
+	case icebergPrimitiveType == "date":
+		return "date", "date", numericPrecision, numericScale
+	case icebergPrimitiveType == "time":
+		return "time without time zone", "time", numericPrecision, numericScale
+	case icebergPrimitiveType == "timestamp", icebergPrimitiveType == "timestamp_ns":
+		return "timestamp without time zone", "timestamp", numericPrecision, numericScale
+	case icebergPrimitiveType == "timestamptz", icebergPrimitiveType == "timestamptz_ns":
+		return "timestamp with time zone", "timestamptz", numericPrecision, numericScale
+	case icebergPrimitiveType == "uuid":
+		return "uuid", "uuid", numericPrecision, numericScale
+	case icebergPrimitiveType == "binary":
+		return "bytea", "bytea", numericPrecision, numericScale
+	default:
+		return "text", "text", numericPrecision, numericScale
+	}
+}
+
+// System pg_* tables
+func (parser *QueryParserTable) IsTableFromPgCatalog(qSchemaTable QuerySchemaTable) bool {
+	return parser.isPgCatalogSchema(qSchemaTable) &&
+		(PG_SYSTEM_TABLES.Contains(qSchemaTable.Table) || PG_SYSTEM_VIEWS.Contains(qSchemaTable.Table))
+}
+
+// Other information_schema.* tables
+func (parser *QueryParserTable) IsTableFromInformationSchema(qSchemaTable QuerySchemaTable) bool {
+	return qSchemaTable.Schema == PG_SCHEMA_INFORMATION_SCHEMA
+}
+
+// table@ref -> table, ref
+func (parser *QueryParserTable) SplitIcebergRef(qSchemaTable QuerySchemaTable) (icebergSchemaTable IcebergSchemaTable, ref string) {
+	icebergSchemaTable = qSchemaTable.ToIcebergSchemaTable()
+
+	table, ref, found := strings.Cut(qSchemaTable.Table, ICEBERG_REF_SEPARATOR)
+	if found {
+		icebergSchemaTable.Table = table
+	}
+
+	return icebergSchemaTable, ref
+}
+
+// FROM table, JOIN table anywhere in the statement, including subqueries and CTEs
+func (parser *QueryParserTable) RangeVars(node *pgQuery.Node) (rangeVars []*pgQuery.RangeVar) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if rangeVar, ok := message.Interface().(*pgQuery.RangeVar); ok {
+			rangeVars = append(rangeVars, rangeVar)
+		}
+	})
+
+	return rangeVars
+}
+
+// current_schema() and current_schemas(bool) anywhere in the statement, including subqueries and FROM functions
+func (parser *QueryParserTable) SchemaFunctionNodes(node *pgQuery.Node) (schemaFunctionNodes []*pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if functionNode, ok := message.Interface().(*pgQuery.Node); ok && parser.SchemaFunctionName(functionNode) != "" {
+			schemaFunctionNodes = append(schemaFunctionNodes, functionNode)
+		}
+	})
+
+	return schemaFunctionNodes
+}
+
+// SELECT current_schema() -> SELECT current_schema() AS current_schema, so that the column keeps its name after remapping
+func (parser *QueryParserTable) SetSchemaFunctionTargetNames(node *pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		resTarget, ok := message.Interface().(*pgQuery.ResTarget)
+		if !ok || resTarget.Name != "" || resTarget.Val == nil {
+			return
+		}
+
+		if functionName := parser.SchemaFunctionName(resTarget.Val); functionName != "" {
+			resTarget.Name = functionName
+		}
+	})
+}
+
+// current_schema, current_schema() or pg_catalog.current_schema() -> "current_schema", current_schemas(bool) -> "current_schemas"
+func (parser *QueryParserTable) SchemaFunctionName(node *pgQuery.Node) string {
+	if node.GetSqlvalueFunction().GetOp() == pgQuery.SQLValueFunctionOp_SVFOP_CURRENT_SCHEMA {
+		return PG_FUNCTION_CURRENT_SCHEMA
+	}
+
+	functionCall := node.GetFuncCall()
+	functionName := parser.pgCatalogFunctionName(functionCall)
+	switch {
+	case functionName == PG_FUNCTION_CURRENT_SCHEMA && len(functionCall.Args) == 0:
+		return functionName
+	case functionName == PG_FUNCTION_CURRENT_SCHEMAS && len(functionCall.Args) == 1:
+		return functionName
+	}
+	return ""
+}
+
+// current_schema() -> 'analytics', or NULL if no schema in the search path exists
+// current_schemas(true) -> list_value('pg_catalog', 'analytics', 'public')
+func (parser *QueryParserTable) RemapSchemaFunction(node *pgQuery.Node, searchPathSchemas []string) {
+	if parser.SchemaFunctionName(node) == PG_FUNCTION_CURRENT_SCHEMA {
+		if len(searchPathSchemas) == 0 {
+			node.Node = &pgQuery.Node_AConst{AConst: &pgQuery.A_Const{Isnull: true}}
+		} else {
+			node.Node = pgQuery.MakeAConstStrNode(searchPathSchemas[0], 0).Node
+		}
+		return
+	}
+
+	// pg_catalog is searched first unless it's in the search path, and only listed with implicit schemas
+	includeImplicit := node.GetFuncCall().Args[0].GetAConst().GetBoolval().GetBoolval()
+	if includeImplicit && !slices.Contains(searchPathSchemas, PG_SCHEMA_PG_CATALOG) {
+		searchPathSchemas = append([]string{PG_SCHEMA_PG_CATALOG}, searchPathSchemas...)
+	}
+
+	var elementNodes []*pgQuery.Node
+	for _, schema := range searchPathSchemas {
+		elementNodes = append(elementNodes, pgQuery.MakeAConstStrNode(schema, 0))
+	}
+	node.Node = pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode("list_value")}, elementNodes, 0).Node
+}
+
+// version() and current_setting('name'[, missing_ok]) anywhere in the statement, including subqueries and FROM functions
+func (parser *QueryParserTable) SettingFunctionNodes(node *pgQuery.Node) (settingFunctionNodes []*pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if functionNode, ok := message.Interface().(*pgQuery.Node); ok && (parser.IsVersionFunction(functionNode) || parser.IsCurrentSettingFunction(functionNode)) {
+			settingFunctionNodes = append(settingFunctionNodes, functionNode)
+		}
+	})
+
+	return settingFunctionNodes
+}
+
+// SELECT version() -> SELECT version() AS version, so that the column keeps its name after remapping
+func (parser *QueryParserTable) SetSettingFunctionTargetNames(node *pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		resTarget, ok := message.Interface().(*pgQuery.ResTarget)
+		if !ok || resTarget.Name != "" || resTarget.Val == nil {
+			return
+		}
+
+		if parser.IsVersionFunction(resTarget.Val) || parser.IsCurrentSettingFunction(resTarget.Val) {
+			resTarget.Name = parser.pgCatalogFunctionName(resTarget.Val.GetFuncCall())
+		}
+	})
+}
+
+// version() or pg_catalog.version()
+func (parser *QueryParserTable) IsVersionFunction(node *pgQuery.Node) bool {
+	functionCall := node.GetFuncCall()
+	return parser.pgCatalogFunctionName(functionCall) == PG_FUNCTION_VERSION && len(functionCall.Args) == 0
+}
+
+// version() -> 'PostgreSQL 17.0 (BemiDB x.y.z), compiled by Bemi' with the configured server version
+func (parser *QueryParserTable) MakeVersionNode() *pgQuery.Node {
+	return pgQuery.MakeAConstStrNode("PostgreSQL "+parser.config.Server.Version+" (BemiDB "+VERSION+"), compiled by Bemi", 0)
+}
+
+// current_setting('name') or current_setting('name', true) with constant arguments.
+// Other calls are left to DuckDB's current_setting() of its own settings
+func (parser *QueryParserTable) IsCurrentSettingFunction(node *pgQuery.Node) bool {
+	functionCall := node.GetFuncCall()
+	if parser.pgCatalogFunctionName(functionCall) != PG_FUNCTION_CURRENT_SETTING || len(functionCall.Args) == 0 || len(functionCall.Args) > 2 {
+		return false
+	}
+	if functionCall.Args[0].GetAConst().GetSval() == nil {
+		return false
+	}
+	return len(functionCall.Args) == 1 || functionCall.Args[1].GetAConst().GetBoolval() != nil
+}
+
+// current_setting('Search_Path', true) -> "search_path", true
+func (parser *QueryParserTable) CurrentSettingFunctionArgs(node *pgQuery.Node) (name string, missingOk bool) {
+	functionCall := node.GetFuncCall()
+	name = strings.ToLower(functionCall.Args[0].GetAConst().GetSval().GetSval())
+	if len(functionCall.Args) == 2 {
+		missingOk = functionCall.Args[1].GetAConst().GetBoolval().GetBoolval()
+	}
+	return name, missingOk
+}
+
+// current_setting('search_path') -> '"$user", public'
+func (parser *QueryParserTable) RemapCurrentSettingFunction(node *pgQuery.Node, value string) {
+	node.Node = pgQuery.MakeAConstStrNode(value, 0).Node
+}
+
+// current_setting('threads', true) -> (SELECT value FROM duckdb_settings() WHERE lower(name) = 'threads'), NULL if DuckDB doesn't know the setting either
+func (parser *QueryParserTable) RemapMissingOkCurrentSettingFunction(node *pgQuery.Node, name string) {
+	queryTree, err := pgQuery.Parse("SELECT value FROM duckdb_settings() WHERE lower(name) = " + parser.quoteString(name))
+	PanicIfError(err)
+
+	node.Node = &pgQuery.Node_SubLink{
+		SubLink: &pgQuery.SubLink{
+			SubLinkType: pgQuery.SubLinkType_EXPR_SUBLINK,
+			Subselect:   queryTree.Stmts[0].Stmt,
+		},
+	}
+}
+
+// name() or pg_catalog.name() -> "name"
+func (parser *QueryParserTable) pgCatalogFunctionName(functionCall *pgQuery.FuncCall) string {
+	if functionCall == nil || len(functionCall.Funcname) == 0 || len(functionCall.Funcname) > 2 {
+		return ""
+	}
+	if len(functionCall.Funcname) == 2 && functionCall.Funcname[0].GetString_().GetSval() != PG_SCHEMA_PG_CATALOG {
+		return ""
+	}
+
+	return functionCall.Funcname[len(functionCall.Funcname)-1].GetString_().GetSval()
+}
+
+// WITH name AS (...) anywhere in the statement
+func (parser *QueryParserTable) CommonTableExpressionNames(node *pgQuery.Node) *Set {
+	names := NewSet([]string{})
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if cte, ok := message.Interface().(*pgQuery.CommonTableExpr); ok {
+			names.Add(cte.Ctename)
+		}
+	})
+
+	return names
+}
+
+// iceberg.table -> FROM iceberg_scan('path', skip_schema_inference = true)
+// iceberg.table@ref -> FROM iceberg_scan('path', snapshot_id::UBIGINT, skip_schema_inference = true)
+// iceberg.table with enum columns -> FROM (SELECT id, mood::enum('sad', 'happy') AS mood FROM iceberg_scan(...))
+func (parser *QueryParserTable) MakeIcebergTableNode(tablePath string, snapshotId int64, icebergSchemaFields []IcebergSchemaField, qSchemaTable QuerySchemaTable) *pgQuery.Node {
+	args := []*pgQuery.Node{pgQuery.MakeAConstStrNode(tablePath, 0)}
+	if snapshotId != 0 {
+		args = append(args, NewQueryParserType(parser.config).MakeTypeCastNode(
+			pgQuery.MakeAConstStrNode(strconv.FormatInt(snapshotId, 10), 0),
+			"ubigint",
+		))
+	}
+	args = append(args, pgQuery.MakeAExprNode(
+		pgQuery.A_Expr_Kind_AEXPR_OP,
+		[]*pgQuery.Node{pgQuery.MakeStrNode("=")},
+		pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("skip_schema_inference")}, 0),
+		parser.utils.MakeAConstBoolNode(true),
+		0,
+	))
+
+	node := pgQuery.MakeSimpleRangeFunctionNode([]*pgQuery.Node{
+		pgQuery.MakeListNode([]*pgQuery.Node{
+			pgQuery.MakeFuncCallNode(
+				[]*pgQuery.Node{
+					pgQuery.MakeStrNode("iceberg_scan"),
+				},
+				args,
+				0,
+			),
+		}),
+	})
+
+	// DuckDB doesn't support aliases on iceberg_scan() functions, so we need to wrap it in a nested select that can have an alias
+	selectStarNode := pgQuery.MakeResTargetNodeWithVal(
+		pgQuery.MakeColumnRefNode(
+			[]*pgQuery.Node{pgQuery.MakeAStarNode()},
+			0,
+		),
+		0,
+	)
+	targetList := []*pgQuery.Node{selectStarNode}
+	if parser.hasEnumFields(icebergSchemaFields) {
+		targetList = parser.makeEnumTargetList(icebergSchemaFields)
+	}
+	return parser.utils.MakeSubselectFromNode(qSchemaTable.Table, targetList, node, qSchemaTable.Alias)
+}
+
+func (parser *QueryParserTable) hasEnumFields(icebergSchemaFields []IcebergSchemaField) bool {
+	for _, icebergSchemaField := range icebergSchemaFields {
+		if len(icebergSchemaField.EnumLabels) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Enums are stored as text, casting them to DuckDB enums makes ORDER BY, min() and max() follow the declared order
+func (parser *QueryParserTable) makeEnumTargetList(icebergSchemaFields []IcebergSchemaField) []*pgQuery.Node {
+	parserEnum := NewQueryParserEnum(parser.config)
+
+	var targetList []*pgQuery.Node
+	for _, icebergSchemaField := range icebergSchemaFields {
+		columnNode := pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode(icebergSchemaField.Name)}, 0)
+		if len(icebergSchemaField.EnumLabels) > 0 {
+			columnNode = parserEnum.MakeEnumTypeCastNode(columnNode, icebergSchemaField.EnumLabels)
+		}
+		targetList = append(targetList, pgQuery.MakeResTargetNodeWithNameAndVal(icebergSchemaField.Name, columnNode, 0))
+	}
+	return targetList
+}
+
+// pg_catalog.PG_FUNCTION() -> PG_FUNCTION()
+func (parser *QueryParserTable) RemovePgCatalogSchemaFromTableFunctions(node *pgQuery.Node) {
+	for _, funcNode := range node.GetRangeFunction().Functions {
+		for _, funcItemNode := range funcNode.GetList().Items {
+			funcCallNode := funcItemNode.GetFuncCall()
+			if funcCallNode == nil || len(funcCallNode.Funcname) != 2 {
+				continue
+			}
+
+			schema := funcCallNode.Funcname[0].GetString_().Sval
+			if schema == PG_SCHEMA_PG_CATALOG {
+				funcCallNode.Funcname = funcCallNode.Funcname[1:]
+			}
+		}
+	}
+}
+
+// pg_get_keywords()
+func (parser *QueryParserTable) IsPgGetKeywordsFunction(node *pgQuery.Node) bool {
+	for _, funcNode := range node.GetRangeFunction().Functions {
+		for _, funcItemNode := range funcNode.GetList().Items {
+			funcCallNode := funcItemNode.GetFuncCall()
+			if funcCallNode == nil {
+				continue
+			}
+			if len(funcCallNode.Funcname) != 1 {
+				continue
+			}
+
+			function := funcCallNode.Funcname[0].GetString_().Sval
+			if function == PG_FUNCTION_PG_GET_KEYWORDS {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pg_get_keywords() -> VALUES(values...) t(columns...)
+func (parser *QueryParserTable) MakePgGetKeywordsNode(node *pgQuery.Node) *pgQuery.Node {
+	columns := []string{"word", "catcode", "barelabel", "catdesc", "baredesc"}
+
+	var rows [][]string
+	for _, kw := range DUCKDB_KEYWORDS {
+		catcode := "U"
+		catdesc := "unreserved"
+
+		switch kw.category {
+		case "reserved":
+			catcode = "R"
+			catdesc = "reserved"
+		case "type_function":
+			catcode = "T"
+			catdesc = "reserved (can be function or type name)"
+		case "column_name":
+			catcode = "C"
+			catdesc = "unreserved (cannot be function or type name)"
+		}
+
+		row := []string{
+			kw.word,
+			catcode,
+			"t",
+			catdesc,
+			"can be bare label",
+		}
+		rows = append(rows, row)
+	}
+
+	var alias string
+	if node.GetAlias() != nil {
+		alias = node.GetAlias().Aliasname
+	}
+
+	return parser.utils.MakeSubselectWithRowsNode(PG_FUNCTION_PG_GET_KEYWORDS, columns, rows, alias)
+}
+
+// array_upper(array, 1)
+func (parser *QueryParserTable) IsArrayUpperFunction(funcCallNode *pgQuery.FuncCall) bool {
+	if len(funcCallNode.Funcname) != 1 {
+		return false
+	}
+
+	funcName := funcCallNode.Funcname[0].GetString_().Sval
+
+	if funcName == PG_FUNCTION_ARRAY_UPPER {
+		dimension := funcCallNode.Args[1].GetAConst().GetIval().Ival
+		if dimension == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// array_upper(array, 1) -> len(array)
+func (parser *QueryParserTable) MakeArrayUpperNode(funcCallNode *pgQuery.FuncCall) *pgQuery.FuncCall {
+	return pgQuery.MakeFuncCallNode(
+		[]*pgQuery.Node{
+			pgQuery.MakeStrNode("len"),
+		},
+		[]*pgQuery.Node{
+			funcCallNode.Args[0],
+		},
+		0,
+	).GetFuncCall()
+}
+
+// pg_show_all_settings()
+func (parser *QueryParserTable) IsPgShowAllSettingsFunction(node *pgQuery.Node) bool {
+	for _, funcNode := range node.GetRangeFunction().Functions {
+		for _, funcItemNode := range funcNode.GetList().Items {
+			funcCallNode := funcItemNode.GetFuncCall()
+			if funcCallNode == nil {
+				continue
+			}
+			if len(funcCallNode.Funcname) != 1 {
+				continue
+			}
+
+			function := funcCallNode.Funcname[0].GetString_().Sval
+			if function == PG_FUNCTION_PG_SHOW_ALL_SETTINGS {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pg_show_all_settings() -> duckdb_settings() mapped to pg format
+func (parser *QueryParserTable) MakePgShowAllSettingsNode(node *pgQuery.Node) *pgQuery.Node {
+	targetList := []*pgQuery.Node{
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"name",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("name")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"setting",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("value")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"unit",
+			pgQuery.MakeAConstStrNode("", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"category",
+			pgQuery.MakeAConstStrNode("Settings", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"short_desc",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("description")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"extra_desc",
+			pgQuery.MakeAConstStrNode("", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"context",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("scope")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"vartype",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("input_type")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"source",
+			pgQuery.MakeAConstStrNode("default", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"min_val",
+			pgQuery.MakeAConstStrNode("", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"max_val",
+			pgQuery.MakeAConstStrNode("", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"enumvals",
+			pgQuery.MakeAConstStrNode("", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"boot_val",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("value")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"reset_val",
+			pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode("value")}, 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"sourcefile",
+			pgQuery.MakeAConstStrNode("", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"sourceline",
+			pgQuery.MakeAConstStrNode("0", 0),
+			0,
+		),
+		pgQuery.MakeResTargetNodeWithNameAndVal(
+			"pending_restart",
+			pgQuery.MakeAConstStrNode("f", 0),
+			0,
+		),
+	}
+	fromNode := pgQuery.MakeSimpleRangeFunctionNode([]*pgQuery.Node{
+		pgQuery.MakeListNode([]*pgQuery.Node{
+			pgQuery.MakeFuncCallNode(
+				[]*pgQuery.Node{
+					pgQuery.MakeStrNode("duckdb_settings"),
+				},
+				nil,
+				0,
+			),
+		}),
+	})
+
+	var alias string
+	if node.GetAlias() != nil {
+		alias = node.GetAlias().Aliasname
+	}
+
+	return parser.utils.MakeSubselectFromNode(PG_FUNCTION_PG_SHOW_ALL_SETTINGS, targetList, fromNode, alias)
+}
+
+// pg_is_in_recovery()
+func (parser *QueryParserTable) IsPgIsInRecoveryFunction(node *pgQuery.Node) bool {
+	for _, funcNode := range node.GetRangeFunction().Functions {
+		for _, funcItemNode := range funcNode.GetList().Items {
+			funcCallNode := funcItemNode.GetFuncCall()
+			if funcCallNode == nil {
+				continue
+			}
+
+			if len(funcCallNode.Funcname) != 1 {
+				continue
+			}
+
+			function := funcCallNode.Funcname[0].GetString_().Sval
+			if function == PG_FUNCTION_PG_IS_IN_RECOVERY {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pg_is_in_recovery() -> 'f'::bool
+func (parser *QueryParserTable) MakePgIsInRecoveryNode(node *pgQuery.Node) *pgQuery.Node {
+	var alias string
+	if node.GetAlias() != nil {
+		alias = node.GetAlias().Aliasname
+	}
+
+	return parser.utils.MakeSubselectWithRowsNode(
+		PG_FUNCTION_PG_IS_IN_RECOVERY,
+		[]string{"pg_is_in_recovery"},
+		[][]string{{"f"}},
+		alias,
+	)
+}
+
+// bemidb.audit
+func (parser *QueryParserTable) IsAuditTable(qSchemaTable QuerySchemaTable) bool {
+	return qSchemaTable.Schema == BEMIDB_SCHEMA && qSchemaTable.Table == BEMIDB_TABLE_AUDIT
+}
+
+// bemidb.audit -> (SELECT schema::varchar AS schema, ... FROM read_json('audit/*.json')) audit
+func (parser *QueryParserTable) MakeAuditTableNode(auditRecordsPath string, alias string) *pgQuery.Node {
+	if auditRecordsPath == "" {
+		return parser.MakeEmptyTableNode(BEMIDB_TABLE_AUDIT, AUDIT_COLUMN_TYPES.Keys(), alias)
+	}
+
+	parserType := NewQueryParserType(parser.config)
+	var targetList []*pgQuery.Node
+	for _, column := range AUDIT_COLUMN_TYPES.Keys() {
+		targetList = append(targetList, pgQuery.MakeResTargetNodeWithNameAndVal(
+			column,
+			parserType.MakeTypeCastNode(pgQuery.MakeColumnRefNode([]*pgQuery.Node{pgQuery.MakeStrNode(column)}, 0), AUDIT_COLUMN_TYPES.Get(column)),
+			0,
+		))
+	}
+
+	fromNode := pgQuery.MakeSimpleRangeFunctionNode([]*pgQuery.Node{
+		pgQuery.MakeListNode([]*pgQuery.Node{
+			pgQuery.MakeFuncCallNode(
+				[]*pgQuery.Node{pgQuery.MakeStrNode("read_json")},
+				[]*pgQuery.Node{pgQuery.MakeAConstStrNode(auditRecordsPath, 0)},
+				0,
+			),
+		}),
+	})
+	return parser.utils.MakeSubselectFromNode(BEMIDB_TABLE_AUDIT, targetList, fromNode, alias)
+}
+
+// table.files -> (SELECT file_path::varchar AS file_path, ... FROM (VALUES(values...)) files(columns...)) files
+func (parser *QueryParserTable) MakeIcebergFilesNode(icebergDataFiles []IcebergDataFile, alias string) *pgQuery.Node {
+	if len(icebergDataFiles) == 0 {
+		return parser.MakeEmptyTableNode(ICEBERG_TABLE_FILES, ICEBERG_FILES_COLUMN_TYPES.Keys(), alias)
+	}
+
+	var rowsSql []string
+	for _, icebergDataFile := range icebergDataFiles {
+		rowsSql = append(rowsSql, "("+strings.Join([]string{
+			parser.quoteString(icebergDataFile.Path),
+			parser.quoteString(icebergDataFile.Format),
+			parser.quoteString(icebergDataFile.Partition),
+			strconv.FormatInt(icebergDataFile.RecordCount, 10),
+			strconv.FormatInt(icebergDataFile.Size, 10),
+		}, ", ")+")")
+	}
+
+	return parser.makeTypedValuesNode(ICEBERG_TABLE_FILES, ICEBERG_FILES_COLUMN_TYPES, rowsSql, alias)
+}
+
+// table.snapshots -> (SELECT committed_at::timestamptz AS committed_at, ... FROM (VALUES(values...)) snapshots(columns...)) snapshots
+func (parser *QueryParserTable) MakeIcebergSnapshotsNode(icebergSnapshots []IcebergSnapshot, alias string) *pgQuery.Node {
+	if len(icebergSnapshots) == 0 {
+		return parser.MakeEmptyTableNode(ICEBERG_TABLE_SNAPSHOTS, ICEBERG_SNAPSHOTS_COLUMN_TYPES.Keys(), alias)
+	}
+
+	var rowsSql []string
+	for _, icebergSnapshot := range icebergSnapshots {
+		parentId := "NULL"
+		if icebergSnapshot.ParentSnapshotId != 0 {
+			parentId = strconv.FormatInt(icebergSnapshot.ParentSnapshotId, 10)
+		}
+		summary, err := json.Marshal(icebergSnapshot.Summary)
+		PanicIfError(err)
+
+		rowsSql = append(rowsSql, "("+strings.Join([]string{
+			parser.quoteString(time.UnixMilli(icebergSnapshot.TimestampMs).UTC().Format(time.RFC3339Nano)),
+			strconv.FormatInt(icebergSnapshot.SnapshotId, 10),
+			parentId,
+			parser.quoteString(icebergSnapshot.Summary["operation"]),
+			parser.quoteString(icebergSnapshot.ManifestList),
+			parser.quoteString(string(summary)),
+		}, ", ")+")")
+	}
+
+	return parser.makeTypedValuesNode(ICEBERG_TABLE_SNAPSHOTS, ICEBERG_SNAPSHOTS_COLUMN_TYPES, rowsSql, alias)
+}
+
+func (parser *QueryParserTable) makeTypedValuesNode(tableName string, columnTypes *OrderedMap, rowsSql []string, alias string) *pgQuery.Node {
+	var targetsSql []string
+	for _, column := range columnTypes.Keys() {
+		targetsSql = append(targetsSql, column+"::"+columnTypes.Get(column)+" AS "+column)
+	}
+
+	query := "SELECT " + strings.Join(targetsSql, ", ") + " FROM (VALUES " + strings.Join(rowsSql, ", ") + ") " + tableName + "(" + strings.Join(columnTypes.Keys(), ", ") + ")"
+	queryTree, err := pgQuery.Parse(query)
+	PanicIfError(err)
+
+	if alias == "" {
+		alias = tableName
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Subquery: queryTree.Stmts[0].Stmt,
+				Alias:    &pgQuery.Alias{Aliasname: alias},
+			},
+		},
+	}
+}
+
+// unnest(array1, array2, ...)
+func (parser *QueryParserTable) IsUnnestMultipleArraysFunction(node *pgQuery.Node) bool {
+	rangeFunction := node.GetRangeFunction()
+	if rangeFunction.Ordinality || len(rangeFunction.Functions) != 1 {
+		return false
+	}
+
+	funcCallNode := rangeFunction.Functions[0].GetList().Items[0].GetFuncCall()
+	if funcCallNode == nil || len(funcCallNode.Funcname) != 1 {
+		return false
+	}
+
+	return funcCallNode.Funcname[0].GetString_().Sval == PG_FUNCTION_UNNEST && len(funcCallNode.Args) > 1
+}
+
+// DuckDB doesn't accept multiple arrays in unnest(), but pads shorter lists with NULLs when unnesting them in the same SELECT:
+// unnest(array1, array2) AS t(a, b) -> (SELECT unnest(array1) AS a, unnest(array2) AS b) t
+func (parser *QueryParserTable) MakeUnnestMultipleArraysNode(node *pgQuery.Node) *pgQuery.Node {
+	rangeFunction := node.GetRangeFunction()
+	funcCallNode := rangeFunction.Functions[0].GetList().Items[0].GetFuncCall()
+
+	alias := PG_FUNCTION_UNNEST
+	var columnNames []string
+	if rangeFunction.Alias != nil {
+		alias = rangeFunction.Alias.Aliasname
+		for _, colnameNode := range rangeFunction.Alias.Colnames {
+			columnNames = append(columnNames, colnameNode.GetString_().Sval)
+		}
+	}
+
+	targetList := make([]*pgQuery.Node, len(funcCallNode.Args))
+	for i, argNode := range funcCallNode.Args {
+		columnName := PG_FUNCTION_UNNEST
+		if i < len(columnNames) {
+			columnName = columnNames[i]
+		}
+		targetList[i] = pgQuery.MakeResTargetNodeWithNameAndVal(
+			columnName,
+			pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode(PG_FUNCTION_UNNEST)}, []*pgQuery.Node{argNode}, 0),
+			0,
+		)
+	}
+
+	return &pgQuery.Node{
+		Node: &pgQuery.Node_RangeSubselect{
+			RangeSubselect: &pgQuery.RangeSubselect{
+				Lateral: rangeFunction.Lateral,
+				Subquery: &pgQuery.Node{
+					Node: &pgQuery.Node_SelectStmt{
+						SelectStmt: &pgQuery.SelectStmt{
+							TargetList: targetList,
+						},
+					},
+				},
+				Alias: &pgQuery.Alias{
+					Aliasname: alias,
+				},
+			},
+		},
+	}
+}
+
+// Postgres names primary key constraints "table_pkey" by default
+func (parser *QueryParserTable) primaryKeyConstraintName(primaryKey PrimaryKey) string {
+	return primaryKey.SchemaTable.Table + "_pkey"
+}
+
+func (parser *QueryParserTable) isPgCatalogSchema(qSchemaTable QuerySchemaTable) bool {
+	return qSchemaTable.Schema == PG_SCHEMA_PG_CATALOG || qSchemaTable.Schema == ""
+}
+
+var PG_SYSTEM_TABLES = NewSet([]string{
+	"pg_aggregate",
+	"pg_am",
+	"pg_amop",
+	"pg_amproc",
+	"pg_attrdef",
+	"pg_attribute",
+	"pg_auth_members",
+	"pg_authid",
+	"pg_cast",
+	"pg_class",
+	"pg_collation",
+	"pg_constraint",
+	"pg_conversion",
+	"pg_database",
+	"pg_db_role_setting",
+	"pg_default_acl",
+	"pg_depend",
+	"pg_description",
+	"pg_enum",
+	"pg_event_trigger",
+	"pg_extension",
+	"pg_foreign_data_wrapper",
+	"pg_foreign_server",
+	"pg_foreign_table",
+	"pg_index",
+	"pg_inherits",
+	"pg_init_privs",
+	"pg_language",
+	"pg_largeobject",
+	"pg_largeobject_metadata",
+	"pg_namespace",
+	"pg_opclass",
+	"pg_operator",
+	"pg_opfamily",
+	"pg_parameter_acl",
+	"pg_partitioned_table",
+	"pg_policy",
+	"pg_proc",
+	"pg_publication",
+	"pg_publication_namespace",
+	"pg_publication_rel",
+	"pg_user",
+	"pg_range",
+	"pg_replication_origin",
+	"pg_replication_slots",
+	"pg_rewrite",
+	"pg_roles",
+	"pg_seclabel",
+	"pg_sequence",
+	"pg_shadow",
+	"pg_shdepend",
+	"pg_shdescription",
+	"pg_shseclabel",
+	"pg_statistic",
+	"pg_statistic_ext",
+	"pg_statistic_ext_data",
+	"pg_subscription",
+	"pg_subscription_rel",
+	"pg_tablespace",
+	"pg_transform",
+	"pg_trigger",
+	"pg_ts_config",
+	"pg_ts_config_map",
+	"pg_ts_dict",
+	"pg_ts_parser",
+	"pg_ts_template",
+	"pg_type",
+	"pg_user_mapping",
+})
+
+var PG_SYSTEM_VIEWS = NewSet([]string{
+	"pg_settings",
+	"pg_stat_activity",
+	"pg_stat_replication",
+	"pg_stat_wal_receiver",
+	"pg_stat_recovery_prefetch",
+	"pg_stat_subscription",
+	"pg_stat_ssl",
+	"pg_stat_gssapi",
+	"pg_stat_progress_analyze",
+	"pg_stat_progress_create_index",
+	"pg_stat_progress_vacuum",
+	"pg_stat_progress_cluster",
+	"pg_stat_progress_basebackup",
+	"pg_stat_progress_copy",
+	"pg_stat_archiver",
+	"pg_stat_bgwriter",
+	"pg_stat_checkpointer",
+	"pg_stat_database",
+	"pg_stat_database_conflicts",
+	"pg_stat_io",
+	"pg_stat_replication_slots",
+	"pg_stat_slru",
+	"pg_stat_subscription_stats",
+	"pg_stat_wal",
+	"pg_stat_all_tables",
+	"pg_stat_sys_tables",
+	"pg_stat_user_tables",
+	"pg_stat_xact_all_tables",
+	"pg_stat_xact_sys_tables",
+	"pg_stat_xact_user_tables",
+	"pg_stat_all_indexes",
+	"pg_stat_sys_indexes",
+	"pg_stat_user_indexes",
+	"pg_stat_user_functions",
+	"pg_stat_xact_user_functions",
+	"pg_statio_all_tables",
+	"pg_statio_sys_tables",
+	"pg_statio_user_tables",
+	"pg_statio_all_indexes",
+	"pg_statio_sys_indexes",
+	"pg_statio_user_indexes",
+	"pg_statio_all_sequences",
+	"pg_statio_sys_sequences",
+	"pg_statio_user_sequences",
+})
+
+var PG_SHADOW_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"usename", "bemidb"},
+	{"usesysid", "10"},
+	{"usecreatedb", "FALSE"},
+	{"usesuper", "FALSE"},
+	{"userepl", "TRUE"},
+	{"usebypassrls", "FALSE"},
+	{"passwd", ""},
+	{"valuntil", "NULL"},
+	{"useconfig", "NULL"},
+})
+
+var PG_ROLES_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"oid", "10"},
+	{"rolname", ""},
+	{"rolsuper", "true"},
+	{"rolinherit", "true"},
+	{"rolcreaterole", "true"},
+	{"rolcreatedb", "true"},
+	{"rolcanlogin", "true"},
+	{"rolreplication", "false"},
+	{"rolconnlimit", "-1"},
+	{"rolpassword", "NULL"},
+	{"rolvaliduntil", "NULL"},
+	{"rolbypassrls", "false"},
+	{"rolconfig", "NULL"},
+})
+
+// Columns of DuckDB's pg_catalog.pg_class, in order
+var PG_CLASS_COLUMNS = []string{
+	"oid", "relname", "relnamespace", "reltype", "reloftype", "relowner", "relam", "relfilenode", "reltablespace", "relpages",
+	"reltuples", "relallvisible", "reltoastrelid", "reltoastidxid", "relhasindex", "relisshared", "relpersistence", "relkind",
+	"relnatts", "relchecks", "relhasoids", "relhaspkey", "relhasrules", "relhastriggers", "relhassubclass", "relrowsecurity",
+	"relispopulated", "relreplident", "relispartition", "relrewrite", "relfrozenxid", "relminmxid", "relacl", "reloptions", "relpartbound",
+}
+
+// Columns of DuckDB's pg_catalog.pg_attribute in order, with the values of Iceberg columns
+var PG_ATTRIBUTE_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"attrelid", "duckdb_tables.table_oid"},
+	{"attname", "iceberg_columns.attname"},
+	{"atttypid", "iceberg_columns.atttypid"},
+	{"attstattarget", "0"},
+	{"attlen", "NULL"},
+	{"attnum", "iceberg_columns.attnum"},
+	{"attndims", "iceberg_columns.attndims"},
+	{"attcacheoff", "-1"},
+	{"atttypmod", "iceberg_columns.atttypmod"},
+	{"attbyval", "false"},
+	{"attstorage", "NULL"},
+	{"attalign", "NULL"},
+	{"attnotnull", "iceberg_columns.attnotnull"},
+	{"atthasdef", "iceberg_columns.atthasdef"},
+	{"atthasmissing", "false"},
+	{"attidentity", "''"},
+	{"attgenerated", "''"},
+	{"attisdropped", "false"},
+	{"attislocal", "true"},
+	{"attinhcount", "0"},
+	{"attcollation", "0"},
+	{"attcompression", "NULL"},
+	{"attacl", "NULL"},
+	{"attoptions", "NULL"},
+	{"attfdwoptions", "NULL"},
+	{"attmissingval", "NULL"},
+})
+
+var PG_EXTENSION_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"oid", "13823"},
+	{"extname", "plpgsql"},
+	{"extowner", "10"},
+	{"extnamespace", "11"},
+	{"extrelocatable", "false"},
+	{"extversion", "1.0"},
+	{"extconfig", "NULL"},
+	{"extcondition", "NULL"},
+})
+
+var PG_DATABASE_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"oid", "1146"},
+	{"datname", "bemidb"},
+	{"datdba", "10"},
+	{"encoding", "6"},
+	{"datlocprovider", "c"},
+	{"datistemplate", "FALSE"},
+	{"datallowconn", "TRUE"},
+	{"datconnlimit", "-1"},
+	{"datfrozenxid", "722"},
+	{"datminmxid", "1"},
+	{"dattablespace", "1663"},
+	{"datcollate", "en_US.UTF-8"},
+	{"datctype", "en_US.UTF-8"},
+	{"daticulocale", "NULL"},
+	{"daticurules", "NULL"},
+	{"datcollversion", "NULL"},
+	{"datacl", "NULL"},
+})
+
+var PG_TABLE_CONSTRAINTS_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"constraint_catalog", "bemidb"},
+	{"constraint_schema", "public"},
+	{"constraint_name", ""},
+	{"table_catalog", "bemidb"},
+	{"table_schema", "public"},
+	{"table_name", ""},
+	{"constraint_type", "PRIMARY KEY"},
+	{"is_deferrable", "NO"},
+	{"initially_deferred", "NO"},
+	{"enforced", "YES"},
+	{"nulls_distinct", "NULL"},
+})
+
+var PG_KEY_COLUMN_USAGE_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"constraint_catalog", "bemidb"},
+	{"constraint_schema", "public"},
+	{"constraint_name", ""},
+	{"table_catalog", "bemidb"},
+	{"table_schema", "public"},
+	{"table_name", ""},
+	{"column_name", ""},
+	{"ordinal_position", "1"},
+	{"position_in_unique_constraint", "NULL"},
+})
+
+var PG_USER_VALUE_BY_COLUMN = NewOrderedMap([][]string{
+	{"usename", "bemidb"},
+	{"usesysid", "10"},
+	{"usecreatedb", "t"},
+	{"usesuper", "t"},
+	{"userepl", "t"},
+	{"usebypassrls", "t"},
+	{"passwd", ""},
+	{"valuntil", "NULL"},
+	{"useconfig", "NULL"},
+})
+
+var PG_COLUMNS_COLUMNS = []string{
+	"table_catalog", "table_schema", "table_name", "column_name", "ordinal_position", "column_default", "is_nullable", "data_type",
+	"character_maximum_length", "numeric_precision", "numeric_scale", "datetime_precision", "udt_catalog", "udt_schema", "udt_name",
+	"is_identity", "is_generated", "is_updatable",
+}
+
+// Column names and DuckDB types of the table.files metadata table, in order
+var ICEBERG_FILES_COLUMN_TYPES = NewOrderedMap([][]string{
+	{"file_path", "varchar"},
+	{"file_format", "varchar"},
+	{"partition", "varchar"},
+	{"record_count", "bigint"},
+	{"file_size_in_bytes", "bigint"},
+})
+
+// Column names and DuckDB types of the table.snapshots metadata table, in order
+var ICEBERG_SNAPSHOTS_COLUMN_TYPES = NewOrderedMap([][]string{
+	{"committed_at", "timestamptz"},
+	{"snapshot_id", "bigint"},
+	{"parent_id", "bigint"},
+	{"operation", "varchar"},
+	{"manifest_list", "varchar"},
+	{"summary", "varchar"},
+})
+
+var PG_SETTINGS_COLUMNS = []string{"name", "setting", "unit", "category", "short_desc", "context", "vartype", "source", "min_val", "max_val"}
+
+// Server setting reported by SHOW and pg_catalog.pg_settings
+type PgServerSetting struct {
+	Name      string
+	Setting   string
+	Category  string
+	ShortDesc string
+	MaxVal    string
+}
+
+// Limits that clients read to size their connection pools.
+// BemiDB reserves no connection slots and doesn't support prepared transactions or replication
+func PgServerSettings(config *Config) []PgServerSetting {
+	return []PgServerSetting{
+		{Name: "max_connections", Setting: IntToString(config.Server.MaxConnections), Category: "Connections and Authentication / Connection Settings", ShortDesc: "Sets the maximum number of concurrent connections.", MaxVal: "262143"},
+		{Name: "superuser_reserved_connections", Setting: "0", Category: "Connections and Authentication / Connection Settings", ShortDesc: "Sets the number of connection slots reserved for superusers.", MaxVal: "262143"},
+		{Name: "reserved_connections", Setting: "0", Category: "Connections and Authentication / Connection Settings", ShortDesc: "Sets the number of connection slots reserved for roles with privileges of pg_use_reserved_connections.", MaxVal: "262143"},
+		{Name: "max_prepared_transactions", Setting: "0", Category: "Resource Usage / Memory", ShortDesc: "Sets the maximum number of simultaneously prepared transactions.", MaxVal: "262143"},
+		{Name: "max_wal_senders", Setting: "0", Category: "Replication / Sending Servers", ShortDesc: "Sets the maximum number of simultaneously running WAL sender processes.", MaxVal: "262143"},
+		{Name: "max_replication_slots", Setting: "0", Category: "Replication / Sending Servers", ShortDesc: "Sets the maximum number of simultaneously defined replication slots.", MaxVal: "262143"},
+	}
+}
+
+type DuckDBKeyword struct {
+	word     string
+	category string
+}
+
+var DUCKDB_KEYWORDS = []DuckDBKeyword{
+	{"abort", "unreserved"},
+	{"absolute", "unreserved"},
+	{"access", "unreserved"},
+	{"action", "unreserved"},
+	{"add", "unreserved"},
+	{"admin", "unreserved"},
+	{"after", "unreserved"},
+	{"aggregate", "unreserved"},
+	{"all", "reserved"},
+	{"also", "unreserved"},
+	{"alter", "unreserved"},
+	{"always", "unreserved"},
+	{"analyse", "reserved"},
+	{"analyze", "reserved"},
+	{"and", "reserved"},
+	{"anti", "type_function"},
+	{"any", "reserved"},
+	{"array", "reserved"},
+	{"as", "reserved"},
+	{"asc", "reserved"},
+	{"asof", "type_function"},
+	{"assertion", "unreserved"},
+	{"assignment", "unreserved"},
+	{"asymmetric", "reserved"},
+	{"at", "unreserved"},
+	{"attach", "unreserved"},
+	{"attribute", "unreserved"},
+	{"authorization", "type_function"},
+	{"backward", "unreserved"},
+	{"before", "unreserved"},
+	{"begin", "unreserved"},
+	{"between", "column_name"},
+	{"bigint", "column_name"},
+	{"binary", "type_function"},
+	{"bit", "column_name"},
+	{"boolean", "column_name"},
+	{"both", "reserved"},
+	{"by", "unreserved"},
+	{"cache", "unreserved"},
+	{"call", "unreserved"},
+	{"called", "unreserved"},
+	{"cascade", "unreserved"},
+	{"cascaded", "unreserved"},
+	{"case", "reserved"},
+	{"cast", "reserved"},
+	{"catalog", "unreserved"},
+	{"centuries", "unreserved"},
+	{"century", "unreserved"},
+	{"chain", "unreserved"},
+	{"char", "column_name"},
+	{"character", "column_name"},
+	{"characteristics", "unreserved"},
+	{"check", "reserved"},
+	{"checkpoint", "unreserved"},
+	{"class", "unreserved"},
+	{"close", "unreserved"},
+	{"cluster", "unreserved"},
+	{"coalesce", "column_name"},
+	{"collate", "reserved"},
+	{"collation", "type_function"},
+	{"column", "reserved"},
+	{"columns", "type_function"},
+	{"comment", "unreserved"},
+	{"comments", "unreserved"},
+	{"commit", "unreserved"},
+	{"committed", "unreserved"},
+	{"compression", "unreserved"},
+	{"concurrently", "type_function"},
+	{"configuration", "unreserved"},
+	{"conflict", "unreserved"},
+	{"connection", "unreserved"},
+	{"constraint", "reserved"},
+	{"constraints", "unreserved"},
+	{"content", "unreserved"},
+	{"continue", "unreserved"},
+	{"conversion", "unreserved"},
+	{"copy", "unreserved"},
+	{"cost", "unreserved"},
+	{"create", "reserved"},
+	{"cross", "type_function"},
+	{"csv", "unreserved"},
+	{"cube", "unreserved"},
+	{"current", "unreserved"},
+	{"cursor", "unreserved"},
+	{"cycle", "unreserved"},
+	{"data", "unreserved"},
+	{"database", "unreserved"},
+	{"day", "unreserved"},
+	{"days", "unreserved"},
+	{"deallocate", "unreserved"},
+	{"dec", "column_name"},
+	{"decade", "unreserved"},
+	{"decades", "unreserved"},
+	{"decimal", "column_name"},
+	{"declare", "unreserved"},
+	{"default", "reserved"},
+	{"defaults", "unreserved"},
+	{"deferrable", "reserved"},
+	{"deferred", "unreserved"},
+	{"definer", "unreserved"},
+	{"delete", "unreserved"},
+	{"delimiter", "unreserved"},
+	{"delimiters", "unreserved"},
+	{"depends", "unreserved"},
+	{"desc", "reserved"},
+	{"describe", "reserved"},
+	{"detach", "unreserved"},
+	{"dictionary", "unreserved"},
+	{"disable", "unreserved"},
+	{"discard", "unreserved"},
+	{"distinct", "reserved"},
+	{"do", "reserved"},
+	{"document", "unreserved"},
+	{"domain", "unreserved"},
+	{"double", "unreserved"},
+	{"drop", "unreserved"},
+	{"each", "unreserved"},
+	{"else", "reserved"},
+	{"enable", "unreserved"},
+	{"encoding", "unreserved"},
+	{"encrypted", "unreserved"},
+	{"end", "reserved"},
+	{"enum", "unreserved"},
+	{"escape", "unreserved"},
+	{"event", "unreserved"},
+	{"except", "reserved"},
+	{"exclude", "unreserved"},
+	{"excluding", "unreserved"},
+	{"exclusive", "unreserved"},
+	{"execute", "unreserved"},
+	{"exists", "column_name"},
+	{"explain", "unreserved"},
+	{"export", "unreserved"},
+	{"export_state", "unreserved"},
+	{"extension", "unreserved"},
+	{"extensions", "unreserved"},
+	{"external", "unreserved"},
+	{"extract", "column_name"},
+	{"false", "reserved"},
+	{"family", "unreserved"},
+	{"fetch", "reserved"},
+	{"filter", "unreserved"},
+	{"first", "unreserved"},
+	{"float", "column_name"},
+	{"following", "unreserved"},
+	{"for", "reserved"},
+	{"force", "unreserved"},
+	{"foreign", "reserved"},
+	{"forward", "unreserved"},
+	{"freeze", "type_function"},
+	{"from", "reserved"},
+	{"full", "type_function"},
+	{"function", "unreserved"},
+	{"functions", "unreserved"},
+	{"generated", "type_function"},
+	{"glob", "type_function"},
+	{"global", "unreserved"},
+	{"grant", "reserved"},
+	{"granted", "unreserved"},
+	{"group", "reserved"},
+	{"grouping", "column_name"},
+	{"grouping_id", "column_name"},
+	{"groups", "unreserved"},
+	{"handler", "unreserved"},
+	{"having", "reserved"},
+	{"header", "unreserved"},
+	{"hold", "unreserved"},
+	{"hour", "unreserved"},
+	{"hours", "unreserved"},
+	{"identity", "unreserved"},
+	{"if", "unreserved"},
+	{"ignore", "unreserved"},
+	{"ilike", "type_function"},
+	{"immediate", "unreserved"},
+	{"immutable", "unreserved"},
+	{"implicit", "unreserved"},
+	{"import", "unreserved"},
+	{"in", "reserved"},
+	{"include", "unreserved"},
+	{"including", "unreserved"},
+	{"increment", "unreserved"},
+	{"index", "unreserved"},
+	{"indexes", "unreserved"},
+	{"inherit", "unreserved"},
+	{"inherits", "unreserved"},
+	{"initially", "reserved"},
+	{"inline", "unreserved"},
+	{"inner", "type_function"},
+	{"inout", "column_name"},
+	{"input", "unreserved"},
+	{"insensitive", "unreserved"},
+	{"insert", "unreserved"},
+	{"install", "unreserved"},
+	{"instead", "unreserved"},
+	{"int", "column_name"},
+	{"integer", "column_name"},
+	{"intersect", "reserved"},
+	{"interval", "column_name"},
+	{"into", "reserved"},
+	{"invoker", "unreserved"},
+	{"is", "type_function"},
+	{"isnull", "type_function"},
+	{"isolation", "unreserved"},
+	{"join", "type_function"},
+	{"json", "unreserved"},
+	{"key", "unreserved"},
+	{"label", "unreserved"},
+	{"language", "unreserved"},
+	{"large", "unreserved"},
+	{"last", "unreserved"},
+	{"lateral", "reserved"},
+	{"leading", "reserved"},
+	{"leakproof", "unreserved"},
+	{"left", "type_function"},
+	{"level", "unreserved"},
+	{"like", "type_function"},
+	{"limit", "reserved"},
+	{"listen", "unreserved"},
+	{"load", "unreserved"},
+	{"local", "unreserved"},
+	{"location", "unreserved"},
+	{"lock", "unreserved"},
+	{"locked", "unreserved"},
+	{"logged", "unreserved"},
+	{"macro", "unreserved"},
+	{"map", "type_function"},
+	{"mapping", "unreserved"},
+	{"match", "unreserved"},
+	{"materialized", "unreserved"},
+	{"maxvalue", "unreserved"},
+	{"method", "unreserved"},
+	{"microsecond", "unreserved"},
+	{"microseconds", "unreserved"},
+	{"millennia", "unreserved"},
+	{"millennium", "unreserved"},
+	{"millisecond", "unreserved"},
+	{"milliseconds", "unreserved"},
+	{"minute", "unreserved"},
+	{"minutes", "unreserved"},
+	{"minvalue", "unreserved"},
+	{"mode", "unreserved"},
+	{"month", "unreserved"},
+	{"months", "unreserved"},
+	{"move", "unreserved"},
+	{"name", "unreserved"},
+	{"names", "unreserved"},
+	{"national", "column_name"},
+	{"natural", "type_function"},
+	{"nchar", "column_name"},
+	{"new", "unreserved"},
+	{"next", "unreserved"},
+	{"no", "unreserved"},
+	{"none", "column_name"},
+	{"not", "reserved"},
+	{"nothing", "unreserved"},
+	{"notify", "unreserved"},
+	{"notnull", "type_function"},
+	{"nowait", "unreserved"},
+	{"null", "reserved"},
+	{"nullif", "column_name"},
+	{"nulls", "unreserved"},
+	{"numeric", "column_name"},
+	{"object", "unreserved"},
+	{"of", "unreserved"},
+	{"off", "unreserved"},
+	{"offset", "reserved"},
+	{"oids", "unreserved"},
+	{"old", "unreserved"},
+	{"on", "reserved"},
+	{"only", "reserved"},
+	{"operator", "unreserved"},
+	{"option", "unreserved"},
+	{"options", "unreserved"},
+	{"or", "reserved"},
+	{"order", "reserved"},
+	{"ordinality", "unreserved"},
+	{"others", "unreserved"},
+	{"out", "column_name"},
+	{"outer", "type_function"},
+	{"over", "unreserved"},
+	{"overlaps", "type_function"},
+	{"overlay", "column_name"},
+	{"overriding", "unreserved"},
+	{"owned", "unreserved"},
+	{"owner", "unreserved"},
+	{"parallel", "unreserved"},
+	{"parser", "unreserved"},
+	{"partial", "unreserved"},
+	{"partition", "unreserved"},
+	{"passing", "unreserved"},
+	{"password", "unreserved"},
+	{"percent", "unreserved"},
+	{"persistent", "unreserved"},
+	{"pivot", "reserved"},
+	{"pivot_longer", "reserved"},
+	{"pivot_wider", "reserved"},
+	{"placing", "reserved"},
+	{"plans", "unreserved"},
+	{"policy", "unreserved"},
+	{"position", "column_name"},
+	{"positional", "type_function"},
+	{"pragma", "unreserved"},
+	{"preceding", "unreserved"},
+	{"precision", "column_name"},
+	{"prepare", "unreserved"},
+	{"prepared", "unreserved"},
+	{"preserve", "unreserved"},
+	{"primary", "reserved"},
+	{"prior", "unreserved"},
+	{"privileges", "unreserved"},
+	{"procedural", "unreserved"},
+	{"procedure", "unreserved"},
+	{"program", "unreserved"},
+	{"publication", "unreserved"},
+	{"qualify", "reserved"},
+	{"quarter", "unreserved"},
+	{"quarters", "unreserved"},
+	{"quote", "unreserved"},
+	{"range", "unreserved"},
+	{"read", "unreserved"},
+	{"real", "column_name"},
+	{"reassign", "unreserved"},
+	{"recheck", "unreserved"},
+	{"recursive", "unreserved"},
+	{"ref", "unreserved"},
+	{"references", "reserved"},
+	{"referencing", "unreserved"},
+	{"refresh", "unreserved"},
+	{"reindex", "unreserved"},
+	{"relative", "unreserved"},
+	{"release", "unreserved"},
+	{"rename", "unreserved"},
+	{"repeatable", "unreserved"},
+	{"replace", "unreserved"},
+	{"replica", "unreserved"},
+	{"reset", "unreserved"},
+	{"respect", "unreserved"},
+	{"restart", "unreserved"},
+	{"restrict", "unreserved"},
+	{"returning", "reserved"},
+	{"returns", "unreserved"},
+	{"revoke", "unreserved"},
+	{"right", "type_function"},
+	{"role", "unreserved"},
+	{"rollback", "unreserved"},
+	{"rollup", "unreserved"},
+	{"row", "column_name"},
+	{"rows", "unreserved"},
+	{"rule", "unreserved"},
+	{"sample", "unreserved"},
+	{"savepoint", "unreserved"},
+	{"schema", "unreserved"},
+	{"schemas", "unreserved"},
+	{"scope", "unreserved"},
+	{"scroll", "unreserved"},
+	{"search", "unreserved"},
+	{"second", "unreserved"},
+	{"seconds", "unreserved"},
+	{"secret", "unreserved"},
+	{"security", "unreserved"},
+	{"select", "reserved"},
+	{"semi", "type_function"},
+	{"sequence", "unreserved"},
+	{"sequences", "unreserved"},
+	{"serializable", "unreserved"},
+	{"server", "unreserved"},
+	{"session", "unreserved"},
+	{"set", "unreserved"},
+	{"setof", "column_name"},
+	{"sets", "unreserved"},
+	{"share", "unreserved"},
+	{"show", "reserved"},
+	{"similar", "type_function"},
+	{"simple", "unreserved"},
+	{"skip", "unreserved"},
+	{"smallint", "column_name"},
+	{"snapshot", "unreserved"},
+	{"some", "reserved"},
+	{"sql", "unreserved"},
+	{"stable", "unreserved"},
+	{"standalone", "unreserved"},
+	{"start", "unreserved"},
+	{"statement", "unreserved"},
+	{"statistics", "unreserved"},
+	{"stdin", "unreserved"},
+	{"stdout", "unreserved"},
+	{"storage", "unreserved"},
+	{"stored", "unreserved"},
+	{"strict", "unreserved"},
+	{"strip", "unreserved"},
+	{"struct", "type_function"},
+	{"subscription", "unreserved"},
+	{"substring", "column_name"},
+	{"summarize", "reserved"},
+	{"symmetric", "reserved"},
+	{"sysid", "unreserved"},
+	{"system", "unreserved"},
+	{"table", "reserved"},
+	{"tables", "unreserved"},
+	{"tablesample", "type_function"},
+	{"tablespace", "unreserved"},
+	{"temp", "unreserved"},
+	{"template", "unreserved"},
+	{"temporary", "unreserved"},
+	{"text", "unreserved"},
+	{"then", "reserved"},
+	{"ties", "unreserved"},
+	{"time", "column_name"},
+	{"timestamp", "column_name"},
+	{"to", "reserved"},
+	{"trailing", "reserved"},
+	{"transaction", "unreserved"},
+	{"transform", "unreserved"},
+	{"treat", "column_name"},
+	{"trigger", "unreserved"},
+	{"trim", "column_name"},
+	{"true", "reserved"},
+	{"truncate", "unreserved"},
+	{"trusted", "unreserved"},
+	{"try_cast", "type_function"},
+	{"type", "unreserved"},
+	{"types", "unreserved"},
+	{"unbounded", "unreserved"},
+	{"uncommitted", "unreserved"},
+	{"unencrypted", "unreserved"},
+	{"union", "reserved"},
+	{"unique", "reserved"},
+	{"unknown", "unreserved"},
+	{"unlisten", "unreserved"},
+	{"unlogged", "unreserved"},
+	{"unpivot", "reserved"},
+	{"until", "unreserved"},
+	{"update", "unreserved"},
+	{"use", "unreserved"},
+	{"user", "unreserved"},
+	{"using", "reserved"},
+	{"vacuum", "unreserved"},
+	{"valid", "unreserved"},
+	{"validate", "unreserved"},
+	{"validator", "unreserved"},
+	{"value", "unreserved"},
+	{"values", "column_name"},
+	{"varchar", "column_name"},
+	{"variable", "unreserved"},
+	{"variadic", "reserved"},
+	{"varying", "unreserved"},
+	{"verbose", "type_function"},
+	{"version", "unreserved"},
+	{"view", "unreserved"},
+	{"views", "unreserved"},
+	{"virtual", "unreserved"},
+	{"volatile", "unreserved"},
+	{"week", "unreserved"},
+	{"weeks", "unreserved"},
+	{"when", "reserved"},
+	{"where", "reserved"},
+	{"whitespace", "unreserved"},
+	{"window", "reserved"},
+	{"with", "reserved"},
+	{"within", "unreserved"},
+	{"without", "unreserved"},
+	{"work", "unreserved"},
+	{"wrapper", "unreserved"},
+	{"write", "unreserved"},
+	{"xml", "unreserved"},
+	{"xmlattributes", "column_name"},
+	{"xmlconcat", "column_name"},
+	{"xmlelement", "column_name"},
+	{"xmlexists", "column_name"},
+	{"xmlforest", "column_name"},
+	{"xmlnamespaces", "column_name"},
+	{"xmlparse", "column_name"},
+	{"xmlpi", "column_name"},
+	{"xmlroot", "column_name"},
+	{"xmlserialize", "column_name"},
+	{"xmltable", "column_name"},
+	{"year", "unreserved"},
+	{"years", "unreserved"},
+	{"yes", "unreserved"},
+	{"zone", "unreserved"},
+}
@@ -0,0 +1,88 @@
+package bemidb
+
+import (
+	"strconv"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	PG_TRIGRAM_OPERATOR_SIMILAR  = "%"
+	PG_TRIGRAM_OPERATOR_DISTANCE = "<->"
+
+	PG_FUNCTION_SIMILARITY = "similarity"
+)
+
+var PG_TEXT_TYPES = NewSet([]string{"text", "varchar", "bpchar", "char"})
+
+type QueryParserTrigram struct {
+	config *Config
+	utils  *QueryParserUtils
+}
+
+func NewQueryParserTrigram(config *Config) *QueryParserTrigram {
+	return &QueryParserTrigram{config: config, utils: NewQueryParserUtils(config)}
+}
+
+// pg_trgm operators anywhere in the statement, including subqueries
+func (parser *QueryParserTrigram) TrigramOperatorNodes(node *pgQuery.Node) (trigramOperatorNodes []*pgQuery.Node) {
+	parser.utils.WalkMessages(node, func(message protoreflect.Message) {
+		if trigramOperatorNode, ok := message.Interface().(*pgQuery.Node); ok && parser.IsTrigramOperator(trigramOperatorNode) {
+			trigramOperatorNodes = append(trigramOperatorNodes, trigramOperatorNode)
+		}
+	})
+
+	return trigramOperatorNodes
+}
+
+// a <-> b, a % b where a or b is a string constant or a cast to text.
+// % is also the modulo operator, so other operands are left untouched
+func (parser *QueryParserTrigram) IsTrigramOperator(node *pgQuery.Node) bool {
+	aExpr := node.GetAExpr()
+	if aExpr == nil || aExpr.Kind != pgQuery.A_Expr_Kind_AEXPR_OP || len(aExpr.Name) != 1 || aExpr.Lexpr == nil {
+		return false
+	}
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_TRIGRAM_OPERATOR_DISTANCE:
+		return true
+	case PG_TRIGRAM_OPERATOR_SIMILAR:
+		return parser.isTextOperand(aExpr.Lexpr) || parser.isTextOperand(aExpr.Rexpr)
+	}
+
+	return false
+}
+
+// a % b -> similarity(a, b) >= 0.3
+// a <-> b -> 1 - similarity(a, b)
+func (parser *QueryParserTrigram) RemapTrigramOperator(node *pgQuery.Node) {
+	aExpr := node.GetAExpr()
+	similarityNode := pgQuery.MakeFuncCallNode([]*pgQuery.Node{pgQuery.MakeStrNode(PG_FUNCTION_SIMILARITY)}, []*pgQuery.Node{aExpr.Lexpr, aExpr.Rexpr}, 0)
+
+	switch aExpr.Name[0].GetString_().GetSval() {
+	case PG_TRIGRAM_OPERATOR_SIMILAR:
+		thresholdNode := &pgQuery.Node{Node: &pgQuery.Node_AConst{AConst: &pgQuery.A_Const{
+			Val: &pgQuery.A_Const_Fval{Fval: &pgQuery.Float{Fval: strconv.FormatFloat(PG_TRIGRAM_SIMILARITY_THRESHOLD, 'f', -1, 64)}},
+		}}}
+		node.Node = pgQuery.MakeAExprNode(pgQuery.A_Expr_Kind_AEXPR_OP, []*pgQuery.Node{pgQuery.MakeStrNode(">=")}, similarityNode, thresholdNode, 0).Node
+	case PG_TRIGRAM_OPERATOR_DISTANCE:
+		node.Node = pgQuery.MakeAExprNode(pgQuery.A_Expr_Kind_AEXPR_OP, []*pgQuery.Node{pgQuery.MakeStrNode("-")}, pgQuery.MakeAConstIntNode(1, 0), similarityNode, 0).Node
+	}
+}
+
+func (parser *QueryParserTrigram) isTextOperand(node *pgQuery.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.GetAConst() != nil {
+		return node.GetAConst().GetSval() != nil
+	}
+
+	typeCast := node.GetTypeCast()
+	if typeCast == nil || typeCast.TypeName == nil || len(typeCast.TypeName.Names) == 0 {
+		return false
+	}
+	typeName := typeCast.TypeName.Names[len(typeCast.TypeName.Names)-1].GetString_().GetSval()
+	return PG_TEXT_TYPES.Contains(typeName)
+}
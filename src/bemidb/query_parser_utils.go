@@ -1,7 +1,10 @@
-package main
+package bemidb
 
 import (
+	"strings"
+
 	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type QueryParserUtils struct {
@@ -127,3 +130,85 @@ func (utils *QueryParserUtils) MakeAConstBoolNode(val bool) *pgQuery.Node {
 		},
 	}
 }
+
+// Visits every message of the node tree, including subqueries and CTEs
+func (utils *QueryParserUtils) WalkMessages(node *pgQuery.Node, visit func(message protoreflect.Message)) {
+	if node == nil {
+		return
+	}
+
+	var walk func(message protoreflect.Message)
+	walk = func(message protoreflect.Message) {
+		visit(message)
+
+		message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+			switch {
+			case field.IsList() && field.Message() != nil:
+				list := value.List()
+				for i := 0; i < list.Len(); i++ {
+					walk(list.Get(i).Message())
+				}
+			case !field.IsMap() && field.Message() != nil:
+				walk(value.Message())
+			}
+			return true
+		})
+	}
+	walk(node.ProtoReflect())
+}
+
+// Deparses the query tree into SQL for DuckDB. Postgres quotes its own keywords, but DuckDB reserves a few more words
+// that Postgres doesn't, e.g. "qualify" or "pivot", so identifiers with these names are quoted as well
+func (utils *QueryParserUtils) DeparseForDuckdb(queryTree *pgQuery.ParseResult) (string, error) {
+	query, err := pgQuery.Deparse(queryTree)
+	if err != nil {
+		return "", err
+	}
+
+	scanResult, err := pgQuery.Scan(query)
+	if err != nil {
+		return "", err
+	}
+
+	var sqlBuilder strings.Builder
+	lastEnd := 0
+	for i, token := range scanResult.Tokens {
+		if !utils.isDuckdbReservedIdentifier(query, token) {
+			continue
+		}
+		// Function names, e.g. try_cast() or map()
+		if i+1 < len(scanResult.Tokens) && scanResult.Tokens[i+1].Token == pgQuery.Token_ASCII_40 {
+			continue
+		}
+
+		sqlBuilder.WriteString(query[lastEnd:token.Start])
+		sqlBuilder.WriteString(`"` + query[token.Start:token.End] + `"`)
+		lastEnd = int(token.End)
+	}
+	if lastEnd == 0 {
+		return query, nil
+	}
+	sqlBuilder.WriteString(query[lastEnd:])
+
+	return sqlBuilder.String(), nil
+}
+
+func (utils *QueryParserUtils) isDuckdbReservedIdentifier(query string, token *pgQuery.ScanToken) bool {
+	switch token.KeywordKind {
+	case pgQuery.KeywordKind_NO_KEYWORD, pgQuery.KeywordKind_UNRESERVED_KEYWORD:
+	default:
+		return false
+	}
+
+	word := query[token.Start:token.End]
+	if token.KeywordKind == pgQuery.KeywordKind_NO_KEYWORD && token.Token != pgQuery.Token_IDENT || strings.HasPrefix(word, `"`) {
+		return false
+	}
+
+	for _, keyword := range DUCKDB_KEYWORDS {
+		if keyword.word == word {
+			return keyword.category != "unreserved"
+		}
+	}
+	return false
+}
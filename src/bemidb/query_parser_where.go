@@ -1,7 +1,8 @@
-package main
+package bemidb
 
 import (
 	pgQuery "github.com/pganalyze/pg_query_go/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type QueryParserWhere struct {
@@ -53,3 +54,36 @@ func (parser *QueryParserWhere) OverrideWhereCondition(selectStatement *pgQuery.
 	selectStatement.WhereClause = whereCondition
 	return selectStatement
 }
+
+// WHERE x IN (SELECT ...), EXISTS (SELECT ... UNION SELECT ...) -> subqueries, without the ones nested in them
+func (parser *QueryParserWhere) SubselectStatements(node *pgQuery.Node) (selectStatements []*pgQuery.SelectStmt) {
+	if node == nil {
+		return nil
+	}
+
+	var findSubselects func(message protoreflect.Message)
+	findSubselects = func(message protoreflect.Message) {
+		if subLink, ok := message.Interface().(*pgQuery.SubLink); ok {
+			if subSelect := subLink.Subselect.GetSelectStmt(); subSelect != nil {
+				selectStatements = append(selectStatements, subSelect)
+			}
+			return
+		}
+
+		message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+			switch {
+			case field.IsList() && field.Message() != nil:
+				list := value.List()
+				for i := 0; i < list.Len(); i++ {
+					findSubselects(list.Get(i).Message())
+				}
+			case !field.IsMap() && field.Message() != nil:
+				findSubselects(value.Message())
+			}
+			return true
+		})
+	}
+	findSubselects(node.ProtoReflect())
+
+	return selectStatements
+}
@@ -0,0 +1,379 @@
+package bemidb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zone names don't depend on the system time zone database
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+const (
+	BEMIDB_SETTING_SNAPSHOT_TIME = "bemidb.snapshot_time"
+	PG_SETTING_TIME_ZONE         = "timezone"
+	PG_SETTING_SEARCH_PATH       = "search_path"
+	PG_SETTING_APPLICATION_NAME  = "application_name"
+	PG_SETTING_STATEMENT_TIMEOUT = "statement_timeout"
+	PG_SETTING_CLIENT_ENCODING   = "client_encoding"
+
+	PG_SETTING_SERVER_VERSION     = "server_version"
+	PG_SETTING_SERVER_VERSION_NUM = "server_version_num"
+
+	PG_DEFAULT_SEARCH_PATH = `"$user", public`
+)
+
+// Values returned by SHOW for settings that clients read on connect, unless they are changed with SET
+var PG_DEFAULT_SETTINGS = map[string]string{
+	"server_encoding":               PG_ENCODING,
+	"standard_conforming_strings":   PG_STANDARD_CONFORMING_STRINGS,
+	"datestyle":                     "ISO, MDY",
+	"intervalstyle":                 "postgres",
+	"extra_float_digits":            "1",
+	"client_min_messages":           "notice",
+	"integer_datetimes":             "on",
+	"max_identifier_length":         "63",
+	"transaction_isolation":         "read committed",
+	"default_transaction_isolation": "read committed",
+	"transaction_read_only":         "off",
+	"default_transaction_read_only": "off",
+}
+
+// Timestamps without a time zone are read as UTC
+var SNAPSHOT_TIME_LAYOUTS = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Settings of a single client connection changed with SET
+type QuerySession struct {
+	// Pins reads of all Iceberg tables to the snapshots that were current at this time. Zero reads the current snapshots
+	SnapshotTime time.Time
+	// Time zone of returned timestamptz values. Nil returns them in UTC
+	TimeZone *time.Location
+	// Schemas of unqualified table names in order. Nil uses "$user", public
+	SearchPath      []string
+	ApplicationName string
+	// Cancels queries running longer than this. Zero disables it
+	StatementTimeout time.Duration
+	// Values of other settings by name, only returned by SHOW, e.g. extra_float_digits
+	OtherSettings map[string]string
+}
+
+// SET bemidb.snapshot_time = '2024-01-01 00:00:00', SET TIME ZONE 'America/New_York', SET search_path TO analytics, public, RESET ALL
+func (session *QuerySession) ApplySetStatement(setStatement *pgQuery.VariableSetStmt) error {
+	if setStatement.Kind == pgQuery.VariableSetKind_VAR_RESET_ALL {
+		*session = QuerySession{}
+		return nil
+	}
+
+	switch setStatement.Name {
+	case BEMIDB_SETTING_SNAPSHOT_TIME:
+		return session.applySnapshotTime(setStatement)
+	case PG_SETTING_TIME_ZONE:
+		return session.applyTimeZone(setStatement)
+	case PG_SETTING_SEARCH_PATH:
+		session.applySearchPath(setStatement)
+	case PG_SETTING_APPLICATION_NAME:
+		session.ApplicationName = ""
+		if setStatement.Kind == pgQuery.VariableSetKind_VAR_SET_VALUE && len(setStatement.Args) > 0 {
+			session.ApplicationName = setStatement.Args[0].GetAConst().GetSval().GetSval()
+		}
+	case PG_SETTING_STATEMENT_TIMEOUT:
+		return session.applyStatementTimeout(setStatement)
+	case PG_SETTING_CLIENT_ENCODING:
+		if setStatement.Kind == pgQuery.VariableSetKind_VAR_SET_VALUE && len(setStatement.Args) > 0 {
+			_, err := ParseClientEncoding(setStatement.Args[0].GetAConst().GetSval().GetSval())
+			return err
+		}
+	default:
+		session.applyOtherSetting(setStatement)
+	}
+
+	return nil
+}
+
+// Value returned by SHOW, or false if the setting isn't kept in the session, e.g. the configured server_version
+func (session *QuerySession) Setting(name string) (string, bool) {
+	switch name {
+	case PG_SETTING_SEARCH_PATH:
+		if session.SearchPath == nil {
+			return PG_DEFAULT_SEARCH_PATH, true
+		}
+		return strings.Join(session.SearchPath, ", "), true
+	case PG_SETTING_TIME_ZONE:
+		return session.timeZoneName(), true
+	case PG_SETTING_APPLICATION_NAME:
+		return session.ApplicationName, true
+	case PG_SETTING_STATEMENT_TIMEOUT:
+		if session.StatementTimeout%time.Second == 0 && session.StatementTimeout != 0 {
+			return strconv.FormatInt(int64(session.StatementTimeout/time.Second), 10) + "s", true
+		}
+		return strconv.FormatInt(session.StatementTimeout.Milliseconds(), 10) + "ms", true
+	case PG_SETTING_CLIENT_ENCODING:
+		return PG_ENCODING, true
+	}
+
+	if value, ok := session.OtherSettings[name]; ok {
+		return value, true
+	}
+	value, ok := PG_DEFAULT_SETTINGS[name]
+	return value, ok
+}
+
+// DuckDB settings applied while running the session's queries, e.g. the time zone of date_trunc() and ::date on timestamptz values.
+// Fixed offsets from UTC have no time zone name that DuckDB accepts
+func (session *QuerySession) DuckdbSettings() []DuckdbSetting {
+	if session.TimeZone == nil || session.TimeZone.String() == "" {
+		return nil
+	}
+
+	return []DuckdbSetting{{Name: "TimeZone", Value: "'" + strings.ReplaceAll(session.TimeZone.String(), "'", "''") + "'"}}
+}
+
+// "$user" is the connected user's schema like in Postgres
+func (session *QuerySession) SearchPathSchemas(user string) []string {
+	searchPath := session.SearchPath
+	if searchPath == nil {
+		searchPath = []string{`"$user"`, PG_SCHEMA_PUBLIC}
+	}
+
+	var schemas []string
+	for _, schema := range searchPath {
+		if schema == `"$user"` || schema == "$user" {
+			schemas = append(schemas, user)
+		} else {
+			schemas = append(schemas, strings.Trim(schema, `"`))
+		}
+	}
+	return schemas
+}
+
+func (session *QuerySession) Location() *time.Location {
+	if session.TimeZone == nil {
+		return time.UTC
+	}
+	return session.TimeZone
+}
+
+func (session *QuerySession) applySnapshotTime(setStatement *pgQuery.VariableSetStmt) error {
+	if setStatement.Kind != pgQuery.VariableSetKind_VAR_SET_VALUE || len(setStatement.Args) == 0 {
+		session.SnapshotTime = time.Time{}
+		return nil
+	}
+
+	value := setStatement.Args[0].GetAConst().GetSval().GetSval()
+	snapshotTime, err := ParseSnapshotTime(value)
+	if err != nil {
+		return err
+	}
+	session.SnapshotTime = snapshotTime
+
+	return nil
+}
+
+// SET TIME ZONE 'Europe/Berlin', SET TIME ZONE -5, SET TIME ZONE LOCAL, RESET timezone
+func (session *QuerySession) applyTimeZone(setStatement *pgQuery.VariableSetStmt) error {
+	if setStatement.Kind != pgQuery.VariableSetKind_VAR_SET_VALUE || len(setStatement.Args) == 0 {
+		session.TimeZone = nil
+		return nil
+	}
+
+	// Numeric values are hour offsets from UTC
+	constant := setStatement.Args[0].GetAConst()
+	if constant.GetIval() != nil {
+		session.TimeZone = time.FixedZone("", int(constant.GetIval().Ival)*3600)
+		return nil
+	}
+	if constant.GetFval() != nil {
+		hours, err := strconv.ParseFloat(constant.GetFval().Fval, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for parameter \"TimeZone\": \"%s\"", constant.GetFval().Fval)
+		}
+		session.TimeZone = time.FixedZone("", int(hours*3600))
+		return nil
+	}
+
+	value := constant.GetSval().GetSval()
+	location, err := ParseTimeZone(value)
+	if err != nil {
+		return err
+	}
+	session.TimeZone = location
+
+	return nil
+}
+
+// SET search_path TO analytics, public, SET search_path = 'analytics, public', RESET search_path
+func (session *QuerySession) applySearchPath(setStatement *pgQuery.VariableSetStmt) {
+	if setStatement.Kind != pgQuery.VariableSetKind_VAR_SET_VALUE || len(setStatement.Args) == 0 {
+		session.SearchPath = nil
+		return
+	}
+
+	searchPath := []string{}
+	for _, arg := range setStatement.Args {
+		for _, schema := range strings.Split(arg.GetAConst().GetSval().GetSval(), ",") {
+			schema = strings.TrimSpace(schema)
+			if schema != "" {
+				searchPath = append(searchPath, schema)
+			}
+		}
+	}
+	session.SearchPath = searchPath
+}
+
+// SET statement_timeout = 5000, SET statement_timeout = '5s', SET statement_timeout = 0
+func (session *QuerySession) applyStatementTimeout(setStatement *pgQuery.VariableSetStmt) error {
+	if setStatement.Kind != pgQuery.VariableSetKind_VAR_SET_VALUE || len(setStatement.Args) == 0 {
+		session.StatementTimeout = 0
+		return nil
+	}
+
+	constant := setStatement.Args[0].GetAConst()
+	if constant.GetIval() != nil {
+		session.StatementTimeout = time.Duration(constant.GetIval().Ival) * time.Millisecond
+		return nil
+	}
+
+	value := constant.GetSval().GetSval()
+	statementTimeout, err := ParseStatementTimeout(value)
+	if err != nil {
+		return err
+	}
+	session.StatementTimeout = statementTimeout
+
+	return nil
+}
+
+// SET extra_float_digits = 3, SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL SERIALIZABLE, RESET extra_float_digits
+func (session *QuerySession) applyOtherSetting(setStatement *pgQuery.VariableSetStmt) {
+	if session.OtherSettings == nil {
+		session.OtherSettings = map[string]string{}
+	}
+
+	switch setStatement.Kind {
+	case pgQuery.VariableSetKind_VAR_SET_VALUE:
+		var values []string
+		for _, arg := range setStatement.Args {
+			values = append(values, session.settingValue(arg))
+		}
+		session.OtherSettings[setStatement.Name] = strings.Join(values, ", ")
+	case pgQuery.VariableSetKind_VAR_SET_MULTI:
+		// Transaction characteristics are options, e.g. transaction_isolation
+		for _, arg := range setStatement.Args {
+			if option := arg.GetDefElem(); option != nil {
+				session.OtherSettings[option.Defname] = session.settingValue(option.Arg)
+			}
+		}
+	case pgQuery.VariableSetKind_VAR_SET_DEFAULT, pgQuery.VariableSetKind_VAR_RESET:
+		delete(session.OtherSettings, setStatement.Name)
+	}
+}
+
+func (session *QuerySession) settingValue(node *pgQuery.Node) string {
+	constant := node.GetAConst()
+	switch {
+	case constant.GetIval() != nil:
+		return strconv.FormatInt(int64(constant.GetIval().Ival), 10)
+	case constant.GetFval() != nil:
+		return constant.GetFval().Fval
+	case constant.GetBoolval() != nil:
+		if constant.GetBoolval().Boolval {
+			return "on"
+		}
+		return "off"
+	}
+	return constant.GetSval().GetSval()
+}
+
+// Fixed offsets are shown like in Postgres, e.g. <-05>+05 for SET TIME ZONE -5
+func (session *QuerySession) timeZoneName() string {
+	location := session.Location()
+	if location.String() != "" {
+		return location.String()
+	}
+
+	_, offset := time.Now().In(location).Zone()
+	sign, inverseSign := "+", "-"
+	if offset < 0 {
+		sign, inverseSign = "-", "+"
+		offset = -offset
+	}
+	name := fmt.Sprintf("%02d", offset/3600)
+	if offset%3600 != 0 {
+		name += fmt.Sprintf(":%02d", offset%3600/60)
+	}
+	return "<" + sign + name + ">" + inverseSign + name
+}
+
+// Milliseconds without a unit like in Postgres: 5000, '5000', '5s', '1min', '250ms'
+func ParseStatementTimeout(value string) (time.Duration, error) {
+	trimmedValue := strings.TrimSpace(value)
+	if milliseconds, err := strconv.ParseInt(trimmedValue, 10, 64); err == nil && milliseconds >= 0 {
+		return time.Duration(milliseconds) * time.Millisecond, nil
+	}
+
+	for _, unit := range []struct {
+		suffix   string
+		duration time.Duration
+	}{{"ms", time.Millisecond}, {"s", time.Second}, {"min", time.Minute}, {"h", time.Hour}, {"d", 24 * time.Hour}} {
+		number, found := strings.CutSuffix(trimmedValue, unit.suffix)
+		if !found {
+			continue
+		}
+		amount, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+		if err == nil && amount >= 0 {
+			return time.Duration(amount) * unit.duration, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid value for parameter \"%s\": \"%s\"", PG_SETTING_STATEMENT_TIMEOUT, value)
+}
+
+func ParseTimeZone(value string) (*time.Location, error) {
+	switch strings.ToLower(value) {
+	case "utc", "gmt", "z", "local", "default":
+		return time.UTC, nil
+	case "":
+		return nil, fmt.Errorf("invalid value for parameter \"TimeZone\": \"%s\"", value)
+	}
+
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for parameter \"TimeZone\": \"%s\"", value)
+	}
+
+	return location, nil
+}
+
+func ParseSnapshotTime(value string) (time.Time, error) {
+	for _, layout := range SNAPSHOT_TIME_LAYOUTS {
+		snapshotTime, err := time.Parse(layout, value)
+		if err == nil {
+			return snapshotTime, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid value for parameter \"%s\": \"%s\"", BEMIDB_SETTING_SNAPSHOT_TIME, value)
+}
+
+// Strings are returned and read as UTF-8 only, there is no transcoding to other client encodings.
+// Encoding names are matched ignoring case and non-alphanumeric characters like in Postgres, e.g. utf-8 or Unicode
+func ParseClientEncoding(value string) (string, error) {
+	normalizedValue := strings.Map(func(char rune) rune {
+		if char >= 'a' && char <= 'z' || char >= '0' && char <= '9' {
+			return char
+		}
+		return -1
+	}, strings.ToLower(value))
+
+	if normalizedValue == "utf8" || normalizedValue == "unicode" {
+		return PG_ENCODING, nil
+	}
+	return "", fmt.Errorf("client_encoding \"%s\" is not supported, BemiDB only supports UTF8", value)
+}
@@ -0,0 +1,508 @@
+package bemidb
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+func TestQuerySession(t *testing.T) {
+	t.Run("Reads all tables in a join at a snapshot time", func(t *testing.T) {
+		config := *loadTestConfig()
+		schemaTables := []IcebergSchemaTable{
+			{Schema: "public", Table: "test_session_users"},
+			{Schema: "public", Table: "test_session_orders"},
+		}
+		icebergWriter := NewIcebergWriter(&config)
+		stagingConfig := config
+		stagingConfig.IcebergBranch = "staging"
+		for _, schemaTable := range schemaTables {
+			defer icebergWriter.DeleteSchemaTable(schemaTable)
+			writeTestTable(icebergWriter, schemaTable)
+		}
+		time.Sleep(5 * time.Millisecond)
+		for _, schemaTable := range schemaTables {
+			writeTestTable(NewIcebergWriter(&stagingConfig), schemaTable)
+			err := icebergWriter.PromoteRef(schemaTable, "staging")
+			testNoError(t, err)
+		}
+
+		icebergReader := NewIcebergReader(&config)
+		snapshotLogs := [][]IcebergSnapshotLogEntry{}
+		for _, schemaTable := range schemaTables {
+			snapshotLog, err := icebergReader.SnapshotLog(schemaTable)
+			testNoError(t, err)
+			if len(snapshotLog) != 2 {
+				t.Fatalf("Expected 2 snapshot log entries, got %v", snapshotLog)
+			}
+			snapshotLogs = append(snapshotLogs, snapshotLog)
+		}
+		pastTime := time.UnixMilli(max(snapshotLogs[0][0].TimestampMs, snapshotLogs[1][0].TimestampMs)).UTC()
+		query := "SELECT u.id FROM test_session_users u JOIN test_session_orders o ON o.id = u.id"
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SET bemidb.snapshot_time = '" + pastTime.Format("2006-01-02 15:04:05.000") + "'")
+		testNoError(t, err)
+		remappedQuery, err := queryHandler.remapQuery(query)
+
+		testNoError(t, err)
+		for _, snapshotLog := range snapshotLogs {
+			testRemappedSnapshotId(t, remappedQuery, snapshotLog[0].SnapshotId)
+		}
+
+		_, err = queryHandler.HandleQuery("SET bemidb.snapshot_time = '" + time.Now().Add(time.Second).UTC().Format(time.RFC3339) + "'")
+		testNoError(t, err)
+		remappedQuery, err = queryHandler.remapQuery(query)
+
+		testNoError(t, err)
+		for _, snapshotLog := range snapshotLogs {
+			testRemappedSnapshotId(t, remappedQuery, snapshotLog[1].SnapshotId)
+		}
+
+		remappedQuery, err = queryHandler.NewSession().remapQuery(query)
+
+		testNoError(t, err)
+		if strings.Contains(remappedQuery, "ubigint") {
+			t.Errorf("Expected a new session to read the current snapshots, got %v", remappedQuery)
+		}
+
+		_, err = queryHandler.HandleQuery("RESET bemidb.snapshot_time")
+		testNoError(t, err)
+		remappedQuery, err = queryHandler.remapQuery(query)
+
+		testNoError(t, err)
+		if strings.Contains(remappedQuery, "ubigint") {
+			t.Errorf("Expected RESET to read the current snapshots, got %v", remappedQuery)
+		}
+	})
+
+	t.Run("Returns an error for an invalid snapshot time", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SET bemidb.snapshot_time = 'yesterday'")
+
+		if err == nil || err.Error() != `invalid value for parameter "bemidb.snapshot_time": "yesterday"` {
+			t.Errorf("Expected an invalid value error, got %v", err)
+		}
+	})
+
+	t.Run("Reads a table at the snapshot time or id after @", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_session_history"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		time.Sleep(5 * time.Millisecond)
+		writeTestTable(icebergWriter, schemaTable)
+		snapshotLog, err := NewIcebergReader(config).SnapshotLog(schemaTable)
+		testNoError(t, err)
+		if len(snapshotLog) != 2 {
+			t.Fatalf("Expected 2 snapshot log entries, got %v", snapshotLog)
+		}
+		pastTime := time.UnixMilli(snapshotLog[0].TimestampMs).UTC()
+		queryHandler := initQueryHandler()
+
+		remappedQuery, err := queryHandler.remapQuery(`SELECT id FROM "test_session_history@` + pastTime.Format("2006-01-02 15:04:05.000") + `"`)
+
+		testNoError(t, err)
+		testRemappedSnapshotId(t, remappedQuery, snapshotLog[0].SnapshotId)
+
+		remappedQuery, err = queryHandler.remapQuery(`SELECT id FROM public."test_session_history@` + strconv.FormatInt(snapshotLog[1].SnapshotId, 10) + `"`)
+
+		testNoError(t, err)
+		testRemappedSnapshotId(t, remappedQuery, snapshotLog[1].SnapshotId)
+	})
+
+	t.Run("Returns an error for a time before the oldest snapshot or an unknown snapshot id", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_session_history"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.remapQuery(`SELECT id FROM "test_session_history@2000-01-01"`)
+
+		if err == nil || !strings.HasPrefix(err.Error(), `Failed to find a snapshot of "public"."test_session_history" at or before 2000-01-01T00:00:00Z, the oldest one is from `) {
+			t.Errorf("Expected a snapshot time error, got %v", err)
+		}
+
+		_, err = queryHandler.remapQuery(`SELECT id FROM "test_session_history@123"`)
+
+		if err == nil || err.Error() != `Failed to find snapshot 123 in "public"."test_session_history"` {
+			t.Errorf("Expected a snapshot id error, got %v", err)
+		}
+
+		_, err = queryHandler.HandleQuery("SET bemidb.snapshot_time = '2000-01-01'")
+		testNoError(t, err)
+		_, err = queryHandler.remapQuery("SELECT id FROM test_session_history")
+
+		if err == nil || !strings.Contains(err.Error(), "at or before 2000-01-01T00:00:00Z") {
+			t.Errorf("Expected a snapshot time error, got %v", err)
+		}
+	})
+
+	t.Run("Returns an error for an unknown branch or tag instead of reading the table without it", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_session_history"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		queryHandler := initQueryHandler()
+
+		for _, query := range []string{
+			`SELECT id FROM "test_session_history@unknown"`,
+			`SELECT t.id FROM test_session_history t JOIN "test_session_history@unknown" u ON t.id = u.id`,
+			`SELECT id FROM (SELECT id FROM "test_session_history@unknown") t`,
+		} {
+			_, err := queryHandler.remapQuery(query)
+
+			if err == nil || err.Error() != `Failed to find ref unknown in "public"."test_session_history"` {
+				t.Errorf("Expected an unknown ref error for %s, got %v", query, err)
+			}
+		}
+
+		// Also when a snapshot can't be read only while remapping the table
+		queryTree, err := pgQuery.Parse(`SELECT id FROM "test_session_history@unknown"`)
+		testNoError(t, err)
+		queryHandler.selectRemapper.remapSelectStatement(queryTree.Stmts[0].Stmt.GetSelectStmt(), 0)
+
+		err = queryHandler.selectRemapper.remapperTable.TakeRemapTableError()
+		if err == nil || err.Error() != `Failed to find ref unknown in "public"."test_session_history"` {
+			t.Errorf("Expected an unknown ref error, got %v", err)
+		}
+		if queryHandler.selectRemapper.remapperTable.TakeRemapTableError() != nil {
+			t.Error("Expected the error to be handed over once")
+		}
+	})
+
+	t.Run("Keeps search_path for the session across queries", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "test_search_path_schema", Table: "test_search_path_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchema(schemaTable.Schema)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		query := "SELECT bit_column FROM test_search_path_table LIMIT 1"
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery(query)
+		if err == nil {
+			t.Errorf("Expected an error for a table outside of the default search_path")
+		}
+
+		_, err = queryHandler.HandleQuery("SET search_path TO test_search_path_schema, public")
+		testNoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			messages, err := queryHandler.HandleQuery(query)
+
+			testNoError(t, err)
+			testMessageTypes(t, messages, []pgproto3.Message{
+				&pgproto3.RowDescription{},
+				&pgproto3.DataRow{},
+				&pgproto3.CommandComplete{},
+			})
+		}
+		testSessionSetting(t, queryHandler, "SHOW search_path", "test_search_path_schema, public")
+
+		otherQueryHandler := queryHandler.NewSession()
+		_, err = otherQueryHandler.HandleQuery(query)
+		if err == nil {
+			t.Errorf("Expected another session to keep the default search_path")
+		}
+		testSessionSetting(t, otherQueryHandler, "SHOW search_path", `"$user", public`)
+
+		_, err = queryHandler.HandleQuery("RESET search_path")
+		testNoError(t, err)
+		_, err = queryHandler.HandleQuery(query)
+		if err == nil {
+			t.Errorf("Expected RESET to restore the default search_path")
+		}
+	})
+
+	t.Run("Returns current_schema() and current_schemas() from the search_path", func(t *testing.T) {
+		config := loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "test_current_schema", Table: "test_current_schema_table"}
+		icebergWriter := NewIcebergWriter(config)
+		defer icebergWriter.DeleteSchema(schemaTable.Schema)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		writeTestTable(icebergWriter, schemaTable)
+		queryHandler := initQueryHandler()
+
+		testSessionSetting(t, queryHandler, "SELECT current_schema()", "public")
+		testSessionSetting(t, queryHandler, "SELECT current_schemas(false)", "{public}")
+		testSessionSetting(t, queryHandler, "SELECT current_schemas(true)", "{pg_catalog,public}")
+
+		_, err := queryHandler.HandleQuery("SET search_path TO missing_schema, test_current_schema, public")
+		testNoError(t, err)
+
+		testSessionSetting(t, queryHandler, "SELECT current_schema", "test_current_schema")
+		testSessionSetting(t, queryHandler, "SELECT pg_catalog.current_schemas(true)", "{pg_catalog,test_current_schema,public}")
+		testSessionSetting(t, queryHandler, "SELECT COUNT(*) FROM pg_catalog.pg_namespace WHERE nspname = ANY(current_schemas(false))", "2")
+		testSessionSetting(t, queryHandler, "SELECT relname FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = current_schema()", "test_current_schema_table")
+
+		_, err = queryHandler.HandleQuery("SET search_path TO missing_schema")
+		testNoError(t, err)
+
+		testSessionSetting(t, queryHandler, "SELECT current_schema() IS NULL AS is_null", "true")
+	})
+
+	t.Run("Keeps TimeZone, application_name and statement_timeout for the session", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for _, query := range []string{"SET TIME ZONE 'America/New_York'", "SET application_name = 'psql'", "SET statement_timeout = '5s'"} {
+			_, err := queryHandler.HandleQuery(query)
+			testNoError(t, err)
+		}
+
+		testSessionSetting(t, queryHandler, "SHOW timezone", "America/New_York")
+		testSessionSetting(t, queryHandler, "SHOW application_name", "psql")
+		testSessionSetting(t, queryHandler, "SHOW statement_timeout", "5s")
+
+		otherQueryHandler := queryHandler.NewSession()
+		testSessionSetting(t, otherQueryHandler, "SHOW timezone", "UTC")
+		testSessionSetting(t, otherQueryHandler, "SHOW application_name", "")
+		testSessionSetting(t, otherQueryHandler, "SHOW statement_timeout", "0ms")
+
+		_, err := queryHandler.HandleQuery("RESET ALL")
+		testNoError(t, err)
+		testSessionSetting(t, queryHandler, "SHOW timezone", "UTC")
+		testSessionSetting(t, queryHandler, "SHOW statement_timeout", "0ms")
+	})
+
+	t.Run("Returns default values from SHOW and keeps other settings for the session", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		testSessionSetting(t, queryHandler, "SHOW extra_float_digits", "1")
+		testSessionSetting(t, queryHandler, "SHOW server_version", PG_VERSION)
+		testSessionSetting(t, queryHandler, "SHOW TRANSACTION ISOLATION LEVEL", "read committed")
+
+		for _, query := range []string{
+			"SET extra_float_digits = 3",
+			"SET DateStyle = 'ISO, DMY'",
+			"SET bemidb_test.custom_setting TO 'custom'",
+			"SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL SERIALIZABLE",
+		} {
+			_, err := queryHandler.HandleQuery(query)
+			testNoError(t, err)
+		}
+
+		testSessionSetting(t, queryHandler, "SHOW extra_float_digits", "3")
+		testSessionSetting(t, queryHandler, "SHOW DateStyle", "ISO, DMY")
+		testSessionSetting(t, queryHandler, "SHOW bemidb_test.custom_setting", "custom")
+		testSessionSetting(t, queryHandler, "SHOW transaction_isolation", "serializable")
+
+		otherQueryHandler := queryHandler.NewSession()
+		testSessionSetting(t, otherQueryHandler, "SHOW extra_float_digits", "1")
+
+		_, err := queryHandler.HandleQuery("RESET extra_float_digits")
+		testNoError(t, err)
+		testSessionSetting(t, queryHandler, "SHOW extra_float_digits", "1")
+
+		_, err = queryHandler.HandleQuery("RESET ALL")
+		testNoError(t, err)
+		testSessionSetting(t, queryHandler, "SHOW DateStyle", "ISO, MDY")
+	})
+
+	t.Run("Returns version() and current_setting() from the session and the configured server version", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+		queryHandler.config.Server.Version = "14.5"
+		defer func() { queryHandler.config.Server.Version = PG_VERSION }()
+
+		_, err := queryHandler.HandleQuery("SET search_path TO analytics, public")
+		testNoError(t, err)
+
+		testSessionSetting(t, queryHandler, "SELECT version()", "PostgreSQL 14.5 (BemiDB "+VERSION+"), compiled by Bemi")
+		testSessionSetting(t, queryHandler, "SHOW server_version", "14.5")
+		testSessionSetting(t, queryHandler, "SELECT current_setting('server_version_num')::int >= 140000", "true")
+		testSessionSetting(t, queryHandler, "SELECT current_setting('Search_Path')", "analytics, public")
+		testSessionSetting(t, queryHandler, "SELECT pg_catalog.current_setting('max_connections')", "100")
+		testSessionSetting(t, queryHandler, "SELECT 'tz: ' || current_setting('TimeZone')", "tz: UTC")
+		testSessionSetting(t, queryHandler, "SELECT current_setting('threads', true)::int > 0", "true")
+
+		messages, err := queryHandler.HandleQuery("SELECT current_setting('bemidb_test.missing_setting', true)")
+
+		testNoError(t, err)
+		testRowDescription(t, messages[0], []string{"current_setting"})
+		testDataRowNullValues(t, messages[1], 0, 0)
+
+		_, err = queryHandler.HandleQuery("SELECT current_setting('bemidb_test.missing_setting')")
+
+		if err == nil || !strings.Contains(err.Error(), `unrecognized configuration parameter "bemidb_test.missing_setting"`) {
+			t.Errorf("Expected an unrecognized configuration parameter error, got %v", err)
+		}
+	})
+
+	t.Run("Applies a named time zone to DuckDB", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SET TIME ZONE 'America/New_York'")
+
+		testNoError(t, err)
+		duckdbSettings := queryHandler.session.DuckdbSettings()
+		if len(duckdbSettings) != 1 || duckdbSettings[0] != (DuckdbSetting{Name: "TimeZone", Value: "'America/New_York'"}) {
+			t.Errorf("Expected the DuckDB TimeZone to be set to America/New_York, got %v", duckdbSettings)
+		}
+
+		_, err = queryHandler.HandleQuery("SET TIME ZONE -5")
+
+		testNoError(t, err)
+		if duckdbSettings := queryHandler.session.DuckdbSettings(); duckdbSettings != nil {
+			t.Errorf("Expected no DuckDB settings for a fixed offset, got %v", duckdbSettings)
+		}
+	})
+
+	t.Run("Accepts UTF8 as client_encoding and rejects other encodings", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		for _, query := range []string{"SET client_encoding TO 'UTF8'", "SET NAMES 'utf-8'", "RESET client_encoding"} {
+			_, err := queryHandler.HandleQuery(query)
+			testNoError(t, err)
+		}
+		testSessionSetting(t, queryHandler, "SHOW client_encoding", "UTF8")
+
+		_, err := queryHandler.HandleQuery("SET client_encoding TO 'LATIN1'")
+
+		if err == nil || err.Error() != `client_encoding "LATIN1" is not supported, BemiDB only supports UTF8` {
+			t.Errorf("Expected an unsupported encoding error, got %v", err)
+		}
+	})
+
+	t.Run("Cancels queries running longer than statement_timeout", func(t *testing.T) {
+		queryHandler := initQueryHandler()
+
+		_, err := queryHandler.HandleQuery("SET statement_timeout = 1")
+		testNoError(t, err)
+		_, err = queryHandler.HandleQuery("SELECT COUNT(*) FROM range(10000000000) t1(i) WHERE i % 7 = 3")
+
+		if err != ERROR_STATEMENT_TIMEOUT {
+			t.Errorf("Expected a statement timeout error, got %v", err)
+		}
+	})
+}
+
+func TestParseStatementTimeout(t *testing.T) {
+	expectedDurationByValue := map[string]time.Duration{
+		"0":     0,
+		"1500":  1500 * time.Millisecond,
+		"250ms": 250 * time.Millisecond,
+		"5s":    5 * time.Second,
+		"2min":  2 * time.Minute,
+		"1h":    time.Hour,
+	}
+
+	for value, expectedDuration := range expectedDurationByValue {
+		t.Run(value, func(t *testing.T) {
+			duration, err := ParseStatementTimeout(value)
+
+			testNoError(t, err)
+			if duration != expectedDuration {
+				t.Errorf("Expected %v, got %v", expectedDuration, duration)
+			}
+		})
+	}
+
+	t.Run("Returns an error for an invalid value", func(t *testing.T) {
+		_, err := ParseStatementTimeout("soon")
+
+		if err == nil || err.Error() != `invalid value for parameter "statement_timeout": "soon"` {
+			t.Errorf("Expected an invalid value error, got %v", err)
+		}
+	})
+}
+
+func TestParseClientEncoding(t *testing.T) {
+	for _, value := range []string{"UTF8", "utf8", "UTF-8", "Unicode"} {
+		t.Run(value, func(t *testing.T) {
+			clientEncoding, err := ParseClientEncoding(value)
+
+			testNoError(t, err)
+			if clientEncoding != "UTF8" {
+				t.Errorf("Expected UTF8, got %v", clientEncoding)
+			}
+		})
+	}
+
+	t.Run("Returns an error for an unsupported encoding", func(t *testing.T) {
+		_, err := ParseClientEncoding("LATIN1")
+
+		if err == nil || err.Error() != `client_encoding "LATIN1" is not supported, BemiDB only supports UTF8` {
+			t.Errorf("Expected an unsupported encoding error, got %v", err)
+		}
+	})
+}
+
+func testSessionSetting(t *testing.T, queryHandler *QueryHandler, query string, expectedValue string) {
+	messages, err := queryHandler.HandleQuery(query)
+
+	testNoError(t, err)
+	testMessageTypes(t, messages, []pgproto3.Message{
+		&pgproto3.RowDescription{},
+		&pgproto3.DataRow{},
+		&pgproto3.CommandComplete{},
+	})
+	testDataRowValues(t, messages[1], []string{expectedValue})
+}
+
+func TestParseTimeZone(t *testing.T) {
+	expectedOffsetByValue := map[string]int{
+		"UTC":              0,
+		"utc":              0,
+		"LOCAL":            0,
+		"Asia/Kolkata":     5*3600 + 30*60,
+		"America/New_York": -5 * 3600,
+	}
+
+	for value, expectedOffset := range expectedOffsetByValue {
+		t.Run(value, func(t *testing.T) {
+			location, err := ParseTimeZone(value)
+
+			testNoError(t, err)
+			_, offset := time.Date(2024, 1, 1, 0, 0, 0, 0, location).Zone()
+			if offset != expectedOffset {
+				t.Errorf("Expected offset %v, got %v", expectedOffset, offset)
+			}
+		})
+	}
+
+	t.Run("Returns an error for an unknown time zone", func(t *testing.T) {
+		_, err := ParseTimeZone("Mars/Olympus_Mons")
+
+		if err == nil || err.Error() != `invalid value for parameter "TimeZone": "Mars/Olympus_Mons"` {
+			t.Errorf("Expected an invalid value error, got %v", err)
+		}
+	})
+}
+
+func TestParseSnapshotTime(t *testing.T) {
+	expectedTimeByValue := map[string]time.Time{
+		"2024-01-02T03:04:05Z":          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"2024-01-02 03:04:05.123":       time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC),
+		"2024-01-02 03:04:05+02":        time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC),
+		"2024-01-02 03:04:05.5-01:30":   time.Date(2024, 1, 2, 4, 34, 5, 500000000, time.UTC),
+		"2024-01-02":                    time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		"2024-01-02T03:04:05.999+00:00": time.Date(2024, 1, 2, 3, 4, 5, 999000000, time.UTC),
+	}
+
+	for value, expectedTime := range expectedTimeByValue {
+		t.Run(value, func(t *testing.T) {
+			snapshotTime, err := ParseSnapshotTime(value)
+
+			testNoError(t, err)
+			if !snapshotTime.Equal(expectedTime) {
+				t.Errorf("Expected %v, got %v", expectedTime, snapshotTime)
+			}
+		})
+	}
+}
+
+func testRemappedSnapshotId(t *testing.T, remappedQuery string, snapshotId int64) {
+	if !strings.Contains(remappedQuery, "'"+strconv.FormatInt(snapshotId, 10)+"'::ubigint") {
+		t.Errorf("Expected the query to read snapshot %v, got %v", snapshotId, remappedQuery)
+	}
+}
@@ -0,0 +1,105 @@
+package bemidb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RowTransformer is an optional extension point invoked by the syncer for each synced table.
+// It can add or modify columns before the rows are written to Parquet and the schema to Iceberg.
+type RowTransformer interface {
+	// Called once per table before writing. Returned columns define the Parquet and Iceberg schemas
+	TransformSchemaColumns(pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn) []PgSchemaColumn
+	// Called for each row. Receives the original columns and row values, returns values for the transformed columns
+	TransformRow(pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn, row []string) []string
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Derived column functions that can be safely applied to a source column value
+var DERIVED_COLUMN_FUNCTIONS = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// DerivedColumnTransformer appends a text column computed from another column of the same table.
+// E.g., public.users.email_normalized = lower(email)
+type DerivedColumnTransformer struct {
+	TableId          string // schema.table
+	ColumnName       string
+	SourceColumnName string
+	Function         func(string) string
+}
+
+func NewDerivedColumnTransformer(tableId string, columnName string, sourceColumnName string, functionName string) (*DerivedColumnTransformer, error) {
+	function, ok := DERIVED_COLUMN_FUNCTIONS[functionName]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported derived column function: %s", functionName)
+	}
+
+	return &DerivedColumnTransformer{
+		TableId:          tableId,
+		ColumnName:       columnName,
+		SourceColumnName: sourceColumnName,
+		Function:         function,
+	}, nil
+}
+
+func (transformer *DerivedColumnTransformer) TransformSchemaColumns(pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn) []PgSchemaColumn {
+	if !transformer.appliesTo(pgSchemaTable) || transformer.sourceColumnIndex(pgSchemaColumns) == -1 {
+		return pgSchemaColumns
+	}
+
+	return append(pgSchemaColumns, PgSchemaColumn{
+		ColumnName:      transformer.ColumnName,
+		DataType:        "text",
+		UdtName:         "text",
+		IsNullable:      PG_TRUE,
+		OrdinalPosition: IntToString(maxPgSchemaColumnOrdinalPosition(pgSchemaColumns) + 1),
+		Namespace:       PG_SCHEMA_PG_CATALOG,
+	})
+}
+
+func (transformer *DerivedColumnTransformer) TransformRow(pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn, row []string) []string {
+	if !transformer.appliesTo(pgSchemaTable) {
+		return row
+	}
+
+	sourceColumnIndex := transformer.sourceColumnIndex(pgSchemaColumns)
+	if sourceColumnIndex == -1 {
+		return row
+	}
+
+	value := row[sourceColumnIndex]
+	if value != PG_NULL_STRING {
+		value = transformer.Function(value)
+	}
+
+	return append(row, value)
+}
+
+func (transformer *DerivedColumnTransformer) appliesTo(pgSchemaTable PgSchemaTable) bool {
+	return pgSchemaTable.Schema+"."+pgSchemaTable.Table == transformer.TableId
+}
+
+func (transformer *DerivedColumnTransformer) sourceColumnIndex(pgSchemaColumns []PgSchemaColumn) int {
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		if pgSchemaColumn.ColumnName == transformer.SourceColumnName {
+			return i
+		}
+	}
+	return -1
+}
+
+func maxPgSchemaColumnOrdinalPosition(pgSchemaColumns []PgSchemaColumn) int {
+	maxOrdinalPosition := 0
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		ordinalPosition, err := StringToInt(pgSchemaColumn.OrdinalPosition)
+		PanicIfError(err)
+		if ordinalPosition > maxOrdinalPosition {
+			maxOrdinalPosition = ordinalPosition
+		}
+	}
+	return maxOrdinalPosition
+}
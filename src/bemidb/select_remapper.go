@@ -1,4 +1,4 @@
-package main
+package bemidb
 
 import (
 	"strings"
@@ -14,11 +14,22 @@ var KNOWN_SET_STATEMENTS = NewSet([]string{
 	"timezone",                    // SET SESSION timezone TO 'UTC'
 	"extra_float_digits",          // SET extra_float_digits = 3
 	"application_name",            // SET application_name = 'psql'
+	"search_path",                 // SET search_path TO analytics, public
+	"statement_timeout",           // SET statement_timeout = '5s'
+	BEMIDB_SETTING_SNAPSHOT_TIME,  // SET bemidb.snapshot_time = '2024-01-01 00:00:00'
 })
 
 type SelectRemapper struct {
 	parserTable    *QueryParserTable
 	parserType     *QueryParserType
+	parserRange    *QueryParserRange
+	parserSimilar  *QueryParserSimilar
+	parserTrigram  *QueryParserTrigram
+	parserArray    *QueryParserArray
+	parserEncoding *QueryParserEncoding
+	parserJsonb    *QueryParserJsonb
+	parserEnum     *QueryParserEnum
+	parserLimit    *QueryParserLimit
 	remapperTable  *SelectRemapperTable
 	remapperWhere  *SelectRemapperWhere
 	remapperSelect *SelectRemapperSelect
@@ -27,11 +38,19 @@ type SelectRemapper struct {
 	config         *Config
 }
 
-func NewSelectRemapper(config *Config, icebergReader *IcebergReader, duckdb *Duckdb) *SelectRemapper {
+func NewSelectRemapper(config *Config, icebergReader *IcebergReader, duckdb *Duckdb, session *QuerySession) *SelectRemapper {
 	return &SelectRemapper{
 		parserTable:    NewQueryParserTable(config),
 		parserType:     NewQueryParserType(config),
-		remapperTable:  NewSelectRemapperTable(config, icebergReader, duckdb),
+		parserRange:    NewQueryParserRange(config),
+		parserSimilar:  NewQueryParserSimilar(config),
+		parserTrigram:  NewQueryParserTrigram(config),
+		parserArray:    NewQueryParserArray(config),
+		parserEncoding: NewQueryParserEncoding(config),
+		parserJsonb:    NewQueryParserJsonb(config),
+		parserEnum:     NewQueryParserEnum(config),
+		parserLimit:    NewQueryParserLimit(config),
+		remapperTable:  NewSelectRemapperTable(config, icebergReader, duckdb, session),
 		remapperWhere:  NewSelectRemapperWhere(config),
 		remapperSelect: NewSelectRemapperSelect(config),
 		icebergReader:  icebergReader,
@@ -48,6 +67,7 @@ func (selectRemapper *SelectRemapper) RemapSetStatement(stmt *pgQuery.RawStmt) *
 		LogWarn(selectRemapper.config, "Unsupported SET ", setStatement.Name, ":", setStatement)
 	}
 
+	stmt.Stmt.GetVariableSetStmt().Kind = pgQuery.VariableSetKind_VAR_SET_VALUE
 	stmt.Stmt.GetVariableSetStmt().Name = "schema"
 	stmt.Stmt.GetVariableSetStmt().Args = []*pgQuery.Node{
 		pgQuery.MakeAConstStrNode(PG_SCHEMA_PUBLIC, 0),
@@ -76,7 +96,7 @@ func (selectRemapper *SelectRemapper) remapSelectStatement(selectStatement *pgQu
 		selectRemapper.remapCaseExpressions(selectStatement, indentLevel) // recursive
 	}
 
-	// UNION
+	// UNION / INTERSECT / EXCEPT, nested set operations are remapped by the self-recursion
 	if selectStatement.FromClause == nil && selectStatement.Larg != nil && selectStatement.Rarg != nil {
 		selectRemapper.traceTreeTraversal("UNION left", indentLevel)
 		leftSelectStatement := selectStatement.Larg
@@ -95,6 +115,12 @@ func (selectRemapper *SelectRemapper) remapSelectStatement(selectStatement *pgQu
 	// WHERE
 	if selectStatement.WhereClause != nil {
 		selectStatement = selectRemapper.remapperWhere.RemapWhereExpressions(selectStatement, selectStatement.WhereClause, indentLevel)
+
+		// WHERE ... IN (SELECT ...), EXISTS (SELECT ...)
+		for _, subSelect := range selectRemapper.remapperWhere.parserWhere.SubselectStatements(selectStatement.WhereClause) {
+			selectRemapper.traceTreeTraversal("WHERE SubLink", indentLevel)
+			selectRemapper.remapSelectStatement(subSelect, indentLevel+1) // self-recursion
+		}
 	}
 
 	// WITH
@@ -342,6 +368,194 @@ func (selectRemapper *SelectRemapper) remapTypeCastsInNode(node *pgQuery.Node) *
 	return node
 }
 
+// FROM Users -> FROM "Users" when case-insensitive table names are enabled, an error otherwise. CTEs are left as is
+func (selectRemapper *SelectRemapper) remapTableNameCase(node *pgQuery.Node) error {
+	cteNames := selectRemapper.parserTable.CommonTableExpressionNames(node)
+	for _, rangeVar := range selectRemapper.parserTable.RangeVars(node) {
+		if rangeVar.Schemaname == "" && cteNames.Contains(rangeVar.Relname) {
+			continue
+		}
+
+		err := selectRemapper.remapperTable.RemapTableNameCase(rangeVar)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FROM "table@ref" or with bemidb.snapshot_time set -> an error if a table can't be read at that snapshot
+func (selectRemapper *SelectRemapper) checkIcebergSnapshots(node *pgQuery.Node) error {
+	cteNames := selectRemapper.parserTable.CommonTableExpressionNames(node)
+	for _, rangeVar := range selectRemapper.parserTable.RangeVars(node) {
+		if rangeVar.Schemaname == "" && cteNames.Contains(rangeVar.Relname) {
+			continue
+		}
+
+		err := selectRemapper.remapperTable.CheckIcebergSnapshot(rangeVar)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tstzrange(a, b) && tstzrange(c, d) -> bemidb_range_overlaps(bemidb_range(a, b, '[)'), bemidb_range(c, d, '[)'))
+// period && other_period -> bemidb_range_overlaps(period, other_period) if both are range columns of a table in the statement
+func (selectRemapper *SelectRemapper) remapRanges(node *pgQuery.Node) {
+	rangeColumnNames := selectRemapper.remapperTable.RangeColumnNames(node)
+	for _, rangeNode := range selectRemapper.parserRange.RangeNodes(node, rangeColumnNames) {
+		switch {
+		case selectRemapper.parserRange.IsRangeOperator(rangeNode, rangeColumnNames):
+			selectRemapper.parserRange.RemapRangeOperator(rangeNode, rangeColumnNames)
+		case selectRemapper.parserRange.IsRangeConstructor(rangeNode):
+			selectRemapper.parserRange.RemapRangeConstructor(rangeNode)
+		case selectRemapper.parserRange.IsRangeTypeCast(rangeNode):
+			selectRemapper.parserRange.RemapRangeTypeCast(rangeNode)
+		}
+	}
+}
+
+func (selectRemapper *SelectRemapper) remapSimilarTo(node *pgQuery.Node) error {
+	for _, similarToNode := range selectRemapper.parserSimilar.SimilarToNodes(node) {
+		err := selectRemapper.parserSimilar.RemapSimilarTo(similarToNode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LIMIT ALL -> (no limit), LIMIT NULL -> (no limit), etc.
+func (selectRemapper *SelectRemapper) remapLimits(node *pgQuery.Node) error {
+	for _, selectStatement := range selectRemapper.parserLimit.LimitedSelectStatements(node) {
+		err := selectRemapper.parserLimit.RemapLimit(selectStatement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name % 'query' -> similarity(name, 'query') >= 0.3, name <-> 'query' -> 1 - similarity(name, 'query')
+func (selectRemapper *SelectRemapper) remapTrigramOperators(node *pgQuery.Node) {
+	for _, trigramOperatorNode := range selectRemapper.parserTrigram.TrigramOperatorNodes(node) {
+		selectRemapper.parserTrigram.RemapTrigramOperator(trigramOperatorNode)
+	}
+}
+
+// array_length(arr, 1) -> bemidb_array_length(arr, 1), arr @> ARRAY[1] -> bemidb_array_contains(arr, ARRAY[1]), etc.
+func (selectRemapper *SelectRemapper) remapArrayFunctions(node *pgQuery.Node) {
+	for _, arrayOperatorNode := range selectRemapper.parserArray.ArrayOperatorNodes(node) {
+		selectRemapper.parserArray.RemapArrayOperator(arrayOperatorNode)
+	}
+
+	selectRemapper.parserArray.SetDefaultTargetNames(node)
+	for _, arrayFunctionCall := range selectRemapper.parserArray.ArrayFunctionCalls(node) {
+		selectRemapper.parserArray.RemapArrayFunction(arrayFunctionCall)
+	}
+}
+
+// current_schema() -> 'public', current_schemas(true) -> list_value('pg_catalog', 'public') from the session's search_path
+func (selectRemapper *SelectRemapper) remapSchemaFunctions(node *pgQuery.Node) {
+	schemaFunctionNodes := selectRemapper.parserTable.SchemaFunctionNodes(node)
+	if len(schemaFunctionNodes) == 0 {
+		return
+	}
+
+	selectRemapper.parserTable.SetSchemaFunctionTargetNames(node)
+	searchPathSchemas := selectRemapper.remapperTable.ExistingSearchPathSchemas()
+	for _, schemaFunctionNode := range schemaFunctionNodes {
+		selectRemapper.parserTable.RemapSchemaFunction(schemaFunctionNode, searchPathSchemas)
+	}
+}
+
+// version() -> 'PostgreSQL 17.0 (BemiDB x.y.z), compiled by Bemi', current_setting('search_path') -> '"$user", public' from the session
+func (selectRemapper *SelectRemapper) remapSettingFunctions(node *pgQuery.Node) {
+	settingFunctionNodes := selectRemapper.parserTable.SettingFunctionNodes(node)
+	if len(settingFunctionNodes) == 0 {
+		return
+	}
+
+	selectRemapper.parserTable.SetSettingFunctionTargetNames(node)
+	for _, settingFunctionNode := range settingFunctionNodes {
+		if selectRemapper.parserTable.IsVersionFunction(settingFunctionNode) {
+			settingFunctionNode.Node = selectRemapper.parserTable.MakeVersionNode().Node
+			continue
+		}
+
+		// DuckDB's own settings, e.g. current_setting('threads'), are left to DuckDB, which errors for unknown settings
+		name, missingOk := selectRemapper.parserTable.CurrentSettingFunctionArgs(settingFunctionNode)
+		if value, ok := selectRemapper.Setting(name); ok {
+			selectRemapper.parserTable.RemapCurrentSettingFunction(settingFunctionNode, value)
+		} else if missingOk {
+			selectRemapper.parserTable.RemapMissingOkCurrentSettingFunction(settingFunctionNode, name)
+		}
+	}
+}
+
+// Value of a setting returned by SHOW and current_setting(), or false if BemiDB doesn't know the setting
+func (selectRemapper *SelectRemapper) Setting(name string) (string, bool) {
+	switch name {
+	case PG_SETTING_SERVER_VERSION:
+		return selectRemapper.config.Server.Version, true
+	case PG_SETTING_SERVER_VERSION_NUM:
+		// 17.0 -> 170000, 14.5 -> 140005
+		majorVersion, minorVersion, _ := strings.Cut(selectRemapper.config.Server.Version, ".")
+		major, _ := StringToInt(majorVersion)
+		minor, _ := StringToInt(minorVersion)
+		return IntToString(major*10000 + minor), true
+	}
+
+	if value, ok := selectRemapper.remapperTable.session.Setting(name); ok {
+		return value, true
+	}
+	for _, serverSetting := range PgServerSettings(selectRemapper.config) {
+		if serverSetting.Name == name {
+			return serverSetting.Setting, true
+		}
+	}
+	return "", false
+}
+
+// encode(data, 'hex') -> bemidb_encode(data, 'hex'), gen_random_uuid() -> uuid(), etc.
+func (selectRemapper *SelectRemapper) remapEncodingFunctions(node *pgQuery.Node) {
+	selectRemapper.parserEncoding.SetDefaultTargetNames(node)
+	for _, encodingFunctionCall := range selectRemapper.parserEncoding.EncodingFunctionCalls(node) {
+		selectRemapper.parserEncoding.RemapEncodingFunction(encodingFunctionCall)
+	}
+}
+
+// data @> '{"a":1}' -> bemidb_jsonb_contains(data, '{"a":1}'), jsonb_path_query(data, '$.a') -> unnest(bemidb_jsonb_path_query(data, '$.a')), etc.
+func (selectRemapper *SelectRemapper) remapJsonb(node *pgQuery.Node) {
+	for _, jsonbOperatorNode := range selectRemapper.parserJsonb.JsonbOperatorNodes(node) {
+		selectRemapper.parserJsonb.RemapJsonbOperator(jsonbOperatorNode)
+	}
+
+	selectRemapper.parserJsonb.SetDefaultTargetNames(node)
+	for _, jsonbPathQueryCall := range selectRemapper.parserJsonb.JsonbPathQueryCalls(node) {
+		selectRemapper.parserJsonb.RemapJsonbPathQuery(jsonbPathQueryCall)
+	}
+
+	for _, jsonbTypeCast := range selectRemapper.parserJsonb.JsonbTypeCasts(node) {
+		selectRemapper.parserJsonb.RemapJsonbTypeCast(jsonbTypeCast)
+	}
+}
+
+// mood < 'happy' -> mood < 'happy'::enum('sad', 'happy') if mood is an enum column of a table in the statement
+func (selectRemapper *SelectRemapper) remapEnumComparisons(node *pgQuery.Node) {
+	enumLabelsByColumnName := selectRemapper.remapperTable.EnumLabelsByColumnName(node)
+	if len(enumLabelsByColumnName) == 0 {
+		return
+	}
+
+	for _, comparisonNode := range selectRemapper.parserEnum.ComparisonNodes(node) {
+		selectRemapper.parserEnum.RemapComparison(comparisonNode, enumLabelsByColumnName)
+	}
+}
+
 func (selectRemapper *SelectRemapper) remapJoinExpressions(selectStatement *pgQuery.SelectStmt, node *pgQuery.Node, indentLevel int) *pgQuery.Node {
 	selectRemapper.traceTreeTraversal("JOIN left", indentLevel)
 	leftJoinNode := node.GetJoinExpr().Larg
@@ -358,6 +572,9 @@ func (selectRemapper *SelectRemapper) remapJoinExpressions(selectStatement *pgQu
 	} else if leftJoinNode.GetRangeSubselect() != nil {
 		leftSelectStatement := leftJoinNode.GetRangeSubselect().Subquery.GetSelectStmt()
 		leftSelectStatement = selectRemapper.remapSelectStatement(leftSelectStatement, indentLevel+1) // parent-recursion
+	} else if leftJoinNode.GetRangeFunction() != nil {
+		// FUNCTION
+		leftJoinNode = selectRemapper.remapTableFunction(leftJoinNode, indentLevel+1) // recursive
 	}
 	node.GetJoinExpr().Larg = leftJoinNode
 
@@ -376,6 +593,9 @@ func (selectRemapper *SelectRemapper) remapJoinExpressions(selectStatement *pgQu
 	} else if rightJoinNode.GetRangeSubselect() != nil {
 		rightSelectStatement := rightJoinNode.GetRangeSubselect().Subquery.GetSelectStmt()
 		rightSelectStatement = selectRemapper.remapSelectStatement(rightSelectStatement, indentLevel+1) // parent-recursion
+	} else if rightJoinNode.GetRangeFunction() != nil {
+		// FUNCTION
+		rightJoinNode = selectRemapper.remapTableFunction(rightJoinNode, indentLevel+1) // recursive
 	}
 	node.GetJoinExpr().Rarg = rightJoinNode
 
@@ -1,17 +1,17 @@
-package main
+package bemidb
 
 import (
 	pgQuery "github.com/pganalyze/pg_query_go/v5"
 )
 
 var REMAPPED_CONSTANT_BY_PG_FUNCTION_NAME = map[string]string{
-	"version":                            "PostgreSQL " + PG_VERSION + ", compiled by Bemi",
 	"pg_get_userbyid":                    "bemidb",
 	"pg_get_function_identity_arguments": "",
 	"pg_total_relation_size":             "0",
 	"pg_table_size":                      "0",
 	"pg_indexes_size":                    "0",
 	"pg_get_partkeydef":                  "",
+	"pg_get_constraintdef":               "",
 	"pg_tablespace_location":             "",
 	"pg_encoding_to_char":                "UTF8",
 	"pg_backend_pid":                     "0",
@@ -31,6 +31,16 @@ func NewSelectRemapperSelect(config *Config) *SelectRemapperSelect {
 
 // SELECT [PG_FUNCTION()]
 func (remapper *SelectRemapperSelect) RemapSelect(targetNode *pgQuery.Node) *pgQuery.Node {
+	// SELECT arr[1], arr[2:4]
+	for _, indirection := range remapper.parserSelect.ArrayIndirections(targetNode.GetResTarget().Val) {
+		remapper.parserSelect.RemapArrayIndirection(indirection)
+	}
+	if indirection := targetNode.GetResTarget().Val.GetAIndirection(); indirection != nil {
+		if columnName := remapper.parserSelect.ArrayIndirectionColumnName(indirection); columnName != "" {
+			remapper.parserSelect.SetDefaultTargetName(targetNode, columnName)
+		}
+	}
+
 	functionCall := remapper.parserSelect.FunctionCall(targetNode)
 	if functionCall == nil {
 		return targetNode
@@ -52,6 +62,7 @@ func (remapper *SelectRemapperSelect) RemapSelect(targetNode *pgQuery.Node) *pgQ
 	remappedArgsFunction := remapper.remappedFunctionArgs(functionCall)
 	if remappedArgsFunction != nil {
 		functionCall = remappedArgsFunction
+		remapper.parserSelect.SetDefaultTargetName(targetNode, originalFunctionName)
 	}
 
 	constantNode := remapper.remappedToConstant(functionCall)
@@ -87,6 +98,16 @@ func (remapper *SelectRemapperSelect) remappedFunctionName(functionCall *pgQuery
 		return remapper.parserSelect.RemapQuoteIdentToConcat(functionCall)
 	}
 
+	// every(bool) -> bool_and(bool)
+	if remapper.parserSelect.IsEveryFunction(functionName) {
+		return remapper.parserSelect.RemapEveryToBoolAnd(functionCall)
+	}
+
+	// pg_typeof(value) -> bemidb_pg_typeof(value)
+	if remapper.parserSelect.IsPgTypeofFunction(functionName) {
+		return remapper.parserSelect.RemapPgTypeof(functionCall)
+	}
+
 	return nil
 }
 
@@ -98,6 +119,16 @@ func (remapper *SelectRemapperSelect) remappedFunctionArgs(functionCall *pgQuery
 		return remapper.parserSelect.RemoveThirdArgumentFromPgGetExpr(functionCall)
 	}
 
+	// lpad(str, len) -> lpad(str, len, ' '), lpad(str, len, '') -> truncated str
+	if remapper.parserSelect.IsPadFunction(functionName) {
+		return remapper.parserSelect.RemapPadFunction(functionCall)
+	}
+
+	// array_agg(DISTINCT x) -> array_agg(DISTINCT x ORDER BY x)
+	if remapper.parserSelect.IsUnorderedDistinctAggregate(functionName, functionCall) {
+		return remapper.parserSelect.RemapUnorderedDistinctAggregate(functionCall)
+	}
+
 	return nil
 }
 
@@ -114,6 +145,11 @@ func (remapper *SelectRemapperSelect) remapNestedFunctionCalls(functionCall *pgQ
 			nestedFunctionCall = renamedFunctionCall
 		}
 
+		remappedArgsFunctionCall := remapper.remappedFunctionArgs(nestedFunctionCall)
+		if remappedArgsFunctionCall != nil {
+			nestedFunctionCall = remappedArgsFunctionCall
+		}
+
 		constantNode := remapper.remappedToConstant(nestedFunctionCall)
 		if constantNode != nil {
 			remapper.parserSelect.OverrideFunctionCallArg(functionCall, i, constantNode)
@@ -0,0 +1,797 @@
+package bemidb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	pgQuery "github.com/pganalyze/pg_query_go/v5"
+)
+
+const (
+	PG_SCHEMA_PUBLIC = "public"
+
+	PG_TABLE_PG_INHERITS           = "pg_inherits"
+	PG_TABLE_PG_SHDESCRIPTION      = "pg_shdescription"
+	PG_TABLE_PG_STATIO_USER_TABLES = "pg_statio_user_tables"
+	PG_TABLE_PG_SHADOW             = "pg_shadow"
+	PG_TABLE_PG_NAMESPACE          = "pg_namespace"
+	PG_TABLE_PG_ROLES              = "pg_roles"
+	PG_TABLE_PG_CLASS              = "pg_class"
+	PG_TABLE_PG_ATTRIBUTE          = "pg_attribute"
+	PG_TABLE_PG_EXTENSION          = "pg_extension"
+	PG_TABLE_PG_REPLICATION_SLOTS  = "pg_replication_slots"
+	PG_TABLE_PG_DATABASE           = "pg_database"
+	PG_TABLE_PG_STAT_GSSAPI        = "pg_stat_gssapi"
+	PG_TABLE_PG_AUTH_MEMBERS       = "pg_auth_members"
+	PG_TABLE_PG_USER               = "pg_user"
+	PG_TABLE_PG_STAT_ACTIVITY      = "pg_stat_activity"
+	PG_TABLE_PG_SETTINGS           = "pg_settings"
+
+	PG_TABLE_TABLES            = "tables"
+	PG_TABLE_TABLE_CONSTRAINTS = "table_constraints"
+	PG_TABLE_KEY_COLUMN_USAGE  = "key_column_usage"
+	PG_TABLE_COLUMNS           = "columns"
+
+	// Metadata tables queried by suffixing a table name, e.g. "users.files"
+	ICEBERG_TABLE_FILES     = "files"
+	ICEBERG_TABLE_SNAPSHOTS = "snapshots"
+)
+
+type SelectRemapperTable struct {
+	parserTable         *QueryParserTable
+	icebergSchemaTables []IcebergSchemaTable
+	// Schema fields by table and snapshot, reset for each statement
+	icebergSchemaFieldsCache map[string][]IcebergSchemaField
+	icebergReader            *IcebergReader
+	duckdb                   *Duckdb
+	session                  *QuerySession
+	config                   *Config
+	// Local copies of the tables read by the statements, by table and snapshot, removed once they have run
+	localCopyPaths    map[string]string
+	localCopyDirPaths []string
+	// Passthrough tables read by the statements, which then run on the source database instead of DuckDB
+	passthroughSchemaTables []IcebergSchemaTable
+	// First table that couldn't be read at its snapshot, returned instead of running the statement
+	remapTableErr error
+}
+
+func NewSelectRemapperTable(config *Config, icebergReader *IcebergReader, duckdb *Duckdb, session *QuerySession) *SelectRemapperTable {
+	remapper := &SelectRemapperTable{
+		parserTable:              NewQueryParserTable(config),
+		icebergSchemaFieldsCache: map[string][]IcebergSchemaField{},
+		icebergReader:            icebergReader,
+		duckdb:                   duckdb,
+		session:                  session,
+		config:                   config,
+	}
+	remapper.reloadIceberSchemaTables()
+	return remapper
+}
+
+// FROM / JOIN [TABLE]
+func (remapper *SelectRemapperTable) RemapTable(node *pgQuery.Node) *pgQuery.Node {
+	parser := remapper.parserTable
+	qSchemaTable := parser.NodeToQuerySchemaTable(node)
+
+	// pg_catalog.pg_* system tables
+	if parser.IsTableFromPgCatalog(qSchemaTable) {
+		switch qSchemaTable.Table {
+		case PG_TABLE_PG_SHADOW:
+			// pg_catalog.pg_shadow -> return hard-coded credentials
+			tableNode := parser.MakePgShadowNode(remapper.config.User, remapper.config.EncryptedPassword, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_ROLES:
+			// pg_catalog.pg_roles -> return hard-coded role info
+			tableNode := parser.MakePgRolesNode(remapper.config.User, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_CLASS:
+			// pg_catalog.pg_class -> reload Iceberg tables and return their number of columns
+			tableNode := parser.MakePgClassNode(remapper.icebergTablesSchemaFields(), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_ATTRIBUTE:
+			// pg_catalog.pg_attribute -> return Iceberg schema fields instead of the columns of the tables created for them in DuckDB
+			tableNode := parser.MakePgAttributeNode(remapper.icebergTablesSchemaFields(), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_NAMESPACE:
+			// pg_catalog.pg_namespace -> return public, Iceberg schemas, pg_catalog and information_schema
+			remapper.reloadIceberSchemaTables()
+			tableNode := parser.MakePgNamespaceNode(remapper.pgNamespaceSchemas(), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_INHERITS:
+			// pg_catalog.pg_inherits -> return nothing
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_INHERITS, PG_INHERITS_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_SHDESCRIPTION:
+			// pg_catalog.pg_shdescription -> return nothing
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_SHDESCRIPTION, PG_SHDESCRIPTION_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_STATIO_USER_TABLES:
+			// pg_catalog.pg_statio_user_tables -> return nothing
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_STATIO_USER_TABLES, PG_STATIO_USER_TABLES_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_EXTENSION:
+			// pg_catalog.pg_extension -> return hard-coded extension info
+			tableNode := parser.MakePgExtensionNode(qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_REPLICATION_SLOTS:
+			// pg_replication_slots -> return nothing
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_REPLICATION_SLOTS, PG_REPLICATION_SLOTS_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_DATABASE:
+			// pg_catalog.pg_database -> return hard-coded database info
+			tableNode := parser.MakePgDatabaseNode(remapper.config.Database, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_STAT_GSSAPI:
+			// pg_catalog.pg_stat_gssapi -> return nothing
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_STAT_GSSAPI, PG_STAT_GSSAPI_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_AUTH_MEMBERS:
+			// pg_catalog.pg_auth_members -> return empty table
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_AUTH_MEMBERS, PG_AUTH_MEMBERS_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_USER:
+			// pg_catalog.pg_user -> return hard-coded user info
+			tableNode := parser.MakePgUserNode(remapper.config.User, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_STAT_ACTIVITY:
+			// pg_stat_activity -> return empty table
+			tableNode := parser.MakeEmptyTableNode(PG_TABLE_PG_STAT_ACTIVITY, PG_STAT_ACTIVITY_COLUMNS, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_PG_SETTINGS:
+			// pg_catalog.pg_settings -> return server limits consistent with the config and DuckDB settings
+			tableNode := parser.MakePgSettingsNode(PgServerSettings(remapper.config), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		default:
+			// pg_catalog.pg_* other system tables -> return as is
+			return node
+		}
+	}
+
+	// information_schema.* system tables
+	if parser.IsTableFromInformationSchema(qSchemaTable) {
+		switch qSchemaTable.Table {
+		case PG_TABLE_TABLES:
+			// information_schema.tables -> reload Iceberg tables
+			remapper.reloadIceberSchemaTables()
+			return node
+		case PG_TABLE_TABLE_CONSTRAINTS:
+			// information_schema.table_constraints -> return primary keys captured during sync
+			tableNode := parser.MakeTableConstraintsNode(remapper.config.Database, remapper.icebergPrimaryKeys(), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_KEY_COLUMN_USAGE:
+			// information_schema.key_column_usage -> return primary key columns captured during sync
+			tableNode := parser.MakeKeyColumnUsageNode(remapper.config.Database, remapper.icebergPrimaryKeys(), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case PG_TABLE_COLUMNS:
+			// information_schema.columns -> return Iceberg schema fields with column defaults captured during sync
+			tableNode := parser.MakeColumnsNode(remapper.config.Database, remapper.icebergTablesSchemaFields(), qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		default:
+			// information_schema.* other system tables -> return as is
+			return node
+		}
+	}
+
+	// bemidb.audit -> FROM read_json('audit/*.json') with a record of each sync commit
+	if remapper.config.AuditLogPath != "" && parser.IsAuditTable(qSchemaTable) {
+		auditRecordsPath, err := remapper.icebergReader.AuditRecordsPath()
+		if err != nil {
+			LogWarn(remapper.config, "Couldn't read audit records:", err)
+			return node
+		}
+		tableNode := parser.MakeAuditTableNode(auditRecordsPath, qSchemaTable.Alias)
+		return remapper.overrideTable(node, tableNode)
+	}
+
+	// table.files -> VALUES with the data files of the current snapshot
+	// table.snapshots -> VALUES with all snapshots, including the ones retained by tags and branches
+	if schemaTable, ok := remapper.metadataTableSchemaTable(node, qSchemaTable); ok {
+		switch qSchemaTable.Table {
+		case ICEBERG_TABLE_FILES:
+			icebergDataFiles, err := remapper.icebergReader.DataFiles(schemaTable)
+			if err != nil {
+				LogWarn(remapper.config, "Couldn't read Iceberg data files:", err)
+				return node
+			}
+			tableNode := parser.MakeIcebergFilesNode(icebergDataFiles, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		case ICEBERG_TABLE_SNAPSHOTS:
+			icebergSnapshots, err := remapper.icebergReader.Snapshots(schemaTable)
+			if err != nil {
+				LogWarn(remapper.config, "Couldn't read Iceberg snapshots:", err)
+				return node
+			}
+			tableNode := parser.MakeIcebergSnapshotsNode(icebergSnapshots, qSchemaTable.Alias)
+			return remapper.overrideTable(node, tableNode)
+		}
+	}
+
+	// iceberg.table -> FROM iceberg_scan('iceberg/schema/table/metadata/v1.metadata.json', skip_schema_inference = true)
+	// iceberg."table@ref" -> same, reading the snapshot of the branch or tag
+	// SET bemidb.snapshot_time -> same, reading the snapshot that was current at that time
+	if qSchemaTable.Schema == "" {
+		qSchemaTable.Schema = remapper.searchPathSchema(qSchemaTable)
+	}
+	schemaTable, icebergRef := remapper.splitIcebergRef(qSchemaTable)
+	// passthrough.table -> return as is, the query runs on the source database
+	if remapper.isPassthroughTable(schemaTable) {
+		remapper.passthroughSchemaTables = append(remapper.passthroughSchemaTables, schemaTable)
+		return node
+	}
+	if !remapper.icebergSchemaTableExists(schemaTable) {
+		remapper.reloadIceberSchemaTables()
+		if !remapper.icebergSchemaTableExists(schemaTable) {
+			// Tables left out of the sync with the include and exclude options aren't exposed either
+			if remapper.config.Pg.PassthroughUnsyncedTables && IsIncludedPgSchemaTable(remapper.config, PgSchemaTable{Schema: schemaTable.Schema, Table: schemaTable.Table}) {
+				remapper.passthroughSchemaTables = append(remapper.passthroughSchemaTables, schemaTable)
+			}
+			return node // Let it return "Catalog Error: Table with name _ does not exist!"
+		}
+	}
+	snapshotId, err := remapper.icebergSnapshotId(schemaTable, icebergRef)
+	if err != nil {
+		remapper.setRemapTableError(err)
+		return node
+	}
+	icebergPath, err := remapper.icebergMetadataFilePath(schemaTable, snapshotId)
+	if err != nil {
+		remapper.setRemapTableError(fmt.Errorf("Failed to download %s: %v", schemaTable.String(), err))
+		return node
+	}
+	icebergSchemaFields := remapper.icebergSchemaFields(schemaTable, snapshotId)
+	tableNode := parser.MakeIcebergTableNode(icebergPath, snapshotId, icebergSchemaFields, qSchemaTable)
+	return remapper.overrideTable(node, tableNode)
+}
+
+// Labels of the enum columns of the tables read by the statement, columns with the same name but different labels are left out
+func (remapper *SelectRemapperTable) EnumLabelsByColumnName(node *pgQuery.Node) map[string][]string {
+	enumLabelsByColumnName := map[string][]string{}
+	ambiguousColumnNames := NewSet([]string{})
+	for _, icebergSchemaField := range remapper.statementIcebergSchemaFields(node) {
+		if len(icebergSchemaField.EnumLabels) == 0 {
+			continue
+		}
+		existingEnumLabels, ok := enumLabelsByColumnName[icebergSchemaField.Name]
+		if ok && !slices.Equal(existingEnumLabels, icebergSchemaField.EnumLabels) {
+			ambiguousColumnNames.Add(icebergSchemaField.Name)
+		}
+		enumLabelsByColumnName[icebergSchemaField.Name] = icebergSchemaField.EnumLabels
+	}
+
+	for columnName := range enumLabelsByColumnName {
+		if ambiguousColumnNames.Contains(columnName) {
+			delete(enumLabelsByColumnName, columnName)
+		}
+	}
+	return enumLabelsByColumnName
+}
+
+// Names of the range columns of the tables read by the statement, columns with the same name that aren't ranges are left out
+func (remapper *SelectRemapperTable) RangeColumnNames(node *pgQuery.Node) *Set {
+	rangeColumnNames := NewSet([]string{})
+	otherColumnNames := NewSet([]string{})
+	for _, icebergSchemaField := range remapper.statementIcebergSchemaFields(node) {
+		if icebergSchemaField.RangeType == "" {
+			otherColumnNames.Add(icebergSchemaField.Name)
+		} else {
+			rangeColumnNames.Add(icebergSchemaField.Name)
+		}
+	}
+
+	for _, columnName := range otherColumnNames.Values() {
+		rangeColumnNames.Remove(columnName)
+	}
+	return rangeColumnNames
+}
+
+// Schema fields of the Iceberg tables read by the statement at their referenced snapshots
+func (remapper *SelectRemapperTable) statementIcebergSchemaFields(node *pgQuery.Node) (icebergSchemaFields []IcebergSchemaField) {
+	remapper.icebergSchemaFieldsCache = map[string][]IcebergSchemaField{}
+
+	cteNames := remapper.parserTable.CommonTableExpressionNames(node)
+	for _, rangeVar := range remapper.parserTable.RangeVars(node) {
+		qSchemaTable := QuerySchemaTable{Schema: rangeVar.Schemaname, Table: rangeVar.Relname}
+		if qSchemaTable.Schema == "" {
+			if cteNames.Contains(qSchemaTable.Table) {
+				continue
+			}
+			qSchemaTable.Schema = remapper.searchPathSchema(qSchemaTable)
+		}
+		schemaTable, icebergRef := remapper.splitIcebergRef(qSchemaTable)
+		if !remapper.icebergSchemaTableExists(schemaTable) {
+			continue
+		}
+		snapshotId, err := remapper.icebergSnapshotId(schemaTable, icebergRef)
+		if err != nil {
+			continue
+		}
+
+		icebergSchemaFields = append(icebergSchemaFields, remapper.icebergSchemaFields(schemaTable, snapshotId)...)
+	}
+	return icebergSchemaFields
+}
+
+// FROM [PG_FUNCTION()]
+func (remapper *SelectRemapperTable) RemapTableFunction(node *pgQuery.Node) *pgQuery.Node {
+	parser := remapper.parserTable
+
+	// pg_catalog.generate_series() -> generate_series(), etc.
+	parser.RemovePgCatalogSchemaFromTableFunctions(node)
+
+	// pg_get_keywords() -> hard-coded keywords
+	if parser.IsPgGetKeywordsFunction(node) {
+		return parser.MakePgGetKeywordsNode(node)
+	}
+
+	// pg_show_all_settings() -> duckdb_settings()
+	if parser.IsPgShowAllSettingsFunction(node) {
+		return parser.MakePgShowAllSettingsNode(node)
+	}
+
+	// pg_is_in_recovery() -> 'f'::bool
+	if parser.IsPgIsInRecoveryFunction(node) {
+		return parser.MakePgIsInRecoveryNode(node)
+	}
+
+	// unnest(array1, array2) -> (SELECT unnest(array1), unnest(array2))
+	if parser.IsUnnestMultipleArraysFunction(node) {
+		return parser.MakeUnnestMultipleArraysNode(node)
+	}
+
+	return node
+}
+
+// FROM PG_FUNCTION(PG_NESTED_FUNCTION())
+func (remapper *SelectRemapperTable) RemapNestedTableFunction(funcCallNode *pgQuery.FuncCall) *pgQuery.FuncCall {
+	// array_upper(values, 1) -> len(values)
+	if remapper.parserTable.IsArrayUpperFunction(funcCallNode) {
+		return remapper.parserTable.MakeArrayUpperNode(funcCallNode)
+	}
+
+	return funcCallNode
+}
+
+func (remapper *SelectRemapperTable) overrideTable(node *pgQuery.Node, fromClause *pgQuery.Node) *pgQuery.Node {
+	node = fromClause
+	return node
+}
+
+func (remapper *SelectRemapperTable) reloadIceberSchemaTables() {
+	icebergSchemaTables, err := remapper.icebergReader.SchemaTables()
+	PanicIfError(err)
+
+	ctx := context.Background()
+	for _, icebergSchemaTable := range icebergSchemaTables {
+		// Schemas synced since the start don't exist in DuckDB yet, aliased schemas are listed under their exposed name
+		exposedSchemaTable := remapper.exposedSchemaTable(icebergSchemaTable)
+		remapper.duckdb.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS \"$schema\"", map[string]string{"schema": exposedSchemaTable.Schema})
+		remapper.duckdb.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+exposedSchemaTable.String()+" (id INT)", nil)
+	}
+
+	remapper.icebergSchemaTables = icebergSchemaTables
+}
+
+func (remapper *SelectRemapperTable) pgNamespaceSchemas() []string {
+	schemas := NewSet([]string{PG_SCHEMA_PUBLIC, PG_SCHEMA_PG_CATALOG, PG_SCHEMA_INFORMATION_SCHEMA})
+	for _, icebergSchemaTable := range remapper.icebergSchemaTables {
+		schemas.Add(remapper.exposedSchemaTable(icebergSchemaTable).Schema)
+	}
+	return schemas.Values()
+}
+
+// Snapshot of the ref, the snapshot id or time after @, or the one that was current at bemidb.snapshot_time, 0 to read the current one
+func (remapper *SelectRemapperTable) icebergSnapshotId(schemaTable IcebergSchemaTable, icebergRef string) (int64, error) {
+	if icebergRef != "" {
+		return remapper.icebergRefSnapshotId(schemaTable, icebergRef)
+	}
+	if !remapper.session.SnapshotTime.IsZero() {
+		return remapper.icebergReader.SnapshotIdAt(schemaTable, remapper.session.SnapshotTime)
+	}
+	return 0, nil
+}
+
+// "table@staging" -> the branch or tag, "table@2025-01-01 12:00:00" -> the snapshot current on main at that time, "table@123" -> the snapshot id
+func (remapper *SelectRemapperTable) icebergRefSnapshotId(schemaTable IcebergSchemaTable, icebergRef string) (int64, error) {
+	snapshotId, err := remapper.icebergReader.RefSnapshotId(schemaTable, icebergRef)
+	if err == nil {
+		return snapshotId, nil
+	}
+
+	if snapshotId, parseErr := strconv.ParseInt(icebergRef, 10, 64); parseErr == nil {
+		exists, err := remapper.icebergReader.SnapshotIdExists(schemaTable, snapshotId)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, fmt.Errorf("Failed to find snapshot %d in %s", snapshotId, schemaTable.String())
+		}
+		return snapshotId, nil
+	}
+
+	if snapshotTime, parseErr := ParseSnapshotTime(icebergRef); parseErr == nil {
+		return remapper.icebergReader.SnapshotIdAt(schemaTable, snapshotTime)
+	}
+
+	return 0, err
+}
+
+// FROM "table@2020-01-01" -> an error if the table has no snapshot at that time instead of reading an empty table
+func (remapper *SelectRemapperTable) CheckIcebergSnapshot(rangeVar *pgQuery.RangeVar) error {
+	qSchemaTable := QuerySchemaTable{Schema: rangeVar.Schemaname, Table: rangeVar.Relname}
+	if remapper.parserTable.IsTableFromPgCatalog(qSchemaTable) || remapper.parserTable.IsTableFromInformationSchema(qSchemaTable) {
+		return nil
+	}
+	if qSchemaTable.Schema == "" {
+		qSchemaTable.Schema = remapper.searchPathSchema(qSchemaTable)
+	}
+
+	schemaTable, icebergRef := remapper.splitIcebergRef(qSchemaTable)
+	if icebergRef == "" && remapper.session.SnapshotTime.IsZero() {
+		return nil
+	}
+	if !remapper.icebergSchemaTableExists(schemaTable) {
+		return nil
+	}
+
+	_, err := remapper.icebergSnapshotId(schemaTable, icebergRef)
+	return err
+}
+
+// Downloads the snapshot into a temporary directory if DuckDB can't read the storage directly
+func (remapper *SelectRemapperTable) icebergMetadataFilePath(schemaTable IcebergSchemaTable, snapshotId int64) (string, error) {
+	if !remapper.icebergReader.ReadsLocalCopies() {
+		return remapper.icebergReader.MetadataFilePath(schemaTable), nil
+	}
+
+	cacheKey := schemaTable.String() + ICEBERG_REF_SEPARATOR + strconv.FormatInt(snapshotId, 10)
+	if localCopyPath, ok := remapper.localCopyPaths[cacheKey]; ok {
+		return localCopyPath, nil
+	}
+
+	localDirPath, err := os.MkdirTemp("", "bemidb-iceberg-")
+	if err != nil {
+		return "", err
+	}
+	remapper.localCopyDirPaths = append(remapper.localCopyDirPaths, localDirPath)
+	localCopyPath, err := remapper.icebergReader.DownloadSnapshot(schemaTable, snapshotId, localDirPath)
+	if err != nil {
+		return "", err
+	}
+
+	if remapper.localCopyPaths == nil {
+		remapper.localCopyPaths = map[string]string{}
+	}
+	remapper.localCopyPaths[cacheKey] = localCopyPath
+	return localCopyPath, nil
+}
+
+// Hands over the directories of the local copies made for the remapped statements, to remove them once they have run
+func (remapper *SelectRemapperTable) TakeLocalCopyDirPaths() []string {
+	localCopyDirPaths := remapper.localCopyDirPaths
+	remapper.localCopyPaths = nil
+	remapper.localCopyDirPaths = nil
+	return localCopyDirPaths
+}
+
+// Hands over the passthrough tables read by the remapped statements, to run them on the source database
+func (remapper *SelectRemapperTable) TakePassthroughSchemaTables() []IcebergSchemaTable {
+	passthroughSchemaTables := remapper.passthroughSchemaTables
+	remapper.passthroughSchemaTables = nil
+	return passthroughSchemaTables
+}
+
+// Hands over the error of a table that couldn't be read at its snapshot, e.g. an unknown ref or a time before the oldest snapshot
+func (remapper *SelectRemapperTable) TakeRemapTableError() error {
+	err := remapper.remapTableErr
+	remapper.remapTableErr = nil
+	return err
+}
+
+func (remapper *SelectRemapperTable) setRemapTableError(err error) {
+	if remapper.remapTableErr == nil {
+		remapper.remapTableErr = err
+	}
+}
+
+func (remapper *SelectRemapperTable) isPassthroughTable(schemaTable IcebergSchemaTable) bool {
+	passthroughTables := remapper.config.Pg.PassthroughTables
+	return passthroughTables != nil && passthroughTables.Contains(schemaTable.Schema+"."+schemaTable.Table)
+}
+
+// Reads the schema fields once per statement, enum columns are cast to their declared order when the table is read
+func (remapper *SelectRemapperTable) icebergSchemaFields(schemaTable IcebergSchemaTable, snapshotId int64) []IcebergSchemaField {
+	cacheKey := schemaTable.String() + ICEBERG_REF_SEPARATOR + strconv.FormatInt(snapshotId, 10)
+	if icebergSchemaFields, ok := remapper.icebergSchemaFieldsCache[cacheKey]; ok {
+		return icebergSchemaFields
+	}
+
+	icebergSchemaFields, err := remapper.icebergReader.SchemaFields(schemaTable, snapshotId)
+	if err != nil {
+		LogWarn(remapper.config, "Couldn't read Iceberg schema:", err)
+	}
+	remapper.icebergSchemaFieldsCache[cacheKey] = icebergSchemaFields
+	return icebergSchemaFields
+}
+
+func (remapper *SelectRemapperTable) icebergPrimaryKeys() []PrimaryKey {
+	remapper.reloadIceberSchemaTables()
+
+	var primaryKeys []PrimaryKey
+	for _, icebergSchemaTable := range remapper.icebergSchemaTables {
+		columnNames, err := remapper.icebergReader.PrimaryKey(icebergSchemaTable)
+		if err != nil {
+			LogWarn(remapper.config, "Couldn't read Iceberg primary key:", err)
+			continue
+		}
+		if len(columnNames) > 0 {
+			primaryKeys = append(primaryKeys, PrimaryKey{SchemaTable: remapper.exposedSchemaTable(icebergSchemaTable), ColumnNames: columnNames})
+		}
+	}
+	return primaryKeys
+}
+
+func (remapper *SelectRemapperTable) icebergTablesSchemaFields() []TableSchemaFields {
+	remapper.reloadIceberSchemaTables()
+
+	var tablesSchemaFields []TableSchemaFields
+	for _, icebergSchemaTable := range remapper.icebergSchemaTables {
+		icebergSchemaFields := remapper.icebergSchemaFields(icebergSchemaTable, 0)
+		tablesSchemaFields = append(tablesSchemaFields, TableSchemaFields{SchemaTable: remapper.exposedSchemaTable(icebergSchemaTable), SchemaFields: icebergSchemaFields})
+	}
+	return tablesSchemaFields
+}
+
+// FROM Users -> FROM "Users" if public."Users" is the only synced table with that name ignoring case.
+// Unquoted names are folded to lowercase by the parser like in Postgres, so without case-insensitive table names a mixed-case
+// table must be quoted. It's an error instead of DuckDB matching the placeholder table ignoring case and returning no rows
+func (remapper *SelectRemapperTable) RemapTableNameCase(rangeVar *pgQuery.RangeVar) error {
+	qSchemaTable := QuerySchemaTable{Schema: rangeVar.Schemaname, Table: rangeVar.Relname}
+	if remapper.parserTable.IsTableFromPgCatalog(qSchemaTable) || remapper.parserTable.IsTableFromInformationSchema(qSchemaTable) {
+		return nil
+	}
+	if qSchemaTable.Schema == "" {
+		qSchemaTable.Schema = remapper.searchPathSchema(qSchemaTable)
+	}
+
+	schemaTable, icebergRef := remapper.splitIcebergRef(qSchemaTable)
+	if remapper.icebergSchemaTableExists(schemaTable) {
+		return nil
+	}
+	remapper.reloadIceberSchemaTables()
+	if remapper.icebergSchemaTableExists(schemaTable) {
+		return nil
+	}
+
+	// Matched by the exposed names of aliased schemas, which are then remapped like any other table
+	var matches []IcebergSchemaTable
+	exposedSchemaTable := remapper.exposedSchemaTable(schemaTable)
+	for _, icebergSchemaTable := range remapper.icebergSchemaTables {
+		icebergSchemaTable = remapper.exposedSchemaTable(icebergSchemaTable)
+		if strings.EqualFold(icebergSchemaTable.Schema, exposedSchemaTable.Schema) && strings.EqualFold(icebergSchemaTable.Table, exposedSchemaTable.Table) {
+			matches = append(matches, icebergSchemaTable)
+		}
+	}
+
+	var matchNames []string
+	for _, match := range matches {
+		matchNames = append(matchNames, match.String())
+	}
+	sort.Strings(matchNames)
+
+	switch {
+	case len(matches) == 0:
+		return nil // Let it return "Catalog Error: Table with name _ does not exist!"
+	case !remapper.config.CaseInsensitiveTables:
+		return fmt.Errorf("relation \"%s\" does not exist, quote the mixed-case name to query %s", rangeVar.Relname, strings.Join(matchNames, ", "))
+	case len(matches) == 1:
+		LogDebug(remapper.config, "Using", matches[0].String(), "for", schemaTable.String())
+		if rangeVar.Schemaname != "" {
+			rangeVar.Schemaname = matches[0].Schema
+		}
+		rangeVar.Relname = matches[0].Table
+		if icebergRef != "" {
+			rangeVar.Relname += ICEBERG_REF_SEPARATOR + icebergRef
+		}
+		return nil
+	default:
+		return fmt.Errorf("table name \"%s\" is ambiguous, it matches %s", rangeVar.Relname, strings.Join(matchNames, ", "))
+	}
+}
+
+// FROM table -> the first schema in the session's search_path with a synced table of that name, public if there is none
+func (remapper *SelectRemapperTable) searchPathSchema(qSchemaTable QuerySchemaTable) string {
+	if remapper.session.SearchPath == nil {
+		return PG_SCHEMA_PUBLIC
+	}
+
+	schemas := remapper.session.SearchPathSchemas(remapper.config.User)
+	for _, reload := range []bool{false, true} {
+		if reload {
+			remapper.reloadIceberSchemaTables()
+		}
+		for _, schema := range schemas {
+			schemaTable, _ := remapper.splitIcebergRef(QuerySchemaTable{Schema: schema, Table: qSchemaTable.Table})
+			if remapper.icebergSchemaTableExists(schemaTable) {
+				return schema
+			}
+		}
+	}
+	return PG_SCHEMA_PUBLIC
+}
+
+// Schemas of the session's search_path that exist, in order, like current_schemas(false) in Postgres
+func (remapper *SelectRemapperTable) ExistingSearchPathSchemas() []string {
+	schemas := remapper.session.SearchPathSchemas(remapper.config.User)
+
+	var existingSchemas []string
+	for _, reload := range []bool{false, true} {
+		if reload {
+			remapper.reloadIceberSchemaTables()
+		}
+		existingSchemas = nil
+		pgNamespaceSchemas := remapper.pgNamespaceSchemas()
+		for _, schema := range schemas {
+			if slices.Contains(pgNamespaceSchemas, schema) {
+				existingSchemas = append(existingSchemas, schema)
+			}
+		}
+		// The default "$user" schema usually doesn't exist, schemas set explicitly may have been synced since the last reload
+		if len(existingSchemas) == len(schemas) || remapper.session.SearchPath == nil {
+			break
+		}
+	}
+	return existingSchemas
+}
+
+// table.files -> the synced table in the search path, schema.table.files -> the synced table in the schema.
+// A synced table named "files" or "snapshots" takes precedence over the metadata table
+func (remapper *SelectRemapperTable) metadataTableSchemaTable(node *pgQuery.Node, qSchemaTable QuerySchemaTable) (IcebergSchemaTable, bool) {
+	if qSchemaTable.Schema == "" || (qSchemaTable.Table != ICEBERG_TABLE_FILES && qSchemaTable.Table != ICEBERG_TABLE_SNAPSHOTS) {
+		return IcebergSchemaTable{}, false
+	}
+
+	schemaTable := IcebergSchemaTable{Schema: node.GetRangeVar().Catalogname, Table: qSchemaTable.Schema}
+	if schemaTable.Schema == "" {
+		schemaTable.Schema = remapper.searchPathSchema(QuerySchemaTable{Table: schemaTable.Table})
+	}
+	schemaTable = remapper.icebergSchemaTable(schemaTable)
+	for _, reload := range []bool{false, true} {
+		if reload {
+			remapper.reloadIceberSchemaTables()
+		}
+		if remapper.icebergSchemaTableExists(remapper.icebergSchemaTable(qSchemaTable.ToIcebergSchemaTable())) {
+			return IcebergSchemaTable{}, false
+		}
+		if remapper.icebergSchemaTableExists(schemaTable) {
+			return schemaTable, true
+		}
+	}
+	return IcebergSchemaTable{}, false
+}
+
+// "analytics.orders@main" -> lake_analytics.orders, "main" with --schema-aliases analytics=lake_analytics
+func (remapper *SelectRemapperTable) splitIcebergRef(qSchemaTable QuerySchemaTable) (IcebergSchemaTable, string) {
+	schemaTable, icebergRef := remapper.parserTable.SplitIcebergRef(qSchemaTable)
+	return remapper.icebergSchemaTable(schemaTable), icebergRef
+}
+
+// analytics.orders -> lake_analytics.orders with --schema-aliases analytics=lake_analytics
+func (remapper *SelectRemapperTable) icebergSchemaTable(schemaTable IcebergSchemaTable) IcebergSchemaTable {
+	if icebergSchema, ok := remapper.config.SchemaAliases[schemaTable.Schema]; ok {
+		schemaTable.Schema = icebergSchema
+	}
+	return schemaTable
+}
+
+// lake_analytics.orders -> analytics.orders with --schema-aliases analytics=lake_analytics
+func (remapper *SelectRemapperTable) exposedSchemaTable(schemaTable IcebergSchemaTable) IcebergSchemaTable {
+	for exposedSchema, icebergSchema := range remapper.config.SchemaAliases {
+		if icebergSchema == schemaTable.Schema {
+			schemaTable.Schema = exposedSchema
+			break
+		}
+	}
+	return schemaTable
+}
+
+// Case-sensitive like quoted identifiers, the names are the Iceberg prefixes as listed
+func (remapper *SelectRemapperTable) icebergSchemaTableExists(schemaTable IcebergSchemaTable) bool {
+	for _, icebergSchemaTable := range remapper.icebergSchemaTables {
+		if icebergSchemaTable == schemaTable {
+			return true
+		}
+	}
+	return false
+}
+
+var PG_INHERITS_COLUMNS = []string{
+	"inhrelid",
+	"inhparent",
+	"inhseqno",
+	"inhdetachpending",
+}
+
+var PG_SHDESCRIPTION_COLUMNS = []string{
+	"objoid",
+	"classoid",
+	"description",
+}
+
+var PG_STATIO_USER_TABLES_COLUMNS = []string{
+	"relid",
+	"schemaname",
+	"relname",
+	"heap_blks_read",
+	"heap_blks_hit",
+	"idx_blks_read",
+	"idx_blks_hit",
+	"toast_blks_read",
+	"toast_blks_hit",
+	"tidx_blks_read",
+	"tidx_blks_hit",
+}
+
+var PG_REPLICATION_SLOTS_COLUMNS = []string{
+	"slot_name",
+	"plugin",
+	"slot_type",
+	"datoid",
+	"database",
+	"temporary",
+	"active",
+	"active_pid",
+	"xmin",
+	"catalog_xmin",
+	"restart_lsn",
+	"confirmed_flush_lsn",
+	"wal_status",
+	"safe_wal_size",
+	"two_phase",
+	"conflicting",
+}
+
+var PG_STAT_GSSAPI_COLUMNS = []string{
+	"pid",
+	"gss_authenticated",
+	"principal",
+	"encrypted",
+	"credentials_delegated",
+}
+
+var PG_AUTH_MEMBERS_COLUMNS = []string{
+	"oid",
+	"roleid",
+	"member",
+	"grantor",
+	"admin_option",
+	"inherit_option",
+	"set_option",
+}
+
+var PG_STAT_ACTIVITY_COLUMNS = []string{
+	"datid",
+	"datname",
+	"pid",
+	"usesysid",
+	"usename",
+	"application_name",
+	"client_addr",
+	"client_hostname",
+	"client_port",
+	"backend_start",
+	"xact_start",
+	"query_start",
+	"state_change",
+	"wait_event_type",
+	"wait_event",
+	"state",
+	"backend_xid",
+	"backend_xmin",
+	"query",
+	"backend_type",
+}
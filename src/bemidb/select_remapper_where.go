@@ -1,4 +1,4 @@
-package main
+package bemidb
 
 import (
 	pgQuery "github.com/pganalyze/pg_query_go/v5"
@@ -41,10 +41,6 @@ func (remapper *SelectRemapperWhere) RemapWhereExpressions(selectStatement *pgQu
 func (remapper *SelectRemapperWhere) RemapWhereClauseForTable(qSchemaTable QuerySchemaTable, selectStatement *pgQuery.SelectStmt) *pgQuery.SelectStmt {
 	if remapper.parserTable.IsTableFromPgCatalog(qSchemaTable) {
 		switch qSchemaTable.Table {
-		case PG_TABLE_PG_NAMESPACE:
-			// FROM pg_catalog.pg_namespace -> FROM pg_catalog.pg_namespace WHERE nspname != 'main'
-			withoutMainSchemaWhereCondition := remapper.parserWhere.MakeExpressionNode("nspname", "!=", "main")
-			return remapper.parserWhere.AppendWhereCondition(selectStatement, withoutMainSchemaWhereCondition)
 		case PG_TABLE_PG_STATIO_USER_TABLES:
 			// FROM pg_catalog.pg_statio_user_tables -> FROM pg_catalog.pg_statio_user_tables WHERE false
 			falseWhereCondition := remapper.parserWhere.MakeFalseConditionNode()
@@ -0,0 +1,201 @@
+package bemidb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var STORAGE_TYPES = []string{STORAGE_TYPE_LOCAL, STORAGE_TYPE_S3, STORAGE_TYPE_GCS, STORAGE_TYPE_AZURE}
+
+const (
+	ICEBERG_MAIN_BRANCH     = "main"
+	ICEBERG_REF_TYPE_BRANCH = "branch"
+	ICEBERG_REF_TYPE_TAG    = "tag"
+
+	// Separates a table name from a ref in queries, e.g. "users@staging"
+	ICEBERG_REF_SEPARATOR = "@"
+
+	// Spec of the files written by BemiDB to tables that aren't partitioned
+	ICEBERG_UNPARTITIONED_SPEC_ID = 0
+	// Ids of partition fields start after the ids reserved for data files
+	ICEBERG_FIRST_PARTITION_FIELD_ID = 1000
+
+	ICEBERG_TRANSFORM_IDENTITY = "identity"
+	ICEBERG_TRANSFORM_DAY      = "day"
+)
+
+var ICEBERG_PARTITION_TRANSFORMS = []string{ICEBERG_TRANSFORM_IDENTITY, ICEBERG_TRANSFORM_DAY}
+
+// Returned when the metadata version to commit already exists, i.e. another writer committed first
+var ERROR_ICEBERG_COMMIT_CONFLICT = errors.New("Iceberg metadata version was committed by another writer")
+
+// Returned when the table has no version hint, i.e. it hasn't been written yet
+var ERROR_ICEBERG_TABLE_NOT_FOUND = errors.New("Iceberg table doesn't exist")
+
+func IcebergMetadataFileName(version int64) string {
+	return fmt.Sprintf("v%d.metadata.json", version)
+}
+
+type ParquetFileStats struct {
+	ColumnSizes     map[int]int64
+	ValueCounts     map[int]int64
+	NullValueCounts map[int]int64
+	NanValueCounts  map[int]int64
+	LowerBounds     map[int][]byte
+	UpperBounds     map[int][]byte
+	SplitOffsets    []int64
+}
+
+type ParquetFile struct {
+	Uuid        string
+	Path        string
+	Size        int64
+	RecordCount int64
+	Stats       ParquetFileStats
+	// Batches of rows left out of the file after failing to be written
+	SkippedBatches []SkippedParquetBatch
+	// Avro values of the partition the rows belong to by partition field name, empty if the table isn't partitioned
+	Partition map[string]interface{}
+}
+
+type ManifestFile struct {
+	SnapshotId int64
+	Path       string
+	Size       int64
+	// Partition spec of the data files listed in the manifest
+	PartitionSpecId int
+}
+
+type ManifestListFile struct {
+	Path string
+}
+
+// Puffin file with column statistics of a snapshot, referenced from the "statistics" list of the metadata
+type StatisticsFile struct {
+	SnapshotId int64
+	Path       string
+	Size       int64
+	FooterSize int64
+	Blobs      []PuffinBlobMetadata
+}
+
+type MetadataFile struct {
+	Version int64
+	Path    string
+}
+
+type IcebergTableStats struct {
+	DataFileCount int64
+	FilesSize     int64
+	RecordCount   int64
+}
+
+// Named reference to a snapshot, e.g. the "main" branch or an audit tag
+type IcebergRef struct {
+	SnapshotId int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+// Partition spec from the "partition-specs" list. A table keeps all specs it has had, since files written before a partition evolution still use the old one
+type IcebergPartitionSpec struct {
+	SpecId int                     `json:"spec-id"`
+	Fields []IcebergPartitionField `json:"fields"`
+}
+
+type IcebergPartitionField struct {
+	SourceId  int    `json:"source-id"`
+	FieldId   int    `json:"field-id"`
+	Name      string `json:"name"`
+	Transform string `json:"transform"`
+	// Avro type of the partition values in manifests, only known for the fields BemiDB partitions by
+	ResultType string `json:"-"`
+}
+
+// Snapshot that became current on the main branch at the time, in chronological order
+type IcebergSnapshotLogEntry struct {
+	SnapshotId  int64 `json:"snapshot-id"`
+	TimestampMs int64 `json:"timestamp-ms"`
+}
+
+// Data file of the current snapshot, as listed in its manifests
+type IcebergDataFile struct {
+	Path   string
+	Format string
+	// JSON object of the partition values, empty for unpartitioned files
+	Partition   string
+	RecordCount int64
+	Size        int64
+}
+
+// Small data files of the same partition in a manifest of the current snapshot, merged into a single data file by compaction
+type IcebergCompactionGroup struct {
+	ManifestPath  string
+	DataFilePaths []string
+	// Avro values of the partition by partition field name, nil if the manifest isn't partitioned
+	Partition      map[string]interface{}
+	RecordCount    int64
+	Size           int64
+	NanValueCounts map[int]int64
+}
+
+// Snapshot from the "snapshots" list, including the ones only retained by tags and branches
+type IcebergSnapshot struct {
+	SnapshotId       int64             `json:"snapshot-id"`
+	ParentSnapshotId int64             `json:"parent-snapshot-id"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+}
+
+type Storage interface {
+	// Read
+	IcebergSchemas() (icebergSchemas []string, err error)
+	IcebergSchemaTables() (icebersSchemaTables []IcebergSchemaTable, err error)
+	IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) (path string)
+	IcebergTableStats(icebergSchemaTable IcebergSchemaTable) (icebergTableStats IcebergTableStats, err error)
+	IcebergRefs(icebergSchemaTable IcebergSchemaTable) (icebergRefs map[string]IcebergRef, err error)
+	IcebergSnapshotLog(icebergSchemaTable IcebergSchemaTable) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error)
+	IcebergSnapshots(icebergSchemaTable IcebergSchemaTable) (icebergSnapshots []IcebergSnapshot, err error)
+	IcebergDataFiles(icebergSchemaTable IcebergSchemaTable) (icebergDataFiles []IcebergDataFile, err error)
+	IcebergPrimaryKey(icebergSchemaTable IcebergSchemaTable) (columnNames []string, err error)
+	IcebergSchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error)
+	IcebergPartitionSpecs(icebergSchemaTable IcebergSchemaTable) (icebergPartitionSpecs []IcebergPartitionSpec, err error)
+	AuditRecordsPath() (path string, err error)
+	DirectReadsSupported() (supported bool)
+	DownloadIcebergSnapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, localDirPath string) (localMetadataFilePath string, err error)
+
+	// Write
+	DeleteSchema(schema string) (err error)
+	DeleteSchemaTable(schemaTable IcebergSchemaTable) (err error)
+	CreateDataDir(schemaTable IcebergSchemaTable) (dataDirPath string)
+	CreateMetadataDir(schemaTable IcebergSchemaTable) (metadataDirPath string)
+	CreateParquet(icebergSchemaTable IcebergSchemaTable, dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) (parquetFile ParquetFile, err error)
+	CreateManifest(metadataDirPath string, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile) (manifestFile ManifestFile, err error)
+	CreateManifestList(metadataDirPath string, parquetFiles []ParquetFile, manifestFile ManifestFile) (manifestListFile ManifestListFile, err error)
+	CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error)
+	CreateVersionHint(metadataDirPath string, metadataFile MetadataFile) (err error)
+	CreateBranchMetadata(metadataDirPath string, branch string, pgSchemaColumns []PgSchemaColumn, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error)
+	UpdateIcebergRef(icebergSchemaTable IcebergSchemaTable, refName string, icebergRef IcebergRef) (err error)
+	CreateStatistics(metadataDirPath string, manifestFile ManifestFile, columnSketches *IcebergColumnSketches) (statisticsFile StatisticsFile, err error)
+	TransitionSupersededDataFiles(icebergSchemaTable IcebergSchemaTable, storageClass string) (err error)
+	DeleteOrphanedFiles(icebergSchemaTable IcebergSchemaTable, modifiedBefore time.Time) (deletedFilePaths []string, err error)
+	ExpireSnapshots(icebergSchemaTable IcebergSchemaTable, keepLast int, olderThan time.Time, dryRun bool) (expiredFilePaths []string, err error)
+	CompactDataFiles(icebergSchemaTable IcebergSchemaTable, targetFileSize int64) (compactedFilePaths []string, err error)
+	CreateAuditRecord(auditRecord AuditRecord) (err error)
+}
+
+func NewStorage(config *Config) Storage {
+	switch config.StorageType {
+	case STORAGE_TYPE_LOCAL:
+		return NewLocalStorage(config)
+	case STORAGE_TYPE_S3:
+		return NewS3Storage(config)
+	case STORAGE_TYPE_GCS:
+		return NewGcsStorage(config)
+	case STORAGE_TYPE_AZURE:
+		return NewAzureStorage(config)
+	}
+
+	return nil
+}
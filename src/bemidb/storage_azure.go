@@ -0,0 +1,883 @@
+package bemidb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+type StorageAzure struct {
+	blobClient  *AzureBlobClient
+	config      *Config
+	storageBase *StorageBase
+}
+
+// Authenticates with the account key if it's set, or with the default Azure credential chain otherwise, see NewAzureBlobClient
+func NewAzureStorage(config *Config) *StorageAzure {
+	return &StorageAzure{
+		blobClient:  NewAzureBlobClient(config),
+		config:      config,
+		storageBase: &StorageBase{config: config},
+	}
+}
+
+// Read ----------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageAzure) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	version, err := storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		version = 1 // Let the reader fail on the missing table
+	}
+	return storage.metadataFilePath(icebergSchemaTable, version)
+}
+
+func (storage *StorageAzure) IcebergSchemas() (icebergSchemas []string, err error) {
+	schemasPrefix := storage.config.StoragePath + "/"
+	icebergSchemas, err = storage.nestedDirectoryPrefixes(schemasPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, schema := range icebergSchemas {
+		schemaParts := strings.Split(schema, "/")
+		icebergSchemas[i] = schemaParts[len(schemaParts)-2]
+	}
+
+	return icebergSchemas, nil
+}
+
+func (storage *StorageAzure) IcebergSchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	icebergSchemas, err := storage.nestedDirectoryPrefixes(storage.config.StoragePath + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schemaPrefix := range icebergSchemas {
+		schemaParts := strings.Split(schemaPrefix, "/")
+		icebergSchema := schemaParts[len(schemaParts)-2]
+
+		tables, err := storage.nestedDirectoryPrefixes(schemaPrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tablePrefix := range tables {
+			tableParts := strings.Split(tablePrefix, "/")
+			table := tableParts[len(tableParts)-2]
+
+			icebergSchemaTables = append(icebergSchemaTables, IcebergSchemaTable{Schema: icebergSchema, Table: table})
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+func (storage *StorageAzure) IcebergTableStats(icebergSchemaTable IcebergSchemaTable) (icebergTableStats IcebergTableStats, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return IcebergTableStats{}, err
+	}
+
+	return storage.storageBase.ParseIcebergTableStats(metadataContent)
+}
+
+func (storage *StorageAzure) IcebergRefs(icebergSchemaTable IcebergSchemaTable) (icebergRefs map[string]IcebergRef, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergRefs(metadataContent)
+}
+
+func (storage *StorageAzure) IcebergSnapshotLog(icebergSchemaTable IcebergSchemaTable) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshotLog(metadataContent)
+}
+
+func (storage *StorageAzure) IcebergSnapshots(icebergSchemaTable IcebergSchemaTable) (icebergSnapshots []IcebergSnapshot, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshots(metadataContent)
+}
+
+func (storage *StorageAzure) IcebergDataFiles(icebergSchemaTable IcebergSchemaTable) (icebergDataFiles []IcebergDataFile, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergDataFiles(metadataContent, func(path string) (io.ReadCloser, error) {
+		return storage.blobClient.GetBlob(strings.TrimPrefix(path, storage.fullContainerPath()))
+	})
+}
+
+// DuckDB reads the container with its azure extension, using the same account key or credential chain
+func (storage *StorageAzure) DirectReadsSupported() bool {
+	return true
+}
+
+func (storage *StorageAzure) DownloadIcebergSnapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, localDirPath string) (localMetadataFilePath string, err error) {
+	metadataContent, version, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return "", err
+	}
+
+	return storage.storageBase.DownloadIcebergSnapshot(IcebergMetadataFileName(version), metadataContent, snapshotId, localDirPath, func(path string) (io.ReadCloser, error) {
+		return storage.blobClient.GetBlob(strings.TrimPrefix(path, storage.fullContainerPath()))
+	})
+}
+
+func (storage *StorageAzure) IcebergPrimaryKey(icebergSchemaTable IcebergSchemaTable) (columnNames []string, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPrimaryKey(metadataContent)
+}
+
+func (storage *StorageAzure) IcebergSchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSchemaFields(metadataContent, snapshotId)
+}
+
+func (storage *StorageAzure) IcebergPartitionSpecs(icebergSchemaTable IcebergSchemaTable) (icebergPartitionSpecs []IcebergPartitionSpec, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+// Glob of the audit record blobs for DuckDB, empty if there are none yet
+func (storage *StorageAzure) AuditRecordsPath() (path string, err error) {
+	blobs, _, err := storage.blobClient.ListBlobs(storage.config.AuditLogPath+"/", "", 1)
+	if err != nil {
+		return "", fmt.Errorf("Failed to list audit records: %v", err)
+	}
+	if len(blobs) == 0 {
+		return "", nil
+	}
+
+	return storage.fullContainerPath() + storage.config.AuditLogPath + "/*.json", nil
+}
+
+// Write ---------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageAzure) DeleteSchema(schema string) (err error) {
+	return storage.deleteNestedBlobs(storage.config.StoragePath + "/" + schema + "/")
+}
+
+func (storage *StorageAzure) DeleteSchemaTable(schemaTable IcebergSchemaTable) (err error) {
+	return storage.deleteNestedBlobs(storage.tablePrefix(schemaTable))
+}
+
+func (storage *StorageAzure) CreateDataDir(schemaTable IcebergSchemaTable) (dataDirPath string) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return tablePrefix + "data"
+}
+
+func (storage *StorageAzure) CreateMetadataDir(schemaTable IcebergSchemaTable) (metadataDirPath string) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return tablePrefix + "metadata"
+}
+
+// The Parquet file is written to a temporary file first and uploaded once it is complete
+func (storage *StorageAzure) CreateParquet(icebergSchemaTable IcebergSchemaTable, dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) (parquetFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := dataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("parquet")
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+	tempFile.Close()
+
+	fileWriter, err := local.NewLocalFileWriter(tempFile.Name())
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, nanValueCounts, skippedBatches, err := storage.storageBase.WriteParquetFile(icebergSchemaTable, fileWriter, pgSchemaColumns, loadRows)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	fileSize, parquetStats, err := storage.uploadParquet(fileKey, tempFile.Name())
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = nanValueCounts
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	return ParquetFile{
+		Uuid:           uuid,
+		Path:           fileKey,
+		Size:           fileSize,
+		RecordCount:    recordCount,
+		SkippedBatches: skippedBatches,
+		Stats:          parquetStats,
+	}, nil
+}
+
+func (storage *StorageAzure) CreateManifest(metadataDirPath string, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile) (manifestFile ManifestFile, err error) {
+	fileName := fmt.Sprintf("%s-m0.avro", parquetFiles[0].Uuid)
+	filePath := metadataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	manifestFile, err = storage.storageBase.WriteManifestFile(storage.fullContainerPath(), tempFile.Name(), icebergPartitionSpec, parquetFiles)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	LogDebug(storage.config, "Manifest file created at:", filePath)
+
+	manifestFile.Path = filePath
+	return manifestFile, nil
+}
+
+func (storage *StorageAzure) CreateManifestList(metadataDirPath string, parquetFiles []ParquetFile, manifestFile ManifestFile) (manifestListFile ManifestListFile, err error) {
+	fileName := fmt.Sprintf("snap-%d-0-%s.avro", manifestFile.SnapshotId, parquetFiles[0].Uuid)
+	filePath := metadataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteManifestListFile(storage.fullContainerPath(), tempFile.Name(), parquetFiles, manifestFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", filePath)
+
+	return ManifestListFile{Path: filePath}, nil
+}
+
+func (storage *StorageAzure) CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CreateOrCommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		func() ([]byte, error) {
+			return storage.storageBase.NewIcebergMetadata(storage.fullContainerPath(), metadataDirPath+"/"+IcebergMetadataFileName(1), pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(metadataContent []byte) ([]byte, error) {
+			return storage.storageBase.AddIcebergSnapshot(storage.fullContainerPath(), metadataContent, pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	filePath := metadataDirPath + "/" + IcebergMetadataFileName(version)
+	LogDebug(storage.config, "Metadata file created at:", filePath)
+
+	return MetadataFile{Version: version, Path: filePath}, nil
+}
+
+func (storage *StorageAzure) CreateVersionHint(metadataDirPath string, metadataFile MetadataFile) (err error) {
+	filePath := metadataDirPath + "/" + VERSION_HINT_FILE_NAME
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteVersionHintFile(tempFile.Name(), metadataFile)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Version hint file created at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageAzure) CreateBranchMetadata(metadataDirPath string, branch string, pgSchemaColumns []PgSchemaColumn, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	metadataFile, err = storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergBranchSnapshot(storage.fullContainerPath(), metadataContent, branch, pgSchemaColumns, parquetFiles, manifestFile, manifestListFile)
+	})
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with branch", branch, "at:", metadataFile.Path)
+
+	return metadataFile, nil
+}
+
+func (storage *StorageAzure) UpdateIcebergRef(icebergSchemaTable IcebergSchemaTable, refName string, icebergRef IcebergRef) (err error) {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.SetIcebergRef(metadataContent, refName, icebergRef)
+	})
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Metadata file created with", icebergRef.Type, refName, "at:", metadataFile.Path)
+
+	return storage.CreateVersionHint(metadataDirPath, metadataFile)
+}
+
+func (storage *StorageAzure) CreateStatistics(metadataDirPath string, manifestFile ManifestFile, columnSketches *IcebergColumnSketches) (statisticsFile StatisticsFile, err error) {
+	fileName := fmt.Sprintf("%d-%s.stats", manifestFile.SnapshotId, uuid.New().String())
+	filePath := metadataDirPath + "/" + fileName
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	tempFile, err := CreateTemporaryFile("statistics")
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	statisticsFile, err = storage.storageBase.WriteStatisticsFile("", tempFile.Name(), metadataContent, manifestFile.SnapshotId, columnSketches)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	statisticsFile.Path = storage.fullContainerPath() + filePath
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Statistics file created at:", filePath)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergStatistics(metadataContent, statisticsFile)
+	})
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with statistics at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	return statisticsFile, nil
+}
+
+func (storage *StorageAzure) TransitionSupersededDataFiles(icebergSchemaTable IcebergSchemaTable, storageClass string) (err error) {
+	LogDebug(storage.config, "Storage class transitions are only supported on AWS S3, skipping transition of", icebergSchemaTable.String())
+	return nil
+}
+
+// Deletes blobs under the table prefix that aren't referenced by any snapshot, e.g. left by failed syncs.
+// Recently modified blobs are kept, since they may belong to a commit that hasn't updated the metadata file yet
+func (storage *StorageAzure) DeleteOrphanedFiles(icebergSchemaTable IcebergSchemaTable, modifiedBefore time.Time) (deletedFilePaths []string, err error) {
+	tablePrefix := storage.tablePrefix(icebergSchemaTable, true)
+	metadataContent, version, err := storage.readCurrentMetadata(tablePrefix + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	referencedFilePaths, err := storage.storageBase.ReferencedFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+		return storage.blobClient.GetBlob(strings.TrimPrefix(path, storage.fullContainerPath()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	referencedFilePaths.Add(storage.metadataFilePath(icebergSchemaTable, version))
+	referencedFilePaths.Add(storage.fullContainerPath() + tablePrefix + "metadata/" + VERSION_HINT_FILE_NAME)
+
+	blobs, _, err := storage.blobClient.ListBlobs(tablePrefix, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list blobs: %v", err)
+	}
+	for _, blob := range blobs {
+		if referencedFilePaths.Contains(storage.fullContainerPath()+blob.Name) || !blob.LastModified.Before(modifiedBefore) {
+			continue
+		}
+
+		LogDebug(storage.config, "Orphaned blob to delete:", blob.Name)
+		err = storage.blobClient.DeleteBlob(blob.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to delete orphaned blob %s: %v", blob.Name, err)
+		}
+		deletedFilePaths = append(deletedFilePaths, blob.Name)
+	}
+
+	return deletedFilePaths, nil
+}
+
+// Expires snapshots older than olderThan except the last keepLast and the ones referenced by branches and tags, see StorageBase.ExpireIcebergSnapshots.
+// The expired snapshots are removed from a new metadata version first, then the files only they referenced are deleted
+func (storage *StorageAzure) ExpireSnapshots(icebergSchemaTable IcebergSchemaTable, keepLast int, olderThan time.Time, dryRun bool) (expiredFilePaths []string, err error) {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	openFile := func(path string) (io.ReadCloser, error) {
+		return storage.blobClient.GetBlob(strings.TrimPrefix(path, storage.fullContainerPath()))
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	updatedMetadataContent, expiredSnapshotIds, err := storage.storageBase.ExpireIcebergSnapshots(metadataContent, keepLast, olderThan)
+	if err != nil {
+		return nil, err
+	}
+	if len(expiredSnapshotIds) == 0 {
+		LogDebug(storage.config, "No snapshots to expire in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	if dryRun {
+		expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, expiredFilePath := range expiredFilePaths {
+			LogInfo(storage.config, "Dry run, expired file to delete:", expiredFilePath)
+		}
+		return expiredFilePaths, nil
+	}
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(currentMetadataContent []byte) ([]byte, error) {
+		metadataContent = currentMetadataContent
+		updatedMetadataContent, expiredSnapshotIds, err = storage.storageBase.ExpireIcebergSnapshots(currentMetadataContent, keepLast, olderThan)
+		return updatedMetadataContent, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created without", len(expiredSnapshotIds), "expired snapshot(s) at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expiredFilePath := range expiredFilePaths {
+		blobName := strings.TrimPrefix(expiredFilePath, storage.fullContainerPath())
+		err = storage.blobClient.DeleteBlob(blobName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to delete expired blob %s: %v", blobName, err)
+		}
+		LogDebug(storage.config, "Expired blob deleted:", blobName)
+	}
+
+	return expiredFilePaths, nil
+}
+
+// Merges the small data files of the current snapshot into files of up to the target size and commits them as a new snapshot.
+// The merged files are still referenced by the previous snapshots, they're deleted once those are expired
+func (storage *StorageAzure) CompactDataFiles(icebergSchemaTable IcebergSchemaTable, targetFileSize int64) (compactedFilePaths []string, err error) {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	openFile := func(path string) (io.ReadCloser, error) {
+		return storage.blobClient.GetBlob(strings.TrimPrefix(path, storage.fullContainerPath()))
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	compactedSnapshot, icebergCompactionGroups, err := storage.storageBase.PlanIcebergCompaction(metadataContent, targetFileSize, openFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(icebergCompactionGroups) == 0 {
+		LogDebug(storage.config, "No data files to compact in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	var parquetFiles []ParquetFile
+	for _, icebergCompactionGroup := range icebergCompactionGroups {
+		parquetFile, err := storage.compactParquet(icebergSchemaTable, icebergCompactionGroup)
+		if err != nil {
+			return nil, err
+		}
+		parquetFiles = append(parquetFiles, parquetFile)
+		compactedFilePaths = append(compactedFilePaths, icebergCompactionGroup.DataFilePaths...)
+	}
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return nil, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	snapshotId := time.Now().UnixNano()
+	compactedManifestFiles := map[string]ManifestFile{}
+	for i, icebergCompactionGroup := range icebergCompactionGroups {
+		if _, ok := compactedManifestFiles[icebergCompactionGroup.ManifestPath]; ok {
+			continue
+		}
+		filePath := metadataDirPath + "/" + fmt.Sprintf("%s-m0.avro", parquetFiles[i].Uuid)
+		manifestFile, err := storage.storageBase.WriteCompactedManifestFile(storage.fullContainerPath(), tempFile.Name(), snapshotId, icebergCompactionGroup.ManifestPath, icebergCompactionGroups, parquetFiles, openFile)
+		if err != nil {
+			return nil, err
+		}
+		err = storage.uploadFile(filePath, tempFile)
+		if err != nil {
+			return nil, err
+		}
+		LogDebug(storage.config, "Manifest file created at:", filePath)
+		manifestFile.Path = filePath
+		compactedManifestFiles[icebergCompactionGroup.ManifestPath] = manifestFile
+	}
+
+	manifestListFile := ManifestListFile{Path: metadataDirPath + "/" + fmt.Sprintf("snap-%d-0-%s.avro", snapshotId, parquetFiles[0].Uuid)}
+	err = storage.storageBase.WriteCompactedManifestListFile(storage.fullContainerPath(), tempFile.Name(), compactedSnapshot.ManifestList, compactedManifestFiles, icebergCompactionGroups, parquetFiles, openFile)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.uploadFile(manifestListFile.Path, tempFile)
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", manifestListFile.Path)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergCompactedSnapshot(storage.fullContainerPath(), metadataContent, compactedSnapshot.SnapshotId, snapshotId, icebergCompactionGroups, parquetFiles, manifestListFile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created with compacted snapshot at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return compactedFilePaths, nil
+}
+
+// Writes the merged file of the compaction group next to the files it merges, i.e. into the directory of their partition.
+// The merged files are downloaded to a temporary directory first, since the Parquet reader seeks within them
+func (storage *StorageAzure) compactParquet(icebergSchemaTable IcebergSchemaTable, icebergCompactionGroup IcebergCompactionGroup) (parquetFile ParquetFile, err error) {
+	dataFileKeys := make([]string, len(icebergCompactionGroup.DataFilePaths))
+	for i, dataFilePath := range icebergCompactionGroup.DataFilePaths {
+		dataFileKeys[i] = strings.TrimPrefix(dataFilePath, storage.fullContainerPath())
+	}
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := path.Dir(dataFileKeys[0]) + "/" + fileName
+
+	tempDirPath, err := os.MkdirTemp("", "compaction")
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	defer os.RemoveAll(tempDirPath)
+
+	compactedFilePath := filepath.Join(tempDirPath, fileName)
+	fileWriter, err := local.NewLocalFileWriter(compactedFilePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, err := storage.storageBase.CompactParquetFiles(icebergSchemaTable, fileWriter, dataFileKeys, func(dataFileKey string) (source.ParquetFile, error) {
+		localFilePath := filepath.Join(tempDirPath, path.Base(dataFileKey))
+		err := storage.downloadFile(dataFileKey, localFilePath)
+		if err != nil {
+			return nil, err
+		}
+		return local.NewLocalFileReader(localFilePath)
+	})
+	if err != nil {
+		return ParquetFile{}, err
+	}
+
+	fileSize, parquetStats, err := storage.uploadParquet(fileKey, compactedFilePath)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = icebergCompactionGroup.NanValueCounts
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) compacted from", len(dataFileKeys), "file(s) at:", fileKey)
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        fileSize,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+		Partition:   icebergCompactionGroup.Partition,
+	}, nil
+}
+
+// Commits the updated current metadata as a new version, see StorageBase.CommitMetadata
+func (storage *StorageAzure) commitMetadata(metadataDirPath string, updateMetadata func(metadataContent []byte) ([]byte, error)) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		updateMetadata,
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+
+	return MetadataFile{Version: version, Path: metadataDirPath + "/" + IcebergMetadataFileName(version)}, nil
+}
+
+func (storage *StorageAzure) readCurrentMetadata(metadataDirPath string) (metadataContent []byte, version int64, err error) {
+	version, err = storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	metadataContent, err = storage.readMetadataFile(metadataDirPath + "/" + IcebergMetadataFileName(version))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return metadataContent, version, nil
+}
+
+// The version hint is written after a commit, so versions committed since are found by checking the next ones
+func (storage *StorageAzure) currentMetadataVersion(metadataDirPath string) (version int64, err error) {
+	blobReader, err := storage.blobClient.GetBlob(metadataDirPath + "/" + VERSION_HINT_FILE_NAME)
+	if IsAzureBlobNotFound(err) {
+		return 0, fmt.Errorf("%w: %v", ERROR_ICEBERG_TABLE_NOT_FOUND, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get version hint file: %v", err)
+	}
+	defer blobReader.Close()
+
+	versionHintContent, err := io.ReadAll(blobReader)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read version hint file: %v", err)
+	}
+
+	version, err = storage.storageBase.ParseVersionHint(versionHintContent)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		exists, err := storage.blobClient.BlobExists(metadataDirPath + "/" + IcebergMetadataFileName(version+1))
+		if err != nil {
+			return 0, fmt.Errorf("Failed to check metadata file: %v", err)
+		}
+		if !exists {
+			return version, nil
+		}
+		version++
+	}
+}
+
+// Uploads the version with If-None-Match: *, which Azure rejects with 409 if the blob already exists
+func (storage *StorageAzure) createMetadataVersion(metadataDirPath string, version int64, metadataContent []byte) (err error) {
+	filePath := metadataDirPath + "/" + IcebergMetadataFileName(version)
+	err = storage.blobClient.PutBlob(filePath, metadataContent, true)
+	var blobError *AzureBlobError
+	if errors.As(err, &blobError) && (blobError.StatusCode == http.StatusConflict || blobError.StatusCode == http.StatusPreconditionFailed) {
+		return ERROR_ICEBERG_COMMIT_CONFLICT
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to upload metadata file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageAzure) readMetadataFile(fileKey string) (metadataContent []byte, err error) {
+	blobReader, err := storage.blobClient.GetBlob(fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get metadata file: %v", err)
+	}
+	defer blobReader.Close()
+
+	metadataContent, err = io.ReadAll(blobReader)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read metadata file: %v", err)
+	}
+
+	return metadataContent, nil
+}
+
+func (storage *StorageAzure) CreateAuditRecord(auditRecord AuditRecord) (err error) {
+	fileKey := storage.config.AuditLogPath + "/" + auditRecord.FileName()
+
+	tempFile, err := CreateTemporaryFile("audit")
+	if err != nil {
+		return err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteAuditRecordFile(tempFile.Name(), auditRecord)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(fileKey, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Audit record created at:", fileKey)
+
+	return nil
+}
+
+func (storage *StorageAzure) uploadFile(filePath string, file *os.File) (err error) {
+	err = storage.blobClient.UploadFile(filePath, file)
+	if err != nil {
+		return fmt.Errorf("Failed to upload file: %v", err)
+	}
+
+	return nil
+}
+
+// Reads the stats of the local Parquet file and uploads it
+func (storage *StorageAzure) uploadParquet(fileKey string, localFilePath string) (fileSize int64, parquetStats ParquetFileStats, err error) {
+	fileReader, err := local.NewLocalFileReader(localFilePath)
+	if err != nil {
+		return 0, ParquetFileStats{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err = storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return 0, ParquetFileStats{}, err
+	}
+
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return 0, ParquetFileStats{}, fmt.Errorf("Failed to open Parquet file for uploading: %v", err)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return 0, ParquetFileStats{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+
+	err = storage.uploadFile(fileKey, file)
+	if err != nil {
+		return 0, ParquetFileStats{}, err
+	}
+
+	return fileInfo.Size(), parquetStats, nil
+}
+
+func (storage *StorageAzure) downloadFile(fileKey string, localFilePath string) (err error) {
+	blobReader, err := storage.blobClient.GetBlob(fileKey)
+	if err != nil {
+		return fmt.Errorf("Failed to download file: %v", err)
+	}
+	defer blobReader.Close()
+
+	file, err := os.Create(localFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, blobReader)
+	if err != nil {
+		return fmt.Errorf("Failed to download file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageAzure) tablePrefix(schemaTable IcebergSchemaTable, isIcebergSchemaTable ...bool) string {
+	if len(isIcebergSchemaTable) > 0 && isIcebergSchemaTable[0] {
+		return storage.config.StoragePath + "/" + schemaTable.Schema + "/" + schemaTable.Table + "/"
+	}
+
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + schemaTable.Schema + "/" + schemaTable.Table + "/"
+}
+
+func (storage *StorageAzure) metadataFilePath(icebergSchemaTable IcebergSchemaTable, version int64) string {
+	return storage.fullContainerPath() + storage.tablePrefix(icebergSchemaTable, true) + "metadata/" + IcebergMetadataFileName(version)
+}
+
+// DuckDB's azure extension reads az:// paths with the account of its secret
+func (storage *StorageAzure) fullContainerPath() string {
+	return "az://" + storage.config.Azure.ContainerName + "/"
+}
+
+// Blob names have no directories, they're listed as the prefixes up to the next delimiter
+func (storage *StorageAzure) nestedDirectoryPrefixes(prefix string) (dirs []string, err error) {
+	_, dirs, err = storage.blobClient.ListBlobs(prefix, "/", 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list blobs: %v", err)
+	}
+
+	return dirs, nil
+}
+
+func (storage *StorageAzure) deleteNestedBlobs(prefix string) (err error) {
+	blobs, _, err := storage.blobClient.ListBlobs(prefix, "", 0)
+	if err != nil {
+		return fmt.Errorf("Failed to list blobs: %v", err)
+	}
+
+	for _, blob := range blobs {
+		LogDebug(storage.config, "Blob to delete:", blob.Name)
+		err = storage.blobClient.DeleteBlob(blob.Name)
+		if err != nil {
+			return fmt.Errorf("Failed to delete blobs: %v", err)
+		}
+	}
+
+	if len(blobs) > 0 {
+		LogDebug(storage.config, "Deleted", len(blobs), "blob(s).")
+	} else {
+		LogDebug(storage.config, "No blobs to delete.")
+	}
+
+	return nil
+}
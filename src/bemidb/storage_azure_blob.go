@@ -0,0 +1,187 @@
+package bemidb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// Files above one block are uploaded as a list of blocks, a blob can have up to 50,000 of them
+const AZURE_BLOB_BLOCK_SIZE = 100 * 1024 * 1024
+
+// Blob operations of the storage on a single container, backed by the Azure SDK for Go
+type AzureBlobClient struct {
+	config          *Config
+	containerClient *container.Client
+}
+
+type AzureBlob struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+}
+
+type AzureBlobError struct {
+	StatusCode int
+	Code       string
+}
+
+func (err *AzureBlobError) Error() string {
+	return fmt.Sprintf("Azure Blob Storage request failed with status %d: %s", err.StatusCode, err.Code)
+}
+
+func IsAzureBlobNotFound(err error) bool {
+	var blobError *AzureBlobError
+	return errors.As(err, &blobError) && blobError.StatusCode == http.StatusNotFound
+}
+
+// Authenticates with the account key if it's set. Otherwise, with the default Azure credential chain: environment variables,
+// AKS workload identity, managed identity of the VM or container, and the Azure CLI
+func NewAzureBlobClient(config *Config) *AzureBlobClient {
+	containerUrl := azureBlobEndpoint(config) + "/" + config.Azure.ContainerName
+
+	var containerClient *container.Client
+	if config.Azure.AccountKey != "" {
+		credential, err := container.NewSharedKeyCredential(config.Azure.Account, config.Azure.AccountKey)
+		PanicIfError(err)
+		containerClient, err = container.NewClientWithSharedKeyCredential(containerUrl, credential, nil)
+		PanicIfError(err)
+	} else {
+		credential, err := azidentity.NewDefaultAzureCredential(nil)
+		PanicIfError(err)
+		containerClient, err = container.NewClient(containerUrl, credential, nil)
+		PanicIfError(err)
+	}
+
+	return &AzureBlobClient{config: config, containerClient: containerClient}
+}
+
+func (client *AzureBlobClient) GetBlob(name string) (body io.ReadCloser, err error) {
+	LogTrace(client.config, "Azure Blob Storage: GET", name)
+	downloadResponse, err := client.containerClient.NewBlobClient(name).DownloadStream(context.Background(), nil)
+	if err != nil {
+		return nil, azureBlobError(err)
+	}
+
+	return downloadResponse.Body, nil
+}
+
+func (client *AzureBlobClient) BlobExists(name string) (exists bool, err error) {
+	LogTrace(client.config, "Azure Blob Storage: HEAD", name)
+	_, err = client.containerClient.NewBlobClient(name).GetProperties(context.Background(), nil)
+	err = azureBlobError(err)
+	if IsAzureBlobNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Creates or overwrites a block blob. With ifNotExists, Azure rejects the upload with 409 if the blob already exists
+func (client *AzureBlobClient) PutBlob(name string, content []byte, ifNotExists bool) (err error) {
+	uploadOptions := &blockblob.UploadBufferOptions{BlockSize: AZURE_BLOB_BLOCK_SIZE}
+	if ifNotExists {
+		uploadOptions.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		}
+	}
+
+	LogTrace(client.config, "Azure Blob Storage: PUT", name)
+	_, err = client.containerClient.NewBlockBlobClient(name).UploadBuffer(context.Background(), content, uploadOptions)
+	return azureBlobError(err)
+}
+
+// Uploads the file in one request, or block by block and then commits the list of blocks if it's larger than a block
+func (client *AzureBlobClient) UploadFile(name string, file *os.File) (err error) {
+	LogTrace(client.config, "Azure Blob Storage: PUT", name)
+	_, err = client.containerClient.NewBlockBlobClient(name).UploadFile(context.Background(), file, &blockblob.UploadFileOptions{BlockSize: AZURE_BLOB_BLOCK_SIZE})
+	return azureBlobError(err)
+}
+
+// Deleting a blob that doesn't exist isn't an error
+func (client *AzureBlobClient) DeleteBlob(name string) (err error) {
+	LogTrace(client.config, "Azure Blob Storage: DELETE", name)
+	_, err = client.containerClient.NewBlobClient(name).Delete(context.Background(), nil)
+	err = azureBlobError(err)
+	if IsAzureBlobNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Lists the blobs under the prefix page by page. With a delimiter, the blobs nested deeper are returned as prefixes, e.g. "iceberg/public/"
+func (client *AzureBlobClient) ListBlobs(prefix string, delimiter string, maxResults int) (blobs []AzureBlob, prefixes []string, err error) {
+	ctx := context.Background()
+	var maxResultsOption *int32
+	if maxResults > 0 {
+		maxResultsOption = to.Ptr(int32(maxResults))
+	}
+	appendBlobItems := func(blobItems []*container.BlobItem) {
+		for _, blobItem := range blobItems {
+			blobs = append(blobs, AzureBlob{Name: *blobItem.Name, Size: *blobItem.Properties.ContentLength, LastModified: *blobItem.Properties.LastModified})
+		}
+	}
+
+	LogTrace(client.config, "Azure Blob Storage: LIST", prefix)
+	if delimiter == "" {
+		pager := client.containerClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix, MaxResults: maxResultsOption})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, nil, azureBlobError(err)
+			}
+			appendBlobItems(page.Segment.BlobItems)
+			if maxResults > 0 && len(blobs) >= maxResults {
+				break
+			}
+		}
+		return blobs, prefixes, nil
+	}
+
+	pager := client.containerClient.NewListBlobsHierarchyPager(delimiter, &container.ListBlobsHierarchyOptions{Prefix: &prefix, MaxResults: maxResultsOption})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, nil, azureBlobError(err)
+		}
+		appendBlobItems(page.Segment.BlobItems)
+		for _, blobPrefix := range page.Segment.BlobPrefixes {
+			prefixes = append(prefixes, *blobPrefix.Name)
+		}
+		if maxResults > 0 && len(blobs)+len(prefixes) >= maxResults {
+			break
+		}
+	}
+	return blobs, prefixes, nil
+}
+
+func azureBlobEndpoint(config *Config) string {
+	if config.Azure.Endpoint != "" {
+		return config.Azure.Endpoint
+	}
+	return "https://" + config.Azure.Account + ".blob.core.windows.net"
+}
+
+// Returns an *AzureBlobError for unsuccessful responses of the Blob service, other errors as is
+func azureBlobError(err error) error {
+	var responseError *azcore.ResponseError
+	if errors.As(err, &responseError) {
+		return &AzureBlobError{StatusCode: responseError.StatusCode, Code: responseError.ErrorCode}
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package bemidb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const TEST_AZURE_ACCOUNT_KEY = "dGVzdC1hY2NvdW50LWtleQ=="
+
+func TestStorageAzurePaths(t *testing.T) {
+	config := &Config{StoragePath: "iceberg", Azure: AzureConfig{Account: "myaccount", ContainerName: "my-container"}, Pg: PgConfig{SchemaPrefix: "mydb_"}}
+	storage := NewAzureStorage(config)
+
+	t.Run("Returns the metadata file path readable by DuckDB", func(t *testing.T) {
+		metadataFilePath := storage.metadataFilePath(IcebergSchemaTable{Schema: "mydb_public", Table: "users"}, 1)
+
+		if metadataFilePath != "az://my-container/iceberg/mydb_public/users/metadata/v1.metadata.json" {
+			t.Errorf("Expected the metadata file path in the Azure container, got %s", metadataFilePath)
+		}
+	})
+
+	t.Run("Writes synced tables under the schema prefix", func(t *testing.T) {
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "users"}
+
+		if storage.CreateDataDir(schemaTable) != "iceberg/mydb_public/users/data" {
+			t.Errorf("Expected the data dir under the schema prefix, got %s", storage.CreateDataDir(schemaTable))
+		}
+	})
+}
+
+func TestStorageAzure(t *testing.T) {
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "users"}
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+	}
+
+	t.Run("Writes a table and reads it back", func(t *testing.T) {
+		config, blobs, _ := startTestAzureBlobServer(t)
+
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1", "Alice"}, {"2", "Bob"}})
+		storage := NewAzureStorage(config)
+
+		icebergSchemaTables, err := storage.IcebergSchemaTables()
+		testNoError(t, err)
+		if len(icebergSchemaTables) != 1 || icebergSchemaTables[0] != schemaTable {
+			t.Errorf("Expected the written table to be listed, got %v", icebergSchemaTables)
+		}
+		icebergTableStats, err := storage.IcebergTableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != 2 || icebergTableStats.DataFileCount != 1 {
+			t.Errorf("Expected 2 records in 1 data file, got %v", icebergTableStats)
+		}
+		icebergDataFiles, err := storage.IcebergDataFiles(schemaTable)
+		testNoError(t, err)
+		if len(icebergDataFiles) != 1 || !strings.HasPrefix(icebergDataFiles[0].Path, "az://bemidb/iceberg/public/users/data/") {
+			t.Errorf("Expected the data file in the container, got %v", icebergDataFiles)
+		}
+		if storage.IcebergMetadataFilePath(schemaTable) != "az://bemidb/iceberg/public/users/metadata/v1.metadata.json" {
+			t.Errorf("Expected the current metadata file in the container, got %s", storage.IcebergMetadataFilePath(schemaTable))
+		}
+		if _, ok := blobs.Load("iceberg/public/users/metadata/" + VERSION_HINT_FILE_NAME); !ok {
+			t.Error("Expected the version hint to be uploaded")
+		}
+	})
+
+	t.Run("Returns a commit conflict if the metadata version already exists", func(t *testing.T) {
+		config, _, _ := startTestAzureBlobServer(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1", "Alice"}})
+		storage := NewAzureStorage(config)
+
+		err := storage.createMetadataVersion(storage.CreateMetadataDir(schemaTable), 1, []byte("{}"))
+
+		if !errors.Is(err, ERROR_ICEBERG_COMMIT_CONFLICT) {
+			t.Errorf("Expected a commit conflict, got %v", err)
+		}
+	})
+
+	t.Run("Deletes the blobs of all list pages", func(t *testing.T) {
+		config, blobs, requestUris := startTestAzureBlobServer(t)
+		testWriteCatalogTable(config, schemaTable, pgSchemaColumns, [][]string{{"1", "Alice"}})
+		storage := NewAzureStorage(config)
+
+		err := storage.DeleteSchemaTable(schemaTable)
+
+		testNoError(t, err)
+		blobCount := 0
+		blobs.Range(func(key, value interface{}) bool {
+			blobCount++
+			return true
+		})
+		if blobCount != 0 {
+			t.Errorf("Expected all blobs to be deleted, got %d", blobCount)
+		}
+		if !slices.ContainsFunc(*requestUris, func(requestUri string) bool { return strings.Contains(requestUri, "marker=") }) {
+			t.Errorf("Expected the next list pages to be requested with the marker, got %v", *requestUris)
+		}
+	})
+
+	t.Run("Returns the error of the Blob service", func(t *testing.T) {
+		config, _, _ := startTestAzureBlobServer(t)
+		config.Azure.ContainerName = "missing"
+
+		_, err := NewAzureStorage(config).IcebergSchemas()
+
+		expectedErrorMessage := "Failed to list blobs: Azure Blob Storage request failed with status 404: ContainerNotFound"
+		if err == nil || err.Error() != expectedErrorMessage {
+			t.Errorf("Expected the error to be '%s', got %v", expectedErrorMessage, err)
+		}
+	})
+}
+
+// Blob service of a single "bemidb" container, listing 2 blobs or prefixes per page
+func startTestAzureBlobServer(t *testing.T) (config *Config, blobs *sync.Map, requestUris *[]string) {
+	blobs = &sync.Map{}
+	blocks := &sync.Map{}
+	requestUris = &[]string{}
+	var requestUrisMutex sync.Mutex
+
+	writeError := func(writer http.ResponseWriter, statusCode int, code string, message string) {
+		writer.Header().Set("x-ms-error-code", code)
+		writer.WriteHeader(statusCode)
+		fmt.Fprintf(writer, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s
+RequestId:00000000-0000-0000-0000-000000000000</Message></Error>`, code, message)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestUrisMutex.Lock()
+		*requestUris = append(*requestUris, request.URL.RequestURI())
+		requestUrisMutex.Unlock()
+		if !strings.HasPrefix(request.Header.Get("Authorization"), "SharedKey devstoreaccount1:") || request.Header.Get("x-ms-version") == "" {
+			writeError(writer, http.StatusForbidden, "AuthenticationFailed", "Server failed to authenticate the request.")
+			return
+		}
+
+		container, name, _ := strings.Cut(strings.TrimPrefix(request.URL.Path, "/"), "/")
+		if container != "bemidb" {
+			writeError(writer, http.StatusNotFound, "ContainerNotFound", "The specified container does not exist.")
+			return
+		}
+		query := request.URL.Query()
+
+		switch {
+		case request.Method == http.MethodGet && query.Get("comp") == "list":
+			prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+			var entries []string
+			blobs.Range(func(key, value interface{}) bool {
+				blobName := key.(string)
+				if !strings.HasPrefix(blobName, prefix) {
+					return true
+				}
+				if delimiter != "" {
+					if index := strings.Index(blobName[len(prefix):], delimiter); index != -1 {
+						blobName = blobName[:len(prefix)+index+1]
+					}
+				}
+				if !slices.Contains(entries, blobName) {
+					entries = append(entries, blobName)
+				}
+				return true
+			})
+			slices.Sort(entries)
+
+			start, _ := strconv.Atoi(query.Get("marker"))
+			end := min(start+2, len(entries))
+			var response bytes.Buffer
+			response.WriteString("<EnumerationResults><Blobs>")
+			for _, entry := range entries[start:end] {
+				if content, ok := blobs.Load(entry); ok {
+					fmt.Fprintf(&response, "<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length></Properties></Blob>", entry, time.Now().UTC().Format(http.TimeFormat), len(content.([]byte)))
+				} else {
+					fmt.Fprintf(&response, "<BlobPrefix><Name>%s</Name></BlobPrefix>", entry)
+				}
+			}
+			response.WriteString("</Blobs><NextMarker>")
+			if end < len(entries) {
+				response.WriteString(strconv.Itoa(end))
+			}
+			response.WriteString("</NextMarker></EnumerationResults>")
+			writer.Write(response.Bytes())
+		case request.Method == http.MethodGet || request.Method == http.MethodHead:
+			content, ok := blobs.Load(name)
+			if !ok {
+				writeError(writer, http.StatusNotFound, "BlobNotFound", "The specified blob does not exist.")
+				return
+			}
+			writer.Header().Set("Content-Length", strconv.Itoa(len(content.([]byte))))
+			if request.Method == http.MethodGet {
+				writer.Write(content.([]byte))
+			}
+		case request.Method == http.MethodPut && query.Get("comp") == "block":
+			content, _ := io.ReadAll(request.Body)
+			blocks.Store(name+"/"+query.Get("blockid"), content)
+			writer.WriteHeader(http.StatusCreated)
+		case request.Method == http.MethodPut && query.Get("comp") == "blocklist":
+			var blockList struct {
+				Latest []string `xml:"Latest"`
+			}
+			xml.NewDecoder(request.Body).Decode(&blockList)
+			var content []byte
+			for _, blockId := range blockList.Latest {
+				block, _ := blocks.LoadAndDelete(name + "/" + blockId)
+				content = append(content, block.([]byte)...)
+			}
+			blobs.Store(name, content)
+			writer.WriteHeader(http.StatusCreated)
+		case request.Method == http.MethodPut:
+			content, _ := io.ReadAll(request.Body)
+			if request.Header.Get("If-None-Match") == "*" {
+				if _, loaded := blobs.LoadOrStore(name, content); loaded {
+					writeError(writer, http.StatusConflict, "BlobAlreadyExists", "The specified blob already exists.")
+					return
+				}
+			} else {
+				blobs.Store(name, content)
+			}
+			writer.WriteHeader(http.StatusCreated)
+		case request.Method == http.MethodDelete:
+			if _, loaded := blobs.LoadAndDelete(name); !loaded {
+				writeError(writer, http.StatusNotFound, "BlobNotFound", "The specified blob does not exist.")
+				return
+			}
+			writer.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config = loadTestConfig()
+	config.StorageType = STORAGE_TYPE_AZURE
+	config.StoragePath = "iceberg"
+	config.Azure = AzureConfig{Account: "devstoreaccount1", AccountKey: TEST_AZURE_ACCOUNT_KEY, ContainerName: "bemidb", Endpoint: server.URL}
+	return config, blobs, requestUris
+}
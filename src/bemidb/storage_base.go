@@ -0,0 +1,1964 @@
+package bemidb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/layout"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	PARQUET_PARALLEL_NUMBER = 4
+	PARQUET_ROW_GROUP_SIZE  = 64 * 1024 * 1024 // 64 MB
+
+	// Rows read from each file at a time when compacting, so that files are merged without holding them in memory
+	PARQUET_COMPACTION_BATCH_SIZE = 10000
+
+	VERSION_HINT_FILE_NAME = "version-hint.text"
+
+	// Commits that lose the race for a metadata version are retried, each attempt loses to a different writer
+	ICEBERG_COMMIT_RETRIES = 5
+)
+
+var PARQUET_COMPRESSIONS = []string{"snappy", "zstd", "gzip", "lz4", "uncompressed"}
+
+var PARQUET_COMPRESSION_CODECS = map[string]parquet.CompressionCodec{
+	"snappy":       parquet.CompressionCodec_SNAPPY,
+	"zstd":         parquet.CompressionCodec_ZSTD,
+	"gzip":         parquet.CompressionCodec_GZIP,
+	"lz4":          parquet.CompressionCodec_LZ4_RAW, // LZ4 (framed) isn't readable by DuckDB and other Parquet readers
+	"uncompressed": parquet.CompressionCodec_UNCOMPRESSED,
+}
+
+type StorageBase struct {
+	config *Config
+}
+
+// Reads the current snapshot summary from the metadata file content
+func (storage *StorageBase) ParseIcebergTableStats(metadataContent []byte) (icebergTableStats IcebergTableStats, err error) {
+	var metadata struct {
+		CurrentSnapshotId int64 `json:"current-snapshot-id"`
+		Snapshots         []struct {
+			SnapshotId int64             `json:"snapshot-id"`
+			Summary    map[string]string `json:"summary"`
+		} `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return IcebergTableStats{}, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	for _, snapshot := range metadata.Snapshots {
+		if snapshot.SnapshotId != metadata.CurrentSnapshotId {
+			continue
+		}
+
+		icebergTableStats.DataFileCount, _ = strconv.ParseInt(snapshot.Summary["total-data-files"], 10, 64)
+		icebergTableStats.FilesSize, _ = strconv.ParseInt(snapshot.Summary["total-files-size"], 10, 64)
+		icebergTableStats.RecordCount, _ = strconv.ParseInt(snapshot.Summary["total-records"], 10, 64)
+		return icebergTableStats, nil
+	}
+
+	return IcebergTableStats{}, nil
+}
+
+// Reads the named snapshot references from the metadata file content
+func (storage *StorageBase) ParseIcebergRefs(metadataContent []byte) (icebergRefs map[string]IcebergRef, err error) {
+	var metadata struct {
+		Refs map[string]IcebergRef `json:"refs"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	return metadata.Refs, nil
+}
+
+// Reads the history of the current snapshot from the metadata file content
+func (storage *StorageBase) ParseIcebergSnapshotLog(metadataContent []byte) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error) {
+	var metadata struct {
+		SnapshotLog []IcebergSnapshotLogEntry `json:"snapshot-log"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	return metadata.SnapshotLog, nil
+}
+
+// Reads all snapshots from the metadata file content, in the order they were committed
+func (storage *StorageBase) ParseIcebergSnapshots(metadataContent []byte) (icebergSnapshots []IcebergSnapshot, err error) {
+	var metadata struct {
+		Snapshots []IcebergSnapshot `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	return metadata.Snapshots, nil
+}
+
+// Copies a snapshot into a local directory with the paths in its metadata, manifest list and manifests pointing to the copies,
+// so that DuckDB can read a table from a storage it can't read directly. Zero copies the current snapshot
+func (storage *StorageBase) DownloadIcebergSnapshot(metadataFileName string, metadataContent []byte, snapshotId int64, localDirPath string, openFile func(path string) (io.ReadCloser, error)) (localMetadataFilePath string, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return "", err
+	}
+	if snapshotId == 0 {
+		snapshotId, err = metadata["current-snapshot-id"].(json.Number).Int64()
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse current snapshot ID: %v", err)
+		}
+	}
+	snapshot := storage.findSnapshot(metadata, snapshotId)
+	if snapshot == nil {
+		return "", fmt.Errorf("Snapshot %d not found", snapshotId)
+	}
+
+	localMetadataDirPath := filepath.Join(localDirPath, "metadata")
+	localDataDirPath := filepath.Join(localDirPath, "data")
+	for _, dirPath := range []string{localMetadataDirPath, localDataDirPath} {
+		err = os.MkdirAll(dirPath, os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("Failed to create local directory: %v", err)
+		}
+	}
+
+	manifestListPath := snapshot["manifest-list"].(string)
+	localManifestListPath := filepath.Join(localMetadataDirPath, path.Base(manifestListPath))
+	err = storage.copyAvroFile(manifestListPath, localManifestListPath, openFile, func(manifestListRecord map[string]interface{}) error {
+		manifestPath := manifestListRecord["manifest_path"].(string)
+		localManifestPath := filepath.Join(localMetadataDirPath, path.Base(manifestPath))
+		manifestListRecord["manifest_path"] = localManifestPath
+
+		return storage.copyAvroFile(manifestPath, localManifestPath, openFile, func(manifestRecord map[string]interface{}) error {
+			dataFile := manifestRecord["data_file"].(map[string]interface{})
+			dataFilePath := dataFile["file_path"].(string)
+			localDataFilePath := filepath.Join(localDataDirPath, path.Base(dataFilePath))
+			dataFile["file_path"] = localDataFilePath
+			if manifestRecord["status"] == int32(2) { // 2: DELETED, not read
+				return nil
+			}
+
+			return storage.copyFile(dataFilePath, localDataFilePath, openFile)
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	snapshot["manifest-list"] = localManifestListPath
+	metadata["location"] = localDirPath
+	localMetadataContent, err := storage.encodeMetadata(metadata)
+	if err != nil {
+		return "", err
+	}
+	localMetadataFilePath = filepath.Join(localMetadataDirPath, metadataFileName)
+	err = os.WriteFile(localMetadataFilePath, localMetadataContent, 0644)
+	if err != nil {
+		return "", fmt.Errorf("Failed to write local metadata file: %v", err)
+	}
+
+	return localMetadataFilePath, nil
+}
+
+// Reads the data files of the current snapshot from its manifests, skipping entries of deleted files
+func (storage *StorageBase) ParseIcebergDataFiles(metadataContent []byte, openFile func(path string) (io.ReadCloser, error)) (icebergDataFiles []IcebergDataFile, err error) {
+	var metadata struct {
+		CurrentSnapshotId int64             `json:"current-snapshot-id"`
+		Snapshots         []IcebergSnapshot `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	for _, snapshot := range metadata.Snapshots {
+		if snapshot.SnapshotId != metadata.CurrentSnapshotId {
+			continue
+		}
+
+		manifestListRecords, err := storage.readAvroRecords(snapshot.ManifestList, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, manifestListRecord := range manifestListRecords {
+			manifestRecords, err := storage.readAvroRecords(manifestListRecord["manifest_path"].(string), openFile)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, manifestRecord := range manifestRecords {
+				if manifestRecord["status"] == int32(2) { // 2: DELETED
+					continue
+				}
+
+				dataFile := manifestRecord["data_file"].(map[string]interface{})
+				partition := map[string]interface{}{}
+				partitionRecord, _ := dataFile["partition"].(map[string]interface{})
+				for name, value := range partitionRecord {
+					// Non-null values are wrapped in their Avro union type, e.g. {"int": 19723}
+					if unionValue, ok := value.(map[string]interface{}); ok {
+						for _, wrappedValue := range unionValue {
+							value = wrappedValue
+						}
+					}
+					partition[name] = value
+				}
+				partitionJson, err := json.Marshal(partition)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to encode partition of %s: %v", dataFile["file_path"], err)
+				}
+
+				icebergDataFiles = append(icebergDataFiles, IcebergDataFile{
+					Path:        dataFile["file_path"].(string),
+					Format:      dataFile["file_format"].(string),
+					Partition:   string(partitionJson),
+					RecordCount: dataFile["record_count"].(int64),
+					Size:        dataFile["file_size_in_bytes"].(int64),
+				})
+			}
+		}
+	}
+
+	return icebergDataFiles, nil
+}
+
+// Returns the names of the current schema's identifier fields, which hold the Postgres primary key, in key order
+func (storage *StorageBase) ParseIcebergPrimaryKey(metadataContent []byte) (columnNames []string, err error) {
+	var metadata struct {
+		CurrentSchemaId int `json:"current-schema-id"`
+		Schemas         []struct {
+			SchemaId           int                  `json:"schema-id"`
+			Fields             []IcebergSchemaField `json:"fields"`
+			IdentifierFieldIds []int                `json:"identifier-field-ids"`
+		} `json:"schemas"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	columnNames = []string{}
+	for _, schema := range metadata.Schemas {
+		if schema.SchemaId != metadata.CurrentSchemaId {
+			continue
+		}
+		for _, identifierFieldId := range schema.IdentifierFieldIds {
+			for _, field := range schema.Fields {
+				if field.Id == identifierFieldId {
+					columnNames = append(columnNames, field.Name)
+				}
+			}
+		}
+	}
+
+	return columnNames, nil
+}
+
+// Returns the fields of the schema the snapshot was written with, or of the current schema if the snapshot id is 0
+func (storage *StorageBase) ParseIcebergSchemaFields(metadataContent []byte, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error) {
+	var metadata struct {
+		CurrentSchemaId int `json:"current-schema-id"`
+		Schemas         []struct {
+			SchemaId int                  `json:"schema-id"`
+			Fields   []IcebergSchemaField `json:"fields"`
+		} `json:"schemas"`
+		Snapshots []struct {
+			SnapshotId int64 `json:"snapshot-id"`
+			SchemaId   *int  `json:"schema-id"`
+		} `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	schemaId := metadata.CurrentSchemaId
+	for _, snapshot := range metadata.Snapshots {
+		if snapshot.SnapshotId == snapshotId && snapshot.SchemaId != nil {
+			schemaId = *snapshot.SchemaId
+		}
+	}
+
+	for _, schema := range metadata.Schemas {
+		if schema.SchemaId == schemaId {
+			return schema.Fields, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Failed to find schema %d in metadata file", schemaId)
+}
+
+// Returns all partition specs of the table, including the ones of files written before a partition evolution
+func (storage *StorageBase) ParseIcebergPartitionSpecs(metadataContent []byte) (icebergPartitionSpecs []IcebergPartitionSpec, err error) {
+	var metadata struct {
+		PartitionSpecs []IcebergPartitionSpec `json:"partition-specs"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	return metadata.PartitionSpecs, nil
+}
+
+// Points the ref at a snapshot. Moving the main branch also changes the snapshot that is read by default
+func (storage *StorageBase) SetIcebergRef(metadataContent []byte, refName string, icebergRef IcebergRef) (updatedMetadataContent []byte, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := storage.findSnapshot(metadata, icebergRef.SnapshotId)
+	if snapshot == nil {
+		return nil, fmt.Errorf("Failed to find snapshot %d", icebergRef.SnapshotId)
+	}
+
+	currentTimestampMs := time.Now().UnixNano() / int64(time.Millisecond)
+	metadata["refs"].(map[string]interface{})[refName] = icebergRef
+	metadata["last-updated-ms"] = currentTimestampMs
+
+	if refName == ICEBERG_MAIN_BRANCH {
+		metadata["current-snapshot-id"] = icebergRef.SnapshotId
+		metadata["current-schema-id"] = snapshot["schema-id"]
+		metadata["snapshot-log"] = append(metadata["snapshot-log"].([]interface{}), map[string]interface{}{
+			"snapshot-id":  icebergRef.SnapshotId,
+			"timestamp-ms": currentTimestampMs,
+		})
+	}
+
+	return storage.encodeMetadata(metadata)
+}
+
+// Returns the data files that are referenced by retained snapshots but not by the current snapshot
+func (storage *StorageBase) SupersededDataFilePaths(metadataContent []byte, openFile func(path string) (io.ReadCloser, error)) (dataFilePaths []string, err error) {
+	var metadata struct {
+		CurrentSnapshotId int64 `json:"current-snapshot-id"`
+		Snapshots         []struct {
+			SnapshotId   int64  `json:"snapshot-id"`
+			ManifestList string `json:"manifest-list"`
+		} `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	currentDataFilePaths := []string{}
+	retainedDataFilePaths := []string{}
+	for _, snapshot := range metadata.Snapshots {
+		snapshotDataFilePaths, err := storage.readSnapshotDataFilePaths(snapshot.ManifestList, openFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if snapshot.SnapshotId == metadata.CurrentSnapshotId {
+			currentDataFilePaths = append(currentDataFilePaths, snapshotDataFilePaths...)
+		} else {
+			retainedDataFilePaths = append(retainedDataFilePaths, snapshotDataFilePaths...)
+		}
+	}
+
+	skippedDataFilePaths := NewSet(currentDataFilePaths)
+	for _, dataFilePath := range retainedDataFilePaths {
+		if !skippedDataFilePaths.Contains(dataFilePath) {
+			dataFilePaths = append(dataFilePaths, dataFilePath)
+			skippedDataFilePaths.Add(dataFilePath)
+		}
+	}
+
+	return dataFilePaths, nil
+}
+
+// Returns the manifest lists, manifests, data and statistics files of all snapshots, including the ones retained by tags and branches.
+// Fails if any of them can't be read, so that files are never treated as orphaned because of a partially read snapshot
+func (storage *StorageBase) ReferencedFilePaths(metadataContent []byte, openFile func(path string) (io.ReadCloser, error)) (filePaths *Set, err error) {
+	var metadata struct {
+		Snapshots []struct {
+			ManifestList string `json:"manifest-list"`
+		} `json:"snapshots"`
+		Statistics []struct {
+			StatisticsPath string `json:"statistics-path"`
+		} `json:"statistics"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	filePaths = NewSet([]string{})
+	for _, snapshot := range metadata.Snapshots {
+		filePaths.Add(snapshot.ManifestList)
+
+		manifestListRecords, err := storage.readAvroRecords(snapshot.ManifestList, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, manifestListRecord := range manifestListRecords {
+			filePaths.Add(manifestListRecord["manifest_path"].(string))
+		}
+
+		dataFilePaths, err := storage.readSnapshotDataFilePaths(snapshot.ManifestList, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, dataFilePath := range dataFilePaths {
+			filePaths.Add(dataFilePath)
+		}
+	}
+
+	for _, statistics := range metadata.Statistics {
+		filePaths.Add(statistics.StatisticsPath)
+	}
+
+	return filePaths, nil
+}
+
+// Removes snapshots older than olderThan from the metadata, along with their snapshot log entries and statistics.
+// The current snapshot, the snapshots of branches and tags, and the last keepLast snapshots are always kept
+func (storage *StorageBase) ExpireIcebergSnapshots(metadataContent []byte, keepLast int, olderThan time.Time) (updatedMetadataContent []byte, expiredSnapshotIds []int64, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	icebergSnapshots, err := storage.ParseIcebergSnapshots(metadataContent)
+	if err != nil {
+		return nil, nil, err
+	}
+	icebergRefs, err := storage.ParseIcebergRefs(metadataContent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	retainedSnapshotIds := map[int64]bool{}
+	if currentSnapshotId, err := metadata["current-snapshot-id"].(json.Number).Int64(); err == nil {
+		retainedSnapshotIds[currentSnapshotId] = true
+	}
+	for _, icebergRef := range icebergRefs {
+		retainedSnapshotIds[icebergRef.SnapshotId] = true
+	}
+	for i, icebergSnapshot := range icebergSnapshots {
+		if i >= len(icebergSnapshots)-keepLast || icebergSnapshot.TimestampMs >= olderThan.UnixMilli() {
+			retainedSnapshotIds[icebergSnapshot.SnapshotId] = true
+		}
+	}
+
+	for _, icebergSnapshot := range icebergSnapshots {
+		if !retainedSnapshotIds[icebergSnapshot.SnapshotId] {
+			expiredSnapshotIds = append(expiredSnapshotIds, icebergSnapshot.SnapshotId)
+		}
+	}
+	if len(expiredSnapshotIds) == 0 {
+		return metadataContent, nil, nil
+	}
+
+	isRetained := func(entry interface{}) bool {
+		snapshotId, err := entry.(map[string]interface{})["snapshot-id"].(json.Number).Int64()
+		return err != nil || retainedSnapshotIds[snapshotId]
+	}
+	for _, key := range []string{"snapshots", "snapshot-log", "statistics"} {
+		entries, ok := metadata[key].([]interface{})
+		if !ok {
+			continue
+		}
+		retainedEntries := []interface{}{}
+		for _, entry := range entries {
+			if isRetained(entry) {
+				retainedEntries = append(retainedEntries, entry)
+			}
+		}
+		metadata[key] = retainedEntries
+	}
+	metadata["last-updated-ms"] = time.Now().UnixNano() / int64(time.Millisecond)
+
+	updatedMetadataContent, err = storage.encodeMetadata(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return updatedMetadataContent, expiredSnapshotIds, nil
+}
+
+// Returns the files referenced by the metadata before expiring snapshots that aren't referenced by the retained snapshots anymore.
+// Files shared with a retained snapshot are never returned, since both sets come from reading every snapshot's manifests
+func (storage *StorageBase) ExpiredFilePaths(metadataContent []byte, updatedMetadataContent []byte, openFile func(path string) (io.ReadCloser, error)) (filePaths []string, err error) {
+	referencedFilePaths, err := storage.ReferencedFilePaths(metadataContent, openFile)
+	if err != nil {
+		return nil, err
+	}
+	retainedFilePaths, err := storage.ReferencedFilePaths(updatedMetadataContent, openFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filePath := range referencedFilePaths.Values() {
+		if !retainedFilePaths.Contains(filePath) {
+			filePaths = append(filePaths, filePath)
+		}
+	}
+
+	return filePaths, nil
+}
+
+// Groups the data files of the current snapshot that are smaller than the target file size by manifest and partition,
+// so that each group adds up to at most the target size. Files at or above the target size and groups of a single file are left as they are
+func (storage *StorageBase) PlanIcebergCompaction(metadataContent []byte, targetFileSize int64, openFile func(path string) (io.ReadCloser, error)) (currentSnapshot IcebergSnapshot, icebergCompactionGroups []IcebergCompactionGroup, err error) {
+	var metadata struct {
+		CurrentSnapshotId int64             `json:"current-snapshot-id"`
+		Snapshots         []IcebergSnapshot `json:"snapshots"`
+	}
+	err = json.Unmarshal(metadataContent, &metadata)
+	if err != nil {
+		return IcebergSnapshot{}, nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	for _, snapshot := range metadata.Snapshots {
+		if snapshot.SnapshotId != metadata.CurrentSnapshotId {
+			continue
+		}
+		currentSnapshot = snapshot
+
+		manifestListRecords, err := storage.readAvroRecords(snapshot.ManifestList, openFile)
+		if err != nil {
+			return IcebergSnapshot{}, nil, err
+		}
+		for _, manifestListRecord := range manifestListRecords {
+			manifestPath := manifestListRecord["manifest_path"].(string)
+			manifestRecords, err := storage.readAvroRecords(manifestPath, openFile)
+			if err != nil {
+				return IcebergSnapshot{}, nil, err
+			}
+
+			var partitionKeys []string
+			groupsByPartition := map[string]*IcebergCompactionGroup{}
+			addGroup := func(partitionKey string) {
+				if group := groupsByPartition[partitionKey]; group != nil && len(group.DataFilePaths) > 1 {
+					icebergCompactionGroups = append(icebergCompactionGroups, *group)
+				}
+				delete(groupsByPartition, partitionKey)
+			}
+
+			for _, manifestRecord := range manifestRecords {
+				if manifestRecord["status"] == int32(2) { // 2: DELETED
+					continue
+				}
+
+				dataFile := manifestRecord["data_file"].(map[string]interface{})
+				size := dataFile["file_size_in_bytes"].(int64)
+				if size >= targetFileSize {
+					continue
+				}
+
+				partition, _ := dataFile["partition"].(map[string]interface{})
+				partitionJson, err := json.Marshal(partition)
+				if err != nil {
+					return IcebergSnapshot{}, nil, fmt.Errorf("Failed to encode partition of %s: %v", dataFile["file_path"], err)
+				}
+				partitionKey := string(partitionJson)
+
+				group := groupsByPartition[partitionKey]
+				if group != nil && group.Size+size > targetFileSize {
+					addGroup(partitionKey)
+					group = nil
+				}
+				if group == nil {
+					if !slices.Contains(partitionKeys, partitionKey) {
+						partitionKeys = append(partitionKeys, partitionKey)
+					}
+					group = &IcebergCompactionGroup{ManifestPath: manifestPath, Partition: partition, NanValueCounts: map[int]int64{}}
+					groupsByPartition[partitionKey] = group
+				}
+
+				group.DataFilePaths = append(group.DataFilePaths, dataFile["file_path"].(string))
+				group.RecordCount += dataFile["record_count"].(int64)
+				group.Size += size
+				nanValueCounts, _ := dataFile["nan_value_counts"].(map[string]interface{})
+				nanValueCountsArr, _ := nanValueCounts["array"].([]interface{})
+				for _, nanValueCount := range nanValueCountsArr {
+					nanValueCountRecord := nanValueCount.(map[string]interface{})
+					group.NanValueCounts[int(nanValueCountRecord["key"].(int32))] += nanValueCountRecord["value"].(int64)
+				}
+			}
+
+			for _, partitionKey := range partitionKeys {
+				addGroup(partitionKey)
+			}
+		}
+	}
+
+	return currentSnapshot, icebergCompactionGroups, nil
+}
+
+// Writes the rows batch by batch, a batch is converted to Parquet rows before any of them are written so that it can be retried or skipped
+func (storage *StorageBase) WriteParquetFile(icebergSchemaTable IcebergSchemaTable, fileWriter source.ParquetFile, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) (recordCount int64, nanValueCounts map[int]int64, skippedBatches []SkippedParquetBatch, err error) {
+	defer fileWriter.Close()
+
+	schemaMap := map[string]interface{}{
+		"Tag":    "name=root",
+		"Fields": []map[string]interface{}{},
+	}
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		fieldMap := pgSchemaColumn.ToParquetSchemaFieldMap(!storage.hasParquetStats(pgSchemaColumn))
+		schemaMap["Fields"] = append(schemaMap["Fields"].([]map[string]interface{}), fieldMap)
+	}
+	schemaJson, err := json.Marshal(schemaMap)
+	PanicIfError(err)
+
+	LogDebug(storage.config, "Parquet schema:", string(schemaJson))
+	parquetWriter, err := writer.NewJSONWriter(string(schemaJson), fileWriter, PARQUET_PARALLEL_NUMBER)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("Failed to create Parquet writer: %v", err)
+	}
+
+	compression, rowGroupSize := storage.parquetTableSettings(icebergSchemaTable)
+	parquetWriter.RowGroupSize = rowGroupSize
+	if parquetWriter.RowGroupSize <= 0 {
+		parquetWriter.RowGroupSize = PARQUET_ROW_GROUP_SIZE
+	}
+	parquetWriter.CompressionType = PARQUET_COMPRESSION_CODECS[compression]
+
+	nanValueCounts = make(map[int]int64)
+	batchRetrier := NewParquetBatchRetrier(storage.config)
+	batchNumber := 0
+	rows := loadRows()
+	for len(rows) > 0 {
+		batchNumber++
+
+		var rowJsons []string
+		var batchNanValueCounts map[int]int64
+		skippedBatch, err := batchRetrier.Write(batchNumber, len(rows), func() (err error) {
+			rowJsons, batchNanValueCounts, err = storage.parquetRowJsons(pgSchemaColumns, rows)
+			return err
+		})
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if skippedBatch != nil {
+			skippedBatches = append(skippedBatches, *skippedBatch)
+		}
+		for fieldID, count := range batchNanValueCounts {
+			nanValueCounts[fieldID] += count
+		}
+
+		for _, rowJson := range rowJsons {
+			if err = parquetWriter.Write(rowJson); err != nil {
+				return 0, nil, nil, fmt.Errorf("Write error: %v", err)
+			}
+			recordCount++
+		}
+
+		rows = loadRows()
+	}
+
+	LogDebug(storage.config, "Stopping Parquet writer...")
+	if err := parquetWriter.WriteStop(); err != nil {
+		return 0, nil, nil, fmt.Errorf("Failed to stop Parquet writer: %v", err)
+	}
+
+	return recordCount, nanValueCounts, skippedBatches, nil
+}
+
+// Converts rows to Parquet JSON rows and counts NaN float values by field id
+// Compression codec and row group size of the table's Parquet files, the global settings unless the table overrides them
+func (storage *StorageBase) parquetTableSettings(icebergSchemaTable IcebergSchemaTable) (compression string, rowGroupSize int64) {
+	compression = storage.config.Parquet.Compression
+	if tableCompression, ok := storage.config.Parquet.TableCompressions[icebergSchemaTable.Schema+"."+icebergSchemaTable.Table]; ok {
+		compression = tableCompression
+	}
+
+	rowGroupSize = storage.config.Parquet.RowGroupSize
+	if tableRowGroupSize, ok := storage.config.Parquet.TableRowGroupSizes[icebergSchemaTable.Schema+"."+icebergSchemaTable.Table]; ok {
+		rowGroupSize = tableRowGroupSize
+	}
+
+	return compression, rowGroupSize
+}
+
+func (storage *StorageBase) parquetRowJsons(pgSchemaColumns []PgSchemaColumn, rows [][]string) (rowJsons []string, nanValueCounts map[int]int64, err error) {
+	nanValueCounts = make(map[int]int64)
+	for _, row := range rows {
+		rowMap := make(map[string]interface{})
+		for i, rowValue := range row {
+			pgSchemaColumn := &pgSchemaColumns[i]
+			if storage.config.Pg.SyncInfiniteTimestamps == INFINITE_TIMESTAMPS_NULL && pgSchemaColumn.IsNullable == PG_TRUE && pgSchemaColumn.IsInfiniteValue(rowValue) {
+				rowValue = PG_NULL_STRING
+			}
+
+			parquetValue := pgSchemaColumn.FormatParquetValue(rowValue)
+			if parquetValue == PARQUET_NAN && pgSchemaColumn.IsNanCountable() {
+				fieldID, err := StringToInt(pgSchemaColumn.OrdinalPosition)
+				if err != nil {
+					return nil, nil, fmt.Errorf("Failed to parse field id: %v", err)
+				}
+				nanValueCounts[fieldID]++
+			}
+			rowMap[pgSchemaColumn.ColumnName] = parquetValue
+		}
+		rowJson, err := json.Marshal(rowMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		rowJsons = append(rowJsons, string(rowJson))
+	}
+	return rowJsons, nanValueCounts, nil
+}
+
+func (storage *StorageBase) hasParquetStats(pgSchemaColumn PgSchemaColumn) bool {
+	parquetConfig := storage.config.Parquet
+	if parquetConfig.StatsColumns == nil && parquetConfig.MaxStatsColumns == 0 {
+		return true
+	}
+	if parquetConfig.StatsColumns != nil && parquetConfig.StatsColumns.Contains(pgSchemaColumn.ColumnName) {
+		return true
+	}
+	position, err := StringToInt(pgSchemaColumn.OrdinalPosition)
+	return parquetConfig.MaxStatsColumns > 0 && err == nil && position <= parquetConfig.MaxStatsColumns
+}
+
+func (storage *StorageBase) ReadParquetStats(fileReader source.ParquetFile) (parquetFileStats ParquetFileStats, err error) {
+	defer fileReader.Close()
+
+	pr, err := reader.NewParquetReader(fileReader, nil, 1)
+	if err != nil {
+		return ParquetFileStats{}, fmt.Errorf("Failed to create Parquet reader: %v", err)
+	}
+	defer pr.ReadStop()
+
+	parquetStats := ParquetFileStats{
+		ColumnSizes:     make(map[int]int64),
+		ValueCounts:     make(map[int]int64),
+		NullValueCounts: make(map[int]int64),
+		LowerBounds:     make(map[int][]byte),
+		UpperBounds:     make(map[int][]byte),
+		SplitOffsets:    []int64{},
+	}
+
+	fieldIDMap := storage.buildFieldIDMap(pr.SchemaHandler)
+	schemaElements := make(map[int]*parquet.SchemaElement)
+	for _, schemaElement := range pr.SchemaHandler.SchemaElements {
+		if schemaElement.FieldID != nil {
+			schemaElements[int(*schemaElement.FieldID)] = schemaElement
+		}
+	}
+	lowerValues := make(map[int]interface{})
+	upperValues := make(map[int]interface{})
+
+	for _, rowGroup := range pr.Footer.RowGroups {
+		if rowGroup.FileOffset != nil {
+			parquetStats.SplitOffsets = append(parquetStats.SplitOffsets, *rowGroup.FileOffset)
+		}
+
+		for _, columnChunk := range rowGroup.Columns {
+			columnMetaData := columnChunk.MetaData
+			columnPath := columnMetaData.PathInSchema
+			columnName := strings.Join(columnPath, ".")
+			fieldID, ok := fieldIDMap[columnName]
+			if !ok {
+				continue
+			}
+			parquetStats.ColumnSizes[fieldID] += columnMetaData.TotalCompressedSize
+			parquetStats.ValueCounts[fieldID] += int64(columnMetaData.NumValues)
+
+			if columnMetaData.Statistics != nil {
+				if columnMetaData.Statistics.NullCount != nil {
+					parquetStats.NullValueCounts[fieldID] += *columnMetaData.Statistics.NullCount
+				}
+
+				// Columns without statistics, all-NULL column chunks, and types without Iceberg bounds are left out
+				minValue := parquetStatsValue(schemaElements[fieldID], columnMetaData.Statistics.MinValue)
+				maxValue := parquetStatsValue(schemaElements[fieldID], columnMetaData.Statistics.MaxValue)
+				if minValue != nil && (lowerValues[fieldID] == nil || parquetStatsValueLess(minValue, lowerValues[fieldID])) {
+					lowerValues[fieldID] = minValue
+				}
+				if maxValue != nil && (upperValues[fieldID] == nil || parquetStatsValueLess(upperValues[fieldID], maxValue)) {
+					upperValues[fieldID] = maxValue
+				}
+			}
+		}
+	}
+
+	for fieldID, lowerValue := range lowerValues {
+		parquetStats.LowerBounds[fieldID] = icebergLowerBoundBytes(lowerValue)
+	}
+	for fieldID, upperValue := range upperValues {
+		if upperBound := icebergUpperBoundBytes(upperValue); upperBound != nil {
+			parquetStats.UpperBounds[fieldID] = upperBound
+		}
+	}
+
+	return parquetStats, nil
+}
+
+// Merges Parquet files with the same schema into a single file. Column values are copied as they were written,
+// since the Postgres values that WriteParquetFile converts can't be recovered from the files
+func (storage *StorageBase) CompactParquetFiles(icebergSchemaTable IcebergSchemaTable, fileWriter source.ParquetFile, dataFilePaths []string, openParquetFile func(path string) (source.ParquetFile, error)) (recordCount int64, err error) {
+	defer fileWriter.Close()
+
+	var parquetWriter *writer.ParquetWriter
+	for _, dataFilePath := range dataFilePaths {
+		fileReader, err := openParquetFile(dataFilePath)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to open Parquet file %s for reading: %v", dataFilePath, err)
+		}
+		defer fileReader.Close()
+
+		pr, err := reader.NewParquetReader(fileReader, nil, 1)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to create Parquet reader: %v", err)
+		}
+
+		if parquetWriter == nil {
+			parquetWriter, err = storage.compactedParquetWriter(icebergSchemaTable, fileWriter, pr)
+			if err != nil {
+				return 0, err
+			}
+		} else if !slices.Equal(pr.SchemaHandler.ValueColumns, parquetWriter.SchemaHandler.ValueColumns) {
+			pr.ReadStop()
+			return 0, fmt.Errorf("Failed to compact Parquet files with different schemas")
+		}
+
+		numRows := pr.GetNumRows()
+		for readRows := int64(0); readRows < numRows; readRows += PARQUET_COMPACTION_BATCH_SIZE {
+			rows := make([]parquetColumnsRow, min(PARQUET_COMPACTION_BATCH_SIZE, numRows-readRows))
+			for columnIndex := range pr.SchemaHandler.ValueColumns {
+				values, repetitionLevels, definitionLevels, err := pr.ReadColumnByIndex(int64(columnIndex), int64(len(rows)))
+				if err != nil {
+					pr.ReadStop()
+					return 0, fmt.Errorf("Failed to read Parquet column: %v", err)
+				}
+
+				// A repetition level of 0 starts the values of the next row
+				rowIndex := -1
+				for i, repetitionLevel := range repetitionLevels {
+					if repetitionLevel == 0 {
+						rowIndex++
+						rows[rowIndex].values = append(rows[rowIndex].values, nil)
+						rows[rowIndex].repetitionLevels = append(rows[rowIndex].repetitionLevels, nil)
+						rows[rowIndex].definitionLevels = append(rows[rowIndex].definitionLevels, nil)
+					}
+					row := &rows[rowIndex]
+					row.values[columnIndex] = append(row.values[columnIndex], values[i])
+					row.repetitionLevels[columnIndex] = append(row.repetitionLevels[columnIndex], repetitionLevel)
+					row.definitionLevels[columnIndex] = append(row.definitionLevels[columnIndex], definitionLevels[i])
+				}
+			}
+
+			for _, row := range rows {
+				if err = parquetWriter.Write(row); err != nil {
+					pr.ReadStop()
+					return 0, fmt.Errorf("Write error: %v", err)
+				}
+				recordCount++
+			}
+		}
+		pr.ReadStop()
+	}
+
+	LogDebug(storage.config, "Stopping Parquet writer...")
+	if err := parquetWriter.WriteStop(); err != nil {
+		return 0, fmt.Errorf("Failed to stop Parquet writer: %v", err)
+	}
+
+	return recordCount, nil
+}
+
+// Values of a row by column in the order of the schema's value columns, with their repetition and definition levels as read from a Parquet file
+type parquetColumnsRow struct {
+	values           [][]interface{}
+	repetitionLevels [][]int32
+	definitionLevels [][]int32
+}
+
+// Parquet writer with the schema of the file read, which writes rows of copied column values. Columns written without statistics are kept without them
+func (storage *StorageBase) compactedParquetWriter(icebergSchemaTable IcebergSchemaTable, fileWriter source.ParquetFile, pr *reader.ParquetReader) (parquetWriter *writer.ParquetWriter, err error) {
+	parquetWriter, err = writer.NewParquetWriter(fileWriter, pr.SchemaHandler, PARQUET_PARALLEL_NUMBER)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Parquet writer: %v", err)
+	}
+
+	compression, rowGroupSize := storage.parquetTableSettings(icebergSchemaTable)
+	parquetWriter.RowGroupSize = rowGroupSize
+	if parquetWriter.RowGroupSize <= 0 {
+		parquetWriter.RowGroupSize = PARQUET_ROW_GROUP_SIZE
+	}
+	parquetWriter.CompressionType = PARQUET_COMPRESSION_CODECS[compression]
+
+	schemaHandler := parquetWriter.SchemaHandler
+	if len(pr.Footer.RowGroups) > 0 {
+		for columnIndex, pathStr := range schemaHandler.ValueColumns {
+			if pr.Footer.RowGroups[0].Columns[columnIndex].MetaData.Statistics == nil {
+				schemaHandler.Infos[schemaHandler.MapIndex[pathStr]].OmitStats = true
+			}
+		}
+	}
+
+	parquetWriter.MarshalFunc = func(rows []interface{}, schemaHandler *schema.SchemaHandler) (*map[string]*layout.Table, error) {
+		tableMap := make(map[string]*layout.Table)
+		for columnIndex, pathStr := range schemaHandler.ValueColumns {
+			schemaIndex := schemaHandler.MapIndex[pathStr]
+			table := layout.NewEmptyTable()
+			table.Path = common.StrToPath(pathStr)
+			table.MaxDefinitionLevel, _ = schemaHandler.MaxDefinitionLevel(table.Path)
+			table.MaxRepetitionLevel, _ = schemaHandler.MaxRepetitionLevel(table.Path)
+			table.RepetitionType = schemaHandler.SchemaElements[schemaIndex].GetRepetitionType()
+			table.Schema = schemaHandler.SchemaElements[schemaIndex]
+			table.Info = schemaHandler.Infos[schemaIndex]
+			for _, row := range rows {
+				columnsRow := row.(parquetColumnsRow)
+				table.Values = append(table.Values, columnsRow.values[columnIndex]...)
+				table.RepetitionLevels = append(table.RepetitionLevels, columnsRow.repetitionLevels[columnIndex]...)
+				table.DefinitionLevels = append(table.DefinitionLevels, columnsRow.definitionLevels[columnIndex]...)
+			}
+			tableMap[pathStr] = table
+		}
+		return &tableMap, nil
+	}
+
+	return parquetWriter, nil
+}
+
+// Writes a manifest entry for each data file, with its partition values if the spec has partition fields
+func (storage *StorageBase) WriteManifestFile(fileSystemPrefix string, filePath string, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile) (manifestFile ManifestFile, err error) {
+	snapshotId := time.Now().UnixNano()
+	manifestSchema := icebergManifestSchema(icebergPartitionSpec)
+	codec, err := goavro.NewCodec(manifestSchema)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("Failed to create Avro codec: %v", err)
+	}
+
+	var manifestEntries []interface{}
+	for _, parquetFile := range parquetFiles {
+		manifestEntries = append(manifestEntries, storage.manifestEntry(fileSystemPrefix, snapshotId, icebergPartitionSpec, parquetFile))
+	}
+
+	avroFile, err := os.Create(filePath)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("Failed to create manifest file: %v", err)
+	}
+	defer avroFile.Close()
+
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:      avroFile,
+		Codec:  codec,
+		Schema: manifestSchema,
+	})
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("Failed to create Avro OCF writer: %v", err)
+	}
+
+	err = ocfWriter.Append(manifestEntries)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("Failed to write to manifest file: %v", err)
+	}
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("Failed to get manifest file info: %v", err)
+	}
+	fileSize := fileInfo.Size()
+
+	return ManifestFile{
+		SnapshotId:      snapshotId,
+		Path:            filePath,
+		Size:            fileSize,
+		PartitionSpecId: icebergPartitionSpec.SpecId,
+	}, nil
+}
+
+func (storage *StorageBase) manifestEntry(fileSystemPrefix string, snapshotId int64, icebergPartitionSpec IcebergPartitionSpec, parquetFile ParquetFile) map[string]interface{} {
+	columnSizesArr := []interface{}{}
+	for fieldID, size := range parquetFile.Stats.ColumnSizes {
+		columnSizesArr = append(columnSizesArr, map[string]interface{}{
+			"key":   fieldID,
+			"value": size,
+		})
+	}
+
+	valueCountsArr := []interface{}{}
+	for fieldID, count := range parquetFile.Stats.ValueCounts {
+		valueCountsArr = append(valueCountsArr, map[string]interface{}{
+			"key":   fieldID,
+			"value": count,
+		})
+	}
+
+	nullValueCountsArr := []interface{}{}
+	for fieldID, count := range parquetFile.Stats.NullValueCounts {
+		nullValueCountsArr = append(nullValueCountsArr, map[string]interface{}{
+			"key":   fieldID,
+			"value": count,
+		})
+	}
+
+	nanValueCountsArr := []interface{}{}
+	for fieldID, count := range parquetFile.Stats.NanValueCounts {
+		nanValueCountsArr = append(nanValueCountsArr, map[string]interface{}{
+			"key":   fieldID,
+			"value": count,
+		})
+	}
+
+	lowerBoundsArr := []interface{}{}
+	for fieldID, value := range parquetFile.Stats.LowerBounds {
+		lowerBoundsArr = append(lowerBoundsArr, map[string]interface{}{
+			"key":   fieldID,
+			"value": value,
+		})
+	}
+
+	upperBoundsArr := []interface{}{}
+	for fieldID, value := range parquetFile.Stats.UpperBounds {
+		upperBoundsArr = append(upperBoundsArr, map[string]interface{}{
+			"key":   fieldID,
+			"value": value,
+		})
+	}
+
+	dataFile := map[string]interface{}{
+		"content":            0, // 0: DATA, 1: POSITION DELETES, 2: EQUALITY DELETES
+		"file_path":          fileSystemPrefix + parquetFile.Path,
+		"file_format":        "PARQUET",
+		"record_count":       parquetFile.RecordCount,
+		"file_size_in_bytes": parquetFile.Size,
+		"column_sizes": map[string]interface{}{
+			"array": columnSizesArr,
+		},
+		"value_counts": map[string]interface{}{
+			"array": valueCountsArr,
+		},
+		"null_value_counts": map[string]interface{}{
+			"array": nullValueCountsArr,
+		},
+		"nan_value_counts": map[string]interface{}{
+			"array": nanValueCountsArr,
+		},
+		"lower_bounds": map[string]interface{}{
+			"array": lowerBoundsArr,
+		},
+		"upper_bounds": map[string]interface{}{
+			"array": upperBoundsArr,
+		},
+		"key_metadata": nil,
+		"split_offsets": map[string]interface{}{
+			"array": parquetFile.Stats.SplitOffsets,
+		},
+		"equality_ids":  nil,
+		"sort_order_id": nil,
+	}
+	if len(icebergPartitionSpec.Fields) > 0 {
+		dataFile["partition"] = parquetFile.Partition
+	}
+
+	return map[string]interface{}{
+		"status":               1, // 0: EXISTING 1: ADDED 2: DELETED
+		"snapshot_id":          map[string]interface{}{"long": snapshotId},
+		"sequence_number":      nil,
+		"file_sequence_number": nil,
+		"data_file":            dataFile,
+	}
+}
+
+func (storage *StorageBase) WriteManifestListFile(fileSystemPrefix string, filePath string, parquetFiles []ParquetFile, manifestFile ManifestFile) (err error) {
+	codec, err := goavro.NewCodec(MANIFEST_LIST_SCHEMA)
+	if err != nil {
+		return fmt.Errorf("Failed to create Avro codec for manifest list: %v", err)
+	}
+
+	var addedRowsCount int64
+	for _, parquetFile := range parquetFiles {
+		addedRowsCount += parquetFile.RecordCount
+	}
+
+	manifestListRecord := map[string]interface{}{
+		"added_files_count":    len(parquetFiles),
+		"added_rows_count":     addedRowsCount,
+		"added_snapshot_id":    manifestFile.SnapshotId,
+		"content":              0,
+		"deleted_files_count":  0,
+		"deleted_rows_count":   0,
+		"existing_files_count": 0,
+		"existing_rows_count":  0,
+		"key_metadata":         nil,
+		"manifest_length":      manifestFile.Size,
+		"manifest_path":        fileSystemPrefix + manifestFile.Path,
+		"min_sequence_number":  1,
+		"partition_spec_id":    manifestFile.PartitionSpecId,
+		"partitions":           map[string]interface{}{"array": []string{}},
+		"sequence_number":      1,
+	}
+
+	avroFile, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("Failed to create manifest list file: %v", err)
+	}
+	defer avroFile.Close()
+
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:      avroFile,
+		Codec:  codec,
+		Schema: MANIFEST_LIST_SCHEMA,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create OCF writer for manifest list: %v", err)
+	}
+
+	err = ocfWriter.Append([]interface{}{manifestListRecord})
+	if err != nil {
+		return fmt.Errorf("Failed to write manifest list record: %v", err)
+	}
+
+	return nil
+}
+
+// Copies the manifest with the compacted data files of its groups replaced by their merged files, parquetFiles[i] being the merged file of icebergCompactionGroups[i].
+// The other data files are kept as existing entries, and the manifest's Avro schema is kept along with its partition record
+func (storage *StorageBase) WriteCompactedManifestFile(fileSystemPrefix string, filePath string, snapshotId int64, manifestPath string, icebergCompactionGroups []IcebergCompactionGroup, parquetFiles []ParquetFile, openFile func(path string) (io.ReadCloser, error)) (manifestFile ManifestFile, err error) {
+	compactedDataFilePaths := NewSet([]string{})
+	var addedManifestEntries []interface{}
+	for i, icebergCompactionGroup := range icebergCompactionGroups {
+		if icebergCompactionGroup.ManifestPath != manifestPath {
+			continue
+		}
+		for _, dataFilePath := range icebergCompactionGroup.DataFilePaths {
+			compactedDataFilePaths.Add(dataFilePath)
+		}
+
+		manifestEntry := storage.manifestEntry(fileSystemPrefix, snapshotId, IcebergPartitionSpec{}, parquetFiles[i])
+		if icebergCompactionGroup.Partition != nil {
+			manifestEntry["data_file"].(map[string]interface{})["partition"] = icebergCompactionGroup.Partition
+		}
+		addedManifestEntries = append(addedManifestEntries, manifestEntry)
+	}
+
+	err = storage.rewriteAvroFile(manifestPath, filePath, openFile, func(manifestRecords []map[string]interface{}) ([]interface{}, error) {
+		var manifestEntries []interface{}
+		for _, manifestRecord := range manifestRecords {
+			dataFile := manifestRecord["data_file"].(map[string]interface{})
+			if manifestRecord["status"] == int32(2) || compactedDataFilePaths.Contains(dataFile["file_path"].(string)) { // 2: DELETED
+				continue
+			}
+			manifestRecord["status"] = 0 // 0: EXISTING
+			manifestEntries = append(manifestEntries, manifestRecord)
+		}
+		return append(manifestEntries, addedManifestEntries...), nil
+	})
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("Failed to get manifest file info: %v", err)
+	}
+
+	return ManifestFile{
+		SnapshotId: snapshotId,
+		Path:       filePath,
+		Size:       fileInfo.Size(),
+	}, nil
+}
+
+// Copies the manifest list of the compacted snapshot, pointing at the compacted manifests by the path of the manifest they replace
+func (storage *StorageBase) WriteCompactedManifestListFile(fileSystemPrefix string, filePath string, manifestListPath string, compactedManifestFiles map[string]ManifestFile, icebergCompactionGroups []IcebergCompactionGroup, parquetFiles []ParquetFile, openFile func(path string) (io.ReadCloser, error)) (err error) {
+	return storage.copyAvroFile(manifestListPath, filePath, openFile, func(manifestListRecord map[string]interface{}) error {
+		manifestPath := manifestListRecord["manifest_path"].(string)
+		manifestFile, ok := compactedManifestFiles[manifestPath]
+		if !ok {
+			return nil
+		}
+
+		// Counts of the data files before compaction, deleted files aren't carried over to the compacted manifest
+		filesCount := int64(manifestListRecord["added_files_count"].(int32) + manifestListRecord["existing_files_count"].(int32))
+		rowsCount := manifestListRecord["added_rows_count"].(int64) + manifestListRecord["existing_rows_count"].(int64)
+		var addedFilesCount, addedRowsCount int64
+		for i, icebergCompactionGroup := range icebergCompactionGroups {
+			if icebergCompactionGroup.ManifestPath != manifestPath {
+				continue
+			}
+			filesCount -= int64(len(icebergCompactionGroup.DataFilePaths))
+			rowsCount -= icebergCompactionGroup.RecordCount
+			addedFilesCount++
+			addedRowsCount += parquetFiles[i].RecordCount
+		}
+
+		manifestListRecord["manifest_path"] = fileSystemPrefix + manifestFile.Path
+		manifestListRecord["manifest_length"] = manifestFile.Size
+		manifestListRecord["added_snapshot_id"] = manifestFile.SnapshotId
+		manifestListRecord["added_files_count"] = addedFilesCount
+		manifestListRecord["added_rows_count"] = addedRowsCount
+		manifestListRecord["existing_files_count"] = filesCount
+		manifestListRecord["existing_rows_count"] = rowsCount
+		manifestListRecord["deleted_files_count"] = 0
+		manifestListRecord["deleted_rows_count"] = 0
+		return nil
+	})
+}
+
+// Metadata of a new table with a single snapshot, committed as its first version
+func (storage *StorageBase) NewIcebergMetadata(fileSystemPrefix string, filePath string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataContent []byte, err error) {
+	tableUuid := uuid.New().String()
+	lastColumnID := maxPgSchemaColumnOrdinalPosition(pgSchemaColumns)
+	currentTimestampMs := time.Now().UnixNano() / int64(time.Millisecond)
+
+	icebergSchemaFields := make([]interface{}, len(pgSchemaColumns))
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		icebergSchemaFields[i] = pgSchemaColumn.ToIcebergSchemaFieldMap()
+	}
+
+	partitionFields := []interface{}{}
+	lastPartitionId := ICEBERG_FIRST_PARTITION_FIELD_ID - 1
+	for _, icebergPartitionField := range icebergPartitionSpec.Fields {
+		partitionFields = append(partitionFields, icebergPartitionField)
+		lastPartitionId = max(lastPartitionId, icebergPartitionField.FieldId)
+	}
+
+	metadata := map[string]interface{}{
+		"format-version":       2,
+		"table-uuid":           tableUuid,
+		"location":             fileSystemPrefix + filePath,
+		"last-sequence-number": 1,
+		"last-updated-ms":      currentTimestampMs,
+		"last-column-id":       lastColumnID,
+		"schemas": []interface{}{
+			map[string]interface{}{
+				"type":                 "struct",
+				"schema-id":            0,
+				"fields":               icebergSchemaFields,
+				"identifier-field-ids": icebergIdentifierFieldIds(pgSchemaColumns),
+			},
+		},
+		"current-schema-id": 0,
+		"partition-specs": []interface{}{
+			map[string]interface{}{
+				"spec-id": manifestFile.PartitionSpecId,
+				"fields":  partitionFields,
+			},
+		},
+		"default-spec-id":       manifestFile.PartitionSpecId,
+		"default-sort-order-id": 0,
+		"last-partition-id":     lastPartitionId,
+		"properties":            map[string]string{},
+		"current-snapshot-id":   manifestFile.SnapshotId,
+		"refs": map[string]interface{}{
+			"main": map[string]interface{}{
+				"snapshot-id": manifestFile.SnapshotId,
+				"type":        "branch",
+			},
+		},
+		"snapshots": []interface{}{
+			map[string]interface{}{
+				"schema-id":       0,
+				"snapshot-id":     manifestFile.SnapshotId,
+				"sequence-number": 1,
+				"timestamp-ms":    currentTimestampMs,
+				"manifest-list":   fileSystemPrefix + manifestListFile.Path,
+				"summary":         storage.snapshotSummary(parquetFiles, nil),
+			},
+		},
+		"snapshot-log": []interface{}{
+			map[string]interface{}{
+				"snapshot-id":  manifestFile.SnapshotId,
+				"timestamp-ms": currentTimestampMs,
+			},
+		},
+		"metadata-log": []interface{}{},
+		"sort-orders": []interface{}{
+			map[string]interface{}{
+				"order-id": 0,
+				"fields":   []interface{}{},
+			},
+		},
+	}
+
+	return storage.encodeMetadata(metadata)
+}
+
+// Writes a Puffin file with the column sketches of the snapshot, which must already be in the metadata
+func (storage *StorageBase) WriteStatisticsFile(fileSystemPrefix string, filePath string, metadataContent []byte, snapshotId int64, columnSketches *IcebergColumnSketches) (statisticsFile StatisticsFile, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	snapshot := storage.findSnapshot(metadata, snapshotId)
+	if snapshot == nil {
+		return StatisticsFile{}, fmt.Errorf("Snapshot %d not found in metadata", snapshotId)
+	}
+	sequenceNumber, err := snapshot["sequence-number"].(json.Number).Int64()
+	if err != nil {
+		return StatisticsFile{}, fmt.Errorf("Failed to parse sequence number: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	puffinFileMetadata, footerSize, err := columnSketches.WritePuffin(&buffer, snapshotId, sequenceNumber)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	err = os.WriteFile(filePath, buffer.Bytes(), 0644)
+	if err != nil {
+		return StatisticsFile{}, fmt.Errorf("Failed to write statistics file: %v", err)
+	}
+
+	return StatisticsFile{
+		SnapshotId: snapshotId,
+		Path:       fileSystemPrefix + filePath,
+		Size:       int64(buffer.Len()),
+		FooterSize: footerSize,
+		Blobs:      puffinFileMetadata.Blobs,
+	}, nil
+}
+
+// Adds the statistics file to the metadata, replacing previous statistics of the same snapshot
+func (storage *StorageBase) AddIcebergStatistics(metadataContent []byte, statisticsFile StatisticsFile) (updatedMetadataContent []byte, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return nil, err
+	}
+
+	blobMetadata := []interface{}{}
+	for _, blob := range statisticsFile.Blobs {
+		blobMetadata = append(blobMetadata, map[string]interface{}{
+			"type":            blob.Type,
+			"snapshot-id":     blob.SnapshotId,
+			"sequence-number": blob.SequenceNumber,
+			"fields":          blob.Fields,
+			"properties":      blob.Properties,
+		})
+	}
+
+	statistics := []interface{}{}
+	if existingStatistics, ok := metadata["statistics"].([]interface{}); ok {
+		for _, existingStatisticsFile := range existingStatistics {
+			snapshotId, err := existingStatisticsFile.(map[string]interface{})["snapshot-id"].(json.Number).Int64()
+			if err == nil && snapshotId == statisticsFile.SnapshotId {
+				continue
+			}
+			statistics = append(statistics, existingStatisticsFile)
+		}
+	}
+	metadata["statistics"] = append(statistics, map[string]interface{}{
+		"snapshot-id":               statisticsFile.SnapshotId,
+		"statistics-path":           statisticsFile.Path,
+		"file-size-in-bytes":        statisticsFile.Size,
+		"file-footer-size-in-bytes": statisticsFile.FooterSize,
+		"blob-metadata":             blobMetadata,
+	})
+
+	return storage.encodeMetadata(metadata)
+}
+
+// Adds a snapshot with the synced data to the existing metadata and makes it the current snapshot of the main branch.
+// Previous snapshots stay in the snapshot log, and the schema and partition spec are added if they've changed
+func (storage *StorageBase) AddIcebergSnapshot(fileSystemPrefix string, metadataContent []byte, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (updatedMetadataContent []byte, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := storage.addSnapshot(fileSystemPrefix, metadata, ICEBERG_MAIN_BRANCH, pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+	if err != nil {
+		return nil, err
+	}
+	metadata["current-snapshot-id"] = manifestFile.SnapshotId
+	metadata["current-schema-id"] = snapshot["schema-id"]
+	metadata["default-spec-id"] = icebergPartitionSpec.SpecId
+	metadata["snapshot-log"] = append(metadata["snapshot-log"].([]interface{}), map[string]interface{}{
+		"snapshot-id":  manifestFile.SnapshotId,
+		"timestamp-ms": snapshot["timestamp-ms"],
+	})
+
+	return storage.encodeMetadata(metadata)
+}
+
+// Adds a snapshot to the existing metadata and points the branch at it, keeping the snapshots of other refs intact
+func (storage *StorageBase) AddIcebergBranchSnapshot(fileSystemPrefix string, metadataContent []byte, branch string, pgSchemaColumns []PgSchemaColumn, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (updatedMetadataContent []byte, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = storage.addSnapshot(fileSystemPrefix, metadata, branch, pgSchemaColumns, IcebergPartitionSpec{SpecId: manifestFile.PartitionSpecId}, parquetFiles, manifestFile, manifestListFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.encodeMetadata(metadata)
+}
+
+// Adds a "replace" snapshot with the compacted data files on top of the snapshot they were compacted from, keeping its schema.
+// Fails if another snapshot has become current since, so that a sync committed during compaction isn't overwritten
+func (storage *StorageBase) AddIcebergCompactedSnapshot(fileSystemPrefix string, metadataContent []byte, compactedSnapshotId int64, snapshotId int64, icebergCompactionGroups []IcebergCompactionGroup, parquetFiles []ParquetFile, manifestListFile ManifestListFile) (updatedMetadataContent []byte, err error) {
+	metadata, err := storage.decodeMetadata(metadataContent)
+	if err != nil {
+		return nil, err
+	}
+
+	currentSnapshotId, _ := metadata["current-snapshot-id"].(json.Number).Int64()
+	if currentSnapshotId != compactedSnapshotId {
+		return nil, fmt.Errorf("Failed to commit compaction, snapshot %d isn't current anymore", compactedSnapshotId)
+	}
+	compactedSnapshot := storage.findSnapshot(metadata, compactedSnapshotId)
+	if compactedSnapshot == nil {
+		return nil, fmt.Errorf("Failed to find snapshot %d in metadata file", compactedSnapshotId)
+	}
+	lastSequenceNumber, err := metadata["last-sequence-number"].(json.Number).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse last sequence number: %v", err)
+	}
+
+	var deletedFilesCount, deletedFilesSize, addedFilesSize int64
+	for i, icebergCompactionGroup := range icebergCompactionGroups {
+		deletedFilesCount += int64(len(icebergCompactionGroup.DataFilePaths))
+		deletedFilesSize += icebergCompactionGroup.Size
+		addedFilesSize += parquetFiles[i].Size
+	}
+	summary := map[string]interface{}{
+		"operation":          "replace",
+		"added-data-files":   strconv.Itoa(len(parquetFiles)),
+		"added-files-size":   strconv.FormatInt(addedFilesSize, 10),
+		"deleted-data-files": strconv.FormatInt(deletedFilesCount, 10),
+		"removed-files-size": strconv.FormatInt(deletedFilesSize, 10),
+	}
+	compactedSummary, _ := compactedSnapshot["summary"].(map[string]interface{})
+	for key, value := range compactedSummary {
+		if _, ok := summary[key]; !ok && strings.HasPrefix(key, "total-") {
+			summary[key] = value
+		}
+	}
+	if totalDataFiles, err := strconv.ParseInt(fmt.Sprint(compactedSummary["total-data-files"]), 10, 64); err == nil {
+		summary["total-data-files"] = strconv.FormatInt(totalDataFiles-deletedFilesCount+int64(len(parquetFiles)), 10)
+	}
+	if totalFilesSize, err := strconv.ParseInt(fmt.Sprint(compactedSummary["total-files-size"]), 10, 64); err == nil {
+		summary["total-files-size"] = strconv.FormatInt(totalFilesSize-deletedFilesSize+addedFilesSize, 10)
+	}
+
+	currentTimestampMs := time.Now().UnixNano() / int64(time.Millisecond)
+	snapshot := map[string]interface{}{
+		"schema-id":          compactedSnapshot["schema-id"],
+		"snapshot-id":        snapshotId,
+		"parent-snapshot-id": compactedSnapshotId,
+		"sequence-number":    lastSequenceNumber + 1,
+		"timestamp-ms":       currentTimestampMs,
+		"manifest-list":      fileSystemPrefix + manifestListFile.Path,
+		"summary":            summary,
+	}
+	metadata["snapshots"] = append(metadata["snapshots"].([]interface{}), snapshot)
+	metadata["refs"].(map[string]interface{})[ICEBERG_MAIN_BRANCH] = IcebergRef{SnapshotId: snapshotId, Type: ICEBERG_REF_TYPE_BRANCH}
+	metadata["current-snapshot-id"] = snapshotId
+	metadata["snapshot-log"] = append(metadata["snapshot-log"].([]interface{}), map[string]interface{}{
+		"snapshot-id":  snapshotId,
+		"timestamp-ms": currentTimestampMs,
+	})
+	metadata["last-sequence-number"] = lastSequenceNumber + 1
+	metadata["last-updated-ms"] = currentTimestampMs
+
+	return storage.encodeMetadata(metadata)
+}
+
+func (storage *StorageBase) addSnapshot(fileSystemPrefix string, metadata map[string]interface{}, branch string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (snapshot map[string]interface{}, err error) {
+	currentTimestampMs := time.Now().UnixNano() / int64(time.Millisecond)
+	schemaId := storage.findOrAddSchema(metadata, pgSchemaColumns)
+	storage.findOrAddPartitionSpec(metadata, icebergPartitionSpec)
+
+	lastSequenceNumber, err := metadata["last-sequence-number"].(json.Number).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse last sequence number: %v", err)
+	}
+	lastColumnID, err := metadata["last-column-id"].(json.Number).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse last column id: %v", err)
+	}
+
+	refs := metadata["refs"].(map[string]interface{})
+	parentSnapshotId := metadata["current-snapshot-id"]
+	if branchRef, ok := refs[branch].(map[string]interface{}); ok {
+		parentSnapshotId = branchRef["snapshot-id"]
+	}
+	var parentSnapshot map[string]interface{}
+	if id, err := strconv.ParseInt(fmt.Sprint(parentSnapshotId), 10, 64); err == nil {
+		parentSnapshot = storage.findSnapshot(metadata, id)
+	}
+
+	snapshot = map[string]interface{}{
+		"schema-id":          schemaId,
+		"snapshot-id":        manifestFile.SnapshotId,
+		"parent-snapshot-id": parentSnapshotId,
+		"sequence-number":    lastSequenceNumber + 1,
+		"timestamp-ms":       currentTimestampMs,
+		"manifest-list":      fileSystemPrefix + manifestListFile.Path,
+		"summary":            storage.snapshotSummary(parquetFiles, parentSnapshot),
+	}
+	metadata["snapshots"] = append(metadata["snapshots"].([]interface{}), snapshot)
+	refs[branch] = IcebergRef{SnapshotId: manifestFile.SnapshotId, Type: ICEBERG_REF_TYPE_BRANCH}
+	metadata["last-sequence-number"] = lastSequenceNumber + 1
+	metadata["last-column-id"] = max(lastColumnID, int64(maxPgSchemaColumnOrdinalPosition(pgSchemaColumns)))
+	metadata["last-updated-ms"] = currentTimestampMs
+
+	return snapshot, nil
+}
+
+// Creates the first metadata version of a new table. If the table already exists, or another writer creates it first,
+// the update is committed to the existing metadata as the next version instead, see CommitMetadata
+func (storage *StorageBase) CreateOrCommitMetadata(readMetadata func() (metadataContent []byte, version int64, err error), newMetadata func() (metadataContent []byte, err error), updateMetadata func(metadataContent []byte) (updatedMetadataContent []byte, err error), createMetadataVersion func(version int64, metadataContent []byte) (err error)) (version int64, err error) {
+	_, _, err = readMetadata()
+	if err != nil {
+		metadataContent, err := newMetadata()
+		if err != nil {
+			return 0, err
+		}
+
+		err = createMetadataVersion(1, metadataContent)
+		if err == nil {
+			return 1, nil
+		}
+		if !errors.Is(err, ERROR_ICEBERG_COMMIT_CONFLICT) {
+			return 0, err
+		}
+	}
+
+	return storage.CommitMetadata(readMetadata, updateMetadata, createMetadataVersion)
+}
+
+// Commits the updated metadata as the next version, which must not exist yet. If another writer commits
+// that version first, the update is applied again to the metadata of the version it committed
+func (storage *StorageBase) CommitMetadata(readMetadata func() (metadataContent []byte, version int64, err error), updateMetadata func(metadataContent []byte) (updatedMetadataContent []byte, err error), createMetadataVersion func(version int64, metadataContent []byte) (err error)) (version int64, err error) {
+	for attempt := 0; ; attempt++ {
+		metadataContent, currentVersion, err := readMetadata()
+		if err != nil {
+			if !errors.Is(err, ERROR_ICEBERG_COMMIT_CONFLICT) || attempt == ICEBERG_COMMIT_RETRIES {
+				return 0, err
+			}
+			LogWarn(storage.config, "Metadata is being committed by another writer, retrying:", err)
+			continue
+		}
+
+		metadataContent, err = updateMetadata(metadataContent)
+		if err != nil {
+			return 0, err
+		}
+
+		err = createMetadataVersion(currentVersion+1, metadataContent)
+		if err == nil {
+			return currentVersion + 1, nil
+		}
+		if !errors.Is(err, ERROR_ICEBERG_COMMIT_CONFLICT) || attempt == ICEBERG_COMMIT_RETRIES {
+			return 0, err
+		}
+		LogWarn(storage.config, "Metadata version", currentVersion+1, "was committed by another writer, retrying against the latest metadata")
+	}
+}
+
+// Returns the id of the schema matching the columns, adding a new schema if the columns have changed
+func (storage *StorageBase) findOrAddSchema(metadata map[string]interface{}, pgSchemaColumns []PgSchemaColumn) int64 {
+	icebergSchemaFields := make([]interface{}, len(pgSchemaColumns))
+	for i, pgSchemaColumn := range pgSchemaColumns {
+		icebergSchemaFields[i] = pgSchemaColumn.ToIcebergSchemaFieldMap()
+	}
+	identifierFieldIds := icebergIdentifierFieldIds(pgSchemaColumns)
+	fieldsJson, err := json.Marshal([]interface{}{icebergSchemaFields, identifierFieldIds})
+	PanicIfError(err)
+
+	schemas := metadata["schemas"].([]interface{})
+	var maxSchemaId int64
+	for _, schema := range schemas {
+		schemaId, err := schema.(map[string]interface{})["schema-id"].(json.Number).Int64()
+		PanicIfError(err)
+		maxSchemaId = max(maxSchemaId, schemaId)
+
+		existingFieldsJson, err := json.Marshal([]interface{}{schema.(map[string]interface{})["fields"], schema.(map[string]interface{})["identifier-field-ids"]})
+		PanicIfError(err)
+		if bytes.Equal(existingFieldsJson, fieldsJson) {
+			return schemaId
+		}
+	}
+
+	metadata["schemas"] = append(schemas, map[string]interface{}{
+		"type":                 "struct",
+		"schema-id":            maxSchemaId + 1,
+		"fields":               icebergSchemaFields,
+		"identifier-field-ids": identifierFieldIds,
+	})
+	return maxSchemaId + 1
+}
+
+// Adds the partition spec unless the table already has a spec with its id.
+// The default spec is only changed by main branch snapshots, since branches are written under the unpartitioned spec
+func (storage *StorageBase) findOrAddPartitionSpec(metadata map[string]interface{}, icebergPartitionSpec IcebergPartitionSpec) {
+	partitionSpecs, _ := metadata["partition-specs"].([]interface{})
+	for _, partitionSpec := range partitionSpecs {
+		existingSpecId, err := partitionSpec.(map[string]interface{})["spec-id"].(json.Number).Int64()
+		PanicIfError(err)
+		if existingSpecId == int64(icebergPartitionSpec.SpecId) {
+			return
+		}
+	}
+
+	lastPartitionIdNumber, _ := metadata["last-partition-id"].(json.Number)
+	lastPartitionId, err := lastPartitionIdNumber.Int64()
+	if err != nil {
+		lastPartitionId = ICEBERG_FIRST_PARTITION_FIELD_ID - 1
+	}
+	partitionFields := []interface{}{}
+	for _, icebergPartitionField := range icebergPartitionSpec.Fields {
+		partitionFields = append(partitionFields, icebergPartitionField)
+		lastPartitionId = max(lastPartitionId, int64(icebergPartitionField.FieldId))
+	}
+	metadata["last-partition-id"] = lastPartitionId
+	metadata["partition-specs"] = append(partitionSpecs, map[string]interface{}{
+		"spec-id": icebergPartitionSpec.SpecId,
+		"fields":  partitionFields,
+	})
+}
+
+// Id of the spec without partition fields, or the next free id if the table has always been partitioned
+func unpartitionedSpecId(icebergPartitionSpecs []IcebergPartitionSpec) int {
+	return icebergPartitionSpecId(icebergPartitionSpecs, nil)
+}
+
+// Id of the spec with the same partition fields, or the next free id if the table doesn't have such a spec yet
+func icebergPartitionSpecId(icebergPartitionSpecs []IcebergPartitionSpec, icebergPartitionFields []IcebergPartitionField) int {
+	if len(icebergPartitionSpecs) == 0 {
+		return ICEBERG_UNPARTITIONED_SPEC_ID
+	}
+
+	maxSpecId := 0
+	for _, icebergPartitionSpec := range icebergPartitionSpecs {
+		if icebergPartitionFieldsEqual(icebergPartitionSpec.Fields, icebergPartitionFields) {
+			return icebergPartitionSpec.SpecId
+		}
+		maxSpecId = max(maxSpecId, icebergPartitionSpec.SpecId)
+	}
+	return maxSpecId + 1
+}
+
+// Compares the fields as they're stored in the metadata, without the result types that are only known for fields BemiDB partitions by
+func icebergPartitionFieldsEqual(icebergPartitionFields []IcebergPartitionField, otherIcebergPartitionFields []IcebergPartitionField) bool {
+	if len(icebergPartitionFields) != len(otherIcebergPartitionFields) {
+		return false
+	}
+	for i, icebergPartitionField := range icebergPartitionFields {
+		otherIcebergPartitionField := otherIcebergPartitionFields[i]
+		otherIcebergPartitionField.ResultType = icebergPartitionField.ResultType
+		if icebergPartitionField != otherIcebergPartitionField {
+			return false
+		}
+	}
+	return true
+}
+
+// Iceberg identifier field ids of the primary key columns, in key order
+func icebergIdentifierFieldIds(pgSchemaColumns []PgSchemaColumn) []interface{} {
+	fieldIdByPosition := map[int]int{}
+	for _, pgSchemaColumn := range pgSchemaColumns {
+		primaryKeyPosition, err := StringToInt(pgSchemaColumn.PrimaryKeyPosition)
+		if err != nil || primaryKeyPosition < 1 || pgSchemaColumn.IsNullable == PG_TRUE {
+			continue
+		}
+		ordinalPosition, err := StringToInt(pgSchemaColumn.OrdinalPosition)
+		PanicIfError(err)
+		fieldIdByPosition[primaryKeyPosition] = ordinalPosition
+	}
+
+	identifierFieldIds := []interface{}{}
+	for position := 1; position <= len(pgSchemaColumns); position++ {
+		if fieldId, ok := fieldIdByPosition[position]; ok {
+			identifierFieldIds = append(identifierFieldIds, fieldId)
+		}
+	}
+	return identifierFieldIds
+}
+
+func (storage *StorageBase) findSnapshot(metadata map[string]interface{}, snapshotId int64) map[string]interface{} {
+	for _, snapshot := range metadata["snapshots"].([]interface{}) {
+		existingSnapshotId, err := snapshot.(map[string]interface{})["snapshot-id"].(json.Number).Int64()
+		if err == nil && existingSnapshotId == snapshotId {
+			return snapshot.(map[string]interface{})
+		}
+	}
+	return nil
+}
+
+func (storage *StorageBase) readSnapshotDataFilePaths(manifestListPath string, openFile func(path string) (io.ReadCloser, error)) (dataFilePaths []string, err error) {
+	manifestListRecords, err := storage.readAvroRecords(manifestListPath, openFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifestListRecord := range manifestListRecords {
+		manifestRecords, err := storage.readAvroRecords(manifestListRecord["manifest_path"].(string), openFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, manifestRecord := range manifestRecords {
+			dataFile := manifestRecord["data_file"].(map[string]interface{})
+			dataFilePaths = append(dataFilePaths, dataFile["file_path"].(string))
+		}
+	}
+
+	return dataFilePaths, nil
+}
+
+func (storage *StorageBase) readAvroRecords(filePath string, openFile func(path string) (io.ReadCloser, error)) (records []map[string]interface{}, err error) {
+	file, err := openFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open Avro file %s: %v", filePath, err)
+	}
+	defer file.Close()
+
+	ocfReader, err := goavro.NewOCFReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Avro OCF reader: %v", err)
+	}
+
+	for ocfReader.Scan() {
+		record, err := ocfReader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read Avro record: %v", err)
+		}
+		records = append(records, record.(map[string]interface{}))
+	}
+
+	return records, ocfReader.Err()
+}
+
+// Keeps the schema and metadata of the Avro file, e.g. the partition record of manifests
+func (storage *StorageBase) copyAvroFile(filePath string, localFilePath string, openFile func(path string) (io.ReadCloser, error), updateRecord func(record map[string]interface{}) error) error {
+	return storage.rewriteAvroFile(filePath, localFilePath, openFile, func(records []map[string]interface{}) ([]interface{}, error) {
+		var updatedRecords []interface{}
+		for _, record := range records {
+			err := updateRecord(record)
+			if err != nil {
+				return nil, err
+			}
+			updatedRecords = append(updatedRecords, record)
+		}
+		return updatedRecords, nil
+	})
+}
+
+// Like copyAvroFile, but the records can be removed or added
+func (storage *StorageBase) rewriteAvroFile(filePath string, localFilePath string, openFile func(path string) (io.ReadCloser, error), rewriteRecords func(records []map[string]interface{}) ([]interface{}, error)) error {
+	file, err := openFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Failed to open Avro file %s: %v", filePath, err)
+	}
+	defer file.Close()
+
+	ocfReader, err := goavro.NewOCFReader(file)
+	if err != nil {
+		return fmt.Errorf("Failed to create Avro OCF reader: %v", err)
+	}
+
+	var records []map[string]interface{}
+	for ocfReader.Scan() {
+		record, err := ocfReader.Read()
+		if err != nil {
+			return fmt.Errorf("Failed to read Avro record: %v", err)
+		}
+		records = append(records, record.(map[string]interface{}))
+	}
+	if ocfReader.Err() != nil {
+		return ocfReader.Err()
+	}
+
+	rewrittenRecords, err := rewriteRecords(records)
+	if err != nil {
+		return err
+	}
+
+	localFile, err := os.Create(localFilePath)
+	if err != nil {
+		return fmt.Errorf("Failed to create local Avro file: %v", err)
+	}
+	defer localFile.Close()
+
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               localFile,
+		Codec:           ocfReader.Codec(),
+		CompressionName: ocfReader.CompressionName(),
+		MetaData:        ocfReader.MetaData(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create Avro OCF writer: %v", err)
+	}
+
+	err = ocfWriter.Append(rewrittenRecords)
+	if err != nil {
+		return fmt.Errorf("Failed to write to local Avro file: %v", err)
+	}
+	return nil
+}
+
+func (storage *StorageBase) copyFile(filePath string, localFilePath string, openFile func(path string) (io.ReadCloser, error)) error {
+	file, err := openFile(filePath)
+	if err != nil {
+		return fmt.Errorf("Failed to open file %s: %v", filePath, err)
+	}
+	defer file.Close()
+
+	localFile, err := os.Create(localFilePath)
+	if err != nil {
+		return fmt.Errorf("Failed to create local file: %v", err)
+	}
+	defer localFile.Close()
+
+	_, err = io.Copy(localFile, file)
+	if err != nil {
+		return fmt.Errorf("Failed to copy %s: %v", filePath, err)
+	}
+	return nil
+}
+
+// Keeps snapshot ids as json.Number, since they don't fit into float64 without losing precision
+func (storage *StorageBase) decodeMetadata(metadataContent []byte) (metadata map[string]interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(metadataContent))
+	decoder.UseNumber()
+	err = decoder.Decode(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata file: %v", err)
+	}
+
+	if metadata["refs"] == nil {
+		metadata["refs"] = map[string]interface{}{}
+	}
+	if metadata["snapshot-log"] == nil {
+		metadata["snapshot-log"] = []interface{}{}
+	}
+
+	return metadata, nil
+}
+
+func (storage *StorageBase) encodeMetadata(metadata map[string]interface{}) (metadataContent []byte, err error) {
+	metadataContent, err = json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode metadata: %v", err)
+	}
+	return append(metadataContent, '\n'), nil
+}
+
+// Summary counts allow query engines to estimate table sizes without reading manifests
+// A sync replaces all rows of the table, so a snapshot with a parent is an "overwrite" that deletes the files of the parent
+func (storage *StorageBase) snapshotSummary(parquetFiles []ParquetFile, parentSnapshot map[string]interface{}) map[string]interface{} {
+	var totalFilesSize, totalRecords int64
+	for _, parquetFile := range parquetFiles {
+		totalFilesSize += parquetFile.Size
+		totalRecords += parquetFile.RecordCount
+	}
+	totalDataFiles := strconv.Itoa(len(parquetFiles))
+
+	summary := map[string]interface{}{
+		"added-data-files":       totalDataFiles,
+		"added-files-size":       strconv.FormatInt(totalFilesSize, 10),
+		"added-records":          strconv.FormatInt(totalRecords, 10),
+		"operation":              "append",
+		"total-data-files":       totalDataFiles,
+		"total-delete-files":     "0",
+		"total-equality-deletes": "0",
+		"total-files-size":       strconv.FormatInt(totalFilesSize, 10),
+		"total-position-deletes": "0",
+		"total-records":          strconv.FormatInt(totalRecords, 10),
+	}
+	if parentSnapshot == nil {
+		return summary
+	}
+
+	summary["operation"] = "overwrite"
+	parentSummary, _ := parentSnapshot["summary"].(map[string]interface{})
+	for deletedKey, totalKey := range map[string]string{
+		"deleted-data-files": "total-data-files",
+		"deleted-records":    "total-records",
+		"removed-files-size": "total-files-size",
+	} {
+		if value, ok := parentSummary[totalKey]; ok {
+			summary[deletedKey] = fmt.Sprint(value)
+		}
+	}
+
+	return summary
+}
+
+func (storage *StorageBase) WriteVersionHintFile(filePath string, metadataFile MetadataFile) (err error) {
+	versionHintFile, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("Failed to create version hint file: %v", err)
+	}
+	defer versionHintFile.Close()
+
+	_, err = versionHintFile.WriteString(fmt.Sprintf("%d", metadataFile.Version))
+	if err != nil {
+		return fmt.Errorf("Failed to write to version hint file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageBase) ParseVersionHint(versionHintContent []byte) (version int64, err error) {
+	version, err = strconv.ParseInt(strings.TrimSpace(string(versionHintContent)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse version hint: %v", err)
+	}
+
+	return version, nil
+}
+
+// The latest vN.metadata.json of the file names, 0 if there is none
+func (storage *StorageBase) LatestMetadataVersion(fileNames []string) (version int64) {
+	for _, fileName := range fileNames {
+		var fileVersion int64
+		_, err := fmt.Sscanf(fileName, "v%d.metadata.json", &fileVersion)
+		if err == nil && fileName == IcebergMetadataFileName(fileVersion) {
+			version = max(version, fileVersion)
+		}
+	}
+	return version
+}
+
+func (storage *StorageBase) WriteAuditRecordFile(filePath string, auditRecord AuditRecord) (err error) {
+	auditRecordJson, err := json.Marshal(auditRecord)
+	if err != nil {
+		return fmt.Errorf("Failed to encode audit record: %v", err)
+	}
+
+	err = os.WriteFile(filePath, append(auditRecordJson, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write audit record: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageBase) buildFieldIDMap(schemaHandler *schema.SchemaHandler) map[string]int {
+	fieldIDMap := make(map[string]int)
+	for _, schema := range schemaHandler.SchemaElements {
+		if schema.FieldID != nil {
+			fieldIDMap[schema.Name] = int(*schema.FieldID)
+		}
+	}
+	return fieldIDMap
+}
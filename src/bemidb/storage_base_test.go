@@ -0,0 +1,582 @@
+package bemidb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+func TestWriteMetadataFile(t *testing.T) {
+	t.Run("Writes snapshot summary counts matching the data", func(t *testing.T) {
+		config := loadTestConfig()
+		tableDir := filepath.Join(config.StoragePath, "public", "test_table")
+
+		metadataContent, err := os.ReadFile(filepath.Join(tableDir, "metadata", "v1.metadata.json"))
+		testNoError(t, err)
+		var metadata struct {
+			Snapshots []struct {
+				Summary map[string]string `json:"summary"`
+			} `json:"snapshots"`
+		}
+		err = json.Unmarshal(metadataContent, &metadata)
+		testNoError(t, err)
+
+		dataFilePaths, err := filepath.Glob(filepath.Join(tableDir, "data", "*.parquet"))
+		testNoError(t, err)
+		var totalFilesSize int64
+		for _, dataFilePath := range dataFilePaths {
+			fileInfo, err := os.Stat(dataFilePath)
+			testNoError(t, err)
+			totalFilesSize += fileInfo.Size()
+		}
+
+		summary := metadata.Snapshots[0].Summary
+		if summary["total-records"] != strconv.Itoa(len(TEST_LOADED_ROWS)) {
+			t.Errorf("Expected total-records to be %v, got %v", len(TEST_LOADED_ROWS), summary["total-records"])
+		}
+		if summary["total-data-files"] != strconv.Itoa(len(dataFilePaths)) {
+			t.Errorf("Expected total-data-files to be %v, got %v", len(dataFilePaths), summary["total-data-files"])
+		}
+		if summary["total-files-size"] != strconv.FormatInt(totalFilesSize, 10) {
+			t.Errorf("Expected total-files-size to be %v, got %v", totalFilesSize, summary["total-files-size"])
+		}
+	})
+}
+
+func TestWriteParquetFile(t *testing.T) {
+	t.Run("Omits statistics for columns that aren't in Parquet stats columns", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.Parquet.StatsColumns = NewSet([]string{"id"})
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+			{ColumnName: "status", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+		}
+		filePath := filepath.Join(t.TempDir(), "data.parquet")
+		fileWriter, err := local.NewLocalFileWriter(filePath)
+		testNoError(t, err)
+		loaded := false
+
+		storageBase := &StorageBase{config: &config}
+		recordCount, _, _, err := storageBase.WriteParquetFile(IcebergSchemaTable{Schema: "public", Table: "test_table"}, fileWriter, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return [][]string{{"1", "active"}, {"2", PG_NULL_STRING}}
+		})
+
+		testNoError(t, err)
+		if recordCount != 2 {
+			t.Errorf("Expected 2 records, got %v", recordCount)
+		}
+		fileReader, err := local.NewLocalFileReader(filePath)
+		testNoError(t, err)
+		parquetStats, err := storageBase.ReadParquetStats(fileReader)
+		testNoError(t, err)
+		if parquetStats.LowerBounds[1] == nil || parquetStats.UpperBounds[1] == nil {
+			t.Errorf("Expected bounds for the id column, got %v and %v", parquetStats.LowerBounds, parquetStats.UpperBounds)
+		}
+		if _, ok := parquetStats.LowerBounds[2]; ok {
+			t.Errorf("Expected no lower bound for the status column, got %v", parquetStats.LowerBounds[2])
+		}
+		if _, ok := parquetStats.UpperBounds[2]; ok {
+			t.Errorf("Expected no upper bound for the status column, got %v", parquetStats.UpperBounds[2])
+		}
+		if parquetStats.ValueCounts[1] != 2 || parquetStats.ValueCounts[2] != 2 {
+			t.Errorf("Expected value counts for all columns, got %v", parquetStats.ValueCounts)
+		}
+		if parquetStats.ColumnSizes[2] == 0 {
+			t.Errorf("Expected a column size for the status column, got %v", parquetStats.ColumnSizes)
+		}
+	})
+
+	t.Run("Reads Iceberg bounds of common types across row groups", func(t *testing.T) {
+		config := *loadTestConfig()
+		config.Parquet.RowGroupSize = 1024
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+			{ColumnName: "big_id", DataType: "bigint", UdtName: "int8", IsNullable: "NO", OrdinalPosition: "2", NumericPrecision: "64", Namespace: "pg_catalog"},
+			{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "3", Namespace: "pg_catalog"},
+			{ColumnName: "created_at", DataType: "timestamp without time zone", UdtName: "timestamp", IsNullable: "NO", OrdinalPosition: "4", DatetimePrecision: "3", Namespace: "pg_catalog"},
+			{ColumnName: "active", DataType: "boolean", UdtName: "bool", IsNullable: "NO", OrdinalPosition: "5", Namespace: "pg_catalog"},
+			{ColumnName: "score", DataType: "double precision", UdtName: "float8", IsNullable: "NO", OrdinalPosition: "6", Namespace: "pg_catalog"},
+		}
+		rows := [][]string{}
+		for i := -1000; i < 1000; i++ {
+			name := "name" + strconv.Itoa(i+1000)
+			if i == 0 {
+				name = PG_NULL_STRING
+			}
+			rows = append(rows, []string{strconv.Itoa(i), strconv.Itoa(i * 1000000000), name, "2025-01-01 00:00:00.123", strconv.FormatBool(i > 0), "1.5"})
+		}
+		rows = append(rows, []string{"0", "0", "zzzzzzzzzzzzzzzzzzzz", "2024-12-31 23:59:59.999", "false", "1.5"})
+		filePath := filepath.Join(t.TempDir(), "data.parquet")
+		fileWriter, err := local.NewLocalFileWriter(filePath)
+		testNoError(t, err)
+		loaded := false
+
+		storageBase := &StorageBase{config: &config}
+		_, _, _, err = storageBase.WriteParquetFile(IcebergSchemaTable{Schema: "public", Table: "test_table"}, fileWriter, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return rows
+		})
+		testNoError(t, err)
+		fileReader, err := local.NewLocalFileReader(filePath)
+		testNoError(t, err)
+		parquetStats, err := storageBase.ReadParquetStats(fileReader)
+
+		testNoError(t, err)
+		expectedLowerBounds := map[int][]byte{
+			1: icebergBoundBytes(int32(-1000)),
+			2: icebergBoundBytes(int64(-1000000000000)),
+			3: []byte("name0"),
+			4: icebergBoundBytes(int64(1735689599999000)),
+			5: {0},
+		}
+		expectedUpperBounds := map[int][]byte{
+			1: icebergBoundBytes(int32(999)),
+			2: icebergBoundBytes(int64(999000000000)),
+			3: []byte("zzzzzzzzzzzzzzz{"),
+			4: icebergBoundBytes(int64(1735689600123000)),
+			5: {1},
+		}
+		for fieldID, expectedLowerBound := range expectedLowerBounds {
+			if !slices.Equal(parquetStats.LowerBounds[fieldID], expectedLowerBound) {
+				t.Errorf("Expected lower bound %v for field %d, got %v", expectedLowerBound, fieldID, parquetStats.LowerBounds[fieldID])
+			}
+		}
+		for fieldID, expectedUpperBound := range expectedUpperBounds {
+			if !slices.Equal(parquetStats.UpperBounds[fieldID], expectedUpperBound) {
+				t.Errorf("Expected upper bound %v for field %d, got %v", expectedUpperBound, fieldID, parquetStats.UpperBounds[fieldID])
+			}
+		}
+		if _, ok := parquetStats.LowerBounds[6]; ok {
+			t.Errorf("Expected no lower bound for the float column, got %v", parquetStats.LowerBounds[6])
+		}
+		if parquetStats.NullValueCounts[3] != 1 || parquetStats.NullValueCounts[1] != 0 {
+			t.Errorf("Expected null value counts of 1 for the name column and 0 for id, got %v", parquetStats.NullValueCounts)
+		}
+	})
+
+	t.Run("Writes row groups of the configured Parquet row group size", func(t *testing.T) {
+		config := *loadTestConfig()
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		}
+		rows := [][]string{}
+		for i := range 10000 {
+			rows = append(rows, []string{strconv.Itoa(i)})
+		}
+
+		for _, testCase := range []struct {
+			rowGroupSize int64
+			multiple     bool
+		}{{rowGroupSize: 1024, multiple: true}, {rowGroupSize: 0, multiple: false}, {rowGroupSize: -1, multiple: false}} {
+			config.Parquet.RowGroupSize = testCase.rowGroupSize
+			filePath := filepath.Join(t.TempDir(), "data.parquet")
+			fileWriter, err := local.NewLocalFileWriter(filePath)
+			testNoError(t, err)
+			loaded := false
+
+			storageBase := &StorageBase{config: &config}
+			recordCount, _, _, err := storageBase.WriteParquetFile(IcebergSchemaTable{Schema: "public", Table: "test_table"}, fileWriter, pgSchemaColumns, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return rows
+			})
+			testNoError(t, err)
+
+			fileReader, err := local.NewLocalFileReader(filePath)
+			testNoError(t, err)
+			parquetStats, err := storageBase.ReadParquetStats(fileReader)
+			testNoError(t, err)
+			if recordCount != 10000 || parquetStats.ValueCounts[1] != 10000 {
+				t.Errorf("Expected 10000 records with %d row group size, got %d and %v", testCase.rowGroupSize, recordCount, parquetStats.ValueCounts)
+			}
+			fileReader, err = local.NewLocalFileReader(filePath)
+			testNoError(t, err)
+			parquetReader, err := reader.NewParquetReader(fileReader, nil, 1)
+			testNoError(t, err)
+			rowGroupCount := len(parquetReader.Footer.RowGroups)
+			parquetReader.ReadStop()
+			fileReader.Close()
+			if testCase.multiple && rowGroupCount < 2 {
+				t.Errorf("Expected multiple row groups with %d row group size, got %d", testCase.rowGroupSize, rowGroupCount)
+			}
+			if !testCase.multiple && rowGroupCount != 1 {
+				t.Errorf("Expected a single row group with %d row group size, got %d", testCase.rowGroupSize, rowGroupCount)
+			}
+		}
+	})
+
+	t.Run("Writes files readable by DuckDB with each Parquet compression", func(t *testing.T) {
+		config := *loadTestConfig()
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		}
+		duckdb := NewDuckdb(&config)
+		defer duckdb.Close()
+
+		for _, compression := range PARQUET_COMPRESSIONS {
+			config.Parquet.Compression = compression
+			filePath := filepath.Join(t.TempDir(), compression+".parquet")
+			fileWriter, err := local.NewLocalFileWriter(filePath)
+			testNoError(t, err)
+			loaded := false
+
+			storageBase := &StorageBase{config: &config}
+			_, _, _, err = storageBase.WriteParquetFile(IcebergSchemaTable{Schema: "public", Table: "test_table"}, fileWriter, pgSchemaColumns, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return [][]string{{"1"}, {"2"}}
+			})
+			testNoError(t, err)
+
+			rows, err := duckdb.QueryContext(context.Background(), "SELECT compression, (SELECT SUM(id) FROM read_parquet('"+filePath+"')) FROM parquet_metadata('"+filePath+"')")
+			testNoError(t, err)
+			var codec string
+			var sum int
+			rows.Next()
+			testNoError(t, rows.Scan(&codec, &sum))
+			rows.Close()
+			if codec != PARQUET_COMPRESSION_CODECS[compression].String() {
+				t.Errorf("Expected %s codec for %s compression, got %s", PARQUET_COMPRESSION_CODECS[compression], compression, codec)
+			}
+			if sum != 3 {
+				t.Errorf("Expected the sum of ids to be 3 for %s compression, got %d", compression, sum)
+			}
+		}
+	})
+
+	t.Run("Writes booleans from Postgres text representation as Parquet booleans", func(t *testing.T) {
+		config := *loadTestConfig()
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+			{ColumnName: "active", DataType: "boolean", UdtName: "bool", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+		}
+		filePath := filepath.Join(t.TempDir(), "data.parquet")
+		fileWriter, err := local.NewLocalFileWriter(filePath)
+		testNoError(t, err)
+		loaded := false
+		duckdb := NewDuckdb(&config)
+		defer duckdb.Close()
+
+		storageBase := &StorageBase{config: &config}
+		_, _, _, err = storageBase.WriteParquetFile(IcebergSchemaTable{Schema: "public", Table: "test_table"}, fileWriter, pgSchemaColumns, func() [][]string {
+			if loaded {
+				return [][]string{}
+			}
+			loaded = true
+			return [][]string{{"1", "t"}, {"2", "f"}, {"3", "true"}, {"4", "FALSE"}, {"5", PG_NULL_STRING}}
+		})
+		testNoError(t, err)
+
+		rows, err := duckdb.QueryContext(context.Background(), "SELECT typeof(active), string_agg(id::text, ',' ORDER BY id) FROM read_parquet('"+filePath+"') WHERE active = true GROUP BY 1")
+		testNoError(t, err)
+		var columnType, ids string
+		rows.Next()
+		testNoError(t, rows.Scan(&columnType, &ids))
+		rows.Close()
+		if columnType != "BOOLEAN" {
+			t.Errorf("Expected the active column to be BOOLEAN, got %s", columnType)
+		}
+		if ids != "1,3" {
+			t.Errorf("Expected ids 1,3 to be active, got %s", ids)
+		}
+	})
+}
+
+func TestCompactParquetFiles(t *testing.T) {
+	t.Run("Merges Parquet files keeping their values and field ids", func(t *testing.T) {
+		config := *loadTestConfig()
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+			{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+			{ColumnName: "tags", DataType: "ARRAY", UdtName: "_text", IsNullable: "YES", OrdinalPosition: "3", Namespace: "pg_catalog"},
+		}
+		dirPath := t.TempDir()
+		storageBase := &StorageBase{config: &config}
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_table"}
+		var dataFilePaths []string
+		for i, rows := range [][][]string{
+			{{"1", "Alice", "{a,b}"}, {"2", PG_NULL_STRING, "{}"}},
+			{{"3", "Bob", PG_NULL_STRING}, {"4", "Carol", "{c,NULL}"}},
+		} {
+			dataFilePath := filepath.Join(dirPath, fmt.Sprintf("data%d.parquet", i))
+			fileWriter, err := local.NewLocalFileWriter(dataFilePath)
+			testNoError(t, err)
+			loaded := false
+			_, _, _, err = storageBase.WriteParquetFile(schemaTable, fileWriter, pgSchemaColumns, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return rows
+			})
+			testNoError(t, err)
+			dataFilePaths = append(dataFilePaths, dataFilePath)
+		}
+		filePath := filepath.Join(dirPath, "compacted.parquet")
+		fileWriter, err := local.NewLocalFileWriter(filePath)
+		testNoError(t, err)
+		duckdb := NewDuckdb(&config)
+		defer duckdb.Close()
+
+		recordCount, err := storageBase.CompactParquetFiles(schemaTable, fileWriter, dataFilePaths, func(path string) (source.ParquetFile, error) {
+			return local.NewLocalFileReader(path)
+		})
+
+		testNoError(t, err)
+		if recordCount != 4 {
+			t.Errorf("Expected 4 records, got %d", recordCount)
+		}
+		valuesQuery := "SELECT string_agg(concat_ws('|', id, coalesce(name, 'NULL'), coalesce(tags::text, 'NULL')), ';' ORDER BY id) FROM read_parquet(%s)"
+		var values, expectedValues string
+		rows, err := duckdb.QueryContext(context.Background(), fmt.Sprintf(valuesQuery, "['"+dataFilePaths[0]+"', '"+dataFilePaths[1]+"']"))
+		testNoError(t, err)
+		rows.Next()
+		testNoError(t, rows.Scan(&expectedValues))
+		rows.Close()
+		rows, err = duckdb.QueryContext(context.Background(), fmt.Sprintf(valuesQuery, "'"+filePath+"'"))
+		testNoError(t, err)
+		rows.Next()
+		testNoError(t, rows.Scan(&values))
+		rows.Close()
+		if values != expectedValues || values != "1|Alice|[a, b];2|NULL|NULL;3|Bob|NULL;4|Carol|[c, NULL]" {
+			t.Errorf("Expected the values of both files %s, got %s", expectedValues, values)
+		}
+		rows, err = duckdb.QueryContext(context.Background(), "SELECT string_agg(name || ':' || field_id, ',' ORDER BY name) FROM parquet_schema('"+filePath+"') WHERE name IN ('id', 'name')")
+		testNoError(t, err)
+		var fieldIds string
+		rows.Next()
+		testNoError(t, rows.Scan(&fieldIds))
+		rows.Close()
+		if fieldIds != "id:1,name:2" {
+			t.Errorf("Expected the field ids to be kept, got %s", fieldIds)
+		}
+	})
+}
+
+func TestSupersededDataFilePaths(t *testing.T) {
+	t.Run("Returns data files of retained snapshots that aren't in the current snapshot", func(t *testing.T) {
+		config := *loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_superseded_table"}
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		}
+		icebergWriter := NewIcebergWriter(&config)
+		defer icebergWriter.DeleteSchemaTable(schemaTable)
+		for _, branch := range []string{ICEBERG_MAIN_BRANCH, "staging"} {
+			branchConfig := config
+			branchConfig.IcebergBranch = branch
+			loaded := false
+			NewIcebergWriter(&branchConfig).Write(schemaTable, pgSchemaColumns, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return [][]string{{"1"}}
+			})
+		}
+		metadataFilePath := NewIcebergReader(&config).MetadataFilePath(schemaTable)
+		metadataContent, err := os.ReadFile(metadataFilePath)
+		testNoError(t, err)
+
+		storageBase := &StorageBase{config: &config}
+		dataFilePaths, err := storageBase.SupersededDataFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+			return os.Open(path)
+		})
+
+		testNoError(t, err)
+		allDataFilePaths, err := filepath.Glob(filepath.Join(filepath.Dir(filepath.Dir(metadataFilePath)), "data", "*.parquet"))
+		testNoError(t, err)
+		if len(allDataFilePaths) != 2 || len(dataFilePaths) != 1 || !slices.Contains(allDataFilePaths, dataFilePaths[0]) {
+			t.Errorf("Expected one of %v to be superseded, got %v", allDataFilePaths, dataFilePaths)
+		}
+
+		err = icebergWriter.PromoteRef(schemaTable, "staging")
+		testNoError(t, err)
+		metadataContent, err = os.ReadFile(NewIcebergReader(&config).MetadataFilePath(schemaTable))
+		testNoError(t, err)
+		promotedDataFilePaths, err := storageBase.SupersededDataFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+			return os.Open(path)
+		})
+
+		testNoError(t, err)
+		if len(promotedDataFilePaths) != 1 || promotedDataFilePaths[0] == dataFilePaths[0] {
+			t.Errorf("Expected the previous main data file to be superseded after promotion, got %v", promotedDataFilePaths)
+		}
+	})
+}
+
+func TestDownloadIcebergSnapshot(t *testing.T) {
+	t.Run("Copies the files of a snapshot with paths pointing to the local copies", func(t *testing.T) {
+		config := *loadTestConfig()
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "test_download_table"}
+		pgSchemaColumns := []PgSchemaColumn{
+			{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		}
+		defer NewIcebergWriter(&config).DeleteSchemaTable(schemaTable)
+		for _, branch := range []string{ICEBERG_MAIN_BRANCH, "staging"} {
+			branchConfig := config
+			branchConfig.IcebergBranch = branch
+			loaded := false
+			NewIcebergWriter(&branchConfig).Write(schemaTable, pgSchemaColumns, func() [][]string {
+				if loaded {
+					return [][]string{}
+				}
+				loaded = true
+				return [][]string{{"1"}}
+			})
+		}
+		icebergReader := NewIcebergReader(&config)
+		stagingSnapshotId, err := icebergReader.RefSnapshotId(schemaTable, "staging")
+		testNoError(t, err)
+		localDirPath := t.TempDir()
+
+		localMetadataFilePath, err := icebergReader.DownloadSnapshot(schemaTable, stagingSnapshotId, localDirPath)
+
+		testNoError(t, err)
+		if localMetadataFilePath != filepath.Join(localDirPath, "metadata", filepath.Base(icebergReader.MetadataFilePath(schemaTable))) {
+			t.Errorf("Expected the local metadata file to be in %v, got %v", localDirPath, localMetadataFilePath)
+		}
+		localMetadataContent, err := os.ReadFile(localMetadataFilePath)
+		testNoError(t, err)
+		storageBase := &StorageBase{config: &config}
+		localMetadata, err := storageBase.decodeMetadata(localMetadataContent)
+		testNoError(t, err)
+		localManifestListPath := storageBase.findSnapshot(localMetadata, stagingSnapshotId)["manifest-list"].(string)
+		localDataFilePaths, err := storageBase.readSnapshotDataFilePaths(localManifestListPath, func(path string) (io.ReadCloser, error) {
+			if filepath.Dir(filepath.Dir(path)) != localDirPath {
+				t.Errorf("Expected %v to be a local copy", path)
+			}
+			return os.Open(path)
+		})
+		testNoError(t, err)
+		copiedDataFilePaths, err := filepath.Glob(filepath.Join(localDirPath, "data", "*.parquet"))
+		testNoError(t, err)
+		if len(localDataFilePaths) != 1 || !slices.Equal(localDataFilePaths, copiedDataFilePaths) {
+			t.Errorf("Expected only the staging data file to be copied, got %v and %v", localDataFilePaths, copiedDataFilePaths)
+		}
+	})
+}
+
+func TestCommitMetadata(t *testing.T) {
+	config := loadTestConfig()
+	storageBase := &StorageBase{config: config}
+	appendCommit := func(metadataContent []byte) ([]byte, error) {
+		return append(metadataContent, '+'), nil
+	}
+
+	t.Run("Retries the commit against the latest metadata when another writer commits first", func(t *testing.T) {
+		committedVersions := map[int64][]byte{1: []byte("v1")}
+		readMetadata := func() ([]byte, int64, error) {
+			version := int64(len(committedVersions))
+			return committedVersions[version], version, nil
+		}
+		createMetadataVersion := func(version int64, metadataContent []byte) error {
+			if version == 2 && committedVersions[2] == nil {
+				committedVersions[2] = []byte("v2") // Committed by another writer in the meantime
+			}
+			if committedVersions[version] != nil {
+				return ERROR_ICEBERG_COMMIT_CONFLICT
+			}
+			committedVersions[version] = metadataContent
+			return nil
+		}
+
+		version, err := storageBase.CommitMetadata(readMetadata, appendCommit, createMetadataVersion)
+
+		testNoError(t, err)
+		if version != 3 {
+			t.Errorf("Expected version 3 to be committed, got %d", version)
+		}
+		if string(committedVersions[3]) != "v2+" {
+			t.Errorf("Expected the update to be applied to the metadata of version 2, got %s", committedVersions[3])
+		}
+	})
+
+	t.Run("Returns the conflict after the last retry", func(t *testing.T) {
+		attempts := 0
+		readMetadata := func() ([]byte, int64, error) {
+			return []byte("v1"), 1, nil
+		}
+		createMetadataVersion := func(version int64, metadataContent []byte) error {
+			attempts++
+			return ERROR_ICEBERG_COMMIT_CONFLICT
+		}
+
+		_, err := storageBase.CommitMetadata(readMetadata, appendCommit, createMetadataVersion)
+
+		if err != ERROR_ICEBERG_COMMIT_CONFLICT {
+			t.Errorf("Expected a commit conflict, got %v", err)
+		}
+		if attempts != ICEBERG_COMMIT_RETRIES+1 {
+			t.Errorf("Expected %d attempts, got %d", ICEBERG_COMMIT_RETRIES+1, attempts)
+		}
+	})
+}
+
+func TestUnpartitionedSpecId(t *testing.T) {
+	t.Run("Returns the spec without partition fields", func(t *testing.T) {
+		specId := unpartitionedSpecId([]IcebergPartitionSpec{
+			{SpecId: 0, Fields: []IcebergPartitionField{{SourceId: 1, FieldId: 1000, Name: "id", Transform: "identity"}}},
+			{SpecId: 1, Fields: []IcebergPartitionField{}},
+		})
+
+		if specId != 1 {
+			t.Errorf("Expected the unpartitioned spec 1, got %v", specId)
+		}
+	})
+
+	t.Run("Returns the next spec id if the table has always been partitioned", func(t *testing.T) {
+		specId := unpartitionedSpecId([]IcebergPartitionSpec{
+			{SpecId: 0, Fields: []IcebergPartitionField{{SourceId: 1, FieldId: 1000, Name: "id", Transform: "identity"}}},
+			{SpecId: 1, Fields: []IcebergPartitionField{{SourceId: 1, FieldId: 1001, Name: "id_bucket", Transform: "bucket[16]"}}},
+		})
+
+		if specId != 2 {
+			t.Errorf("Expected a new spec 2, got %v", specId)
+		}
+	})
+}
+
+func TestIcebergPartitionSpecId(t *testing.T) {
+	icebergPartitionSpecs := []IcebergPartitionSpec{
+		{SpecId: 0, Fields: []IcebergPartitionField{}},
+		{SpecId: 1, Fields: []IcebergPartitionField{{SourceId: 1, FieldId: 1000, Name: "id", Transform: "identity"}}},
+	}
+
+	t.Run("Returns the spec with the same partition fields", func(t *testing.T) {
+		specId := icebergPartitionSpecId(icebergPartitionSpecs, []IcebergPartitionField{{SourceId: 1, FieldId: 1000, Name: "id", Transform: "identity", ResultType: "int"}})
+
+		if specId != 1 {
+			t.Errorf("Expected the identity spec 1, got %v", specId)
+		}
+	})
+
+	t.Run("Returns the next spec id for new partition fields", func(t *testing.T) {
+		specId := icebergPartitionSpecId(icebergPartitionSpecs, []IcebergPartitionField{{SourceId: 2, FieldId: 1000, Name: "created_at_day", Transform: "day", ResultType: "int"}})
+
+		if specId != 2 {
+			t.Errorf("Expected a new spec 2, got %v", specId)
+		}
+	})
+}
@@ -0,0 +1,883 @@
+package bemidb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	gcsStorage "cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	parquetGcs "github.com/xitongsys/parquet-go-source/gcs"
+	"github.com/xitongsys/parquet-go/source"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+type StorageGcs struct {
+	gcsClient   *gcsStorage.Client
+	config      *Config
+	storageBase *StorageBase
+}
+
+// Authenticates with application-default credentials, e.g. GOOGLE_APPLICATION_CREDENTIALS or the attached service account
+func NewGcsStorage(config *Config) *StorageGcs {
+	gcsClient, err := gcsStorage.NewClient(context.Background())
+	PanicIfError(err)
+
+	return &StorageGcs{
+		gcsClient:   gcsClient,
+		config:      config,
+		storageBase: &StorageBase{config: config},
+	}
+}
+
+// Read ----------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageGcs) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	version, err := storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		version = 1 // Let the reader fail on the missing table
+	}
+	return storage.metadataFilePath(icebergSchemaTable, version)
+}
+
+func (storage *StorageGcs) IcebergSchemas() (icebergSchemas []string, err error) {
+	schemasPrefix := storage.config.StoragePath + "/"
+	icebergSchemas, err = storage.nestedDirectoryPrefixes(schemasPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, schema := range icebergSchemas {
+		schemaParts := strings.Split(schema, "/")
+		icebergSchemas[i] = schemaParts[len(schemaParts)-2]
+	}
+
+	return icebergSchemas, nil
+}
+
+func (storage *StorageGcs) IcebergSchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	icebergSchemas, err := storage.nestedDirectoryPrefixes(storage.config.StoragePath + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schemaPrefix := range icebergSchemas {
+		schemaParts := strings.Split(schemaPrefix, "/")
+		icebergSchema := schemaParts[len(schemaParts)-2]
+
+		tables, err := storage.nestedDirectoryPrefixes(schemaPrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tablePrefix := range tables {
+			tableParts := strings.Split(tablePrefix, "/")
+			table := tableParts[len(tableParts)-2]
+
+			icebergSchemaTables = append(icebergSchemaTables, IcebergSchemaTable{Schema: icebergSchema, Table: table})
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+func (storage *StorageGcs) IcebergTableStats(icebergSchemaTable IcebergSchemaTable) (icebergTableStats IcebergTableStats, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return IcebergTableStats{}, err
+	}
+
+	return storage.storageBase.ParseIcebergTableStats(metadataContent)
+}
+
+func (storage *StorageGcs) IcebergRefs(icebergSchemaTable IcebergSchemaTable) (icebergRefs map[string]IcebergRef, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergRefs(metadataContent)
+}
+
+func (storage *StorageGcs) IcebergSnapshotLog(icebergSchemaTable IcebergSchemaTable) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshotLog(metadataContent)
+}
+
+func (storage *StorageGcs) IcebergSnapshots(icebergSchemaTable IcebergSchemaTable) (icebergSnapshots []IcebergSnapshot, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshots(metadataContent)
+}
+
+func (storage *StorageGcs) IcebergDataFiles(icebergSchemaTable IcebergSchemaTable) (icebergDataFiles []IcebergDataFile, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergDataFiles(metadataContent, func(path string) (io.ReadCloser, error) {
+		return storage.bucket().Object(strings.TrimPrefix(path, storage.fullBucketPath())).NewReader(context.Background())
+	})
+}
+
+// DuckDB reads GCS only with HMAC keys, while the storage authenticates with application-default credentials
+func (storage *StorageGcs) DirectReadsSupported() bool {
+	return storage.config.Gcs.HmacKeyId != ""
+}
+
+func (storage *StorageGcs) DownloadIcebergSnapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, localDirPath string) (localMetadataFilePath string, err error) {
+	metadataContent, version, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return "", err
+	}
+
+	return storage.storageBase.DownloadIcebergSnapshot(IcebergMetadataFileName(version), metadataContent, snapshotId, localDirPath, func(path string) (io.ReadCloser, error) {
+		return storage.bucket().Object(strings.TrimPrefix(path, storage.fullBucketPath())).NewReader(context.Background())
+	})
+}
+
+func (storage *StorageGcs) IcebergPrimaryKey(icebergSchemaTable IcebergSchemaTable) (columnNames []string, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPrimaryKey(metadataContent)
+}
+
+func (storage *StorageGcs) IcebergSchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSchemaFields(metadataContent, snapshotId)
+}
+
+func (storage *StorageGcs) IcebergPartitionSpecs(icebergSchemaTable IcebergSchemaTable) (icebergPartitionSpecs []IcebergPartitionSpec, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+// Glob of the audit record objects for DuckDB, empty if there are none yet
+func (storage *StorageGcs) AuditRecordsPath() (path string, err error) {
+	objectIterator := storage.bucket().Objects(context.Background(), &gcsStorage.Query{Prefix: storage.config.AuditLogPath + "/"})
+	_, err = objectIterator.Next()
+	if err == iterator.Done {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to list audit records: %v", err)
+	}
+
+	return storage.fullBucketPath() + storage.config.AuditLogPath + "/*.json", nil
+}
+
+// Write ---------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageGcs) DeleteSchema(schema string) (err error) {
+	return storage.deleteNestedObjects(storage.config.StoragePath + "/" + schema + "/")
+}
+
+func (storage *StorageGcs) DeleteSchemaTable(schemaTable IcebergSchemaTable) (err error) {
+	return storage.deleteNestedObjects(storage.tablePrefix(schemaTable))
+}
+
+func (storage *StorageGcs) CreateDataDir(schemaTable IcebergSchemaTable) (dataDirPath string) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return tablePrefix + "data"
+}
+
+func (storage *StorageGcs) CreateMetadataDir(schemaTable IcebergSchemaTable) (metadataDirPath string) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return tablePrefix + "metadata"
+}
+
+func (storage *StorageGcs) CreateParquet(icebergSchemaTable IcebergSchemaTable, dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) (parquetFile ParquetFile, err error) {
+	ctx := context.Background()
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := dataDirPath + "/" + fileName
+
+	fileWriter, err := parquetGcs.NewGcsFileWriterWithClient(ctx, storage.gcsClient, "", storage.config.Gcs.Bucket, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, nanValueCounts, skippedBatches, err := storage.storageBase.WriteParquetFile(icebergSchemaTable, fileWriter, pgSchemaColumns, loadRows)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	objectAttrs, err := storage.bucket().Object(fileKey).Attrs(ctx)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+	fileSize := objectAttrs.Size
+
+	fileReader, err := parquetGcs.NewGcsFileReaderWithClient(ctx, storage.gcsClient, "", storage.config.Gcs.Bucket, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = nanValueCounts
+
+	return ParquetFile{
+		Uuid:           uuid,
+		Path:           fileKey,
+		Size:           fileSize,
+		RecordCount:    recordCount,
+		SkippedBatches: skippedBatches,
+		Stats:          parquetStats,
+	}, nil
+}
+
+func (storage *StorageGcs) CreateManifest(metadataDirPath string, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile) (manifestFile ManifestFile, err error) {
+	fileName := fmt.Sprintf("%s-m0.avro", parquetFiles[0].Uuid)
+	filePath := metadataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	manifestFile, err = storage.storageBase.WriteManifestFile(storage.fullBucketPath(), tempFile.Name(), icebergPartitionSpec, parquetFiles)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	LogDebug(storage.config, "Manifest file created at:", filePath)
+
+	manifestFile.Path = filePath
+	return manifestFile, nil
+}
+
+func (storage *StorageGcs) CreateManifestList(metadataDirPath string, parquetFiles []ParquetFile, manifestFile ManifestFile) (manifestListFile ManifestListFile, err error) {
+	fileName := fmt.Sprintf("snap-%d-0-%s.avro", manifestFile.SnapshotId, parquetFiles[0].Uuid)
+	filePath := metadataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteManifestListFile(storage.fullBucketPath(), tempFile.Name(), parquetFiles, manifestFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", filePath)
+
+	return ManifestListFile{Path: filePath}, nil
+}
+
+func (storage *StorageGcs) CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CreateOrCommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		func() ([]byte, error) {
+			return storage.storageBase.NewIcebergMetadata(storage.fullBucketPath(), metadataDirPath+"/"+IcebergMetadataFileName(1), pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(metadataContent []byte) ([]byte, error) {
+			return storage.storageBase.AddIcebergSnapshot(storage.fullBucketPath(), metadataContent, pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	filePath := metadataDirPath + "/" + IcebergMetadataFileName(version)
+	LogDebug(storage.config, "Metadata file created at:", filePath)
+
+	return MetadataFile{Version: version, Path: filePath}, nil
+}
+
+func (storage *StorageGcs) CreateVersionHint(metadataDirPath string, metadataFile MetadataFile) (err error) {
+	filePath := metadataDirPath + "/" + VERSION_HINT_FILE_NAME
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteVersionHintFile(tempFile.Name(), metadataFile)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Version hint file created at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageGcs) CreateBranchMetadata(metadataDirPath string, branch string, pgSchemaColumns []PgSchemaColumn, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	metadataFile, err = storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergBranchSnapshot(storage.fullBucketPath(), metadataContent, branch, pgSchemaColumns, parquetFiles, manifestFile, manifestListFile)
+	})
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with branch", branch, "at:", metadataFile.Path)
+
+	return metadataFile, nil
+}
+
+func (storage *StorageGcs) UpdateIcebergRef(icebergSchemaTable IcebergSchemaTable, refName string, icebergRef IcebergRef) (err error) {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.SetIcebergRef(metadataContent, refName, icebergRef)
+	})
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Metadata file created with", icebergRef.Type, refName, "at:", metadataFile.Path)
+
+	return storage.CreateVersionHint(metadataDirPath, metadataFile)
+}
+
+func (storage *StorageGcs) CreateStatistics(metadataDirPath string, manifestFile ManifestFile, columnSketches *IcebergColumnSketches) (statisticsFile StatisticsFile, err error) {
+	fileName := fmt.Sprintf("%d-%s.stats", manifestFile.SnapshotId, uuid.New().String())
+	filePath := metadataDirPath + "/" + fileName
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	tempFile, err := CreateTemporaryFile("statistics")
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	statisticsFile, err = storage.storageBase.WriteStatisticsFile("", tempFile.Name(), metadataContent, manifestFile.SnapshotId, columnSketches)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	statisticsFile.Path = storage.fullBucketPath() + filePath
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Statistics file created at:", filePath)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergStatistics(metadataContent, statisticsFile)
+	})
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with statistics at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	return statisticsFile, nil
+}
+
+func (storage *StorageGcs) TransitionSupersededDataFiles(icebergSchemaTable IcebergSchemaTable, storageClass string) (err error) {
+	LogDebug(storage.config, "Storage class transitions are only supported on AWS S3, skipping transition of", icebergSchemaTable.String())
+	return nil
+}
+
+// Deletes objects under the table prefix that aren't referenced by any snapshot, e.g. left by failed syncs.
+// Recently modified objects are kept, since they may belong to a commit that hasn't updated the metadata file yet
+func (storage *StorageGcs) DeleteOrphanedFiles(icebergSchemaTable IcebergSchemaTable, modifiedBefore time.Time) (deletedFilePaths []string, err error) {
+	ctx := context.Background()
+	tablePrefix := storage.tablePrefix(icebergSchemaTable, true)
+	metadataContent, version, err := storage.readCurrentMetadata(tablePrefix + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	referencedFilePaths, err := storage.storageBase.ReferencedFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+		return storage.bucket().Object(strings.TrimPrefix(path, storage.fullBucketPath())).NewReader(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	referencedFilePaths.Add(storage.metadataFilePath(icebergSchemaTable, version))
+	referencedFilePaths.Add(storage.fullBucketPath() + tablePrefix + "metadata/" + VERSION_HINT_FILE_NAME)
+
+	objectIterator := storage.bucket().Objects(ctx, &gcsStorage.Query{Prefix: tablePrefix})
+	for {
+		objectAttrs, err := objectIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list objects: %v", err)
+		}
+		if referencedFilePaths.Contains(storage.fullBucketPath()+objectAttrs.Name) || !objectAttrs.Updated.Before(modifiedBefore) {
+			continue
+		}
+
+		LogDebug(storage.config, "Orphaned object to delete:", objectAttrs.Name)
+		err = storage.bucket().Object(objectAttrs.Name).Delete(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to delete orphaned object %s: %v", objectAttrs.Name, err)
+		}
+		deletedFilePaths = append(deletedFilePaths, objectAttrs.Name)
+	}
+
+	return deletedFilePaths, nil
+}
+
+// Expires snapshots older than olderThan except the last keepLast and the ones referenced by branches and tags, see StorageBase.ExpireIcebergSnapshots.
+// The expired snapshots are removed from a new metadata version first, then the files only they referenced are deleted
+func (storage *StorageGcs) ExpireSnapshots(icebergSchemaTable IcebergSchemaTable, keepLast int, olderThan time.Time, dryRun bool) (expiredFilePaths []string, err error) {
+	ctx := context.Background()
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	openFile := func(path string) (io.ReadCloser, error) {
+		return storage.bucket().Object(strings.TrimPrefix(path, storage.fullBucketPath())).NewReader(ctx)
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	updatedMetadataContent, expiredSnapshotIds, err := storage.storageBase.ExpireIcebergSnapshots(metadataContent, keepLast, olderThan)
+	if err != nil {
+		return nil, err
+	}
+	if len(expiredSnapshotIds) == 0 {
+		LogDebug(storage.config, "No snapshots to expire in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	if dryRun {
+		expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, expiredFilePath := range expiredFilePaths {
+			LogInfo(storage.config, "Dry run, expired file to delete:", expiredFilePath)
+		}
+		return expiredFilePaths, nil
+	}
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(currentMetadataContent []byte) ([]byte, error) {
+		metadataContent = currentMetadataContent
+		updatedMetadataContent, expiredSnapshotIds, err = storage.storageBase.ExpireIcebergSnapshots(currentMetadataContent, keepLast, olderThan)
+		return updatedMetadataContent, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created without", len(expiredSnapshotIds), "expired snapshot(s) at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expiredFilePath := range expiredFilePaths {
+		objectName := strings.TrimPrefix(expiredFilePath, storage.fullBucketPath())
+		err = storage.bucket().Object(objectName).Delete(ctx)
+		if err != nil && !errors.Is(err, gcsStorage.ErrObjectNotExist) {
+			return nil, fmt.Errorf("Failed to delete expired object %s: %v", objectName, err)
+		}
+		LogDebug(storage.config, "Expired object deleted:", objectName)
+	}
+
+	return expiredFilePaths, nil
+}
+
+// Merges the small data files of the current snapshot into files of up to the target size and commits them as a new snapshot.
+// The merged files are still referenced by the previous snapshots, they're deleted once those are expired
+func (storage *StorageGcs) CompactDataFiles(icebergSchemaTable IcebergSchemaTable, targetFileSize int64) (compactedFilePaths []string, err error) {
+	ctx := context.Background()
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	openFile := func(path string) (io.ReadCloser, error) {
+		return storage.bucket().Object(strings.TrimPrefix(path, storage.fullBucketPath())).NewReader(ctx)
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	compactedSnapshot, icebergCompactionGroups, err := storage.storageBase.PlanIcebergCompaction(metadataContent, targetFileSize, openFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(icebergCompactionGroups) == 0 {
+		LogDebug(storage.config, "No data files to compact in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	var parquetFiles []ParquetFile
+	for _, icebergCompactionGroup := range icebergCompactionGroups {
+		parquetFile, err := storage.compactParquet(icebergSchemaTable, icebergCompactionGroup)
+		if err != nil {
+			return nil, err
+		}
+		parquetFiles = append(parquetFiles, parquetFile)
+		compactedFilePaths = append(compactedFilePaths, icebergCompactionGroup.DataFilePaths...)
+	}
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return nil, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	snapshotId := time.Now().UnixNano()
+	compactedManifestFiles := map[string]ManifestFile{}
+	for i, icebergCompactionGroup := range icebergCompactionGroups {
+		if _, ok := compactedManifestFiles[icebergCompactionGroup.ManifestPath]; ok {
+			continue
+		}
+		filePath := metadataDirPath + "/" + fmt.Sprintf("%s-m0.avro", parquetFiles[i].Uuid)
+		manifestFile, err := storage.storageBase.WriteCompactedManifestFile(storage.fullBucketPath(), tempFile.Name(), snapshotId, icebergCompactionGroup.ManifestPath, icebergCompactionGroups, parquetFiles, openFile)
+		if err != nil {
+			return nil, err
+		}
+		err = storage.uploadFile(filePath, tempFile)
+		if err != nil {
+			return nil, err
+		}
+		LogDebug(storage.config, "Manifest file created at:", filePath)
+		manifestFile.Path = filePath
+		compactedManifestFiles[icebergCompactionGroup.ManifestPath] = manifestFile
+	}
+
+	manifestListFile := ManifestListFile{Path: metadataDirPath + "/" + fmt.Sprintf("snap-%d-0-%s.avro", snapshotId, parquetFiles[0].Uuid)}
+	err = storage.storageBase.WriteCompactedManifestListFile(storage.fullBucketPath(), tempFile.Name(), compactedSnapshot.ManifestList, compactedManifestFiles, icebergCompactionGroups, parquetFiles, openFile)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.uploadFile(manifestListFile.Path, tempFile)
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", manifestListFile.Path)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergCompactedSnapshot(storage.fullBucketPath(), metadataContent, compactedSnapshot.SnapshotId, snapshotId, icebergCompactionGroups, parquetFiles, manifestListFile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created with compacted snapshot at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return compactedFilePaths, nil
+}
+
+// Writes the merged file of the compaction group next to the files it merges, i.e. into the directory of their partition
+func (storage *StorageGcs) compactParquet(icebergSchemaTable IcebergSchemaTable, icebergCompactionGroup IcebergCompactionGroup) (parquetFile ParquetFile, err error) {
+	ctx := context.Background()
+	dataFileKeys := make([]string, len(icebergCompactionGroup.DataFilePaths))
+	for i, dataFilePath := range icebergCompactionGroup.DataFilePaths {
+		dataFileKeys[i] = strings.TrimPrefix(dataFilePath, storage.fullBucketPath())
+	}
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := path.Dir(dataFileKeys[0]) + "/" + fileName
+
+	fileWriter, err := parquetGcs.NewGcsFileWriterWithClient(ctx, storage.gcsClient, "", storage.config.Gcs.Bucket, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, err := storage.storageBase.CompactParquetFiles(icebergSchemaTable, fileWriter, dataFileKeys, func(dataFileKey string) (source.ParquetFile, error) {
+		return parquetGcs.NewGcsFileReaderWithClient(ctx, storage.gcsClient, "", storage.config.Gcs.Bucket, dataFileKey)
+	})
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) compacted from", len(dataFileKeys), "file(s) at:", fileKey)
+
+	objectAttrs, err := storage.bucket().Object(fileKey).Attrs(ctx)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+
+	fileReader, err := parquetGcs.NewGcsFileReaderWithClient(ctx, storage.gcsClient, "", storage.config.Gcs.Bucket, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = icebergCompactionGroup.NanValueCounts
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        objectAttrs.Size,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+		Partition:   icebergCompactionGroup.Partition,
+	}, nil
+}
+
+// Commits the updated current metadata as a new version, see StorageBase.CommitMetadata
+func (storage *StorageGcs) commitMetadata(metadataDirPath string, updateMetadata func(metadataContent []byte) ([]byte, error)) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		updateMetadata,
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+
+	return MetadataFile{Version: version, Path: metadataDirPath + "/" + IcebergMetadataFileName(version)}, nil
+}
+
+func (storage *StorageGcs) readCurrentMetadata(metadataDirPath string) (metadataContent []byte, version int64, err error) {
+	version, err = storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	metadataContent, err = storage.readMetadataFile(metadataDirPath + "/" + IcebergMetadataFileName(version))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return metadataContent, version, nil
+}
+
+// The version hint is written after a commit, so versions committed since are found by checking the next ones
+func (storage *StorageGcs) currentMetadataVersion(metadataDirPath string) (version int64, err error) {
+	ctx := context.Background()
+	objectReader, err := storage.bucket().Object(metadataDirPath + "/" + VERSION_HINT_FILE_NAME).NewReader(ctx)
+	if errors.Is(err, gcsStorage.ErrObjectNotExist) {
+		return 0, fmt.Errorf("%w: %v", ERROR_ICEBERG_TABLE_NOT_FOUND, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get version hint file: %v", err)
+	}
+	defer objectReader.Close()
+
+	versionHintContent, err := io.ReadAll(objectReader)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read version hint file: %v", err)
+	}
+
+	version, err = storage.storageBase.ParseVersionHint(versionHintContent)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		_, err = storage.bucket().Object(metadataDirPath + "/" + IcebergMetadataFileName(version+1)).Attrs(ctx)
+		if errors.Is(err, gcsStorage.ErrObjectNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Failed to check metadata file: %v", err)
+		}
+		version++
+	}
+}
+
+// Uploads the version with a precondition that the object doesn't exist, which GCS rejects with 412 otherwise
+func (storage *StorageGcs) createMetadataVersion(metadataDirPath string, version int64, metadataContent []byte) (err error) {
+	filePath := metadataDirPath + "/" + IcebergMetadataFileName(version)
+	objectWriter := storage.bucket().Object(filePath).If(gcsStorage.Conditions{DoesNotExist: true}).NewWriter(context.Background())
+	_, err = objectWriter.Write(metadataContent)
+	if err != nil {
+		objectWriter.Close()
+		return fmt.Errorf("Failed to upload metadata file: %v", err)
+	}
+
+	err = objectWriter.Close()
+	var apiError *googleapi.Error
+	if errors.As(err, &apiError) && apiError.Code == http.StatusPreconditionFailed {
+		return ERROR_ICEBERG_COMMIT_CONFLICT
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to upload metadata file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageGcs) readMetadataFile(fileKey string) (metadataContent []byte, err error) {
+	objectReader, err := storage.bucket().Object(fileKey).NewReader(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get metadata file: %v", err)
+	}
+	defer objectReader.Close()
+
+	metadataContent, err = io.ReadAll(objectReader)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read metadata file: %v", err)
+	}
+
+	return metadataContent, nil
+}
+
+func (storage *StorageGcs) CreateAuditRecord(auditRecord AuditRecord) (err error) {
+	fileKey := storage.config.AuditLogPath + "/" + auditRecord.FileName()
+
+	tempFile, err := CreateTemporaryFile("audit")
+	if err != nil {
+		return err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteAuditRecordFile(tempFile.Name(), auditRecord)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(fileKey, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Audit record created at:", fileKey)
+
+	return nil
+}
+
+// The GCS client uploads large files in chunks and retries failed chunks
+func (storage *StorageGcs) uploadFile(filePath string, file *os.File) (err error) {
+	objectWriter := storage.bucket().Object(filePath).NewWriter(context.Background())
+	_, err = io.Copy(objectWriter, file)
+	if err != nil {
+		objectWriter.Close()
+		return fmt.Errorf("Failed to upload file: %v", err)
+	}
+
+	err = objectWriter.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to upload file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageGcs) tablePrefix(schemaTable IcebergSchemaTable, isIcebergSchemaTable ...bool) string {
+	if len(isIcebergSchemaTable) > 0 && isIcebergSchemaTable[0] {
+		return storage.config.StoragePath + "/" + schemaTable.Schema + "/" + schemaTable.Table + "/"
+	}
+
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + schemaTable.Schema + "/" + schemaTable.Table + "/"
+}
+
+func (storage *StorageGcs) metadataFilePath(icebergSchemaTable IcebergSchemaTable, version int64) string {
+	return storage.fullBucketPath() + storage.tablePrefix(icebergSchemaTable, true) + "metadata/" + IcebergMetadataFileName(version)
+}
+
+func (storage *StorageGcs) fullBucketPath() string {
+	return "gs://" + storage.config.Gcs.Bucket + "/"
+}
+
+func (storage *StorageGcs) bucket() *gcsStorage.BucketHandle {
+	return storage.gcsClient.Bucket(storage.config.Gcs.Bucket)
+}
+
+func (storage *StorageGcs) nestedDirectoryPrefixes(prefix string) (dirs []string, err error) {
+	objectIterator := storage.bucket().Objects(context.Background(), &gcsStorage.Query{Prefix: prefix, Delimiter: "/"})
+	for {
+		objectAttrs, err := objectIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list objects: %v", err)
+		}
+
+		// Directories are returned as synthetic objects with only a prefix
+		if objectAttrs.Prefix != "" {
+			dirs = append(dirs, objectAttrs.Prefix)
+		}
+	}
+
+	return dirs, nil
+}
+
+func (storage *StorageGcs) deleteNestedObjects(prefix string) (err error) {
+	ctx := context.Background()
+	deletedCount := 0
+
+	objectIterator := storage.bucket().Objects(ctx, &gcsStorage.Query{Prefix: prefix})
+	for {
+		objectAttrs, err := objectIterator.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to list objects: %v", err)
+		}
+
+		LogDebug(storage.config, "Object to delete:", objectAttrs.Name)
+		err = storage.bucket().Object(objectAttrs.Name).Delete(ctx)
+		if err != nil && !errors.Is(err, gcsStorage.ErrObjectNotExist) {
+			return fmt.Errorf("Failed to delete objects: %v", err)
+		}
+		deletedCount++
+	}
+
+	if deletedCount > 0 {
+		LogDebug(storage.config, "Deleted", deletedCount, "object(s).")
+	} else {
+		LogDebug(storage.config, "No objects to delete.")
+	}
+
+	return nil
+}
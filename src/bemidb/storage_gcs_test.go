@@ -0,0 +1,29 @@
+package bemidb
+
+import (
+	"testing"
+)
+
+func TestStorageGcsPaths(t *testing.T) {
+	config := &Config{StoragePath: "iceberg", Gcs: GcsConfig{Bucket: "my-bucket"}, Pg: PgConfig{SchemaPrefix: "mydb_"}}
+	storage := &StorageGcs{config: config}
+
+	t.Run("Returns the metadata file path readable by DuckDB", func(t *testing.T) {
+		metadataFilePath := storage.metadataFilePath(IcebergSchemaTable{Schema: "mydb_public", Table: "users"}, 1)
+
+		if metadataFilePath != "gs://my-bucket/iceberg/mydb_public/users/metadata/v1.metadata.json" {
+			t.Errorf("Expected the metadata file path in the GCS bucket, got %s", metadataFilePath)
+		}
+	})
+
+	t.Run("Writes synced tables under the schema prefix", func(t *testing.T) {
+		schemaTable := IcebergSchemaTable{Schema: "public", Table: "users"}
+
+		if storage.CreateDataDir(schemaTable) != "iceberg/mydb_public/users/data" {
+			t.Errorf("Expected the data dir under the schema prefix, got %s", storage.CreateDataDir(schemaTable))
+		}
+		if storage.CreateMetadataDir(schemaTable) != "iceberg/mydb_public/users/metadata" {
+			t.Errorf("Expected the metadata dir under the schema prefix, got %s", storage.CreateMetadataDir(schemaTable))
+		}
+	})
+}
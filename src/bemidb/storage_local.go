@@ -0,0 +1,784 @@
+package bemidb
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+type StorageLocal struct {
+	config      *Config
+	storageBase *StorageBase
+}
+
+func NewLocalStorage(config *Config) *StorageLocal {
+	return &StorageLocal{config: config, storageBase: &StorageBase{config: config}}
+}
+
+// Read ----------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageLocal) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+	metadataDirPath := storage.tablePath(icebergSchemaTable, true) + "/metadata"
+	version, err := storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		version = 1 // Let the reader fail on the missing table
+	}
+	return metadataDirPath + "/" + IcebergMetadataFileName(version)
+}
+
+func (storage *StorageLocal) IcebergSchemas() (icebergSchemas []string, err error) {
+	schemasPath := storage.absoluteIcebergPath()
+	icebergSchemas, err = storage.nestedDirectories(schemasPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return icebergSchemas, nil
+}
+
+func (storage *StorageLocal) IcebergSchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	schemasPath := storage.absoluteIcebergPath()
+	icebergSchemas, err := storage.IcebergSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, icebergSchema := range icebergSchemas {
+		tablesPath := filepath.Join(schemasPath, icebergSchema)
+		tables, err := storage.nestedDirectories(tablesPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, table := range tables {
+			icebergSchemaTables = append(icebergSchemaTables, IcebergSchemaTable{Schema: icebergSchema, Table: table})
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+func (storage *StorageLocal) IcebergTableStats(icebergSchemaTable IcebergSchemaTable) (icebergTableStats IcebergTableStats, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return IcebergTableStats{}, err
+	}
+
+	return storage.storageBase.ParseIcebergTableStats(metadataContent)
+}
+
+func (storage *StorageLocal) IcebergRefs(icebergSchemaTable IcebergSchemaTable) (icebergRefs map[string]IcebergRef, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePath(icebergSchemaTable, true) + "/metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergRefs(metadataContent)
+}
+
+func (storage *StorageLocal) IcebergSnapshotLog(icebergSchemaTable IcebergSchemaTable) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshotLog(metadataContent)
+}
+
+func (storage *StorageLocal) IcebergSnapshots(icebergSchemaTable IcebergSchemaTable) (icebergSnapshots []IcebergSnapshot, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshots(metadataContent)
+}
+
+func (storage *StorageLocal) IcebergDataFiles(icebergSchemaTable IcebergSchemaTable) (icebergDataFiles []IcebergDataFile, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergDataFiles(metadataContent, func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+func (storage *StorageLocal) DirectReadsSupported() bool {
+	return true
+}
+
+func (storage *StorageLocal) DownloadIcebergSnapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, localDirPath string) (localMetadataFilePath string, err error) {
+	metadataFilePath := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	metadataContent, err := storage.readMetadataFile(metadataFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	return storage.storageBase.DownloadIcebergSnapshot(filepath.Base(metadataFilePath), metadataContent, snapshotId, localDirPath, func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+func (storage *StorageLocal) IcebergPrimaryKey(icebergSchemaTable IcebergSchemaTable) (columnNames []string, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPrimaryKey(metadataContent)
+}
+
+func (storage *StorageLocal) IcebergSchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSchemaFields(metadataContent, snapshotId)
+}
+
+func (storage *StorageLocal) IcebergPartitionSpecs(icebergSchemaTable IcebergSchemaTable) (icebergPartitionSpecs []IcebergPartitionSpec, err error) {
+	metadataContent, err := storage.readMetadataFile(storage.IcebergMetadataFilePath(icebergSchemaTable))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+// Glob of the audit record files for DuckDB, empty if there are none yet
+func (storage *StorageLocal) AuditRecordsPath() (path string, err error) {
+	auditLogPath := storage.absoluteAuditLogPath()
+	filePaths, err := filepath.Glob(filepath.Join(auditLogPath, "*.json"))
+	if err != nil || len(filePaths) == 0 {
+		return "", err
+	}
+
+	return filepath.Join(auditLogPath, "*.json"), nil
+}
+
+func (storage *StorageLocal) absoluteIcebergPath(relativePaths ...string) string {
+	execPath, err := os.Getwd()
+	PanicIfError(err)
+
+	return filepath.Join(execPath, storage.config.StoragePath, filepath.Join(relativePaths...))
+}
+
+// Write ---------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageLocal) DeleteSchema(schema string) error {
+	schemaPath := storage.absoluteIcebergPath(schema)
+
+	_, err := os.Stat(schemaPath)
+	if !os.IsNotExist(err) {
+		err := os.RemoveAll(schemaPath)
+		return err
+	}
+
+	return nil
+}
+
+func (storage *StorageLocal) DeleteSchemaTable(schemaTable IcebergSchemaTable) error {
+	tablePath := storage.tablePath(schemaTable)
+
+	_, err := os.Stat(tablePath)
+	if !os.IsNotExist(err) {
+		err := os.RemoveAll(tablePath)
+		return err
+	}
+
+	return nil
+}
+
+func (storage *StorageLocal) CreateDataDir(schemaTable IcebergSchemaTable) string {
+	tablePath := storage.tablePath(schemaTable)
+	dataPath := filepath.Join(tablePath, "data")
+	err := os.MkdirAll(dataPath, os.ModePerm)
+	PanicIfError(err)
+	return dataPath
+}
+
+func (storage *StorageLocal) CreateMetadataDir(schemaTable IcebergSchemaTable) string {
+	tablePath := storage.tablePath(schemaTable)
+	metadataPath := filepath.Join(tablePath, "metadata")
+	err := os.MkdirAll(metadataPath, os.ModePerm)
+	PanicIfError(err)
+	return metadataPath
+}
+
+func (storage *StorageLocal) CreateParquet(icebergSchemaTable IcebergSchemaTable, dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) (parquetFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	filePath := filepath.Join(dataDirPath, fileName)
+
+	// Partitioned tables are written into a subdirectory per partition
+	err = os.MkdirAll(dataDirPath, os.ModePerm)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to create data directory: %v", err)
+	}
+
+	fileWriter, err := local.NewLocalFileWriter(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, nanValueCounts, skippedBatches, err := storage.storageBase.WriteParquetFile(icebergSchemaTable, fileWriter, pgSchemaColumns, loadRows)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", filePath)
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+	fileSize := fileInfo.Size()
+
+	fileReader, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = nanValueCounts
+
+	return ParquetFile{
+		Uuid:           uuid,
+		Path:           filePath,
+		Size:           fileSize,
+		RecordCount:    recordCount,
+		SkippedBatches: skippedBatches,
+		Stats:          parquetStats,
+	}, nil
+}
+
+func (storage *StorageLocal) CreateManifest(metadataDirPath string, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile) (manifestFile ManifestFile, err error) {
+	fileName := fmt.Sprintf("%s-m0.avro", parquetFiles[0].Uuid)
+	filePath := filepath.Join(metadataDirPath, fileName)
+
+	manifestFile, err = storage.storageBase.WriteManifestFile(storage.fileSystemPrefix(), filePath, icebergPartitionSpec, parquetFiles)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	LogDebug(storage.config, "Manifest file created at:", filePath)
+
+	return manifestFile, nil
+}
+
+func (storage *StorageLocal) CreateManifestList(metadataDirPath string, parquetFiles []ParquetFile, manifestFile ManifestFile) (manifestListFile ManifestListFile, err error) {
+	fileName := fmt.Sprintf("snap-%d-0-%s.avro", manifestFile.SnapshotId, parquetFiles[0].Uuid)
+	filePath := filepath.Join(metadataDirPath, fileName)
+
+	err = storage.storageBase.WriteManifestListFile(storage.fileSystemPrefix(), filePath, parquetFiles, manifestFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", filePath)
+
+	return ManifestListFile{Path: filePath}, nil
+}
+
+// Creates the first version of a new table, or commits the synced data as a new snapshot of the existing table
+func (storage *StorageLocal) CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CreateOrCommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		func() ([]byte, error) {
+			return storage.storageBase.NewIcebergMetadata(storage.fileSystemPrefix(), filepath.Join(metadataDirPath, IcebergMetadataFileName(1)), pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(metadataContent []byte) ([]byte, error) {
+			return storage.storageBase.AddIcebergSnapshot(storage.fileSystemPrefix(), metadataContent, pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	filePath := filepath.Join(metadataDirPath, IcebergMetadataFileName(version))
+	LogDebug(storage.config, "Metadata file created at:", filePath)
+
+	return MetadataFile{Version: version, Path: filePath}, nil
+}
+
+// Renames a fully written temporary file into place, so that concurrent committers never read a partially written hint
+func (storage *StorageLocal) CreateVersionHint(metadataDirPath string, metadataFile MetadataFile) (err error) {
+	filePath := filepath.Join(metadataDirPath, VERSION_HINT_FILE_NAME)
+
+	tempFile, err := os.CreateTemp(metadataDirPath, VERSION_HINT_FILE_NAME+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary version hint file: %v", err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	err = storage.storageBase.WriteVersionHintFile(tempFile.Name(), metadataFile)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempFile.Name(), filePath)
+	if err != nil {
+		return fmt.Errorf("Failed to create version hint file: %v", err)
+	}
+	LogDebug(storage.config, "Version hint file created at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageLocal) CreateBranchMetadata(metadataDirPath string, branch string, pgSchemaColumns []PgSchemaColumn, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	metadataFile, err = storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergBranchSnapshot(storage.fileSystemPrefix(), metadataContent, branch, pgSchemaColumns, parquetFiles, manifestFile, manifestListFile)
+	})
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with branch", branch, "at:", metadataFile.Path)
+
+	return metadataFile, nil
+}
+
+func (storage *StorageLocal) UpdateIcebergRef(icebergSchemaTable IcebergSchemaTable, refName string, icebergRef IcebergRef) (err error) {
+	metadataDirPath := storage.tablePath(icebergSchemaTable, true) + "/metadata"
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.SetIcebergRef(metadataContent, refName, icebergRef)
+	})
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Metadata file created with", icebergRef.Type, refName, "at:", metadataFile.Path)
+
+	return storage.CreateVersionHint(metadataDirPath, metadataFile)
+}
+
+func (storage *StorageLocal) CreateStatistics(metadataDirPath string, manifestFile ManifestFile, columnSketches *IcebergColumnSketches) (statisticsFile StatisticsFile, err error) {
+	fileName := fmt.Sprintf("%d-%s.stats", manifestFile.SnapshotId, uuid.New().String())
+	filePath := filepath.Join(metadataDirPath, fileName)
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	statisticsFile, err = storage.storageBase.WriteStatisticsFile(storage.fileSystemPrefix(), filePath, metadataContent, manifestFile.SnapshotId, columnSketches)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Statistics file created at:", filePath)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergStatistics(metadataContent, statisticsFile)
+	})
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with statistics at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	return statisticsFile, nil
+}
+
+func (storage *StorageLocal) TransitionSupersededDataFiles(icebergSchemaTable IcebergSchemaTable, storageClass string) (err error) {
+	LogDebug(storage.config, "Local storage has no storage classes, skipping transition of", icebergSchemaTable.String())
+	return nil
+}
+
+// Deletes files under the table directory that aren't referenced by any snapshot, e.g. left by failed syncs.
+// Recently modified files are kept, since they may belong to a commit that hasn't updated the metadata file yet
+func (storage *StorageLocal) DeleteOrphanedFiles(icebergSchemaTable IcebergSchemaTable, modifiedBefore time.Time) (deletedFilePaths []string, err error) {
+	metadataFilePath := storage.IcebergMetadataFilePath(icebergSchemaTable)
+	metadataContent, err := storage.readMetadataFile(metadataFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	referencedFilePaths, err := storage.storageBase.ReferencedFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	referencedFilePaths.Add(metadataFilePath)
+	referencedFilePaths.Add(filepath.Join(filepath.Dir(metadataFilePath), VERSION_HINT_FILE_NAME))
+
+	err = filepath.WalkDir(storage.tablePath(icebergSchemaTable, true), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || referencedFilePaths.Contains(storage.fileSystemPrefix()+path) {
+			return err
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !fileInfo.ModTime().Before(modifiedBefore) {
+			return nil
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("Failed to delete orphaned file %s: %v", path, err)
+		}
+		LogDebug(storage.config, "Orphaned file deleted:", path)
+		deletedFilePaths = append(deletedFilePaths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deletedFilePaths, nil
+}
+
+func (storage *StorageLocal) CreateAuditRecord(auditRecord AuditRecord) (err error) {
+	auditLogPath := storage.absoluteAuditLogPath()
+	err = os.MkdirAll(auditLogPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("Failed to create audit log directory: %v", err)
+	}
+
+	filePath := filepath.Join(auditLogPath, auditRecord.FileName())
+	err = storage.storageBase.WriteAuditRecordFile(filePath, auditRecord)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Audit record created at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageLocal) absoluteAuditLogPath() string {
+	if filepath.IsAbs(storage.config.AuditLogPath) {
+		return storage.config.AuditLogPath
+	}
+
+	execPath, err := os.Getwd()
+	PanicIfError(err)
+
+	return filepath.Join(execPath, storage.config.AuditLogPath)
+}
+
+func (storage *StorageLocal) readMetadataFile(filePath string) (metadataContent []byte, err error) {
+	metadataContent, err = os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read metadata file: %v", err)
+	}
+
+	return metadataContent, nil
+}
+
+// Expires snapshots older than olderThan except the last keepLast and the ones referenced by branches and tags, see StorageBase.ExpireIcebergSnapshots.
+// The expired snapshots are removed from a new metadata version first, then the files only they referenced are deleted
+func (storage *StorageLocal) ExpireSnapshots(icebergSchemaTable IcebergSchemaTable, keepLast int, olderThan time.Time, dryRun bool) (expiredFilePaths []string, err error) {
+	metadataDirPath := storage.tablePath(icebergSchemaTable, true) + "/metadata"
+	openFile := func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	updatedMetadataContent, expiredSnapshotIds, err := storage.storageBase.ExpireIcebergSnapshots(metadataContent, keepLast, olderThan)
+	if err != nil {
+		return nil, err
+	}
+	if len(expiredSnapshotIds) == 0 {
+		LogDebug(storage.config, "No snapshots to expire in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	if dryRun {
+		expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, expiredFilePath := range expiredFilePaths {
+			LogInfo(storage.config, "Dry run, expired file to delete:", expiredFilePath)
+		}
+		return expiredFilePaths, nil
+	}
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(currentMetadataContent []byte) ([]byte, error) {
+		metadataContent = currentMetadataContent
+		updatedMetadataContent, expiredSnapshotIds, err = storage.storageBase.ExpireIcebergSnapshots(currentMetadataContent, keepLast, olderThan)
+		return updatedMetadataContent, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created without", len(expiredSnapshotIds), "expired snapshot(s) at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expiredFilePath := range expiredFilePaths {
+		err = os.Remove(expiredFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("Failed to delete expired file %s: %v", expiredFilePath, err)
+		}
+		LogDebug(storage.config, "Expired file deleted:", expiredFilePath)
+	}
+
+	return expiredFilePaths, nil
+}
+
+// Merges the small data files of the current snapshot into files of up to the target size and commits them as a new snapshot.
+// The merged files are still referenced by the previous snapshots, they're deleted once those are expired
+func (storage *StorageLocal) CompactDataFiles(icebergSchemaTable IcebergSchemaTable, targetFileSize int64) (compactedFilePaths []string, err error) {
+	metadataDirPath := storage.tablePath(icebergSchemaTable, true) + "/metadata"
+	openFile := func(path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	compactedSnapshot, icebergCompactionGroups, err := storage.storageBase.PlanIcebergCompaction(metadataContent, targetFileSize, openFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(icebergCompactionGroups) == 0 {
+		LogDebug(storage.config, "No data files to compact in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	var parquetFiles []ParquetFile
+	for _, icebergCompactionGroup := range icebergCompactionGroups {
+		parquetFile, err := storage.compactParquet(icebergSchemaTable, icebergCompactionGroup)
+		if err != nil {
+			return nil, err
+		}
+		parquetFiles = append(parquetFiles, parquetFile)
+		compactedFilePaths = append(compactedFilePaths, icebergCompactionGroup.DataFilePaths...)
+	}
+
+	snapshotId := time.Now().UnixNano()
+	compactedManifestFiles := map[string]ManifestFile{}
+	for i, icebergCompactionGroup := range icebergCompactionGroups {
+		if _, ok := compactedManifestFiles[icebergCompactionGroup.ManifestPath]; ok {
+			continue
+		}
+		filePath := filepath.Join(metadataDirPath, fmt.Sprintf("%s-m0.avro", parquetFiles[i].Uuid))
+		manifestFile, err := storage.storageBase.WriteCompactedManifestFile(storage.fileSystemPrefix(), filePath, snapshotId, icebergCompactionGroup.ManifestPath, icebergCompactionGroups, parquetFiles, openFile)
+		if err != nil {
+			return nil, err
+		}
+		LogDebug(storage.config, "Manifest file created at:", filePath)
+		compactedManifestFiles[icebergCompactionGroup.ManifestPath] = manifestFile
+	}
+
+	manifestListFile := ManifestListFile{Path: filepath.Join(metadataDirPath, fmt.Sprintf("snap-%d-0-%s.avro", snapshotId, parquetFiles[0].Uuid))}
+	err = storage.storageBase.WriteCompactedManifestListFile(storage.fileSystemPrefix(), manifestListFile.Path, compactedSnapshot.ManifestList, compactedManifestFiles, icebergCompactionGroups, parquetFiles, openFile)
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", manifestListFile.Path)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergCompactedSnapshot(storage.fileSystemPrefix(), metadataContent, compactedSnapshot.SnapshotId, snapshotId, icebergCompactionGroups, parquetFiles, manifestListFile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created with compacted snapshot at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return compactedFilePaths, nil
+}
+
+// Writes the merged file of the compaction group next to the files it merges, i.e. into the directory of their partition
+func (storage *StorageLocal) compactParquet(icebergSchemaTable IcebergSchemaTable, icebergCompactionGroup IcebergCompactionGroup) (parquetFile ParquetFile, err error) {
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	filePath := filepath.Join(filepath.Dir(icebergCompactionGroup.DataFilePaths[0]), fileName)
+
+	fileWriter, err := local.NewLocalFileWriter(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, err := storage.storageBase.CompactParquetFiles(icebergSchemaTable, fileWriter, icebergCompactionGroup.DataFilePaths, func(path string) (source.ParquetFile, error) {
+		return local.NewLocalFileReader(path)
+	})
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) compacted from", len(icebergCompactionGroup.DataFilePaths), "file(s) at:", filePath)
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+
+	fileReader, err := local.NewLocalFileReader(filePath)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = icebergCompactionGroup.NanValueCounts
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        filePath,
+		Size:        fileInfo.Size(),
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+		Partition:   icebergCompactionGroup.Partition,
+	}, nil
+}
+
+// Commits the updated current metadata as a new version, see StorageBase.CommitMetadata
+func (storage *StorageLocal) commitMetadata(metadataDirPath string, updateMetadata func(metadataContent []byte) ([]byte, error)) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		updateMetadata,
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+
+	return MetadataFile{Version: version, Path: filepath.Join(metadataDirPath, IcebergMetadataFileName(version))}, nil
+}
+
+func (storage *StorageLocal) readCurrentMetadata(metadataDirPath string) (metadataContent []byte, version int64, err error) {
+	version, err = storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	metadataContent, err = storage.readMetadataFile(filepath.Join(metadataDirPath, IcebergMetadataFileName(version)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return metadataContent, version, nil
+}
+
+// The version hint is written after a commit, so versions committed since are found by checking the next ones.
+// Without a readable hint, e.g. one written by an older version in place, the metadata files are listed instead
+func (storage *StorageLocal) currentMetadataVersion(metadataDirPath string) (version int64, err error) {
+	versionHintContent, err := os.ReadFile(filepath.Join(metadataDirPath, VERSION_HINT_FILE_NAME))
+	if err == nil {
+		version, err = storage.storageBase.ParseVersionHint(versionHintContent)
+	}
+	if err != nil {
+		hintErr := err
+		entries, err := os.ReadDir(metadataDirPath)
+		if os.IsNotExist(err) {
+			return 0, fmt.Errorf("%w: %v", ERROR_ICEBERG_TABLE_NOT_FOUND, hintErr)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Failed to read version hint file: %v", hintErr)
+		}
+		var fileNames []string
+		for _, entry := range entries {
+			fileNames = append(fileNames, entry.Name())
+		}
+		version = storage.storageBase.LatestMetadataVersion(fileNames)
+		if version == 0 {
+			if os.IsNotExist(hintErr) {
+				return 0, fmt.Errorf("%w: %v", ERROR_ICEBERG_TABLE_NOT_FOUND, hintErr)
+			}
+			return 0, fmt.Errorf("%w: %v", ERROR_ICEBERG_COMMIT_CONFLICT, hintErr)
+		}
+	}
+
+	for {
+		_, err = os.Stat(filepath.Join(metadataDirPath, IcebergMetadataFileName(version+1)))
+		if os.IsNotExist(err) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Failed to check metadata file: %v", err)
+		}
+		version++
+	}
+}
+
+// Links a fully written temporary file, so that the version is created atomically and only if it doesn't exist yet
+func (storage *StorageLocal) createMetadataVersion(metadataDirPath string, version int64, metadataContent []byte) (err error) {
+	tempFile, err := os.CreateTemp(metadataDirPath, "metadata-*.tmp")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary metadata file: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(metadataContent)
+	tempFile.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to write metadata to file: %v", err)
+	}
+
+	err = os.Link(tempFile.Name(), filepath.Join(metadataDirPath, IcebergMetadataFileName(version)))
+	if os.IsExist(err) {
+		return ERROR_ICEBERG_COMMIT_CONFLICT
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to create metadata file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageLocal) tablePath(schemaTable IcebergSchemaTable, isIcebergSchemaTable ...bool) string {
+	if len(isIcebergSchemaTable) > 0 && isIcebergSchemaTable[0] {
+		return storage.absoluteIcebergPath(schemaTable.Schema, schemaTable.Table)
+	}
+	return storage.absoluteIcebergPath(storage.config.Pg.SchemaPrefix+schemaTable.Schema, schemaTable.Table)
+}
+
+func (storage *StorageLocal) fileSystemPrefix() string {
+	return ""
+}
+
+func (storage *StorageLocal) nestedDirectories(path string) (dirs []string, err error) {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read directory: %v", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			dirs = append(dirs, file.Name())
+		}
+	}
+
+	return dirs, nil
+}
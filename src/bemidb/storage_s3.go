@@ -0,0 +1,1496 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+	"github.com/xitongsys/parquet-go-source/s3v2"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/acl-overview.html#canned-acl
+var AWS_S3_ACLS = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"aws-exec-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-class-intro.html
+// Excludes GLACIER and DEEP_ARCHIVE since their objects must be restored before DuckDB can read them
+var AWS_S3_STORAGE_CLASSES = []string{
+	"STANDARD",
+	"REDUCED_REDUNDANCY",
+	"STANDARD_IA",
+	"ONEZONE_IA",
+	"INTELLIGENT_TIERING",
+	"GLACIER_IR",
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/serv-side-encryption.html
+// Excludes SSE-C since DuckDB can't read objects encrypted with customer-provided keys
+var AWS_S3_SSE_ALGORITHMS = []string{
+	string(types.ServerSideEncryptionAes256),
+	string(types.ServerSideEncryptionAwsKms),
+}
+
+// Maximum number of keys in a single DeleteObjects request
+const AWS_S3_MAX_DELETE_OBJECTS = 1000
+
+// Errors of single keys in a DeleteObjects response that are deleted again.
+// Errors of the whole request are retried by the AWS SDK retryer
+var AWS_S3_RETRYABLE_DELETE_ERROR_CODES = []string{"SlowDown", "InternalError", "ServiceUnavailable"}
+
+// Objects above the CopyObject limit are copied in parts with UploadPartCopy
+const AWS_S3_MAX_COPY_OBJECT_SIZE = 5 * 1024 * 1024 * 1024
+const AWS_S3_COPY_PART_SIZE = 1024 * 1024 * 1024
+
+// Assumed role credentials are refreshed this long before they expire, so that long-running queries and uploads don't fail
+const AWS_ASSUMED_ROLE_EXPIRY_WINDOW = 5 * time.Minute
+
+type StorageS3 struct {
+	s3Clients   map[string]*s3.Client // by region, for tables stored in buckets in other regions
+	config      *Config
+	storageBase *StorageBase
+	// Set when the bucket has ACLs disabled (Object Ownership enforced), see S3AclFallbackClient
+	aclNotSupported atomic.Bool
+	// Set during a sync run, see S3RequestCache
+	requestCache atomic.Pointer[S3RequestCache]
+}
+
+func NewS3Storage(config *Config) *StorageS3 {
+	awsCredentials := newAwsCredentialsProvider(config)
+	s3Clients := map[string]*s3.Client{config.Aws.Region: newS3Client(config, config.Aws.Region, awsCredentials)}
+	for _, awsS3Bucket := range config.Aws.S3TableBuckets {
+		if s3Clients[awsS3Bucket.Region] == nil {
+			s3Clients[awsS3Bucket.Region] = newS3Client(config, awsS3Bucket.Region, awsCredentials)
+		}
+	}
+
+	return &StorageS3{
+		s3Clients:   s3Clients,
+		config:      config,
+		storageBase: &StorageBase{config: config},
+	}
+}
+
+// Access keys, or temporary credentials of the role assumed with them for buckets in other AWS accounts.
+// The temporary credentials are cached and assumed again when they are about to expire
+func newAwsCredentialsProvider(config *Config) aws.CredentialsProvider {
+	awsCredentials := credentials.NewStaticCredentialsProvider(
+		config.Aws.AccessKeyId,
+		config.Aws.SecretAccessKey,
+		"",
+	)
+	if config.Aws.AssumeRoleArn == "" {
+		return awsCredentials
+	}
+
+	stsClient := sts.NewFromConfig(aws.Config{Region: config.Aws.Region, Credentials: awsCredentials})
+	assumeRoleProvider := stscreds.NewAssumeRoleProvider(stsClient, config.Aws.AssumeRoleArn, func(options *stscreds.AssumeRoleOptions) {
+		options.RoleSessionName = "bemidb"
+		if config.Aws.ExternalId != "" {
+			options.ExternalID = aws.String(config.Aws.ExternalId)
+		}
+	})
+
+	return aws.NewCredentialsCache(assumeRoleProvider, func(options *aws.CredentialsCacheOptions) {
+		options.ExpiryWindow = AWS_ASSUMED_ROLE_EXPIRY_WINDOW
+	})
+}
+
+func newS3Client(config *Config, region string, awsCredentials aws.CredentialsProvider) *s3.Client {
+	var logMode aws.ClientLogMode
+	// if config.LogLevel == LOG_LEVEL_DEBUG {
+	// 	logMode = aws.LogRequest | aws.LogResponse
+	// }
+
+	loadedAwsConfig, err := awsConfig.LoadDefaultConfig(
+		context.Background(),
+		awsConfig.WithRegion(region),
+		awsConfig.WithCredentialsProvider(awsCredentials),
+		awsConfig.WithClientLogMode(logMode),
+		awsConfig.WithRetryer(func() aws.Retryer { return newAwsRetryer(config) }),
+	)
+	PanicIfError(err)
+
+	return s3.NewFromConfig(loadedAwsConfig, func(options *s3.Options) {
+		// S3-compatible storage like MinIO or Cloudflare R2, the default AWS endpoint is resolved by region instead
+		if config.Aws.S3Endpoint != "" && config.Aws.S3Endpoint != DEFAULT_AWS_S3_ENDPOINT {
+			options.BaseEndpoint = aws.String(parseAwsS3Endpoint(config.Aws.S3Endpoint).String())
+		}
+		options.UsePathStyle = config.Aws.S3ForcePathStyle
+	})
+}
+
+// Retries throttled (SlowDown), 5xx and network errors with exponential backoff, and slows down all requests of the client while S3 is throttling.
+// Auth and not-found errors aren't retried. Retries aren't limited by the SDK's retry quota, so that long syncs keep retrying throttled requests
+func newAwsRetryer(config *Config) aws.Retryer {
+	return retry.NewAdaptiveMode(func(options *retry.AdaptiveModeOptions) {
+		options.StandardOptions = append(options.StandardOptions, func(standardOptions *retry.StandardOptions) {
+			standardOptions.MaxAttempts = config.Aws.MaxRetries + 1
+			standardOptions.RateLimiter = ratelimit.None
+		})
+	})
+}
+
+// Read ----------------------------------------------------------------------------------------------------------------
+
+// Serves repeated requests from memory until the cache is unset with nil
+func (storage *StorageS3) SetRequestCache(requestCache *S3RequestCache) {
+	storage.requestCache.Store(requestCache)
+}
+
+func (storage *StorageS3) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	version, err := storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		version = 1 // Let the reader fail on the missing table
+	}
+	return storage.metadataFilePath(icebergSchemaTable, version)
+}
+
+func (storage *StorageS3) IcebergSchemas() (icebergSchemas []string, err error) {
+	schemasPrefix := storage.config.StoragePath + "/"
+	icebergSchemas, err = storage.nestedDirectoryPrefixes(storage.defaultBucket(), schemasPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, schema := range icebergSchemas {
+		schemaParts := strings.Split(schema, "/")
+		icebergSchemas[i] = schemaParts[len(schemaParts)-2]
+	}
+
+	tableBucketSchemaTables, err := storage.tableBucketSchemaTables()
+	if err != nil {
+		return nil, err
+	}
+	for _, icebergSchemaTable := range tableBucketSchemaTables {
+		if !slices.Contains(icebergSchemas, icebergSchemaTable.Schema) {
+			icebergSchemas = append(icebergSchemas, icebergSchemaTable.Schema)
+		}
+	}
+
+	return icebergSchemas, nil
+}
+
+// Schema and table names are the prefixes with their case preserved, so mixed-case ones are queried with quoted identifiers
+func (storage *StorageS3) IcebergSchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	icebergSchemas, err := storage.nestedDirectoryPrefixes(storage.defaultBucket(), storage.config.StoragePath+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schemaPrefix := range icebergSchemas {
+		schemaParts := strings.Split(schemaPrefix, "/")
+		icebergSchema := schemaParts[len(schemaParts)-2]
+
+		tables, err := storage.nestedDirectoryPrefixes(storage.defaultBucket(), schemaPrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tablePrefix := range tables {
+			tableParts := strings.Split(tablePrefix, "/")
+			table := tableParts[len(tableParts)-2]
+
+			icebergSchemaTables = append(icebergSchemaTables, IcebergSchemaTable{Schema: icebergSchema, Table: table})
+		}
+	}
+
+	tableBucketSchemaTables, err := storage.tableBucketSchemaTables()
+	if err != nil {
+		return nil, err
+	}
+	for _, icebergSchemaTable := range tableBucketSchemaTables {
+		if !slices.Contains(icebergSchemaTables, icebergSchemaTable) {
+			icebergSchemaTables = append(icebergSchemaTables, icebergSchemaTable)
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+func (storage *StorageS3) IcebergTableStats(icebergSchemaTable IcebergSchemaTable) (icebergTableStats IcebergTableStats, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return IcebergTableStats{}, err
+	}
+
+	return storage.storageBase.ParseIcebergTableStats(metadataContent)
+}
+
+func (storage *StorageS3) IcebergRefs(icebergSchemaTable IcebergSchemaTable) (icebergRefs map[string]IcebergRef, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergRefs(metadataContent)
+}
+
+func (storage *StorageS3) IcebergSnapshotLog(icebergSchemaTable IcebergSchemaTable) (icebergSnapshotLog []IcebergSnapshotLogEntry, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshotLog(metadataContent)
+}
+
+func (storage *StorageS3) IcebergSnapshots(icebergSchemaTable IcebergSchemaTable) (icebergSnapshots []IcebergSnapshot, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSnapshots(metadataContent)
+}
+
+func (storage *StorageS3) IcebergDataFiles(icebergSchemaTable IcebergSchemaTable) (icebergDataFiles []IcebergDataFile, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	return storage.storageBase.ParseIcebergDataFiles(metadataContent, func(path string) (io.ReadCloser, error) {
+		getObjectResponse, err := storage.client(awsS3Bucket).GetObject(context.Background(), &s3.GetObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(strings.TrimPrefix(path, storage.fullBucketPath(awsS3Bucket))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return getObjectResponse.Body, nil
+	})
+}
+
+// DuckDB reads S3 with the secrets created for each bucket, also from custom endpoints it can reach
+func (storage *StorageS3) DirectReadsSupported() bool {
+	return true
+}
+
+func (storage *StorageS3) DownloadIcebergSnapshot(icebergSchemaTable IcebergSchemaTable, snapshotId int64, localDirPath string) (localMetadataFilePath string, err error) {
+	metadataContent, version, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return "", err
+	}
+
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	return storage.storageBase.DownloadIcebergSnapshot(IcebergMetadataFileName(version), metadataContent, snapshotId, localDirPath, func(path string) (io.ReadCloser, error) {
+		getObjectResponse, err := storage.client(awsS3Bucket).GetObject(context.Background(), &s3.GetObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(strings.TrimPrefix(path, storage.fullBucketPath(awsS3Bucket))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return getObjectResponse.Body, nil
+	})
+}
+
+func (storage *StorageS3) IcebergPrimaryKey(icebergSchemaTable IcebergSchemaTable) (columnNames []string, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPrimaryKey(metadataContent)
+}
+
+func (storage *StorageS3) IcebergSchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) (icebergSchemaFields []IcebergSchemaField, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergSchemaFields(metadataContent, snapshotId)
+}
+
+func (storage *StorageS3) IcebergPartitionSpecs(icebergSchemaTable IcebergSchemaTable) (icebergPartitionSpecs []IcebergPartitionSpec, err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.storageBase.ParseIcebergPartitionSpecs(metadataContent)
+}
+
+// Glob of the audit record objects for DuckDB, empty if there are none yet
+func (storage *StorageS3) AuditRecordsPath() (path string, err error) {
+	ctx := context.Background()
+	awsS3Bucket := storage.defaultBucket()
+	listResponse, err := storage.client(awsS3Bucket).ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(awsS3Bucket.Name),
+		Prefix:  aws.String(storage.config.AuditLogPath + "/"),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to list audit records: %v", err)
+	}
+	if len(listResponse.Contents) == 0 {
+		return "", nil
+	}
+
+	return storage.fullBucketPath(awsS3Bucket) + storage.config.AuditLogPath + "/*.json", nil
+}
+
+// Write ---------------------------------------------------------------------------------------------------------------
+
+func (storage *StorageS3) DeleteSchema(schema string) (err error) {
+	for schemaTable, awsS3Bucket := range storage.config.Aws.S3TableBuckets {
+		tableSchema, table, _ := strings.Cut(schemaTable, ".")
+		if tableSchema == schema {
+			err = storage.deleteNestedObjects(awsS3Bucket, storage.tablePrefix(IcebergSchemaTable{Schema: tableSchema, Table: table}, true))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return storage.deleteNestedObjects(storage.defaultBucket(), storage.config.StoragePath+"/"+schema+"/")
+}
+
+func (storage *StorageS3) DeleteSchemaTable(schemaTable IcebergSchemaTable) (err error) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return storage.deleteNestedObjects(storage.keyBucket(tablePrefix), tablePrefix)
+}
+
+func (storage *StorageS3) CreateDataDir(schemaTable IcebergSchemaTable) (dataDirPath string) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return tablePrefix + "data"
+}
+
+func (storage *StorageS3) CreateMetadataDir(schemaTable IcebergSchemaTable) (metadataDirPath string) {
+	tablePrefix := storage.tablePrefix(schemaTable)
+	return tablePrefix + "metadata"
+}
+
+func (storage *StorageS3) CreateParquet(icebergSchemaTable IcebergSchemaTable, dataDirPath string, pgSchemaColumns []PgSchemaColumn, loadRows func() [][]string) (parquetFile ParquetFile, err error) {
+	ctx := context.Background()
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := dataDirPath + "/" + fileName
+	awsS3Bucket := storage.keyBucket(fileKey)
+
+	defer storage.requestCache.Load().InvalidateKey(awsS3Bucket, fileKey)
+	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.uploadClient(awsS3Bucket), awsS3Bucket.Name, fileKey, storage.uploaderOptions(), storage.putObjectInputOptions()...)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, nanValueCounts, skippedBatches, err := storage.storageBase.WriteParquetFile(icebergSchemaTable, fileWriter, pgSchemaColumns, loadRows)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) created at:", fileKey)
+
+	headObjectResponse, err := storage.client(awsS3Bucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Key:    aws.String(fileKey),
+	})
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+	fileSize := *headObjectResponse.ContentLength
+
+	fileReader, err := s3v2.NewS3FileReaderWithClient(ctx, storage.client(awsS3Bucket), awsS3Bucket.Name, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = nanValueCounts
+
+	return ParquetFile{
+		Uuid:           uuid,
+		Path:           fileKey,
+		Size:           fileSize,
+		RecordCount:    recordCount,
+		SkippedBatches: skippedBatches,
+		Stats:          parquetStats,
+	}, nil
+}
+
+func (storage *StorageS3) CreateManifest(metadataDirPath string, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile) (manifestFile ManifestFile, err error) {
+	fileName := fmt.Sprintf("%s-m0.avro", parquetFiles[0].Uuid)
+	filePath := metadataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	manifestFile, err = storage.storageBase.WriteManifestFile(storage.fullBucketPath(storage.keyBucket(filePath)), tempFile.Name(), icebergPartitionSpec, parquetFiles)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	LogDebug(storage.config, "Manifest file created at:", filePath)
+
+	manifestFile.Path = filePath
+	return manifestFile, nil
+}
+
+func (storage *StorageS3) CreateManifestList(metadataDirPath string, parquetFiles []ParquetFile, manifestFile ManifestFile) (manifestListFile ManifestListFile, err error) {
+	fileName := fmt.Sprintf("snap-%d-0-%s.avro", manifestFile.SnapshotId, parquetFiles[0].Uuid)
+	filePath := metadataDirPath + "/" + fileName
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteManifestListFile(storage.fullBucketPath(storage.keyBucket(filePath)), tempFile.Name(), parquetFiles, manifestFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return ManifestListFile{}, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", filePath)
+
+	return ManifestListFile{Path: filePath}, nil
+}
+
+func (storage *StorageS3) CreateMetadata(metadataDirPath string, pgSchemaColumns []PgSchemaColumn, icebergPartitionSpec IcebergPartitionSpec, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CreateOrCommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		func() ([]byte, error) {
+			return storage.storageBase.NewIcebergMetadata(storage.fullBucketPath(storage.keyBucket(metadataDirPath)), metadataDirPath+"/"+IcebergMetadataFileName(1), pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(metadataContent []byte) ([]byte, error) {
+			return storage.storageBase.AddIcebergSnapshot(storage.fullBucketPath(storage.keyBucket(metadataDirPath)), metadataContent, pgSchemaColumns, icebergPartitionSpec, parquetFiles, manifestFile, manifestListFile)
+		},
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	filePath := metadataDirPath + "/" + IcebergMetadataFileName(version)
+	LogDebug(storage.config, "Metadata file created at:", filePath)
+
+	return MetadataFile{Version: version, Path: filePath}, nil
+}
+
+func (storage *StorageS3) CreateVersionHint(metadataDirPath string, metadataFile MetadataFile) (err error) {
+	filePath := metadataDirPath + "/" + VERSION_HINT_FILE_NAME
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteVersionHintFile(tempFile.Name(), metadataFile)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Version hint file created at:", filePath)
+
+	return nil
+}
+
+func (storage *StorageS3) CreateBranchMetadata(metadataDirPath string, branch string, pgSchemaColumns []PgSchemaColumn, parquetFiles []ParquetFile, manifestFile ManifestFile, manifestListFile ManifestListFile) (metadataFile MetadataFile, err error) {
+	metadataFile, err = storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergBranchSnapshot(storage.fullBucketPath(storage.keyBucket(metadataDirPath)), metadataContent, branch, pgSchemaColumns, parquetFiles, manifestFile, manifestListFile)
+	})
+	if err != nil {
+		return MetadataFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with branch", branch, "at:", metadataFile.Path)
+
+	return metadataFile, nil
+}
+
+func (storage *StorageS3) UpdateIcebergRef(icebergSchemaTable IcebergSchemaTable, refName string, icebergRef IcebergRef) (err error) {
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.SetIcebergRef(metadataContent, refName, icebergRef)
+	})
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Metadata file created with", icebergRef.Type, refName, "at:", metadataFile.Path)
+
+	return storage.CreateVersionHint(metadataDirPath, metadataFile)
+}
+
+func (storage *StorageS3) CreateStatistics(metadataDirPath string, manifestFile ManifestFile, columnSketches *IcebergColumnSketches) (statisticsFile StatisticsFile, err error) {
+	fileName := fmt.Sprintf("%d-%s.stats", manifestFile.SnapshotId, uuid.New().String())
+	filePath := metadataDirPath + "/" + fileName
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	tempFile, err := CreateTemporaryFile("statistics")
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	statisticsFile, err = storage.storageBase.WriteStatisticsFile("", tempFile.Name(), metadataContent, manifestFile.SnapshotId, columnSketches)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	statisticsFile.Path = storage.fullBucketPath(storage.keyBucket(filePath)) + filePath
+
+	err = storage.uploadFile(filePath, tempFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Statistics file created at:", filePath)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergStatistics(metadataContent, statisticsFile)
+	})
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+	LogDebug(storage.config, "Metadata file created with statistics at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return StatisticsFile{}, err
+	}
+
+	return statisticsFile, nil
+}
+
+// Moves data files that are only referenced by retained non-current snapshots, e.g. by tags, to a colder storage class
+func (storage *StorageS3) TransitionSupersededDataFiles(icebergSchemaTable IcebergSchemaTable, storageClass string) (err error) {
+	metadataContent, _, err := storage.readCurrentMetadata(storage.tablePrefix(icebergSchemaTable, true) + "metadata")
+	if err != nil {
+		return err
+	}
+
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	dataFilePaths, err := storage.storageBase.SupersededDataFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+		getObjectResponse, err := storage.client(awsS3Bucket).GetObject(context.Background(), &s3.GetObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(strings.TrimPrefix(path, storage.fullBucketPath(awsS3Bucket))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return getObjectResponse.Body, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dataFilePath := range dataFilePaths {
+		fileKey := strings.TrimPrefix(dataFilePath, storage.fullBucketPath(awsS3Bucket))
+		headObjectResponse, err := storage.client(awsS3Bucket).HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(fileKey),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to transition %s to %s: %v", fileKey, storageClass, err)
+		}
+
+		copyObjectInput := storage.transitionCopyObjectInput(fileKey, storageClass)
+		if aws.ToInt64(headObjectResponse.ContentLength) > AWS_S3_MAX_COPY_OBJECT_SIZE {
+			err = storage.multipartCopyObject(copyObjectInput, headObjectResponse, AWS_S3_COPY_PART_SIZE)
+		} else {
+			_, err = storage.client(awsS3Bucket).CopyObject(context.Background(), copyObjectInput)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to transition %s to %s: %v", fileKey, storageClass, err)
+		}
+		LogDebug(storage.config, "Data file transitioned to", storageClass+":", fileKey)
+	}
+
+	return nil
+}
+
+// Deletes objects under the table prefix that aren't referenced by any snapshot, e.g. left by failed syncs.
+// Recently modified objects are kept, since they may belong to a commit that hasn't updated the metadata file yet
+func (storage *StorageS3) DeleteOrphanedFiles(icebergSchemaTable IcebergSchemaTable, modifiedBefore time.Time) (deletedFilePaths []string, err error) {
+	ctx := context.Background()
+	tablePrefix := storage.tablePrefix(icebergSchemaTable, true)
+	metadataContent, version, err := storage.readCurrentMetadata(tablePrefix + "metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	referencedFilePaths, err := storage.storageBase.ReferencedFilePaths(metadataContent, func(path string) (io.ReadCloser, error) {
+		getObjectResponse, err := storage.client(awsS3Bucket).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(strings.TrimPrefix(path, storage.fullBucketPath(awsS3Bucket))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return getObjectResponse.Body, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	referencedFilePaths.Add(storage.metadataFilePath(icebergSchemaTable, version))
+	referencedFilePaths.Add(storage.fullBucketPath(awsS3Bucket) + tablePrefix + "metadata/" + VERSION_HINT_FILE_NAME)
+
+	paginator := s3.NewListObjectsV2Paginator(storage.client(awsS3Bucket), &s3.ListObjectsV2Input{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Prefix: aws.String(tablePrefix),
+	})
+	for paginator.HasMorePages() {
+		listResponse, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list objects: %v", err)
+		}
+
+		var objectsToDelete []types.ObjectIdentifier
+		for _, obj := range listResponse.Contents {
+			if referencedFilePaths.Contains(storage.fullBucketPath(awsS3Bucket)+*obj.Key) || obj.LastModified == nil || !obj.LastModified.Before(modifiedBefore) {
+				continue
+			}
+			LogDebug(storage.config, "Orphaned object to delete:", *obj.Key)
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: obj.Key})
+		}
+		if len(objectsToDelete) == 0 {
+			continue
+		}
+
+		err = storage.deleteObjects(ctx, awsS3Bucket, objectsToDelete)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to delete orphaned objects: %v", err)
+		}
+		for _, objectToDelete := range objectsToDelete {
+			deletedFilePaths = append(deletedFilePaths, *objectToDelete.Key)
+		}
+	}
+
+	return deletedFilePaths, nil
+}
+
+// Expires snapshots older than olderThan except the last keepLast and the ones referenced by branches and tags, see StorageBase.ExpireIcebergSnapshots.
+// The expired snapshots are removed from a new metadata version first, then the files only they referenced are deleted
+func (storage *StorageS3) ExpireSnapshots(icebergSchemaTable IcebergSchemaTable, keepLast int, olderThan time.Time, dryRun bool) (expiredFilePaths []string, err error) {
+	ctx := context.Background()
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	openFile := func(path string) (io.ReadCloser, error) {
+		getObjectResponse, err := storage.client(awsS3Bucket).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(strings.TrimPrefix(path, storage.fullBucketPath(awsS3Bucket))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return getObjectResponse.Body, nil
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	updatedMetadataContent, expiredSnapshotIds, err := storage.storageBase.ExpireIcebergSnapshots(metadataContent, keepLast, olderThan)
+	if err != nil {
+		return nil, err
+	}
+	if len(expiredSnapshotIds) == 0 {
+		LogDebug(storage.config, "No snapshots to expire in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	if dryRun {
+		expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, expiredFilePath := range expiredFilePaths {
+			LogInfo(storage.config, "Dry run, expired file to delete:", expiredFilePath)
+		}
+		return expiredFilePaths, nil
+	}
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(currentMetadataContent []byte) ([]byte, error) {
+		metadataContent = currentMetadataContent
+		updatedMetadataContent, expiredSnapshotIds, err = storage.storageBase.ExpireIcebergSnapshots(currentMetadataContent, keepLast, olderThan)
+		return updatedMetadataContent, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created without", len(expiredSnapshotIds), "expired snapshot(s) at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	expiredFilePaths, err = storage.storageBase.ExpiredFilePaths(metadataContent, updatedMetadataContent, openFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for chunk := range slices.Chunk(expiredFilePaths, AWS_S3_MAX_DELETE_OBJECTS) {
+		var objectsToDelete []types.ObjectIdentifier
+		for _, expiredFilePath := range chunk {
+			LogDebug(storage.config, "Expired object to delete:", expiredFilePath)
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: aws.String(strings.TrimPrefix(expiredFilePath, storage.fullBucketPath(awsS3Bucket)))})
+		}
+
+		err = storage.deleteObjects(ctx, awsS3Bucket, objectsToDelete)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to delete expired objects: %v", err)
+		}
+	}
+
+	return expiredFilePaths, nil
+}
+
+// Merges the small data files of the current snapshot into files of up to the target size and commits them as a new snapshot.
+// The merged files are still referenced by the previous snapshots, they're deleted once those are expired
+func (storage *StorageS3) CompactDataFiles(icebergSchemaTable IcebergSchemaTable, targetFileSize int64) (compactedFilePaths []string, err error) {
+	ctx := context.Background()
+	metadataDirPath := storage.tablePrefix(icebergSchemaTable, true) + "metadata"
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	openFile := func(path string) (io.ReadCloser, error) {
+		getObjectResponse, err := storage.client(awsS3Bucket).GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Key:    aws.String(strings.TrimPrefix(path, storage.fullBucketPath(awsS3Bucket))),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return getObjectResponse.Body, nil
+	}
+
+	metadataContent, _, err := storage.readCurrentMetadata(metadataDirPath)
+	if err != nil {
+		return nil, err
+	}
+	compactedSnapshot, icebergCompactionGroups, err := storage.storageBase.PlanIcebergCompaction(metadataContent, targetFileSize, openFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(icebergCompactionGroups) == 0 {
+		LogDebug(storage.config, "No data files to compact in", icebergSchemaTable.String())
+		return nil, nil
+	}
+
+	var parquetFiles []ParquetFile
+	for _, icebergCompactionGroup := range icebergCompactionGroups {
+		parquetFile, err := storage.compactParquet(icebergSchemaTable, icebergCompactionGroup)
+		if err != nil {
+			return nil, err
+		}
+		parquetFiles = append(parquetFiles, parquetFile)
+		compactedFilePaths = append(compactedFilePaths, icebergCompactionGroup.DataFilePaths...)
+	}
+
+	tempFile, err := CreateTemporaryFile("manifest")
+	if err != nil {
+		return nil, err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	snapshotId := time.Now().UnixNano()
+	compactedManifestFiles := map[string]ManifestFile{}
+	for i, icebergCompactionGroup := range icebergCompactionGroups {
+		if _, ok := compactedManifestFiles[icebergCompactionGroup.ManifestPath]; ok {
+			continue
+		}
+		filePath := metadataDirPath + "/" + fmt.Sprintf("%s-m0.avro", parquetFiles[i].Uuid)
+		manifestFile, err := storage.storageBase.WriteCompactedManifestFile(storage.fullBucketPath(awsS3Bucket), tempFile.Name(), snapshotId, icebergCompactionGroup.ManifestPath, icebergCompactionGroups, parquetFiles, openFile)
+		if err != nil {
+			return nil, err
+		}
+		err = storage.uploadFile(filePath, tempFile)
+		if err != nil {
+			return nil, err
+		}
+		LogDebug(storage.config, "Manifest file created at:", filePath)
+		manifestFile.Path = filePath
+		compactedManifestFiles[icebergCompactionGroup.ManifestPath] = manifestFile
+	}
+
+	manifestListFile := ManifestListFile{Path: metadataDirPath + "/" + fmt.Sprintf("snap-%d-0-%s.avro", snapshotId, parquetFiles[0].Uuid)}
+	err = storage.storageBase.WriteCompactedManifestListFile(storage.fullBucketPath(awsS3Bucket), tempFile.Name(), compactedSnapshot.ManifestList, compactedManifestFiles, icebergCompactionGroups, parquetFiles, openFile)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.uploadFile(manifestListFile.Path, tempFile)
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Manifest list file created at:", manifestListFile.Path)
+
+	metadataFile, err := storage.commitMetadata(metadataDirPath, func(metadataContent []byte) ([]byte, error) {
+		return storage.storageBase.AddIcebergCompactedSnapshot(storage.fullBucketPath(awsS3Bucket), metadataContent, compactedSnapshot.SnapshotId, snapshotId, icebergCompactionGroups, parquetFiles, manifestListFile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	LogDebug(storage.config, "Metadata file created with compacted snapshot at:", metadataFile.Path)
+
+	err = storage.CreateVersionHint(metadataDirPath, metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return compactedFilePaths, nil
+}
+
+// Writes the merged file of the compaction group next to the files it merges, i.e. into the directory of their partition
+func (storage *StorageS3) compactParquet(icebergSchemaTable IcebergSchemaTable, icebergCompactionGroup IcebergCompactionGroup) (parquetFile ParquetFile, err error) {
+	ctx := context.Background()
+	awsS3Bucket := storage.tableBucket(icebergSchemaTable)
+	dataFileKeys := make([]string, len(icebergCompactionGroup.DataFilePaths))
+	for i, dataFilePath := range icebergCompactionGroup.DataFilePaths {
+		dataFileKeys[i] = strings.TrimPrefix(dataFilePath, storage.fullBucketPath(awsS3Bucket))
+	}
+	uuid := uuid.New().String()
+	fileName := fmt.Sprintf("00000-0-%s.parquet", uuid)
+	fileKey := path.Dir(dataFileKeys[0]) + "/" + fileName
+
+	defer storage.requestCache.Load().InvalidateKey(awsS3Bucket, fileKey)
+	fileWriter, err := s3v2.NewS3FileWriterWithClient(ctx, storage.uploadClient(awsS3Bucket), awsS3Bucket.Name, fileKey, storage.uploaderOptions(), storage.putObjectInputOptions()...)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for writing: %v", err)
+	}
+
+	recordCount, err := storage.storageBase.CompactParquetFiles(icebergSchemaTable, fileWriter, dataFileKeys, func(dataFileKey string) (source.ParquetFile, error) {
+		return s3v2.NewS3FileReaderWithClient(ctx, storage.client(awsS3Bucket), awsS3Bucket.Name, dataFileKey)
+	})
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	LogDebug(storage.config, "Parquet file with", recordCount, "record(s) compacted from", len(dataFileKeys), "file(s) at:", fileKey)
+
+	headObjectResponse, err := storage.client(awsS3Bucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Key:    aws.String(fileKey),
+	})
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to get Parquet file info: %v", err)
+	}
+
+	fileReader, err := s3v2.NewS3FileReaderWithClient(ctx, storage.client(awsS3Bucket), awsS3Bucket.Name, fileKey)
+	if err != nil {
+		return ParquetFile{}, fmt.Errorf("Failed to open Parquet file for reading: %v", err)
+	}
+	parquetStats, err := storage.storageBase.ReadParquetStats(fileReader)
+	if err != nil {
+		return ParquetFile{}, err
+	}
+	parquetStats.NanValueCounts = icebergCompactionGroup.NanValueCounts
+
+	return ParquetFile{
+		Uuid:        uuid,
+		Path:        fileKey,
+		Size:        *headObjectResponse.ContentLength,
+		RecordCount: recordCount,
+		Stats:       parquetStats,
+		Partition:   icebergCompactionGroup.Partition,
+	}, nil
+}
+
+// Commits the updated current metadata as a new version, see StorageBase.CommitMetadata
+func (storage *StorageS3) commitMetadata(metadataDirPath string, updateMetadata func(metadataContent []byte) ([]byte, error)) (metadataFile MetadataFile, err error) {
+	version, err := storage.storageBase.CommitMetadata(
+		func() ([]byte, int64, error) {
+			return storage.readCurrentMetadata(metadataDirPath)
+		},
+		updateMetadata,
+		func(version int64, metadataContent []byte) error {
+			return storage.createMetadataVersion(metadataDirPath, version, metadataContent)
+		},
+	)
+	if err != nil {
+		return MetadataFile{}, err
+	}
+
+	return MetadataFile{Version: version, Path: metadataDirPath + "/" + IcebergMetadataFileName(version)}, nil
+}
+
+func (storage *StorageS3) readCurrentMetadata(metadataDirPath string) (metadataContent []byte, version int64, err error) {
+	version, err = storage.currentMetadataVersion(metadataDirPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	metadataContent, err = storage.readMetadataFile(metadataDirPath + "/" + IcebergMetadataFileName(version))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return metadataContent, version, nil
+}
+
+// The version hint is written after a commit, so versions committed since are found by checking the next ones
+func (storage *StorageS3) currentMetadataVersion(metadataDirPath string) (version int64, err error) {
+	awsS3Bucket := storage.keyBucket(metadataDirPath)
+	versionHintContent, err := storage.getObjectContent(awsS3Bucket, metadataDirPath+"/"+VERSION_HINT_FILE_NAME)
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return 0, fmt.Errorf("%w: %v", ERROR_ICEBERG_TABLE_NOT_FOUND, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get version hint file: %v", err)
+	}
+
+	version, err = storage.storageBase.ParseVersionHint(versionHintContent)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		exists, err := storage.objectExists(awsS3Bucket, metadataDirPath+"/"+IcebergMetadataFileName(version+1))
+		if err != nil {
+			return 0, fmt.Errorf("Failed to check metadata file: %v", err)
+		}
+		if !exists {
+			return version, nil
+		}
+		version++
+	}
+}
+
+// Uploads the version with "If-None-Match: *", which S3 rejects if the key already exists
+func (storage *StorageS3) createMetadataVersion(metadataDirPath string, version int64, metadataContent []byte) (err error) {
+	filePath := metadataDirPath + "/" + IcebergMetadataFileName(version)
+	awsS3Bucket := storage.keyBucket(filePath)
+
+	putObjectInput := &s3.PutObjectInput{
+		Bucket:      aws.String(awsS3Bucket.Name),
+		Key:         aws.String(filePath),
+		Body:        bytes.NewReader(metadataContent),
+		IfNoneMatch: aws.String("*"),
+	}
+	for _, option := range storage.putObjectInputOptions() {
+		option(putObjectInput)
+	}
+
+	_, err = storage.uploadClient(awsS3Bucket).PutObject(context.Background(), putObjectInput)
+	if isS3PreconditionError(err) {
+		storage.requestCache.Load().InvalidatePrefix(awsS3Bucket, metadataDirPath+"/")
+		return ERROR_ICEBERG_COMMIT_CONFLICT
+	}
+	if err != nil {
+		storage.requestCache.Load().InvalidateKey(awsS3Bucket, filePath)
+		return fmt.Errorf("Failed to upload metadata file: %v", err)
+	}
+	storage.requestCache.Load().SetObjectContent(awsS3Bucket, filePath, metadataContent)
+
+	return nil
+}
+
+// S3 returns 412 when the key exists and 409 when a concurrent conditional write to the key is in progress
+func isS3PreconditionError(err error) bool {
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	return apiError.ErrorCode() == "PreconditionFailed" || apiError.ErrorCode() == "ConditionalRequestConflict"
+}
+
+func (storage *StorageS3) readMetadataFile(fileKey string) (metadataContent []byte, err error) {
+	metadataContent, err = storage.getObjectContent(storage.keyBucket(fileKey), fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get metadata file: %v", err)
+	}
+
+	return metadataContent, nil
+}
+
+// Content of the object, from the request cache during a sync run
+func (storage *StorageS3) getObjectContent(awsS3Bucket AwsS3Bucket, fileKey string) (content []byte, err error) {
+	requestCache := storage.requestCache.Load()
+	if content, ok := requestCache.ObjectContent(awsS3Bucket, fileKey); ok {
+		return content, nil
+	}
+
+	getObjectResponse, err := storage.client(awsS3Bucket).GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Key:    aws.String(fileKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer getObjectResponse.Body.Close()
+
+	content, err = io.ReadAll(getObjectResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+	requestCache.SetObjectContent(awsS3Bucket, fileKey, content)
+
+	return content, nil
+}
+
+// Whether the object exists, from the request cache during a sync run
+func (storage *StorageS3) objectExists(awsS3Bucket AwsS3Bucket, fileKey string) (exists bool, err error) {
+	requestCache := storage.requestCache.Load()
+	if exists, ok := requestCache.ObjectExists(awsS3Bucket, fileKey); ok {
+		return exists, nil
+	}
+
+	_, err = storage.client(awsS3Bucket).HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Key:    aws.String(fileKey),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		requestCache.SetObjectExists(awsS3Bucket, fileKey, false)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	requestCache.SetObjectExists(awsS3Bucket, fileKey, true)
+
+	return true, nil
+}
+
+func (storage *StorageS3) CreateAuditRecord(auditRecord AuditRecord) (err error) {
+	fileKey := storage.config.AuditLogPath + "/" + auditRecord.FileName()
+
+	tempFile, err := CreateTemporaryFile("audit")
+	if err != nil {
+		return err
+	}
+	defer DeleteTemporaryFile(tempFile)
+
+	err = storage.storageBase.WriteAuditRecordFile(tempFile.Name(), auditRecord)
+	if err != nil {
+		return err
+	}
+
+	err = storage.uploadFile(fileKey, tempFile)
+	if err != nil {
+		return err
+	}
+	LogDebug(storage.config, "Audit record created at:", fileKey)
+
+	return nil
+}
+
+func (storage *StorageS3) uploadFile(filePath string, file *os.File) (err error) {
+	awsS3Bucket := storage.keyBucket(filePath)
+	defer storage.requestCache.Load().InvalidateKey(awsS3Bucket, filePath)
+	uploadClient := storage.uploadClient(awsS3Bucket)
+	multipartUploader := NewS3MultipartUploader(storage.config, uploadClient)
+
+	putObjectInput := &s3.PutObjectInput{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Key:    aws.String(filePath),
+		Body:   file,
+	}
+	for _, option := range storage.putObjectInputOptions() {
+		option(putObjectInput)
+	}
+
+	isMultipart, err := multipartUploader.IsMultipart(file)
+	if err != nil {
+		return err
+	}
+	if isMultipart {
+		err = multipartUploader.Upload(context.Background(), putObjectInput, file)
+	} else {
+		_, err = manager.NewUploader(uploadClient, storage.uploaderOptions()...).Upload(context.Background(), putObjectInput)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to upload file: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageS3) uploadClient(awsS3Bucket AwsS3Bucket) *S3AclFallbackClient {
+	return &S3AclFallbackClient{Client: storage.client(awsS3Bucket), storage: storage}
+}
+
+// S3 client for uploads that retries a request without the ACL if the bucket has ACLs disabled, and leaves the ACL out of
+// the next uploads. Used by all upload paths, since streamed Parquet files can't be re-sent as a whole
+type S3AclFallbackClient struct {
+	*s3.Client
+	storage *StorageS3
+}
+
+func (client *S3AclFallbackClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	putObjectResponse, err := client.Client.PutObject(ctx, params, optFns...)
+	if params.ACL == "" || !client.isAclNotSupportedError(err) {
+		return putObjectResponse, err
+	}
+
+	// The uploader sends a single part from memory, other bodies are files or byte readers
+	if body, ok := params.Body.(io.Seeker); ok {
+		_, err = body.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to rewind upload: %v", err)
+		}
+	}
+	params.ACL = ""
+	return client.Client.PutObject(ctx, params, optFns...)
+}
+
+func (client *S3AclFallbackClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	createResponse, err := client.Client.CreateMultipartUpload(ctx, params, optFns...)
+	if params.ACL == "" || !client.isAclNotSupportedError(err) {
+		return createResponse, err
+	}
+
+	params.ACL = ""
+	return client.Client.CreateMultipartUpload(ctx, params, optFns...)
+}
+
+func (client *S3AclFallbackClient) isAclNotSupportedError(err error) bool {
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) || apiError.ErrorCode() != "AccessControlListNotSupported" {
+		return false
+	}
+
+	if !client.storage.aclNotSupported.Swap(true) {
+		LogWarn(client.storage.config, "AWS S3 bucket has ACLs disabled, uploading without ACL", client.storage.config.Aws.S3ACL)
+	}
+	return true
+}
+
+// Streamed Parquet files are buffered one part per concurrent upload, and sent with a single PutObject if they fit into the first part
+func (storage *StorageS3) uploaderOptions() []func(*manager.Uploader) {
+	var options []func(*manager.Uploader)
+
+	if storage.config.Aws.UploadPartSize != 0 {
+		options = append(options, func(uploader *manager.Uploader) {
+			uploader.PartSize = storage.config.Aws.UploadPartSize
+		})
+	}
+
+	if storage.config.Aws.UploadConcurrency != 0 {
+		options = append(options, func(uploader *manager.Uploader) {
+			uploader.Concurrency = storage.config.Aws.UploadConcurrency
+		})
+	}
+
+	return options
+}
+
+func (storage *StorageS3) putObjectInputOptions() []func(*s3.PutObjectInput) {
+	var options []func(*s3.PutObjectInput)
+
+	if storage.config.Aws.S3ACL != "" && !storage.aclNotSupported.Load() {
+		options = append(options, func(putObjectInput *s3.PutObjectInput) {
+			putObjectInput.ACL = types.ObjectCannedACL(storage.config.Aws.S3ACL)
+		})
+	}
+
+	if storage.config.Aws.S3StorageClass != "" {
+		options = append(options, func(putObjectInput *s3.PutObjectInput) {
+			putObjectInput.StorageClass = types.StorageClass(storage.config.Aws.S3StorageClass)
+		})
+	}
+
+	if storage.config.Aws.SseAlgorithm != "" {
+		options = append(options, func(putObjectInput *s3.PutObjectInput) {
+			putObjectInput.ServerSideEncryption = types.ServerSideEncryption(storage.config.Aws.SseAlgorithm)
+			if storage.config.Aws.KmsKeyId != "" {
+				putObjectInput.SSEKMSKeyId = aws.String(storage.config.Aws.KmsKeyId)
+			}
+		})
+	}
+
+	return options
+}
+
+// Copies an object onto itself to change its storage class, keeping its metadata, ACL and configured encryption
+func (storage *StorageS3) transitionCopyObjectInput(fileKey string, storageClass string) *s3.CopyObjectInput {
+	awsS3Bucket := storage.keyBucket(fileKey)
+	copyObjectInput := &s3.CopyObjectInput{
+		Bucket:            aws.String(awsS3Bucket.Name),
+		Key:               aws.String(fileKey),
+		CopySource:        aws.String(awsS3Bucket.Name + "/" + fileKey),
+		MetadataDirective: types.MetadataDirectiveCopy,
+		StorageClass:      types.StorageClass(storageClass),
+	}
+
+	if storage.config.Aws.S3ACL != "" && !storage.aclNotSupported.Load() {
+		copyObjectInput.ACL = types.ObjectCannedACL(storage.config.Aws.S3ACL)
+	}
+	if storage.config.Aws.SseAlgorithm != "" {
+		copyObjectInput.ServerSideEncryption = types.ServerSideEncryption(storage.config.Aws.SseAlgorithm)
+		if storage.config.Aws.KmsKeyId != "" {
+			copyObjectInput.SSEKMSKeyId = aws.String(storage.config.Aws.KmsKeyId)
+		}
+	}
+
+	return copyObjectInput
+}
+
+// Copies an object in parts, since CopyObject fails for objects above 5 GB. Unlike CopyObject, a multipart copy
+// doesn't keep the metadata of the source object, so it's set from the HeadObject response
+func (storage *StorageS3) multipartCopyObject(copyObjectInput *s3.CopyObjectInput, headObjectResponse *s3.HeadObjectOutput, partSize int64) (err error) {
+	ctx := context.Background()
+	uploadClient := storage.uploadClient(storage.keyBucket(*copyObjectInput.Key))
+
+	createResponse, err := uploadClient.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               copyObjectInput.Bucket,
+		Key:                  copyObjectInput.Key,
+		ACL:                  copyObjectInput.ACL,
+		StorageClass:         copyObjectInput.StorageClass,
+		ServerSideEncryption: copyObjectInput.ServerSideEncryption,
+		SSEKMSKeyId:          copyObjectInput.SSEKMSKeyId,
+		ContentType:          headObjectResponse.ContentType,
+		Metadata:             headObjectResponse.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create multipart copy: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			uploadClient.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   copyObjectInput.Bucket,
+				Key:      copyObjectInput.Key,
+				UploadId: createResponse.UploadId,
+			})
+		}
+	}()
+
+	objectSize := aws.ToInt64(headObjectResponse.ContentLength)
+	var completedParts []types.CompletedPart
+	for partNumber, offset := int32(1), int64(0); offset < objectSize; partNumber, offset = partNumber+1, offset+partSize {
+		uploadPartCopyResponse, err := uploadClient.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          copyObjectInput.Bucket,
+			Key:             copyObjectInput.Key,
+			UploadId:        createResponse.UploadId,
+			PartNumber:      aws.Int32(partNumber),
+			CopySource:      copyObjectInput.CopySource,
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, min(offset+partSize, objectSize)-1)),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to copy part %d: %v", partNumber, err)
+		}
+		completedParts = append(completedParts, types.CompletedPart{PartNumber: aws.Int32(partNumber), ETag: uploadPartCopyResponse.CopyPartResult.ETag})
+	}
+
+	_, err = uploadClient.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          copyObjectInput.Bucket,
+		Key:             copyObjectInput.Key,
+		UploadId:        createResponse.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to complete multipart copy: %v", err)
+	}
+
+	return nil
+}
+
+func (storage *StorageS3) tablePrefix(schemaTable IcebergSchemaTable, isIcebergSchemaTable ...bool) string {
+	if len(isIcebergSchemaTable) > 0 && isIcebergSchemaTable[0] {
+		return storage.config.StoragePath + "/" + schemaTable.Schema + "/" + schemaTable.Table + "/"
+	}
+
+	return storage.config.StoragePath + "/" + storage.config.Pg.SchemaPrefix + schemaTable.Schema + "/" + schemaTable.Table + "/"
+}
+
+func (storage *StorageS3) metadataFilePath(icebergSchemaTable IcebergSchemaTable, version int64) string {
+	return storage.fullBucketPath(storage.tableBucket(icebergSchemaTable)) + storage.tablePrefix(icebergSchemaTable, true) + "metadata/" + IcebergMetadataFileName(version)
+}
+
+func (storage *StorageS3) fullBucketPath(awsS3Bucket AwsS3Bucket) string {
+	return "s3://" + awsS3Bucket.Name + "/"
+}
+
+func (storage *StorageS3) defaultBucket() AwsS3Bucket {
+	return AwsS3Bucket{Name: storage.config.Aws.S3Bucket, Region: storage.config.Aws.Region}
+}
+
+// S3Bucket unless the table is configured to be stored in another bucket
+func (storage *StorageS3) tableBucket(icebergSchemaTable IcebergSchemaTable) AwsS3Bucket {
+	if awsS3Bucket, ok := storage.config.Aws.S3TableBuckets[icebergSchemaTable.Schema+"."+icebergSchemaTable.Table]; ok {
+		return awsS3Bucket
+	}
+	return storage.defaultBucket()
+}
+
+// Bucket of a table's object from its key, e.g. [StoragePath]/public/users/metadata/v1.metadata.json
+func (storage *StorageS3) keyBucket(fileKey string) AwsS3Bucket {
+	keyParts := strings.Split(strings.TrimPrefix(fileKey, storage.config.StoragePath+"/"), "/")
+	if len(keyParts) < 2 {
+		return storage.defaultBucket()
+	}
+	return storage.tableBucket(IcebergSchemaTable{Schema: keyParts[0], Table: keyParts[1]})
+}
+
+func (storage *StorageS3) client(awsS3Bucket AwsS3Bucket) *s3.Client {
+	return storage.s3Clients[awsS3Bucket.Region]
+}
+
+// Tables stored in other buckets that have been synced
+func (storage *StorageS3) tableBucketSchemaTables() (icebergSchemaTables []IcebergSchemaTable, err error) {
+	for schemaTable, awsS3Bucket := range storage.config.Aws.S3TableBuckets {
+		schema, table, _ := strings.Cut(schemaTable, ".")
+		icebergSchemaTable := IcebergSchemaTable{Schema: schema, Table: table}
+
+		tableDirs, err := storage.nestedDirectoryPrefixes(awsS3Bucket, storage.tablePrefix(icebergSchemaTable, true))
+		if err != nil {
+			return nil, err
+		}
+		if len(tableDirs) > 0 {
+			icebergSchemaTables = append(icebergSchemaTables, icebergSchemaTable)
+		}
+	}
+
+	return icebergSchemaTables, nil
+}
+
+// Listed directories, from the request cache during a sync run
+func (storage *StorageS3) nestedDirectoryPrefixes(awsS3Bucket AwsS3Bucket, prefix string) (dirs []string, err error) {
+	requestCache := storage.requestCache.Load()
+	if dirs, ok := requestCache.DirectoryPrefixes(awsS3Bucket, prefix); ok {
+		return dirs, nil
+	}
+
+	ctx := context.Background()
+	paginator := s3.NewListObjectsV2Paginator(storage.client(awsS3Bucket), &s3.ListObjectsV2Input{
+		Bucket:    aws.String(awsS3Bucket.Name),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		listResponse, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list objects: %v", err)
+		}
+
+		for _, prefix := range listResponse.CommonPrefixes {
+			dirs = append(dirs, *prefix.Prefix)
+		}
+	}
+	requestCache.SetDirectoryPrefixes(awsS3Bucket, prefix, dirs)
+
+	return dirs, nil
+}
+
+func (storage *StorageS3) deleteNestedObjects(awsS3Bucket AwsS3Bucket, prefix string) (err error) {
+	ctx := context.Background()
+	deletedObjectCount := 0
+
+	paginator := s3.NewListObjectsV2Paginator(storage.client(awsS3Bucket), &s3.ListObjectsV2Input{
+		Bucket: aws.String(awsS3Bucket.Name),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		listResponse, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to list objects: %v", err)
+		}
+
+		var objectsToDelete []types.ObjectIdentifier
+		for _, obj := range listResponse.Contents {
+			LogDebug(storage.config, "Object to delete:", *obj.Key)
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: obj.Key})
+		}
+
+		// S3-compatible storages can return more keys per page than a single DeleteObjects request accepts
+		for chunk := range slices.Chunk(objectsToDelete, AWS_S3_MAX_DELETE_OBJECTS) {
+			err = storage.deleteObjects(ctx, awsS3Bucket, chunk)
+			if err != nil {
+				return fmt.Errorf("Failed to delete objects: %v", err)
+			}
+			deletedObjectCount += len(chunk)
+		}
+	}
+
+	if deletedObjectCount > 0 {
+		LogDebug(storage.config, "Deleted", deletedObjectCount, "object(s).")
+	} else {
+		LogDebug(storage.config, "No objects to delete.")
+	}
+
+	return nil
+}
+
+// Deletes up to AWS_S3_MAX_DELETE_OBJECTS objects. S3 can fail to delete single keys of a successful request,
+// e.g. with SlowDown, so these keys are deleted again with exponential backoff
+func (storage *StorageS3) deleteObjects(ctx context.Context, awsS3Bucket AwsS3Bucket, objectsToDelete []types.ObjectIdentifier) (err error) {
+	backoff := retry.NewExponentialJitterBackoff(retry.DefaultMaxBackoff)
+	defer func(objectsToDelete []types.ObjectIdentifier) {
+		for _, objectToDelete := range objectsToDelete {
+			storage.requestCache.Load().InvalidateKey(awsS3Bucket, aws.ToString(objectToDelete.Key))
+		}
+	}(objectsToDelete)
+
+	for attempt := 1; ; attempt++ {
+		deleteResponse, err := storage.client(awsS3Bucket).DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(awsS3Bucket.Name),
+			Delete: &types.Delete{
+				Objects: objectsToDelete,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if len(deleteResponse.Errors) == 0 {
+			return nil
+		}
+
+		objectsToDelete = nil
+		for _, deleteError := range deleteResponse.Errors {
+			if attempt > storage.config.Aws.MaxRetries || !slices.Contains(AWS_S3_RETRYABLE_DELETE_ERROR_CODES, aws.ToString(deleteError.Code)) {
+				return fmt.Errorf("object %s: %s %s", aws.ToString(deleteError.Key), aws.ToString(deleteError.Code), aws.ToString(deleteError.Message))
+			}
+			objectsToDelete = append(objectsToDelete, types.ObjectIdentifier{Key: deleteError.Key, VersionId: deleteError.VersionId})
+		}
+
+		delay, err := backoff.BackoffDelay(attempt, nil)
+		if err != nil {
+			return err
+		}
+		LogWarn(storage.config, "Failed to delete", len(objectsToDelete), "AWS S3 object(s), retrying in", delay)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,146 @@
+package bemidb
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Responses of S3 requests that are repeated within a sync run, e.g. each table write reads the version hint and checks
+// the next metadata version several times, and the schemas are listed again when deleting old tables.
+// Shared by the storages of a Syncer for the duration of a sync run. Keys written or deleted through these storages are
+// invalidated, while a metadata version committed by another process is detected by the conditional upload of the next
+// version, which invalidates the table's metadata before the commit is retried
+type S3RequestCache struct {
+	mutex             sync.Mutex
+	objectContents    map[string][]byte   // by bucket and key
+	objectExists      map[string]bool     // by bucket and key, results of HeadObject
+	directoryPrefixes map[string][]string // by bucket and prefix, results of ListObjectsV2 with the "/" delimiter
+}
+
+func NewS3RequestCache() *S3RequestCache {
+	return &S3RequestCache{
+		objectContents:    map[string][]byte{},
+		objectExists:      map[string]bool{},
+		directoryPrefixes: map[string][]string{},
+	}
+}
+
+// The methods of a nil cache don't cache anything, so that storages without a sync run in progress send every request
+
+func (cache *S3RequestCache) ObjectContent(awsS3Bucket AwsS3Bucket, key string) (content []byte, ok bool) {
+	if cache == nil {
+		return nil, false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	content, ok = cache.objectContents[cache.bucketKey(awsS3Bucket, key)]
+	return slices.Clone(content), ok
+}
+
+func (cache *S3RequestCache) SetObjectContent(awsS3Bucket AwsS3Bucket, key string, content []byte) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateKey(awsS3Bucket, key)
+	cache.objectContents[cache.bucketKey(awsS3Bucket, key)] = slices.Clone(content)
+	cache.objectExists[cache.bucketKey(awsS3Bucket, key)] = true
+}
+
+func (cache *S3RequestCache) ObjectExists(awsS3Bucket AwsS3Bucket, key string) (exists bool, ok bool) {
+	if cache == nil {
+		return false, false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	exists, ok = cache.objectExists[cache.bucketKey(awsS3Bucket, key)]
+	return exists, ok
+}
+
+func (cache *S3RequestCache) SetObjectExists(awsS3Bucket AwsS3Bucket, key string, exists bool) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.objectExists[cache.bucketKey(awsS3Bucket, key)] = exists
+}
+
+func (cache *S3RequestCache) DirectoryPrefixes(awsS3Bucket AwsS3Bucket, prefix string) (dirs []string, ok bool) {
+	if cache == nil {
+		return nil, false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	dirs, ok = cache.directoryPrefixes[cache.bucketKey(awsS3Bucket, prefix)]
+	return slices.Clone(dirs), ok
+}
+
+func (cache *S3RequestCache) SetDirectoryPrefixes(awsS3Bucket AwsS3Bucket, prefix string, dirs []string) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.directoryPrefixes[cache.bucketKey(awsS3Bucket, prefix)] = slices.Clone(dirs)
+}
+
+// Forgets a written or deleted key, and the listings it may have added a directory to or removed one from
+func (cache *S3RequestCache) InvalidateKey(awsS3Bucket AwsS3Bucket, key string) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.invalidateKey(awsS3Bucket, key)
+}
+
+// Forgets all keys with the prefix, e.g. the metadata of a table after another writer committed a version
+func (cache *S3RequestCache) InvalidatePrefix(awsS3Bucket AwsS3Bucket, prefix string) {
+	if cache == nil {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	bucketPrefix := cache.bucketKey(awsS3Bucket, prefix)
+	for bucketKey := range cache.objectContents {
+		if strings.HasPrefix(bucketKey, bucketPrefix) {
+			delete(cache.objectContents, bucketKey)
+		}
+	}
+	for bucketKey := range cache.objectExists {
+		if strings.HasPrefix(bucketKey, bucketPrefix) {
+			delete(cache.objectExists, bucketKey)
+		}
+	}
+	for bucketListPrefix := range cache.directoryPrefixes {
+		if strings.HasPrefix(bucketListPrefix, bucketPrefix) || strings.HasPrefix(bucketPrefix, bucketListPrefix) {
+			delete(cache.directoryPrefixes, bucketListPrefix)
+		}
+	}
+}
+
+func (cache *S3RequestCache) invalidateKey(awsS3Bucket AwsS3Bucket, key string) {
+	bucketKey := cache.bucketKey(awsS3Bucket, key)
+	delete(cache.objectContents, bucketKey)
+	delete(cache.objectExists, bucketKey)
+	for bucketListPrefix := range cache.directoryPrefixes {
+		if strings.HasPrefix(bucketKey, bucketListPrefix) {
+			delete(cache.directoryPrefixes, bucketListPrefix)
+		}
+	}
+}
+
+func (cache *S3RequestCache) bucketKey(awsS3Bucket AwsS3Bucket, key string) string {
+	return awsS3Bucket.Name + "/" + key
+}
@@ -0,0 +1,288 @@
+package bemidb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Files above one part (AwsConfig.UploadPartSize) are uploaded in parts that survive a restart, smaller files are re-sent as a whole
+const AWS_S3_RESUMABLE_UPLOAD_STATE_DIR = "bemidb-s3-uploads"
+
+// Subset of the S3 client used for multipart uploads
+type S3MultipartClient interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
+}
+
+// Progress of a multipart upload persisted after each part, so that a restarted process can resume it
+type S3MultipartUploadState struct {
+	Bucket      string  `json:"bucket"`
+	Key         string  `json:"key"`
+	UploadId    string  `json:"upload-id"`
+	PartSize    int64   `json:"part-size"`
+	FileSize    int64   `json:"file-size"`
+	FileModTime int64   `json:"file-mod-time"`
+	Parts       []int32 `json:"parts"`
+}
+
+type S3MultipartUploader struct {
+	client   S3MultipartClient
+	stateDir string
+	partSize int64
+	config   *Config
+}
+
+func NewS3MultipartUploader(config *Config, client S3MultipartClient) *S3MultipartUploader {
+	return &S3MultipartUploader{
+		client:   client,
+		stateDir: filepath.Join(os.TempDir(), AWS_S3_RESUMABLE_UPLOAD_STATE_DIR),
+		partSize: config.Aws.UploadPartSize,
+		config:   config,
+	}
+}
+
+func (uploader *S3MultipartUploader) IsMultipart(file *os.File) (bool, error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("Failed to get file info: %v", err)
+	}
+	return fileInfo.Size() > uploader.partSize, nil
+}
+
+// Uploads the file in parts, resuming an upload of the same file to the same key that was interrupted by a restart.
+// Other unfinished uploads to the key can't be resumed and are aborted, so S3 doesn't keep charging for their parts
+func (uploader *S3MultipartUploader) Upload(ctx context.Context, putObjectInput *s3.PutObjectInput, file *os.File) error {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("Failed to get file info: %v", err)
+	}
+
+	state := uploader.resumableState(ctx, putObjectInput, fileInfo)
+	if state == nil {
+		state, err = uploader.createUpload(ctx, putObjectInput, fileInfo)
+		if err != nil {
+			return err
+		}
+	}
+
+	partCount := int32((state.FileSize + state.PartSize - 1) / state.PartSize)
+	for partNumber := int32(1); partNumber <= partCount; partNumber++ {
+		if slices.Contains(state.Parts, partNumber) {
+			continue
+		}
+
+		offset := int64(partNumber-1) * state.PartSize
+		_, err = uploader.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(state.Bucket),
+			Key:           aws.String(state.Key),
+			UploadId:      aws.String(state.UploadId),
+			PartNumber:    aws.Int32(partNumber),
+			Body:          io.NewSectionReader(file, offset, min(state.PartSize, state.FileSize-offset)),
+			ContentLength: aws.Int64(min(state.PartSize, state.FileSize-offset)),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to upload part %d of %s: %v", partNumber, state.Key, err)
+		}
+
+		state.Parts = append(state.Parts, partNumber)
+		err = uploader.writeState(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	completedParts, err := uploader.uploadedParts(ctx, state)
+	if err != nil {
+		return err
+	}
+	_, err = uploader.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(state.Bucket),
+		Key:             aws.String(state.Key),
+		UploadId:        aws.String(state.UploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to complete multipart upload of %s: %v", state.Key, err)
+	}
+	LogDebug(uploader.config, "Multipart upload completed for", state.Key)
+
+	return uploader.deleteState(state.Bucket, state.Key)
+}
+
+// Returns the persisted state if the upload can be resumed, parts that S3 doesn't have anymore are uploaded again
+func (uploader *S3MultipartUploader) resumableState(ctx context.Context, putObjectInput *s3.PutObjectInput, fileInfo os.FileInfo) *S3MultipartUploadState {
+	state, err := uploader.readState(*putObjectInput.Bucket, *putObjectInput.Key)
+	if err != nil {
+		LogWarn(uploader.config, "Couldn't read multipart upload state:", err)
+		return nil
+	}
+	if state == nil || state.PartSize != uploader.partSize || state.FileSize != fileInfo.Size() || state.FileModTime != fileInfo.ModTime().UnixNano() {
+		return nil
+	}
+
+	completedParts, err := uploader.uploadedParts(ctx, state)
+	if err != nil {
+		LogWarn(uploader.config, "Couldn't resume multipart upload of", state.Key+":", err)
+		return nil
+	}
+
+	state.Parts = nil
+	for _, completedPart := range completedParts {
+		state.Parts = append(state.Parts, *completedPart.PartNumber)
+	}
+	LogInfo(uploader.config, "Resuming multipart upload of", state.Key, "with", len(state.Parts), "uploaded part(s)")
+	return state
+}
+
+func (uploader *S3MultipartUploader) createUpload(ctx context.Context, putObjectInput *s3.PutObjectInput, fileInfo os.FileInfo) (*S3MultipartUploadState, error) {
+	err := uploader.abortUploads(ctx, *putObjectInput.Bucket, *putObjectInput.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	createResponse, err := uploader.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               putObjectInput.Bucket,
+		Key:                  putObjectInput.Key,
+		ACL:                  putObjectInput.ACL,
+		StorageClass:         putObjectInput.StorageClass,
+		ServerSideEncryption: putObjectInput.ServerSideEncryption,
+		SSEKMSKeyId:          putObjectInput.SSEKMSKeyId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create multipart upload of %s: %v", *putObjectInput.Key, err)
+	}
+
+	state := &S3MultipartUploadState{
+		Bucket:      *putObjectInput.Bucket,
+		Key:         *putObjectInput.Key,
+		UploadId:    *createResponse.UploadId,
+		PartSize:    uploader.partSize,
+		FileSize:    fileInfo.Size(),
+		FileModTime: fileInfo.ModTime().UnixNano(),
+	}
+	return state, uploader.writeState(state)
+}
+
+// Aborts unfinished uploads to the key, e.g. of a file that changed before the upload could be resumed
+func (uploader *S3MultipartUploader) abortUploads(ctx context.Context, bucket string, key string) error {
+	listResponse, err := uploader.client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list multipart uploads: %v", err)
+	}
+
+	for _, multipartUpload := range listResponse.Uploads {
+		if *multipartUpload.Key != key {
+			continue
+		}
+		_, err = uploader.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: multipartUpload.UploadId,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to abort multipart upload of %s: %v", key, err)
+		}
+		LogDebug(uploader.config, "Aborted stale multipart upload of", key)
+	}
+
+	return nil
+}
+
+func (uploader *S3MultipartUploader) uploadedParts(ctx context.Context, state *S3MultipartUploadState) (completedParts []types.CompletedPart, err error) {
+	var partNumberMarker *string
+	for {
+		listResponse, err := uploader.client.ListParts(ctx, &s3.ListPartsInput{
+			Bucket:           aws.String(state.Bucket),
+			Key:              aws.String(state.Key),
+			UploadId:         aws.String(state.UploadId),
+			PartNumberMarker: partNumberMarker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list uploaded parts: %v", err)
+		}
+
+		for _, part := range listResponse.Parts {
+			completedParts = append(completedParts, types.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+		}
+		if !aws.ToBool(listResponse.IsTruncated) {
+			return completedParts, nil
+		}
+		partNumberMarker = listResponse.NextPartNumberMarker
+	}
+}
+
+func (uploader *S3MultipartUploader) statePath(bucket string, key string) string {
+	hash := sha256.Sum256([]byte(bucket + "/" + key))
+	return filepath.Join(uploader.stateDir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (uploader *S3MultipartUploader) readState(bucket string, key string) (*S3MultipartUploadState, error) {
+	content, err := os.ReadFile(uploader.statePath(bucket, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state S3MultipartUploadState
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Bucket != bucket || state.Key != key {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// Writes to a temporary file first, so a restart while writing doesn't leave a truncated state
+func (uploader *S3MultipartUploader) writeState(state *S3MultipartUploadState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("Failed to encode multipart upload state: %v", err)
+	}
+
+	err = os.MkdirAll(uploader.stateDir, 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to create multipart upload state directory: %v", err)
+	}
+
+	statePath := uploader.statePath(state.Bucket, state.Key)
+	err = os.WriteFile(statePath+".tmp", content, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write multipart upload state: %v", err)
+	}
+	err = os.Rename(statePath+".tmp", statePath)
+	if err != nil {
+		return fmt.Errorf("Failed to write multipart upload state: %v", err)
+	}
+	return nil
+}
+
+func (uploader *S3MultipartUploader) deleteState(bucket string, key string) error {
+	err := os.Remove(uploader.statePath(bucket, key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Failed to delete multipart upload state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestS3MultipartUploader(t *testing.T) {
+	t.Run("Resumes an upload interrupted by a restart without re-sending uploaded parts", func(t *testing.T) {
+		stateDir := t.TempDir()
+		file := testMultipartFile(t, "0123456789abcdefghij")
+		client := &fakeS3MultipartClient{uploads: map[string]map[int32][]byte{}, failPartNumber: 3}
+
+		err := testMultipartUploader(client, stateDir).Upload(context.Background(), testPutObjectInput(), file)
+		if err == nil {
+			t.Fatal("Expected the upload to be interrupted")
+		}
+
+		client.failPartNumber = 0
+		client.uploadedPartNumbers = nil
+		err = testMultipartUploader(client, stateDir).Upload(context.Background(), testPutObjectInput(), file)
+
+		testNoError(t, err)
+		if !slices.Equal(client.uploadedPartNumbers, []int32{3, 4}) {
+			t.Errorf("Expected only parts [3 4] to be uploaded after the restart, got %v", client.uploadedPartNumbers)
+		}
+		if string(client.completedObject) != "0123456789abcdefghij" {
+			t.Errorf("Expected the completed object to match the file, got %s", client.completedObject)
+		}
+		if len(client.abortedUploadIds) != 0 {
+			t.Errorf("Expected no aborted uploads, got %v", client.abortedUploadIds)
+		}
+		stateFiles, _ := os.ReadDir(stateDir)
+		if len(stateFiles) != 0 {
+			t.Errorf("Expected the upload state to be deleted, got %v file(s)", len(stateFiles))
+		}
+	})
+
+	t.Run("Aborts the interrupted upload when the file changed", func(t *testing.T) {
+		stateDir := t.TempDir()
+		file := testMultipartFile(t, "0123456789abcdefghij")
+		client := &fakeS3MultipartClient{uploads: map[string]map[int32][]byte{}, failPartNumber: 2}
+
+		err := testMultipartUploader(client, stateDir).Upload(context.Background(), testPutObjectInput(), file)
+		if err == nil {
+			t.Fatal("Expected the upload to be interrupted")
+		}
+
+		client.failPartNumber = 0
+		client.uploadedPartNumbers = nil
+		file = testMultipartFile(t, "0123456789abcdefghijklmno")
+		err = testMultipartUploader(client, stateDir).Upload(context.Background(), testPutObjectInput(), file)
+
+		testNoError(t, err)
+		if !slices.Equal(client.abortedUploadIds, []string{"upload-1"}) {
+			t.Errorf("Expected the stale upload to be aborted, got %v", client.abortedUploadIds)
+		}
+		if !slices.Equal(client.uploadedPartNumbers, []int32{1, 2, 3, 4, 5}) {
+			t.Errorf("Expected all parts to be uploaded, got %v", client.uploadedPartNumbers)
+		}
+		if string(client.completedObject) != "0123456789abcdefghijklmno" {
+			t.Errorf("Expected the completed object to match the changed file, got %s", client.completedObject)
+		}
+	})
+
+	t.Run("Starts over when the interrupted upload no longer exists", func(t *testing.T) {
+		stateDir := t.TempDir()
+		file := testMultipartFile(t, "0123456789abcdefghij")
+		client := &fakeS3MultipartClient{uploads: map[string]map[int32][]byte{}, failPartNumber: 2}
+
+		err := testMultipartUploader(client, stateDir).Upload(context.Background(), testPutObjectInput(), file)
+		if err == nil {
+			t.Fatal("Expected the upload to be interrupted")
+		}
+
+		client.failPartNumber = 0
+		client.uploadedPartNumbers = nil
+		delete(client.uploads, "upload-1") // e.g. removed by a bucket lifecycle rule
+		err = testMultipartUploader(client, stateDir).Upload(context.Background(), testPutObjectInput(), file)
+
+		testNoError(t, err)
+		if !slices.Equal(client.uploadedPartNumbers, []int32{1, 2, 3, 4}) {
+			t.Errorf("Expected all parts to be uploaded, got %v", client.uploadedPartNumbers)
+		}
+		if string(client.completedObject) != "0123456789abcdefghij" {
+			t.Errorf("Expected the completed object to match the file, got %s", client.completedObject)
+		}
+	})
+
+	t.Run("Creates the upload with the server-side encryption of the object", func(t *testing.T) {
+		file := testMultipartFile(t, "0123456789abcdefghij")
+		client := &fakeS3MultipartClient{uploads: map[string]map[int32][]byte{}}
+		putObjectInput := testPutObjectInput()
+		putObjectInput.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		putObjectInput.SSEKMSKeyId = aws.String("my-key")
+
+		err := testMultipartUploader(client, t.TempDir()).Upload(context.Background(), putObjectInput, file)
+
+		testNoError(t, err)
+		if client.createInput.ServerSideEncryption != types.ServerSideEncryptionAwsKms || *client.createInput.SSEKMSKeyId != "my-key" {
+			t.Errorf("Expected the upload to be encrypted with aws:kms and my-key, got %s and %v", client.createInput.ServerSideEncryption, client.createInput.SSEKMSKeyId)
+		}
+	})
+}
+
+func testMultipartUploader(client S3MultipartClient, stateDir string) *S3MultipartUploader {
+	uploader := NewS3MultipartUploader(loadTestConfig(), client)
+	uploader.stateDir = stateDir
+	uploader.partSize = 5
+	return uploader
+}
+
+func testMultipartFile(t *testing.T, content string) *os.File {
+	filePath := filepath.Join(t.TempDir(), "file.parquet")
+	err := os.WriteFile(filePath, []byte(content), 0644)
+	testNoError(t, err)
+
+	file, err := os.Open(filePath)
+	testNoError(t, err)
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func testPutObjectInput() *s3.PutObjectInput {
+	return &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("iceberg/public/users/data/file.parquet")}
+}
+
+// In-memory multipart uploads of a single key
+type fakeS3MultipartClient struct {
+	uploads             map[string]map[int32][]byte // parts by upload id
+	uploadCount         int
+	failPartNumber      int32
+	uploadedPartNumbers []int32
+	abortedUploadIds    []string
+	completedObject     []byte
+	createInput         *s3.CreateMultipartUploadInput
+}
+
+func (client *fakeS3MultipartClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	client.uploadCount++
+	client.createInput = params
+	uploadId := fmt.Sprintf("upload-%d", client.uploadCount)
+	client.uploads[uploadId] = map[int32][]byte{}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(uploadId)}, nil
+}
+
+func (client *fakeS3MultipartClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if *params.PartNumber == client.failPartNumber {
+		return nil, errors.New("connection reset")
+	}
+	parts, ok := client.uploads[*params.UploadId]
+	if !ok {
+		return nil, errors.New("NoSuchUpload")
+	}
+
+	content, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	parts[*params.PartNumber] = content
+	client.uploadedPartNumbers = append(client.uploadedPartNumbers, *params.PartNumber)
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", *params.PartNumber))}, nil
+}
+
+func (client *fakeS3MultipartClient) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	parts, ok := client.uploads[*params.UploadId]
+	if !ok {
+		return nil, errors.New("NoSuchUpload")
+	}
+
+	listResponse := &s3.ListPartsOutput{IsTruncated: aws.Bool(false)}
+	for partNumber := int32(1); partNumber <= int32(len(parts)+1); partNumber++ {
+		if _, ok := parts[partNumber]; ok {
+			listResponse.Parts = append(listResponse.Parts, types.Part{PartNumber: aws.Int32(partNumber), ETag: aws.String(fmt.Sprintf("etag-%d", partNumber))})
+		}
+	}
+	return listResponse, nil
+}
+
+func (client *fakeS3MultipartClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	parts, ok := client.uploads[*params.UploadId]
+	if !ok {
+		return nil, errors.New("NoSuchUpload")
+	}
+
+	var object bytes.Buffer
+	for _, completedPart := range params.MultipartUpload.Parts {
+		object.Write(parts[*completedPart.PartNumber])
+	}
+	client.completedObject = object.Bytes()
+	delete(client.uploads, *params.UploadId)
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (client *fakeS3MultipartClient) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	client.abortedUploadIds = append(client.abortedUploadIds, *params.UploadId)
+	delete(client.uploads, *params.UploadId)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (client *fakeS3MultipartClient) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	listResponse := &s3.ListMultipartUploadsOutput{}
+	for uploadId := range client.uploads {
+		listResponse.Uploads = append(listResponse.Uploads, types.MultipartUpload{Key: params.Prefix, UploadId: aws.String(uploadId)})
+	}
+	return listResponse, nil
+}
@@ -0,0 +1,814 @@
+package bemidb
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/xitongsys/parquet-go-source/s3v2"
+)
+
+func TestPutObjectInputOptions(t *testing.T) {
+	t.Run("Sets the ACL when configured", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{S3ACL: "bucket-owner-full-control"}}}
+		putObjectInput := &s3.PutObjectInput{}
+
+		for _, option := range storage.putObjectInputOptions() {
+			option(putObjectInput)
+		}
+
+		if putObjectInput.ACL != "bucket-owner-full-control" {
+			t.Errorf("Expected ACL to be bucket-owner-full-control, got %s", putObjectInput.ACL)
+		}
+	})
+
+	t.Run("Sets the storage class when configured", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{S3StorageClass: "INTELLIGENT_TIERING"}}}
+		putObjectInput := &s3.PutObjectInput{}
+
+		for _, option := range storage.putObjectInputOptions() {
+			option(putObjectInput)
+		}
+
+		if putObjectInput.StorageClass != types.StorageClassIntelligentTiering {
+			t.Errorf("Expected storage class to be INTELLIGENT_TIERING, got %s", putObjectInput.StorageClass)
+		}
+	})
+
+	t.Run("Sets server-side encryption with the KMS key when configured", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{SseAlgorithm: "aws:kms", KmsKeyId: "my-key"}}}
+		putObjectInput := &s3.PutObjectInput{}
+
+		for _, option := range storage.putObjectInputOptions() {
+			option(putObjectInput)
+		}
+
+		if putObjectInput.ServerSideEncryption != types.ServerSideEncryptionAwsKms {
+			t.Errorf("Expected server-side encryption to be aws:kms, got %s", putObjectInput.ServerSideEncryption)
+		}
+		if putObjectInput.SSEKMSKeyId == nil || *putObjectInput.SSEKMSKeyId != "my-key" {
+			t.Errorf("Expected KMS key ID to be my-key, got %v", putObjectInput.SSEKMSKeyId)
+		}
+	})
+
+	t.Run("Doesn't set the ACL when not configured", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{}}
+
+		if len(storage.putObjectInputOptions()) != 0 {
+			t.Error("Expected no PutObjectInput options")
+		}
+	})
+
+	t.Run("Doesn't set the ACL when the bucket has ACLs disabled", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{S3ACL: "bucket-owner-full-control"}}}
+		storage.aclNotSupported.Store(true)
+
+		if len(storage.putObjectInputOptions()) != 0 {
+			t.Error("Expected no PutObjectInput options")
+		}
+	})
+}
+
+func TestUploaderOptions(t *testing.T) {
+	uploadParquet := func(t *testing.T, storage *StorageS3, size int) *fakeS3UploadClient {
+		client := &fakeS3UploadClient{}
+		fileWriter, err := s3v2.NewS3FileWriterWithClient(context.Background(), client, "my_bucket", "public/users/data/00000-0-1.parquet", storage.uploaderOptions())
+		testNoError(t, err)
+
+		_, err = fileWriter.Write(bytes.Repeat([]byte("a"), size))
+		testNoError(t, err)
+		testNoError(t, fileWriter.Close())
+		return client
+	}
+
+	t.Run("Streams a Parquet file larger than a part in parts of the configured size", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{UploadPartSize: 5 * 1024 * 1024, UploadConcurrency: 2}}}
+
+		client := uploadParquet(t, storage, 12*1024*1024)
+
+		if client.putObjectCount != 0 || !slices.Equal(client.partSizes, []int{5 * 1024 * 1024, 5 * 1024 * 1024, 2 * 1024 * 1024}) {
+			t.Errorf("Expected a multipart upload of 3 parts, got %v PutObject(s) and parts %v", client.putObjectCount, client.partSizes)
+		}
+	})
+
+	t.Run("Uploads a Parquet file smaller than a part with a single request", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{UploadPartSize: 5 * 1024 * 1024, UploadConcurrency: 2}}}
+
+		client := uploadParquet(t, storage, 1024)
+
+		if client.putObjectCount != 1 || len(client.partSizes) != 0 {
+			t.Errorf("Expected a single PutObject, got %v PutObject(s) and parts %v", client.putObjectCount, client.partSizes)
+		}
+	})
+
+	t.Run("Keeps the uploader defaults when not configured", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{}}
+
+		if len(storage.uploaderOptions()) != 0 {
+			t.Error("Expected no uploader options")
+		}
+	})
+}
+
+func TestNewS3Client(t *testing.T) {
+	t.Run("Uses a custom endpoint with path-style addressing", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{Region: "us-east-1", S3Endpoint: "http://localhost:9000", S3ForcePathStyle: true}}
+
+		options := newS3Client(config, config.Aws.Region, newAwsCredentialsProvider(config)).Options()
+
+		if options.BaseEndpoint == nil || *options.BaseEndpoint != "http://localhost:9000" {
+			t.Errorf("Expected the base endpoint to be http://localhost:9000, got %v", options.BaseEndpoint)
+		}
+		if !options.UsePathStyle {
+			t.Error("Expected path-style addressing")
+		}
+	})
+
+	t.Run("Defaults to https for a custom endpoint without a scheme", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{Region: "auto", S3Endpoint: "account-id.r2.cloudflarestorage.com"}}
+
+		options := newS3Client(config, config.Aws.Region, newAwsCredentialsProvider(config)).Options()
+
+		if options.BaseEndpoint == nil || *options.BaseEndpoint != "https://account-id.r2.cloudflarestorage.com" {
+			t.Errorf("Expected the base endpoint to be https://account-id.r2.cloudflarestorage.com, got %v", options.BaseEndpoint)
+		}
+		if options.UsePathStyle {
+			t.Error("Expected virtual-hosted-style addressing")
+		}
+	})
+
+	t.Run("Resolves the default AWS endpoint by region", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{Region: "us-west-1", S3Endpoint: DEFAULT_AWS_S3_ENDPOINT}}
+
+		options := newS3Client(config, config.Aws.Region, newAwsCredentialsProvider(config)).Options()
+
+		if options.BaseEndpoint != nil {
+			t.Errorf("Expected no base endpoint, got %v", *options.BaseEndpoint)
+		}
+	})
+}
+
+// Responds to the requests in order with the given status codes and XML bodies, and records the requests and their bodies
+func startTestS3Server(t *testing.T, responses []string, statusCodes []int) (storage *StorageS3, requestBodies *[]string, requestUris *[]string) {
+	requestBodies = &[]string{}
+	requestUris = &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		requestIndex := len(*requestBodies)
+		*requestBodies = append(*requestBodies, string(body))
+		*requestUris = append(*requestUris, request.Method+" "+request.URL.RequestURI())
+		if requestIndex >= len(responses) {
+			t.Errorf("Unexpected request %s %s", request.Method, request.URL.RequestURI())
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/xml")
+		writer.WriteHeader(statusCodes[requestIndex])
+		writer.Write([]byte(responses[requestIndex]))
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{Aws: AwsConfig{Region: "us-east-1", S3Endpoint: server.URL, S3ForcePathStyle: true, S3Bucket: "bucket", AccessKeyId: "key", SecretAccessKey: "secret", MaxRetries: 2}}
+	return NewS3Storage(config), requestBodies, requestUris
+}
+
+func TestAwsRetries(t *testing.T) {
+	emptyListResponse := `<ListBucketResult><Name>bucket</Name><KeyCount>0</KeyCount><IsTruncated>false</IsTruncated></ListBucketResult>`
+	deletedResponse := `<DeleteResult></DeleteResult>`
+	objectsToDelete := []types.ObjectIdentifier{{Key: aws.String("a.parquet")}, {Key: aws.String("b.parquet")}}
+
+	t.Run("Retries throttled requests", func(t *testing.T) {
+		storage, requestBodies, _ := startTestS3Server(t,
+			[]string{`<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`, emptyListResponse},
+			[]int{http.StatusServiceUnavailable, http.StatusOK},
+		)
+
+		_, err := storage.nestedDirectoryPrefixes(storage.defaultBucket(), "iceberg/")
+
+		testNoError(t, err)
+		if len(*requestBodies) != 2 {
+			t.Errorf("Expected the throttled request to be retried once, got %d requests", len(*requestBodies))
+		}
+	})
+
+	t.Run("Doesn't retry access denied errors", func(t *testing.T) {
+		storage, requestBodies, _ := startTestS3Server(t,
+			[]string{`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`},
+			[]int{http.StatusForbidden},
+		)
+
+		_, err := storage.nestedDirectoryPrefixes(storage.defaultBucket(), "iceberg/")
+
+		if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+			t.Errorf("Expected an access denied error, got %v", err)
+		}
+		if len(*requestBodies) != 1 {
+			t.Errorf("Expected a single request, got %d", len(*requestBodies))
+		}
+	})
+
+	t.Run("Deletes the keys that failed with SlowDown again", func(t *testing.T) {
+		storage, requestBodies, _ := startTestS3Server(t,
+			[]string{`<DeleteResult><Error><Key>b.parquet</Key><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error></DeleteResult>`, deletedResponse},
+			[]int{http.StatusOK, http.StatusOK},
+		)
+
+		err := storage.deleteObjects(context.Background(), storage.defaultBucket(), objectsToDelete)
+
+		testNoError(t, err)
+		if len(*requestBodies) != 2 || !strings.Contains((*requestBodies)[1], "b.parquet") || strings.Contains((*requestBodies)[1], "a.parquet") {
+			t.Errorf("Expected only the failed key to be deleted again, got %v", *requestBodies)
+		}
+	})
+
+	t.Run("Returns the keys that failed with a non-retryable error", func(t *testing.T) {
+		storage, requestBodies, _ := startTestS3Server(t,
+			[]string{`<DeleteResult><Error><Key>b.parquet</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error></DeleteResult>`},
+			[]int{http.StatusOK},
+		)
+
+		err := storage.deleteObjects(context.Background(), storage.defaultBucket(), objectsToDelete)
+
+		if err == nil || err.Error() != "object b.parquet: AccessDenied Access Denied" {
+			t.Errorf("Expected the failed key to be returned, got %v", err)
+		}
+		if len(*requestBodies) != 1 {
+			t.Errorf("Expected a single request, got %d", len(*requestBodies))
+		}
+	})
+}
+
+func TestListObjectsPagination(t *testing.T) {
+	t.Run("Lists the directories of all pages", func(t *testing.T) {
+		storage, _, requestUris := startTestS3Server(t,
+			[]string{
+				`<ListBucketResult><CommonPrefixes><Prefix>iceberg/public/</Prefix></CommonPrefixes><IsTruncated>true</IsTruncated><NextContinuationToken>page-2</NextContinuationToken></ListBucketResult>`,
+				`<ListBucketResult><CommonPrefixes><Prefix>iceberg/sales/</Prefix></CommonPrefixes><IsTruncated>false</IsTruncated></ListBucketResult>`,
+			},
+			[]int{http.StatusOK, http.StatusOK},
+		)
+
+		dirs, err := storage.nestedDirectoryPrefixes(storage.defaultBucket(), "iceberg/")
+
+		testNoError(t, err)
+		if !slices.Equal(dirs, []string{"iceberg/public/", "iceberg/sales/"}) {
+			t.Errorf("Expected the directories of both pages, got %v", dirs)
+		}
+		if len(*requestUris) != 2 || !strings.Contains((*requestUris)[1], "continuation-token=page-2") {
+			t.Errorf("Expected the second page to be requested with the continuation token, got %v", *requestUris)
+		}
+	})
+
+	t.Run("Deletes the objects of all pages", func(t *testing.T) {
+		storage, requestBodies, requestUris := startTestS3Server(t,
+			[]string{
+				`<ListBucketResult><Contents><Key>iceberg/public/users/data/a.parquet</Key></Contents><IsTruncated>true</IsTruncated><NextContinuationToken>page-2</NextContinuationToken></ListBucketResult>`,
+				`<DeleteResult></DeleteResult>`,
+				`<ListBucketResult><Contents><Key>iceberg/public/users/data/b.parquet</Key></Contents><IsTruncated>false</IsTruncated></ListBucketResult>`,
+				`<DeleteResult></DeleteResult>`,
+			},
+			[]int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK},
+		)
+
+		err := storage.DeleteSchemaTable(IcebergSchemaTable{Schema: "public", Table: "users"})
+
+		testNoError(t, err)
+		if len(*requestUris) != 4 || !strings.Contains((*requestUris)[2], "continuation-token=page-2") {
+			t.Errorf("Expected the second page to be listed with the continuation token, got %v", *requestUris)
+		}
+		if len(*requestBodies) != 4 || !strings.Contains((*requestBodies)[1], "a.parquet") || !strings.Contains((*requestBodies)[3], "b.parquet") {
+			t.Errorf("Expected the objects of both pages to be deleted, got %v", *requestBodies)
+		}
+	})
+}
+
+func TestNewAwsCredentialsProvider(t *testing.T) {
+	t.Run("Uses the access keys", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{Region: "us-west-1", AccessKeyId: "access-key-id", SecretAccessKey: "secret-access-key"}}
+
+		awsCredentials, err := newAwsCredentialsProvider(config).Retrieve(context.Background())
+
+		testNoError(t, err)
+		if awsCredentials.AccessKeyID != "access-key-id" || awsCredentials.SecretAccessKey != "secret-access-key" {
+			t.Errorf("Expected the access keys, got %v", awsCredentials)
+		}
+	})
+
+	t.Run("Caches the credentials of the assumed role until they are about to expire", func(t *testing.T) {
+		config := &Config{Aws: AwsConfig{
+			Region:          "us-west-1",
+			AccessKeyId:     "access-key-id",
+			SecretAccessKey: "secret-access-key",
+			AssumeRoleArn:   "arn:aws:iam::123456789012:role/bemidb",
+			ExternalId:      "external-id",
+		}}
+
+		awsCredentialsCache, ok := newAwsCredentialsProvider(config).(*aws.CredentialsCache)
+
+		if !ok {
+			t.Fatalf("Expected the assumed role credentials to be cached")
+		}
+		if !awsCredentialsCache.IsCredentialsProvider(&stscreds.AssumeRoleProvider{}) {
+			t.Errorf("Expected the cached credentials to be assumed with STS")
+		}
+	})
+}
+
+func TestTransitionCopyObjectInput(t *testing.T) {
+	t.Run("Copies the object onto itself with the new storage class", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{S3Bucket: "bucket", S3ACL: "bucket-owner-full-control"}}}
+
+		copyObjectInput := storage.transitionCopyObjectInput("iceberg/public/users/data/file.parquet", "STANDARD_IA")
+
+		if *copyObjectInput.Key != "iceberg/public/users/data/file.parquet" || *copyObjectInput.CopySource != "bucket/iceberg/public/users/data/file.parquet" {
+			t.Errorf("Expected the object to be copied onto itself, got %s from %s", *copyObjectInput.Key, *copyObjectInput.CopySource)
+		}
+		if copyObjectInput.StorageClass != types.StorageClassStandardIa {
+			t.Errorf("Expected storage class to be STANDARD_IA, got %s", copyObjectInput.StorageClass)
+		}
+		if copyObjectInput.ACL != "bucket-owner-full-control" {
+			t.Errorf("Expected ACL to be kept, got %s", copyObjectInput.ACL)
+		}
+	})
+
+	t.Run("Encrypts the copy with the configured server-side encryption", func(t *testing.T) {
+		storage := &StorageS3{config: &Config{Aws: AwsConfig{S3Bucket: "bucket", SseAlgorithm: "AES256"}}}
+
+		copyObjectInput := storage.transitionCopyObjectInput("iceberg/public/users/data/file.parquet", "STANDARD_IA")
+
+		if copyObjectInput.ServerSideEncryption != types.ServerSideEncryptionAes256 {
+			t.Errorf("Expected server-side encryption to be AES256, got %s", copyObjectInput.ServerSideEncryption)
+		}
+		if copyObjectInput.SSEKMSKeyId != nil {
+			t.Errorf("Expected no KMS key ID, got %s", *copyObjectInput.SSEKMSKeyId)
+		}
+	})
+}
+
+func TestMultipartCopyObject(t *testing.T) {
+	createResponse := `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>file.parquet</Key><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`
+	copyPartResponse := `<CopyPartResult><ETag>"etag"</ETag></CopyPartResult>`
+	headObjectResponse := &s3.HeadObjectOutput{ContentLength: aws.Int64(25)}
+
+	t.Run("Copies the object onto itself in parts", func(t *testing.T) {
+		storage, requestBodies, requestUris := startTestS3Server(t,
+			[]string{createResponse, copyPartResponse, copyPartResponse, copyPartResponse, `<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`},
+			[]int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK},
+		)
+
+		err := storage.multipartCopyObject(storage.transitionCopyObjectInput("file.parquet", "STANDARD_IA"), headObjectResponse, 10)
+
+		testNoError(t, err)
+		expectedRequestUris := []string{
+			"POST /bucket/file.parquet?uploads=",
+			"PUT /bucket/file.parquet?partNumber=1&uploadId=upload-id&x-id=UploadPartCopy",
+			"PUT /bucket/file.parquet?partNumber=2&uploadId=upload-id&x-id=UploadPartCopy",
+			"PUT /bucket/file.parquet?partNumber=3&uploadId=upload-id&x-id=UploadPartCopy",
+			"POST /bucket/file.parquet?uploadId=upload-id",
+		}
+		if !slices.Equal(*requestUris, expectedRequestUris) {
+			t.Errorf("Expected requests %v, got %v", expectedRequestUris, *requestUris)
+		}
+		if strings.Count((*requestBodies)[4], "<Part>") != 3 {
+			t.Errorf("Expected 3 copied parts to be completed, got %s", (*requestBodies)[4])
+		}
+	})
+
+	t.Run("Aborts the multipart copy if a part fails", func(t *testing.T) {
+		storage, _, requestUris := startTestS3Server(t,
+			[]string{createResponse, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`, ""},
+			[]int{http.StatusOK, http.StatusForbidden, http.StatusNoContent},
+		)
+
+		err := storage.multipartCopyObject(storage.transitionCopyObjectInput("file.parquet", "STANDARD_IA"), headObjectResponse, 10)
+
+		if err == nil || !strings.Contains(err.Error(), "Failed to copy part 1") {
+			t.Errorf("Expected the part copy error, got %v", err)
+		}
+		if (*requestUris)[2] != "DELETE /bucket/file.parquet?uploadId=upload-id&x-id=AbortMultipartUpload" {
+			t.Errorf("Expected the multipart copy to be aborted, got %v", *requestUris)
+		}
+	})
+}
+
+func TestTableBucket(t *testing.T) {
+	config := &Config{
+		StoragePath: "iceberg",
+		Aws: AwsConfig{
+			Region:   "us-west-1",
+			S3Bucket: "hot-bucket",
+			S3TableBuckets: map[string]AwsS3Bucket{
+				"public.events": {Name: "cold-bucket", Region: "eu-west-1"},
+			},
+		},
+	}
+
+	t.Run("Resolves the bucket of each table", func(t *testing.T) {
+		storage := &StorageS3{config: config}
+
+		usersPath := storage.metadataFilePath(IcebergSchemaTable{Schema: "public", Table: "users"}, 1)
+		if usersPath != "s3://hot-bucket/iceberg/public/users/metadata/v1.metadata.json" {
+			t.Errorf("Expected public.users to be read from the default bucket, got %s", usersPath)
+		}
+
+		eventsPath := storage.metadataFilePath(IcebergSchemaTable{Schema: "public", Table: "events"}, 1)
+		if eventsPath != "s3://cold-bucket/iceberg/public/events/metadata/v1.metadata.json" {
+			t.Errorf("Expected public.events to be read from its bucket, got %s", eventsPath)
+		}
+	})
+
+	t.Run("Resolves the bucket of written files from their keys", func(t *testing.T) {
+		storage := &StorageS3{config: config}
+
+		dataDirPath := storage.CreateDataDir(IcebergSchemaTable{Schema: "public", Table: "events"})
+		if storage.keyBucket(dataDirPath+"/00000-0-file.parquet") != (AwsS3Bucket{Name: "cold-bucket", Region: "eu-west-1"}) {
+			t.Errorf("Expected data files of public.events to be written to its bucket")
+		}
+
+		metadataDirPath := storage.CreateMetadataDir(IcebergSchemaTable{Schema: "public", Table: "users"})
+		if storage.keyBucket(metadataDirPath+"/v1.metadata.json") != storage.defaultBucket() {
+			t.Errorf("Expected metadata files of public.users to be written to the default bucket")
+		}
+
+		copyObjectInput := storage.transitionCopyObjectInput("iceberg/public/events/data/file.parquet", "STANDARD_IA")
+		if *copyObjectInput.Bucket != "cold-bucket" || *copyObjectInput.CopySource != "cold-bucket/iceberg/public/events/data/file.parquet" {
+			t.Errorf("Expected the object to be copied within its bucket, got %s from %s", *copyObjectInput.Bucket, *copyObjectInput.CopySource)
+		}
+	})
+
+	t.Run("Joins tables stored in different buckets", func(t *testing.T) {
+		queryConfig := loadTestConfig()
+		queryConfig.StoragePath = config.StoragePath
+		queryConfig.Aws = config.Aws
+		storage := &testSchemaTablesStorage{
+			StorageS3: &StorageS3{config: queryConfig},
+			schemaTables: []IcebergSchemaTable{
+				{Schema: "public", Table: "users"},
+				{Schema: "public", Table: "events"},
+			},
+		}
+		queryHandler := NewQueryHandler(queryConfig, NewDuckdb(queryConfig), &IcebergReader{config: queryConfig, storage: storage})
+
+		messages, err := queryHandler.HandleQuery("EXPLAIN (REMAP) SELECT users.id, events.name FROM users JOIN events ON events.user_id = users.id")
+
+		testNoError(t, err)
+		remappedQuery := string(messages[1].(*pgproto3.DataRow).Values[0])
+		if !strings.Contains(remappedQuery, "iceberg_scan('s3://hot-bucket/iceberg/public/users/metadata/v1.metadata.json'") {
+			t.Errorf("Expected users to be read from the default bucket, got %s", remappedQuery)
+		}
+		if !strings.Contains(remappedQuery, "iceberg_scan('s3://cold-bucket/iceberg/public/events/metadata/v1.metadata.json'") {
+			t.Errorf("Expected events to be read from its bucket, got %s", remappedQuery)
+		}
+	})
+}
+
+// Lists the given tables without reading them from S3
+type testSchemaTablesStorage struct {
+	*StorageS3
+	schemaTables []IcebergSchemaTable
+}
+
+func (storage *testSchemaTablesStorage) IcebergSchemaTables() ([]IcebergSchemaTable, error) {
+	return storage.schemaTables, nil
+}
+
+func (storage *testSchemaTablesStorage) IcebergSchemas() (icebergSchemas []string, err error) {
+	for _, schemaTable := range storage.schemaTables {
+		if !slices.Contains(icebergSchemas, schemaTable.Schema) {
+			icebergSchemas = append(icebergSchemas, schemaTable.Schema)
+		}
+	}
+	return icebergSchemas, nil
+}
+
+func (storage *testSchemaTablesStorage) IcebergMetadataFilePath(icebergSchemaTable IcebergSchemaTable) string {
+	return storage.metadataFilePath(icebergSchemaTable, 1)
+}
+
+func (storage *testSchemaTablesStorage) IcebergSchemaFields(icebergSchemaTable IcebergSchemaTable, snapshotId int64) ([]IcebergSchemaField, error) {
+	return nil, nil
+}
+
+type fakeS3UploadClient struct {
+	s3v2.S3API
+	mutex          sync.Mutex
+	putObjectCount int
+	partSizes      []int
+}
+
+func (client *fakeS3UploadClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.putObjectCount++
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (client *fakeS3UploadClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (client *fakeS3UploadClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	content, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for len(client.partSizes) < int(*params.PartNumber) {
+		client.partSizes = append(client.partSizes, 0)
+	}
+	client.partSizes[*params.PartNumber-1] = len(content)
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func (client *fakeS3UploadClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func TestS3RequestCache(t *testing.T) {
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+		{ColumnName: "name", DataType: "text", UdtName: "text", IsNullable: "YES", OrdinalPosition: "2", Namespace: "pg_catalog"},
+	}
+	schemaTables := []IcebergSchemaTable{{Schema: "public", Table: "users"}, {Schema: "public", Table: "orders"}, {Schema: "sales", Table: "invoices"}}
+
+	// Writes the tables twice like 2 syncs, and lists them after each like deleting old tables
+	syncTables := func(storage *StorageS3) {
+		icebergWriter := &IcebergWriter{config: storage.config, storage: storage}
+		for range 2 {
+			for _, schemaTable := range schemaTables {
+				testWriteS3Table(icebergWriter, schemaTable, pgSchemaColumns, [][]string{{"1", "Alice"}})
+			}
+			_, err := storage.IcebergSchemas()
+			testNoError(t, err)
+			_, err = storage.IcebergSchemaTables()
+			testNoError(t, err)
+		}
+	}
+
+	t.Run("Sends fewer HeadObject, GetObject and ListObjectsV2 requests when syncing multiple tables", func(t *testing.T) {
+		config, _, requestUris := startTestS3ObjectServer(t)
+		syncTables(NewS3Storage(config))
+		uncachedRequestCounts := testS3RequestCounts(*requestUris)
+
+		config, _, requestUris = startTestS3ObjectServer(t)
+		storage := NewS3Storage(config)
+		storage.SetRequestCache(NewS3RequestCache())
+		syncTables(storage)
+		cachedRequestCounts := testS3RequestCounts(*requestUris)
+
+		for _, request := range []string{"HEAD", "GET", "LIST"} {
+			if cachedRequestCounts[request] >= uncachedRequestCounts[request] {
+				t.Errorf("Expected fewer %s requests with the cache, got %d (without: %d)", request, cachedRequestCounts[request], uncachedRequestCounts[request])
+			}
+		}
+		if cachedRequestCounts["PUT"] != uncachedRequestCounts["PUT"] {
+			t.Errorf("Expected the same uploads with the cache, got %d (without: %d)", cachedRequestCounts["PUT"], uncachedRequestCounts["PUT"])
+		}
+	})
+
+	t.Run("Lists the tables written and deleted during the sync run", func(t *testing.T) {
+		config, _, _ := startTestS3ObjectServer(t)
+		storage := NewS3Storage(config)
+		storage.SetRequestCache(NewS3RequestCache())
+		icebergWriter := &IcebergWriter{config: config, storage: storage}
+		testWriteS3Table(icebergWriter, schemaTables[0], pgSchemaColumns, [][]string{{"1", "Alice"}})
+		_, err := storage.IcebergSchemaTables()
+		testNoError(t, err)
+
+		testWriteS3Table(icebergWriter, schemaTables[2], pgSchemaColumns, [][]string{{"1", "Alice"}})
+		icebergSchemaTables, err := storage.IcebergSchemaTables()
+		testNoError(t, err)
+		if !slices.Equal(icebergSchemaTables, []IcebergSchemaTable{schemaTables[0], schemaTables[2]}) {
+			t.Errorf("Expected the written tables to be listed, got %v", icebergSchemaTables)
+		}
+
+		err = storage.DeleteSchema("sales")
+		testNoError(t, err)
+		icebergSchemas, err := storage.IcebergSchemas()
+		testNoError(t, err)
+		if !slices.Equal(icebergSchemas, []string{"public"}) {
+			t.Errorf("Expected the deleted schema not to be listed, got %v", icebergSchemas)
+		}
+	})
+
+	t.Run("Commits after the metadata version committed by another writer", func(t *testing.T) {
+		config, _, _ := startTestS3ObjectServer(t)
+		storage := NewS3Storage(config)
+		storage.SetRequestCache(NewS3RequestCache())
+		icebergWriter := &IcebergWriter{config: config, storage: storage}
+		otherStorage := NewS3Storage(config)
+		testWriteS3Table(icebergWriter, schemaTables[0], pgSchemaColumns, [][]string{{"1", "Alice"}})
+
+		testWriteS3Table(&IcebergWriter{config: config, storage: otherStorage}, schemaTables[0], pgSchemaColumns, [][]string{{"2", "Bob"}})
+		testWriteS3Table(icebergWriter, schemaTables[0], pgSchemaColumns, [][]string{{"3", "Carol"}})
+
+		icebergSnapshots, err := otherStorage.IcebergSnapshots(schemaTables[0])
+		testNoError(t, err)
+		if len(icebergSnapshots) != 3 {
+			t.Errorf("Expected a snapshot per write, got %d", len(icebergSnapshots))
+		}
+		if otherStorage.IcebergMetadataFilePath(schemaTables[0]) != "s3://bucket/iceberg/public/users/metadata/v3.metadata.json" {
+			t.Errorf("Expected the third metadata version, got %s", otherStorage.IcebergMetadataFilePath(schemaTables[0]))
+		}
+	})
+}
+
+func TestS3AclFallback(t *testing.T) {
+	pgSchemaColumns := []PgSchemaColumn{
+		{ColumnName: "id", DataType: "integer", UdtName: "int4", IsNullable: "NO", OrdinalPosition: "1", NumericPrecision: "32", Namespace: "pg_catalog"},
+	}
+	schemaTable := IcebergSchemaTable{Schema: "public", Table: "users"}
+
+	t.Run("Writes the Parquet, manifest and metadata files without the ACL if the bucket has ACLs disabled", func(t *testing.T) {
+		config, objects, requestUris := startTestS3ObjectServer(t)
+		config.Aws.S3ACL = "bucket-owner-full-control"
+		storage := NewS3Storage(config)
+
+		testWriteS3Table(&IcebergWriter{config: config, storage: storage}, schemaTable, pgSchemaColumns, [][]string{{"1"}})
+
+		icebergTableStats, err := storage.IcebergTableStats(schemaTable)
+		testNoError(t, err)
+		if icebergTableStats.RecordCount != 1 {
+			t.Errorf("Expected the written record, got %v", icebergTableStats)
+		}
+		if _, ok := objects.Load("iceberg/public/users/metadata/v1.metadata.json"); !ok {
+			t.Error("Expected the metadata file to be uploaded")
+		}
+		if !storage.aclNotSupported.Load() {
+			t.Error("Expected the ACL to be left out of the next uploads")
+		}
+		putCounts := map[string]int{}
+		for _, requestUri := range *requestUris {
+			if strings.HasPrefix(requestUri, "PUT ") {
+				putCounts[requestUri]++
+			}
+		}
+		var retriedUris []string
+		for requestUri, putCount := range putCounts {
+			if putCount > 1 {
+				retriedUris = append(retriedUris, requestUri)
+			}
+		}
+		if len(retriedUris) != 1 || !strings.Contains(retriedUris[0], "/data/") {
+			t.Errorf("Expected only the streamed Parquet file to be sent again, got %v", retriedUris)
+		}
+	})
+}
+
+func testWriteS3Table(icebergWriter *IcebergWriter, schemaTable IcebergSchemaTable, pgSchemaColumns []PgSchemaColumn, rows [][]string) {
+	loaded := false
+	icebergWriter.Write(schemaTable, pgSchemaColumns, func() [][]string {
+		if loaded {
+			return [][]string{}
+		}
+		loaded = true
+		return rows
+	})
+}
+
+// Number of requests by method, with ListObjectsV2 requests counted as LIST
+func testS3RequestCounts(requestUris []string) map[string]int {
+	requestCounts := map[string]int{}
+	for _, requestUri := range requestUris {
+		method, uri, _ := strings.Cut(requestUri, " ")
+		if strings.Contains(uri, "list-type=2") {
+			method = "LIST"
+		}
+		requestCounts[method]++
+	}
+	return requestCounts
+}
+
+// S3 API of a single "bucket" with path-style requests, and records the requests as "METHOD URI"
+func startTestS3ObjectServer(t *testing.T) (config *Config, objects *sync.Map, requestUris *[]string) {
+	objects = &sync.Map{}
+	requestUris = &[]string{}
+	var requestUrisMutex sync.Mutex
+
+	writeError := func(writer http.ResponseWriter, statusCode int, code string) {
+		writer.Header().Set("Content-Type", "application/xml")
+		writer.WriteHeader(statusCode)
+		fmt.Fprintf(writer, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestUrisMutex.Lock()
+		*requestUris = append(*requestUris, request.Method+" "+request.URL.RequestURI())
+		requestUrisMutex.Unlock()
+
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(request.URL.Path, "/"), "/")
+		if bucket != "bucket" {
+			writeError(writer, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+		query := request.URL.Query()
+
+		switch {
+		case request.Method == http.MethodGet && query.Get("list-type") == "2":
+			prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+			var keys, commonPrefixes []string
+			objects.Range(func(objectKey, value interface{}) bool {
+				if !strings.HasPrefix(objectKey.(string), prefix) {
+					return true
+				}
+				if index := strings.Index(objectKey.(string)[len(prefix):], delimiter); delimiter != "" && index != -1 {
+					if commonPrefix := objectKey.(string)[:len(prefix)+index+1]; !slices.Contains(commonPrefixes, commonPrefix) {
+						commonPrefixes = append(commonPrefixes, commonPrefix)
+					}
+				} else {
+					keys = append(keys, objectKey.(string))
+				}
+				return true
+			})
+			slices.Sort(keys)
+			slices.Sort(commonPrefixes)
+
+			var response bytes.Buffer
+			response.WriteString("<ListBucketResult><Name>bucket</Name>")
+			for _, objectKey := range keys {
+				content, _ := objects.Load(objectKey)
+				fmt.Fprintf(&response, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", objectKey, len(content.([]byte)))
+			}
+			for _, commonPrefix := range commonPrefixes {
+				fmt.Fprintf(&response, "<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>", commonPrefix)
+			}
+			fmt.Fprintf(&response, "<KeyCount>%d</KeyCount><IsTruncated>false</IsTruncated></ListBucketResult>", len(keys)+len(commonPrefixes))
+			writer.Header().Set("Content-Type", "application/xml")
+			writer.Write(response.Bytes())
+		case request.Method == http.MethodGet || request.Method == http.MethodHead:
+			value, ok := objects.Load(key)
+			if !ok {
+				writeError(writer, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			content := value.([]byte)
+			writer.Header().Set("ETag", `"etag"`)
+			if request.Method == http.MethodHead {
+				writer.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				return
+			}
+			var start, end int
+			if _, err := fmt.Sscanf(request.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+				end = min(end, len(content)-1)
+				writer.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+				writer.WriteHeader(http.StatusPartialContent)
+				writer.Write(content[start : end+1])
+				return
+			}
+			writer.Write(content)
+		case request.Method == http.MethodPut:
+			content, _ := io.ReadAll(request.Body)
+			// Like a bucket with ACLs disabled (Object Ownership enforced)
+			if request.Header.Get("x-amz-acl") != "" {
+				writeError(writer, http.StatusBadRequest, "AccessControlListNotSupported")
+				return
+			}
+			if request.Header.Get("If-None-Match") == "*" {
+				if _, loaded := objects.LoadOrStore(key, content); loaded {
+					writeError(writer, http.StatusPreconditionFailed, "PreconditionFailed")
+					return
+				}
+			} else {
+				objects.Store(key, content)
+			}
+			writer.Header().Set("ETag", `"etag"`)
+		case request.Method == http.MethodPost && query.Has("delete"):
+			var deleteRequest struct {
+				Objects []struct {
+					Key string `xml:"Key"`
+				} `xml:"Object"`
+			}
+			xml.NewDecoder(request.Body).Decode(&deleteRequest)
+			for _, object := range deleteRequest.Objects {
+				objects.Delete(object.Key)
+			}
+			writer.Header().Set("Content-Type", "application/xml")
+			writer.Write([]byte("<DeleteResult></DeleteResult>"))
+		default:
+			writeError(writer, http.StatusNotImplemented, "NotImplemented")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config = loadTestConfig()
+	config.StorageType = STORAGE_TYPE_S3
+	config.StoragePath = "iceberg"
+	config.Aws = AwsConfig{Region: "us-east-1", S3Endpoint: server.URL, S3ForcePathStyle: true, S3Bucket: "bucket", AccessKeyId: "key", SecretAccessKey: "secret", MaxRetries: 2, UploadPartSize: 5 * 1024 * 1024}
+	return config, objects, requestUris
+}
@@ -0,0 +1,638 @@
+package bemidb
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	BATCH_SIZE                    = 10000
+	PING_INTERVAL_BETWEEN_BATCHES = 20
+
+	// Long-running transactions prevent VACUUM from removing dead tuples on the source database
+	LONG_TRANSACTION_WARNING_DURATION = 30 * time.Minute
+)
+
+// Subset of *pgx.Conn used to share a consistent transaction snapshot
+type PgSnapshotConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Type of a query result column
+type PgResultType struct {
+	UdtName        string
+	Namespace      string
+	ElementUdtName string // Set if the type is an array
+	EnumLabels     []string
+}
+
+var _syncsInProgress atomic.Int32
+
+func IsSyncInProgress() bool {
+	return _syncsInProgress.Load() > 0
+}
+
+type Syncer struct {
+	config        *Config
+	icebergWriter *IcebergWriter
+	icebergReader *IcebergReader
+	// Optional hooks applied to each synced table's schema and rows
+	rowTransformers []RowTransformer
+}
+
+func NewSyncer(config *Config) *Syncer {
+	icebergWriter := NewIcebergWriter(config)
+	icebergReader := NewIcebergReader(config)
+	return &Syncer{config: config, icebergWriter: icebergWriter, icebergReader: icebergReader}
+}
+
+func (syncer *Syncer) AddRowTransformer(rowTransformer RowTransformer) {
+	syncer.rowTransformers = append(syncer.rowTransformers, rowTransformer)
+}
+
+func (syncer *Syncer) SyncFromPostgres() {
+	if syncer.config.Pg.DatabaseUrl == "" {
+		panic("Missing PostgreSQL database URL")
+	}
+
+	_syncsInProgress.Add(1)
+	defer _syncsInProgress.Add(-1)
+	defer syncer.cacheStorageRequests()()
+
+	ctx := context.Background()
+	databaseUrl := UrlEncodePassword(syncer.config.Pg.DatabaseUrl)
+
+	conn, err := pgx.Connect(ctx, databaseUrl)
+	PanicIfError(err)
+	defer conn.Close(ctx)
+
+	// All tables are read from one consistent snapshot
+	snapshotId := syncer.exportPgSnapshot(conn)
+	LogDebug(syncer.config, "Exported snapshot:", snapshotId)
+
+	pgSchemaTables := []PgSchemaTable{}
+	for _, schema := range syncer.listPgSchemas(conn) {
+		for _, pgSchemaTable := range syncer.listPgSchemaTables(conn, schema) {
+			if syncer.shouldSyncTable(pgSchemaTable) {
+				pgSchemaTables = append(pgSchemaTables, pgSchemaTable)
+			}
+		}
+	}
+
+	pool := NewPgConnPool(syncer.config, syncer.pgSourcePoolSize(conn), func(ctx context.Context) (PgPoolConn, error) {
+		workerConn, err := pgx.Connect(ctx, databaseUrl)
+		if err != nil {
+			return nil, err
+		}
+		syncer.importPgSnapshot(workerConn, snapshotId)
+		return workerConn, nil
+	})
+	defer pool.Close(ctx)
+
+	failedPgSchemaTables := syncer.syncFromPgTables(pool, pgSchemaTables, func(conn PgPoolConn, pgSchemaTable PgSchemaTable) {
+		syncer.syncFromPgTable(conn.(*pgx.Conn), pgSchemaTable)
+	})
+
+	if syncer.config.Pg.SchemaPrefix == "" {
+		syncer.deleteOldIcebergSchemaTables(pgSchemaTables)
+	}
+
+	syncer.reportSkippedParquetBatches()
+
+	if len(failedPgSchemaTables) > 0 {
+		panic(fmt.Sprintf("Failed to sync %d table(s): %v", len(failedPgSchemaTables), failedPgSchemaTables))
+	}
+}
+
+// Shares an S3 request cache between the writer and the reader for the duration of the sync run, see S3RequestCache
+func (syncer *Syncer) cacheStorageRequests() (stopCaching func()) {
+	requestCache := NewS3RequestCache()
+	var s3Storages []*StorageS3
+	for _, storage := range []Storage{syncer.icebergWriter.storage, syncer.icebergReader.storage} {
+		if s3Storage, ok := storage.(*StorageS3); ok {
+			s3Storage.SetRequestCache(requestCache)
+			s3Storages = append(s3Storages, s3Storage)
+		}
+	}
+
+	return func() {
+		for _, s3Storage := range s3Storages {
+			s3Storage.SetRequestCache(nil)
+		}
+	}
+}
+
+// Syncs tables in parallel with up to MaxConcurrency workers sharing the pool connections.
+// A table that fails is logged and returned without stopping the other tables, its connection is discarded since its transaction may be aborted
+func (syncer *Syncer) syncFromPgTables(pool *PgConnPool, pgSchemaTables []PgSchemaTable, syncPgTable func(conn PgPoolConn, pgSchemaTable PgSchemaTable)) (failedPgSchemaTables []PgSchemaTable) {
+	ctx := context.Background()
+	startedAt := time.Now()
+	var warnedAboutLongTransaction atomic.Bool
+	var workerPanic atomic.Value
+	var failedPgSchemaTablesMutex sync.Mutex
+
+	pgSchemaTablesChan := make(chan PgSchemaTable, len(pgSchemaTables))
+	for _, pgSchemaTable := range pgSchemaTables {
+		pgSchemaTablesChan <- pgSchemaTable
+	}
+	close(pgSchemaTablesChan)
+
+	// Returns whether the table was synced
+	syncWithConn := func(conn PgPoolConn, pgSchemaTable PgSchemaTable) (synced bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				LogError(syncer.config, "Failed to sync "+pgSchemaTable.String()+":", r)
+				failedPgSchemaTablesMutex.Lock()
+				failedPgSchemaTables = append(failedPgSchemaTables, pgSchemaTable)
+				failedPgSchemaTablesMutex.Unlock()
+			}
+		}()
+		syncPgTable(conn, pgSchemaTable)
+		return true
+	}
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < min(pool.Size(), syncer.config.MaxConcurrency); i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			// Connection failures stop the other workers after their current table and are re-raised once they are done
+			defer func() {
+				if r := recover(); r != nil {
+					workerPanic.CompareAndSwap(nil, r)
+				}
+			}()
+
+			for pgSchemaTable := range pgSchemaTablesChan {
+				if workerPanic.Load() != nil {
+					return
+				}
+
+				conn, err := pool.Acquire(ctx)
+				PanicIfError(err)
+				if syncWithConn(conn, pgSchemaTable) {
+					pool.Release(conn)
+				} else {
+					pool.Discard(conn)
+				}
+
+				if time.Since(startedAt) > LONG_TRANSACTION_WARNING_DURATION && warnedAboutLongTransaction.CompareAndSwap(false, true) {
+					LogWarn(syncer.config, "Sync transaction has been open for more than", LONG_TRANSACTION_WARNING_DURATION, "which may cause table bloat on the source database")
+				}
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	if r := workerPanic.Load(); r != nil {
+		panic(r)
+	}
+
+	return failedPgSchemaTables
+}
+
+// Caps the configured pool size at the connections the source database has left for non-superusers
+func (syncer *Syncer) pgSourcePoolSize(conn PgSnapshotConn) int {
+	var availableConnections int
+	err := conn.QueryRow(
+		context.Background(),
+		"SELECT current_setting('max_connections')::int - current_setting('superuser_reserved_connections')::int - (SELECT COUNT(*) FROM pg_stat_activity WHERE backend_type = 'client backend')::int",
+	).Scan(&availableConnections)
+	PanicIfError(err)
+
+	poolSize := syncer.config.Pg.SyncSourcePoolSize
+	if poolSize > availableConnections {
+		poolSize = max(availableConnections, 1)
+		LogWarn(syncer.config, "Source database has", availableConnections, "connection(s) available, reducing the source pool size from", syncer.config.Pg.SyncSourcePoolSize, "to", poolSize)
+	}
+	return poolSize
+}
+
+// Opens a read-only transaction and exports its snapshot so that other connections can read the same data
+func (syncer *Syncer) exportPgSnapshot(conn PgSnapshotConn) (snapshotId string) {
+	ctx := context.Background()
+
+	_, err := conn.Exec(ctx, "BEGIN TRANSACTION ISOLATION LEVEL SERIALIZABLE READ ONLY DEFERRABLE")
+	PanicIfError(err)
+
+	err = conn.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snapshotId)
+	PanicIfError(err)
+
+	return snapshotId
+}
+
+// Opens a read-only transaction on another connection using a snapshot exported by exportPgSnapshot
+func (syncer *Syncer) importPgSnapshot(conn PgSnapshotConn, snapshotId string) {
+	ctx := context.Background()
+
+	_, err := conn.Exec(ctx, "BEGIN TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY")
+	PanicIfError(err)
+
+	_, err = conn.Exec(ctx, "SET TRANSACTION SNAPSHOT '"+strings.ReplaceAll(snapshotId, "'", "''")+"'")
+	PanicIfError(err)
+}
+
+func (syncer *Syncer) shouldSyncTable(schemaTable PgSchemaTable) bool {
+	tableId := fmt.Sprintf("%s.%s", schemaTable.Schema, schemaTable.Table)
+
+	// Partitions are synced through their partitioned table to avoid reading the same rows twice,
+	// unless the filters leave out the partitioned table and all of its ancestors
+	for _, partitionedTable := range schemaTable.PartitionedTableAncestors {
+		if IsIncludedPgSchemaTable(syncer.config, partitionedTable) {
+			return false
+		}
+	}
+
+	if schemaTable.View {
+		return syncer.config.Pg.IncludeViews != nil && syncer.config.Pg.IncludeViews.Contains(tableId)
+	}
+
+	return IsIncludedPgSchemaTable(syncer.config, schemaTable)
+}
+
+// Whether the include and exclude options for schemas and tables allow the table, which is also checked before
+// querying an unsynced table via the source database
+func IsIncludedPgSchemaTable(config *Config, schemaTable PgSchemaTable) bool {
+	tableId := fmt.Sprintf("%s.%s", schemaTable.Schema, schemaTable.Table)
+
+	if config.Pg.IncludeSchemas != nil {
+		if !config.Pg.IncludeSchemas.Contains(schemaTable.Schema) {
+			return false
+		}
+	} else if config.Pg.ExcludeSchemas != nil {
+		if config.Pg.ExcludeSchemas.Contains(schemaTable.Schema) {
+			return false
+		}
+	}
+
+	if config.Pg.IncludeTables != nil {
+		return config.Pg.IncludeTables.Contains(tableId)
+	}
+
+	if config.Pg.ExcludeTables != nil {
+		return !config.Pg.ExcludeTables.Contains(tableId)
+	}
+
+	return true
+}
+
+func (syncer *Syncer) listPgSchemas(conn *pgx.Conn) []string {
+	var schemas []string
+
+	schemasRows, err := conn.Query(
+		context.Background(),
+		"SELECT schema_name FROM information_schema.schemata WHERE schema_name NOT IN ('pg_catalog', 'pg_toast', 'information_schema')",
+	)
+	PanicIfError(err)
+	defer schemasRows.Close()
+
+	for schemasRows.Next() {
+		var schema string
+		err = schemasRows.Scan(&schema)
+		PanicIfError(err)
+		schemas = append(schemas, schema)
+	}
+
+	return schemas
+}
+
+// Materialized views created WITH NO DATA can't be read until they are refreshed, so they are left out
+func (syncer *Syncer) listPgSchemaTables(conn *pgx.Conn, schema string) []PgSchemaTable {
+	var pgSchemaTables []PgSchemaTable
+
+	tablesRows, err := conn.Query(
+		context.Background(),
+		`
+		SELECT
+			pg_class.relname AS table,
+			COALESCE(ancestors.schemas, '{}') AS partitioned_table_ancestor_schemas,
+			COALESCE(ancestors.tables, '{}') AS partitioned_table_ancestor_tables,
+			pg_class.relkind = 'p' AS partitioned,
+			pg_class.relkind IN ('v', 'm') AS view
+		FROM pg_class
+		JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
+		LEFT JOIN LATERAL (
+			WITH RECURSIVE ancestor_oids(oid) AS (
+				SELECT pg_inherits.inhparent FROM pg_inherits WHERE pg_inherits.inhrelid = pg_class.oid AND pg_class.relispartition
+				UNION ALL
+				SELECT pg_inherits.inhparent
+				FROM ancestor_oids
+				JOIN pg_class AS partition ON partition.oid = ancestor_oids.oid AND partition.relispartition
+				JOIN pg_inherits ON pg_inherits.inhrelid = ancestor_oids.oid
+			)
+			SELECT array_agg(ancestor_namespace.nspname::text) AS schemas, array_agg(ancestor.relname::text) AS tables
+			FROM ancestor_oids
+			JOIN pg_class AS ancestor ON ancestor.oid = ancestor_oids.oid
+			JOIN pg_namespace AS ancestor_namespace ON ancestor_namespace.oid = ancestor.relnamespace
+		) AS ancestors ON true
+		WHERE pg_namespace.nspname = $1 AND pg_class.relkind IN ('r', 'p', 'v', 'm') AND pg_class.relispopulated;
+		`,
+		schema,
+	)
+	PanicIfError(err)
+	defer tablesRows.Close()
+
+	for tablesRows.Next() {
+		pgSchemaTable := PgSchemaTable{Schema: schema}
+		var ancestorSchemas, ancestorTables []string
+		err = tablesRows.Scan(&pgSchemaTable.Table, &ancestorSchemas, &ancestorTables, &pgSchemaTable.Partitioned, &pgSchemaTable.View)
+		PanicIfError(err)
+		for i := range ancestorTables {
+			pgSchemaTable.PartitionedTableAncestors = append(pgSchemaTable.PartitionedTableAncestors, PgSchemaTable{Schema: ancestorSchemas[i], Table: ancestorTables[i], Partitioned: true})
+		}
+		pgSchemaTables = append(pgSchemaTables, pgSchemaTable)
+	}
+
+	return pgSchemaTables
+}
+
+func (syncer *Syncer) syncFromPgTable(conn *pgx.Conn, pgSchemaTable PgSchemaTable) {
+	LogInfo(syncer.config, "Syncing "+pgSchemaTable.String()+"...")
+
+	csvFile, err := syncer.exportPgTableToCsv(conn, pgSchemaTable)
+	PanicIfError(err)
+	defer csvFile.Close()
+
+	csvReader := csv.NewReader(csvFile)
+	csvHeader, err := csvReader.Read()
+	PanicIfError(err)
+
+	var pgSchemaColumns []PgSchemaColumn
+	if pgSchemaTable.View {
+		pgSchemaColumns = syncer.pgViewSchemaColumns(conn, pgSchemaTable)
+	} else {
+		pgSchemaColumns = syncer.pgTableSchemaColumns(conn, pgSchemaTable, csvHeader)
+	}
+	transformedPgSchemaColumns := syncer.transformPgSchemaColumns(pgSchemaTable, pgSchemaColumns)
+	reachedEnd := false
+	totalRowCount := 0
+
+	schemaTable := pgSchemaTable.ToIcebergSchemaTable()
+	syncer.icebergWriter.Write(schemaTable, transformedPgSchemaColumns, func() [][]string {
+		if reachedEnd {
+			return [][]string{}
+		}
+
+		var rows [][]string
+		for {
+			row, err := csvReader.Read()
+			if err != nil {
+				reachedEnd = true
+				break
+			}
+
+			rows = append(rows, syncer.transformRow(pgSchemaTable, pgSchemaColumns, row))
+			if len(rows) >= BATCH_SIZE {
+				break
+			}
+		}
+
+		totalRowCount += len(rows)
+		LogDebug(syncer.config, "Writing", totalRowCount, "rows to Parquet...")
+
+		// Ping the database to prevent the connection from being closed
+		if totalRowCount%(BATCH_SIZE*PING_INTERVAL_BETWEEN_BATCHES) == 0 {
+			LogDebug(syncer.config, "Pinging the database...")
+			_, err := conn.Exec(context.Background(), "SELECT 1")
+			PanicIfError(err)
+		}
+
+		return rows
+	})
+}
+
+// Skipped rows are missing from the synced tables until a later sync writes them successfully
+func (syncer *Syncer) reportSkippedParquetBatches() {
+	skippedParquetBatches := syncer.icebergWriter.TakeSkippedParquetBatches()
+	if len(skippedParquetBatches) == 0 {
+		return
+	}
+
+	LogWarn(syncer.config, "Sync finished with", len(skippedParquetBatches), "skipped batch(es):")
+	for _, skippedBatch := range skippedParquetBatches {
+		LogWarn(syncer.config, "-", skippedBatch.String())
+	}
+}
+
+func (syncer *Syncer) transformPgSchemaColumns(pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn) []PgSchemaColumn {
+	transformedPgSchemaColumns := pgSchemaColumns
+	for _, rowTransformer := range syncer.rowTransformers {
+		transformedPgSchemaColumns = rowTransformer.TransformSchemaColumns(pgSchemaTable, transformedPgSchemaColumns)
+	}
+	return transformedPgSchemaColumns
+}
+
+func (syncer *Syncer) transformRow(pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn, row []string) []string {
+	transformedPgSchemaColumns := pgSchemaColumns
+	for _, rowTransformer := range syncer.rowTransformers {
+		row = rowTransformer.TransformRow(pgSchemaTable, transformedPgSchemaColumns, row)
+		transformedPgSchemaColumns = rowTransformer.TransformSchemaColumns(pgSchemaTable, transformedPgSchemaColumns)
+	}
+	return row
+}
+
+func (syncer *Syncer) pgTableSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable, csvHeader []string) []PgSchemaColumn {
+	var pgSchemaColumns []PgSchemaColumn
+
+	rows, err := conn.Query(
+		context.Background(),
+		`SELECT
+			column_name,
+			data_type,
+			udt_name,
+			is_nullable,
+			ordinal_position,
+			COALESCE(character_maximum_length, 0),
+			COALESCE(numeric_precision, 0),
+			COALESCE(numeric_scale, 0),
+			COALESCE(datetime_precision, 0),
+			pg_namespace.nspname,
+			COALESCE((
+				SELECT array_position(pg_index.indkey::int2[], pg_attribute.attnum)
+				FROM pg_index
+				JOIN pg_attribute ON pg_attribute.attrelid = pg_index.indrelid
+				WHERE pg_index.indrelid = format('%I.%I', table_schema, table_name)::regclass AND pg_index.indisprimary AND pg_attribute.attname = column_name
+			), 0),
+			COALESCE((
+				SELECT array_agg(pg_enum.enumlabel ORDER BY pg_enum.enumsortorder)
+				FROM pg_enum
+				WHERE pg_enum.enumtypid = pg_type.oid
+			), '{}'),
+			COALESCE(column_default, '')
+		FROM information_schema.columns
+		JOIN pg_type ON pg_type.typname = udt_name
+		JOIN pg_namespace ON pg_namespace.oid = pg_type.typnamespace
+		WHERE table_schema = $1 AND table_name = $2
+		ORDER BY array_position($3, column_name)`,
+		pgSchemaTable.Schema,
+		pgSchemaTable.Table,
+		csvHeader,
+	)
+	PanicIfError(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var pgSchemaColumn PgSchemaColumn
+		err = rows.Scan(
+			&pgSchemaColumn.ColumnName,
+			&pgSchemaColumn.DataType,
+			&pgSchemaColumn.UdtName,
+			&pgSchemaColumn.IsNullable,
+			&pgSchemaColumn.OrdinalPosition,
+			&pgSchemaColumn.CharacterMaximumLength,
+			&pgSchemaColumn.NumericPrecision,
+			&pgSchemaColumn.NumericScale,
+			&pgSchemaColumn.DatetimePrecision,
+			&pgSchemaColumn.Namespace,
+			&pgSchemaColumn.PrimaryKeyPosition,
+			&pgSchemaColumn.EnumLabels,
+			&pgSchemaColumn.ColumnDefault,
+		)
+		PanicIfError(err)
+		pgSchemaColumns = append(pgSchemaColumns, pgSchemaColumn)
+	}
+
+	return pgSchemaColumns
+}
+
+// Materialized views aren't in information_schema.columns, so the columns of views are inferred from their result description.
+// Views have no primary key and their columns are nullable
+func (syncer *Syncer) pgViewSchemaColumns(conn *pgx.Conn, pgSchemaTable PgSchemaTable) []PgSchemaColumn {
+	ctx := context.Background()
+
+	rows, err := conn.Query(ctx, "SELECT * FROM "+pgSchemaTable.String()+" LIMIT 0")
+	PanicIfError(err)
+	fieldDescriptions := slices.Clone(rows.FieldDescriptions())
+	rows.Close()
+	PanicIfError(rows.Err())
+
+	pgResultTypes := make(map[uint32]PgResultType)
+	for _, fieldDescription := range fieldDescriptions {
+		if _, ok := pgResultTypes[fieldDescription.DataTypeOID]; ok {
+			continue
+		}
+
+		var pgResultType PgResultType
+		err = conn.QueryRow(
+			ctx,
+			`SELECT
+				pg_type.typname,
+				pg_namespace.nspname,
+				COALESCE(element_type.typname, ''),
+				COALESCE((
+					SELECT array_agg(pg_enum.enumlabel ORDER BY pg_enum.enumsortorder)
+					FROM pg_enum
+					WHERE pg_enum.enumtypid = pg_type.oid
+				), '{}')
+			FROM pg_type
+			JOIN pg_namespace ON pg_namespace.oid = pg_type.typnamespace
+			LEFT JOIN pg_type AS element_type ON element_type.oid = pg_type.typelem AND pg_type.typcategory = 'A'
+			WHERE pg_type.oid = $1`,
+			fieldDescription.DataTypeOID,
+		).Scan(&pgResultType.UdtName, &pgResultType.Namespace, &pgResultType.ElementUdtName, &pgResultType.EnumLabels)
+		PanicIfError(err)
+		pgResultTypes[fieldDescription.DataTypeOID] = pgResultType
+	}
+
+	return syncer.resultPgSchemaColumns(fieldDescriptions, pgResultTypes)
+}
+
+func (syncer *Syncer) resultPgSchemaColumns(fieldDescriptions []pgconn.FieldDescription, pgResultTypes map[uint32]PgResultType) []PgSchemaColumn {
+	pgSchemaColumns := make([]PgSchemaColumn, len(fieldDescriptions))
+	for i, fieldDescription := range fieldDescriptions {
+		pgResultType := pgResultTypes[fieldDescription.DataTypeOID]
+
+		var pgSchemaColumn PgSchemaColumn
+		if pgResultType.ElementUdtName != "" {
+			pgSchemaColumn = pgTypeSchemaColumn(pgResultType.ElementUdtName, pgResultType.Namespace, true, nil)
+		} else {
+			pgSchemaColumn = pgTypeSchemaColumn(pgResultType.UdtName, pgResultType.Namespace, false, pgTypeModifiers(pgResultType.UdtName, fieldDescription.TypeModifier))
+			pgSchemaColumn.EnumLabels = pgResultType.EnumLabels
+		}
+		pgSchemaColumn.ColumnName = fieldDescription.Name
+		pgSchemaColumn.OrdinalPosition = IntToString(i + 1)
+		pgSchemaColumn.IsNullable = PG_TRUE
+		pgSchemaColumn.PrimaryKeyPosition = "0"
+		pgSchemaColumns[i] = pgSchemaColumn
+	}
+	return pgSchemaColumns
+}
+
+func (syncer *Syncer) exportPgTableToCsv(conn *pgx.Conn, pgSchemaTable PgSchemaTable) (csvFile *os.File, err error) {
+	tempFile, err := CreateTemporaryFile(pgSchemaTable.String())
+	PanicIfError(err)
+	defer DeleteTemporaryFile(tempFile)
+
+	result, err := conn.PgConn().CopyTo(context.Background(), tempFile, syncer.copyPgTableQuery(pgSchemaTable))
+	PanicIfError(err)
+	LogDebug(syncer.config, "Copied", result.RowsAffected(), "row(s) into", tempFile.Name())
+
+	return os.Open(tempFile.Name())
+}
+
+// COPY doesn't read partitioned tables and views directly, so they are read through a query
+func (syncer *Syncer) copyPgTableQuery(pgSchemaTable PgSchemaTable) string {
+	source := pgSchemaTable.String()
+	if pgSchemaTable.Partitioned || pgSchemaTable.View {
+		source = "(SELECT * FROM " + pgSchemaTable.String() + ")"
+	}
+
+	return "COPY " + source + " TO STDOUT WITH CSV HEADER NULL '" + PG_NULL_STRING + "'"
+}
+
+func (syncer *Syncer) deleteOldIcebergSchemaTables(pgSchemaTables []PgSchemaTable) {
+	var prefixedPgSchemaTables []PgSchemaTable
+	for _, pgSchemaTable := range pgSchemaTables {
+		prefixedPgSchemaTables = append(
+			prefixedPgSchemaTables,
+			PgSchemaTable{Schema: syncer.config.Pg.SchemaPrefix + pgSchemaTable.Schema, Table: pgSchemaTable.Table},
+		)
+	}
+
+	icebergSchemas, err := syncer.icebergReader.Schemas()
+	PanicIfError(err)
+
+	for _, icebergSchema := range icebergSchemas {
+		found := false
+		for _, pgSchemaTable := range prefixedPgSchemaTables {
+			if icebergSchema == pgSchemaTable.Schema {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			LogInfo(syncer.config, "Deleting", icebergSchema, "...")
+			syncer.icebergWriter.DeleteSchema(icebergSchema)
+		}
+	}
+
+	icebergSchemaTables, err := syncer.icebergReader.SchemaTables()
+	PanicIfError(err)
+
+	for _, icebergSchemaTable := range icebergSchemaTables {
+		found := false
+		for _, pgSchemaTable := range prefixedPgSchemaTables {
+			if icebergSchemaTable.String() == pgSchemaTable.String() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			LogInfo(syncer.config, "Deleting", icebergSchemaTable.String(), "...")
+			syncer.icebergWriter.DeleteSchemaTable(icebergSchemaTable)
+		}
+	}
+}
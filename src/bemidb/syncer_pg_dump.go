@@ -0,0 +1,61 @@
+package bemidb
+
+import (
+	"io"
+	"os"
+)
+
+// Syncs tables from a plain-format pg_dump file without connecting to Postgres.
+// The file is read twice: first for the table definitions, since pg_dump adds primary keys after the data, then for the data
+func (syncer *Syncer) SyncFromPgDump(dumpPath string) {
+	_syncsInProgress.Add(1)
+	defer _syncsInProgress.Add(-1)
+	defer syncer.cacheStorageRequests()()
+
+	definitionsFile, err := os.Open(dumpPath)
+	PanicIfError(err)
+	pgDumpTables, err := NewPgDumpReader(definitionsFile).ReadTables()
+	definitionsFile.Close()
+	PanicIfError(err)
+
+	dataFile, err := os.Open(dumpPath)
+	PanicIfError(err)
+	defer dataFile.Close()
+
+	dumpReader := NewPgDumpReaderWithTables(dataFile, pgDumpTables)
+	for {
+		pgSchemaTable, pgSchemaColumns, err := dumpReader.NextCopy()
+		if err == io.EOF {
+			break
+		}
+		PanicIfError(err)
+
+		if syncer.shouldSyncTable(pgSchemaTable) {
+			syncer.syncFromPgDumpTable(dumpReader, pgSchemaTable, pgSchemaColumns)
+		}
+	}
+
+	syncer.reportSkippedParquetBatches()
+}
+
+func (syncer *Syncer) syncFromPgDumpTable(dumpReader *PgDumpReader, pgSchemaTable PgSchemaTable, pgSchemaColumns []PgSchemaColumn) {
+	LogInfo(syncer.config, "Syncing "+pgSchemaTable.String()+" from the dump...")
+
+	transformedPgSchemaColumns := syncer.transformPgSchemaColumns(pgSchemaTable, pgSchemaColumns)
+	totalRowCount := 0
+
+	schemaTable := pgSchemaTable.ToIcebergSchemaTable()
+	syncer.icebergWriter.Write(schemaTable, transformedPgSchemaColumns, func() [][]string {
+		rows, err := dumpReader.ReadRows(BATCH_SIZE)
+		PanicIfError(err)
+
+		for i, row := range rows {
+			rows[i] = syncer.transformRow(pgSchemaTable, pgSchemaColumns, row)
+		}
+
+		totalRowCount += len(rows)
+		LogDebug(syncer.config, "Writing", totalRowCount, "rows to Parquet...")
+
+		return rows
+	})
+}
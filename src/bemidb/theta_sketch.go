@@ -0,0 +1,201 @@
+package bemidb
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	THETA_SKETCH_DEFAULT_SEED       = 9001
+	THETA_SKETCH_NOMINAL_ENTRIES    = 4096
+	THETA_SKETCH_MAX_THETA          = math.MaxInt64
+	THETA_SKETCH_SERIAL_VERSION     = 3
+	THETA_SKETCH_FAMILY_COMPACT     = 3
+	THETA_SKETCH_FLAG_READ_ONLY     = 1 << 1
+	THETA_SKETCH_FLAG_EMPTY         = 1 << 2
+	THETA_SKETCH_FLAG_COMPACT       = 1 << 3
+	THETA_SKETCH_FLAG_ORDERED       = 1 << 4
+	THETA_SKETCH_FLAG_SINGLE_ITEM   = 1 << 5
+	THETA_SKETCH_REBUILD_MULTIPLIER = 2
+)
+
+// Estimates the number of distinct values by keeping the smallest hashes (KMV), compatible with Apache DataSketches theta sketches
+type ThetaSketch struct {
+	hashes     map[uint64]struct{}
+	thetaLong  uint64
+	isEmpty    bool
+	maxEntries int
+}
+
+func NewThetaSketch() *ThetaSketch {
+	return &ThetaSketch{
+		hashes:     map[uint64]struct{}{},
+		thetaLong:  THETA_SKETCH_MAX_THETA,
+		isEmpty:    true,
+		maxEntries: THETA_SKETCH_NOMINAL_ENTRIES,
+	}
+}
+
+func (sketch *ThetaSketch) Update(value []byte) {
+	sketch.isEmpty = false
+
+	h1, _ := murmurHash3x64128(value, THETA_SKETCH_DEFAULT_SEED)
+	hash := h1 >> 1
+	if hash == 0 || hash >= sketch.thetaLong {
+		return
+	}
+
+	sketch.hashes[hash] = struct{}{}
+	if len(sketch.hashes) >= sketch.maxEntries*THETA_SKETCH_REBUILD_MULTIPLIER {
+		sketch.rebuild()
+	}
+}
+
+func (sketch *ThetaSketch) Estimate() float64 {
+	sketch.rebuild()
+	return float64(len(sketch.hashes)) / (float64(sketch.thetaLong) / THETA_SKETCH_MAX_THETA)
+}
+
+// Serializes the sketch in the DataSketches compact ordered format (serial version 3), little-endian
+func (sketch *ThetaSketch) CompactBytes() []byte {
+	sketch.rebuild()
+
+	sortedHashes := make([]uint64, 0, len(sketch.hashes))
+	for hash := range sketch.hashes {
+		sortedHashes = append(sortedHashes, hash)
+	}
+	slices.Sort(sortedHashes)
+
+	isEmpty := sketch.isEmpty && sketch.thetaLong == THETA_SKETCH_MAX_THETA
+	flags := byte(THETA_SKETCH_FLAG_READ_ONLY | THETA_SKETCH_FLAG_COMPACT | THETA_SKETCH_FLAG_ORDERED)
+	preambleLongs := 1
+	switch {
+	case sketch.thetaLong < THETA_SKETCH_MAX_THETA:
+		preambleLongs = 3
+	case isEmpty:
+		flags |= THETA_SKETCH_FLAG_EMPTY
+	case len(sortedHashes) == 1:
+		flags |= THETA_SKETCH_FLAG_SINGLE_ITEM
+	case len(sortedHashes) > 1:
+		preambleLongs = 2
+	}
+
+	data := make([]byte, 8*preambleLongs, 8*(preambleLongs+len(sortedHashes)))
+	data[0] = byte(preambleLongs)
+	data[1] = THETA_SKETCH_SERIAL_VERSION
+	data[2] = THETA_SKETCH_FAMILY_COMPACT
+	data[5] = flags
+	binary.LittleEndian.PutUint16(data[6:8], thetaSketchSeedHash(THETA_SKETCH_DEFAULT_SEED))
+	if preambleLongs > 1 {
+		binary.LittleEndian.PutUint32(data[8:12], uint32(len(sortedHashes)))
+		binary.LittleEndian.PutUint32(data[12:16], math.Float32bits(1.0)) // sampling probability
+	}
+	if preambleLongs > 2 {
+		binary.LittleEndian.PutUint64(data[16:24], sketch.thetaLong)
+	}
+
+	for _, hash := range sortedHashes {
+		data = binary.LittleEndian.AppendUint64(data, hash)
+	}
+
+	return data
+}
+
+// Keeps only the nominal number of smallest hashes and lowers theta to the smallest dropped hash
+func (sketch *ThetaSketch) rebuild() {
+	if len(sketch.hashes) <= sketch.maxEntries {
+		return
+	}
+
+	sortedHashes := make([]uint64, 0, len(sketch.hashes))
+	for hash := range sketch.hashes {
+		sortedHashes = append(sortedHashes, hash)
+	}
+	slices.Sort(sortedHashes)
+
+	sketch.thetaLong = sortedHashes[sketch.maxEntries]
+	for _, hash := range sortedHashes[sketch.maxEntries:] {
+		delete(sketch.hashes, hash)
+	}
+}
+
+// Identifies the seed in serialized sketches, so sketches built with different seeds aren't merged
+func thetaSketchSeedHash(seed uint64) uint16 {
+	seedBytes := binary.LittleEndian.AppendUint64(nil, seed)
+	h1, _ := murmurHash3x64128(seedBytes, 0)
+	return uint16(h1)
+}
+
+// MurmurHash3_x64_128 as used by Apache DataSketches
+func murmurHash3x64128(data []byte, seed uint64) (h1 uint64, h2 uint64) {
+	const c1 = 0x87c37b91114253d5
+	const c2 = 0x4cf5ad432745937f
+
+	h1, h2 = seed, seed
+	length := len(data)
+	blockCount := length / 16
+
+	for i := 0; i < blockCount; i++ {
+		k1 := binary.LittleEndian.Uint64(data[i*16:])
+		k2 := binary.LittleEndian.Uint64(data[i*16+8:])
+
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	tail := data[blockCount*16:]
+	var k1, k2 uint64
+	for i := len(tail) - 1; i >= 8; i-- {
+		k2 ^= uint64(tail[i]) << (8 * (i - 8))
+	}
+	if len(tail) > 8 {
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+	}
+	for i := min(len(tail), 8) - 1; i >= 0; i-- {
+		k1 ^= uint64(tail[i]) << (8 * i)
+	}
+	if len(tail) > 0 {
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+	}
+
+	h1 ^= uint64(length)
+	h2 ^= uint64(length)
+	h1 += h2
+	h2 += h1
+	h1 = murmurHash3Fmix64(h1)
+	h2 = murmurHash3Fmix64(h2)
+	h1 += h2
+	h2 += h1
+
+	return h1, h2
+}
+
+func murmurHash3Fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,44 @@
+package bemidb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Default pg_trgm.similarity_threshold used by the % operator
+const PG_TRIGRAM_SIMILARITY_THRESHOLD = 0.3
+
+// Similarity of two strings like pg_trgm's similarity(): the number of shared trigrams divided by the number of distinct trigrams in both
+func TrigramSimilarity(a string, b string) float32 {
+	trigramsA := trigrams(a)
+	trigramsB := trigrams(b)
+	if len(trigramsA) == 0 || len(trigramsB) == 0 {
+		return 0
+	}
+
+	sharedCount := 0
+	for trigram := range trigramsA {
+		if trigramsB[trigram] {
+			sharedCount++
+		}
+	}
+
+	return float32(sharedCount) / float32(len(trigramsA)+len(trigramsB)-sharedCount)
+}
+
+// Lowercased words of letters and digits, each padded with two spaces before and one after, e.g. "cat" -> "  c", " ca", "cat", "at "
+func trigrams(text string) map[string]bool {
+	result := make(map[string]bool)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+	})
+	for _, word := range words {
+		paddedWord := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(paddedWord); i++ {
+			result[string(paddedWord[i:i+3])] = true
+		}
+	}
+
+	return result
+}
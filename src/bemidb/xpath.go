@@ -0,0 +1,202 @@
+package bemidb
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	XPATH_TEXT_TEST      = "text()"
+	XPATH_WILDCARD_TEST  = "*"
+	XPATH_ATTRIBUTE_AXIS = "@"
+)
+
+// name, *, text() or @name, optionally followed by a position, e.g. item[2]
+var XPATH_STEP_REGEX = regexp.MustCompile(`^(text\(\)|@?[A-Za-z_][\w.\-]*|@?\*)(?:\[(\d+)\])?$`)
+
+// Parsed XML element or text, keeping its original markup so that matches are returned as they were synced
+type xpathNode struct {
+	name     string // element name, "" for text
+	attrs    []xml.Attr
+	children []*xpathNode
+	markup   string
+}
+
+type xpathStep struct {
+	descendant bool // //step
+	test       string
+	position   int // 1-based, 0 for all matches
+}
+
+// Evaluates a location path on an XML document stored as text, like Postgres xpath() does.
+// Supports a subset of XPath 1.0: /a/b, //b, *, [n], text() and @name, without namespaces
+func EvaluateXPath(path string, document string) (matches []string, err error) {
+	steps, err := parseXPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseXPathDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	contextNodes := []*xpathNode{root}
+	for i, step := range steps {
+		if strings.HasPrefix(step.test, XPATH_ATTRIBUTE_AXIS) {
+			if i != len(steps)-1 {
+				return nil, fmt.Errorf("unsupported XPath expression: %s", path)
+			}
+			return xpathAttributeValues(contextNodes, step), nil
+		}
+
+		var nextContextNodes []*xpathNode
+		for _, contextNode := range contextNodes {
+			nextContextNodes = append(nextContextNodes, xpathStepMatches(contextNode, step)...)
+		}
+		contextNodes = nextContextNodes
+	}
+
+	for _, contextNode := range contextNodes {
+		matches = append(matches, contextNode.markup)
+	}
+	return matches, nil
+}
+
+func parseXPath(path string) (steps []xpathStep, err error) {
+	remainingPath := strings.TrimSpace(path)
+	if remainingPath == "" || remainingPath == "/" {
+		return nil, fmt.Errorf("empty XPath expression")
+	}
+
+	for remainingPath != "" {
+		step := xpathStep{}
+		switch {
+		case strings.HasPrefix(remainingPath, "//"):
+			step.descendant = true
+			remainingPath = remainingPath[2:]
+		case strings.HasPrefix(remainingPath, "/"):
+			remainingPath = remainingPath[1:]
+		}
+
+		stepText := remainingPath
+		remainingPath = ""
+		if separatorIndex := strings.Index(stepText, "/"); separatorIndex >= 0 {
+			stepText, remainingPath = stepText[:separatorIndex], stepText[separatorIndex:]
+		}
+
+		match := XPATH_STEP_REGEX.FindStringSubmatch(stepText)
+		if match == nil {
+			return nil, fmt.Errorf("unsupported XPath expression: %s", path)
+		}
+		step.test = match[1]
+		if match[2] != "" {
+			step.position, _ = strconv.Atoi(match[2])
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+func parseXPathDocument(document string) (*xpathNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+
+	root := &xpathNode{}
+	stack := []*xpathNode{root}
+	var startOffsets []int64
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse XML document: %v", err)
+		}
+
+		parent := stack[len(stack)-1]
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &xpathNode{name: token.Name.Local, attrs: token.Attr}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+			startOffsets = append(startOffsets, offset)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("could not parse XML document: unexpected end element </%s>", token.Name.Local)
+			}
+			parent.markup = document[startOffsets[len(startOffsets)-1]:decoder.InputOffset()]
+			stack = stack[:len(stack)-1]
+			startOffsets = startOffsets[:len(startOffsets)-1]
+		case xml.CharData:
+			parent.children = append(parent.children, &xpathNode{markup: document[offset:decoder.InputOffset()]})
+		}
+	}
+
+	return root, nil
+}
+
+// Returns the matches in document order, the position applies to the matching children of each element
+func xpathStepMatches(contextNode *xpathNode, step xpathStep) (matches []*xpathNode) {
+	var childMatches []*xpathNode
+	for _, child := range contextNode.children {
+		if xpathNodeMatches(child, step.test) {
+			childMatches = append(childMatches, child)
+		}
+	}
+	if step.position > 0 {
+		if step.position > len(childMatches) {
+			childMatches = nil
+		} else {
+			childMatches = childMatches[step.position-1 : step.position]
+		}
+	}
+
+	for _, child := range contextNode.children {
+		if slices.Contains(childMatches, child) {
+			matches = append(matches, child)
+		}
+		if step.descendant {
+			matches = append(matches, xpathStepMatches(child, step)...)
+		}
+	}
+	return matches
+}
+
+func xpathNodeMatches(node *xpathNode, test string) bool {
+	switch test {
+	case XPATH_TEXT_TEST:
+		return node.name == ""
+	case XPATH_WILDCARD_TEST:
+		return node.name != ""
+	default:
+		return node.name == test
+	}
+}
+
+func xpathAttributeValues(contextNodes []*xpathNode, step xpathStep) (values []string) {
+	for _, contextNode := range contextNodes {
+		nodes := []*xpathNode{contextNode}
+		if step.descendant {
+			nodes = xpathStepMatches(contextNode, xpathStep{descendant: true, test: XPATH_WILDCARD_TEST})
+		}
+
+		for _, node := range nodes {
+			for _, attr := range node.attrs {
+				if step.test == XPATH_ATTRIBUTE_AXIS+XPATH_WILDCARD_TEST || step.test == XPATH_ATTRIBUTE_AXIS+attr.Name.Local {
+					var escapedValue strings.Builder
+					xml.EscapeText(&escapedValue, []byte(attr.Value))
+					values = append(values, escapedValue.String())
+				}
+			}
+		}
+	}
+	return values
+}
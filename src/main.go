@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"time"
-)
 
-const VERSION = "0.28.3"
+	"github.com/BemiHQ/BemiDB/bemidb"
+)
 
 func main() {
-	config := LoadConfig()
+	config := bemidb.LoadConfig()
 
 	if len(flag.Args()) == 0 {
 		start(config)
@@ -22,53 +23,116 @@ func main() {
 	case "start":
 		start(config)
 	case "sync":
+		if config.Maintenance.Interval != "" {
+			go bemidb.NewMaintainer(config).Run()
+		}
 		if config.Pg.SyncInterval != "" {
 			duration, err := time.ParseDuration(config.Pg.SyncInterval)
 			if err != nil {
 				panic("Invalid interval format: " + config.Pg.SyncInterval)
 			}
-			LogInfo(config, "Starting sync loop with interval:", config.Pg.SyncInterval)
+			bemidb.LogInfo(config, "Starting sync loop with interval:", config.Pg.SyncInterval)
 			for {
 				syncFromPg(config)
-				LogInfo(config, "Sleeping for", config.Pg.SyncInterval)
+				bemidb.LogInfo(config, "Sleeping for", config.Pg.SyncInterval)
 				time.Sleep(duration)
 			}
 		} else {
 			syncFromPg(config)
 		}
+	case "sync-dump":
+		// bemidb sync-dump dump.sql
+		if flag.Arg(1) == "" {
+			panic("Usage: bemidb sync-dump dump.sql")
+		}
+		err := bemidb.NewSyncer(config).SyncPgDump(context.Background(), flag.Arg(1))
+		bemidb.PanicIfError(err)
+		bemidb.LogInfo(config, "Sync from the pg_dump file completed successfully.")
+	case "tag":
+		// bemidb tag schema.table tag [ref]
+		if flag.Arg(1) == "" || flag.Arg(2) == "" {
+			panic("Usage: bemidb tag schema.table tag [ref]")
+		}
+		fromRef := bemidb.ICEBERG_MAIN_BRANCH
+		if flag.Arg(3) != "" {
+			fromRef = flag.Arg(3)
+		}
+		icebergSchemaTable := bemidb.ParseIcebergSchemaTable(flag.Arg(1))
+		err := bemidb.NewIcebergWriter(config).CreateTag(icebergSchemaTable, flag.Arg(2), fromRef)
+		bemidb.PanicIfError(err)
+		bemidb.LogInfo(config, "Tagged", icebergSchemaTable.String(), "as", flag.Arg(2), "from", fromRef)
+	case "promote":
+		// bemidb promote schema.table ref
+		if flag.Arg(1) == "" || flag.Arg(2) == "" {
+			panic("Usage: bemidb promote schema.table ref")
+		}
+		icebergSchemaTable := bemidb.ParseIcebergSchemaTable(flag.Arg(1))
+		err := bemidb.NewIcebergWriter(config).PromoteRef(icebergSchemaTable, flag.Arg(2))
+		bemidb.PanicIfError(err)
+		bemidb.LogInfo(config, "Promoted", flag.Arg(2), "to", bemidb.ICEBERG_MAIN_BRANCH, "in", icebergSchemaTable.String())
+	case "vacuum":
+		// bemidb vacuum schema.table
+		if flag.Arg(1) == "" {
+			panic("Usage: bemidb vacuum schema.table")
+		}
+		icebergSchemaTable := bemidb.ParseIcebergSchemaTable(flag.Arg(1))
+		deletedFilePaths, err := bemidb.NewIcebergWriter(config).Vacuum(icebergSchemaTable)
+		bemidb.PanicIfError(err)
+		bemidb.LogInfo(config, "Deleted", len(deletedFilePaths), "orphaned file(s) from", icebergSchemaTable.String())
+	case "compact":
+		// bemidb compact schema.table
+		if flag.Arg(1) == "" {
+			panic("Usage: bemidb compact schema.table")
+		}
+		icebergSchemaTable := bemidb.ParseIcebergSchemaTable(flag.Arg(1))
+		unlock := bemidb.LockIcebergSchemaTable(icebergSchemaTable)
+		err := bemidb.NewIcebergWriter(config).CompactTable(icebergSchemaTable)
+		unlock()
+		bemidb.PanicIfError(err)
+	case "expire-snapshots":
+		// bemidb expire-snapshots schema.table
+		if flag.Arg(1) == "" {
+			panic("Usage: bemidb expire-snapshots schema.table")
+		}
+		if config.Maintenance.SnapshotMaxAge == 0 {
+			panic("Missing snapshot max age, set --maintenance-snapshot-max-age")
+		}
+		icebergSchemaTable := bemidb.ParseIcebergSchemaTable(flag.Arg(1))
+		unlock := bemidb.LockIcebergSchemaTable(icebergSchemaTable)
+		err := bemidb.NewIcebergWriter(config).ExpireSnapshots(icebergSchemaTable)
+		unlock()
+		bemidb.PanicIfError(err)
+	case "catalog-diff":
+		// bemidb catalog-diff target-storage-path
+		if flag.Arg(1) == "" {
+			panic("Usage: bemidb catalog-diff target-storage-path")
+		}
+		catalogTableDiffs, err := bemidb.DiffCatalogs(config, bemidb.ParseCatalogDiffTargetConfig(config, flag.Arg(1)))
+		bemidb.PanicIfError(err)
+		for _, catalogTableDiff := range catalogTableDiffs {
+			fmt.Println(catalogTableDiff.String())
+		}
+		bemidb.LogInfo(config, "Found", len(catalogTableDiffs), "table(s) that differ in", flag.Arg(1))
 	case "version":
-		fmt.Println("BemiDB version:", VERSION)
+		fmt.Println("BemiDB version:", bemidb.VERSION)
 	default:
 		panic("Unknown command: " + command)
 	}
 }
 
-func start(config *Config) {
-	tcpListener := NewTcpListener(config)
-	LogInfo(config, "BemiDB: Listening on", tcpListener.Addr())
-
-	duckdb := NewDuckdb(config)
-	LogInfo(config, "DuckDB: Connected")
-	defer duckdb.Close()
+func start(config *bemidb.Config) {
+	tcpListener := bemidb.NewTcpListener(config)
+	bemidb.LogInfo(config, "BemiDB: Listening on", tcpListener.Addr())
 
-	icebergReader := NewIcebergReader(config)
-	queryHandler := NewQueryHandler(config, duckdb, icebergReader)
+	engine := bemidb.NewEngine(config)
+	bemidb.LogInfo(config, "DuckDB: Connected")
+	defer engine.Close()
 
-	for {
-		conn := AcceptConnection(tcpListener)
-		LogInfo(config, "BemiDB: Accepted connection from", conn.RemoteAddr())
-		postgres := NewPostgres(config, &conn)
-
-		go func() {
-			postgres.Run(queryHandler)
-			defer postgres.Close()
-			LogInfo(config, "BemiDB: Closed connection from", conn.RemoteAddr())
-		}()
-	}
+	bemidb.Serve(config, tcpListener, engine, bemidb.NewConnectionLimiter(config))
 }
 
-func syncFromPg(config *Config) {
-	syncer := NewSyncer(config)
-	syncer.SyncFromPostgres()
-	LogInfo(config, "Sync from PostgreSQL completed successfully.")
+func syncFromPg(config *bemidb.Config) {
+	err := bemidb.NewSyncer(config).Sync(context.Background())
+	bemidb.PanicIfError(err)
+	bemidb.LogInfo(config, "Sync from PostgreSQL completed successfully.")
 }